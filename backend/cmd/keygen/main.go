@@ -0,0 +1,118 @@
+// Command keygen runs the key generation service. It keeps the Redis queue
+// the URL service pops short codes from filled, and hands out codes directly
+// on GET /generate when the queue runs dry
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joho/godotenv"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/config"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/keygen"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+	"github.com/redis/go-redis/v9"
+)
+
+// Code lengths must pass short code validation, and 62^length must fit a uint64
+const (
+	minCodeLength = 3
+	maxCodeLength = 10
+)
+
+func main() {
+	flags, err := config.ParseFlags(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid flags: %v", err)
+	}
+	if flags.Migrate != "" {
+		log.Fatalf("--migrate is only run by the server")
+	}
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+	cfg, err := config.LoadConfig(flags)
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	if flags.ValidateOnly {
+		log.Println("Configuration is valid")
+		return
+	}
+	if cfg.Keygen.CodeLength < minCodeLength || cfg.Keygen.CodeLength > maxCodeLength {
+		log.Fatalf("KEY_CODE_LENGTH must be between %d and %d", minCodeLength, maxCodeLength)
+	}
+	if cfg.Keygen.RangeSize < 1 {
+		log.Fatalf("KEY_RANGE_SIZE must be positive")
+	}
+	redisOptions := &redis.UniversalOptions{
+		Addrs:            cfg.Redis.Addresses,
+		Password:         cfg.Redis.Password,
+		DB:               cfg.Redis.DB,
+		SentinelPassword: cfg.Redis.SentinelPassword,
+		IsClusterMode:    cfg.Redis.Mode == "cluster",
+	}
+	if cfg.Redis.Mode == "sentinel" {
+		redisOptions.MasterName = cfg.Redis.MasterName
+	}
+	redisClient := redis.NewUniversalClient(redisOptions)
+	defer redisClient.Close()
+
+	reserved := validators.NewReservedWords(cfg.ReservedCodes)
+	generator := keygen.NewGenerator(redisClient, cfg.Keygen.RangeSize, cfg.Keygen.CodeLength, reserved)
+	filler := keygen.NewFiller(generator, redisClient, cfg.Keygen.Queue, cfg.Keygen.QueueSize, cfg.Keygen.RefillInterval)
+	filler.Start()
+	defer filler.Stop()
+
+	router := gin.Default()
+	router.GET("/generate", func(c *gin.Context) {
+		shortCode, err := generator.Next(c.Request.Context())
+		if err != nil {
+			log.Printf("Failed to generate short code: %v", err)
+			status := http.StatusServiceUnavailable
+			if errors.Is(err, keygen.ErrExhausted) {
+				status = http.StatusInternalServerError
+			}
+			utils.RespondWithError(c, status, "Failed to generate short code")
+			return
+		}
+		utils.RespondWithJSON(c, http.StatusOK, gin.H{"short_code": shortCode})
+	})
+	router.GET("/health", func(c *gin.Context) {
+		length, err := filler.QueueLength(c.Request.Context())
+		if err != nil {
+			utils.RespondWithJSON(c, http.StatusServiceUnavailable, gin.H{"status": "unhealthy", "redis": err.Error()})
+			return
+		}
+		utils.RespondWithJSON(c, http.StatusOK, gin.H{"status": "healthy", "queue_length": length, "queue_target": cfg.Keygen.QueueSize})
+	})
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%s", cfg.Keygen.Port),
+		Handler: router,
+	}
+	go func() {
+		log.Printf("Key generation service starting on port %s", cfg.Keygen.Port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("Shutting down the key generation service...")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Fatalf("server forced to shutdown: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+// Command migrate-cassandra copies every live link from MongoDB into the
+// Cassandra redirect store. Run it before switching REDIRECT_STORE to
+// cassandra; it is safe to run again, and to run while servers already
+// writing to the store are up, since their writes are newer than its copies
+package main
+
+import (
+	"context"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/breaker"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/config"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+)
+
+// progressInterval is how many links are copied between progress lines
+const progressInterval = 10000
+
+func main() {
+	flags, err := config.ParseFlags(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid flags: %v", err)
+	}
+	if flags.Migrate != "" {
+		log.Fatalf("--migrate is only run by the server")
+	}
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+	cfg, err := config.LoadConfig(flags)
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	if flags.ValidateOnly {
+		log.Println("Configuration is valid")
+		return
+	}
+	if len(cfg.Cassandra.Hosts) == 0 {
+		log.Fatalf("CASSANDRA_HOSTS must be set")
+	}
+	ctx := context.Background()
+
+	mongoOptions := repository.MongoOptions{
+		URI:                    cfg.MongoDB.URI,
+		MaxPoolSize:            uint64(cfg.MongoDB.MaxPoolSize),
+		MinPoolSize:            uint64(cfg.MongoDB.MinPoolSize),
+		MaxConnIdleTime:        cfg.MongoDB.MaxConnIdleTime,
+		ReadPreference:         cfg.MongoDB.ReadPreference,
+		WriteConcern:           cfg.MongoDB.WriteConcern,
+		ConnectTimeout:         cfg.MongoDB.ConnectTimeout,
+		ServerSelectionTimeout: cfg.MongoDB.ServerSelectionTimeout,
+		// The copy is one long scan, so MONGODB_OPERATION_TIMEOUT is left out
+	}
+	mongoClient, err := repository.ConnectMongo(mongoOptions)
+	if err != nil {
+		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	defer mongoClient.Disconnect(ctx)
+	// With MONGODB_SHARDS set the links live on the shards, and the copy
+	// reads every one of them
+	shardClients, err := repository.ConnectMongoShards(mongoOptions, cfg.MongoDB.Shards)
+	if err != nil {
+		log.Fatalf("Failed to connect to the MongoDB shards: %v", err)
+	}
+	threshold := uint32(cfg.Breaker.FailureThreshold)
+	shards := []repository.MongoShard{{
+		Name:    "default",
+		Client:  mongoClient,
+		Breaker: breaker.New("mongodb", threshold, cfg.Breaker.OpenTimeout, repository.IsExpectedMongoError),
+	}}
+	if len(shardClients) > 0 {
+		shards = shards[:0]
+		for _, name := range slices.Sorted(maps.Keys(shardClients)) {
+			defer shardClients[name].Disconnect(ctx)
+			shards = append(shards, repository.MongoShard{
+				Name:    name,
+				Client:  shardClients[name],
+				Breaker: breaker.New("mongodb-"+name, threshold, cfg.Breaker.OpenTimeout, repository.IsExpectedMongoError),
+			})
+		}
+	}
+	mongoRepo, err := repository.NewShardedMongoRepository(ctx, shards, cfg.MongoDB.Database, "short_urls")
+	if err != nil {
+		log.Fatalf("Failed to create MongoDB repository: %v", err)
+	}
+	cassandraRepo, err := repository.NewCassandraRepository(repository.CassandraOptions{
+		Hosts:       cfg.Cassandra.Hosts,
+		Keyspace:    cfg.Cassandra.Keyspace,
+		Username:    cfg.Cassandra.Username,
+		Password:    cfg.Cassandra.Password,
+		Consistency: cfg.Cassandra.Consistency,
+		Timeout:     cfg.Cassandra.Timeout,
+	}, breaker.New("cassandra", threshold, cfg.Breaker.OpenTimeout, repository.IsExpectedCassandraError))
+	if err != nil {
+		log.Fatalf("Failed to connect to Cassandra: %v", err)
+	}
+	defer cassandraRepo.Close()
+
+	// Every copy is written as read when the migration started, so any
+	// change a server made to the store since then wins over it
+	readAt := time.Now()
+	var copied int64
+	err = mongoRepo.ForEachShortURL(ctx, func(shortURL *models.ShortURL) error {
+		if err := cassandraRepo.SaveShortURL(ctx, shortURL, readAt); err != nil {
+			return err
+		}
+		if copied++; copied%progressInterval == 0 {
+			log.Printf("Copied %d links", copied)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed after copying %d links: %v", copied, err)
+	}
+	log.Printf("Copied %d links to Cassandra", copied)
+}
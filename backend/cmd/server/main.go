@@ -2,120 +2,920 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
+	"maps"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/breaker"
 	"github.com/ranjanshahajishitole/url-shortener/backend/internal/config"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/events"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/geoip"
 	"github.com/ranjanshahajishitole/url-shortener/backend/internal/handlers"
 	"github.com/ranjanshahajishitole/url-shortener/backend/internal/middleware"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/migrations"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
 	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
 	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/storage"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/tracing"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 func main() {
+	flags, err := config.ParseFlags(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid flags: %v", err)
+	}
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found")
 	}
-	cfg, err := config.LoadConfig()
+	cfg, err := config.LoadConfig(flags)
 	if err != nil {
-		log.Fatalf("Failed to load Config: %v", err)
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	if flags.ValidateOnly {
+		log.Println("Configuration is valid")
+		return
 	}
-	mongoClient, err := connectMongoDB(cfg.MongoDB.URI)
+	mongoClient, err := repository.ConnectMongo(mongoOptions(cfg))
 	if err != nil {
-		log.Fatalf("Failed to connect to MongoDB: %v", err)
+		log.Fatalf("Failed to configure MongoDB client: %v", err)
 	}
 	defer mongoClient.Disconnect(context.Background())
-	log.Println("Connected to MongoDB")
+	shardClients, err := repository.ConnectMongoShards(mongoOptions(cfg), cfg.MongoDB.Shards)
+	if err != nil {
+		log.Fatalf("Failed to configure MongoDB shard clients: %v", err)
+	}
+	for _, client := range shardClients {
+		defer client.Disconnect(context.Background())
+	}
+	redisClient := connectRedis(cfg)
+	instances := services.NewInstanceRegistry(redisClient, cfg.Instance.ID, cfg.Server.Port, cfg.Instance.Region, cfg.Instance.HeartbeatInterval)
+	// Every log line names the replica that wrote it
+	log.SetPrefix("[" + instances.ID() + "] ")
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.Endpoint, cfg.Tracing.ServiceName, instances.ID(), cfg.Instance.Region, cfg.Tracing.SampleRatio)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	// Installed after Setup, since it picks up the tracer provider right away
+	if err := redisotel.InstrumentTracing(redisClient); err != nil {
+		log.Fatalf("Failed to instrument Redis: %v", err)
+	}
+	if flags.Migrate != "" {
+		migrate(flags.Migrate, migrations.NewMigrator(mongoClient, cfg.MongoDB.Database, instances.ID()))
+		return
+	}
+	invalidations := services.NewInvalidationBus(redisClient, instances.ID())
+	invalidations.Start()
+	defer invalidations.Stop()
 
-	redisClient := connectRedis(cfg.Redis.Address, cfg.Redis.Password, cfg.Redis.DB)
-	if redisClient == nil {
-		log.Fatalf("Failed to connect to Redis")
+	// Unreachable dependencies don't stop startup: the server comes up
+	// degraded, the clients keep reconnecting, and /readyz reports the state
+	waitForDependency("MongoDB", cfg.Startup.RetryTimeout, func(ctx context.Context) error {
+		return mongoClient.Ping(ctx, nil)
+	})
+	for _, name := range slices.Sorted(maps.Keys(shardClients)) {
+		waitForDependency("MongoDB shard "+name, cfg.Startup.RetryTimeout, func(ctx context.Context) error {
+			return shardClients[name].Ping(ctx, nil)
+		})
 	}
-	mongoRepo, err := repository.NewMongoRepository(mongoClient, cfg.MongoDB.Database, "short_urls")
+	waitForDependency("Redis", cfg.Startup.RetryTimeout, func(ctx context.Context) error {
+		return redisClient.Ping(ctx).Err()
+	})
+	// setupCtx bounds startup work that may outlive startup, such as index
+	// creation retried in the background; it ends at shutdown
+	setupCtx, endSetup := context.WithCancel(context.Background())
+	defer endSetup()
+	if cfg.Startup.Migrate {
+		migrateOnStart(setupCtx, migrations.NewMigrator(mongoClient, cfg.MongoDB.Database, instances.ID()))
+	}
+	threshold := uint32(cfg.Breaker.FailureThreshold)
+	mongoBreaker := breaker.New("mongodb", threshold, cfg.Breaker.OpenTimeout, repository.IsExpectedMongoError)
+	redisBreaker := breaker.New("redis", threshold, cfg.Breaker.OpenTimeout, func(err error) bool {
+		return errors.Is(err, redis.Nil) || errors.Is(err, context.Canceled)
+	})
+	mongoRepo, err := newLinkRepository(setupCtx, cfg, mongoClient, mongoBreaker, shardClients)
 	if err != nil {
 		log.Fatalf("Failed to create MongoDB repository: %v", err)
 	}
+	// Redirects may read nearby secondaries; writes always go to the primary
+	if cfg.MongoDB.RedirectReadPreference != "" {
+		pref, err := repository.ParseReadPreference(cfg.MongoDB.RedirectReadPreference)
+		if err == nil {
+			err = mongoRepo.SetRedirectReadPreference(pref)
+		}
+		if err != nil {
+			log.Fatalf("Failed to set the redirect read preference: %v", err)
+		}
+	}
+	// Sandbox links stay on MONGODB_URI, whatever the shards
+	sandboxRepo, err := repository.NewSandboxRepository(setupCtx, mongoClient, cfg.MongoDB.Database, "sandbox_links", mongoBreaker)
+	if err != nil {
+		log.Fatalf("Failed to create sandbox link repository: %v", err)
+	}
 	_ = repository.NewHealthCheckRepository(mongoClient, cfg.MongoDB.Database, "health_checks") // Reserved for future health check endpoints
-	keyService := services.NewKeyService(redisClient, cfg.KeyGenServiceURL, "short_code_queue")
-	urlService := services.NewURLService(mongoRepo, keyService)
+	healthService := services.NewHealthService(mongoClient, redisClient, shardClients)
+	botFilter := services.NewBotFilter(cfg.Bots.UserAgents, cfg.Bots.Challenge, cfg.Bots.ChallengeSecret, cfg.Bots.CountCrawlers)
+	errorPages, err := handlers.NewErrorPages(cfg.ErrorPages.FallbackURL, cfg.ErrorPages.Template)
+	if err != nil {
+		log.Fatalf("Failed to load error pages: %v", err)
+	}
+	cloakPages, err := handlers.NewCloakPages(cfg.Cloak.Template)
+	if err != nil {
+		log.Fatalf("Failed to load the cloak page: %v", err)
+	}
+	robotsTxt, err := handlers.NewRobotsTxt(cfg.Robots.Template)
+	if err != nil {
+		log.Fatalf("Failed to load robots.txt: %v", err)
+	}
+	var geo *geoip.Database
+	if cfg.GeoIP.Path != "" {
+		if geo, err = geoip.Open(cfg.GeoIP.Path, cfg.GeoIP.ReloadInterval); err != nil {
+			log.Fatalf("Failed to load the GeoIP database: %v", err)
+		}
+		geo.Start()
+		defer geo.Stop()
+	}
+	apiKeyRepo, err := repository.NewAPIKeyRepository(setupCtx, mongoClient, cfg.MongoDB.Database, "api_keys")
+	if err != nil {
+		log.Fatalf("Failed to create API key repository: %v", err)
+	}
+	clickRepo, err := repository.NewClickRepository(setupCtx, mongoClient, cfg.MongoDB.Database, "click_events")
+	if err != nil {
+		log.Fatalf("Failed to create click event repository: %v", err)
+	}
+	auditRepo, err := repository.NewAuditRepository(setupCtx, mongoClient, cfg.MongoDB.Database, "audit_log")
+	if err != nil {
+		log.Fatalf("Failed to create audit repository: %v", err)
+	}
+	domainRepo, err := repository.NewDomainRepository(setupCtx, mongoClient, cfg.MongoDB.Database, "domains")
+	if err != nil {
+		log.Fatalf("Failed to create domain repository: %v", err)
+	}
+	shadowBanRepo, err := repository.NewShadowBanRepository(setupCtx, mongoClient, cfg.MongoDB.Database, "shadow_bans")
+	if err != nil {
+		log.Fatalf("Failed to create shadow ban repository: %v", err)
+	}
+	destinationRuleRepo, err := repository.NewDestinationRuleRepository(setupCtx, mongoClient, cfg.MongoDB.Database, "destination_rules")
+	if err != nil {
+		log.Fatalf("Failed to create destination rule repository: %v", err)
+	}
+	notificationRepo, err := repository.NewNotificationRepository(setupCtx, mongoClient, cfg.MongoDB.Database, "notification_preferences")
+	if err != nil {
+		log.Fatalf("Failed to create notification repository: %v", err)
+	}
+	orgRepo, err := repository.NewOrganizationRepository(setupCtx, mongoClient, cfg.MongoDB.Database, "organizations", "memberships")
+	if err != nil {
+		log.Fatalf("Failed to create organization repository: %v", err)
+	}
+	conversionRepo, err := repository.NewConversionRepository(setupCtx, mongoClient, cfg.MongoDB.Database, "conversions")
+	if err != nil {
+		log.Fatalf("Failed to create conversion repository: %v", err)
+	}
+	campaignRepo, err := repository.NewCampaignRepository(setupCtx, mongoClient, cfg.MongoDB.Database, "campaigns")
+	if err != nil {
+		log.Fatalf("Failed to create campaign repository: %v", err)
+	}
+	transferRepo, err := repository.NewTransferRepository(setupCtx, mongoClient, cfg.MongoDB.Database, "link_transfers")
+	if err != nil {
+		log.Fatalf("Failed to create transfer repository: %v", err)
+	}
+	chatRepo, err := repository.NewChatRepository(setupCtx, mongoClient, cfg.MongoDB.Database, "chat_workspaces")
+	if err != nil {
+		log.Fatalf("Failed to create chat workspace repository: %v", err)
+	}
+	reserved := validators.NewReservedWords(cfg.ReservedCodes)
+	defaultURLPolicy := validators.URLPolicy{}
+	if cfg.URLPolicy.Strict {
+		defaultURLPolicy = validators.StrictURLPolicy
+	}
+	urlPolicies := validators.NewURLPolicies(defaultURLPolicy, cfg.URLPolicy.StrictAccounts)
+	keyService := services.NewKeyService(redisClient, cfg.KeyGenServiceURL, cfg.Keygen.Queue, reserved, redisBreaker, instances.ID(), cfg.Keygen.BlockSize, cfg.Keygen.LeaseTTL)
+	keyService.Start()
+	defer keyService.Stop()
+	plans := make(map[string]models.LinkQuota, len(cfg.Quota.Plans))
+	for name, plan := range cfg.Quota.Plans {
+		plans[name] = models.LinkQuota{Daily: plan.Daily, Monthly: plan.Monthly}
+	}
+	quotaService := services.NewQuotaService(redisClient, cfg.Quota.LinksPerMonth, cfg.Quota.LinksPerDay, plans)
+	// Click archiving and analytics exports are enabled when object storage is configured
+	var clickArchiver *services.ClickArchiver
+	var objectStore *storage.ObjectStore
+	if cfg.ObjectStore.Endpoint != "" {
+		objectStore, err = storage.NewObjectStore(context.Background(), cfg.ObjectStore.Endpoint, cfg.ObjectStore.AccessKey, cfg.ObjectStore.SecretKey, cfg.ObjectStore.Bucket, cfg.ObjectStore.UseSSL)
+		if err != nil {
+			log.Fatalf("Failed to connect to object storage: %v", err)
+		}
+		clickArchiver = services.NewClickArchiver(clickRepo, objectStore, cfg.Archive.HotWindow, cfg.Archive.Interval)
+		clickArchiver.Start()
+		defer clickArchiver.Stop()
+	}
+	exportService := services.NewExportService(clickRepo, objectStore, cfg.Export.Format, cfg.Export.Interval, cfg.Archive.HotWindow)
+	exportService.Start()
+	defer exportService.Stop()
+	clickFeed := services.NewClickFeed(redisClient)
+	clickFeed.Start()
+	defer clickFeed.Stop()
+	analyticsService := services.NewAnalyticsService(clickRepo, conversionRepo, quotaService, clickArchiver, clickFeed, services.StatsLimits{
+		MaxBuckets:    int(cfg.Stats.MaxBuckets),
+		MaxRange:      cfg.Stats.MaxRange,
+		MaxBatchCodes: int(cfg.Stats.MaxBatchCodes),
+		Timeout:       cfg.Stats.QueryTimeout,
+	}, cfg.Privacy.Mode)
+	rollouts := services.NewRollouts(redisClient, cfg.Rollout.Percentages, cfg.Rollout.FlushInterval)
+	rollouts.Start()
+	deprecations := map[string]services.Deprecation{}
+	v1 := services.Deprecation{
+		DeprecatedAt: cfg.APIVersions.V1DeprecatedAt,
+		SunsetAt:     cfg.APIVersions.V1SunsetAt,
+		MigrationURL: cfg.APIVersions.V1MigrationURL,
+	}
+	if v1 != (services.Deprecation{}) {
+		deprecations[services.APIVersion1] = v1
+	}
+	apiVersions := services.NewAPIVersionMetrics(redisClient, deprecations, cfg.APIVersions.FlushInterval)
+	apiVersions.Start()
+	cacheMetrics := services.NewCacheMetrics(redisClient, cfg.Instance.Region, cfg.Cache.MetricsFlushInterval)
+	cacheMetrics.Start()
+	linkCache := services.NewLinkCache(redisClient, cfg.Cache.LinkTTL, int(cfg.Cache.LocalSize), cfg.Cache.LocalTTL, redisBreaker, invalidations, rollouts, cacheMetrics)
+	// Shared counters keep viral links from turning their document into a write hotspot
+	var clickCounters *services.ClickCounters
+	if cfg.Clicks.SyncInterval > 0 {
+		clickCounters = services.NewClickCounters(redisClient, mongoRepo, redisBreaker, cfg.Clicks.SyncInterval)
+		clickCounters.Start()
+	}
+	clickAggregator := services.NewClickAggregator(mongoRepo, clickCounters, cfg.Clicks.FlushInterval, int(cfg.Clicks.FlushBatch))
+	clickAggregator.Start()
+	domainService := services.NewDomainService(domainRepo, invalidations)
+	leaderboardService := services.NewLeaderboardService(redisClient, redisBreaker)
+	linkRateLimiter := services.NewLinkRateLimiter(redisClient, redisBreaker)
+	clientIPs, err := utils.NewClientIPResolver(cfg.Server.TrustedProxies, cfg.Server.ClientIPHeaders)
+	if err != nil {
+		log.Fatalf("TRUSTED_PROXIES must be IP addresses or CIDR ranges")
+	}
+	ipAnonymizer, err := services.NewIPAnonymizer(cfg.Privacy.IPAnonymization, cfg.Privacy.IPHashSecret)
+	if err != nil {
+		log.Fatalf("PRIVACY_IP_ANONYMIZATION must be truncate or hash, and hash needs PRIVACY_IP_HASH_SECRET")
+	}
+	shadowBanService := services.NewShadowBanService(shadowBanRepo, mongoRepo, ipAnonymizer)
+	mailer := services.NewMailer(cfg.SMTP.Addr, cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.From)
+	// Honeytoken alerts go to the regular webhooks plus the security team's endpoints
+	alertWebhooks := services.NewWebhookService(events.DefaultRegistry(), append(append([]string{}, cfg.Webhooks.URLs...), cfg.Honeytokens.WebhookURLs...), cfg.Webhooks.Secret, cfg.Webhooks.SchemaVersion)
+	honeytokenService := services.NewHoneytokenService(alertWebhooks, mailer, cfg.Honeytokens.AlertEmails, ipAnonymizer)
+	edgeCache := services.NewEdgeCache(cfg.CDN.CacheTTL, cfg.CDN.MaxAge, cfg.CDN.Provider, cfg.CDN.APIToken, cfg.CDN.ServiceID, cfg.BaseURL)
+	// Click and link lifecycle events for external analytics, when a stream is configured
+	eventStream := services.NewEventStream(events.DefaultRegistry(), eventPublisher(cfg), int(cfg.EventStream.BufferSize))
+	eventStream.Start()
+	// The Bloom filter of codes lets the redirect path skip MongoDB for codes that were never created
+	var codeFilter *services.CodeFilter
+	if cfg.CodeFilter.Enabled {
+		codeFilter = services.NewCodeFilter(redisClient, mongoRepo, redisBreaker, cfg.CodeFilter.Capacity, cfg.CodeFilter.FalsePositiveRate, cfg.CodeFilter.RebuildInterval)
+		codeFilter.Start()
+		defer codeFilter.Stop()
+	}
+	var redirects repository.LinkStore
+	// The Redis mirror goes through the same client and breaker as the link cache
+	var redisMirror *repository.RedisLinkStore
+	if cfg.RedirectStore.Backend == "redis" {
+		redisMirror = repository.NewRedisLinkStore(redisClient, cfg.RedisMirror.Key, redisBreaker)
+		redirects = redisMirror
+	} else if cassandraRepo := redirectStore(cfg, threshold); cassandraRepo != nil {
+		redirects = cassandraRepo
+		defer cassandraRepo.Close()
+	}
+	titleFetcher := services.NewTitleFetcher(cfg.Titles.FetchTimeout, int(cfg.Titles.Workers), int(cfg.Titles.QueueSize))
+	titleFetcher.Start()
+	defer titleFetcher.Stop()
+	destinationRules := services.NewDestinationRules(destinationRuleRepo, invalidations, cfg.Destinations.Blocklist, cfg.Destinations.Allowlist)
+	urlService := services.NewURLService(mongoRepo, auditRepo, keyService, quotaService, analyticsService, linkCache, clickAggregator, domainService, leaderboardService, shadowBanService, honeytokenService, edgeCache, linkRateLimiter, titleFetcher, eventStream, codeFilter, redirects, cfg.Trash.Retention, cfg.LinkArchive.Rehydrate, urlPolicies, destinationRules, validators.URLNormalizer{SortQuery: cfg.URLPolicy.SortQuery}, reserved, selfHosts(cfg))
+	sandboxService := urlService.Sandbox(sandboxRepo)
+	linkMirror := services.NewLinkMirror(redisMirror, mongoRepo, redisClient, cfg.RedisMirror.ReconcileInterval)
+	linkMirror.Start()
+	defer linkMirror.Stop()
+	campaignService := services.NewCampaignService(campaignRepo, mongoRepo, urlService, analyticsService, int(cfg.Campaigns.MaxLinks))
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo, cfg.APIKeys.RotationGrace)
+	orgService := services.NewOrganizationService(orgRepo, apiKeyService)
+	transferService := services.NewTransferService(transferRepo, auditRepo, urlService, domainService, orgService, cfg.Transfers.OfferTTL)
+	chatService := services.NewChatService(chatRepo, cfg.Chat.SlackSigningSecret, cfg.Chat.DiscordPublicKey, cfg.Chat.ConnectCodeTTL)
+	webhookService := services.NewWebhookService(events.DefaultRegistry(), cfg.Webhooks.URLs, cfg.Webhooks.Secret, cfg.Webhooks.SchemaVersion)
+	rateLimiter := services.NewRateLimiter("api", redisClient, webhookService, cfg.RateLimit.Requests, cfg.RateLimit.Window, cfg.RateLimit.WarnThreshold)
+	embedLimiter := services.NewRateLimiter("embed", redisClient, webhookService, cfg.Embed.RateLimit, time.Minute, 1)
+	embedService := services.NewEmbedService(cfg.Embed.SigningSecret)
+	manageService := services.NewManageService(cfg.Manage.TokenSecret)
+	sitemapService := services.NewSitemapService(domainService, mongoRepo, redisClient, cfg.Sitemap.RefreshInterval)
+	sitemapService.Start()
+	defer sitemapService.Stop()
+	overviewService := services.NewOverviewService(mongoRepo, repository.NewStorageRepository(mongoClient, cfg.MongoDB.Database), cacheMetrics, redisClient)
+	trashPurger := services.NewTrashPurger(mongoRepo, cfg.Trash.Retention, cfg.Trash.PurgeInterval)
+	trashPurger.Start()
+	defer trashPurger.Stop()
+	if cfg.LinkArchive.After > 0 {
+		linkArchiver := services.NewLinkArchiver(mongoRepo, cfg.LinkArchive.After, cfg.LinkArchive.Interval, cfg.LinkArchive.BatchSize)
+		linkArchiver.Start()
+		defer linkArchiver.Stop()
+	}
+	if cfg.LinkHealth.Interval > 0 {
+		healthChecker := services.NewLinkHealthChecker(mongoRepo, urlService, cfg.LinkHealth.Timeout, cfg.LinkHealth.Interval, cfg.LinkHealth.Recheck, int(cfg.LinkHealth.BatchSize), int(cfg.LinkHealth.Concurrency), cfg.LinkHealth.DeactivateAfter)
+		healthChecker.Start()
+		defer healthChecker.Stop()
+	}
+	notificationService := services.NewNotificationService(notificationRepo, mongoRepo, mailer, cfg.BaseURL, cfg.Notifications.ScanInterval, int(cfg.Notifications.Workers), int(cfg.Notifications.QueueSize))
+	// Preferences can be set either way; emails only go out with a relay
+	if mailer.Enabled() {
+		notificationService.Start()
+		defer notificationService.Stop()
+	}
+	instances.Start()
+	defer instances.Stop()
 
-	router := setupRouter(urlService, keyService)
+	router := setupRouter(cfg, routerDeps{
+		urlService:     urlService,
+		sandbox:        sandboxService,
+		keyService:     keyService,
+		quotaService:   quotaService,
+		apiKeyService:  apiKeyService,
+		notifications:  notificationService,
+		orgService:     orgService,
+		campaigns:      campaignService,
+		transfers:      transferService,
+		chat:           chatService,
+		rateLimiter:    rateLimiter,
+		embedLimiter:   embedLimiter,
+		embedService:   embedService,
+		manageService:  manageService,
+		domainService:  domainService,
+		sitemapService: sitemapService,
+		healthService:  healthService,
+		leaderboards:   leaderboardService,
+		botFilter:      botFilter,
+		shadowBans:     shadowBanService,
+		destinations:   destinationRules,
+		exports:        exportService,
+		linkMirror:     linkMirror,
+		instances:      instances,
+		rollouts:       rollouts,
+		apiVersions:    apiVersions,
+		overview:       overviewService,
+		errorPages:     errorPages,
+		cloakPages:     cloakPages,
+		geo:            geo,
+		robotsTxt:      robotsTxt,
+		instanceID:     instances.ID(),
+		reserved:       reserved,
+		clientIPs:      clientIPs,
+	})
+	tlsConfig, httpHandler := serverTLS(cfg)
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%s", cfg.Server.Port),
-		Handler: router,
+		Addr:      fmt.Sprintf(":%s", cfg.Server.Port),
+		Handler:   router,
+		TLSConfig: tlsConfig,
+		// Larger headers are refused with a 431 before reaching the router
+		MaxHeaderBytes: int(cfg.Limits.MaxHeaderBytes),
 	}
+	// Shutdown waits for open requests, which click streams never end by themselves
+	server.RegisterOnShutdown(clickFeed.Close)
 	go func() {
 		log.Printf("Server starting on port %s", cfg.Server.Port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if tlsConfig != nil {
+			// Certificates come from TLSConfig when autocert is on
+			err = server.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Failed to start server: %v", err)
 		}
 	}()
+	var httpServer *http.Server
+	if httpHandler != nil {
+		httpServer = &http.Server{
+			Addr:              fmt.Sprintf(":%s", cfg.TLS.HTTPPort),
+			Handler:           httpHandler,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			log.Printf("HTTP redirect listener starting on port %s", cfg.TLS.HTTPPort)
+			if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Failed to start HTTP redirect listener: %v", err)
+			}
+		}()
+	}
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Println("Shutting down the server...")
+	endSetup()
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatalf("server forced to shutdown: %v", err)
 	}
+	if httpServer != nil {
+		if err := httpServer.Shutdown(ctx); err != nil {
+			log.Printf("Failed to shut down the HTTP redirect listener: %v", err)
+		}
+	}
+	if err := clickAggregator.Stop(ctx); err != nil {
+		log.Printf("Failed to flush click counts: %v", err)
+	}
+	if clickCounters != nil {
+		clickCounters.Stop()
+	}
+	if err := rollouts.Stop(ctx); err != nil {
+		log.Printf("Failed to flush rollout metrics: %v", err)
+	}
+	if err := apiVersions.Stop(ctx); err != nil {
+		log.Printf("Failed to flush API version metrics: %v", err)
+	}
+	if err := cacheMetrics.Stop(ctx); err != nil {
+		log.Printf("Failed to flush cache metrics: %v", err)
+	}
+	if err := eventStream.Stop(); err != nil {
+		log.Printf("Failed to close the event stream: %v", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("Failed to flush traces: %v", err)
+	}
 	log.Println("Server shutdown gracefully")
 }
 
+// routerDeps groups the services handlers are built from
+type routerDeps struct {
+	urlService     *services.URLService
+	sandbox        *services.URLService
+	keyService     *services.KeyService
+	quotaService   *services.QuotaService
+	apiKeyService  *services.APIKeyService
+	notifications  *services.NotificationService
+	orgService     *services.OrganizationService
+	campaigns      *services.CampaignService
+	transfers      *services.TransferService
+	chat           *services.ChatService
+	rateLimiter    *services.RateLimiter
+	embedLimiter   *services.RateLimiter
+	embedService   *services.EmbedService
+	manageService  *services.ManageService
+	domainService  *services.DomainService
+	sitemapService *services.SitemapService
+	healthService  *services.HealthService
+	leaderboards   *services.LeaderboardService
+	botFilter      *services.BotFilter
+	shadowBans     *services.ShadowBanService
+	destinations   *services.DestinationRules
+	exports        *services.ExportService
+	linkMirror     *services.LinkMirror
+	instances      *services.InstanceRegistry
+	rollouts       *services.Rollouts
+	apiVersions    *services.APIVersionMetrics
+	overview       *services.OverviewService
+	errorPages     *handlers.ErrorPages
+	cloakPages     *handlers.CloakPages
+	robotsTxt      *handlers.RobotsTxt
+	geo            *geoip.Database
+	instanceID     string
+	reserved       *validators.ReservedWords
+	clientIPs      *utils.ClientIPResolver
+}
+
 // setupRouter configures all the routes for the application
-func setupRouter(urlService *services.URLService, keyService *services.KeyService) *gin.Engine {
+func setupRouter(cfg *config.Config, deps routerDeps) *gin.Engine {
 	router := gin.Default()
+	// Forwarding headers are only believed from the configured load balancers
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("TRUSTED_PROXIES must be IP addresses or CIDR ranges: %v", err)
+	}
+	router.RemoteIPHeaders = cfg.Server.ClientIPHeaders
+	// Continues the caller's trace, if any, and is the root of everything below
+	router.Use(otelgin.Middleware(cfg.Tracing.ServiceName))
+	router.Use(middleware.ResolveClientIP(deps.clientIPs))
 
 	// Add middleware (logging, CORS, etc.)
 	router.Use(middleware.Logger())
+	if cfg.TLS.RedirectHTTP || cfg.TLS.HSTSMaxAge > 0 {
+		router.Use(middleware.HTTPSRedirect(cfg.TLS.RedirectHTTP, middleware.HSTS{
+			MaxAge:            cfg.TLS.HSTSMaxAge,
+			IncludeSubdomains: cfg.TLS.HSTSIncludeSubdomains,
+		}))
+	}
+	if cfg.Instance.ServedByHeader {
+		router.Use(middleware.ServedBy(deps.instanceID))
+	}
+	router.Use(middleware.ResponseFormat(utils.ResponseFormat{
+		Casing:   cfg.Response.Casing,
+		Envelope: cfg.Response.Envelope,
+	}))
+	router.Use(middleware.ServiceErrors())
+	router.Use(middleware.LimitRequestSize(cfg.Limits.MaxURLBytes, cfg.Limits.MaxBodyBytes))
 
 	// Create handlers
-	urlHandler := handlers.NewURLHandler(urlService)
-	keyHandler := handlers.NewKeyHandler(keyService)
+	links := handlers.NewLinks(cfg.BaseURL)
+	urlHandler := handlers.NewURLHandler(deps.urlService, deps.sandbox, deps.domainService, deps.botFilter, deps.reserved, deps.campaigns, links, deps.rollouts, deps.errorPages, deps.cloakPages, deps.geo, deps.manageService, cfg.Stats.CacheMaxAge)
+	keyHandler := handlers.NewKeyHandler(deps.keyService)
+	accountHandler := handlers.NewAccountHandler(deps.quotaService, deps.apiKeyService, deps.notifications)
+	adminHandler := handlers.NewAdminHandler(deps.apiKeyService, deps.quotaService, deps.shadowBans, deps.destinations, deps.exports, deps.linkMirror, deps.instances, deps.rollouts, deps.apiVersions, deps.overview)
+	embedHandler := handlers.NewEmbedHandler(deps.embedService, deps.urlService, links)
+	manageHandler := handlers.NewManageHandler(deps.manageService, deps.urlService)
+	integrationHandler := handlers.NewIntegrationHandler(deps.urlService, links)
+	domainHandler := handlers.NewDomainHandler(deps.domainService, deps.sitemapService, deps.robotsTxt)
+	healthHandler := handlers.NewHealthHandler(deps.healthService, deps.instanceID)
+	leaderboardHandler := handlers.NewLeaderboardHandler(deps.leaderboards, links)
+	orgHandler := handlers.NewOrganizationHandler(deps.orgService)
+	provisioningHandler := handlers.NewProvisioningHandler(deps.orgService, deps.apiKeyService, deps.domainService, deps.quotaService)
+	campaignHandler := handlers.NewCampaignHandler(deps.campaigns, links, cfg.Stats.CacheMaxAge)
+	transferHandler := handlers.NewTransferHandler(deps.transfers)
+	chatHandler := handlers.NewChatHandler(deps.chat, deps.urlService, links)
+	graphQLHandler := handlers.NewGraphQLHandler(deps.urlService, deps.campaigns, links)
+	badgeHandler := handlers.NewBadgeHandler(deps.urlService, deps.domainService, deps.reserved, links, cfg.Stats.BadgeMaxAge)
+
+	// API routes, served under every version. Handlers are shared; the
+	// version middleware adapts the response format. Sandbox keys can only
+	// create and manage their own test links
+	noSandbox := middleware.RejectSandbox()
+	for _, version := range services.APIVersions {
+		api := router.Group("/api/" + version)
+		api.Use(middleware.APIVersion(version, deps.apiVersions))
+		api.Use(middleware.Authenticate(deps.apiKeyService))
+		api.Use(middleware.Workspace(deps.orgService))
+		api.Use(middleware.RateLimit(deps.rateLimiter))
+		api.POST("/shorten", middleware.RequireScope(models.ScopeShorten), urlHandler.ShortenURL)
+		api.GET("/shorten", middleware.RequireAccount(), middleware.RequireScope(models.ScopeShorten), urlHandler.ShortenURLQuery)
+		api.GET("/generate", keyHandler.GenerateKey) // Key generation endpoint
+		api.GET("/:code/stats", middleware.RequireAccount(), noSandbox, middleware.RequireScope(models.ScopeStatsRead), urlHandler.GetStats)
+		api.GET("/:code/stats/stream", middleware.RequireAccount(), noSandbox, middleware.RequireScope(models.ScopeStatsRead), urlHandler.StreamClicks)
+		api.GET("/:code/stats/referrers", middleware.RequireAccount(), noSandbox, middleware.RequireScope(models.ScopeStatsRead), urlHandler.GetReferrerStats)
+		api.GET("/:code/stats/countries", middleware.RequireAccount(), noSandbox, middleware.RequireScope(models.ScopeStatsRead), urlHandler.GetCountryStats)
+		api.GET("/stats", middleware.RequireAccount(), noSandbox, middleware.RequireScope(models.ScopeStatsRead), urlHandler.GetStatsBatch)
+		api.POST("/resolve", noSandbox, urlHandler.ResolveLinks)
+		api.GET("/links", middleware.RequireAccount(), urlHandler.ListLinks)
+		api.GET("/lookup", middleware.RequireAccount(), noSandbox, middleware.RequireScope(models.ScopeShorten), urlHandler.LookupURL)
+		api.PUT("/:code", middleware.RequireAccount(), middleware.RequireScope(models.ScopeLinksWrite), urlHandler.UpdateURL)
+		api.PATCH("/:code", middleware.RequireAccount(), middleware.RequireScope(models.ScopeLinksWrite), urlHandler.PatchURL)
+		api.DELETE("/:code", middleware.RequireAccount(), middleware.RequireScope(models.ScopeLinksWrite), urlHandler.DeleteURL)
+		api.GET("/manage/:token", manageHandler.GetLink)
+		api.DELETE("/manage/:token", manageHandler.DeleteLink)
+		api.POST("/manage/:token/rotate", manageHandler.RotateToken)
+		api.POST("/:code/restore", middleware.RequireAccount(), middleware.RequireScope(models.ScopeLinksWrite), urlHandler.RestoreURL)
+		api.POST("/:code/renew", middleware.RequireAccount(), middleware.RequireScope(models.ScopeLinksWrite), urlHandler.RenewURL)
+		api.POST("/:code/conversions", middleware.RequireAccount(), noSandbox, middleware.RequireScope(models.ScopeLinksWrite), urlHandler.RecordConversion)
+		api.POST("/:code/transfer", middleware.RequireAccount(), noSandbox, middleware.RequireScope(models.ScopeLinksWrite), transferHandler.TransferLink)
+		api.GET("/:code/history", middleware.RequireAccount(), noSandbox, middleware.RequireScope(models.ScopeStatsRead), urlHandler.GetHistory)
+		api.GET("/:code/clicks", middleware.RequireAccount(), noSandbox, middleware.RequireScope(models.ScopeStatsRead), urlHandler.ListClicks)
+		api.GET("/:code/qr", noSandbox, urlHandler.QRCode)
+		api.PUT("/integrations/cms/links", middleware.RequireAccount(), noSandbox, middleware.RequireScope(models.ScopeLinksWrite), integrationHandler.UpsertCMSLink)
+		account := api.Group("/account", middleware.RequireAccount(), noSandbox)
+		account.GET("/usage", middleware.RequireScope(models.ScopeStatsRead), accountHandler.GetUsage)
+		account.GET("/keys", middleware.RequireScope(models.ScopeAdmin), accountHandler.ListKeys)
+		account.POST("/keys/:id/rotate", middleware.RequireScope(models.ScopeAdmin), accountHandler.RotateKey)
+		account.POST("/keys/:id/revoke", middleware.RequireScope(models.ScopeAdmin), accountHandler.RevokeKey)
+		account.POST("/embed-tokens", middleware.RequireScope(models.ScopeAdmin), embedHandler.IssueToken)
+		account.GET("/domains", middleware.RequireScope(models.ScopeAdmin), domainHandler.ListDomains)
+		account.POST("/domains", middleware.RequireScope(models.ScopeAdmin), domainHandler.AddDomain)
+		account.POST("/domains/:id/verify", middleware.RequireScope(models.ScopeAdmin), domainHandler.VerifyDomain)
+		account.GET("/leaderboard", middleware.RequireScope(models.ScopeStatsRead), leaderboardHandler.AccountLeaderboard)
+		account.GET("/notifications", middleware.RequireScope(models.ScopeAdmin), accountHandler.GetNotifications)
+		account.PUT("/notifications", middleware.RequireScope(models.ScopeAdmin), accountHandler.UpdateNotifications)
+		account.GET("/chat-workspaces", middleware.RequireScope(models.ScopeAdmin), chatHandler.ListChatWorkspaces)
+		account.POST("/chat-workspaces", middleware.RequireScope(models.ScopeAdmin), chatHandler.CreateConnectCode)
+		account.DELETE("/chat-workspaces/:id", middleware.RequireScope(models.ScopeAdmin), chatHandler.DisconnectChatWorkspace)
+		orgs := api.Group("/orgs", middleware.RequireAccount(), noSandbox)
+		orgs.POST("", middleware.RequireScope(models.ScopeAdmin), orgHandler.CreateOrganization)
+		orgs.GET("", orgHandler.ListOrganizations)
+		orgs.GET("/:id/members", orgHandler.ListMembers)
+		orgs.PUT("/:id/members/:account", middleware.RequireScope(models.ScopeAdmin), orgHandler.SetMember)
+		orgs.DELETE("/:id/members/:account", middleware.RequireScope(models.ScopeAdmin), orgHandler.RemoveMember)
+		orgs.POST("/:id/keys", middleware.RequireScope(models.ScopeAdmin), orgHandler.CreateKey)
+		campaigns := api.Group("/campaigns", middleware.RequireAccount(), noSandbox)
+		campaigns.POST("", middleware.RequireScope(models.ScopeShorten), campaignHandler.CreateCampaign)
+		campaigns.GET("", campaignHandler.ListCampaigns)
+		campaigns.GET("/:id", campaignHandler.GetCampaign)
+		campaigns.GET("/:id/links", campaignHandler.ListCampaignLinks)
+		campaigns.GET("/:id/stats", middleware.RequireScope(models.ScopeStatsRead), campaignHandler.GetCampaignStats)
+		transfers := api.Group("/transfers", middleware.RequireAccount(), noSandbox)
+		transfers.GET("", transferHandler.ListTransfers)
+		transfers.POST("/:id/accept", middleware.RequireScope(models.ScopeLinksWrite), transferHandler.AcceptTransfer)
+		transfers.POST("/:id/decline", middleware.RequireScope(models.ScopeLinksWrite), transferHandler.DeclineTransfer)
+		transfers.POST("/:id/cancel", middleware.RequireScope(models.ScopeLinksWrite), transferHandler.CancelTransfer)
+
+		// Shortening from browser extensions. CORS runs ahead of
+		// authentication, so its own group, and answers preflights itself
+		quick := router.Group("/api/" + version + "/quick-shorten")
+		quick.Use(middleware.CORS(cfg.QuickShorten.AllowedOrigins, "POST, OPTIONS"))
+		quick.Use(middleware.APIVersion(version, deps.apiVersions))
+		quick.Use(middleware.Authenticate(deps.apiKeyService))
+		quick.Use(middleware.RateLimit(deps.rateLimiter))
+		quick.OPTIONS("")
+		quick.POST("", middleware.RequireAccount(), middleware.RequireScope(models.ScopeShorten), urlHandler.QuickShorten)
+	}
+
+	// GraphQL link management for dashboards; every operation needs an API key
+	graphQL := router.Group("/graphql")
+	graphQL.Use(middleware.Authenticate(deps.apiKeyService))
+	graphQL.Use(middleware.Workspace(deps.orgService))
+	graphQL.Use(middleware.RateLimit(deps.rateLimiter))
+	graphQL.POST("", middleware.RequireAccount(), middleware.RejectSandbox(), graphQLHandler.Serve)
+
+	// Public "shorten this page" widget, authenticated by a signed site token
+	embed := router.Group("/embed")
+	embed.OPTIONS("/shorten", embedHandler.Preflight)
+	embed.POST("/shorten", middleware.RateLimit(deps.embedLimiter), embedHandler.Shorten)
 
-	// API routes
-	api := router.Group("/api/v1")
-	api.POST("/shorten", urlHandler.ShortenURL)
-	api.GET("/generate", keyHandler.GenerateKey) // Key generation endpoint
-	api.GET("/:code/stats", urlHandler.GetStats)
+	// Slack and Discord slash commands, authenticated by the platforms' signatures
+	router.POST("/integrations/slack", chatHandler.Slack)
+	router.POST("/integrations/discord", chatHandler.Discord)
+
+	// Admin routes
+	admin := router.Group("/admin")
+	admin.Use(middleware.AdminAuth(cfg.AdminToken))
+	admin.POST("/api-keys", adminHandler.CreateAPIKey)
+	admin.PUT("/api-keys/:id/quota", adminHandler.SetAPIKeyQuota)
+	admin.PUT("/api-keys/:id/origins", adminHandler.SetAPIKeyOrigins)
+	admin.GET("/leaderboard", leaderboardHandler.GlobalLeaderboard)
+	admin.GET("/shadow-bans", adminHandler.ListShadowBans)
+	admin.PUT("/accounts/:id/shadow-ban", adminHandler.ShadowBanAccount)
+	admin.DELETE("/accounts/:id/shadow-ban", adminHandler.LiftShadowBan)
+	admin.GET("/destination-rules", adminHandler.ListDestinationRules)
+	admin.PUT("/destination-rules/:pattern", adminHandler.PutDestinationRule)
+	admin.DELETE("/destination-rules/:pattern", adminHandler.DeleteDestinationRule)
+	admin.POST("/exports", adminHandler.CreateExport)
+	admin.POST("/link-mirror/reconcile", adminHandler.ReconcileLinkMirror)
+	admin.GET("/instances", adminHandler.ListInstances)
+	admin.GET("/overview", adminHandler.Overview)
+	admin.GET("/rollouts", adminHandler.ListRollouts)
+	admin.GET("/api-versions", adminHandler.ListAPIVersions)
+	admin.GET("/api-versions/:version/accounts", adminHandler.ListAPIVersionAccounts)
+	// Declarative tenant provisioning, addressed by caller-chosen IDs
+	admin.PUT("/organizations/:org", provisioningHandler.PutOrganization)
+	admin.GET("/organizations/:org", provisioningHandler.GetOrganization)
+	admin.PUT("/organizations/:org/api-keys/:key", provisioningHandler.PutAPIKey)
+	admin.GET("/organizations/:org/api-keys/:key", provisioningHandler.GetAPIKey)
+	admin.DELETE("/organizations/:org/api-keys/:key", provisioningHandler.DeleteAPIKey)
+	admin.PUT("/organizations/:org/domains/:host", provisioningHandler.PutDomain)
+	admin.GET("/organizations/:org/domains/:host", provisioningHandler.GetDomain)
+
+	// API documentation
+	router.GET("/openapi.json", handlers.OpenAPISpec)
+	router.GET("/docs", handlers.SwaggerUI)
+
+	// Liveness and readiness probes
+	router.GET("/livez", healthHandler.Livez)
+	router.GET("/readyz", healthHandler.Readyz)
+
+	// Sitemap of indexable links, served on branded domains
+	router.GET("/sitemap.xml", domainHandler.Sitemap)
+	router.GET("/robots.txt", domainHandler.Robots)
+
+	// Public click counters to embed, served next to the redirect
+	router.GET("/:code/badge.svg", badgeHandler.Badge)
+	router.GET("/:code/stats/embed", badgeHandler.Embed)
+
+	// Redirects of sandbox links, kept apart from production codes
+	router.GET("/sandbox/:code", middleware.Identify(deps.apiKeyService), urlHandler.RedirectSandbox)
 
 	// Redirect route (should be last to avoid conflicts)
-	router.GET("/:code", urlHandler.RedirectURL)
+	router.GET("/:code", middleware.Identify(deps.apiKeyService), urlHandler.RedirectURL)
+	// Unknown routes get the same error body as everything else
+	router.NoRoute(func(c *gin.Context) {
+		utils.RespondWithErrorCode(c, http.StatusNotFound, utils.ErrCodeRouteNotFound, "Route not found")
+	})
 
 	return router
 }
-func connectMongoDB(uri string) (*mongo.Client, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	clientOptions := options.Client().ApplyURI(uri)
-	client, err := mongo.Connect(ctx, clientOptions)
+
+// migrate runs a --migrate command against the database and reports it
+func migrate(command string, migrator *migrations.Migrator) {
+	ctx := context.Background()
+	switch command {
+	case "up":
+		ran, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatalf("Failed to apply migrations after %d: %v", ran, err)
+		}
+		log.Printf("Applied %d migrations", ran)
+	case "down":
+		reverted, err := migrator.Down(ctx)
+		if err != nil {
+			log.Fatalf("Failed to revert a migration: %v", err)
+		}
+		log.Printf("Reverted migration %03d %s", reverted.Version, reverted.Name)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalf("Failed to read migrations: %v", err)
+		}
+		for _, status := range statuses {
+			if status.AppliedAt != nil {
+				log.Printf("%03d %s: applied %s", status.Version, status.Name, status.AppliedAt.Format(time.RFC3339))
+			} else {
+				log.Printf("%03d %s: pending", status.Version, status.Name)
+			}
+		}
+	}
+}
+
+// migrateOnStart applies pending migrations before the repositories are
+// created. Like the other dependencies, an unreachable MongoDB doesn't stop
+// startup; the migrations are then applied by the next replica to start
+func migrateOnStart(ctx context.Context, migrator *migrations.Migrator) {
+	ran, err := migrator.Up(ctx)
+	if err != nil && repository.IsUnavailable(err) {
+		log.Printf("Skipping migrations, MongoDB unavailable: %v", err)
+		return
+	}
 	if err != nil {
-		return nil, err
+		log.Fatalf("Failed to apply migrations: %v", err)
+	}
+	if ran > 0 {
+		log.Printf("Applied %d migrations", ran)
 	}
-	if err := client.Ping(ctx, nil); err != nil {
-		return nil, err
+}
+
+// mongoOptions are the MongoDB client settings from the configuration
+func mongoOptions(cfg *config.Config) repository.MongoOptions {
+	return repository.MongoOptions{
+		URI:                    cfg.MongoDB.URI,
+		MaxPoolSize:            uint64(cfg.MongoDB.MaxPoolSize),
+		MinPoolSize:            uint64(cfg.MongoDB.MinPoolSize),
+		MaxConnIdleTime:        cfg.MongoDB.MaxConnIdleTime,
+		ReadPreference:         cfg.MongoDB.ReadPreference,
+		WriteConcern:           cfg.MongoDB.WriteConcern,
+		ConnectTimeout:         cfg.MongoDB.ConnectTimeout,
+		ServerSelectionTimeout: cfg.MongoDB.ServerSelectionTimeout,
+		OperationTimeout:       cfg.MongoDB.OperationTimeout,
+		Monitor:                tracing.MongoMonitor(),
+	}
+}
+
+// newLinkRepository stores links on MONGODB_URI, or spreads them over the
+// MONGODB_SHARDS clusters, each behind its own breaker so one cluster's
+// outage doesn't fail the links on the others
+func newLinkRepository(ctx context.Context, cfg *config.Config, mongoClient *mongo.Client, mongoBreaker *breaker.Breaker, shardClients map[string]*mongo.Client) (*repository.MongoRepository, error) {
+	if len(shardClients) == 0 {
+		return repository.NewMongoRepository(ctx, mongoClient, cfg.MongoDB.Database, "short_urls", mongoBreaker)
+	}
+	threshold := uint32(cfg.Breaker.FailureThreshold)
+	shards := make([]repository.MongoShard, 0, len(shardClients))
+	for _, name := range slices.Sorted(maps.Keys(shardClients)) {
+		shards = append(shards, repository.MongoShard{
+			Name:    name,
+			Client:  shardClients[name],
+			Breaker: breaker.New("mongodb-"+name, threshold, cfg.Breaker.OpenTimeout, repository.IsExpectedMongoError),
+		})
+	}
+	return repository.NewShardedMongoRepository(ctx, shards, cfg.MongoDB.Database, "short_urls")
+}
+
+// connectRedis creates a client for a single server, a Sentinel-monitored
+// master or a cluster, as REDIS_MODE says
+func connectRedis(cfg *config.Config) redis.UniversalClient {
+	opts := &redis.UniversalOptions{
+		Addrs:            cfg.Redis.Addresses,
+		Password:         cfg.Redis.Password,
+		DB:               cfg.Redis.DB,
+		SentinelPassword: cfg.Redis.SentinelPassword,
+		IsClusterMode:    cfg.Redis.Mode == "cluster",
 	}
-	return client, nil
+	if cfg.Redis.Mode == "sentinel" {
+		opts.MasterName = cfg.Redis.MasterName
+	}
+	return redis.NewUniversalClient(opts)
 }
 
-func connectRedis(address, password string, db int) *redis.Client {
-	client := redis.NewClient(&redis.Options{
-		Addr:     address,
-		Password: password,
-		DB:       db,
+// waitForDependency pings a dependency with exponential backoff until it
+// answers or timeout passes, then logs whether the server starts degraded
+func waitForDependency(name string, timeout time.Duration, ping func(ctx context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := utils.RetryWithBackoff(ctx, 500*time.Millisecond, 5*time.Second, func(ctx context.Context) error {
+		err := ping(ctx)
+		if err != nil {
+			log.Printf("%s not reachable yet: %v", name, err)
+		}
+		return err
+	})
+	if err != nil {
+		log.Printf("Starting without %s, running degraded: %v", name, err)
+		return
+	}
+	log.Printf("Connected to %s", name)
+}
+
+// redirectStore connects to the Cassandra cluster when redirects are served
+// from it, or returns nil when they read MongoDB or the Redis mirror. Unlike the other
+// dependencies a missing cluster stops startup: a replica running without it
+// would update links without updating their copies there
+func redirectStore(cfg *config.Config, threshold uint32) *repository.CassandraRepository {
+	switch cfg.RedirectStore.Backend {
+	case "mongo", "redis":
+		return nil
+	case "cassandra":
+	default:
+		log.Fatalf("REDIRECT_STORE must be mongo, cassandra or redis, got %q", cfg.RedirectStore.Backend)
+	}
+	cassandraBreaker := breaker.New("cassandra", threshold, cfg.Breaker.OpenTimeout, repository.IsExpectedCassandraError)
+	opts := repository.CassandraOptions{
+		Hosts:       cfg.Cassandra.Hosts,
+		Keyspace:    cfg.Cassandra.Keyspace,
+		Username:    cfg.Cassandra.Username,
+		Password:    cfg.Cassandra.Password,
+		Consistency: cfg.Cassandra.Consistency,
+		Timeout:     cfg.Cassandra.Timeout,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Startup.RetryTimeout)
+	defer cancel()
+	var cassandraRepo *repository.CassandraRepository
+	err := utils.RetryWithBackoff(ctx, 500*time.Millisecond, 5*time.Second, func(ctx context.Context) error {
+		var err error
+		cassandraRepo, err = repository.NewCassandraRepository(opts, cassandraBreaker)
+		if err != nil {
+			log.Printf("Cassandra not reachable yet: %v", err)
+		}
+		return err
 	})
-	if err := client.Ping(context.Background()).Err(); err != nil {
+	if err != nil {
+		log.Fatalf("Failed to connect to Cassandra: %v", err)
+	}
+	log.Printf("Connected to Cassandra")
+	return cassandraRepo
+}
+
+// eventPublisher connects to the configured event stream, or discards events
+// when none is configured
+func eventPublisher(cfg *config.Config) events.Publisher {
+	switch cfg.EventStream.Backend {
+	case "":
+		return events.NopPublisher{}
+	case "kafka":
+		return events.NewKafkaPublisher(cfg.EventStream.KafkaRESTURL, cfg.EventStream.KafkaTopic)
+	case "nats":
+		publisher, err := events.NewNATSPublisher(cfg.EventStream.NATSURL, cfg.EventStream.NATSSubjectPrefix)
+		if err != nil {
+			log.Fatalf("Failed to set up the event stream: %v", err)
+		}
+		return publisher
+	default:
+		log.Fatalf("EVENT_STREAM must be kafka or nats, got %q", cfg.EventStream.Backend)
 		return nil
 	}
-	return client
+}
+
+// selfHosts are the hosts serving this shortener, which links may not point at:
+// BASE_URL's host plus SHORTENER_HOSTS
+func selfHosts(cfg *config.Config) []string {
+	hosts := append([]string{}, cfg.ShortenerHosts...)
+	if u, err := url.Parse(cfg.BaseURL); err == nil && u.Hostname() != "" {
+		hosts = append(hosts, u.Hostname())
+	}
+	return hosts
+}
+
+// serverTLS returns the TLS config for the main server, or nil when TLS ends
+// at a proxy, and the handler for the plain HTTP listener, or nil for none.
+// With autocert that listener also answers Let's Encrypt's HTTP-01
+// challenges, and certificates are only requested for the short domain
+func serverTLS(cfg *config.Config) (*tls.Config, http.Handler) {
+	var (
+		tlsConfig   *tls.Config
+		httpHandler http.Handler
+	)
+	switch {
+	case cfg.TLS.Autocert:
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			Cache:      autocert.DirCache(cfg.TLS.AutocertCacheDir),
+			HostPolicy: autocert.HostWhitelist(selfHosts(cfg)...),
+			Email:      cfg.TLS.AutocertEmail,
+		}
+		tlsConfig = manager.TLSConfig()
+		httpHandler = manager.HTTPHandler(middleware.RedirectToHTTPS())
+	case cfg.TLS.CertFile != "" && cfg.TLS.KeyFile != "":
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		httpHandler = middleware.RedirectToHTTPS()
+	default:
+		return nil, nil
+	}
+	if cfg.TLS.HTTPPort == "" {
+		if cfg.TLS.Autocert {
+			log.Println("TLS_HTTP_PORT is not set; Let's Encrypt can only validate over TLS-ALPN-01")
+		}
+		return tlsConfig, nil
+	}
+	return tlsConfig, httpHandler
 }
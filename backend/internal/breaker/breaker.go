@@ -0,0 +1,56 @@
+// Package breaker wraps sony/gobreaker for Redis and Mongo calls so a dead
+// dependency fails fast instead of stacking timeouts
+package breaker
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+// ErrOpen is returned without calling the dependency while the circuit is open
+var ErrOpen = errors.New("circuit breaker open")
+
+// Breaker trips after consecutive failures and probes again after a cool-down
+type Breaker struct {
+	cb *gobreaker.CircuitBreaker
+}
+
+// New creates a breaker that opens after failureThreshold consecutive failures
+// and stays open for openTimeout. Errors for which ignore returns true (e.g.
+// "not found") count as successes since the dependency answered
+func New(name string, failureThreshold uint32, openTimeout time.Duration, ignore func(error) bool) *Breaker {
+	settings := gobreaker.Settings{
+		Name:        name,
+		MaxRequests: 1,
+		Timeout:     openTimeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= failureThreshold
+		},
+		IsSuccessful: func(err error) bool {
+			return err == nil || (ignore != nil && ignore(err))
+		},
+		OnStateChange: func(name string, from, to gobreaker.State) {
+			log.Printf("Circuit breaker %s: %s -> %s", name, from, to)
+		},
+	}
+	return &Breaker{cb: gobreaker.NewCircuitBreaker(settings)}
+}
+
+// Do runs fn through the breaker
+func (b *Breaker) Do(fn func() error) error {
+	_, err := b.cb.Execute(func() (interface{}, error) {
+		return nil, fn()
+	})
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		return ErrOpen
+	}
+	return err
+}
+
+// State returns the current state name: closed, half-open or open
+func (b *Breaker) State() string {
+	return b.cb.State().String()
+}
@@ -1,44 +1,589 @@
 package config
 
 import (
-	"os"
+	"errors"
+	"strings"
+	"time"
 )
 
 type Config struct {
 	Server struct {
 		Port string
+		// TrustedProxies are the addresses and CIDR ranges of the load
+		// balancers whose ClientIPHeaders are believed; empty trusts none
+		TrustedProxies  []string
+		ClientIPHeaders []string
+	}
+	// TLS is served natively when CertFile and KeyFile are set or Autocert
+	// is on; otherwise TLS is expected to end at a proxy in front
+	TLS struct {
+		CertFile string
+		KeyFile  string
+		// Autocert gets certificates for the short domain from Let's Encrypt
+		Autocert         bool
+		AutocertEmail    string
+		AutocertCacheDir string
+		// HTTPPort is a plain HTTP listener that redirects to HTTPS and
+		// answers ACME challenges; empty runs none
+		HTTPPort string
+		// RedirectHTTP redirects plain HTTP requests reaching the main server,
+		// such as those a proxy forwards with X-Forwarded-Proto: http
+		RedirectHTTP          bool
+		HSTSMaxAge            time.Duration
+		HSTSIncludeSubdomains bool
+	}
+	Instance struct {
+		ID                string
+		HeartbeatInterval time.Duration
+		ServedByHeader    bool
+		// Region names where the replica runs, such as us-east-1, and tags
+		// its registry entry, traces and cache metrics; empty tags nothing
+		Region string
+	}
+	// Limits protect the server and MongoDB from oversized requests
+	Limits struct {
+		MaxBodyBytes   int64
+		MaxURLBytes    int64
+		MaxHeaderBytes int64
 	}
 	MongoDB struct {
 		URI      string
 		Database string
+		// Pool sizes and idle time of the connections to each server; 0
+		// keeps the driver's default
+		MaxPoolSize     int64
+		MinPoolSize     int64
+		MaxConnIdleTime time.Duration
+		// ReadPreference and WriteConcern override the URI's when set
+		ReadPreference string
+		WriteConcern   string
+		ConnectTimeout time.Duration
+		// ServerSelectionTimeout is how long an operation waits for a
+		// reachable server before failing
+		ServerSelectionTimeout time.Duration
+		// OperationTimeout bounds every operation not already bounded by
+		// its caller; 0 leaves them unbounded
+		OperationTimeout time.Duration
+		// Shards maps cluster names to URIs. When set, links are spread
+		// over these clusters by short code instead of stored at URI,
+		// which keeps every other collection
+		Shards map[string]string
+		// RedirectReadPreference is the read preference of the redirect
+		// path's link lookups alone, such as nearest; empty uses ReadPreference
+		RedirectReadPreference string
 	}
 	Redis struct {
-		Address  string
-		Password string
-		DB       int
+		// Mode is "standalone", "sentinel" or "cluster"
+		Mode string
+		// Addresses is the server in standalone mode, the sentinels in
+		// sentinel mode and any of the nodes in cluster mode
+		Addresses []string
+		Password  string
+		DB        int
+		// MasterName is the master the sentinels monitor
+		MasterName       string
+		SentinelPassword string
 	}
+	// KeyGenServiceURL is the keygen service asked for codes when the queue
+	// is empty; empty means codes are generated locally instead
 	KeyGenServiceURL string
+	Keygen           struct {
+		Port           string
+		Queue          string
+		QueueSize      int64
+		RangeSize      int64
+		CodeLength     int
+		RefillInterval time.Duration
+		// BlockSize is how many codes a server replica leases off the queue
+		// at once; 0 pops one per link
+		BlockSize int64
+		LeaseTTL  time.Duration
+	}
+	BaseURL        string
+	ShortenerHosts []string
+	Response       struct {
+		Casing   string
+		Envelope bool
+	}
+	APIVersions struct {
+		// V1DeprecatedAt, V1SunsetAt and V1MigrationURL announce /api/v1's
+		// retirement in response headers; zero values are not announced
+		V1DeprecatedAt time.Time
+		V1SunsetAt     time.Time
+		V1MigrationURL string
+		FlushInterval  time.Duration
+	}
+	AdminToken string
+	Quota      struct {
+		// LinksPerMonth and LinksPerDay are the link creation quota of API
+		// keys without a plan; 0 is unlimited
+		LinksPerMonth int64
+		LinksPerDay   int64
+		// Plans are named quotas keys can be assigned
+		Plans map[string]PlanQuota
+	}
+	RateLimit struct {
+		Requests      int64
+		Window        time.Duration
+		WarnThreshold float64
+	}
+	Cache struct {
+		LinkTTL   time.Duration
+		LocalSize int64
+		LocalTTL  time.Duration
+		// MetricsFlushInterval is how often hit and miss counts are added to Redis
+		MetricsFlushInterval time.Duration
+	}
+	Rollout struct {
+		// Percentages maps a rollout flag to the share of short codes it is on for
+		Percentages   map[string]int
+		FlushInterval time.Duration
+	}
+	CDN struct {
+		CacheTTL  time.Duration
+		MaxAge    time.Duration
+		Provider  string
+		APIToken  string
+		ServiceID string
+	}
+	APIKeys struct {
+		RotationGrace time.Duration
+	}
+	Embed struct {
+		SigningSecret string
+		RateLimit     int64
+	}
+	// Manage signs the management tokens of anonymous links; empty issues none
+	Manage struct {
+		TokenSecret string
+	}
+	// QuickShorten is the endpoint for browser extensions
+	QuickShorten struct {
+		// AllowedOrigins are the origins allowed to call it: exact origins,
+		// prefixes ending in '*' such as chrome-extension://*, or "*"
+		AllowedOrigins []string
+	}
+	ReservedCodes []string
+	// Destinations limits which hosts links may point at, with patterns
+	// such as example.com or *.example.com
+	Destinations struct {
+		Blocklist []string
+		Allowlist []string
+	}
+	URLPolicy struct {
+		Strict         bool
+		StrictAccounts []string
+		// SortQuery orders destination query parameters when normalizing
+		SortQuery bool
+	}
+	Webhooks struct {
+		URLs          []string
+		Secret        string
+		SchemaVersion int
+	}
+	EventStream struct {
+		// Backend is kafka or nats; empty disables event streaming
+		Backend           string
+		KafkaRESTURL      string
+		KafkaTopic        string
+		NATSURL           string
+		NATSSubjectPrefix string
+		BufferSize        int64
+	}
+	Clicks struct {
+		FlushInterval time.Duration
+		FlushBatch    int64
+		// SyncInterval is how often the shared Redis click counters are
+		// written to MongoDB; 0 makes every replica write to MongoDB directly
+		SyncInterval time.Duration
+	}
+	Sitemap struct {
+		RefreshInterval time.Duration
+	}
+	LinkArchive struct {
+		// After is how long a link goes unclicked before it is archived; 0 turns archiving off
+		After     time.Duration
+		Interval  time.Duration
+		BatchSize int64
+		// Rehydrate moves archived links back to the hot collection when visited
+		Rehydrate bool
+	}
+	LinkHealth struct {
+		// Interval is how often a batch of destinations is checked; 0 turns
+		// the checker off
+		Interval    time.Duration
+		Recheck     time.Duration
+		BatchSize   int64
+		Concurrency int64
+		Timeout     time.Duration
+		// DeactivateAfter turns off links dead for that long; 0 never does
+		DeactivateAfter time.Duration
+	}
+	CodeFilter struct {
+		Enabled bool
+		// Capacity is how many codes the filter is sized for at FalsePositiveRate
+		Capacity          int64
+		FalsePositiveRate float64
+		RebuildInterval   time.Duration
+	}
+	// RedirectStore is where the redirect path reads links ahead of MongoDB
+	RedirectStore struct {
+		// Backend is "mongo" to read MongoDB directly, "cassandra" or "redis"
+		Backend string
+	}
+	// RedisMirror is the redirect store kept in a Redis hash
+	RedisMirror struct {
+		Key string
+		// ReconcileInterval is how often the mirror is compared with MongoDB
+		ReconcileInterval time.Duration
+	}
+	Cassandra struct {
+		Hosts    []string
+		Keyspace string
+		Username string
+		Password string
+		// Consistency is the CQL consistency level of every query
+		Consistency string
+		Timeout     time.Duration
+	}
+	Trash struct {
+		// Retention is how long deleted links can be restored before they are purged
+		Retention     time.Duration
+		PurgeInterval time.Duration
+	}
+	ObjectStore struct {
+		Endpoint  string
+		AccessKey string
+		SecretKey string
+		Bucket    string
+		UseSSL    bool
+	}
+	Archive struct {
+		HotWindow time.Duration
+		Interval  time.Duration
+	}
+	Export struct {
+		Format   string
+		Interval time.Duration
+	}
+	Stats struct {
+		MaxBuckets    int64
+		MaxRange      time.Duration
+		MaxBatchCodes int64
+		QueryTimeout  time.Duration
+		CacheMaxAge   time.Duration
+		// BadgeMaxAge is how long anyone, including CDNs and README image
+		// proxies, may cache a click badge or embedded counter
+		BadgeMaxAge time.Duration
+	}
+	Campaigns struct {
+		MaxLinks int64
+	}
+	// Transfers hand links to other accounts and organizations
+	Transfers struct {
+		// OfferTTL is how long a recipient has to accept a transfer
+		OfferTTL time.Duration
+	}
+	Chat struct {
+		// SlackSigningSecret and DiscordPublicKey verify slash command
+		// requests; each platform is off while its is empty
+		SlackSigningSecret string
+		DiscordPublicKey   string
+		// ConnectCodeTTL is how long a workspace connect code stays usable
+		ConnectCodeTTL time.Duration
+	}
+	Breaker struct {
+		FailureThreshold int64
+		OpenTimeout      time.Duration
+	}
+	Startup struct {
+		RetryTimeout time.Duration
+		// Migrate applies pending database migrations before serving
+		Migrate bool
+	}
+	SMTP struct {
+		Addr     string
+		Username string
+		Password string
+		From     string
+	}
+	// Notifications are the expiration and click milestone emails link owners opt into
+	Notifications struct {
+		ScanInterval time.Duration
+		Workers      int64
+		QueueSize    int64
+	}
+	// Privacy limits the personal data kept about visitors
+	Privacy struct {
+		// Mode puts every link in privacy mode: no click events, only counts
+		Mode bool
+		// IPAnonymization is "truncate", "hash" or empty to keep addresses
+		IPAnonymization string
+		IPHashSecret    string
+	}
+	Honeytokens struct {
+		WebhookURLs []string
+		AlertEmails []string
+	}
+	Bots struct {
+		UserAgents      []string
+		Challenge       bool
+		ChallengeSecret string
+		// CountCrawlers records known crawlers' redirects as bot clicks
+		// instead of skipping them
+		CountCrawlers bool
+	}
+	Tracing struct {
+		// Endpoint is the OTLP/HTTP collector URL; empty disables tracing
+		Endpoint    string
+		ServiceName string
+		SampleRatio float64
+	}
+	Titles struct {
+		// FetchTimeout bounds each request reading a destination page's
+		// metadata or its site's robots.txt
+		FetchTimeout time.Duration
+		// Workers fetch pages concurrently from a queue holding QueueSize
+		Workers   int64
+		QueueSize int64
+	}
+	ErrorPages struct {
+		// FallbackURL receives visitors of unknown, expired and inactive links
+		FallbackURL string
+		// Template is an html/template file rendered for them instead
+		Template string
+	}
+	Cloak struct {
+		// Template is an html/template file replacing the built-in page
+		// cloaked links serve
+		Template string
+	}
+	Robots struct {
+		// Template is a text/template file replacing the built-in robots.txt
+		Template string
+	}
+	GeoIP struct {
+		// Path is a MaxMind DB file locating visitors; empty records only
+		// the country a trusted CDN reports
+		Path string
+		// ReloadInterval is how often the file is checked for changes
+		ReloadInterval time.Duration
+	}
 }
 
-func LoadConfig() (*Config, error) {
-	cfg := &Config{}
-
-	cfg.Server.Port = getEnv("PORT", "8080")
-	cfg.MongoDB.URI = getEnv("MONGODB_URI", "mongodb://localhost:27017")
-	cfg.MongoDB.Database = getEnv("MONGODB_DB", "url_shortener")
-	cfg.Redis.Address = getEnv("REDIS_ADDR", "localhost:6379")
-	cfg.Redis.Password = getEnv("REDIS_PASSWORD", "")
-	cfg.KeyGenServiceURL = getEnv("KEY_GEN_SERVICE_URL", "http://localhost:8081")
-	
-	// Redis DB is an int, handling it simply here for now, default 0
-	cfg.Redis.DB = 0
+// defaultClientIPHeaders are the headers proxies put the client address in
+var defaultClientIPHeaders = []string{"X-Forwarded-For", "X-Real-IP"}
 
-	return cfg, nil
+// defaultReservedCodes are path segments that must never be issued as short codes
+var defaultReservedCodes = []string{
+	"api", "admin", "docs", "openapi.json", "healthz", "readyz", "livez", "metrics",
+	"static", "assets", "robots.txt", "favicon.ico", "sitemap.xml", "login", "logout",
+	"signup", "account", "settings", "dashboard", "integrations", "www", "graphql",
+	"sandbox",
 }
 
-func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
+// LoadConfig reads every setting from, in order of precedence, flags, the
+// environment, the YAML config file and the defaults below, then validates
+// the result. The error lists every problem found, not just the first
+func LoadConfig(flags *Flags) (*Config, error) {
+	if flags == nil {
+		flags = &Flags{}
+	}
+	l, err := newLoader(flags)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+
+	cfg.Server.Port = l.string("PORT", "8080")
+	cfg.Server.TrustedProxies = l.list("TRUSTED_PROXIES")
+	cfg.Server.ClientIPHeaders = l.list("CLIENT_IP_HEADERS")
+	if len(cfg.Server.ClientIPHeaders) == 0 {
+		cfg.Server.ClientIPHeaders = defaultClientIPHeaders
+	}
+	cfg.TLS.CertFile = l.string("TLS_CERT_FILE", "")
+	cfg.TLS.KeyFile = l.string("TLS_KEY_FILE", "")
+	cfg.TLS.Autocert = l.bool("TLS_AUTOCERT", false)
+	cfg.TLS.AutocertEmail = l.string("TLS_AUTOCERT_EMAIL", "")
+	cfg.TLS.AutocertCacheDir = l.string("TLS_AUTOCERT_CACHE_DIR", "certs")
+	cfg.TLS.HTTPPort = l.string("TLS_HTTP_PORT", "")
+	cfg.TLS.RedirectHTTP = l.bool("HTTPS_REDIRECT", false)
+	cfg.TLS.HSTSMaxAge = l.duration("HSTS_MAX_AGE", 0)
+	cfg.TLS.HSTSIncludeSubdomains = l.bool("HSTS_INCLUDE_SUBDOMAINS", false)
+	cfg.Instance.ID = l.string("INSTANCE_ID", "")
+	cfg.Instance.Region = l.string("REGION", "")
+	cfg.Instance.HeartbeatInterval = l.duration("INSTANCE_HEARTBEAT_INTERVAL", 10*time.Second)
+	cfg.Instance.ServedByHeader = l.bool("SERVED_BY_HEADER", false)
+	cfg.Limits.MaxBodyBytes = l.int64("MAX_BODY_BYTES", 1<<20)
+	cfg.Limits.MaxURLBytes = l.int64("MAX_URL_BYTES", 8<<10)
+	cfg.Limits.MaxHeaderBytes = l.int64("MAX_HEADER_BYTES", 32<<10)
+	cfg.MongoDB.URI = l.string("MONGODB_URI", "mongodb://localhost:27017")
+	cfg.MongoDB.Database = l.string("MONGODB_DB", "url_shortener")
+	cfg.MongoDB.MaxPoolSize = l.int64("MONGODB_MAX_POOL_SIZE", 100)
+	cfg.MongoDB.MinPoolSize = l.int64("MONGODB_MIN_POOL_SIZE", 0)
+	cfg.MongoDB.MaxConnIdleTime = l.duration("MONGODB_MAX_CONN_IDLE_TIME", 0)
+	cfg.MongoDB.ReadPreference = l.choice("MONGODB_READ_PREFERENCE", "", "", "primary", "primaryPreferred", "secondary", "secondaryPreferred", "nearest")
+	cfg.MongoDB.WriteConcern = l.string("MONGODB_WRITE_CONCERN", "")
+	cfg.MongoDB.RedirectReadPreference = l.choice("MONGODB_REDIRECT_READ_PREFERENCE", "", "", "primary", "primaryPreferred", "secondary", "secondaryPreferred", "nearest")
+	cfg.MongoDB.ConnectTimeout = l.duration("MONGODB_CONNECT_TIMEOUT", 30*time.Second)
+	cfg.MongoDB.ServerSelectionTimeout = l.duration("MONGODB_SERVER_SELECTION_TIMEOUT", 5*time.Second)
+	cfg.MongoDB.OperationTimeout = l.duration("MONGODB_OPERATION_TIMEOUT", 0)
+	cfg.MongoDB.Shards = l.shards("MONGODB_SHARDS", "MONGODB_SHARD_")
+	cfg.Redis.Mode = l.choice("REDIS_MODE", "standalone", "standalone", "sentinel", "cluster")
+	cfg.Redis.Addresses = l.list("REDIS_ADDR")
+	if _, set := l.lookupSet("REDIS_ADDR"); !set {
+		cfg.Redis.Addresses = []string{"localhost:6379"}
+	}
+	cfg.Redis.Password = l.string("REDIS_PASSWORD", "")
+	cfg.Redis.DB = int(l.int64("REDIS_DB", 0))
+	cfg.Redis.MasterName = l.string("REDIS_SENTINEL_MASTER", "")
+	cfg.Redis.SentinelPassword = l.string("REDIS_SENTINEL_PASSWORD", "")
+	cfg.KeyGenServiceURL = strings.TrimRight(l.string("KEY_GEN_SERVICE_URL", ""), "/")
+	cfg.Keygen.Port = l.string("KEYGEN_PORT", "8081")
+	cfg.Keygen.Queue = l.string("KEY_QUEUE", "short_code_queue")
+	cfg.Keygen.QueueSize = l.int64("KEY_QUEUE_SIZE", 10000)
+	cfg.Keygen.RangeSize = l.int64("KEY_RANGE_SIZE", 1000)
+	cfg.Keygen.CodeLength = int(l.int64("KEY_CODE_LENGTH", 7))
+	cfg.Keygen.RefillInterval = l.duration("KEY_REFILL_INTERVAL", 5*time.Second)
+	cfg.Keygen.BlockSize = l.int64("KEY_BLOCK_SIZE", 100)
+	cfg.Keygen.LeaseTTL = l.duration("KEY_LEASE_TTL", 10*time.Minute)
+	cfg.BaseURL = strings.TrimRight(l.string("BASE_URL", "http://localhost:8080"), "/")
+	cfg.ShortenerHosts = l.list("SHORTENER_HOSTS")
+	cfg.Response.Casing = l.choice("RESPONSE_CASING", "snake", "snake", "camel")
+	cfg.Response.Envelope = l.bool("RESPONSE_ENVELOPE", false)
+	cfg.APIVersions.V1DeprecatedAt = l.time("API_V1_DEPRECATED_AT")
+	cfg.APIVersions.V1SunsetAt = l.time("API_V1_SUNSET_AT")
+	cfg.APIVersions.V1MigrationURL = l.string("API_V1_MIGRATION_URL", "")
+	cfg.APIVersions.FlushInterval = l.duration("API_VERSION_FLUSH_INTERVAL", 10*time.Second)
+	cfg.AdminToken = l.string("ADMIN_TOKEN", "")
+	cfg.Quota.LinksPerMonth = l.int64("QUOTA_LINKS_PER_MONTH", 1000)
+	cfg.Quota.LinksPerDay = l.int64("QUOTA_LINKS_PER_DAY", 0)
+	cfg.Quota.Plans = l.plans("QUOTA_PLANS")
+	cfg.RateLimit.Requests = l.int64("RATE_LIMIT_REQUESTS", 600)
+	cfg.RateLimit.Window = l.duration("RATE_LIMIT_WINDOW", time.Minute)
+	cfg.RateLimit.WarnThreshold = l.float("RATE_LIMIT_WARN_THRESHOLD", 0.8)
+	cfg.Cache.LinkTTL = l.duration("LINK_CACHE_TTL", 10*time.Minute)
+	cfg.Cache.LocalSize = l.int64("LOCAL_CACHE_SIZE", 10000)
+	cfg.Cache.LocalTTL = l.duration("LOCAL_CACHE_TTL", 5*time.Second)
+	cfg.Cache.MetricsFlushInterval = l.duration("CACHE_METRICS_FLUSH_INTERVAL", 10*time.Second)
+	cfg.Rollout.Percentages = l.percentages("ROLLOUTS", map[string]int{"local_cache": 100})
+	cfg.Rollout.FlushInterval = l.duration("ROLLOUT_FLUSH_INTERVAL", 10*time.Second)
+	cfg.CDN.CacheTTL = l.duration("CDN_CACHE_TTL", 0)
+	cfg.CDN.MaxAge = l.duration("REDIRECT_MAX_AGE", 0)
+	cfg.CDN.Provider = l.choice("CDN_PROVIDER", "", "", "fastly", "cloudflare")
+	cfg.CDN.APIToken = l.string("CDN_API_TOKEN", "")
+	cfg.CDN.ServiceID = l.string("CDN_SERVICE_ID", "")
+	cfg.APIKeys.RotationGrace = l.duration("API_KEY_ROTATION_GRACE", 7*24*time.Hour)
+	cfg.Embed.SigningSecret = l.string("EMBED_SIGNING_SECRET", "")
+	cfg.Embed.RateLimit = l.int64("EMBED_RATE_LIMIT", 30)
+	cfg.Manage.TokenSecret = l.string("MANAGE_TOKEN_SECRET", "")
+	cfg.QuickShorten.AllowedOrigins = l.list("QUICK_SHORTEN_ORIGINS")
+	if len(cfg.QuickShorten.AllowedOrigins) == 0 {
+		cfg.QuickShorten.AllowedOrigins = []string{"*"}
+	}
+	cfg.ReservedCodes = l.list("RESERVED_CODES")
+	if len(cfg.ReservedCodes) == 0 {
+		cfg.ReservedCodes = defaultReservedCodes
+	}
+	cfg.Destinations.Blocklist = l.list("DESTINATION_BLOCKLIST")
+	cfg.Destinations.Allowlist = l.list("DESTINATION_ALLOWLIST")
+	cfg.URLPolicy.Strict = l.choice("URL_POLICY", "standard", "standard", "strict") == "strict"
+	cfg.URLPolicy.StrictAccounts = l.list("STRICT_URL_ACCOUNTS")
+	cfg.URLPolicy.SortQuery = l.bool("URL_SORT_QUERY", false)
+	cfg.Webhooks.URLs = l.list("WEBHOOK_URLS")
+	cfg.Webhooks.Secret = l.string("WEBHOOK_SECRET", "")
+	cfg.Webhooks.SchemaVersion = int(l.int64("WEBHOOK_SCHEMA_VERSION", 0))
+	cfg.EventStream.Backend = l.choice("EVENT_STREAM", "", "", "kafka", "nats")
+	cfg.EventStream.KafkaRESTURL = l.string("KAFKA_REST_URL", "http://localhost:8082")
+	cfg.EventStream.KafkaTopic = l.string("KAFKA_TOPIC", "url-shortener-events")
+	cfg.EventStream.NATSURL = l.string("NATS_URL", "nats://localhost:4222")
+	cfg.EventStream.NATSSubjectPrefix = l.string("NATS_SUBJECT_PREFIX", "url_shortener")
+	cfg.EventStream.BufferSize = l.int64("EVENT_STREAM_BUFFER", 10000)
+	cfg.Clicks.FlushInterval = l.duration("CLICK_FLUSH_INTERVAL", 5*time.Second)
+	cfg.Clicks.FlushBatch = l.int64("CLICK_FLUSH_BATCH", 1000)
+	cfg.Clicks.SyncInterval = l.duration("CLICK_SYNC_INTERVAL", 10*time.Second)
+	cfg.Sitemap.RefreshInterval = l.duration("SITEMAP_REFRESH_INTERVAL", time.Hour)
+	cfg.LinkArchive.After = l.duration("LINK_ARCHIVE_AFTER", 0)
+	cfg.LinkArchive.Interval = l.duration("LINK_ARCHIVE_INTERVAL", 24*time.Hour)
+	cfg.LinkArchive.BatchSize = l.int64("LINK_ARCHIVE_BATCH_SIZE", 1000)
+	cfg.LinkArchive.Rehydrate = l.bool("LINK_ARCHIVE_REHYDRATE", true)
+	cfg.LinkHealth.Interval = l.duration("LINK_HEALTH_INTERVAL", 0)
+	cfg.LinkHealth.Recheck = l.duration("LINK_HEALTH_RECHECK", 24*time.Hour)
+	cfg.LinkHealth.BatchSize = l.int64("LINK_HEALTH_BATCH_SIZE", 100)
+	cfg.LinkHealth.Concurrency = l.int64("LINK_HEALTH_CONCURRENCY", 8)
+	cfg.LinkHealth.Timeout = l.duration("LINK_HEALTH_TIMEOUT", 10*time.Second)
+	cfg.LinkHealth.DeactivateAfter = l.duration("LINK_HEALTH_DEACTIVATE_AFTER", 0)
+	cfg.CodeFilter.Enabled = l.bool("CODE_FILTER", false)
+	cfg.CodeFilter.Capacity = l.int64("CODE_FILTER_CAPACITY", 10000000)
+	cfg.CodeFilter.FalsePositiveRate = l.float("CODE_FILTER_FALSE_POSITIVE_RATE", 0.01)
+	cfg.CodeFilter.RebuildInterval = l.duration("CODE_FILTER_REBUILD_INTERVAL", 24*time.Hour)
+	cfg.RedirectStore.Backend = l.choice("REDIRECT_STORE", "mongo", "mongo", "cassandra", "redis")
+	cfg.RedisMirror.Key = l.string("REDIS_MIRROR_KEY", "redirect_links")
+	cfg.RedisMirror.ReconcileInterval = l.duration("REDIS_MIRROR_RECONCILE_INTERVAL", time.Hour)
+	cfg.Cassandra.Hosts = l.list("CASSANDRA_HOSTS")
+	cfg.Cassandra.Keyspace = l.string("CASSANDRA_KEYSPACE", "url_shortener")
+	cfg.Cassandra.Username = l.string("CASSANDRA_USERNAME", "")
+	cfg.Cassandra.Password = l.string("CASSANDRA_PASSWORD", "")
+	cfg.Cassandra.Consistency = l.string("CASSANDRA_CONSISTENCY", "LOCAL_QUORUM")
+	cfg.Cassandra.Timeout = l.duration("CASSANDRA_TIMEOUT", 2*time.Second)
+	cfg.Trash.Retention = l.duration("TRASH_RETENTION", 30*24*time.Hour)
+	cfg.Trash.PurgeInterval = l.duration("TRASH_PURGE_INTERVAL", time.Hour)
+	cfg.ObjectStore.Endpoint = l.string("OBJECT_STORE_ENDPOINT", "")
+	cfg.ObjectStore.AccessKey = l.string("OBJECT_STORE_ACCESS_KEY", "")
+	cfg.ObjectStore.SecretKey = l.string("OBJECT_STORE_SECRET_KEY", "")
+	cfg.ObjectStore.Bucket = l.string("OBJECT_STORE_BUCKET", "url-shortener")
+	cfg.ObjectStore.UseSSL = l.bool("OBJECT_STORE_USE_SSL", true)
+	cfg.Archive.HotWindow = l.duration("CLICK_HOT_WINDOW", 90*24*time.Hour)
+	cfg.Archive.Interval = l.duration("CLICK_ARCHIVE_INTERVAL", 24*time.Hour)
+	cfg.Export.Format = l.choice("EXPORT_FORMAT", "csv", "csv", "csv.gz", "parquet")
+	cfg.Export.Interval = l.duration("EXPORT_INTERVAL", 24*time.Hour)
+	cfg.Stats.MaxBuckets = l.int64("STATS_MAX_BUCKETS", 1000)
+	cfg.Stats.MaxRange = l.duration("STATS_MAX_RANGE", 2*365*24*time.Hour)
+	cfg.Stats.MaxBatchCodes = l.int64("STATS_MAX_BATCH_CODES", 25)
+	cfg.Stats.QueryTimeout = l.duration("STATS_QUERY_TIMEOUT", 5*time.Second)
+	cfg.Stats.CacheMaxAge = l.duration("STATS_CACHE_MAX_AGE", 0)
+	cfg.Stats.BadgeMaxAge = l.duration("STATS_BADGE_MAX_AGE", 5*time.Minute)
+	cfg.Campaigns.MaxLinks = l.int64("CAMPAIGN_MAX_LINKS", 100)
+	cfg.Transfers.OfferTTL = l.duration("TRANSFER_OFFER_TTL", 7*24*time.Hour)
+	cfg.Chat.SlackSigningSecret = l.string("SLACK_SIGNING_SECRET", "")
+	cfg.Chat.DiscordPublicKey = l.string("DISCORD_PUBLIC_KEY", "")
+	cfg.Chat.ConnectCodeTTL = l.duration("CHAT_CONNECT_CODE_TTL", 15*time.Minute)
+	cfg.Breaker.FailureThreshold = l.int64("BREAKER_FAILURE_THRESHOLD", 5)
+	cfg.Breaker.OpenTimeout = l.duration("BREAKER_OPEN_TIMEOUT", 30*time.Second)
+	cfg.Startup.RetryTimeout = l.duration("STARTUP_RETRY_TIMEOUT", 30*time.Second)
+	cfg.Startup.Migrate = l.bool("MIGRATE_ON_START", true)
+	cfg.SMTP.Addr = l.string("SMTP_ADDR", "")
+	cfg.SMTP.Username = l.string("SMTP_USERNAME", "")
+	cfg.SMTP.Password = l.string("SMTP_PASSWORD", "")
+	cfg.SMTP.From = l.string("SMTP_FROM", "alerts@localhost")
+	cfg.Privacy.Mode = l.bool("PRIVACY_MODE", false)
+	cfg.Privacy.IPAnonymization = l.choice("PRIVACY_IP_ANONYMIZATION", "", "", "truncate", "hash")
+	cfg.Privacy.IPHashSecret = l.string("PRIVACY_IP_HASH_SECRET", "")
+	cfg.Notifications.ScanInterval = l.duration("NOTIFICATION_SCAN_INTERVAL", time.Hour)
+	cfg.Notifications.Workers = l.int64("NOTIFICATION_WORKERS", 2)
+	cfg.Notifications.QueueSize = l.int64("NOTIFICATION_QUEUE_SIZE", 100)
+	cfg.Honeytokens.WebhookURLs = l.list("HONEYTOKEN_WEBHOOK_URLS")
+	cfg.Honeytokens.AlertEmails = l.list("HONEYTOKEN_ALERT_EMAILS")
+	cfg.Bots.UserAgents = l.list("BOT_USER_AGENTS")
+	cfg.Bots.Challenge = l.bool("BOT_CHALLENGE", false)
+	cfg.Bots.ChallengeSecret = l.string("BOT_CHALLENGE_SECRET", "")
+	cfg.Bots.CountCrawlers = l.bool("COUNT_CRAWLER_CLICKS", false)
+	cfg.Tracing.Endpoint = l.string("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	cfg.Tracing.ServiceName = l.string("OTEL_SERVICE_NAME", "url-shortener")
+	cfg.Tracing.SampleRatio = l.float("TRACE_SAMPLE_RATIO", 1)
+	cfg.Titles.FetchTimeout = l.duration("TITLE_FETCH_TIMEOUT", 3*time.Second)
+	cfg.Titles.Workers = l.int64("TITLE_FETCH_WORKERS", 4)
+	cfg.Titles.QueueSize = l.int64("TITLE_FETCH_QUEUE_SIZE", 1000)
+	cfg.ErrorPages.FallbackURL = l.string("ERROR_PAGE_URL", "")
+	cfg.ErrorPages.Template = l.string("ERROR_PAGE_TEMPLATE", "")
+	cfg.Cloak.Template = l.string("CLOAK_TEMPLATE", "")
+	cfg.Robots.Template = l.string("ROBOTS_TXT_TEMPLATE", "")
+	cfg.GeoIP.Path = l.string("GEOIP_DB_PATH", "")
+	cfg.GeoIP.ReloadInterval = l.duration("GEOIP_RELOAD_INTERVAL", time.Minute)
+
+	l.unknown()
+	if err := errors.Join(append(l.errs, cfg.Validate())...); err != nil {
+		return nil, err
 	}
-	return fallback
+	return cfg, nil
 }
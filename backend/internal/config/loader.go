@@ -0,0 +1,360 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Flags are the command-line flags every binary accepts
+type Flags struct {
+	// File is a YAML file of settings, from --config or CONFIG_FILE
+	File string
+	// ValidateOnly asks to check the configuration and exit, from
+	// --validate-config
+	ValidateOnly bool
+	// Migrate is a migrations command to run instead of serving, from
+	// --migrate: up, down or status
+	Migrate string
+	// Settings are --setting-name=value overrides, keyed by the setting's
+	// environment variable name
+	Settings map[string]string
+}
+
+// ParseFlags reads --config, --validate-config, --migrate and any setting
+// given as a flag: --mongodb-uri=... or --mongodb-uri ... sets MONGODB_URI. A setting
+// flag without a value is true
+func ParseFlags(args []string) (*Flags, error) {
+	flags := &Flags{Settings: map[string]string{}}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name := strings.TrimLeft(arg, "-")
+		if name == arg || name == "" {
+			return nil, fmt.Errorf("unexpected argument %q", arg)
+		}
+		name, value, hasValue := strings.Cut(name, "=")
+		switch name {
+		case "validate-config":
+			flags.ValidateOnly = true
+			continue
+		}
+		if !hasValue {
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				i++
+				value = args[i]
+			} else if name == "config" || name == "migrate" {
+				return nil, fmt.Errorf("--%s needs a value", name)
+			} else {
+				value = "true"
+			}
+		}
+		switch name {
+		case "config":
+			flags.File = value
+			continue
+		case "migrate":
+			if !slices.Contains([]string{"up", "down", "status"}, value) {
+				return nil, fmt.Errorf("--migrate must be up, down or status, got %q", value)
+			}
+			flags.Migrate = value
+			continue
+		}
+		flags.Settings[settingKey(name)] = value
+	}
+	return flags, nil
+}
+
+// settingKey turns a flag or file key into the setting's environment name
+func settingKey(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(name), "-", "_"))
+}
+
+// loader reads settings from, in order of precedence, flags, the
+// environment and the YAML file. Malformed values are collected as errors
+// instead of silently falling back to the default
+type loader struct {
+	flags map[string]string
+	file  map[string]string
+	used  map[string]bool
+	errs  []error
+}
+
+func newLoader(flags *Flags) (*loader, error) {
+	l := &loader{flags: flags.Settings, file: map[string]string{}, used: map[string]bool{}}
+	path := flags.File
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		file, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		l.file = file
+	}
+	return l, nil
+}
+
+// readFile reads a YAML file of settings named like their environment
+// variables, in any case. Lists may be YAML sequences and key=value settings
+// YAML maps
+func readFile(path string) (map[string]string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	var doc map[string]yaml.Node
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	values := make(map[string]string, len(doc))
+	for key, node := range doc {
+		value, err := nodeValue(&node)
+		if err != nil {
+			return nil, fmt.Errorf("config file %s: %s %w", path, key, err)
+		}
+		values[settingKey(key)] = value
+	}
+	return values, nil
+}
+
+// nodeValue flattens a YAML value to the form the environment uses
+func nodeValue(node *yaml.Node) (string, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			return "", nil
+		}
+		return node.Value, nil
+	case yaml.SequenceNode:
+		items := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return "", errors.New("must only list values")
+			}
+			items = append(items, item.Value)
+		}
+		return strings.Join(items, ","), nil
+	case yaml.MappingNode:
+		pairs := make([]string, 0, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i+1].Kind != yaml.ScalarNode {
+				return "", errors.New("must only map names to values")
+			}
+			pairs = append(pairs, node.Content[i].Value+"="+node.Content[i+1].Value)
+		}
+		return strings.Join(pairs, ","), nil
+	}
+	return "", errors.New("must be a value, a list or a map")
+}
+
+func (l *loader) lookup(key string) (string, bool) {
+	l.used[key] = true
+	if value, ok := l.flags[key]; ok {
+		return value, true
+	}
+	if value, ok := os.LookupEnv(key); ok {
+		return value, true
+	}
+	value, ok := l.file[key]
+	return value, ok
+}
+
+// lookupSet is lookup treating empty values as unset, for typed settings
+func (l *loader) lookupSet(key string) (string, bool) {
+	value, ok := l.lookup(key)
+	value = strings.TrimSpace(value)
+	return value, ok && value != ""
+}
+
+func (l *loader) fail(key, format string, args ...interface{}) {
+	l.errs = append(l.errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+}
+
+func (l *loader) string(key, fallback string) string {
+	if value, ok := l.lookup(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func (l *loader) bool(key string, fallback bool) bool {
+	value, ok := l.lookupSet(key)
+	if !ok {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		l.fail(key, "%q is not true or false", value)
+		return fallback
+	}
+	return parsed
+}
+
+func (l *loader) int64(key string, fallback int64) int64 {
+	value, ok := l.lookupSet(key)
+	if !ok {
+		return fallback
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		l.fail(key, "%q is not a whole number", value)
+		return fallback
+	}
+	return parsed
+}
+
+func (l *loader) float(key string, fallback float64) float64 {
+	value, ok := l.lookupSet(key)
+	if !ok {
+		return fallback
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		l.fail(key, "%q is not a number", value)
+		return fallback
+	}
+	return parsed
+}
+
+func (l *loader) duration(key string, fallback time.Duration) time.Duration {
+	value, ok := l.lookupSet(key)
+	if !ok {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		l.fail(key, "%q is not a duration such as 30s, 10m or 24h", value)
+		return fallback
+	}
+	return parsed
+}
+
+// time reads an RFC 3339 timestamp; unset is zero
+func (l *loader) time(key string) time.Time {
+	value, ok := l.lookupSet(key)
+	if !ok {
+		return time.Time{}
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		l.fail(key, "%q is not an RFC 3339 time such as 2025-01-31T00:00:00Z", value)
+	}
+	return parsed
+}
+
+// list reads a comma-separated list, skipping empty entries
+func (l *loader) list(key string) []string {
+	value, _ := l.lookup(key)
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// choice reads a string that must be one of choices
+func (l *loader) choice(key, fallback string, choices ...string) string {
+	value := l.string(key, fallback)
+	if !slices.Contains(choices, value) {
+		l.fail(key, "%q must be one of %s", value, strings.Join(choices, ", "))
+		return fallback
+	}
+	return value
+}
+
+// PlanQuota is a quota plan's daily and monthly link creation limits
+type PlanQuota struct {
+	Daily   int64
+	Monthly int64
+}
+
+// plans reads comma-separated plan=daily/monthly entries
+func (l *loader) plans(key string) map[string]PlanQuota {
+	plans := map[string]PlanQuota{}
+	for _, item := range l.list(key) {
+		name, limits, _ := strings.Cut(item, "=")
+		dailyRaw, monthlyRaw, ok := strings.Cut(limits, "/")
+		daily, dailyErr := strconv.ParseInt(strings.TrimSpace(dailyRaw), 10, 64)
+		monthly, monthlyErr := strconv.ParseInt(strings.TrimSpace(monthlyRaw), 10, 64)
+		if !ok || strings.TrimSpace(name) == "" || dailyErr != nil || monthlyErr != nil {
+			l.fail(key, "%q is not plan=daily/monthly", item)
+			continue
+		}
+		plans[strings.TrimSpace(name)] = PlanQuota{Daily: daily, Monthly: monthly}
+	}
+	return plans
+}
+
+// percentages reads comma-separated flag=percent pairs over the defaults
+func (l *loader) percentages(key string, defaults map[string]int) map[string]int {
+	percentages := make(map[string]int, len(defaults))
+	for flag, percent := range defaults {
+		percentages[flag] = percent
+	}
+	for _, item := range l.list(key) {
+		flag, value, _ := strings.Cut(item, "=")
+		percent, err := strconv.Atoi(strings.TrimSpace(value))
+		if strings.TrimSpace(flag) == "" || err != nil || percent < 0 || percent > 100 {
+			l.fail(key, "%q is not flag=percent with a percent from 0 to 100", item)
+			continue
+		}
+		percentages[strings.TrimSpace(flag)] = percent
+	}
+	return percentages
+}
+
+// shards reads the names listed under key and each one's URI from
+// prefix<NAME>_URI, which keeps the commas of replica set URIs intact
+func (l *loader) shards(key, prefix string) map[string]string {
+	shards := map[string]string{}
+	for _, name := range l.list(key) {
+		if !shardNamePattern.MatchString(name) {
+			l.fail(key, "%q is not a shard name of lowercase letters, digits and _", name)
+			continue
+		}
+		if _, ok := shards[name]; ok {
+			l.fail(key, "%q is listed twice", name)
+			continue
+		}
+		uriKey := prefix + strings.ToUpper(name) + "_URI"
+		uri, ok := l.lookupSet(uriKey)
+		if !ok {
+			l.fail(uriKey, "must be set for shard %q", name)
+			continue
+		}
+		shards[name] = uri
+	}
+	return shards
+}
+
+var shardNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// unknown reports flags and file entries that name no setting, which are
+// usually typos
+func (l *loader) unknown() {
+	for _, key := range sortedKeys(l.flags) {
+		if !l.used[key] {
+			l.errs = append(l.errs, fmt.Errorf("--%s: unknown flag", strings.ToLower(strings.ReplaceAll(key, "_", "-"))))
+		}
+	}
+	for _, key := range sortedKeys(l.file) {
+		if !l.used[key] {
+			l.errs = append(l.errs, fmt.Errorf("%s: unknown setting in config file", strings.ToLower(key)))
+		}
+	}
+}
+
+func sortedKeys(values map[string]string) []string {
+	return slices.Sorted(maps.Keys(values))
+}
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+)
+
+// Validate checks settings that parse but can't work: missing required
+// values, ports out of range, malformed URLs, out of range numbers and
+// settings that need each other. The error lists every problem
+func (c *Config) Validate() error {
+	var v validation
+
+	v.required("MONGODB_URI", c.MongoDB.URI)
+	v.required("MONGODB_DB", c.MongoDB.Database)
+	if len(c.Redis.Addresses) == 0 {
+		v.fail("REDIS_ADDR", "is required")
+	}
+
+	v.port("PORT", c.Server.Port, true)
+	v.port("KEYGEN_PORT", c.Keygen.Port, true)
+	v.port("TLS_HTTP_PORT", c.TLS.HTTPPort, false)
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		v.fail("TLS_CERT_FILE", "must be set together with TLS_KEY_FILE")
+	}
+
+	v.url("MONGODB_URI", c.MongoDB.URI, "mongodb", "mongodb+srv")
+	for _, name := range slices.Sorted(maps.Keys(c.MongoDB.Shards)) {
+		v.url("MONGODB_SHARD_"+strings.ToUpper(name)+"_URI", c.MongoDB.Shards[name], "mongodb", "mongodb+srv")
+	}
+	v.url("BASE_URL", c.BaseURL, "http", "https")
+	v.url("KEY_GEN_SERVICE_URL", c.KeyGenServiceURL, "http", "https")
+	v.url("API_V1_MIGRATION_URL", c.APIVersions.V1MigrationURL, "http", "https")
+	v.url("ERROR_PAGE_URL", c.ErrorPages.FallbackURL, "http", "https")
+	v.url("OTEL_EXPORTER_OTLP_ENDPOINT", c.Tracing.Endpoint, "http", "https")
+	for _, webhook := range c.Webhooks.URLs {
+		v.url("WEBHOOK_URLS", webhook, "http", "https")
+	}
+	for _, webhook := range c.Honeytokens.WebhookURLs {
+		v.url("HONEYTOKEN_WEBHOOK_URLS", webhook, "http", "https")
+	}
+	for _, origin := range c.QuickShorten.AllowedOrigins {
+		if !validators.ValidOriginPattern(origin) {
+			v.fail("QUICK_SHORTEN_ORIGINS", "%q is not an origin, scheme://*, scheme://*.host or '*'", origin)
+		}
+	}
+	v.hostPatterns("DESTINATION_BLOCKLIST", c.Destinations.Blocklist)
+	v.hostPatterns("DESTINATION_ALLOWLIST", c.Destinations.Allowlist)
+	switch c.EventStream.Backend {
+	case "kafka":
+		v.url("KAFKA_REST_URL", c.EventStream.KafkaRESTURL, "http", "https")
+	case "nats":
+		v.url("NATS_URL", c.EventStream.NATSURL, "nats", "tls")
+	}
+
+	v.between("RATE_LIMIT_WARN_THRESHOLD", c.RateLimit.WarnThreshold, 0, 1)
+	v.between("TRACE_SAMPLE_RATIO", c.Tracing.SampleRatio, 0, 1)
+	// A shortened URL must fit in a request
+	v.atLeast("MAX_BODY_BYTES", c.Limits.MaxBodyBytes, 4<<10)
+	v.atLeast("MAX_URL_BYTES", c.Limits.MaxURLBytes, 4<<10)
+	v.atLeast("MAX_HEADER_BYTES", c.Limits.MaxHeaderBytes, 4<<10)
+	v.atLeast("MONGODB_MAX_POOL_SIZE", c.MongoDB.MaxPoolSize, 0)
+	v.atLeast("MONGODB_MIN_POOL_SIZE", c.MongoDB.MinPoolSize, 0)
+	if c.MongoDB.MaxPoolSize > 0 && c.MongoDB.MinPoolSize > c.MongoDB.MaxPoolSize {
+		v.fail("MONGODB_MIN_POOL_SIZE", "%d is more than MONGODB_MAX_POOL_SIZE", c.MongoDB.MinPoolSize)
+	}
+	if c.MongoDB.WriteConcern != "" && c.MongoDB.WriteConcern != "majority" {
+		if members, err := strconv.Atoi(c.MongoDB.WriteConcern); err != nil || members < 0 {
+			v.fail("MONGODB_WRITE_CONCERN", "%q is not majority or a number of members", c.MongoDB.WriteConcern)
+		}
+	}
+	v.positive("TRANSFER_OFFER_TTL", c.Transfers.OfferTTL)
+	if key, err := hex.DecodeString(c.Chat.DiscordPublicKey); err != nil || (len(key) != 0 && len(key) != ed25519.PublicKeySize) {
+		v.fail("DISCORD_PUBLIC_KEY", "must be the application's hex encoded Ed25519 public key")
+	}
+	v.positive("CHAT_CONNECT_CODE_TTL", c.Chat.ConnectCodeTTL)
+	v.positive("MONGODB_CONNECT_TIMEOUT", c.MongoDB.ConnectTimeout)
+	v.positive("MONGODB_SERVER_SELECTION_TIMEOUT", c.MongoDB.ServerSelectionTimeout)
+	v.atLeast("REDIS_DB", int64(c.Redis.DB), 0)
+	switch c.Redis.Mode {
+	case "standalone":
+		if len(c.Redis.Addresses) > 1 {
+			v.fail("REDIS_ADDR", "lists %d addresses; set REDIS_MODE to sentinel or cluster for more than one", len(c.Redis.Addresses))
+		}
+	case "sentinel":
+		v.required("REDIS_SENTINEL_MASTER", c.Redis.MasterName)
+	case "cluster":
+		if c.Redis.DB != 0 {
+			v.fail("REDIS_DB", "must be 0 in cluster mode")
+		}
+	}
+	v.positive("RATE_LIMIT_WINDOW", c.RateLimit.Window)
+	v.positive("INSTANCE_HEARTBEAT_INTERVAL", c.Instance.HeartbeatInterval)
+	v.positive("KEY_REFILL_INTERVAL", c.Keygen.RefillInterval)
+	v.atLeast("KEY_BLOCK_SIZE", c.Keygen.BlockSize, 0)
+	v.positive("KEY_LEASE_TTL", c.Keygen.LeaseTTL)
+	v.positive("API_VERSION_FLUSH_INTERVAL", c.APIVersions.FlushInterval)
+	v.positive("CACHE_METRICS_FLUSH_INTERVAL", c.Cache.MetricsFlushInterval)
+	v.positive("ROLLOUT_FLUSH_INTERVAL", c.Rollout.FlushInterval)
+	v.positive("CLICK_FLUSH_INTERVAL", c.Clicks.FlushInterval)
+	v.positive("SITEMAP_REFRESH_INTERVAL", c.Sitemap.RefreshInterval)
+	v.positive("TRASH_PURGE_INTERVAL", c.Trash.PurgeInterval)
+	v.positive("REDIS_MIRROR_RECONCILE_INTERVAL", c.RedisMirror.ReconcileInterval)
+
+	if c.RedirectStore.Backend == "cassandra" && len(c.Cassandra.Hosts) == 0 {
+		v.fail("CASSANDRA_HOSTS", "must be set when REDIRECT_STORE is cassandra")
+	}
+	if c.Privacy.IPAnonymization == "hash" && c.Privacy.IPHashSecret == "" {
+		v.fail("PRIVACY_IP_HASH_SECRET", "must be set when PRIVACY_IP_ANONYMIZATION is hash")
+	}
+	if c.ObjectStore.Endpoint != "" {
+		v.positive("CLICK_ARCHIVE_INTERVAL", c.Archive.Interval)
+		v.positive("EXPORT_INTERVAL", c.Export.Interval)
+		// Exports read click events from MongoDB, which only keeps the hot window
+		if c.Export.Interval > c.Archive.HotWindow {
+			v.fail("EXPORT_INTERVAL", "must not be longer than CLICK_HOT_WINDOW")
+		}
+	}
+	if c.LinkArchive.After > 0 {
+		v.positive("LINK_ARCHIVE_INTERVAL", c.LinkArchive.Interval)
+	}
+	if c.LinkHealth.Interval > 0 {
+		v.atLeast("LINK_HEALTH_BATCH_SIZE", c.LinkHealth.BatchSize, 1)
+		v.atLeast("LINK_HEALTH_CONCURRENCY", c.LinkHealth.Concurrency, 1)
+	}
+	if c.CodeFilter.Enabled {
+		v.atLeast("CODE_FILTER_CAPACITY", c.CodeFilter.Capacity, 1)
+		if c.CodeFilter.FalsePositiveRate <= 0 || c.CodeFilter.FalsePositiveRate >= 1 {
+			v.fail("CODE_FILTER_FALSE_POSITIVE_RATE", "must be between 0 and 1")
+		}
+		v.positive("CODE_FILTER_REBUILD_INTERVAL", c.CodeFilter.RebuildInterval)
+	}
+	v.positive("TITLE_FETCH_TIMEOUT", c.Titles.FetchTimeout)
+	v.atLeast("TITLE_FETCH_WORKERS", c.Titles.Workers, 1)
+	v.atLeast("TITLE_FETCH_QUEUE_SIZE", c.Titles.QueueSize, 1)
+	v.positive("GEOIP_RELOAD_INTERVAL", c.GeoIP.ReloadInterval)
+	// Notifications only go out with a mail relay
+	if c.SMTP.Addr != "" {
+		v.atLeast("NOTIFICATION_WORKERS", c.Notifications.Workers, 1)
+		v.atLeast("NOTIFICATION_QUEUE_SIZE", c.Notifications.QueueSize, 0)
+		v.positive("NOTIFICATION_SCAN_INTERVAL", c.Notifications.ScanInterval)
+	}
+	return errors.Join(v.errs...)
+}
+
+// validation collects the problems Validate finds
+type validation struct {
+	errs []error
+}
+
+func (v *validation) fail(key, format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+}
+
+func (v *validation) required(key, value string) {
+	if value == "" {
+		v.fail(key, "is required")
+	}
+}
+
+// port checks a TCP port number; optional ports may be empty
+func (v *validation) port(key, value string, required bool) {
+	if value == "" && !required {
+		return
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		v.fail(key, "%q is not a port from 1 to 65535", value)
+	}
+}
+
+// url checks an absolute URL with one of schemes; empty values are left to
+// required
+func (v *validation) url(key, value string, schemes ...string) {
+	if value == "" {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" || !slices.Contains(schemes, u.Scheme) {
+		v.fail(key, "%q is not an absolute %s URL", value, schemeList(schemes))
+	}
+}
+
+func schemeList(schemes []string) string {
+	list := schemes[0]
+	for i, scheme := range schemes[1:] {
+		if i == len(schemes)-2 {
+			list += " or " + scheme
+		} else {
+			list += ", " + scheme
+		}
+	}
+	return list
+}
+
+// hostPatterns checks a list of destination host patterns
+func (v *validation) hostPatterns(key string, patterns []string) {
+	for _, pattern := range patterns {
+		if _, ok := validators.NormalizeHostPattern(pattern); !ok {
+			v.fail(key, "%q is not a host name or IP address pattern", pattern)
+		}
+	}
+}
+
+func (v *validation) between(key string, value, low, high float64) {
+	if value < low || value > high {
+		v.fail(key, "%v is not between %v and %v", value, low, high)
+	}
+}
+
+func (v *validation) positive(key string, value time.Duration) {
+	if value <= 0 {
+		v.fail(key, "must be positive")
+	}
+}
+
+func (v *validation) atLeast(key string, value, low int64) {
+	if value < low {
+		v.fail(key, "%d is less than %d", value, low)
+	}
+}
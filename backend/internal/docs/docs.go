@@ -0,0 +1,11 @@
+// Package docs embeds the hand-written OpenAPI spec and the Swagger UI page
+// Keep openapi.json in sync when adding or changing routes in cmd/server
+package docs
+
+import _ "embed"
+
+//go:embed openapi.json
+var OpenAPISpec []byte
+
+//go:embed swagger.html
+var SwaggerUI []byte
@@ -0,0 +1,94 @@
+package events
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Type identifies the kind of outbound event
+type Type string
+
+const (
+	TypeLinkCreated Type = "link.created"
+	TypeLinkUpdated Type = "link.updated"
+	TypeLinkDeleted Type = "link.deleted"
+	TypeLinkClicked Type = "link.clicked"
+
+	TypeRateLimitWarning Type = "rate_limit.warning"
+
+	TypeHoneytokenTriggered Type = "honeytoken.triggered"
+)
+
+// Envelope wraps every outbound event payload (webhooks, Kafka, streams)
+// SchemaVersion tells consumers which shape Data has so they can pin a version
+type Envelope struct {
+	ID            string          `json:"id"`
+	Type          Type            `json:"type"`
+	SchemaVersion int             `json:"schema_version"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// LinkCreated is the v1 payload for link.created
+type LinkCreated struct {
+	ShortCode   string     `json:"short_code"`
+	Domain      string     `json:"domain,omitempty"`
+	OriginalURL string     `json:"original_url"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// LinkUpdated is the v1 payload for link.updated
+type LinkUpdated struct {
+	ShortCode   string    `json:"short_code"`
+	Domain      string    `json:"domain,omitempty"`
+	OriginalURL string    `json:"original_url"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// LinkDeleted is the v1 payload for link.deleted
+type LinkDeleted struct {
+	ShortCode string    `json:"short_code"`
+	Domain    string    `json:"domain,omitempty"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// LinkClicked is the v1 payload for link.clicked
+type LinkClicked struct {
+	ShortCode string    `json:"short_code"`
+	Domain    string    `json:"domain,omitempty"`
+	ClickedAt time.Time `json:"clicked_at"`
+	Referrer  string    `json:"referrer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Country   string    `json:"country,omitempty"`
+	City      string    `json:"city,omitempty"`
+	Bot       bool      `json:"bot,omitempty"`
+	Variant   string    `json:"variant,omitempty"`
+}
+
+// RateLimitWarning is the v1 payload for rate_limit.warning, sent when an API
+// key crosses the warning threshold of its rate limit window
+type RateLimitWarning struct {
+	AccountID string    `json:"account_id"`
+	KeyPrefix string    `json:"key_prefix"`
+	Limit     int64     `json:"limit"`
+	Used      int64     `json:"used"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+// HoneytokenTriggered is the v1 payload for honeytoken.triggered, sent the
+// moment anyone opens a honeytoken link. Credential headers are redacted
+type HoneytokenTriggered struct {
+	Priority    string            `json:"priority"`
+	ShortCode   string            `json:"short_code"`
+	Domain      string            `json:"domain,omitempty"`
+	Label       string            `json:"label,omitempty"`
+	OwnerID     string            `json:"owner_id,omitempty"`
+	TriggeredAt time.Time         `json:"triggered_at"`
+	IP          string            `json:"ip"`
+	Country     string            `json:"country,omitempty"`
+	UserAgent   string            `json:"user_agent,omitempty"`
+	Referrer    string            `json:"referrer,omitempty"`
+	URL         string            `json:"url"`
+	Headers     map[string]string `json:"headers"`
+}
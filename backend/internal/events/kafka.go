@@ -0,0 +1,81 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// KafkaPublisher produces events to a Kafka topic through a Kafka REST proxy
+// speaking the v2 produce API (Confluent REST Proxy, Redpanda HTTP Proxy)
+// The message key picks the partition, so a link's events stay in order
+type KafkaPublisher struct {
+	httpClient *http.Client
+	endpoint   string
+}
+
+func NewKafkaPublisher(proxyURL, topic string) *KafkaPublisher {
+	return &KafkaPublisher{
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		endpoint: strings.TrimRight(proxyURL, "/") + "/topics/" + topic,
+	}
+}
+
+type kafkaRecord struct {
+	Key   string    `json:"key"`
+	Value *Envelope `json:"value"`
+}
+
+type kafkaProduceResponse struct {
+	Offsets []struct {
+		ErrorCode *int   `json:"error_code"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+}
+
+// Publish produces the batch in one request; it fails if any record was rejected
+func (p *KafkaPublisher) Publish(ctx context.Context, messages []Message) error {
+	records := make([]kafkaRecord, len(messages))
+	for i, message := range messages {
+		records[i] = kafkaRecord{Key: message.Key, Value: message.Envelope}
+	}
+	body, err := json.Marshal(map[string]interface{}{"records": records})
+	if err != nil {
+		return fmt.Errorf("failed to marshal records: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to produce to Kafka: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to produce to Kafka: %s", resp.Status)
+	}
+	var produced kafkaProduceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&produced); err != nil {
+		return fmt.Errorf("failed to decode produce response: %w", err)
+	}
+	for _, offset := range produced.Offsets {
+		if offset.ErrorCode != nil {
+			return fmt.Errorf("failed to produce to Kafka: %s (code %d)", offset.Error, *offset.ErrorCode)
+		}
+	}
+	return nil
+}
+
+// Close is a no-op; requests don't hold a connection open
+func (p *KafkaPublisher) Close() error {
+	return nil
+}
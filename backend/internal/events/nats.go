@@ -0,0 +1,47 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSPublisher publishes each event to the subject <prefix>.<type>, e.g.
+// url_shortener.link.clicked, so consumers can subscribe to the types they need
+type NATSPublisher struct {
+	conn   *nats.Conn
+	prefix string
+}
+
+func NewNATSPublisher(url, prefix string) (*NATSPublisher, error) {
+	conn, err := nats.Connect(url, nats.Name("url-shortener"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	return &NATSPublisher{conn: conn, prefix: prefix}, nil
+}
+
+// Publish sends the batch and waits until the server has received it
+// The message key travels in the Event-Key header
+func (p *NATSPublisher) Publish(ctx context.Context, messages []Message) error {
+	for _, message := range messages {
+		data, err := json.Marshal(message.Envelope)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+		msg := nats.NewMsg(p.prefix + "." + string(message.Envelope.Type))
+		msg.Header.Set("Event-Key", message.Key)
+		msg.Data = data
+		if err := p.conn.PublishMsg(msg); err != nil {
+			return fmt.Errorf("failed to publish to NATS: %w", err)
+		}
+	}
+	return p.conn.FlushWithContext(ctx)
+}
+
+// Close flushes pending messages and closes the connection
+func (p *NATSPublisher) Close() error {
+	return p.conn.Drain()
+}
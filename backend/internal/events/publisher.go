@@ -0,0 +1,25 @@
+package events
+
+import "context"
+
+// Message is an envelope bound for an event stream. Messages with the same
+// Key keep their order, so every event of one link is consumed in sequence
+type Message struct {
+	Key      string
+	Envelope *Envelope
+}
+
+// Publisher streams events to an external system such as Kafka or NATS
+type Publisher interface {
+	// Publish sends a batch of messages, in order
+	Publish(ctx context.Context, messages []Message) error
+	// Close releases the connection once no more messages will be published
+	Close() error
+}
+
+// NopPublisher discards every message; it is used when no stream is configured
+type NopPublisher struct{}
+
+func (NopPublisher) Publish(context.Context, []Message) error { return nil }
+
+func (NopPublisher) Close() error { return nil }
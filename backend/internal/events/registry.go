@@ -0,0 +1,182 @@
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownEventType    = errors.New("unknown event type")
+	ErrUnsupportedVersion  = errors.New("unsupported schema version")
+	ErrMissingConverter    = errors.New("no converter registered between schema versions")
+	ErrNonAdjacentVersions = errors.New("converters must link adjacent schema versions")
+)
+
+// Converter rewrites a decoded payload from one schema version to an adjacent one
+type Converter func(data map[string]interface{}) (map[string]interface{}, error)
+
+type schema struct {
+	current    int
+	upgrades   map[int]Converter // v -> v+1
+	downgrades map[int]Converter // v -> v-1
+}
+
+// Registry keeps the current schema version of each event type together with
+// the converters needed to serve consumers pinned to older (or newer) versions
+type Registry struct {
+	mu      sync.RWMutex
+	schemas map[Type]*schema
+}
+
+// NewRegistry creates an empty schema registry
+func NewRegistry() *Registry {
+	return &Registry{
+		schemas: make(map[Type]*schema),
+	}
+}
+
+// DefaultRegistry returns a registry with every event type this service emits
+// Bump the version and register converters here when a payload shape changes
+func DefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.Register(TypeLinkCreated, 1)
+	r.Register(TypeLinkUpdated, 1)
+	r.Register(TypeLinkDeleted, 1)
+	r.Register(TypeLinkClicked, 1)
+	r.Register(TypeRateLimitWarning, 1)
+	r.Register(TypeHoneytokenTriggered, 1)
+	return r
+}
+
+// Register declares the current schema version for an event type
+func (r *Registry) Register(t Type, currentVersion int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.schemas[t]
+	if !ok {
+		s = &schema{
+			upgrades:   make(map[int]Converter),
+			downgrades: make(map[int]Converter),
+		}
+		r.schemas[t] = s
+	}
+	s.current = currentVersion
+}
+
+// RegisterConverter adds a converter between two adjacent versions of an event type
+func (r *Registry) RegisterConverter(t Type, from, to int, fn Converter) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.schemas[t]
+	if !ok {
+		return ErrUnknownEventType
+	}
+	switch to - from {
+	case 1:
+		s.upgrades[from] = fn
+	case -1:
+		s.downgrades[from] = fn
+	default:
+		return ErrNonAdjacentVersions
+	}
+	return nil
+}
+
+// CurrentVersion returns the version new events of this type are emitted with
+func (r *Registry) CurrentVersion(t Type) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.schemas[t]
+	if !ok {
+		return 0, ErrUnknownEventType
+	}
+	return s.current, nil
+}
+
+// New builds an envelope for payload stamped with the current schema version
+func (r *Registry) New(t Type, payload interface{}) (*Envelope, error) {
+	version, err := r.CurrentVersion(t)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event payload: %w", err)
+	}
+	return &Envelope{
+		ID:            newEventID(),
+		Type:          t,
+		SchemaVersion: version,
+		OccurredAt:    time.Now().UTC(),
+		Data:          data,
+	}, nil
+}
+
+// Convert returns a copy of env whose payload matches the target schema version
+// A target of 0 means "no preference" and returns the envelope unchanged
+func (r *Registry) Convert(env *Envelope, target int) (*Envelope, error) {
+	if target == 0 || target == env.SchemaVersion {
+		return env, nil
+	}
+
+	r.mu.RLock()
+	s, ok := r.schemas[env.Type]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownEventType
+	}
+	if target < 1 || target > s.current {
+		return nil, ErrUnsupportedVersion
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(env.Data, &data); err != nil {
+		return nil, fmt.Errorf("failed to decode event payload: %w", err)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	version := env.SchemaVersion
+	for version != target {
+		var (
+			fn   Converter
+			next int
+		)
+		if version < target {
+			fn, next = s.upgrades[version], version+1
+		} else {
+			fn, next = s.downgrades[version], version-1
+		}
+		if fn == nil {
+			return nil, fmt.Errorf("%w: %s v%d -> v%d", ErrMissingConverter, env.Type, version, next)
+		}
+		converted, err := fn(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s v%d -> v%d: %w", env.Type, version, next, err)
+		}
+		data, version = converted, next
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal converted payload: %w", err)
+	}
+	out := *env
+	out.SchemaVersion = target
+	out.Data = raw
+	return &out, nil
+}
+
+func newEventID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
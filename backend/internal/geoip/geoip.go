@@ -0,0 +1,140 @@
+// Package geoip locates visitors by IP address with a MaxMind DB file, such
+// as GeoLite2 City or DB-IP City Lite. The file is reread when it changes,
+// so a database update needs no restart
+package geoip
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Location is where an address is, as far as the database knows
+type Location struct {
+	// Country is the ISO 3166-1 alpha-2 code
+	Country string
+	// City is the English name
+	City string
+}
+
+// Database is a GeoIP database that follows changes to its file
+type Database struct {
+	path     string
+	interval time.Duration
+	current  atomic.Pointer[maxminddb.Reader]
+	// modTime and size tell whether the file changed since it was loaded
+	modTime time.Time
+	size    int64
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// Open loads the database at path, which Start checks for changes every interval
+func Open(path string, interval time.Duration) (*Database, error) {
+	db := &Database{
+		path:     path,
+		interval: interval,
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	if _, err := db.reload(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// Start rereads the file whenever it changes until Stop is called. A file
+// that fails to load, such as one still being written, leaves the previous
+// database in use and is tried again
+func (db *Database) Start() {
+	go func() {
+		defer close(db.stopped)
+		ticker := time.NewTicker(db.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				reloaded, err := db.reload()
+				if err != nil {
+					log.Printf("Failed to reload GeoIP database: %v", err)
+				} else if reloaded {
+					log.Printf("Reloaded GeoIP database %s", db.path)
+				}
+			case <-db.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the change checks
+func (db *Database) Stop() {
+	close(db.stop)
+	<-db.stopped
+}
+
+// reload loads the file if it changed since the last load
+func (db *Database) reload() (bool, error) {
+	info, err := os.Stat(db.path)
+	if err != nil {
+		return false, err
+	}
+	if info.ModTime().Equal(db.modTime) && info.Size() == db.size {
+		return false, nil
+	}
+	buf, err := os.ReadFile(db.path)
+	if err != nil {
+		return false, err
+	}
+	r, err := maxminddb.FromBytes(buf)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", db.path, err)
+	}
+	db.current.Store(r)
+	db.modTime, db.size = info.ModTime(), info.Size()
+	return true, nil
+}
+
+// record holds the fields of a City database entry that are used
+type record struct {
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+// Lookup locates ip. Unknown and invalid addresses, and nil databases, give
+// an empty Location
+func (db *Database) Lookup(ip string) Location {
+	if db == nil {
+		return Location{}
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return Location{}
+	}
+	var found record
+	if err := db.current.Load().Lookup(addr, &found); err != nil {
+		return Location{}
+	}
+	location := Location{City: found.City.Names["en"], Country: found.Country.ISOCode}
+	// Addresses without a physical country, such as anycast ones, may
+	// still have the country they are registered in
+	if location.Country == "" {
+		location.Country = found.RegisteredCountry.ISOCode
+	}
+	location.Country = strings.ToUpper(location.Country)
+	return location
+}
@@ -0,0 +1,6 @@
+// Package graph is the executable schema of the GraphQL API, generated by
+// gqlgen from schema.graphql. The resolvers live in the handlers package
+// Run go generate after changing the schema or gqlgen.yml
+package graph
+
+//go:generate go tool gqlgen generate --config gqlgen.yml
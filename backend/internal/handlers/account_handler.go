@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/middleware"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AccountHandler struct {
+	quotaService  *services.QuotaService
+	apiKeyService *services.APIKeyService
+	notifications *services.NotificationService
+}
+
+func NewAccountHandler(quotaService *services.QuotaService, apiKeyService *services.APIKeyService, notifications *services.NotificationService) *AccountHandler {
+	return &AccountHandler{
+		quotaService:  quotaService,
+		apiKeyService: apiKeyService,
+		notifications: notifications,
+	}
+}
+
+type RotateAPIKeyRequest struct {
+	GracePeriod string `json:"grace_period,omitempty"`
+}
+
+// Validate checks the optional grace period
+func (r *RotateAPIKeyRequest) Validate() error {
+	var errs validators.Errors
+	errs.CheckDuration("grace_period", r.GracePeriod)
+	return errs.Err()
+}
+
+type NotificationPreferencesRequest struct {
+	Email             string  `json:"email"`
+	ExpiryWarningDays int     `json:"expiry_warning_days"`
+	ClickMilestones   []int64 `json:"click_milestones"`
+}
+
+// Validate checks the address and the bounds of each preference
+func (r *NotificationPreferencesRequest) Validate() error {
+	var errs validators.Errors
+	if r.Email != "" {
+		if addr, err := mail.ParseAddress(r.Email); err != nil || addr.Address != r.Email {
+			errs.Add(validators.CodeInvalid, "email", "email must be a plain email address")
+		}
+	} else if r.ExpiryWarningDays > 0 || len(r.ClickMilestones) > 0 {
+		errs.Add(validators.CodeRequired, "email", "email is required to receive notifications")
+	}
+	if r.ExpiryWarningDays < 0 || r.ExpiryWarningDays > models.MaxExpiryWarningDays {
+		errs.Add(validators.CodeOutOfRange, "expiry_warning_days", fmt.Sprintf("expiry_warning_days must be between 0 and %d", models.MaxExpiryWarningDays))
+	}
+	if len(r.ClickMilestones) > models.MaxClickMilestones {
+		errs.Add(validators.CodeOutOfRange, "click_milestones", fmt.Sprintf("at most %d click_milestones are allowed", models.MaxClickMilestones))
+	} else {
+		for _, milestone := range r.ClickMilestones {
+			if milestone <= 0 {
+				errs.Add(validators.CodeOutOfRange, "click_milestones", "click_milestones must be positive")
+				break
+			}
+		}
+	}
+	return errs.Err()
+}
+
+// GetUsage handles GET /api/v1/account/usage
+func (h *AccountHandler) GetUsage(c *gin.Context) {
+	usage, err := h.quotaService.Usage(c.Request.Context(), middleware.AccountID(c))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to retrieve usage")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, usage)
+}
+
+// ListKeys handles GET /api/v1/account/keys
+// Each key reports its version, usage count and last use so owners can confirm
+// traffic has moved to a rotated key before revoking the old one
+func (h *AccountHandler) ListKeys(c *gin.Context) {
+	pageReq, ok := parsePageRequest(c, repository.CreatedListSpec)
+	if !ok {
+		return
+	}
+	page, err := h.apiKeyService.ListKeys(c.Request.Context(), middleware.AccountID(c), pageReq)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to list API keys")
+		return
+	}
+	respondWithPage(c, page)
+}
+
+// RotateKey handles POST /api/v1/account/keys/:id/rotate
+func (h *AccountHandler) RotateKey(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		utils.RespondWithErrorCode(c, http.StatusNotFound, utils.ErrCodeAPIKeyNotFound, "API key not found")
+		return
+	}
+	var req RotateAPIKeyRequest
+	if c.Request.ContentLength > 0 && !bindJSON(c, &req) {
+		return
+	}
+	// Validate has already checked the format
+	grace, _ := time.ParseDuration(req.GracePeriod)
+
+	plaintext, key, err := h.apiKeyService.RotateKey(c.Request.Context(), middleware.AccountID(c), id, grace)
+	if err != nil {
+		respondError(c, err, "Failed to rotate API key")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusCreated, CreateAPIKeyResponse{
+		Key:    plaintext,
+		APIKey: key,
+	})
+}
+
+// RevokeKey handles POST /api/v1/account/keys/:id/revoke
+func (h *AccountHandler) RevokeKey(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		utils.RespondWithErrorCode(c, http.StatusNotFound, utils.ErrCodeAPIKeyNotFound, "API key not found")
+		return
+	}
+	if err := h.apiKeyService.RevokeKey(c.Request.Context(), middleware.AccountID(c), id); err != nil {
+		respondError(c, err, "Failed to revoke API key")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetNotifications handles GET /api/v1/account/notifications
+func (h *AccountHandler) GetNotifications(c *gin.Context) {
+	prefs, err := h.notifications.GetPreferences(c.Request.Context(), middleware.AccountID(c))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to retrieve notification preferences")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, prefs)
+}
+
+// UpdateNotifications handles PUT /api/v1/account/notifications
+// The body replaces every preference; fields left out turn those emails off
+func (h *AccountHandler) UpdateNotifications(c *gin.Context) {
+	var req NotificationPreferencesRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	prefs, err := h.notifications.SetPreferences(c.Request.Context(), &models.NotificationPreferences{
+		AccountID:         middleware.AccountID(c),
+		Email:             req.Email,
+		ExpiryWarningDays: req.ExpiryWarningDays,
+		ClickMilestones:   req.ClickMilestones,
+	})
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update notification preferences")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, prefs)
+}
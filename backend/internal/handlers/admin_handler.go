@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AdminHandler struct {
+	apiKeyService    *services.APIKeyService
+	quotaService     *services.QuotaService
+	shadowBanService *services.ShadowBanService
+	destinations     *services.DestinationRules
+	exportService    *services.ExportService
+	linkMirror       *services.LinkMirror
+	instances        *services.InstanceRegistry
+	rollouts         *services.Rollouts
+	apiVersions      *services.APIVersionMetrics
+	overview         *services.OverviewService
+}
+
+func NewAdminHandler(apiKeyService *services.APIKeyService, quotaService *services.QuotaService, shadowBanService *services.ShadowBanService, destinations *services.DestinationRules, exportService *services.ExportService, linkMirror *services.LinkMirror, instances *services.InstanceRegistry, rollouts *services.Rollouts, apiVersions *services.APIVersionMetrics, overview *services.OverviewService) *AdminHandler {
+	return &AdminHandler{
+		apiKeyService:    apiKeyService,
+		quotaService:     quotaService,
+		shadowBanService: shadowBanService,
+		destinations:     destinations,
+		exportService:    exportService,
+		linkMirror:       linkMirror,
+		instances:        instances,
+		rollouts:         rollouts,
+		apiVersions:      apiVersions,
+		overview:         overview,
+	}
+}
+
+type CreateAPIKeyRequest struct {
+	AccountID string            `json:"account_id" binding:"required"`
+	Name      string            `json:"name"`
+	Scopes    []string          `json:"scopes,omitempty"`
+	Plan      string            `json:"plan,omitempty"`
+	LinkQuota *models.LinkQuota `json:"link_quota,omitempty"`
+	// AllowedOrigins restricts the browser origins the key may be used from
+	AllowedOrigins []string `json:"allowed_origins,omitempty"`
+	// Sandbox keys create test links that expire in a day and count
+	// towards nothing
+	Sandbox bool `json:"sandbox,omitempty"`
+}
+
+// Validate checks the origin patterns
+func (r *CreateAPIKeyRequest) Validate() error {
+	var errs validators.Errors
+	errs.CheckOrigins("allowed_origins", r.AllowedOrigins)
+	return errs.Err()
+}
+
+// APIKeyQuotaRequest assigns a key's quota plan and per-key override
+type APIKeyQuotaRequest struct {
+	Plan      string            `json:"plan,omitempty"`
+	LinkQuota *models.LinkQuota `json:"link_quota,omitempty"`
+}
+
+// checkQuota checks the plan exists and the override's limits aren't
+// negative, writing the error response if not
+func checkQuota(c *gin.Context, quotaService *services.QuotaService, plan string, quota *models.LinkQuota) bool {
+	if !quotaService.ValidPlan(plan) {
+		respondInvalidField(c, validators.CodeInvalid, "plan", "unknown quota plan")
+		return false
+	}
+	if quota != nil && (quota.Daily < 0 || quota.Monthly < 0) {
+		respondInvalidField(c, validators.CodeOutOfRange, "link_quota", "link_quota limits must not be negative")
+		return false
+	}
+	return true
+}
+
+// APIKeyOriginsRequest replaces the browser origins a key may be used from
+type APIKeyOriginsRequest struct {
+	AllowedOrigins []string `json:"allowed_origins"`
+}
+
+// Validate checks the origin patterns
+func (r *APIKeyOriginsRequest) Validate() error {
+	var errs validators.Errors
+	errs.CheckOrigins("allowed_origins", r.AllowedOrigins)
+	return errs.Err()
+}
+
+type CreateAPIKeyResponse struct {
+	Key    string         `json:"key"`
+	APIKey *models.APIKey `json:"api_key"`
+}
+
+// CreateAPIKey handles POST /admin/api-keys
+// The plaintext key is only ever returned in this response
+func (h *AdminHandler) CreateAPIKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if !checkQuota(c, h.quotaService, req.Plan, req.LinkQuota) {
+		return
+	}
+	plaintext, key, err := h.apiKeyService.CreateKey(c.Request.Context(), req.AccountID, req.Name, req.Scopes, req.Sandbox)
+	if err != nil {
+		respondError(c, err, "Failed to create API key")
+		return
+	}
+	if req.Plan != "" || req.LinkQuota != nil {
+		if key, err = h.apiKeyService.SetQuota(c.Request.Context(), key.ID, req.Plan, req.LinkQuota); err != nil {
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to set API key quota")
+			return
+		}
+	}
+	if len(req.AllowedOrigins) > 0 {
+		if key, err = h.apiKeyService.SetAllowedOrigins(c.Request.Context(), key.ID, req.AllowedOrigins); err != nil {
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to set API key origins")
+			return
+		}
+	}
+	utils.RespondWithJSON(c, http.StatusCreated, CreateAPIKeyResponse{
+		Key:    plaintext,
+		APIKey: key,
+	})
+}
+
+// SetAPIKeyQuota handles PUT /admin/api-keys/:id/quota
+// Omitting plan moves the key to the default plan, omitting link_quota drops its override
+func (h *AdminHandler) SetAPIKeyQuota(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		utils.RespondWithErrorCode(c, http.StatusNotFound, utils.ErrCodeAPIKeyNotFound, "API key not found")
+		return
+	}
+	var req APIKeyQuotaRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if !checkQuota(c, h.quotaService, req.Plan, req.LinkQuota) {
+		return
+	}
+	key, err := h.apiKeyService.SetQuota(c.Request.Context(), id, req.Plan, req.LinkQuota)
+	if err != nil {
+		respondError(c, err, "Failed to set API key quota")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, key)
+}
+
+// SetAPIKeyOrigins handles PUT /admin/api-keys/:id/origins
+// An empty list lets the key be used from any origin again
+func (h *AdminHandler) SetAPIKeyOrigins(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		utils.RespondWithErrorCode(c, http.StatusNotFound, utils.ErrCodeAPIKeyNotFound, "API key not found")
+		return
+	}
+	var req APIKeyOriginsRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	key, err := h.apiKeyService.SetAllowedOrigins(c.Request.Context(), id, req.AllowedOrigins)
+	if err != nil {
+		respondError(c, err, "Failed to set API key origins")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, key)
+}
+
+type ShadowBanRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ShadowBanAccount handles PUT /admin/accounts/:id/shadow-ban
+// From now on the account's new and edited links only resolve for itself
+func (h *AdminHandler) ShadowBanAccount(c *gin.Context) {
+	var req ShadowBanRequest
+	if c.Request.ContentLength != 0 && !bindJSON(c, &req) {
+		return
+	}
+	ban, err := h.shadowBanService.Ban(c.Request.Context(), c.Param("id"), req.Reason)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to shadow-ban account")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, ban)
+}
+
+// LiftShadowBan handles DELETE /admin/accounts/:id/shadow-ban
+// The account's shadowed links start resolving for everyone
+func (h *AdminHandler) LiftShadowBan(c *gin.Context) {
+	if err := h.shadowBanService.Unban(c.Request.Context(), c.Param("id")); err != nil {
+		respondError(c, err, "Failed to lift shadow ban")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListShadowBans handles GET /admin/shadow-bans
+func (h *AdminHandler) ListShadowBans(c *gin.Context) {
+	bans, err := h.shadowBanService.List(c.Request.Context())
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to list shadow bans")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, bans)
+}
+
+type DestinationRuleRequest struct {
+	List   string `json:"list" binding:"required"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// PutDestinationRule handles PUT /admin/destination-rules/:pattern
+// Putting a pattern that has a rule moves it to the requested list
+func (h *AdminHandler) PutDestinationRule(c *gin.Context) {
+	var req DestinationRuleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	rule, err := h.destinations.Put(c.Request.Context(), c.Param("pattern"), req.List, req.Reason)
+	if err != nil {
+		respondError(c, err, "Failed to save destination rule")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, rule)
+}
+
+// DeleteDestinationRule handles DELETE /admin/destination-rules/:pattern
+func (h *AdminHandler) DeleteDestinationRule(c *gin.Context) {
+	if err := h.destinations.Delete(c.Request.Context(), c.Param("pattern")); err != nil {
+		respondError(c, err, "Failed to delete destination rule")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListDestinationRules handles GET /admin/destination-rules
+// Lists the configured rules, which can't be changed here, then the managed ones
+func (h *AdminHandler) ListDestinationRules(c *gin.Context) {
+	rules, err := h.destinations.List(c.Request.Context())
+	if err != nil {
+		respondError(c, err, "Failed to list destination rules")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, rules)
+}
+
+type InstancesResponse struct {
+	Self      string            `json:"self"`
+	Instances []models.Instance `json:"instances"`
+}
+
+// ListInstances handles GET /admin/instances
+// Lists the replicas with a recent heartbeat; self is the one answering
+func (h *AdminHandler) ListInstances(c *gin.Context) {
+	instances, err := h.instances.Instances(c.Request.Context())
+	if err != nil {
+		utils.RespondWithError(c, http.StatusServiceUnavailable, "Failed to list instances")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, InstancesResponse{Self: h.instances.ID(), Instances: instances})
+}
+
+// ListRollouts handles GET /admin/rollouts
+// Compares redirect error rates and latency with each flag on and off;
+// counts are flushed from every replica periodically, so they lag slightly
+func (h *AdminHandler) ListRollouts(c *gin.Context) {
+	reports, err := h.rollouts.Reports(c.Request.Context())
+	if err != nil {
+		utils.RespondWithError(c, http.StatusServiceUnavailable, "Failed to read rollout metrics")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"rollouts": reports})
+}
+
+// Overview handles GET /admin/overview
+// Summarizes links, redirects, storage and the link cache; the numbers are
+// computed at most once a minute
+func (h *AdminHandler) Overview(c *gin.Context) {
+	overview, err := h.overview.Overview(c.Request.Context())
+	if err != nil {
+		utils.RespondWithError(c, http.StatusServiceUnavailable, "Failed to build overview")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, overview)
+}
+
+// ListAPIVersions handles GET /admin/api-versions
+// Reports each API version's traffic and how many accounts call it
+func (h *AdminHandler) ListAPIVersions(c *gin.Context) {
+	reports, err := h.apiVersions.Reports(c.Request.Context())
+	if err != nil {
+		utils.RespondWithError(c, http.StatusServiceUnavailable, "Failed to read API version metrics")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"versions": reports})
+}
+
+// ListAPIVersionAccounts handles GET /admin/api-versions/:version/accounts
+// Lists the accounts still calling the version, most recent first
+func (h *AdminHandler) ListAPIVersionAccounts(c *gin.Context) {
+	version := c.Param("version")
+	if !slices.Contains(services.APIVersions, version) {
+		utils.RespondWithErrorCode(c, http.StatusNotFound, utils.ErrCodeUnknownAPIVersion, "Unknown API version")
+		return
+	}
+	accounts, err := h.apiVersions.Accounts(c.Request.Context(), version)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusServiceUnavailable, "Failed to read API version accounts")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"accounts": accounts})
+}
+
+type CreateExportRequest struct {
+	From   *time.Time `json:"from,omitempty"`
+	To     *time.Time `json:"to,omitempty"`
+	Format string     `json:"format,omitempty" binding:"omitempty,oneof=csv csv.gz parquet"`
+}
+
+// CreateExport handles POST /admin/exports
+// Without from/to it exports the most recently completed interval
+func (h *AdminHandler) CreateExport(c *gin.Context) {
+	var req CreateExportRequest
+	if c.Request.ContentLength != 0 && !bindJSON(c, &req) {
+		return
+	}
+	from, to := h.exportService.LastWindow()
+	if req.From != nil {
+		from = *req.From
+	}
+	if req.To != nil {
+		to = *req.To
+	}
+	result, err := h.exportService.Export(c.Request.Context(), from, to, req.Format)
+	if err != nil {
+		respondError(c, err, "Failed to export click analytics")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusCreated, result)
+}
+
+// ReconcileLinkMirror handles POST /admin/link-mirror/reconcile
+// Runs a reconciliation of the Redis link mirror now and returns what it repaired
+func (h *AdminHandler) ReconcileLinkMirror(c *gin.Context) {
+	report, err := h.linkMirror.Reconcile(c.Request.Context())
+	if err != nil {
+		respondError(c, err, "Failed to reconcile link mirror")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, report)
+}
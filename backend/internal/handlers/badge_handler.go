@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+)
+
+const (
+	defaultBadgeLabel = "clicks"
+	defaultBadgeColor = "blue"
+	maxBadgeLabel     = 40
+	// minEmbedRefresh keeps embedded counters from reloading more than once a minute
+	minEmbedRefresh = time.Minute
+)
+
+// badgeColors are the named colors badges accept, as on shields.io
+var badgeColors = map[string]string{
+	"brightgreen": "#4c1",
+	"green":       "#97ca00",
+	"yellowgreen": "#a4a61d",
+	"yellow":      "#dfb317",
+	"orange":      "#fe7d37",
+	"red":         "#e05d44",
+	"blue":        "#007ec6",
+	"lightgrey":   "#9f9f9f",
+	"grey":        "#555",
+}
+
+var hexColor = regexp.MustCompile(`^([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+var embedPage = template.Must(template.New("embed").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<meta http-equiv="refresh" content="{{.Refresh}}">
+<title>{{.Name}}</title>
+<style>
+body{margin:0;font-family:-apple-system,BlinkMacSystemFont,"Segoe UI",Roboto,sans-serif;color:#24292f;background:transparent}
+.counter{display:inline-block;padding:12px 16px;border:1px solid #d0d7de;border-radius:6px;background:#fff}
+.clicks{font-size:28px;font-weight:600;line-height:1.2}
+.label{font-size:13px;color:#57606a}
+.name{font-size:13px;margin-top:6px;max-width:260px;overflow:hidden;text-overflow:ellipsis;white-space:nowrap}
+</style>
+</head>
+<body>
+<div class="counter">
+<div class="clicks">{{.Clicks}}</div>
+<div class="label">{{.Label}}</div>
+<div class="name" title="{{.Short}}">{{.Name}}</div>
+</div>
+</body>
+</html>
+`))
+
+// embedPageData is what the embedded counter is rendered with
+type embedPageData struct {
+	Clicks  string
+	Label   string
+	Name    string
+	Short   string
+	Refresh int
+}
+
+// BadgeHandler serves the public click counters links can be embedded with:
+// an SVG badge for READMEs and a small HTML page for iframes. They are
+// served on the short domain, next to the redirect
+type BadgeHandler struct {
+	urlService    *services.URLService
+	domainService *services.DomainService
+	reserved      *validators.ReservedWords
+	links         *Links
+	maxAge        time.Duration
+}
+
+func NewBadgeHandler(urlService *services.URLService, domainService *services.DomainService, reserved *validators.ReservedWords, links *Links, maxAge time.Duration) *BadgeHandler {
+	return &BadgeHandler{
+		urlService:    urlService,
+		domainService: domainService,
+		reserved:      reserved,
+		links:         links,
+		maxAge:        maxAge,
+	}
+}
+
+// Badge handles GET /:code/badge.svg
+// ?label= replaces "clicks", ?color= takes a shields.io color name or a hex
+// code and ?style=flat-square drops the rounded corners
+func (h *BadgeHandler) Badge(c *gin.Context) {
+	label := c.DefaultQuery("label", defaultBadgeLabel)
+	if label == "" || utf8.RuneCountInString(label) > maxBadgeLabel {
+		respondInvalidField(c, validators.CodeOutOfRange, "label", fmt.Sprintf("label must be 1 to %d characters", maxBadgeLabel))
+		return
+	}
+	color, ok := badgeColor(c.DefaultQuery("color", defaultBadgeColor))
+	if !ok {
+		respondInvalidField(c, validators.CodeInvalid, "color", "color must be a color name or a hex code")
+		return
+	}
+	style := c.DefaultQuery("style", "flat")
+	if style != "flat" && style != "flat-square" {
+		respondInvalidField(c, validators.CodeInvalid, "style", "style must be flat or flat-square")
+		return
+	}
+	counter, ok := h.counter(c)
+	if !ok {
+		return
+	}
+	h.setCaching(c)
+	c.Data(http.StatusOK, "image/svg+xml; charset=utf-8", renderBadge(label, compactCount(counter.Clicks), color, style == "flat"))
+}
+
+// Embed handles GET /:code/stats/embed
+// The page reloads itself as often as it may be cached, so an embedded
+// counter stays current
+func (h *BadgeHandler) Embed(c *gin.Context) {
+	counter, ok := h.counter(c)
+	if !ok {
+		return
+	}
+	short := h.links.Short(counter.Domain, counter.ShortCode)
+	name := counter.Title
+	if name == "" {
+		name = short
+	}
+	label := "clicks"
+	if counter.Clicks == 1 {
+		label = "click"
+	}
+	refresh := h.maxAge
+	if refresh < minEmbedRefresh {
+		refresh = minEmbedRefresh
+	}
+	var body bytes.Buffer
+	err := embedPage.Execute(&body, embedPageData{
+		Clicks:  groupedCount(counter.Clicks),
+		Label:   label,
+		Name:    name,
+		Short:   short,
+		Refresh: int(refresh.Seconds()),
+	})
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to render counter")
+		return
+	}
+	h.setCaching(c)
+	// Meant to be framed by other sites, but nothing in it needs to run
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", body.Bytes())
+}
+
+// counter loads the counter of the link the path names, on the request's
+// domain, responding with the error itself when it can't
+func (h *BadgeHandler) counter(c *gin.Context) (*models.LinkCounter, bool) {
+	shortCode := c.Param("code")
+	if !h.reserved.Allowed(shortCode) {
+		utils.RespondWithErrorCode(c, http.StatusNotFound, utils.ErrCodeURLNotFound, "Short URL not found")
+		return nil, false
+	}
+	domain, err := h.domainService.Resolve(c.Request.Context(), c.Request.Host)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to load counter")
+		return nil, false
+	}
+	host := ""
+	if domain != nil {
+		host = domain.Host
+	}
+	counter, err := h.urlService.GetCounter(c.Request.Context(), host, shortCode)
+	if err != nil {
+		respondError(c, err, "Failed to load counter")
+		return nil, false
+	}
+	return counter, true
+}
+
+// setCaching lets any cache keep the counter for maxAge. Counters are
+// public, so README image proxies and CDNs may share them
+func (h *BadgeHandler) setCaching(c *gin.Context) {
+	if h.maxAge > 0 {
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(h.maxAge.Seconds())))
+	} else {
+		c.Header("Cache-Control", "no-cache")
+	}
+}
+
+// badgeColor resolves a color name or hex code to the fill of the value
+func badgeColor(color string) (string, bool) {
+	if fill, ok := badgeColors[color]; ok {
+		return fill, true
+	}
+	if hexColor.MatchString(color) {
+		return "#" + color, true
+	}
+	return "", false
+}
+
+// renderBadge draws a two-part badge in the shields.io flat styles
+func renderBadge(label, value, fill string, rounded bool) []byte {
+	labelWidth := textWidth(label) + 10
+	valueWidth := textWidth(value) + 10
+	width := labelWidth + valueWidth
+	label, value = html.EscapeString(label), html.EscapeString(value)
+
+	var svg bytes.Buffer
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s">`, width, label, value)
+	fmt.Fprintf(&svg, `<title>%s: %s</title>`, label, value)
+	radius := 0
+	if rounded {
+		radius = 3
+		svg.WriteString(`<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`)
+	}
+	fmt.Fprintf(&svg, `<clipPath id="r"><rect width="%d" height="20" rx="%d" fill="#fff"/></clipPath>`, width, radius)
+	fmt.Fprintf(&svg, `<g clip-path="url(#r)"><rect width="%d" height="20" fill="#555"/><rect x="%d" width="%d" height="20" fill="%s"/>`, labelWidth, labelWidth, valueWidth, fill)
+	if rounded {
+		fmt.Fprintf(&svg, `<rect width="%d" height="20" fill="url(#s)"/>`, width)
+	}
+	svg.WriteString(`</g><g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`)
+	for _, part := range []struct {
+		text string
+		x    float64
+	}{{label, float64(labelWidth) / 2}, {value, float64(labelWidth) + float64(valueWidth)/2}} {
+		fmt.Fprintf(&svg, `<text x="%.1f" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%.1f" y="14">%s</text>`, part.x, part.text, part.x, part.text)
+	}
+	svg.WriteString(`</g></svg>`)
+	return svg.Bytes()
+}
+
+// textWidth estimates the width in pixels of text in 11px Verdana
+func textWidth(text string) int {
+	width := 0.0
+	for _, r := range text {
+		switch {
+		case r == 'i' || r == 'j' || r == 'l' || r == '.' || r == ',' || r == ':' || r == ';' || r == '!' || r == '|' || r == '\'':
+			width += 3.5
+		case r == ' ' || r == 'f' || r == 't' || r == 'r' || r == 'I':
+			width += 4.5
+		case r == 'm' || r == 'w' || r == 'M' || r == 'W':
+			width += 10
+		case r >= 'A' && r <= 'Z':
+			width += 7.5
+		default:
+			width += 7
+		}
+	}
+	return int(width + 0.5)
+}
+
+// compactCount shortens a count for a badge: 950, 1.2k, 3.4M
+func compactCount(n int64) string {
+	if n < 1000 {
+		return strconv.FormatInt(n, 10)
+	}
+	value, suffix := float64(n), ""
+	for _, next := range []string{"k", "M", "B"} {
+		// Compared rounded, so 999,950 is 1M rather than 1000k
+		if math.Round(value*10)/10 < 1000 {
+			break
+		}
+		value, suffix = value/1000, next
+	}
+	return strings.TrimSuffix(strconv.FormatFloat(value, 'f', 1, 64), ".0") + suffix
+}
+
+// groupedCount writes a count in full with thousands separators
+func groupedCount(n int64) string {
+	digits := strconv.FormatInt(n, 10)
+	var out []byte
+	for i := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			out = append(out, ',')
+		}
+		out = append(out, digits[i])
+	}
+	return string(out)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+)
+
+// noScriptParam marks a redirect reached through the challenge's noscript fallback
+const noScriptParam = "_nojs"
+
+var botChallengePage = template.Must(template.New("challenge").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<noscript><meta http-equiv="refresh" content="0;url={{.Fallback}}"></noscript>
+<title>Redirecting…</title>
+</head>
+<body>
+<script>
+document.cookie = {{.Cookie}};
+// Without cookies the challenge can't pass; take the fallback instead of looping
+location.replace(document.cookie.indexOf({{.Name}} + "=") >= 0 ? location.href : {{.Fallback}});
+</script>
+</body>
+</html>
+`))
+
+// renderBotChallenge serves the page whose script sets the challenge cookie
+// and reloads the short link, hinting at destination so the browser can
+// connect to it while the challenge runs
+func renderBotChallenge(c *gin.Context, token, destination string) {
+	query := c.Request.URL.Query()
+	query.Set(noScriptParam, "1")
+	fallback := *c.Request.URL
+	fallback.RawQuery = query.Encode()
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	setPreconnectHints(c, destination)
+	c.Status(http.StatusOK)
+	botChallengePage.Execute(c.Writer, map[string]string{
+		"Name":     services.BotChallengeCookie,
+		"Fallback": fallback.RequestURI(),
+		"Cookie":   services.BotChallengeCookie + "=" + token + "; path=/; max-age=86400; SameSite=Lax",
+	})
+}
+
+// forwardableQuery is the visit's query string without the challenge's own
+// parameter, for links that pass it on to their destination
+func forwardableQuery(c *gin.Context) url.Values {
+	query := c.Request.URL.Query()
+	query.Del(noScriptParam)
+	return query
+}
+
+// setPreconnectHints adds Link headers asking the browser to resolve and
+// connect to destination's origin while it is still on an interstitial page
+// Only the origin is sent, never the path or query
+func setPreconnectHints(c *gin.Context, destination string) {
+	u, err := url.Parse(destination)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return
+	}
+	origin := u.Scheme + "://" + u.Host
+	c.Writer.Header().Add("Link", "<"+origin+">; rel=preconnect")
+	c.Writer.Header().Add("Link", "<"+origin+">; rel=dns-prefetch")
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/middleware"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+)
+
+// CampaignHandler manages the caller's campaigns: bundles of links whose
+// clicks are reported together
+type CampaignHandler struct {
+	campaigns *services.CampaignService
+	links     *Links
+	// statsMaxAge is how long clients may reuse a stats response without revalidating
+	statsMaxAge time.Duration
+}
+
+func NewCampaignHandler(campaigns *services.CampaignService, links *Links, statsMaxAge time.Duration) *CampaignHandler {
+	return &CampaignHandler{
+		campaigns:   campaigns,
+		links:       links,
+		statsMaxAge: statsMaxAge,
+	}
+}
+
+// CreateCampaignRequest creates a campaign, optionally with its first links
+type CreateCampaignRequest struct {
+	Name        string              `json:"name" binding:"required,max=100"`
+	Description string              `json:"description,omitempty" binding:"max=1000"`
+	Links       []ShortenURLRequest `json:"links,omitempty" binding:"dive"`
+}
+
+// Validate runs each link's own checks, reporting fields as links[i].field
+func (r *CreateCampaignRequest) Validate() error {
+	var errs validators.Errors
+	for i := range r.Links {
+		var linkErrs validators.Errors
+		if errors.As(r.Links[i].Validate(), &linkErrs) {
+			for _, fe := range linkErrs {
+				field := fmt.Sprintf("links[%d].%s", i, fe.Field)
+				errs.Add(fe.Code, field, strings.Replace(fe.Message, fe.Field, field, 1))
+			}
+		}
+		if r.Links[i].CampaignID != "" {
+			errs.Add(validators.CodeInvalid, fmt.Sprintf("links[%d].campaign_id", i), "links are added to the new campaign")
+		}
+	}
+	return errs.Err()
+}
+
+// CampaignLinkResult is the outcome of one link of a new campaign, in request order
+type CampaignLinkResult struct {
+	*ShortenResponse
+	Error *CampaignLinkError `json:"error,omitempty"`
+}
+
+// CampaignLinkError is why one link of a batch failed, in the shape of an
+// error response: Errors names the field when the link failed validation
+type CampaignLinkError struct {
+	Status  int                     `json:"status"`
+	Code    string                  `json:"code"`
+	Message string                  `json:"message"`
+	Errors  []validators.FieldError `json:"errors,omitempty"`
+}
+
+type CreateCampaignResponse struct {
+	*models.Campaign
+	Links []CampaignLinkResult `json:"links"`
+}
+
+// CreateCampaign handles POST /api/v1/campaigns
+// Each link is created independently; failed ones are reported in place
+func (h *CampaignHandler) CreateCampaign(c *gin.Context) {
+	var req CreateCampaignRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	bundle := make([]services.BundleLink, len(req.Links))
+	for i := range req.Links {
+		if req.Links[i].Honeytoken && !allowHoneytoken(c) {
+			return
+		}
+		bundle[i] = services.BundleLink{URL: req.Links[i].URL, Options: shortenOptions(c, &req.Links[i])}
+	}
+	campaign, results, err := h.campaigns.CreateBundle(c.Request.Context(), middleware.AccountID(c), req.Name, req.Description, bundle)
+	if err != nil {
+		if errors.Is(err, services.ErrCampaignFull) {
+			respondInvalidField(c, validators.CodeOutOfRange, "links", fmt.Sprintf("a campaign holds at most %d links", h.campaigns.MaxLinks()))
+			return
+		}
+		respondError(c, err, "Failed to create campaign")
+		return
+	}
+	response := CreateCampaignResponse{Campaign: campaign, Links: make([]CampaignLinkResult, len(results))}
+	for i, result := range results {
+		if result.Err != nil {
+			failure := services.Describe(result.Err, "Failed to shorten URL")
+			response.Links[i].Error = &CampaignLinkError{Status: failure.Status, Code: failure.Code, Message: failure.Detail}
+			if failure.Field != "" {
+				response.Links[i].Error.Errors = validators.NewError(failure.FieldCode, failure.Field, failure.Detail)
+			}
+			continue
+		}
+		link := newShortenResponse(h.links, result.Link)
+		response.Links[i].ShortenResponse = &link
+	}
+	utils.RespondWithJSON(c, http.StatusCreated, response)
+}
+
+// ListCampaigns handles GET /api/v1/campaigns
+func (h *CampaignHandler) ListCampaigns(c *gin.Context) {
+	pageReq, ok := parsePageRequest(c, repository.CampaignListSpec)
+	if !ok {
+		return
+	}
+	page, err := h.campaigns.List(c.Request.Context(), middleware.AccountID(c), pageReq)
+	if err != nil {
+		respondError(c, err, "Failed to list campaigns")
+		return
+	}
+	respondWithPage(c, page)
+}
+
+// GetCampaign handles GET /api/v1/campaigns/:id
+func (h *CampaignHandler) GetCampaign(c *gin.Context) {
+	campaign, err := h.campaigns.Get(c.Request.Context(), middleware.AccountID(c), c.Param("id"))
+	if err != nil {
+		respondError(c, err, "Failed to retrieve campaign")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, campaign)
+}
+
+// ListCampaignLinks handles GET /api/v1/campaigns/:id/links
+func (h *CampaignHandler) ListCampaignLinks(c *gin.Context) {
+	pageReq, ok := parsePageRequest(c, repository.LinkListSpec)
+	if !ok {
+		return
+	}
+	page, err := h.campaigns.Links(c.Request.Context(), middleware.AccountID(c), c.Param("id"), pageReq)
+	if err != nil {
+		respondError(c, err, "Failed to list campaign links")
+		return
+	}
+	respondWithPage(c, page)
+}
+
+// GetCampaignStats handles GET /api/v1/campaigns/:id/stats
+// Takes the same from, to, granularity and traffic parameters as link stats
+func (h *CampaignHandler) GetCampaignStats(c *gin.Context) {
+	query, err := parseStatsQuery(c)
+	if err != nil {
+		utils.RespondWithErrorCode(c, http.StatusBadRequest, utils.ErrCodeInvalidQuery, err.Error())
+		return
+	}
+	stats, err := h.campaigns.Stats(c.Request.Context(), middleware.AccountID(c), c.Param("id"), query)
+	if err != nil {
+		respondError(c, err, "Failed to retrieve stats")
+		return
+	}
+	utils.RespondWithETag(c, stats, h.statsMaxAge)
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/middleware"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// chatUsage answers /shorten help and commands it doesn't understand
+const chatUsage = "Usage: `/shorten <url> [alias]` creates a short link for this workspace's account. " +
+	"`/shorten connect <code>` connects the workspace, with a code from POST /api/v1/account/chat-workspaces"
+
+// Discord interaction and response types
+const (
+	discordPing               = 1
+	discordApplicationCommand = 2
+	discordPong               = 1
+	discordChannelMessage     = 4
+	// discordEphemeral shows a response only to the user who ran the command
+	discordEphemeral = 1 << 6
+)
+
+// ChatHandler answers Slack and Discord slash commands and manages the
+// workspaces connected to an account. The platforms sign their requests
+// instead of sending an API key, and expect their own response formats
+type ChatHandler struct {
+	chat       *services.ChatService
+	urlService *services.URLService
+	links      *Links
+}
+
+func NewChatHandler(chat *services.ChatService, urlService *services.URLService, links *Links) *ChatHandler {
+	return &ChatHandler{
+		chat:       chat,
+		urlService: urlService,
+		links:      links,
+	}
+}
+
+// ConnectCodeRequest names the platform of the workspace to connect
+type ConnectCodeRequest struct {
+	Platform string `json:"platform" binding:"required,oneof=slack discord"`
+}
+
+type ConnectCodeResponse struct {
+	Platform  string    `json:"platform"`
+	Code      string    `json:"code"`
+	Command   string    `json:"command"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// chatReply is a slash command's answer; public ones are shown to the
+// whole channel, the rest only to whoever ran the command
+type chatReply struct {
+	text   string
+	public bool
+}
+
+// CreateConnectCode handles POST /api/v1/account/chat-workspaces
+// The code connects the workspace it is used in to the caller's account
+func (h *ChatHandler) CreateConnectCode(c *gin.Context) {
+	var req ConnectCodeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	code, expiresAt, err := h.chat.NewConnectCode(c.Request.Context(), middleware.AccountID(c), req.Platform)
+	if err != nil {
+		respondError(c, err, "Failed to create connect code")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusCreated, ConnectCodeResponse{
+		Platform:  req.Platform,
+		Code:      code,
+		Command:   "/shorten connect " + code,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// ListChatWorkspaces handles GET /api/v1/account/chat-workspaces
+func (h *ChatHandler) ListChatWorkspaces(c *gin.Context) {
+	workspaces, err := h.chat.Workspaces(c.Request.Context(), middleware.AccountID(c))
+	if err != nil {
+		respondError(c, err, "Failed to list chat workspaces")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, workspaces)
+}
+
+// DisconnectChatWorkspace handles DELETE /api/v1/account/chat-workspaces/:id
+func (h *ChatHandler) DisconnectChatWorkspace(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err == nil {
+		err = h.chat.Disconnect(c.Request.Context(), middleware.AccountID(c), id)
+	} else {
+		err = services.ErrChatWorkspaceNotFound
+	}
+	if err != nil {
+		respondError(c, err, "Failed to disconnect chat workspace")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Slack handles POST /integrations/slack, the request URL of a Slack slash
+// command
+func (h *ChatHandler) Slack(c *gin.Context) {
+	body, ok := h.signedBody(c, func(body []byte) error {
+		return h.chat.VerifySlack(c.GetHeader("X-Slack-Request-Timestamp"), c.GetHeader("X-Slack-Signature"), body)
+	})
+	if !ok {
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		utils.RespondWithErrorCode(c, http.StatusBadRequest, utils.ErrCodeBadRequest, "Invalid slash command payload")
+		return
+	}
+	reply := h.runCommand(c.Request.Context(), models.ChatSlack, form.Get("team_id"), form.Get("user_id"), form.Get("text"))
+	responseType := "ephemeral"
+	if reply.public {
+		responseType = "in_channel"
+	}
+	// Slack's format, not the API's response envelope
+	c.JSON(http.StatusOK, gin.H{"response_type": responseType, "text": reply.text})
+}
+
+// discordInteraction is the part of a Discord interaction the handler reads
+type discordInteraction struct {
+	Type    int    `json:"type"`
+	GuildID string `json:"guild_id"`
+	Member  *struct {
+		User struct {
+			ID string `json:"id"`
+		} `json:"user"`
+	} `json:"member"`
+	Data struct {
+		Options []struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"options"`
+	} `json:"data"`
+}
+
+// Discord handles POST /integrations/discord, the interactions endpoint of
+// a Discord application. The command's string options form the text, so
+// register /shorten with one string option
+func (h *ChatHandler) Discord(c *gin.Context) {
+	body, ok := h.signedBody(c, func(body []byte) error {
+		return h.chat.VerifyDiscord(c.GetHeader("X-Signature-Timestamp"), c.GetHeader("X-Signature-Ed25519"), body)
+	})
+	if !ok {
+		return
+	}
+	var interaction discordInteraction
+	if err := json.Unmarshal(body, &interaction); err != nil {
+		utils.RespondWithErrorCode(c, http.StatusBadRequest, utils.ErrCodeBadRequest, "Invalid interaction payload")
+		return
+	}
+	switch interaction.Type {
+	case discordPing:
+		c.JSON(http.StatusOK, gin.H{"type": discordPong})
+		return
+	case discordApplicationCommand:
+	default:
+		utils.RespondWithErrorCode(c, http.StatusBadRequest, utils.ErrCodeBadRequest, "Unsupported interaction type")
+		return
+	}
+	var reply chatReply
+	if interaction.GuildID == "" || interaction.Member == nil {
+		reply = chatReply{text: "/shorten only works in a server"}
+	} else {
+		var words []string
+		for _, option := range interaction.Data.Options {
+			var value string
+			if json.Unmarshal(option.Value, &value) == nil {
+				words = append(words, value)
+			}
+		}
+		reply = h.runCommand(c.Request.Context(), models.ChatDiscord, interaction.GuildID, interaction.Member.User.ID, strings.Join(words, " "))
+	}
+	data := gin.H{"content": reply.text}
+	if !reply.public {
+		data["flags"] = discordEphemeral
+	}
+	c.JSON(http.StatusOK, gin.H{"type": discordChannelMessage, "data": data})
+}
+
+// signedBody reads the request body and checks its signature with verify,
+// writing the error response and reporting false if either fails
+func (h *ChatHandler) signedBody(c *gin.Context, verify func(body []byte) error) ([]byte, bool) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.RespondWithErrorCode(c, http.StatusRequestEntityTooLarge, utils.ErrCodePayloadTooLarge,
+				fmt.Sprintf("Request body must be at most %d bytes", tooLarge.Limit))
+			return nil, false
+		}
+		utils.RespondWithErrorCode(c, http.StatusBadRequest, utils.ErrCodeBadRequest, "Failed to read request body")
+		return nil, false
+	}
+	if err := verify(body); err != nil {
+		respondError(c, err, "Failed to verify request")
+		return nil, false
+	}
+	return body, true
+}
+
+// runCommand carries out /shorten <text> run by userID in workspaceID
+func (h *ChatHandler) runCommand(ctx context.Context, platform, workspaceID, userID, text string) chatReply {
+	args := strings.Fields(text)
+	if len(args) == 0 || args[0] == "help" || len(args) > 2 {
+		return chatReply{text: chatUsage}
+	}
+	if args[0] == "connect" {
+		if len(args) != 2 {
+			return chatReply{text: chatUsage}
+		}
+		_, err := h.chat.Connect(ctx, platform, workspaceID, userID, args[1])
+		switch err {
+		case nil:
+			return chatReply{text: "Connected. `/shorten <url>` now creates links in this workspace for your account"}
+		case services.ErrChatCode:
+			return chatReply{text: "That connect code is unknown or has expired; create a new one"}
+		case services.ErrChatWorkspaceTaken:
+			return chatReply{text: "This workspace is already connected to an account. Disconnect it there first"}
+		default:
+			return chatReply{text: "Couldn't connect the workspace right now; try again later"}
+		}
+	}
+
+	accountID, err := h.chat.AccountFor(ctx, platform, workspaceID)
+	if errors.Is(err, services.ErrChatWorkspaceNotConnected) {
+		return chatReply{text: "This workspace isn't connected to an account yet. " + chatUsage}
+	}
+	if err != nil {
+		return chatReply{text: "Couldn't shorten the link right now; try again later"}
+	}
+	destination := unwrapChatLink(args[0])
+	var alias string
+	if len(args) == 2 {
+		alias = args[1]
+	}
+	var errs validators.Errors
+	errs.CheckURL("url", destination)
+	errs.CheckShortCode("alias", alias)
+	if err := errs.Err(); err != nil {
+		return chatReply{text: "Couldn't shorten the link: " + err.Error()}
+	}
+	shortURL, err := h.urlService.ShortenURL(ctx, destination, services.ShortenOptions{
+		OwnerID: accountID,
+		Alias:   alias,
+	})
+	if err != nil {
+		return chatReply{text: "Couldn't shorten the link: " + services.Describe(err, "Failed to shorten URL").Detail}
+	}
+	return chatReply{
+		text:   h.links.Short(shortURL.Domain, shortURL.ShortCode) + " → " + shortURL.OriginalURL,
+		public: true,
+	}
+}
+
+// unwrapChatLink undoes Slack's link formatting, <url> or <url|label>
+func unwrapChatLink(arg string) string {
+	if strings.HasPrefix(arg, "<") && strings.HasSuffix(arg, ">") {
+		arg, _, _ = strings.Cut(arg[1:len(arg)-1], "|")
+	}
+	return arg
+}
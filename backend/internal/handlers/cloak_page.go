@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+)
+
+var defaultCloakPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+{{if eq .Mode "meta"}}<meta http-equiv="refresh" content="0;url={{.URL}}">{{end}}
+<title>{{if .Title}}{{.Title}}{{else}}{{.Host}}/{{.Code}}{{end}}</title>
+{{if eq .Mode "frame"}}<style>html,body{margin:0;height:100%;overflow:hidden}iframe{border:0;width:100%;height:100%}</style>{{end}}
+</head>
+<body>
+{{if eq .Mode "frame"}}<iframe src="{{.URL}}" allowfullscreen>
+<a href="{{.URL}}">Continue to {{.URL}}</a>
+</iframe>{{else}}<p><a href="{{.URL}}">Continue to {{.URL}}</a></p>{{end}}
+</body>
+</html>
+`
+
+// CloakPages renders the page cloaked links serve in place of a redirect
+type CloakPages struct {
+	page *template.Template
+}
+
+// CloakPageData is what a cloak page template is rendered with
+type CloakPageData struct {
+	// Mode is models.CloakFrame or models.CloakMeta
+	Mode  string
+	URL   string
+	Title string
+	Code  string
+	Host  string
+}
+
+// NewCloakPages loads the cloak page template from templatePath, or uses the
+// built-in page when it is empty
+func NewCloakPages(templatePath string) (*CloakPages, error) {
+	raw := defaultCloakPage
+	if templatePath != "" {
+		file, err := os.ReadFile(templatePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cloak page template: %w", err)
+		}
+		raw = string(file)
+	}
+	page, err := template.New("cloak").Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cloak page template: %w", err)
+	}
+	return &CloakPages{page: page}, nil
+}
+
+// Render answers a visit to a cloaked link with its page. Caching headers are
+// already set; a template that fails to render falls back to a redirect
+func (p *CloakPages) Render(c *gin.Context, redirect *services.Redirect) {
+	var body bytes.Buffer
+	err := p.page.Execute(&body, CloakPageData{
+		Mode:  redirect.Cloak,
+		URL:   redirect.URL,
+		Title: redirect.Title,
+		Code:  c.Param("code"),
+		Host:  c.Request.Host,
+	})
+	if err != nil {
+		c.Redirect(http.StatusTemporaryRedirect, redirect.URL)
+		return
+	}
+	setPreconnectHints(c, redirect.URL)
+	c.Data(http.StatusOK, "text/html; charset=utf-8", body.Bytes())
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/middleware"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type DomainHandler struct {
+	domainService  *services.DomainService
+	sitemapService *services.SitemapService
+	robots         *RobotsTxt
+}
+
+func NewDomainHandler(domainService *services.DomainService, sitemapService *services.SitemapService, robots *RobotsTxt) *DomainHandler {
+	return &DomainHandler{
+		domainService:  domainService,
+		sitemapService: sitemapService,
+		robots:         robots,
+	}
+}
+
+type AddDomainRequest struct {
+	Host string `json:"host" binding:"required"`
+}
+
+// AddDomain handles POST /api/v1/account/domains
+func (h *DomainHandler) AddDomain(c *gin.Context) {
+	var req AddDomainRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	domain, err := h.domainService.AddDomain(c.Request.Context(), middleware.AccountID(c), req.Host)
+	if err != nil {
+		respondError(c, err, "Failed to add domain")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusCreated, domain)
+}
+
+// VerifyDomain handles POST /api/v1/account/domains/:id/verify
+// It looks up the TXT record named by the domain's verification_record
+func (h *DomainHandler) VerifyDomain(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		utils.RespondWithErrorCode(c, http.StatusNotFound, utils.ErrCodeDomainNotFound, "Domain not found")
+		return
+	}
+	domain, err := h.domainService.VerifyDomain(c.Request.Context(), middleware.AccountID(c), id)
+	if err != nil {
+		respondError(c, err, "Failed to verify domain")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, domain)
+}
+
+// ListDomains handles GET /api/v1/account/domains
+func (h *DomainHandler) ListDomains(c *gin.Context) {
+	pageReq, ok := parsePageRequest(c, repository.CreatedListSpec)
+	if !ok {
+		return
+	}
+	page, err := h.domainService.ListDomains(c.Request.Context(), middleware.AccountID(c), pageReq)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to list domains")
+		return
+	}
+	respondWithPage(c, page)
+}
+
+// Sitemap handles GET /sitemap.xml on a branded domain
+func (h *DomainHandler) Sitemap(c *gin.Context) {
+	body, err := h.sitemapService.Get(c.Request.Context(), c.Request.Host)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to build sitemap")
+		return
+	}
+	if body == nil {
+		utils.RespondWithErrorCode(c, http.StatusNotFound, utils.ErrCodeSitemapNotFound, "Sitemap not found")
+		return
+	}
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", body)
+}
+
+// Robots handles GET /robots.txt, pointing crawlers of a branded domain at
+// its sitemap
+func (h *DomainHandler) Robots(c *gin.Context) {
+	domain, err := h.domainService.Resolve(c.Request.Context(), c.Request.Host)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to render robots.txt")
+		return
+	}
+	data := RobotsTxtData{Host: c.Request.Host}
+	if domain != nil {
+		data = RobotsTxtData{Host: domain.Host, Sitemap: true}
+	}
+	h.robots.Render(c, data)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/middleware"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+)
+
+type EmbedHandler struct {
+	embedService *services.EmbedService
+	urlService   *services.URLService
+	links        *Links
+}
+
+func NewEmbedHandler(embedService *services.EmbedService, urlService *services.URLService, links *Links) *EmbedHandler {
+	return &EmbedHandler{
+		embedService: embedService,
+		urlService:   urlService,
+		links:        links,
+	}
+}
+
+type EmbedShortenRequest struct {
+	Token string `json:"token" binding:"required"`
+	URL   string `json:"url" binding:"required,url"`
+}
+
+// Validate checks the destination URL beyond the struct tags
+func (r *EmbedShortenRequest) Validate() error {
+	var errs validators.Errors
+	errs.CheckURL("url", r.URL)
+	return errs.Err()
+}
+
+type EmbedShortenResponse struct {
+	ShortURL  string `json:"short_url"`
+	ShortCode string `json:"short_code"`
+}
+
+type IssueEmbedTokenRequest struct {
+	Domain string `json:"domain" binding:"required"`
+	TTL    string `json:"ttl,omitempty"`
+}
+
+// Validate checks the optional TTL
+func (r *IssueEmbedTokenRequest) Validate() error {
+	var errs validators.Errors
+	errs.CheckDuration("ttl", r.TTL)
+	return errs.Err()
+}
+
+type IssueEmbedTokenResponse struct {
+	Token     string     `json:"token"`
+	Domain    string     `json:"domain"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Preflight handles OPTIONS /embed/shorten
+// The site token travels in the body, so origins are checked on the real request
+func (h *EmbedHandler) Preflight(c *gin.Context) {
+	if origin := c.GetHeader("Origin"); origin != "" {
+		c.Header("Access-Control-Allow-Origin", origin)
+		c.Header("Vary", "Origin")
+	}
+	c.Header("Access-Control-Allow-Methods", "POST, OPTIONS")
+	c.Header("Access-Control-Allow-Headers", "Content-Type")
+	c.Header("Access-Control-Max-Age", "600")
+	c.Status(http.StatusNoContent)
+}
+
+// Shorten handles POST /embed/shorten for the public widget
+// Links are created under the site token's account and must stay on its domain
+func (h *EmbedHandler) Shorten(c *gin.Context) {
+	var req EmbedShortenRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	claims, err := h.embedService.VerifyToken(req.Token)
+	if err != nil {
+		respondError(c, err, "Failed to verify site token")
+		return
+	}
+	if origin := c.GetHeader("Origin"); origin != "" {
+		if !h.embedService.AllowedOrigin(claims, origin) {
+			utils.RespondWithErrorCode(c, http.StatusForbidden, utils.ErrCodeOriginNotAllowed, "Origin does not match the site token")
+			return
+		}
+		c.Header("Access-Control-Allow-Origin", origin)
+		c.Header("Vary", "Origin")
+	}
+	if err := h.embedService.CheckDestination(claims, req.URL); err != nil {
+		utils.RespondWithErrorCode(c, http.StatusForbidden, utils.ErrCodeURLNotAllowed, "URL must be on "+claims.Domain)
+		return
+	}
+
+	shortURL, err := h.urlService.ShortenURL(c.Request.Context(), req.URL, services.ShortenOptions{
+		OwnerID:  claims.AccountID,
+		ClientIP: middleware.ClientIP(c),
+	})
+	if err != nil {
+		respondError(c, err, "Failed to shorten URL")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, EmbedShortenResponse{
+		ShortURL:  h.links.Short(shortURL.Domain, shortURL.ShortCode),
+		ShortCode: shortURL.ShortCode,
+	})
+}
+
+// IssueToken handles POST /api/v1/account/embed-tokens
+func (h *EmbedHandler) IssueToken(c *gin.Context) {
+	var req IssueEmbedTokenRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	// Validate has already checked the format
+	ttl, _ := time.ParseDuration(req.TTL)
+
+	token, claims, err := h.embedService.IssueToken(middleware.AccountID(c), req.Domain, ttl)
+	if err != nil {
+		respondError(c, err, "Failed to issue site token")
+		return
+	}
+	response := IssueEmbedTokenResponse{Token: token, Domain: claims.Domain}
+	if claims.ExpiresAt != 0 {
+		expiresAt := time.Unix(claims.ExpiresAt, 0).UTC()
+		response.ExpiresAt = &expiresAt
+	}
+	utils.RespondWithJSON(c, http.StatusCreated, response)
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+)
+
+// Reasons a short link did not redirect, passed to the error page template
+const (
+	ReasonNotFound   = "not_found"
+	ReasonExpired    = "expired"
+	ReasonInactive   = "inactive"
+	ReasonClickLimit = "click_limit"
+	ReasonRateLimit  = "rate_limit"
+)
+
+// reasonErrorCodes are the error codes of JSON responses for each reason
+var reasonErrorCodes = map[string]string{
+	ReasonNotFound:   utils.ErrCodeURLNotFound,
+	ReasonExpired:    utils.ErrCodeURLExpired,
+	ReasonInactive:   utils.ErrCodeURLInactive,
+	ReasonClickLimit: utils.ErrCodeClickLimitReached,
+	ReasonRateLimit:  utils.ErrCodeLinkRateLimited,
+}
+
+// errorReason returns the reason for the service error in err's chain whose
+// code has an error page, or ok false for any other error
+func errorReason(err error) (reason string, serviceErr *services.Error, ok bool) {
+	if !errors.As(err, &serviceErr) {
+		return "", nil, false
+	}
+	for reason, code := range reasonErrorCodes {
+		if code == serviceErr.Code {
+			return reason, serviceErr, true
+		}
+	}
+	return "", nil, false
+}
+
+// ErrorPages is what visitors see when a short link doesn't redirect: a
+// redirect to a fallback URL, a branded HTML page, or the JSON error
+type ErrorPages struct {
+	fallbackURL string
+	page        *template.Template
+}
+
+// ErrorPageData is what an error page template is rendered with
+type ErrorPageData struct {
+	Code    string
+	Host    string
+	Status  int
+	Reason  string
+	Message string
+}
+
+// NewErrorPages loads the error page template from templatePath, if set
+// fallbackURL, if set, takes precedence over the page
+func NewErrorPages(fallbackURL, templatePath string) (*ErrorPages, error) {
+	pages := &ErrorPages{fallbackURL: fallbackURL}
+	if templatePath == "" {
+		return pages, nil
+	}
+	raw, err := os.ReadFile(templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read error page template: %w", err)
+	}
+	pages.page, err = template.New("error").Parse(string(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse error page template: %w", err)
+	}
+	return pages, nil
+}
+
+// Respond answers a visit to a short link that doesn't redirect. A fallback
+// URL applies to every visit, the page only to clients asking for HTML;
+// everything else gets the JSON error
+func (p *ErrorPages) Respond(c *gin.Context, status int, reason, message string) {
+	if p.fallbackURL != "" {
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(http.StatusTemporaryRedirect, p.fallbackURL)
+		return
+	}
+	if p.page == nil || c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) != gin.MIMEHTML {
+		utils.RespondWithErrorCode(c, status, reasonErrorCodes[reason], message)
+		return
+	}
+	// Rendered to a buffer first so a failing template can still fall back to JSON
+	var body bytes.Buffer
+	err := p.page.Execute(&body, ErrorPageData{
+		Code:    c.Param("code"),
+		Host:    c.Request.Host,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	})
+	if err != nil {
+		utils.RespondWithErrorCode(c, status, reasonErrorCodes[reason], message)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Data(status, "text/html; charset=utf-8", body.Bytes())
+}
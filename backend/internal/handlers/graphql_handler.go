@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/graph"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/middleware"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// GraphQL limits keep one request from fanning out into unbounded work
+const (
+	graphQLMaxDepth       = 8
+	graphQLMaxComplexity  = 500
+	graphQLMaxParallelism = 10
+)
+
+// GraphQLHandler serves the link management GraphQL API. It covers the same
+// operations as the REST routes, so dashboards can fetch a link with its
+// time series and referrers in one round trip
+type GraphQLHandler struct {
+	server *handler.Server
+}
+
+func NewGraphQLHandler(urlService *services.URLService, campaigns *services.CampaignService, links *Links) *GraphQLHandler {
+	resolver := &graphQLResolver{urls: urlService, campaigns: campaigns, links: links}
+	server := handler.New(graph.NewExecutableSchema(graph.Config{Resolvers: resolver}))
+	server.AddTransport(transport.POST{})
+	server.Use(extension.Introspection{})
+	server.Use(extension.FixedComplexityLimit(graphQLMaxComplexity))
+	server.Use(graphQLLimits{})
+	return &GraphQLHandler{server: server}
+}
+
+// Serve handles POST /graphql
+// Errors of individual fields are reported in the response's errors list
+// with a 200, as GraphQL clients expect
+func (h *GraphQLHandler) Serve(c *gin.Context) {
+	ctx := withGraphQLCaller(c.Request.Context(), newGraphQLCaller(c))
+	h.server.ServeHTTP(c.Writer, c.Request.WithContext(ctx))
+}
+
+// graphQLCaller is what resolvers know about the request: who it acts for
+// and which scopes it may use
+type graphQLCaller struct {
+	accountID string
+	apiKey    *models.APIKey
+	clientIP  string
+	scopes    map[string]bool
+	// slots bounds the resolvers of the request that run at once
+	slots chan struct{}
+}
+
+func newGraphQLCaller(c *gin.Context) *graphQLCaller {
+	caller := &graphQLCaller{
+		accountID: middleware.AccountID(c),
+		apiKey:    middleware.APIKey(c),
+		clientIP:  middleware.ClientIP(c),
+		scopes:    map[string]bool{},
+		slots:     make(chan struct{}, graphQLMaxParallelism),
+	}
+	for _, scope := range []string{models.ScopeShorten, models.ScopeLinksWrite, models.ScopeStatsRead, models.ScopeAdmin} {
+		caller.scopes[scope] = middleware.HasScope(c, scope)
+	}
+	return caller
+}
+
+type graphQLCallerKey struct{}
+
+func withGraphQLCaller(ctx context.Context, caller *graphQLCaller) context.Context {
+	return context.WithValue(ctx, graphQLCallerKey{}, caller)
+}
+
+// callerFrom returns the request's caller after checking it may use scope
+// (no scope check when scope is empty)
+func callerFrom(ctx context.Context, scope string) (*graphQLCaller, error) {
+	caller, _ := ctx.Value(graphQLCallerKey{}).(*graphQLCaller)
+	if caller == nil || caller.accountID == "" {
+		return nil, newGraphQLError(http.StatusUnauthorized, utils.ErrCodeAPIKeyRequired, "API key required")
+	}
+	if scope != "" && !caller.scopes[scope] {
+		return nil, newGraphQLError(http.StatusForbidden, utils.ErrCodeInsufficientScope, "API key lacks the "+scope+" scope")
+	}
+	return caller, nil
+}
+
+// graphQLLimits rejects operations nested deeper than graphQLMaxDepth and
+// runs at most graphQLMaxParallelism resolvers of a request at once
+type graphQLLimits struct{}
+
+var (
+	_ graphql.OperationContextMutator = graphQLLimits{}
+	_ graphql.FieldInterceptor        = graphQLLimits{}
+)
+
+func (graphQLLimits) ExtensionName() string {
+	return "GraphQLLimits"
+}
+
+func (graphQLLimits) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (graphQLLimits) MutateOperationContext(ctx context.Context, opCtx *graphql.OperationContext) *gqlerror.Error {
+	if selectionDepth(opCtx.Operation.SelectionSet) > graphQLMaxDepth {
+		return newGraphQLError(http.StatusBadRequest, utils.ErrCodeValidationFailed, "Query exceeds the maximum depth")
+	}
+	return nil
+}
+
+func (graphQLLimits) InterceptField(ctx context.Context, next graphql.Resolver) (any, error) {
+	caller, _ := ctx.Value(graphQLCallerKey{}).(*graphQLCaller)
+	if fc := graphql.GetFieldContext(ctx); caller == nil || fc == nil || !fc.IsResolver {
+		return next(ctx)
+	}
+	select {
+	case caller.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-caller.slots }()
+	return next(ctx)
+}
+
+// selectionDepth is how deeply fields nest in set, through fragments.
+// Introspection fields are not counted
+func selectionDepth(set ast.SelectionSet) int {
+	depth := 0
+	for _, selection := range set {
+		var d int
+		switch s := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name, "__") {
+				continue
+			}
+			d = 1 + selectionDepth(s.SelectionSet)
+		case *ast.InlineFragment:
+			d = selectionDepth(s.SelectionSet)
+		case *ast.FragmentSpread:
+			if s.Definition != nil {
+				d = selectionDepth(s.Definition.SelectionSet)
+			}
+		}
+		depth = max(depth, d)
+	}
+	return depth
+}
+
+// newGraphQLError is a resolver error carrying the status and error code the
+// REST API would have returned under the error's extensions
+func newGraphQLError(status int, code, message string) *gqlerror.Error {
+	return &gqlerror.Error{
+		Message:    message,
+		Extensions: map[string]any{"status": status, "code": code},
+	}
+}
+
+// withField adds the field of an invalid argument and its validation code
+// to the extensions of err
+func withField(err *gqlerror.Error, field, fieldCode string) *gqlerror.Error {
+	if field != "" {
+		err.Extensions["field"] = field
+		err.Extensions["field_code"] = fieldCode
+	}
+	return err
+}
+
+// serviceError maps a service error to the status, code and field the REST
+// handlers would answer it with
+func serviceError(err error, fallback string) error {
+	failure := services.Describe(err, fallback)
+	if services.Internal(err) {
+		log.Printf("GraphQL: %s: %v", fallback, err)
+	}
+	return withField(newGraphQLError(failure.Status, failure.Code, failure.Detail), failure.Field, failure.FieldCode)
+}
+
+// invalidArgument reports a bad argument with the same codes as REST validation
+func invalidArgument(field, fieldCode, message string) error {
+	return withField(newGraphQLError(http.StatusBadRequest, utils.ErrCodeValidationFailed, message), field, fieldCode)
+}
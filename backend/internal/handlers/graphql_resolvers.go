@@ -0,0 +1,475 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/graph"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+)
+
+// graphQLResolver resolves the schema in internal/graph/schema.graphql
+type graphQLResolver struct {
+	urls      *services.URLService
+	campaigns *services.CampaignService
+	links     *Links
+}
+
+var _ graph.ResolverRoot = (*graphQLResolver)(nil)
+
+func (r *graphQLResolver) Query() graph.QueryResolver       { return queryResolver{r} }
+func (r *graphQLResolver) Mutation() graph.MutationResolver { return mutationResolver{r} }
+func (r *graphQLResolver) Link() graph.LinkResolver         { return linkResolver{r} }
+func (r *graphQLResolver) Campaign() graph.CampaignResolver { return campaignResolver{r} }
+
+type queryResolver struct{ *graphQLResolver }
+
+func (r queryResolver) Link(ctx context.Context, code string, domain *string) (*graph.Link, error) {
+	caller, err := callerFrom(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	shortURL, err := r.urls.GetOwnedLink(ctx, caller.accountID, domainArg(domain), code)
+	if errors.Is(err, services.ErrURLNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, serviceError(err, "Failed to retrieve link")
+	}
+	return r.link(shortURL), nil
+}
+
+func (r queryResolver) Links(ctx context.Context, url string) ([]*graph.Link, error) {
+	caller, err := callerFrom(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	matches, err := r.urls.LookupDestination(ctx, caller.accountID, url)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidURL) {
+			return nil, invalidArgument("url", validators.CodeInvalidURL, "url must be an absolute URL")
+		}
+		return nil, serviceError(err, "Failed to look up links")
+	}
+	links := make([]*graph.Link, len(matches))
+	for i, shortURL := range matches {
+		links[i] = r.link(shortURL)
+	}
+	return links, nil
+}
+
+func (r queryResolver) Campaign(ctx context.Context, id string) (*graph.Campaign, error) {
+	caller, err := callerFrom(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	campaign, err := r.campaigns.Get(ctx, caller.accountID, id)
+	if errors.Is(err, services.ErrCampaignNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, serviceError(err, "Failed to retrieve campaign")
+	}
+	return newCampaign(campaign), nil
+}
+
+func (r queryResolver) Campaigns(ctx context.Context, first *int32, after *string) (*graph.CampaignConnection, error) {
+	caller, err := callerFrom(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	pageReq, err := pageRequest(repository.CampaignListSpec, first, after)
+	if err != nil {
+		return nil, err
+	}
+	page, err := r.campaigns.List(ctx, caller.accountID, pageReq)
+	if err != nil {
+		return nil, serviceError(err, "Failed to list campaigns")
+	}
+	conn := &graph.CampaignConnection{
+		Nodes:    make([]*graph.Campaign, len(page.Items)),
+		PageInfo: newPageInfo(page.PageInfo),
+	}
+	for i := range page.Items {
+		conn.Nodes[i] = newCampaign(&page.Items[i])
+	}
+	return conn, nil
+}
+
+type mutationResolver struct{ *graphQLResolver }
+
+func (r mutationResolver) CreateLink(ctx context.Context, in graph.CreateLinkInput) (*graph.Link, error) {
+	caller, err := callerFrom(ctx, models.ScopeShorten)
+	if err != nil {
+		return nil, err
+	}
+	req := ShortenURLRequest{
+		URL:        in.URL,
+		Alias:      stringArg(in.Alias),
+		Domain:     domainArg(in.Domain),
+		Title:      stringArg(in.Title),
+		Notes:      stringArg(in.Notes),
+		CampaignID: stringArg(in.CampaignID),
+	}
+	if in.ExpiresIn != nil {
+		hours := int(*in.ExpiresIn)
+		req.ExpiresIn = &hours
+	}
+	if err := req.Validate(); err != nil {
+		return nil, validationError(err)
+	}
+	if req.CampaignID != "" {
+		if err := r.campaigns.CheckCapacity(ctx, caller.accountID, req.CampaignID, 1); err != nil {
+			return nil, serviceError(err, "Failed to shorten URL")
+		}
+	}
+	opts := services.ShortenOptions{
+		OwnerID:    caller.accountID,
+		Alias:      req.Alias,
+		Domain:     req.Domain,
+		Title:      req.Title,
+		Notes:      req.Notes,
+		CampaignID: req.CampaignID,
+		ClientIP:   caller.clientIP,
+	}
+	if caller.apiKey != nil {
+		opts.Quota = &services.CreationQuota{Key: caller.apiKey}
+	}
+	if req.ExpiresIn != nil {
+		duration := time.Duration(*req.ExpiresIn) * time.Hour
+		opts.ExpiresIn = &duration
+	}
+	shortURL, err := r.urls.ShortenURL(ctx, req.URL, opts)
+	if err != nil {
+		return nil, serviceError(err, "Failed to shorten URL")
+	}
+	return r.link(shortURL), nil
+}
+
+// UpdateLink changes only the fields the input sets, as PATCH does on the
+// REST API
+func (r mutationResolver) UpdateLink(ctx context.Context, code string, domain *string, in graph.UpdateLinkInput) (*graph.Link, error) {
+	caller, err := callerFrom(ctx, models.ScopeLinksWrite)
+	if err != nil {
+		return nil, err
+	}
+	patch := services.LinkPatch{ClientIP: caller.clientIP}
+	var errs validators.Errors
+	if in.URL != nil {
+		patch.Fields = append(patch.Fields, services.PatchOriginalURL)
+		patch.OriginalURL = *in.URL
+		errs.CheckURL("url", patch.OriginalURL)
+	}
+	if in.Title != nil {
+		patch.Fields = append(patch.Fields, services.PatchTitle)
+		patch.Title = *in.Title
+		errs.CheckLength("title", patch.Title, validators.MaxTitleLength)
+	}
+	if in.Notes != nil {
+		patch.Fields = append(patch.Fields, services.PatchNotes)
+		patch.Notes = *in.Notes
+		errs.CheckLength("notes", patch.Notes, validators.MaxNotesLength)
+	}
+	if hours, set := in.ExpiresIn.ValueOK(); set {
+		patch.Fields = append(patch.Fields, services.PatchExpiresIn)
+		if hours != nil {
+			if *hours <= 0 {
+				errs.Add(validators.CodeOutOfRange, "expires_in", "expires_in must be a positive number of hours")
+			}
+			duration := time.Duration(*hours) * time.Hour
+			patch.ExpiresIn = &duration
+		}
+	}
+	if err := errs.Err(); err != nil {
+		return nil, validationError(err)
+	}
+
+	shortURL, err := r.urls.PatchURL(ctx, caller.accountID, domainArg(domain), code, patch)
+	if err != nil {
+		return nil, serviceError(err, "Failed to update URL")
+	}
+	return r.link(shortURL), nil
+}
+
+func (r mutationResolver) DeleteLink(ctx context.Context, code string, domain *string) (*graph.Link, error) {
+	caller, err := callerFrom(ctx, models.ScopeLinksWrite)
+	if err != nil {
+		return nil, err
+	}
+	shortURL, err := r.urls.DeleteURL(ctx, caller.accountID, domainArg(domain), code)
+	if err != nil {
+		return nil, serviceError(err, "Failed to delete URL")
+	}
+	return r.link(shortURL), nil
+}
+
+// link converts a stored link to its GraphQL model
+func (r *graphQLResolver) link(shortURL *models.ShortURL) *graph.Link {
+	return &graph.Link{
+		Code:          shortURL.ShortCode,
+		Domain:        optionalString(shortURL.Domain),
+		ShortURL:      r.links.Short(shortURL.Domain, shortURL.ShortCode),
+		URL:           shortURL.OriginalURL,
+		Title:         optionalString(shortURL.Title),
+		Notes:         optionalString(shortURL.Notes),
+		IsActive:      shortURL.IsActive,
+		Clicks:        count(shortURL.ClickCount),
+		BotClicks:     count(shortURL.BotClickCount),
+		CreatedAt:     shortURL.CreatedAt,
+		UpdatedAt:     shortURL.UpdatedAt,
+		ExpiresAt:     shortURL.ExpiresAt,
+		LastClickedAt: shortURL.LastClickedAt,
+		CampaignID:    optionalString(shortURL.CampaignID),
+		DeletedAt:     shortURL.DeletedAt,
+	}
+}
+
+type linkResolver struct{ *graphQLResolver }
+
+func (r linkResolver) Stats(ctx context.Context, link *graph.Link, from, to *time.Time, granularity, traffic *string) (*graph.LinkStats, error) {
+	caller, err := callerFrom(ctx, models.ScopeStatsRead)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := r.urls.GetStats(ctx, caller.accountID, stringArg(link.Domain), link.Code, statsQuery(from, to, granularity, traffic))
+	if err != nil {
+		return nil, serviceError(err, "Failed to retrieve stats")
+	}
+	return &graph.LinkStats{
+		From:         stats.From,
+		To:           stats.To,
+		Granularity:  stats.Granularity,
+		Traffic:      stats.Traffic,
+		Analytics:    stats.Analytics,
+		Timeseries:   buckets(stats.TimeSeries),
+		TopReferrers: countEntries(stats.TopReferrers),
+		TopCountries: countEntries(stats.TopCountries),
+		TopCities:    cityCounts(stats.TopCities),
+	}, nil
+}
+
+func (r linkResolver) Referrers(ctx context.Context, link *graph.Link, from, to *time.Time, traffic *string, limit *int32) (*graph.ReferrerStats, error) {
+	caller, err := callerFrom(ctx, models.ScopeStatsRead)
+	if err != nil {
+		return nil, err
+	}
+	top := defaultReferrerLimit
+	if limit != nil {
+		top = int(*limit)
+	}
+	stats, err := r.urls.GetReferrerStats(ctx, caller.accountID, stringArg(link.Domain), link.Code, statsQuery(from, to, nil, traffic), top)
+	if err != nil {
+		return nil, serviceError(err, "Failed to retrieve stats")
+	}
+	referrers := make([]*graph.ReferrerShare, len(stats.Referrers))
+	for i, share := range stats.Referrers {
+		referrers[i] = &graph.ReferrerShare{Domain: share.Domain, Clicks: count(share.Clicks), Percentage: share.Percentage}
+	}
+	return &graph.ReferrerStats{
+		From:         stats.From,
+		To:           stats.To,
+		Analytics:    stats.Analytics,
+		TotalClicks:  count(stats.TotalClicks),
+		DirectClicks: count(stats.DirectClicks),
+		Referrers:    referrers,
+	}, nil
+}
+
+func (r linkResolver) Countries(ctx context.Context, link *graph.Link, from, to *time.Time, traffic *string, limit *int32) (*graph.CountryStats, error) {
+	caller, err := callerFrom(ctx, models.ScopeStatsRead)
+	if err != nil {
+		return nil, err
+	}
+	top := defaultCountryLimit
+	if limit != nil {
+		top = int(*limit)
+	}
+	stats, err := r.urls.GetCountryStats(ctx, caller.accountID, stringArg(link.Domain), link.Code, statsQuery(from, to, nil, traffic), top)
+	if err != nil {
+		return nil, serviceError(err, "Failed to retrieve stats")
+	}
+	countries := make([]*graph.CountryShare, len(stats.Countries))
+	for i, share := range stats.Countries {
+		cities := make([]*graph.CityShare, len(share.Cities))
+		for j, city := range share.Cities {
+			cities[j] = &graph.CityShare{City: city.City, Clicks: count(city.Clicks), Percentage: city.Percentage}
+		}
+		countries[i] = &graph.CountryShare{Country: share.Country, Clicks: count(share.Clicks), Percentage: share.Percentage, Cities: cities}
+	}
+	return &graph.CountryStats{
+		From:          stats.From,
+		To:            stats.To,
+		Analytics:     stats.Analytics,
+		TotalClicks:   count(stats.TotalClicks),
+		UnknownClicks: count(stats.UnknownClicks),
+		Countries:     countries,
+	}, nil
+}
+
+func newCampaign(campaign *models.Campaign) *graph.Campaign {
+	return &graph.Campaign{
+		ID:          campaign.ID.Hex(),
+		Name:        campaign.Name,
+		Description: optionalString(campaign.Description),
+		CreatedAt:   campaign.CreatedAt,
+	}
+}
+
+type campaignResolver struct{ *graphQLResolver }
+
+func (r campaignResolver) Links(ctx context.Context, campaign *graph.Campaign, first *int32, after *string) (*graph.LinkConnection, error) {
+	caller, err := callerFrom(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	pageReq, err := pageRequest(repository.LinkListSpec, first, after)
+	if err != nil {
+		return nil, err
+	}
+	page, err := r.campaigns.Links(ctx, caller.accountID, campaign.ID, pageReq)
+	if err != nil {
+		return nil, serviceError(err, "Failed to list campaign links")
+	}
+	conn := &graph.LinkConnection{
+		Nodes:    make([]*graph.Link, len(page.Items)),
+		PageInfo: newPageInfo(page.PageInfo),
+	}
+	for i := range page.Items {
+		conn.Nodes[i] = r.link(&page.Items[i])
+	}
+	return conn, nil
+}
+
+func (r campaignResolver) Stats(ctx context.Context, campaign *graph.Campaign, from, to *time.Time, granularity, traffic *string) (*graph.CampaignStats, error) {
+	caller, err := callerFrom(ctx, models.ScopeStatsRead)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := r.campaigns.Stats(ctx, caller.accountID, campaign.ID, statsQuery(from, to, granularity, traffic))
+	if err != nil {
+		return nil, serviceError(err, "Failed to retrieve stats")
+	}
+	return &graph.CampaignStats{
+		LinkCount:    count(int64(stats.LinkCount)),
+		Clicks:       count(stats.ClickCount),
+		BotClicks:    count(stats.BotClickCount),
+		From:         stats.From,
+		To:           stats.To,
+		Granularity:  stats.Granularity,
+		Traffic:      stats.Traffic,
+		Analytics:    stats.Analytics,
+		Timeseries:   buckets(stats.TimeSeries),
+		TopReferrers: countEntries(stats.TopReferrers),
+		TopCountries: countEntries(stats.TopCountries),
+		TopCities:    cityCounts(stats.TopCities),
+		TopLinks:     countEntries(stats.TopLinks),
+	}, nil
+}
+
+func buckets(series []models.TimeBucket) []*graph.TimeBucket {
+	out := make([]*graph.TimeBucket, len(series))
+	for i, b := range series {
+		out[i] = &graph.TimeBucket{Start: b.Start, Clicks: count(b.Clicks)}
+	}
+	return out
+}
+
+func countEntries(entries []models.CountEntry) []*graph.CountEntry {
+	out := make([]*graph.CountEntry, len(entries))
+	for i, e := range entries {
+		out[i] = &graph.CountEntry{Key: e.Key, Clicks: count(e.Clicks)}
+	}
+	return out
+}
+
+func cityCounts(counts []models.CityCount) []*graph.CityCount {
+	out := make([]*graph.CityCount, len(counts))
+	for i, c := range counts {
+		out[i] = &graph.CityCount{Country: c.Country, City: c.City, Clicks: count(c.Clicks)}
+	}
+	return out
+}
+
+func newPageInfo(info repository.PageInfo) *graph.PageInfo {
+	return &graph.PageInfo{
+		EndCursor:     optionalString(info.NextCursor),
+		HasNextPage:   info.HasMore,
+		TotalEstimate: count(info.TotalEstimate),
+	}
+}
+
+// pageRequest builds the page request of a list with spec from the cursor
+// arguments of list fields, in the list's default order
+func pageRequest(spec *repository.ListSpec, first *int32, after *string) (repository.PageRequest, error) {
+	var req repository.PageRequest
+	if first != nil {
+		if *first < 1 {
+			return req, invalidArgument("first", validators.CodeOutOfRange, "first must be a positive integer")
+		}
+		req.Limit = int(*first)
+	}
+	if after != nil {
+		req.Cursor = *after
+		if err := spec.Check(req); err != nil {
+			return req, invalidArgument("after", validators.CodeInvalid, "Invalid cursor")
+		}
+	}
+	return req, nil
+}
+
+// statsQuery builds a stats query from the stats range arguments, as on the
+// REST stats endpoints
+func statsQuery(from, to *time.Time, granularity, traffic *string) services.StatsQuery {
+	query := services.StatsQuery{
+		Granularity: stringArg(granularity),
+		Traffic:     repository.TrafficFilter(stringArg(traffic)),
+	}
+	if from != nil {
+		query.From = *from
+	}
+	if to != nil {
+		query.To = *to
+	}
+	return query
+}
+
+// validationError reports the first failed check of a validators.Errors
+func validationError(err error) error {
+	var errs validators.Errors
+	if errors.As(err, &errs) && len(errs) > 0 {
+		return invalidArgument(errs[0].Field, errs[0].Code, errs[0].Message)
+	}
+	return invalidArgument("", "", err.Error())
+}
+
+func domainArg(domain *string) string {
+	return strings.ToLower(strings.TrimSpace(stringArg(domain)))
+}
+
+func stringArg(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// count fits a counter into a GraphQL Int, capping it at the largest value
+func count(n int64) int32 {
+	if n > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int32(n)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+)
+
+type HealthHandler struct {
+	healthService *services.HealthService
+	instanceID    string
+}
+
+func NewHealthHandler(healthService *services.HealthService, instanceID string) *HealthHandler {
+	return &HealthHandler{
+		healthService: healthService,
+		instanceID:    instanceID,
+	}
+}
+
+// ReadinessResponse reports overall readiness, each dependency's state and
+// the replica that checked them
+type ReadinessResponse struct {
+	Status       string                               `json:"status"`
+	Instance     string                               `json:"instance"`
+	Dependencies map[string]services.DependencyStatus `json:"dependencies"`
+}
+
+// Livez handles GET /livez
+// The process is alive as long as it can answer
+func (h *HealthHandler) Livez(c *gin.Context) {
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz handles GET /readyz
+// Returns 503 while a required dependency is down and reports "degraded"
+// when only optional ones are
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	ready, dependencies := h.healthService.Check(c.Request.Context())
+	response := ReadinessResponse{Status: "ok", Instance: h.instanceID, Dependencies: dependencies}
+	code := http.StatusOK
+	if !ready {
+		response.Status = "unavailable"
+		code = http.StatusServiceUnavailable
+	} else {
+		for _, dep := range dependencies {
+			if dep.Status == services.DependencyDown {
+				response.Status = "degraded"
+			}
+		}
+	}
+	utils.RespondWithJSON(c, code, response)
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/middleware"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+)
+
+// IntegrationHandler serves endpoints tailored to third-party plugins
+type IntegrationHandler struct {
+	urlService *services.URLService
+	links      *Links
+}
+
+func NewIntegrationHandler(urlService *services.URLService, links *Links) *IntegrationHandler {
+	return &IntegrationHandler{
+		urlService: urlService,
+		links:      links,
+	}
+}
+
+// CMSLinkRequest is sent by a CMS plugin whenever a post is published
+type CMSLinkRequest struct {
+	URL    string `json:"url" binding:"required,url"`
+	Slug   string `json:"slug" binding:"required"`
+	Title  string `json:"title,omitempty"`
+	Domain string `json:"domain,omitempty"`
+}
+
+// Validate checks the destination URL and slug beyond the struct tags
+func (r *CMSLinkRequest) Validate() error {
+	var errs validators.Errors
+	errs.CheckURL("url", r.URL)
+	errs.CheckShortCode("slug", r.Slug)
+	errs.CheckLength("title", r.Title, validators.MaxTitleLength)
+	return errs.Err()
+}
+
+type CMSLinkResponse struct {
+	ShortURL    string `json:"short_url"`
+	ShortCode   string `json:"short_code"`
+	OriginalURL string `json:"original_url"`
+	QRURL       string `json:"qr_url"`
+	Created     bool   `json:"created"`
+}
+
+// UpsertCMSLink idempotently creates or repoints the account's link for a post slug
+func (h *IntegrationHandler) UpsertCMSLink(c *gin.Context) {
+	var req CMSLinkRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	shortURL, created, err := h.urlService.UpsertBySlug(c.Request.Context(), middleware.AccountID(c), req.Domain, req.Slug, req.URL, req.Title, middleware.ClientIP(c))
+	if err != nil {
+		if errors.Is(err, services.ErrURLNotAllowed) {
+			respondInvalidField(c, validators.CodeURLNotAllowed, "url", err.Error())
+			return
+		}
+		switch err {
+		case services.ErrInvalidURL:
+			respondInvalidField(c, validators.CodeInvalidURL, "url", "Invalid URL")
+		case services.ErrInvalidAlias:
+			respondInvalidField(c, validators.CodeInvalidAlias, "slug", "Slug must be 3-32 letters, digits, '-' or '_' and not a reserved word")
+		case services.ErrAliasTaken:
+			utils.RespondWithErrorCode(c, http.StatusConflict, utils.ErrCodeAliasTaken, "Slug is already taken")
+		case services.ErrInvalidDomain, services.ErrDomainNotFound:
+			utils.RespondWithErrorCode(c, http.StatusBadRequest, utils.ErrCodeUnknownDomain, "Unknown domain")
+		case services.ErrDomainNotVerified:
+			utils.RespondWithErrorCode(c, http.StatusBadRequest, utils.ErrCodeDomainNotVerified, "Domain is not verified")
+		case services.ErrServiceUnavailable:
+			utils.RespondWithError(c, http.StatusServiceUnavailable, "Service temporarily unavailable")
+		default:
+			respondError(c, err, "Failed to save link")
+		}
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	utils.RespondWithJSON(c, status, CMSLinkResponse{
+		ShortURL:    h.links.Short(shortURL.Domain, shortURL.ShortCode),
+		ShortCode:   shortURL.ShortCode,
+		OriginalURL: shortURL.OriginalURL,
+		QRURL:       h.links.QR(shortURL.Domain, shortURL.ShortCode),
+		Created:     created,
+	})
+}
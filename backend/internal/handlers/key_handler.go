@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
 )
 
 type KeyHandler struct {
@@ -25,11 +26,10 @@ type GenerateResponse struct {
 // This generates a new short code
 func (h *KeyHandler) GenerateKey(c *gin.Context) {
 	shortCode := h.keyService.GenerateShortCode()
-	
+
 	response := GenerateResponse{
 		ShortCode: shortCode,
 	}
-	
-	c.JSON(http.StatusOK, response)
-}
 
+	utils.RespondWithJSON(c, http.StatusOK, response)
+}
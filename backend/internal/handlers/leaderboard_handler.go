@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/middleware"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+)
+
+// defaultLeaderboardSize is used when ?limit= is omitted
+const defaultLeaderboardSize = 10
+
+type LeaderboardHandler struct {
+	leaderboardService *services.LeaderboardService
+	links              *Links
+}
+
+func NewLeaderboardHandler(leaderboardService *services.LeaderboardService, links *Links) *LeaderboardHandler {
+	return &LeaderboardHandler{
+		leaderboardService: leaderboardService,
+		links:              links,
+	}
+}
+
+type LeaderboardEntryResponse struct {
+	Rank      int    `json:"rank"`
+	Domain    string `json:"domain,omitempty"`
+	ShortCode string `json:"short_code"`
+	ShortURL  string `json:"short_url"`
+	Clicks    int64  `json:"clicks"`
+}
+
+type LeaderboardResponse struct {
+	Period  string                     `json:"period"`
+	Entries []LeaderboardEntryResponse `json:"entries"`
+}
+
+// AccountLeaderboard handles GET /api/v1/account/leaderboard
+// Returns the account's most clicked links today (?period=day) or this week
+func (h *LeaderboardHandler) AccountLeaderboard(c *gin.Context) {
+	h.respond(c, middleware.AccountID(c))
+}
+
+// GlobalLeaderboard handles GET /admin/leaderboard
+// Same as AccountLeaderboard across all links
+func (h *LeaderboardHandler) GlobalLeaderboard(c *gin.Context) {
+	h.respond(c, "")
+}
+
+func (h *LeaderboardHandler) respond(c *gin.Context, accountID string) {
+	period := c.DefaultQuery("period", services.LeaderboardDay)
+	limit := defaultLeaderboardSize
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			utils.RespondWithError(c, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = parsed
+	}
+
+	entries, err := h.leaderboardService.Top(c.Request.Context(), accountID, period, limit)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidLeaderboardPeriod) {
+			respondError(c, err, "")
+			return
+		}
+		utils.RespondWithErrorCode(c, http.StatusServiceUnavailable, utils.ErrCodeLeaderboardUnavailable, "Leaderboard unavailable")
+		return
+	}
+
+	response := LeaderboardResponse{Period: period, Entries: make([]LeaderboardEntryResponse, 0, len(entries))}
+	for i, entry := range entries {
+		response.Entries = append(response.Entries, LeaderboardEntryResponse{
+			Rank:      i + 1,
+			Domain:    entry.Domain,
+			ShortCode: entry.ShortCode,
+			ShortURL:  h.links.Short(entry.Domain, entry.ShortCode),
+			Clicks:    entry.Clicks,
+		})
+	}
+	utils.RespondWithJSON(c, http.StatusOK, response)
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+)
+
+// Links builds the public URLs of short codes
+type Links struct {
+	baseURL string
+}
+
+// NewLinks creates a builder for links on baseURL, the public origin of the
+// default short domain (e.g. https://sho.rt)
+func NewLinks(baseURL string) *Links {
+	return &Links{baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// Short is the public URL of a short code on domain ("" for the default domain)
+func (l *Links) Short(domain, shortCode string) string {
+	if domain != "" {
+		return fmt.Sprintf("https://%s/%s", domain, shortCode)
+	}
+	return fmt.Sprintf("%s/%s", l.baseURL, shortCode)
+}
+
+// Link is the public URL of shortURL; sandbox links are served under /sandbox/
+func (l *Links) Link(shortURL *models.ShortURL) string {
+	if shortURL.Sandbox {
+		return l.Short(shortURL.Domain, "sandbox/"+shortURL.ShortCode)
+	}
+	return l.Short(shortURL.Domain, shortURL.ShortCode)
+}
+
+// QR is the URL of a short code's QR image
+func (l *Links) QR(domain, shortCode string) string {
+	if domain != "" {
+		return fmt.Sprintf("%s/api/v1/%s/qr?domain=%s", l.baseURL, shortCode, url.QueryEscape(domain))
+	}
+	return fmt.Sprintf("%s/api/v1/%s/qr", l.baseURL, shortCode)
+}
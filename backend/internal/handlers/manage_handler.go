@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+)
+
+// ManageHandler serves the links of anonymous users, who hold a management
+// token for each link they created instead of an API key
+type ManageHandler struct {
+	manageService *services.ManageService
+	urlService    *services.URLService
+}
+
+func NewManageHandler(manageService *services.ManageService, urlService *services.URLService) *ManageHandler {
+	return &ManageHandler{
+		manageService: manageService,
+		urlService:    urlService,
+	}
+}
+
+// GetLink handles GET /api/v1/manage/:token
+// Answers with the link and its stats, taking the query parameters of link stats
+func (h *ManageHandler) GetLink(c *gin.Context) {
+	claims, err := h.manageService.VerifyToken(c.Param("token"))
+	if err != nil {
+		respondError(c, err, "Failed to verify management token")
+		return
+	}
+	query, err := parseStatsQuery(c)
+	if err != nil {
+		utils.RespondWithErrorCode(c, http.StatusBadRequest, utils.ErrCodeInvalidQuery, err.Error())
+		return
+	}
+	stats, err := h.urlService.GetManagedStats(c.Request.Context(), claims, query)
+	if err != nil {
+		respondError(c, err, "Failed to retrieve stats")
+		return
+	}
+	// The token is the only credential, so shared caches must not keep it
+	c.Header("Cache-Control", "no-store")
+	utils.RespondWithJSON(c, http.StatusOK, stats)
+}
+
+// DeleteLink handles DELETE /api/v1/manage/:token
+func (h *ManageHandler) DeleteLink(c *gin.Context) {
+	claims, err := h.manageService.VerifyToken(c.Param("token"))
+	if err != nil {
+		respondError(c, err, "Failed to verify management token")
+		return
+	}
+	shortURL, err := h.urlService.DeleteManagedURL(c.Request.Context(), claims)
+	if err != nil {
+		respondError(c, err, "Failed to delete URL")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, shortURL)
+}
+
+// ManageTokenResponse carries a newly issued management token
+type ManageTokenResponse struct {
+	ManagementToken string `json:"management_token"`
+}
+
+// RotateToken handles POST /api/v1/manage/:token/rotate
+// Issues a new token for the link and revokes every token issued before,
+// for when one may have leaked
+func (h *ManageHandler) RotateToken(c *gin.Context) {
+	claims, err := h.manageService.VerifyToken(c.Param("token"))
+	if err != nil {
+		respondError(c, err, "Failed to verify management token")
+		return
+	}
+	shortURL, err := h.urlService.RotateManagedToken(c.Request.Context(), claims)
+	if err != nil {
+		respondError(c, err, "Failed to rotate management token")
+		return
+	}
+	token, err := h.manageService.IssueToken(shortURL)
+	if err != nil {
+		respondError(c, err, "Failed to issue management token")
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	utils.RespondWithJSON(c, http.StatusOK, ManageTokenResponse{ManagementToken: token})
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/middleware"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+)
+
+// OrganizationHandler manages organizations on behalf of the caller's own
+// account; membership never depends on the X-Organization workspace
+type OrganizationHandler struct {
+	orgService *services.OrganizationService
+}
+
+func NewOrganizationHandler(orgService *services.OrganizationService) *OrganizationHandler {
+	return &OrganizationHandler{
+		orgService: orgService,
+	}
+}
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type SetMemberRequest struct {
+	Role string `json:"role" binding:"required,oneof=admin editor viewer"`
+}
+
+type CreateOrganizationKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// CreateOrganization handles POST /api/v1/orgs
+// The caller becomes the organization's first admin
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	var req CreateOrganizationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	org, err := h.orgService.Create(c.Request.Context(), middleware.CallerID(c), req.Name)
+	if err != nil {
+		respondError(c, err, "Failed to create organization")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusCreated, org)
+}
+
+// ListOrganizations handles GET /api/v1/orgs
+func (h *OrganizationHandler) ListOrganizations(c *gin.Context) {
+	orgs, err := h.orgService.List(c.Request.Context(), middleware.CallerID(c))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to list organizations")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"organizations": orgs})
+}
+
+// ListMembers handles GET /api/v1/orgs/:id/members
+func (h *OrganizationHandler) ListMembers(c *gin.Context) {
+	members, err := h.orgService.Members(c.Request.Context(), c.Param("id"), middleware.CallerID(c))
+	if err != nil {
+		respondError(c, err, "Failed to list members")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"members": members})
+}
+
+// SetMember handles PUT /api/v1/orgs/:id/members/:account
+// Adds the account or changes its role; admins only
+func (h *OrganizationHandler) SetMember(c *gin.Context) {
+	var req SetMemberRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	membership, err := h.orgService.SetMember(c.Request.Context(), c.Param("id"), middleware.CallerID(c), c.Param("account"), req.Role)
+	if err != nil {
+		respondError(c, err, "Failed to save member")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, membership)
+}
+
+// RemoveMember handles DELETE /api/v1/orgs/:id/members/:account; admins only
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	if err := h.orgService.RemoveMember(c.Request.Context(), c.Param("id"), middleware.CallerID(c), c.Param("account")); err != nil {
+		respondError(c, err, "Failed to remove member")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// CreateKey handles POST /api/v1/orgs/:id/keys
+// The key acts as the organization itself; the plaintext is only returned here
+func (h *OrganizationHandler) CreateKey(c *gin.Context) {
+	var req CreateOrganizationKeyRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	plaintext, key, err := h.orgService.IssueKey(c.Request.Context(), c.Param("id"), middleware.CallerID(c), req.Name, req.Scopes)
+	if err != nil {
+		respondError(c, err, "Failed to create API key")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusCreated, CreateAPIKeyResponse{
+		Key:    plaintext,
+		APIKey: key,
+	})
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+)
+
+// parsePageRequest reads ?limit=, ?cursor= and ?sort= shared by all list
+// endpoints, plus one query parameter per filter of spec
+func parsePageRequest(c *gin.Context, spec *repository.ListSpec) (repository.PageRequest, bool) {
+	req := repository.PageRequest{Cursor: c.Query("cursor"), Sort: c.Query("sort")}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			utils.RespondWithError(c, http.StatusBadRequest, "limit must be a positive integer")
+			return req, false
+		}
+		req.Limit = limit
+	}
+	for name := range spec.Filters {
+		if value := c.Query(name); value != "" {
+			if req.Filters == nil {
+				req.Filters = map[string]string{}
+			}
+			req.Filters[name] = value
+		}
+	}
+	if err := spec.Check(req); err != nil {
+		var queryErr *repository.QueryError
+		if errors.As(err, &queryErr) {
+			respondInvalidField(c, validators.CodeInvalid, queryErr.Param, queryErr.Message)
+		} else {
+			utils.RespondWithErrorCode(c, http.StatusBadRequest, utils.ErrCodeInvalidCursor, "Invalid cursor")
+		}
+		return req, false
+	}
+	return req, true
+}
+
+// respondWithPage writes a page using the standard pagination envelope
+// With the {data, error, meta} format the page info moves under meta.pagination
+func respondWithPage[T any](c *gin.Context, page *repository.Page[T]) {
+	if utils.GetResponseFormat(c).Envelope {
+		utils.RespondWithMeta(c, http.StatusOK, page.Items, map[string]interface{}{
+			"pagination": page.PageInfo,
+		})
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, page)
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+)
+
+// ProvisioningHandler lets infrastructure-as-code tools manage tenants
+// declaratively: every resource is addressed by a caller-chosen stable ID
+// and PUT brings it to the state in the body, so repeating a request is
+// harmless. PUT answers 201 when it created the resource and 200 otherwise
+type ProvisioningHandler struct {
+	orgService    *services.OrganizationService
+	apiKeyService *services.APIKeyService
+	domainService *services.DomainService
+	quotaService  *services.QuotaService
+}
+
+func NewProvisioningHandler(orgService *services.OrganizationService, apiKeyService *services.APIKeyService, domainService *services.DomainService, quotaService *services.QuotaService) *ProvisioningHandler {
+	return &ProvisioningHandler{
+		orgService:    orgService,
+		apiKeyService: apiKeyService,
+		domainService: domainService,
+		quotaService:  quotaService,
+	}
+}
+
+// PutOrganizationRequest is the desired state of a provisioned organization
+type PutOrganizationRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+	// Admins are made admins of the organization; the first one is its
+	// creator. Members added through the API are left alone
+	Admins []string `json:"admins" binding:"required,min=1,dive,required"`
+}
+
+// PutAPIKeyRequest is the desired state of a provisioned API key
+type PutAPIKeyRequest struct {
+	Name           string            `json:"name"`
+	Scopes         []string          `json:"scopes,omitempty"`
+	Plan           string            `json:"plan,omitempty"`
+	LinkQuota      *models.LinkQuota `json:"link_quota,omitempty"`
+	AllowedOrigins []string          `json:"allowed_origins,omitempty"`
+	// Sandbox is fixed when the key is issued
+	Sandbox bool `json:"sandbox,omitempty"`
+}
+
+// Validate checks the origin patterns
+func (r *PutAPIKeyRequest) Validate() error {
+	var errs validators.Errors
+	errs.CheckOrigins("allowed_origins", r.AllowedOrigins)
+	return errs.Err()
+}
+
+// externalID reads the path parameter name as an external ID, writing the
+// error response if it isn't one
+func externalID(c *gin.Context, name string) (string, bool) {
+	id := c.Param(name)
+	if !validators.IsValidExternalID(id) {
+		respondInvalidField(c, validators.CodeInvalid, name, name+" must be 1-128 letters, digits, '.', '_', ':' or '-', starting with a letter or digit")
+		return "", false
+	}
+	return id, true
+}
+
+// organization resolves the :org path parameter, writing the error
+// response if there is no such organization
+func (h *ProvisioningHandler) organization(c *gin.Context) (*models.Organization, bool) {
+	id, ok := externalID(c, "org")
+	if !ok {
+		return nil, false
+	}
+	org, err := h.orgService.GetProvisioned(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "Failed to look up organization")
+		return nil, false
+	}
+	return org, true
+}
+
+// putStatus is the status of a PUT that did or didn't create its resource
+func putStatus(created bool) int {
+	if created {
+		return http.StatusCreated
+	}
+	return http.StatusOK
+}
+
+// PutOrganization handles PUT /admin/organizations/:org
+func (h *ProvisioningHandler) PutOrganization(c *gin.Context) {
+	id, ok := externalID(c, "org")
+	if !ok {
+		return
+	}
+	var req PutOrganizationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	org, created, err := h.orgService.Provision(c.Request.Context(), id, req.Name, req.Admins)
+	if err != nil {
+		respondError(c, err, "Failed to provision organization")
+		return
+	}
+	utils.RespondWithJSON(c, putStatus(created), org)
+}
+
+// GetOrganization handles GET /admin/organizations/:org
+func (h *ProvisioningHandler) GetOrganization(c *gin.Context) {
+	org, ok := h.organization(c)
+	if !ok {
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, org)
+}
+
+// PutAPIKey handles PUT /admin/organizations/:org/api-keys/:key
+// The plaintext key is only returned by the request that issues the key
+func (h *ProvisioningHandler) PutAPIKey(c *gin.Context) {
+	org, ok := h.organization(c)
+	if !ok {
+		return
+	}
+	keyID, ok := externalID(c, "key")
+	if !ok {
+		return
+	}
+	var req PutAPIKeyRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if !checkQuota(c, h.quotaService, req.Plan, req.LinkQuota) {
+		return
+	}
+	plaintext, key, created, err := h.apiKeyService.PutKey(c.Request.Context(), org.AccountID, keyID, services.APIKeySpec{
+		Name:           req.Name,
+		Scopes:         req.Scopes,
+		Plan:           req.Plan,
+		LinkQuota:      req.LinkQuota,
+		AllowedOrigins: req.AllowedOrigins,
+		Sandbox:        req.Sandbox,
+	})
+	if err != nil {
+		respondError(c, err, "Failed to provision API key")
+		return
+	}
+	if created {
+		utils.RespondWithJSON(c, http.StatusCreated, CreateAPIKeyResponse{
+			Key:    plaintext,
+			APIKey: key,
+		})
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, key)
+}
+
+// GetAPIKey handles GET /admin/organizations/:org/api-keys/:key
+func (h *ProvisioningHandler) GetAPIKey(c *gin.Context) {
+	org, ok := h.organization(c)
+	if !ok {
+		return
+	}
+	keyID, ok := externalID(c, "key")
+	if !ok {
+		return
+	}
+	key, err := h.apiKeyService.GetProvisionedKey(c.Request.Context(), org.AccountID, keyID)
+	if err != nil {
+		respondError(c, err, "Failed to look up API key")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, key)
+}
+
+// DeleteAPIKey handles DELETE /admin/organizations/:org/api-keys/:key
+// Revoking a key that is already gone succeeds too
+func (h *ProvisioningHandler) DeleteAPIKey(c *gin.Context) {
+	org, ok := h.organization(c)
+	if !ok {
+		return
+	}
+	keyID, ok := externalID(c, "key")
+	if !ok {
+		return
+	}
+	if _, err := h.apiKeyService.RevokeProvisionedKey(c.Request.Context(), org.AccountID, keyID); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to revoke API key")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// PutDomain handles PUT /admin/organizations/:org/domains/:host
+// The domain still has to be verified through its TXT record
+func (h *ProvisioningHandler) PutDomain(c *gin.Context) {
+	org, ok := h.organization(c)
+	if !ok {
+		return
+	}
+	domain, created, err := h.domainService.ProvisionDomain(c.Request.Context(), org.AccountID, c.Param("host"))
+	if err != nil {
+		respondError(c, err, "Failed to provision domain")
+		return
+	}
+	utils.RespondWithJSON(c, putStatus(created), domain)
+}
+
+// GetDomain handles GET /admin/organizations/:org/domains/:host
+func (h *ProvisioningHandler) GetDomain(c *gin.Context) {
+	org, ok := h.organization(c)
+	if !ok {
+		return
+	}
+	domain, err := h.domainService.ProvisionedDomain(c.Request.Context(), org.AccountID, c.Param("host"))
+	if err != nil {
+		respondError(c, err, "Failed to look up domain")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, domain)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"text/template"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+)
+
+// defaultRobotsTxt keeps crawlers off the API and leaves short links open;
+// links opt out one by one with noindex
+var defaultRobotsTxt = `User-agent: *
+Disallow: /api/
+Disallow: /admin/
+Disallow: /graphql
+Disallow: /embed/
+Disallow: /integrations/
+{{if .Sitemap}}
+Sitemap: https://{{.Host}}/sitemap.xml
+{{end}}`
+
+// RobotsTxt renders /robots.txt
+type RobotsTxt struct {
+	page *template.Template
+}
+
+// RobotsTxtData is what a robots.txt template is rendered with
+type RobotsTxtData struct {
+	Host string
+	// Sitemap is set on branded domains, which serve /sitemap.xml
+	Sitemap bool
+}
+
+// NewRobotsTxt loads the robots.txt template from templatePath, or uses the
+// built-in one when it is empty
+func NewRobotsTxt(templatePath string) (*RobotsTxt, error) {
+	raw := defaultRobotsTxt
+	if templatePath != "" {
+		file, err := os.ReadFile(templatePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read robots.txt template: %w", err)
+		}
+		raw = string(file)
+	}
+	page, err := template.New("robots").Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse robots.txt template: %w", err)
+	}
+	return &RobotsTxt{page: page}, nil
+}
+
+// Render writes robots.txt for data's host, cacheable for an hour
+func (r *RobotsTxt) Render(c *gin.Context, data RobotsTxtData) {
+	var body bytes.Buffer
+	if err := r.page.Execute(&body, data); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to render robots.txt")
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", body.Bytes())
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/middleware"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TransferHandler hands links between accounts and organizations. Both
+// sides act as their workspace, so an organization's links are offered
+// and accepted by members working in it
+type TransferHandler struct {
+	transfers *services.TransferService
+}
+
+func NewTransferHandler(transfers *services.TransferService) *TransferHandler {
+	return &TransferHandler{
+		transfers: transfers,
+	}
+}
+
+// TransferLinkRequest names the recipient: an account or an organization
+type TransferLinkRequest struct {
+	ToAccount      string `json:"to_account,omitempty"`
+	ToOrganization string `json:"to_organization,omitempty"`
+}
+
+// Validate checks exactly one recipient is given
+func (r *TransferLinkRequest) Validate() error {
+	if (r.ToAccount == "") == (r.ToOrganization == "") {
+		return validators.NewError(validators.CodeRequired, "to_account", "one of to_account or to_organization is required")
+	}
+	return nil
+}
+
+// TransferLink handles POST /api/v1/:code/transfer
+// The link stays with the caller until the recipient accepts
+func (h *TransferHandler) TransferLink(c *gin.Context) {
+	var req TransferLinkRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	transfer, err := h.transfers.Offer(c.Request.Context(), services.TransferOffer{
+		Domain:         linkDomain(c),
+		ShortCode:      c.Param("code"),
+		From:           middleware.AccountID(c),
+		OfferedBy:      middleware.CallerID(c),
+		ToAccount:      req.ToAccount,
+		ToOrganization: req.ToOrganization,
+	})
+	if err != nil {
+		respondError(c, err, "Failed to transfer link")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusAccepted, transfer)
+}
+
+// ListTransfers handles GET /api/v1/transfers
+// ?direction=incoming (the default) lists offers to the caller's workspace,
+// outgoing those it made
+func (h *TransferHandler) ListTransfers(c *gin.Context) {
+	direction := c.DefaultQuery("direction", services.TransfersIncoming)
+	if direction != services.TransfersIncoming && direction != services.TransfersOutgoing {
+		respondInvalidField(c, validators.CodeInvalid, "direction", "direction must be incoming or outgoing")
+		return
+	}
+	pageReq, ok := parsePageRequest(c, repository.CreatedListSpec)
+	if !ok {
+		return
+	}
+	page, err := h.transfers.List(c.Request.Context(), middleware.AccountID(c), direction, pageReq)
+	if err != nil {
+		respondError(c, err, "Failed to list transfers")
+		return
+	}
+	respondWithPage(c, page)
+}
+
+// AcceptTransfer handles POST /api/v1/transfers/:id/accept
+func (h *TransferHandler) AcceptTransfer(c *gin.Context) {
+	h.respond(c, h.transfers.Accept, "Failed to accept transfer")
+}
+
+// DeclineTransfer handles POST /api/v1/transfers/:id/decline
+func (h *TransferHandler) DeclineTransfer(c *gin.Context) {
+	h.respond(c, h.transfers.Decline, "Failed to decline transfer")
+}
+
+// CancelTransfer handles POST /api/v1/transfers/:id/cancel
+func (h *TransferHandler) CancelTransfer(c *gin.Context) {
+	h.respond(c, h.transfers.Cancel, "Failed to cancel transfer")
+}
+
+type transferAction func(ctx context.Context, accountID, caller string, id primitive.ObjectID) (*models.LinkTransfer, error)
+
+func (h *TransferHandler) respond(c *gin.Context, action transferAction, fallback string) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		utils.RespondWithErrorCode(c, http.StatusNotFound, utils.ErrCodeTransferNotFound, "Transfer not found")
+		return
+	}
+	transfer, err := action(c.Request.Context(), middleware.AccountID(c), middleware.CallerID(c), id)
+	if err != nil {
+		respondError(c, err, fallback)
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, transfer)
+}
@@ -1,114 +1,1277 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"maps"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/geoip"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/middleware"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
 	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+	"github.com/skip2/go-qrcode"
 )
 
+// variantCookieMaxAge is how long a visitor keeps its A/B variant
+const variantCookieMaxAge = 30 * 24 * time.Hour
+
+// clickStreamKeepalive is how often an idle click stream sends a comment
+const clickStreamKeepalive = 15 * time.Second
+
 type URLHandler struct {
-	urlService *services.URLService
+	urlService    *services.URLService
+	sandbox       *services.URLService
+	domainService *services.DomainService
+	botFilter     *services.BotFilter
+	reserved      *validators.ReservedWords
+	campaigns     *services.CampaignService
+	links         *Links
+	rollouts      *services.Rollouts
+	errorPages    *ErrorPages
+	cloakPages    *CloakPages
+	geo           *geoip.Database
+	manage        *services.ManageService
+	// statsMaxAge is how long clients may reuse a stats response without revalidating
+	statsMaxAge time.Duration
 }
 
-func NewURLHandler(urlService *services.URLService) *URLHandler {
+func NewURLHandler(urlService, sandbox *services.URLService, domainService *services.DomainService, botFilter *services.BotFilter, reserved *validators.ReservedWords, campaigns *services.CampaignService, links *Links, rollouts *services.Rollouts, errorPages *ErrorPages, cloakPages *CloakPages, geo *geoip.Database, manage *services.ManageService, statsMaxAge time.Duration) *URLHandler {
 	return &URLHandler{
-		urlService: urlService,
+		urlService:    urlService,
+		sandbox:       sandbox,
+		domainService: domainService,
+		botFilter:     botFilter,
+		reserved:      reserved,
+		campaigns:     campaigns,
+		links:         links,
+		rollouts:      rollouts,
+		errorPages:    errorPages,
+		cloakPages:    cloakPages,
+		geo:           geo,
+		manage:        manage,
+		statsMaxAge:   statsMaxAge,
+	}
+}
+
+// service is the service for the request's links: the sandbox for sandbox
+// API keys, else production
+func (h *URLHandler) service(c *gin.Context) *services.URLService {
+	if middleware.Sandbox(c) {
+		return h.sandbox
 	}
+	return h.urlService
 }
 
+// ShortenURLRequest is also accepted as an HTML form; nested device_rules and
+// utm fields are flattened to ios_url, utm_source and so on
 type ShortenURLRequest struct {
-	URL       string `json:"url" binding:"required,url"`
-	ExpiresIn *int   `json:"expires_in,omitempty"`
+	URL            string                `json:"url" form:"url" binding:"required,url"`
+	Alias          string                `json:"alias,omitempty" form:"alias"`
+	Domain         string                `json:"domain,omitempty" form:"domain"`
+	ExpiresIn      *int                  `json:"expires_in,omitempty" form:"expires_in"`
+	Title          string                `json:"title,omitempty" form:"title"`
+	FetchTitle     bool                  `json:"fetch_title,omitempty" form:"fetch_title"`
+	Notes          string                `json:"notes,omitempty" form:"notes"`
+	DeviceRules    *models.DeviceRules   `json:"device_rules,omitempty"`
+	UTM            *models.UTMParams     `json:"utm,omitempty"`
+	Indexable      bool                  `json:"indexable,omitempty" form:"indexable"`
+	NoIndex        bool                  `json:"noindex,omitempty" form:"noindex"`
+	Honeytoken     bool                  `json:"honeytoken,omitempty" form:"honeytoken"`
+	MaxClicks      int64                 `json:"max_clicks,omitempty" form:"max_clicks" binding:"gte=0"`
+	EdgeCacheTTL   *int64                `json:"edge_cache_ttl,omitempty" form:"edge_cache_ttl" binding:"omitempty,gte=0"`
+	CacheMaxAge    *int64                `json:"cache_max_age,omitempty" form:"cache_max_age" binding:"omitempty,gte=0"`
+	CampaignID     string                `json:"campaign_id,omitempty" form:"campaign_id"`
+	Variants       []models.Variant      `json:"variants,omitempty" form:"-"`
+	ActivateAt     *time.Time            `json:"activate_at,omitempty" form:"activate_at" time_format:"2006-01-02T15:04:05Z07:00"`
+	DeactivateAt   *time.Time            `json:"deactivate_at,omitempty" form:"deactivate_at" time_format:"2006-01-02T15:04:05Z07:00"`
+	FallbackURL    string                `json:"fallback_url,omitempty" form:"fallback_url"`
+	IPAccess       *models.IPAccess      `json:"ip_access,omitempty" form:"-"`
+	RateLimit      *models.LinkRateLimit `json:"rate_limit,omitempty" form:"-"`
+	Cloak          string                `json:"cloak,omitempty" form:"cloak"`
+	ForwardQuery   bool                  `json:"forward_query,omitempty" form:"forward_query"`
+	SignedQuery    bool                  `json:"signed_query,omitempty" form:"signed_query"`
+	RedirectStatus int                   `json:"redirect_status,omitempty" form:"redirect_status"`
+	Privacy        bool                  `json:"privacy,omitempty" form:"privacy"`
+	SingleUse      bool                  `json:"single_use,omitempty" form:"single_use"`
+}
+
+// Validate checks the destination URLs and alias beyond the struct tags
+func (r *ShortenURLRequest) Validate() error {
+	var errs validators.Errors
+	errs.CheckURL("url", r.URL)
+	errs.CheckShortCode("alias", r.Alias)
+	errs.CheckLength("title", r.Title, validators.MaxTitleLength)
+	errs.CheckLength("notes", r.Notes, validators.MaxNotesLength)
+	if r.ExpiresIn != nil && *r.ExpiresIn <= 0 {
+		errs.Add(validators.CodeOutOfRange, "expires_in", "expires_in must be a positive number of hours")
+	}
+	if r.DeviceRules != nil {
+		errs.CheckURL("device_rules.ios", r.DeviceRules.IOS)
+		errs.CheckURL("device_rules.android", r.DeviceRules.Android)
+		errs.CheckURL("device_rules.desktop", r.DeviceRules.Desktop)
+	}
+	checkVariants(&errs, r.Variants)
+	checkSchedule(&errs, r.ActivateAt, r.DeactivateAt, r.FallbackURL)
+	checkIPAccess(&errs, r.IPAccess)
+	checkRateLimit(&errs, r.RateLimit)
+	checkCloak(&errs, r.Cloak)
+	checkRedirectStatus(&errs, r.RedirectStatus)
+	checkNoIndex(&errs, r.Indexable, r.NoIndex)
+	if r.SingleUse && r.MaxClicks > 1 {
+		errs.Add(validators.CodeInvalid, "max_clicks", "single_use links redirect once; leave max_clicks out")
+	}
+	return errs.Err()
+}
+
+type UpdateURLRequest struct {
+	URL            string                `json:"url" binding:"required,url"`
+	ExpiresIn      *int                  `json:"expires_in,omitempty"`
+	Title          string                `json:"title,omitempty"`
+	Notes          string                `json:"notes,omitempty"`
+	Indexable      bool                  `json:"indexable,omitempty"`
+	NoIndex        bool                  `json:"noindex,omitempty"`
+	EdgeCacheTTL   *int64                `json:"edge_cache_ttl,omitempty" binding:"omitempty,gte=0"`
+	CacheMaxAge    *int64                `json:"cache_max_age,omitempty" binding:"omitempty,gte=0"`
+	Variants       []models.Variant      `json:"variants,omitempty"`
+	ActivateAt     *time.Time            `json:"activate_at,omitempty"`
+	DeactivateAt   *time.Time            `json:"deactivate_at,omitempty"`
+	FallbackURL    string                `json:"fallback_url,omitempty"`
+	IPAccess       *models.IPAccess      `json:"ip_access,omitempty"`
+	RateLimit      *models.LinkRateLimit `json:"rate_limit,omitempty"`
+	Cloak          string                `json:"cloak,omitempty"`
+	ForwardQuery   bool                  `json:"forward_query,omitempty"`
+	SignedQuery    *bool                 `json:"signed_query,omitempty"`
+	RedirectStatus int                   `json:"redirect_status,omitempty"`
+	Privacy        *bool                 `json:"privacy,omitempty"`
+	// fields are the names present in the body
+	fields []string
+}
+
+// Validate checks the destination URL beyond the struct tags
+func (r *UpdateURLRequest) Validate() error {
+	var errs validators.Errors
+	errs.CheckURL("url", r.URL)
+	errs.CheckLength("title", r.Title, validators.MaxTitleLength)
+	errs.CheckLength("notes", r.Notes, validators.MaxNotesLength)
+	checkVariants(&errs, r.Variants)
+	checkSchedule(&errs, r.ActivateAt, r.DeactivateAt, r.FallbackURL)
+	checkIPAccess(&errs, r.IPAccess)
+	checkRateLimit(&errs, r.RateLimit)
+	checkCloak(&errs, r.Cloak)
+	checkRedirectStatus(&errs, r.RedirectStatus)
+	checkNoIndex(&errs, r.Indexable, r.NoIndex)
+	if r.ExpiresIn != nil && *r.ExpiresIn <= 0 {
+		errs.Add(validators.CodeOutOfRange, "expires_in", "expires_in must be a positive number of hours")
+	}
+	return errs.Err()
+}
+
+func checkCloak(errs *validators.Errors, cloak string) {
+	if !models.ValidCloak(cloak) {
+		errs.Add(validators.CodeInvalid, "cloak", "cloak must be "+models.CloakFrame+" or "+models.CloakMeta)
+	}
+}
+
+func checkRedirectStatus(errs *validators.Errors, status int) {
+	if !models.ValidRedirectStatus(status) {
+		errs.Add(validators.CodeInvalid, "redirect_status", "redirect_status must be 301, 302, 307 or 308")
+	}
+}
+
+// checkVariants checks each A/B variant's fields; the service checks the split as a whole
+// checkNoIndex rejects a link both listed in its domain's sitemap and hidden
+// from search engines
+func checkNoIndex(errs *validators.Errors, indexable, noIndex bool) {
+	if indexable && noIndex {
+		errs.Add(validators.CodeInvalid, "noindex", "noindex can't be combined with indexable")
+	}
+}
+
+func checkVariants(errs *validators.Errors, variants []models.Variant) {
+	for i, variant := range variants {
+		prefix := fmt.Sprintf("variants[%d].", i)
+		if variant.Name == "" {
+			errs.Add(validators.CodeRequired, prefix+"name", prefix+"name is required")
+		}
+		if variant.URL == "" {
+			errs.Add(validators.CodeRequired, prefix+"url", prefix+"url is required")
+		}
+		errs.CheckURL(prefix+"url", variant.URL)
+		if variant.Weight < 1 {
+			errs.Add(validators.CodeOutOfRange, prefix+"weight", prefix+"weight must be at least 1")
+		}
+	}
+}
+
+// checkSchedule checks the activation window and fallback fields; the service
+// checks the fallback against the account's URL policy
+func checkSchedule(errs *validators.Errors, activateAt, deactivateAt *time.Time, fallbackURL string) {
+	if activateAt != nil && deactivateAt != nil && !deactivateAt.After(*activateAt) {
+		errs.Add(validators.CodeInvalid, "deactivate_at", "deactivate_at must be after activate_at")
+	}
+	errs.CheckURL("fallback_url", fallbackURL)
+}
+
+// checkRateLimit checks a link's redirect cap
+func checkRateLimit(errs *validators.Errors, limit *models.LinkRateLimit) {
+	if limit == nil {
+		return
+	}
+	if limit.Limit < 1 {
+		errs.Add(validators.CodeOutOfRange, "rate_limit.limit", "rate_limit.limit must be at least 1")
+	}
+	if limit.Window() == 0 {
+		errs.Add(validators.CodeInvalid, "rate_limit.period", "rate_limit.period must be "+models.RateLimitHour+" or "+models.RateLimitDay)
+	}
+	errs.CheckURL("rate_limit.retry_url", limit.RetryURL)
+}
+
+// checkIPAccess checks that every ip_access entry is an address or CIDR range
+func checkIPAccess(errs *validators.Errors, access *models.IPAccess) {
+	if access == nil {
+		return
+	}
+	checkIPRanges(errs, "ip_access.allow", access.Allow)
+	checkIPRanges(errs, "ip_access.deny", access.Deny)
+}
+
+func checkIPRanges(errs *validators.Errors, field string, ranges []string) {
+	for i, raw := range ranges {
+		if _, err := models.ParseIPRange(strings.TrimSpace(raw)); err != nil {
+			name := fmt.Sprintf("%s[%d]", field, i)
+			errs.Add(validators.CodeInvalidCIDR, name, name+" must be an IP address or CIDR range")
+		}
+	}
 }
 
 type ShortenResponse struct {
 	ShortURL    string  `json:"short_url"`
 	ShortCode   string  `json:"short_code"`
 	OriginalURL string  `json:"original_url"`
+	Title       string  `json:"title,omitempty"`
+	Notes       string  `json:"notes,omitempty"`
 	ExpiresAt   *string `json:"expires_at,omitempty"`
+	// ManagementToken is given once, to anonymous callers creating a link
+	ManagementToken string `json:"management_token,omitempty"`
+	// SigningKey is given to whoever creates a signed link; signed links are
+	// never reused, so that is always its owner
+	SigningKey string `json:"signing_key,omitempty"`
 }
 
 func (h *URLHandler) ShortenURL(c *gin.Context) {
 	var req ShortenURLRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !checkRequest(c, &req, bindShortenRequest(c, &req)) {
 		return
 	}
-	var expiresIn *time.Duration
-	if req.ExpiresIn != nil {
-		duration := time.Duration(*req.ExpiresIn) * time.Hour
-		expiresIn = &duration
+	shortURL, token, ok := h.shorten(c, &req)
+	if !ok {
+		return
 	}
-	shortURL, err := h.urlService.ShortenURL(c.Request.Context(), req.URL, expiresIn)
+	resp := newShortenResponse(h.links, shortURL)
+	resp.ManagementToken = token
+	utils.RespondWithJSON(c, http.StatusOK, resp)
+}
+
+// ShortenURLQuery handles GET /api/v1/shorten?url=...
+// For scripts and tools that can't easily POST JSON; the query takes the
+// same fields as a form post. Responds with the bare short URL when the
+// client accepts text/plain, else the usual JSON. Errors are always JSON
+func (h *URLHandler) ShortenURLQuery(c *gin.Context) {
+	var req ShortenURLRequest
+	if !checkRequest(c, &req, c.ShouldBindQuery(&req)) {
+		return
+	}
+	shortURL, token, ok := h.shorten(c, &req)
+	if !ok {
+		return
+	}
+	// Every call creates a link, so no cache may answer a repeat
+	c.Header("Cache-Control", "no-store")
+	resp := newShortenResponse(h.links, shortURL)
+	resp.ManagementToken = token
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEPlain) == gin.MIMEPlain {
+		c.String(http.StatusOK, resp.ShortURL+"\n")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, resp)
+}
+
+// QuickShortenRequest is the minimal body browser extensions send
+type QuickShortenRequest struct {
+	URL   string `json:"url" binding:"required,url"`
+	Title string `json:"title,omitempty"`
+}
+
+// Validate checks the destination URL and title beyond the struct tags
+func (r *QuickShortenRequest) Validate() error {
+	var errs validators.Errors
+	errs.CheckURL("url", r.URL)
+	errs.CheckLength("title", r.Title, validators.MaxTitleLength)
+	return errs.Err()
+}
+
+type QuickShortenResponse struct {
+	ShortURL  string `json:"short_url"`
+	ShortCode string `json:"short_code"`
+}
+
+// QuickShorten handles POST /api/v1/quick-shorten for browser extensions
+// Takes only the page's URL and title and answers with just the short URL,
+// as text when the client accepts text/plain
+func (h *URLHandler) QuickShorten(c *gin.Context) {
+	var req QuickShortenRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	shortURL, _, ok := h.shorten(c, &ShortenURLRequest{URL: req.URL, Title: req.Title})
+	if !ok {
+		return
+	}
+	resp := QuickShortenResponse{
+		ShortURL:  h.links.Link(shortURL),
+		ShortCode: shortURL.ShortCode,
+	}
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEPlain) == gin.MIMEPlain {
+		c.String(http.StatusOK, resp.ShortURL+"\n")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, resp)
+}
+
+// shorten creates the link for a validated request, writing the error
+// response and reporting false if it can't. An anonymous caller making a
+// new link also gets its management token; a reused link has none, since
+// other anonymous callers may share it
+func (h *URLHandler) shorten(c *gin.Context, req *ShortenURLRequest) (*models.ShortURL, string, bool) {
+	if req.Honeytoken && !allowHoneytoken(c) {
+		return nil, "", false
+	}
+	if req.CampaignID != "" {
+		if err := h.campaigns.CheckCapacity(c.Request.Context(), middleware.AccountID(c), req.CampaignID, 1); err != nil {
+			respondError(c, err, "Failed to shorten URL")
+			return nil, "", false
+		}
+	}
+	opts := shortenOptions(c, req)
+	opts.CampaignID = req.CampaignID
+	var created bool
+	opts.Created = &created
+	shortURL, err := h.service(c).ShortenURL(c.Request.Context(), req.URL, opts)
+	setQuotaHeaders(c, opts.Quota)
 	if err != nil {
-		if err == services.ErrInvalidURL {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL"})
-			return
+		respondError(c, err, "Failed to shorten URL")
+		return nil, "", false
+	}
+	var token string
+	if created && shortURL.OwnerID == "" && h.manage.Enabled() {
+		if token, err = h.manage.IssueToken(shortURL); err != nil {
+			log.Printf("Failed to issue management token: %v", err)
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to shorten URL"})
+	}
+	return shortURL, token, true
+}
+
+// allowHoneytoken checks that the caller may create honeytokens, which are
+// for security teams: admin keys only. It writes the error response if not
+func allowHoneytoken(c *gin.Context) bool {
+	if middleware.APIKey(c) == nil {
+		utils.RespondWithErrorCode(c, http.StatusUnauthorized, utils.ErrCodeAPIKeyRequired, "API key required")
+		return false
+	}
+	if !middleware.HasScope(c, models.ScopeAdmin) {
+		utils.RespondWithErrorCode(c, http.StatusForbidden, utils.ErrCodeInsufficientScope, "API key lacks the "+models.ScopeAdmin+" scope")
+		return false
+	}
+	return true
+}
+
+// shortenOptions turns a validated shorten request into service options
+func shortenOptions(c *gin.Context, req *ShortenURLRequest) services.ShortenOptions {
+	opts := services.ShortenOptions{
+		OwnerID:        middleware.AccountID(c),
+		Alias:          req.Alias,
+		Domain:         req.Domain,
+		Title:          req.Title,
+		FetchTitle:     req.FetchTitle,
+		Notes:          req.Notes,
+		DeviceRules:    req.DeviceRules,
+		UTM:            req.UTM,
+		Indexable:      req.Indexable,
+		NoIndex:        req.NoIndex,
+		Honeytoken:     req.Honeytoken,
+		MaxClicks:      req.MaxClicks,
+		EdgeCacheTTL:   req.EdgeCacheTTL,
+		CacheMaxAge:    req.CacheMaxAge,
+		Variants:       req.Variants,
+		ActivateAt:     req.ActivateAt,
+		DeactivateAt:   req.DeactivateAt,
+		FallbackURL:    req.FallbackURL,
+		IPAccess:       req.IPAccess,
+		RateLimit:      req.RateLimit,
+		Cloak:          req.Cloak,
+		ForwardQuery:   req.ForwardQuery,
+		SignedQuery:    req.SignedQuery,
+		RedirectStatus: req.RedirectStatus,
+		Privacy:        req.Privacy,
+		SingleUse:      req.SingleUse,
+		ClientIP:       middleware.ClientIP(c),
+	}
+	if key := middleware.APIKey(c); key != nil {
+		opts.Quota = &services.CreationQuota{Key: key}
+	}
+	if req.ExpiresIn != nil {
+		duration := time.Duration(*req.ExpiresIn) * time.Hour
+		opts.ExpiresIn = &duration
+	}
+	return opts
+}
+
+// setQuotaHeaders reports the API key's remaining link creation quota, with
+// Retry-After once it is used up
+func setQuotaHeaders(c *gin.Context, quota *services.CreationQuota) {
+	if quota == nil || quota.State == nil {
 		return
 	}
+	c.Header("X-Quota-Limit", strconv.FormatInt(quota.State.Limit, 10))
+	c.Header("X-Quota-Remaining", strconv.FormatInt(quota.State.Remaining, 10))
+	c.Header("X-Quota-Reset", strconv.FormatInt(quota.State.Reset.Unix(), 10))
+	if quota.State.Remaining == 0 {
+		c.Header("Retry-After", strconv.Itoa(int(time.Until(quota.State.Reset).Seconds())+1))
+	}
+}
 
+// newShortenResponse describes a created link
+func newShortenResponse(links *Links, shortURL *models.ShortURL) ShortenResponse {
 	var expiresAtStr *string
 	if shortURL.ExpiresAt != nil {
 		formatted := shortURL.ExpiresAt.Format(time.RFC3339)
 		expiresAtStr = &formatted
 	}
-
-	response := ShortenResponse{
-		ShortURL:    fmt.Sprintf("http://localhost:8080/%s", shortURL.ShortCode),
+	return ShortenResponse{
+		ShortURL:    links.Link(shortURL),
 		ShortCode:   shortURL.ShortCode,
 		OriginalURL: shortURL.OriginalURL,
+		Title:       shortURL.Title,
+		Notes:       shortURL.Notes,
 		ExpiresAt:   expiresAtStr,
+		SigningKey:  shortURL.SigningKey,
+	}
+}
+
+// bindShortenRequest reads a shorten request from a form post (urlencoded or
+// multipart) or, for any other content type, from JSON
+func bindShortenRequest(c *gin.Context, req *ShortenURLRequest) error {
+	switch c.ContentType() {
+	case binding.MIMEPOSTForm:
+		return c.ShouldBindWith(req, binding.Form)
+	case binding.MIMEMultipartPOSTForm:
+		return c.ShouldBindWith(req, binding.FormMultipart)
 	}
-	c.JSON(http.StatusOK, response)
+	return c.ShouldBindJSON(req)
 }
 
 func (h *URLHandler) RedirectURL(c *gin.Context) {
+	h.redirect(c, h.urlService, "/")
+}
+
+// RedirectSandbox handles GET /sandbox/:code, the redirects of sandbox links
+func (h *URLHandler) RedirectSandbox(c *gin.Context) {
+	h.redirect(c, h.sandbox, "/sandbox/")
+}
+
+// redirect serves a visit to a link of urlService, which lives under prefix
+func (h *URLHandler) redirect(c *gin.Context, urlService *services.URLService, prefix string) {
 	shortCode := c.Param("code")
 	if shortCode == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "short code is needed"})
+		utils.RespondWithError(c, http.StatusBadRequest, "short code is needed")
+		return
+	}
+	// Anything that can't be a short code (or is reserved for a route) never hits the database
+	if !h.reserved.Allowed(shortCode) {
+		h.errorPages.Respond(c, http.StatusNotFound, ReasonNotFound, "URL not found")
 		return
 	}
-	originalURL, err := h.urlService.GetOriginalURL(c.Request.Context(), shortCode)
+	domain, err := h.domainService.Resolve(c.Request.Context(), c.Request.Host)
 	if err != nil {
-		if err == services.ErrURLNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
-			return
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to redirect URL")
+		return
+	}
+	host := ""
+	if domain != nil {
+		host = domain.Host
+	}
+	// Counted against each rollout arm the link falls into, for comparison
+	start := time.Now()
+	defer func() {
+		h.rollouts.Observe(host, shortCode, time.Since(start), c.Writer.Status() >= http.StatusInternalServerError)
+	}()
+	visit := services.Visit{
+		UserAgent: c.Request.UserAgent(),
+		IP:        middleware.ClientIP(c),
+		Referrer:  c.Request.Referer(),
+		AccountID: middleware.AccountID(c),
+		URL:       c.Request.Host + c.Request.URL.RequestURI(),
+		Headers:   c.Request.Header,
+		Query:     forwardableQuery(c),
+	}
+	visit.Country, visit.City = h.locate(c, visit.IP)
+	visit.Variant, _ = c.Cookie(services.VariantCookie)
+	if h.botFilter.IsCrawler(visit.UserAgent) {
+		visit.Bot = true
+		visit.Uncounted = !h.botFilter.CountsCrawlers()
+	} else if h.botFilter.ChallengeEnabled() {
+		token, _ := c.Cookie(services.BotChallengeCookie)
+		if !h.botFilter.VerifyChallenge(token, visit) {
+			// Browsers pass the challenge and come back; clients without
+			// JS follow the noscript fallback and are counted as bots.
+			// Honeytokens skip it so every access alerts
+			if c.Query(noScriptParam) == "" {
+				destination, honeytoken := urlService.PeekLink(c.Request.Context(), host, shortCode, visit)
+				if !honeytoken {
+					renderBotChallenge(c, h.botFilter.ChallengeToken(visit), destination)
+					return
+				}
+			}
+			visit.Bot = true
 		}
-		if err == services.ErrURLExpired {
-			c.JSON(http.StatusGone, gin.H{"error": "URL has expired"})
+	}
+	redirect, err := urlService.GetOriginalURL(c.Request.Context(), host, shortCode, visit)
+	if err != nil {
+		if reason, serviceErr, ok := errorReason(err); ok {
+			if reason == ReasonRateLimit {
+				c.Header("Retry-After", strconv.Itoa(int(time.Until(redirect.RetryAt).Seconds())+1))
+			}
+			h.errorPages.Respond(c, serviceErr.Status, reason, serviceErr.Detail)
 			return
 		}
-		if err == services.ErrURLInactive {
-			c.JSON(http.StatusGone, gin.H{"error": "URL is inactive"})
-			return
+		respondError(c, err, "Failed to redirect URL")
+		return
+	}
+	if redirect.Variant != "" {
+		// Keep the visitor on this variant; the path scopes it to this link
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(services.VariantCookie, redirect.Variant, int(variantCookieMaxAge.Seconds()), prefix+shortCode, "", c.Request.TLS != nil, true)
+	}
+	status := http.StatusTemporaryRedirect
+	if redirect.EdgeCacheTTL > 0 || redirect.MaxAge > 0 {
+		// A CDN may serve this response until the link changes and is purged.
+		// Browsers keep it for MaxAge, or revalidate so they pick up edits;
+		// without an edge TTL the browser copy is private so CDNs skip it
+		cacheControl := "no-cache"
+		if redirect.MaxAge > 0 {
+			visibility := "private"
+			if redirect.EdgeCacheTTL > 0 {
+				visibility = "public"
+			}
+			cacheControl = fmt.Sprintf("%s, max-age=%d", visibility, int(redirect.MaxAge.Seconds()))
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redirect URL"})
+		c.Header("Cache-Control", cacheControl)
+		if redirect.EdgeCacheTTL > 0 {
+			edgeMaxAge := fmt.Sprintf("max-age=%d", int(redirect.EdgeCacheTTL.Seconds()))
+			c.Header("Surrogate-Control", edgeMaxAge)
+			c.Header("CDN-Cache-Control", edgeMaxAge)
+			c.Header("Surrogate-Key", redirect.SurrogateKey)
+		}
+		status = http.StatusMovedPermanently
+	} else {
+		// Every visit to this link must reach the backend
+		c.Header("Cache-Control", "no-store")
+	}
+	if redirect.Status != 0 {
+		status = redirect.Status
+	}
+	if redirect.NoIndex {
+		c.Header("X-Robots-Tag", "noindex")
+	}
+	if redirect.Cloak != "" {
+		h.cloakPages.Render(c, redirect)
 		return
 	}
-	c.Redirect(http.StatusTemporaryRedirect, originalURL)
+	c.Redirect(status, redirect.URL)
+}
+
+type ConversionRequest struct {
+	Variant string `json:"variant" binding:"required"`
+}
+
+// RecordConversion handles POST /api/v1/:code/conversions
+// The link's owner reports that a visitor sent to variant reached its goal
+func (h *URLHandler) RecordConversion(c *gin.Context) {
+	var req ConversionRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	err := h.urlService.RecordConversion(c.Request.Context(), middleware.AccountID(c), linkDomain(c), c.Param("code"), req.Variant)
+	if err != nil {
+		respondError(c, err, "Failed to record conversion")
+		return
+	}
+	c.Status(http.StatusNoContent)
 }
 
 func (h *URLHandler) GetStats(c *gin.Context) {
 	shortCode := c.Param("code")
 	if shortCode == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Short code is required"})
+		utils.RespondWithError(c, http.StatusBadRequest, "Short code is required")
+		return
+	}
+
+	query, err := parseStatsQuery(c)
+	if err != nil {
+		utils.RespondWithErrorCode(c, http.StatusBadRequest, utils.ErrCodeInvalidQuery, err.Error())
 		return
 	}
 
-	stats, err := h.urlService.GetStats(c.Request.Context(), shortCode)
+	stats, err := h.urlService.GetStats(c.Request.Context(), middleware.AccountID(c), linkDomain(c), shortCode, query)
 	if err != nil {
-		if err == services.ErrURLNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Short URL not found"})
+		respondError(c, err, "Failed to retrieve stats")
+		return
+	}
+
+	utils.RespondWithETag(c, stats, h.statsMaxAge)
+}
+
+// defaultReferrerLimit is how many referrers a breakdown lists unless asked otherwise
+const defaultReferrerLimit = 10
+
+// GetReferrerStats handles GET /api/v1/:code/stats/referrers
+// Takes the from, to and traffic parameters of link stats plus limit, and
+// answers with CSV when the client accepts text/csv
+func (h *URLHandler) GetReferrerStats(c *gin.Context) {
+	query, err := parseStatsQuery(c)
+	if err != nil {
+		utils.RespondWithErrorCode(c, http.StatusBadRequest, utils.ErrCodeInvalidQuery, err.Error())
+		return
+	}
+	limit := defaultReferrerLimit
+	if raw := c.Query("limit"); raw != "" {
+		if limit, err = strconv.Atoi(raw); err != nil {
+			utils.RespondWithError(c, http.StatusBadRequest, "limit must be an integer")
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve stats"})
+	}
+
+	stats, err := h.urlService.GetReferrerStats(c.Request.Context(), middleware.AccountID(c), linkDomain(c), c.Param("code"), query, limit)
+	if err != nil {
+		respondError(c, err, "Failed to retrieve stats")
+		return
+	}
+	if c.NegotiateFormat(gin.MIMEJSON, mimeCSV) == mimeCSV {
+		respondReferrersCSV(c, stats)
+		return
+	}
+	utils.RespondWithETag(c, stats, h.statsMaxAge)
+}
+
+const mimeCSV = "text/csv"
+
+// respondReferrersCSV writes one row per listed referrer
+func respondReferrersCSV(c *gin.Context, stats *models.ReferrerStats) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"referrer_domain", "clicks", "percentage"})
+	for _, referrer := range stats.Referrers {
+		w.Write([]string{referrer.Domain, strconv.FormatInt(referrer.Clicks, 10), strconv.FormatFloat(referrer.Percentage, 'f', 2, 64)})
+	}
+	w.Flush()
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-referrers.csv"`, stats.ShortCode))
+	c.Data(http.StatusOK, mimeCSV+"; charset=utf-8", buf.Bytes())
+}
+
+// defaultCountryLimit is how many countries a breakdown lists unless asked otherwise
+const defaultCountryLimit = 10
+
+// GetCountryStats handles GET /api/v1/:code/stats/countries
+// Takes the from, to and traffic parameters of link stats plus limit, and
+// answers with CSV when the client accepts text/csv
+func (h *URLHandler) GetCountryStats(c *gin.Context) {
+	query, err := parseStatsQuery(c)
+	if err != nil {
+		utils.RespondWithErrorCode(c, http.StatusBadRequest, utils.ErrCodeInvalidQuery, err.Error())
+		return
+	}
+	limit := defaultCountryLimit
+	if raw := c.Query("limit"); raw != "" {
+		if limit, err = strconv.Atoi(raw); err != nil {
+			utils.RespondWithError(c, http.StatusBadRequest, "limit must be an integer")
+			return
+		}
+	}
+
+	stats, err := h.urlService.GetCountryStats(c.Request.Context(), middleware.AccountID(c), linkDomain(c), c.Param("code"), query, limit)
+	if err != nil {
+		respondError(c, err, "Failed to retrieve stats")
+		return
+	}
+	if c.NegotiateFormat(gin.MIMEJSON, mimeCSV) == mimeCSV {
+		respondCountriesCSV(c, stats)
+		return
+	}
+	utils.RespondWithETag(c, stats, h.statsMaxAge)
+}
+
+// respondCountriesCSV writes one row per listed country, followed by a row
+// per listed city of that country
+func respondCountriesCSV(c *gin.Context, stats *models.CountryStats) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"country", "city", "clicks", "percentage"})
+	for _, country := range stats.Countries {
+		w.Write([]string{country.Country, "", strconv.FormatInt(country.Clicks, 10), strconv.FormatFloat(country.Percentage, 'f', 2, 64)})
+		for _, city := range country.Cities {
+			w.Write([]string{country.Country, city.City, strconv.FormatInt(city.Clicks, 10), strconv.FormatFloat(city.Percentage, 'f', 2, 64)})
+		}
+	}
+	w.Flush()
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-countries.csv"`, stats.ShortCode))
+	c.Data(http.StatusOK, mimeCSV+"; charset=utf-8", buf.Bytes())
+}
+
+type BatchStatsResponse struct {
+	Stats    []*models.LinkStats `json:"stats"`
+	NotFound []string            `json:"not_found"`
+}
+
+// GetStatsBatch handles GET /api/v1/stats?codes=a,b,c for dashboards
+func (h *URLHandler) GetStatsBatch(c *gin.Context) {
+	var codes []string
+	seen := map[string]bool{}
+	for _, code := range strings.Split(c.Query("codes"), ",") {
+		if code = strings.TrimSpace(code); code != "" && !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		utils.RespondWithError(c, http.StatusBadRequest, "codes is required")
 		return
 	}
 
-	c.JSON(http.StatusOK, stats)
+	query, err := parseStatsQuery(c)
+	if err != nil {
+		utils.RespondWithErrorCode(c, http.StatusBadRequest, utils.ErrCodeInvalidQuery, err.Error())
+		return
+	}
+
+	stats, notFound, err := h.urlService.GetStatsBatch(c.Request.Context(), middleware.AccountID(c), linkDomain(c), codes, query)
+	if err != nil {
+		respondError(c, err, "Failed to retrieve stats")
+		return
+	}
+	utils.RespondWithETag(c, BatchStatsResponse{Stats: stats, NotFound: notFound}, h.statsMaxAge)
+}
+
+// UpdateURL handles PUT /api/v1/:code
+// Replaces the destination, expiration, title and notes; omitted optional
+// fields are cleared, except ip_access, rate_limit, signed_query and privacy,
+// which only change when the body has them
+func (h *URLHandler) UpdateURL(c *gin.Context) {
+	var req UpdateURLRequest
+	if !bindUpdateJSON(c, &req) {
+		return
+	}
+	opts := services.UpdateOptions{
+		OriginalURL:    req.URL,
+		Title:          req.Title,
+		Notes:          req.Notes,
+		Indexable:      req.Indexable,
+		NoIndex:        req.NoIndex,
+		EdgeCacheTTL:   req.EdgeCacheTTL,
+		CacheMaxAge:    req.CacheMaxAge,
+		Variants:       req.Variants,
+		ActivateAt:     req.ActivateAt,
+		DeactivateAt:   req.DeactivateAt,
+		FallbackURL:    req.FallbackURL,
+		IPAccess:       req.IPAccess,
+		KeepIPAccess:   !slices.Contains(req.fields, "ip_access"),
+		RateLimit:      req.RateLimit,
+		KeepRateLimit:  !slices.Contains(req.fields, "rate_limit"),
+		Cloak:          req.Cloak,
+		ForwardQuery:   req.ForwardQuery,
+		SignedQuery:    req.SignedQuery,
+		RedirectStatus: req.RedirectStatus,
+		Privacy:        req.Privacy,
+		ClientIP:       middleware.ClientIP(c),
+	}
+	if req.ExpiresIn != nil {
+		duration := time.Duration(*req.ExpiresIn) * time.Hour
+		opts.ExpiresIn = &duration
+	}
+
+	shortURL, err := h.service(c).UpdateURL(c.Request.Context(), middleware.AccountID(c), linkDomain(c), c.Param("code"), opts)
+	if err != nil {
+		respondError(c, err, "Failed to update URL")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, UpdateURLResponse{ShortURL: shortURL, SigningKey: shortURL.SigningKey})
+}
+
+// bindUpdateJSON binds a PUT body, noting which fields it has
+func bindUpdateJSON(c *gin.Context, req *UpdateURLRequest) bool {
+	err := c.ShouldBindBodyWith(req, binding.JSON)
+	if err == nil {
+		var present map[string]json.RawMessage
+		body, _ := c.Get(gin.BodyBytesKey)
+		if json.Unmarshal(body.([]byte), &present) == nil {
+			req.fields = slices.Sorted(maps.Keys(present))
+		}
+	}
+	return checkRequest(c, req, err)
+}
+
+// UpdateURLResponse is the replaced link plus, for signed links, the key
+// other responses leave out
+type UpdateURLResponse struct {
+	*models.ShortURL
+	SigningKey string `json:"signing_key,omitempty"`
+}
+
+// PatchURLRequest is a partial update of a link: only the fields present in
+// the body change, and null clears one
+type PatchURLRequest struct {
+	URL       *string  `json:"url"`
+	Title     *string  `json:"title"`
+	Notes     *string  `json:"notes"`
+	Tags      []string `json:"tags"`
+	ExpiresIn *int     `json:"expires_in"`
+	IsActive  *bool    `json:"is_active"`
+	// fields are the names present in the body
+	fields []string
+}
+
+var patchFields = []string{services.PatchOriginalURL, services.PatchTitle, services.PatchNotes, services.PatchTags, services.PatchExpiresIn, services.PatchIsActive}
+
+// Validate checks each field present in the body
+func (r *PatchURLRequest) Validate() error {
+	var errs validators.Errors
+	if len(r.fields) == 0 {
+		errs.Add(validators.CodeRequired, "", "request body must set at least one field")
+	}
+	for _, field := range r.fields {
+		if !slices.Contains(patchFields, field) {
+			errs.Add(validators.CodeInvalid, field, field+" can't be patched")
+		}
+	}
+	if r.URL != nil {
+		errs.CheckURL("url", *r.URL)
+	} else if slices.Contains(r.fields, services.PatchOriginalURL) {
+		errs.Add(validators.CodeRequired, "url", "url can't be null")
+	}
+	if r.Title != nil {
+		errs.CheckLength("title", *r.Title, validators.MaxTitleLength)
+	}
+	if r.Notes != nil {
+		errs.CheckLength("notes", *r.Notes, validators.MaxNotesLength)
+	}
+	checkTags(&errs, r.Tags)
+	if r.ExpiresIn != nil && *r.ExpiresIn <= 0 {
+		errs.Add(validators.CodeOutOfRange, "expires_in", "expires_in must be a positive number of hours")
+	}
+	if slices.Contains(r.fields, services.PatchIsActive) && r.IsActive == nil {
+		errs.Add(validators.CodeRequired, "is_active", "is_active can't be null")
+	}
+	return errs.Err()
+}
+
+func checkTags(errs *validators.Errors, tags []string) {
+	if len(tags) > validators.MaxTags {
+		errs.Add(validators.CodeOutOfRange, "tags", fmt.Sprintf("tags must have at most %d entries", validators.MaxTags))
+		return
+	}
+	for i, tag := range tags {
+		field := fmt.Sprintf("tags[%d]", i)
+		if strings.TrimSpace(tag) == "" {
+			errs.Add(validators.CodeRequired, field, field+" is empty")
+		}
+		errs.CheckLength(field, tag, validators.MaxTagLength)
+	}
+}
+
+// bindPatchJSON binds a PATCH body, noting which fields it has
+func bindPatchJSON(c *gin.Context, req *PatchURLRequest) bool {
+	body, err := c.GetRawData()
+	if err == nil && len(body) == 0 {
+		err = io.EOF
+	}
+	var present map[string]json.RawMessage
+	if err == nil {
+		err = json.Unmarshal(body, &present)
+	}
+	if err == nil {
+		err = json.Unmarshal(body, req)
+	}
+	req.fields = slices.Sorted(maps.Keys(present))
+	return checkRequest(c, req, err)
+}
+
+// PatchURL handles PATCH /api/v1/:code
+// Changes only the destination, title, notes, tags, expiration or active flag
+// the body sets
+func (h *URLHandler) PatchURL(c *gin.Context) {
+	var req PatchURLRequest
+	if !bindPatchJSON(c, &req) {
+		return
+	}
+	patch := services.LinkPatch{Fields: req.fields, Tags: req.Tags, ClientIP: middleware.ClientIP(c)}
+	if req.URL != nil {
+		patch.OriginalURL = *req.URL
+	}
+	if req.Title != nil {
+		patch.Title = *req.Title
+	}
+	if req.Notes != nil {
+		patch.Notes = *req.Notes
+	}
+	if req.ExpiresIn != nil {
+		duration := time.Duration(*req.ExpiresIn) * time.Hour
+		patch.ExpiresIn = &duration
+	}
+	if req.IsActive != nil {
+		patch.IsActive = *req.IsActive
+	}
+	shortURL, err := h.service(c).PatchURL(c.Request.Context(), middleware.AccountID(c), linkDomain(c), c.Param("code"), patch)
+	if err != nil {
+		respondError(c, err, "Failed to update URL")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, shortURL)
+}
+
+// DeleteURL handles DELETE /api/v1/:code, moving the link to the trash
+func (h *URLHandler) DeleteURL(c *gin.Context) {
+	shortURL, err := h.service(c).DeleteURL(c.Request.Context(), middleware.AccountID(c), linkDomain(c), c.Param("code"))
+	if err != nil {
+		respondError(c, err, "Failed to delete URL")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, shortURL)
+}
+
+// RestoreURL handles POST /api/v1/:code/restore, taking the link out of the trash
+func (h *URLHandler) RestoreURL(c *gin.Context) {
+	shortURL, err := h.service(c).RestoreURL(c.Request.Context(), middleware.AccountID(c), linkDomain(c), c.Param("code"))
+	if err != nil {
+		respondError(c, err, "Failed to restore URL")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, shortURL)
+}
+
+// RenewURLRequest sets a link's new expiration; omitting expires_in removes it
+type RenewURLRequest struct {
+	ExpiresIn *int `json:"expires_in,omitempty"`
+}
+
+// Validate checks the new expiration is in the future
+func (r *RenewURLRequest) Validate() error {
+	var errs validators.Errors
+	if r.ExpiresIn != nil && *r.ExpiresIn <= 0 {
+		errs.Add(validators.CodeOutOfRange, "expires_in", "expires_in must be a positive number of hours")
+	}
+	return errs.Err()
+}
+
+// RenewURL handles POST /api/v1/:code/renew
+// Extends or removes the link's expiration, so expired links redirect
+// again; an empty body removes the expiration
+func (h *URLHandler) RenewURL(c *gin.Context) {
+	var req RenewURLRequest
+	if c.Request.ContentLength > 0 && !bindJSON(c, &req) {
+		return
+	}
+	var expiresIn *time.Duration
+	if req.ExpiresIn != nil {
+		duration := time.Duration(*req.ExpiresIn) * time.Hour
+		expiresIn = &duration
+	}
+	shortURL, err := h.service(c).RenewURL(c.Request.Context(), middleware.AccountID(c), linkDomain(c), c.Param("code"), expiresIn)
+	if err != nil {
+		respondError(c, err, "Failed to renew URL")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, shortURL)
+}
+
+// GetHistory handles GET /api/v1/:code/history
+func (h *URLHandler) GetHistory(c *gin.Context) {
+	pageReq, ok := parsePageRequest(c, repository.AuditListSpec)
+	if !ok {
+		return
+	}
+	page, err := h.urlService.GetHistory(c.Request.Context(), middleware.AccountID(c), linkDomain(c), c.Param("code"), pageReq)
+	if err != nil {
+		respondError(c, err, "Failed to retrieve history")
+		return
+	}
+	respondWithPage(c, page)
+}
+
+// ListLinks handles GET /api/v1/links
+// Pages through the caller's links, newest first unless ?sort= says
+// otherwise; ?health= keeps the links whose destination check had that
+// status, or dead for any failure
+func (h *URLHandler) ListLinks(c *gin.Context) {
+	pageReq, ok := parsePageRequest(c, repository.LinkListSpec)
+	if !ok {
+		return
+	}
+	page, err := h.service(c).ListLinks(c.Request.Context(), middleware.AccountID(c), pageReq)
+	if err != nil {
+		respondError(c, err, "Failed to list links")
+		return
+	}
+	respondWithPage(c, page)
+}
+
+// ListClicks handles GET /api/v1/:code/clicks
+// Pages through the recorded click events of one of the caller's links,
+// newest first; events already archived are not listed
+func (h *URLHandler) ListClicks(c *gin.Context) {
+	pageReq, ok := parsePageRequest(c, repository.ClickListSpec)
+	if !ok {
+		return
+	}
+	page, err := h.urlService.ListClicks(c.Request.Context(), middleware.AccountID(c), linkDomain(c), c.Param("code"), pageReq)
+	if err != nil {
+		respondError(c, err, "Failed to list clicks")
+		return
+	}
+	respondWithPage(c, page)
+}
+
+// StreamClicks handles GET /api/v1/:code/stats/stream
+// Pushes the clicks on one of the caller's links as server-sent "click"
+// events while the connection stays open
+func (h *URLHandler) StreamClicks(c *gin.Context) {
+	ctx := c.Request.Context()
+	clicks, err := h.urlService.WatchClicks(ctx, middleware.AccountID(c), linkDomain(c), c.Param("code"))
+	if err != nil {
+		respondError(c, err, "Failed to stream clicks")
+		return
+	}
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	// Tells nginx not to buffer the stream
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	keepalive := time.NewTicker(clickStreamKeepalive)
+	defer keepalive.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case click, ok := <-clicks:
+			if !ok {
+				return false
+			}
+			c.SSEvent("click", click)
+			return true
+		case <-keepalive.C:
+			// A comment line, so proxies don't close an idle stream
+			_, err := io.WriteString(w, ": keepalive\n\n")
+			return err == nil
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
+
+// LookupResponse lists the caller's existing links to a destination
+type LookupResponse struct {
+	URL   string            `json:"url"`
+	Links []ShortenResponse `json:"links"`
+}
+
+// LookupURL handles GET /api/v1/lookup?url=
+// Returns the caller's links to url, matched after normalization, so clients
+// can check before creating a duplicate
+func (h *URLHandler) LookupURL(c *gin.Context) {
+	rawURL := strings.TrimSpace(c.Query("url"))
+	if rawURL == "" {
+		respondInvalidField(c, validators.CodeRequired, "url", "url is required")
+		return
+	}
+	matches, err := h.urlService.LookupDestination(c.Request.Context(), middleware.AccountID(c), rawURL)
+	if err != nil {
+		respondError(c, err, "Failed to look up links")
+		return
+	}
+	resp := LookupResponse{URL: rawURL, Links: make([]ShortenResponse, 0, len(matches))}
+	for _, shortURL := range matches {
+		resp.Links = append(resp.Links, newShortenResponse(h.links, shortURL))
+	}
+	utils.RespondWithJSON(c, http.StatusOK, resp)
+}
+
+// maxResolveCodes caps the codes one resolve request may expand
+const maxResolveCodes = 100
+
+type ResolveRequest struct {
+	Codes []string `json:"codes"`
+}
+
+// Validate checks a batch of codes was sent and that it isn't too large
+func (r *ResolveRequest) Validate() error {
+	var errs validators.Errors
+	if len(r.Codes) == 0 {
+		errs.Add(validators.CodeRequired, "codes", "codes is required")
+	} else if len(r.Codes) > maxResolveCodes {
+		errs.Add(validators.CodeOutOfRange, "codes", fmt.Sprintf("at most %d codes are allowed", maxResolveCodes))
+	}
+	return errs.Err()
+}
+
+type ResolvedLink struct {
+	Code   string `json:"code"`
+	Status string `json:"status"`
+	URL    string `json:"url,omitempty"`
+}
+
+type ResolveResponse struct {
+	Links []ResolvedLink `json:"links"`
+}
+
+// ResolveLinks handles POST /api/v1/resolve
+// Expands many short codes in one call, for clients such as email scanners
+// that would otherwise follow each redirect; no clicks are counted
+func (h *URLHandler) ResolveLinks(c *gin.Context) {
+	var req ResolveRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	var codes []string
+	seen := map[string]bool{}
+	for _, code := range req.Codes {
+		if code = strings.TrimSpace(code); code != "" && !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	// Codes that can't exist never reach the cache or the database
+	var lookups []string
+	for _, code := range codes {
+		if h.reserved.Allowed(code) {
+			lookups = append(lookups, code)
+		}
+	}
+	visit := services.Visit{
+		UserAgent: c.Request.UserAgent(),
+		IP:        middleware.ClientIP(c),
+		AccountID: middleware.AccountID(c),
+		URL:       c.Request.Host + c.Request.URL.RequestURI(),
+		Headers:   c.Request.Header,
+	}
+	visit.Country, visit.City = h.locate(c, visit.IP)
+	resolutions, err := h.urlService.ResolveLinks(c.Request.Context(), linkDomain(c), lookups, visit)
+	if err != nil {
+		respondError(c, err, "Failed to resolve links")
+		return
+	}
+	byCode := make(map[string]services.Resolution, len(resolutions))
+	for _, resolution := range resolutions {
+		byCode[resolution.Code] = resolution
+	}
+	resp := ResolveResponse{Links: make([]ResolvedLink, 0, len(codes))}
+	for _, code := range codes {
+		resolution, ok := byCode[code]
+		if !ok {
+			resolution = services.Resolution{Code: code, Status: services.ResolveNotFound}
+		}
+		resp.Links = append(resp.Links, ResolvedLink{Code: code, Status: resolution.Status, URL: resolution.URL})
+	}
+	utils.RespondWithJSON(c, http.StatusOK, resp)
+}
+
+// parseStatsQuery reads ?from=&to= (RFC 3339) and ?granularity=hour|day|week
+func parseStatsQuery(c *gin.Context) (services.StatsQuery, error) {
+	query := services.StatsQuery{
+		Granularity: c.Query("granularity"),
+		Traffic:     repository.TrafficFilter(c.Query("traffic")),
+	}
+	if raw := c.Query("from"); raw != "" {
+		from, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return query, errors.New("from must be an RFC 3339 timestamp")
+		}
+		query.From = from
+	}
+	if raw := c.Query("to"); raw != "" {
+		to, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return query, errors.New("to must be an RFC 3339 timestamp")
+		}
+		query.To = to
+	}
+	return query, nil
+}
+
+// requestCountry reads the visitor country set by a CDN or load balancer, if
+// any. Only trusted proxies' headers count, or clients could pick a country
+func requestCountry(c *gin.Context) string {
+	if !middleware.FromTrustedProxy(c) {
+		return ""
+	}
+	for _, header := range []string{"CF-IPCountry", "CloudFront-Viewer-Country", "X-Country-Code"} {
+		if v := c.GetHeader(header); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// locate finds where the visitor at ip is: in the country a trusted CDN
+// reports, else the one the GeoIP database knows. The database's city is
+// only kept when it lies in that country
+func (h *URLHandler) locate(c *gin.Context, ip string) (country, city string) {
+	location := h.geo.Lookup(ip)
+	country = requestCountry(c)
+	if country == "" {
+		return location.Country, location.City
+	}
+	if strings.EqualFold(country, location.Country) {
+		return country, location.City
+	}
+	return country, ""
+}
+
+// QRCode serves a PNG QR code that encodes the link's short URL
+func (h *URLHandler) QRCode(c *gin.Context) {
+	shortCode := c.Param("code")
+	if !h.reserved.Allowed(shortCode) {
+		utils.RespondWithErrorCode(c, http.StatusNotFound, utils.ErrCodeURLNotFound, "URL not found")
+		return
+	}
+	domain := linkDomain(c)
+	if _, err := h.urlService.GetLink(c.Request.Context(), domain, shortCode); err != nil {
+		respondError(c, err, "Failed to look up URL")
+		return
+	}
+	png, err := qrcode.Encode(h.links.Short(domain, shortCode), qrcode.Medium, 256)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to render QR code")
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+// linkDomain is the branded domain a management request addresses with
+// ?domain=, or "" for the default short domain
+func linkDomain(c *gin.Context) string {
+	return strings.ToLower(strings.TrimSpace(c.Query("domain")))
 }
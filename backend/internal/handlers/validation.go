@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+)
+
+func init() {
+	// Report struct-tag failures by the field names clients send
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(validators.JSONFieldName)
+	}
+}
+
+// validatable is a request with checks beyond its struct tags
+type validatable interface {
+	Validate() error
+}
+
+// bindJSON decodes and validates the JSON body into req, writing a 400 with
+// field errors and returning false when it is invalid
+func bindJSON(c *gin.Context, req interface{}) bool {
+	return checkRequest(c, req, c.ShouldBindJSON(req))
+}
+
+// checkRequest writes the field errors from a failed bind and from req's own
+// Validate method, and reports whether the request may proceed
+// Validate only runs once the body decoded, so it sees every field
+func checkRequest(c *gin.Context, req interface{}, bindErr error) bool {
+	// The body was cut off by middleware.LimitRequestSize
+	var tooLarge *http.MaxBytesError
+	if errors.As(bindErr, &tooLarge) {
+		utils.RespondWithErrorCode(c, http.StatusRequestEntityTooLarge, utils.ErrCodePayloadTooLarge,
+			fmt.Sprintf("Request body must be at most %d bytes", tooLarge.Limit))
+		return false
+	}
+	var errs validators.Errors
+	var tagErrs validator.ValidationErrors
+	if bindErr != nil {
+		errs = validators.FromBindError(bindErr)
+	}
+	if v, ok := req.(validatable); ok && (bindErr == nil || errors.As(bindErr, &tagErrs)) {
+		if err := v.Validate(); err != nil {
+			errs = errs.Merge(validators.FromBindError(err))
+		}
+	}
+	if len(errs) > 0 {
+		utils.RespondWithValidationError(c, errs)
+		return false
+	}
+	return true
+}
+
+// respondInvalidField writes a 400 for a single field that failed validation
+func respondInvalidField(c *gin.Context, code, field, message string) {
+	utils.RespondWithValidationError(c, validators.NewError(code, field, message))
+}
+
+// respondError leaves err for middleware.ServiceErrors to answer; fallback
+// is the message of the 500 it answers when err is no service error
+func respondError(c *gin.Context, err error, fallback string) {
+	c.Error(err).SetMeta(fallback)
+}
@@ -0,0 +1,90 @@
+package keygen
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// fillBatch caps how many codes one RPUSH adds
+const fillBatch = 500
+
+// Filler keeps the Redis queue the URL service pops codes from topped up, so
+// shortening rarely has to wait on a call to this service
+type Filler struct {
+	generator   *Generator
+	redisClient redis.UniversalClient
+	queue       string
+	target      int64
+	interval    time.Duration
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+func NewFiller(generator *Generator, redisClient redis.UniversalClient, queue string, target int64, interval time.Duration) *Filler {
+	return &Filler{
+		generator:   generator,
+		redisClient: redisClient,
+		queue:       queue,
+		target:      target,
+		interval:    interval,
+		stop:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+}
+
+// Start refills the queue right away and then every interval until Stop is called
+func (f *Filler) Start() {
+	go func() {
+		defer close(f.stopped)
+		ticker := time.NewTicker(f.interval)
+		defer ticker.Stop()
+		for {
+			if err := f.Fill(context.Background()); err != nil {
+				log.Printf("Failed to refill the key queue: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-f.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the refill loop
+func (f *Filler) Stop() {
+	close(f.stop)
+	<-f.stopped
+}
+
+// Fill pushes codes until the queue holds target of them. Several instances
+// may fill at once; the queue can then overshoot a little, which is harmless
+func (f *Filler) Fill(ctx context.Context) error {
+	length, err := f.redisClient.LLen(ctx, f.queue).Result()
+	if err != nil {
+		return err
+	}
+	for length < f.target {
+		batch := make([]interface{}, 0, min(f.target-length, fillBatch))
+		for len(batch) < cap(batch) {
+			code, err := f.generator.Next(ctx)
+			if err != nil {
+				return err
+			}
+			batch = append(batch, code)
+		}
+		if length, err = f.redisClient.RPush(ctx, f.queue, batch...).Result(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// QueueLength reports how many codes are waiting in the queue
+func (f *Filler) QueueLength(ctx context.Context) (int64, error) {
+	return f.redisClient.LLen(ctx, f.queue).Result()
+}
@@ -0,0 +1,101 @@
+// Package keygen issues unique short codes for the standalone key generation
+// service. Instances lease disjoint ranges of a shared Redis counter, so any
+// number of them can run side by side without coordinating per code
+package keygen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/bits"
+	"sync"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+	"github.com/redis/go-redis/v9"
+)
+
+// counterKey is the Redis counter ranges are leased from
+const counterKey = "keygen:counter"
+
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// multiplier scrambles consecutive counter values into unrelated codes. It is
+// coprime with 62, so multiplying modulo 62^length is a bijection and codes
+// stay unique
+const multiplier = 0x5DEECE66D
+
+// ErrExhausted means every code of the configured length has been issued
+var ErrExhausted = errors.New("short code space exhausted")
+
+// Generator turns counter values from leased ranges into fixed-length Base62
+// codes. Values are scrambled so neighbouring links don't get guessable codes
+type Generator struct {
+	redisClient redis.UniversalClient
+	rangeSize   int64
+	length      int
+	space       uint64
+	reserved    *validators.ReservedWords
+
+	mu   sync.Mutex
+	next int64
+	end  int64 // exclusive; next == end means a new range is needed
+}
+
+func NewGenerator(redisClient redis.UniversalClient, rangeSize int64, length int, reserved *validators.ReservedWords) *Generator {
+	space := uint64(1)
+	for range length {
+		space *= uint64(len(base62Alphabet))
+	}
+	return &Generator{
+		redisClient: redisClient,
+		rangeSize:   rangeSize,
+		length:      length,
+		space:       space,
+		reserved:    reserved,
+	}
+}
+
+// Next returns an unused code, leasing a new range when the current one runs out
+// Codes that are reserved words are skipped
+func (g *Generator) Next(ctx context.Context) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for {
+		if g.next == g.end {
+			if err := g.lease(ctx); err != nil {
+				return "", err
+			}
+		}
+		value := g.next
+		g.next++
+		if code := g.encode(uint64(value)); g.reserved.Allowed(code) {
+			return code, nil
+		}
+	}
+}
+
+// lease reserves the next rangeSize counter values for this instance
+func (g *Generator) lease(ctx context.Context) error {
+	end, err := g.redisClient.IncrBy(ctx, counterKey, g.rangeSize).Result()
+	if err != nil {
+		return fmt.Errorf("failed to lease a counter range: %w", err)
+	}
+	start := end - g.rangeSize
+	if uint64(start) >= g.space {
+		return ErrExhausted
+	}
+	g.next, g.end = start, min(end, int64(g.space))
+	return nil
+}
+
+// encode maps value to a code of g.length Base62 digits
+func (g *Generator) encode(value uint64) string {
+	hi, lo := bits.Mul64(value, multiplier)
+	scrambled := bits.Rem64(hi, lo, g.space)
+	code := make([]byte, g.length)
+	for i := g.length - 1; i >= 0; i-- {
+		code[i] = base62Alphabet[scrambled%62]
+		scrambled /= 62
+	}
+	return string(code)
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+)
+
+// APIVersion adapts responses to the API version a route group serves and
+// counts its traffic. v1 keeps the negotiated format, so existing clients
+// see no change; v2 always answers with the {data, error, meta} envelope
+// Retiring versions announce it with Deprecation, Sunset and Link headers
+func APIVersion(version string, metrics *services.APIVersionMetrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := utils.GetResponseFormat(c)
+		format.Version = version
+		if version != services.APIVersion1 {
+			format.Envelope = true
+		}
+		utils.SetResponseFormat(c, format)
+		if deprecation, ok := metrics.Deprecation(version); ok {
+			setDeprecationHeaders(c, version, deprecation)
+		}
+
+		c.Next()
+
+		metrics.Observe(version, AccountID(c), c.Writer.Status())
+	}
+}
+
+// setDeprecationHeaders follows RFC 9745 (Deprecation) and RFC 8594 (Sunset)
+// The successor-version link points at the same path on the newest version
+func setDeprecationHeaders(c *gin.Context, version string, deprecation services.Deprecation) {
+	if !deprecation.DeprecatedAt.IsZero() {
+		c.Header("Deprecation", fmt.Sprintf("@%d", deprecation.DeprecatedAt.Unix()))
+	}
+	if !deprecation.SunsetAt.IsZero() {
+		c.Header("Sunset", deprecation.SunsetAt.UTC().Format(http.TimeFormat))
+	}
+	if deprecation.MigrationURL != "" {
+		c.Writer.Header().Add("Link", "<"+deprecation.MigrationURL+`>; rel="deprecation"`)
+	}
+	latest := services.APIVersions[len(services.APIVersions)-1]
+	if latest != version {
+		successor := strings.Replace(c.Request.URL.Path, "/api/"+version+"/", "/api/"+latest+"/", 1)
+		c.Writer.Header().Add("Link", "<"+successor+`>; rel="successor-version"`)
+	}
+}
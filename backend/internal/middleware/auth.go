@@ -0,0 +1,223 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+)
+
+const (
+	apiKeyContextKey    = "api_key"
+	workspaceContextKey = "workspace"
+	roleContextKey      = "workspace_role"
+)
+
+// Authenticate resolves an API key sent as X-API-Key or "Authorization: Bearer"
+// Requests without a key pass through anonymously; an invalid key is rejected
+func Authenticate(apiKeyService *services.APIKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		plaintext := requestAPIKey(c)
+		if plaintext == "" {
+			c.Next()
+			return
+		}
+
+		key, err := apiKeyService.Authenticate(c.Request.Context(), plaintext)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidAPIKey) || errors.Is(err, services.ErrAPIKeyRevoked) || errors.Is(err, services.ErrAPIKeyExpired) {
+				utils.RespondWithErrorCode(c, http.StatusUnauthorized, utils.ErrCodeInvalidAPIKey, "Invalid API key")
+			} else {
+				utils.RespondWithError(c, http.StatusInternalServerError, "Failed to authenticate")
+			}
+			c.Abort()
+			return
+		}
+
+		// Browsers always send Origin on cross-origin calls; other clients
+		// could forge it, so the restriction only guards against keys
+		// lifted from a page or extension being reused from another site
+		if origin := c.GetHeader("Origin"); origin != "" && len(key.AllowedOrigins) > 0 && !validators.MatchOrigin(key.AllowedOrigins, origin) {
+			utils.RespondWithErrorCode(c, http.StatusForbidden, utils.ErrCodeOriginNotAllowed, "Origin is not allowed for this API key")
+			c.Abort()
+			return
+		}
+
+		c.Set(apiKeyContextKey, key)
+		c.Next()
+	}
+}
+
+// Identify resolves an API key like Authenticate but never rejects the
+// request; missing or invalid keys leave it anonymous
+// Used on the redirect route, where a key is only a hint about the visitor
+func Identify(apiKeyService *services.APIKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if plaintext := requestAPIKey(c); plaintext != "" {
+			if key, err := apiKeyService.Authenticate(c.Request.Context(), plaintext); err == nil {
+				c.Set(apiKeyContextKey, key)
+			}
+		}
+		c.Next()
+	}
+}
+
+// Workspace switches the request into the organization named by the
+// X-Organization header. The caller's membership role then limits what its
+// key may do there, and AccountID reports the organization's workspace
+// Must run after Authenticate; requests without the header are unaffected
+func Workspace(orgService *services.OrganizationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID := c.GetHeader("X-Organization")
+		if orgID == "" {
+			c.Next()
+			return
+		}
+		key := APIKey(c)
+		if key == nil {
+			utils.RespondWithErrorCode(c, http.StatusUnauthorized, utils.ErrCodeAPIKeyRequired, "API key required")
+			c.Abort()
+			return
+		}
+
+		org, role, err := orgService.Resolve(c.Request.Context(), orgID, key.AccountID)
+		if err != nil {
+			if errors.Is(err, services.ErrOrganizationNotFound) {
+				utils.RespondWithErrorCode(c, http.StatusNotFound, utils.ErrCodeOrganizationNotFound, "Organization not found")
+			} else {
+				utils.RespondWithError(c, http.StatusInternalServerError, "Failed to resolve organization")
+			}
+			c.Abort()
+			return
+		}
+
+		c.Set(workspaceContextKey, org.AccountID)
+		c.Set(roleContextKey, role)
+		c.Next()
+	}
+}
+
+// RequireAccount rejects requests that were not authenticated with an API key
+func RequireAccount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if AccountID(c) == "" {
+			utils.RespondWithErrorCode(c, http.StatusUnauthorized, utils.ErrCodeAPIKeyRequired, "API key required")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireScope rejects API keys that lack scope
+// Anonymous requests pass; combine with RequireAccount where a key is mandatory
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key := APIKey(c); key != nil && !key.HasScope(scope) {
+			utils.RespondWithErrorCode(c, http.StatusForbidden, utils.ErrCodeInsufficientScope, "API key lacks the "+scope+" scope")
+			c.Abort()
+			return
+		}
+		if role := Role(c); role != "" && !models.RoleAllows(role, scope) {
+			utils.RespondWithErrorCode(c, http.StatusForbidden, utils.ErrCodeInsufficientScope, "The "+role+" role does not allow the "+scope+" scope")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// AdminAuth protects admin routes with a static token sent as X-Admin-Token
+// An empty configured token disables admin routes entirely
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided := c.GetHeader("X-Admin-Token")
+		if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			utils.RespondWithErrorCode(c, http.StatusUnauthorized, utils.ErrCodeAdminTokenRequired, "Admin token required")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// APIKey returns the key that authenticated this request, or nil
+func APIKey(c *gin.Context) *models.APIKey {
+	if v, ok := c.Get(apiKeyContextKey); ok {
+		if key, ok := v.(*models.APIKey); ok {
+			return key
+		}
+	}
+	return nil
+}
+
+// AccountID returns the account the request acts on: the organization
+// workspace selected with X-Organization, else the key's own account, or ""
+// for anonymous requests
+func AccountID(c *gin.Context) string {
+	if workspace := c.GetString(workspaceContextKey); workspace != "" {
+		return workspace
+	}
+	return CallerID(c)
+}
+
+// CallerID returns the account that owns the request's API key, ignoring
+// any organization workspace, or "" for anonymous requests
+func CallerID(c *gin.Context) string {
+	if key := APIKey(c); key != nil {
+		return key.AccountID
+	}
+	return ""
+}
+
+// Sandbox reports whether the request was authenticated with a sandbox API key
+func Sandbox(c *gin.Context) bool {
+	key := APIKey(c)
+	return key != nil && key.Sandbox
+}
+
+// RejectSandbox rejects sandbox API keys on routes that only work on
+// production links and data
+func RejectSandbox() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if Sandbox(c) {
+			utils.RespondWithErrorCode(c, http.StatusForbidden, utils.ErrCodeSandboxKey, "Not available to sandbox API keys")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Role returns the caller's role in the selected organization, or "" outside one
+func Role(c *gin.Context) string {
+	return c.GetString(roleContextKey)
+}
+
+// HasScope reports whether the request may act with scope: the key must
+// grant it and, inside an organization, so must the caller's role
+func HasScope(c *gin.Context, scope string) bool {
+	key := APIKey(c)
+	if key == nil || !key.HasScope(scope) {
+		return false
+	}
+	role := Role(c)
+	return role == "" || models.RoleAllows(role, scope)
+}
+
+func requestAPIKey(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	auth := c.GetHeader("Authorization")
+	if strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return ""
+}
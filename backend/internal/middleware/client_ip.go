@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+)
+
+const (
+	clientIPContextKey     = "client_ip"
+	trustedProxyContextKey = "trusted_proxy"
+)
+
+// ResolveClientIP works out the client address once per request, trusting
+// forwarding headers only from the configured proxies. Must run before
+// anything that reads ClientIP
+func ResolveClientIP(resolver *utils.ClientIPResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(clientIPContextKey, resolver.ClientIP(c.Request))
+		c.Set(trustedProxyContextKey, resolver.FromTrustedProxy(c.Request))
+		c.Next()
+	}
+}
+
+// ClientIP returns the address of the client behind any trusted proxies,
+// for rate limits, access rules and click logging
+func ClientIP(c *gin.Context) string {
+	if ip := c.GetString(clientIPContextKey); ip != "" {
+		return ip
+	}
+	return c.RemoteIP()
+}
+
+// FromTrustedProxy reports whether the request came through a trusted
+// proxy, whose headers describing the visitor can be believed
+func FromTrustedProxy(c *gin.Context) bool {
+	return c.GetBool(trustedProxyContextKey)
+}
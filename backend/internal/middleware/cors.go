@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+)
+
+// CORS lets pages and extensions on origins matching allowed call the
+// routes it guards with an API key in the Authorization header. Preflights
+// are answered here and go no further; other requests from an origin that
+// isn't allowed get a 403. It runs before authentication so that error
+// responses carry the CORS headers too and the caller can read them
+func CORS(allowed []string, methods string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		c.Header("Vary", "Origin")
+		if origin != "" && !validators.MatchOrigin(allowed, origin) {
+			utils.RespondWithErrorCode(c, http.StatusForbidden, utils.ErrCodeOriginNotAllowed, "Origin is not allowed")
+			c.Abort()
+			return
+		}
+		if origin != "" {
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Access-Control-Expose-Headers", "Retry-After, X-Quota-Limit, X-Quota-Remaining, X-Quota-Reset, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset")
+		}
+		if c.Request.Method == http.MethodOptions {
+			c.Header("Access-Control-Allow-Methods", methods)
+			c.Header("Access-Control-Allow-Headers", "Authorization, Content-Type, X-API-Key")
+			c.Header("Access-Control-Max-Age", "600")
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+)
+
+// ServiceErrors answers requests whose handler gave up with c.Error instead
+// of responding, so every handler reports service errors the same way. A
+// services.Error anywhere in the chain picks the status, code and message;
+// any other error is logged and answered with a 500 carrying the message
+// the handler set as the error's meta
+func ServiceErrors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		last := c.Errors.Last()
+		fallback, _ := last.Meta.(string)
+		if services.Internal(last.Err) {
+			log.Printf("%s %s failed: %v", c.Request.Method, c.FullPath(), last.Err)
+		}
+		described := services.Describe(last.Err, fallback)
+		if described.Field != "" {
+			utils.RespondWithValidationError(c, validators.NewError(described.FieldCode, described.Field, described.Detail))
+			return
+		}
+		utils.RespondWithErrorCode(c, described.Status, described.Code, described.Detail)
+	}
+}
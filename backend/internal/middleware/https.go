@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HSTS configures the Strict-Transport-Security header; a zero MaxAge sends none
+type HSTS struct {
+	MaxAge            time.Duration
+	IncludeSubdomains bool
+}
+
+// HTTPSRedirect sends plain HTTP requests to the same URL over HTTPS when
+// redirect is on, and adds HSTS to responses served over HTTPS. Requests
+// count as HTTPS when TLS ended here or at a proxy that says so in
+// X-Forwarded-Proto. Health probes are never redirected, since orchestrators
+// usually probe over plain HTTP
+func HTTPSRedirect(redirect bool, hsts HSTS) gin.HandlerFunc {
+	header := ""
+	if hsts.MaxAge > 0 {
+		header = fmt.Sprintf("max-age=%d", int64(hsts.MaxAge.Seconds()))
+		if hsts.IncludeSubdomains {
+			header += "; includeSubDomains"
+		}
+	}
+	return func(c *gin.Context) {
+		if isHTTPS(c.Request) {
+			if header != "" {
+				c.Header("Strict-Transport-Security", header)
+			}
+			c.Next()
+			return
+		}
+		if redirect && c.Request.URL.Path != "/livez" && c.Request.URL.Path != "/readyz" {
+			c.Redirect(redirectStatus(c.Request), httpsURL(c.Request))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RedirectToHTTPS is a plain handler sending every request to HTTPS, for the
+// listener that only exists to upgrade clients
+func RedirectToHTTPS() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, httpsURL(r), redirectStatus(r))
+	})
+}
+
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// httpsURL is r's URL on the https scheme and default port
+func httpsURL(r *http.Request) string {
+	host := r.Host
+	if i := strings.LastIndex(host, ":"); i != -1 && !strings.HasSuffix(host, "]") {
+		host = host[:i]
+	}
+	return "https://" + host + r.URL.RequestURI()
+}
+
+// redirectStatus is 301 for reads and 308 otherwise, so clients resend
+// other methods with their body instead of turning them into a GET
+func redirectStatus(r *http.Request) int {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return http.StatusMovedPermanently
+	}
+	return http.StatusPermanentRedirect
+}
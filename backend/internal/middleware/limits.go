@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+)
+
+// LimitRequestSize rejects request URLs over maxURL bytes with a 414 and
+// bodies over maxBody bytes with a 413. Bodies that don't declare their
+// length are cut off at maxBody, and binding them fails with a 413 too
+func LimitRequestSize(maxURL, maxBody int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if int64(len(c.Request.RequestURI)) > maxURL {
+			utils.RespondWithErrorCode(c, http.StatusRequestURITooLong, utils.ErrCodeURITooLong,
+				fmt.Sprintf("Request URL must be at most %d bytes", maxURL))
+			c.Abort()
+			return
+		}
+		if c.Request.ContentLength > maxBody {
+			utils.RespondWithErrorCode(c, http.StatusRequestEntityTooLarge, utils.ErrCodePayloadTooLarge,
+				fmt.Sprintf("Request body must be at most %d bytes", maxBody))
+			c.Abort()
+			return
+		}
+		if c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBody)
+		}
+		c.Next()
+	}
+}
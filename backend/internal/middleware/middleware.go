@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"log"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -22,6 +23,21 @@ func Logger() gin.HandlerFunc {
 		latency := time.Since(t)
 		status := c.Writer.Status()
 
-		log.Printf("Path: %s | Status: %d | Latency: %v", c.Request.URL.Path, status, latency)
+		// Management tokens are credentials, so they stay out of the log
+		path := c.Request.URL.Path
+		if token := c.Param("token"); token != "" {
+			path = strings.Replace(path, token, ":token", 1)
+		}
+
+		log.Printf("Path: %s | Status: %d | Latency: %v", path, status, latency)
+	}
+}
+
+// ServedBy names the replica that handled the request in X-Served-By
+// Meant for debugging multi-instance deployments, so it is off by default
+func ServedBy(instanceID string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("X-Served-By", instanceID)
+		c.Next()
 	}
 }
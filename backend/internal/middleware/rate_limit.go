@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/services"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+)
+
+// RateLimit enforces the per-key (or per-IP for anonymous calls) request limit
+// and reports the window state in X-RateLimit-Limit/Remaining/Reset headers
+// Must run after Authenticate so keyed requests are limited per key
+func RateLimit(limiter *services.RateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := APIKey(c)
+		subject := "ip:" + ClientIP(c)
+		if key != nil {
+			subject = "key:" + key.ID.Hex()
+		}
+
+		result, err := limiter.Allow(c.Request.Context(), subject, key)
+		if err != nil {
+			// Fail open: a Redis hiccup shouldn't take the API down
+			log.Printf("Rate limiter unavailable: %v", err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.FormatInt(result.Limit, 10))
+		c.Header("X-RateLimit-Remaining", strconv.FormatInt(result.Remaining, 10))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(result.Reset.Unix(), 10))
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(int(time.Until(result.Reset).Seconds())+1))
+			utils.RespondWithErrorCode(c, http.StatusTooManyRequests, utils.ErrCodeRateLimited, "Rate limit exceeded")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+)
+
+// ResponseFormat negotiates JSON casing and envelope per request
+// Clients override the configured defaults with X-Response-Casing (snake|camel)
+// and X-Response-Envelope (true|false)
+func ResponseFormat(defaults utils.ResponseFormat) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := defaults
+
+		switch strings.ToLower(c.GetHeader("X-Response-Casing")) {
+		case utils.CasingCamel:
+			format.Casing = utils.CasingCamel
+		case utils.CasingSnake:
+			format.Casing = utils.CasingSnake
+		}
+		if v := c.GetHeader("X-Response-Envelope"); v != "" {
+			if envelope, err := strconv.ParseBool(v); err == nil {
+				format.Envelope = envelope
+			}
+		}
+
+		utils.SetResponseFormat(c, format)
+		c.Next()
+	}
+}
@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Short codes used to be unique across every domain. Branded domains made
+// them unique per domain, so the old index would reject a code taken on
+// another domain. It can't come back once codes repeat across domains
+func init() {
+	register(Migration{
+		Version: 1,
+		Name:    "drop_global_short_code_index",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndex(ctx, db.Collection("short_urls"), "short_code_1")
+		},
+	})
+}
@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Click events are paged by code, time and _id. The index on code and time
+// alone is a prefix of that one, so it only costs writes
+func init() {
+	register(Migration{
+		Version: 2,
+		Name:    "drop_click_code_time_index",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			clicks := db.Collection("click_events")
+			// The replacement is built first so stats queries always have one
+			replacement := mongo.IndexModel{
+				Keys: bson.D{{Key: "short_code", Value: 1}, {Key: "clicked_at", Value: 1}, {Key: "_id", Value: 1}},
+			}
+			if _, err := clicks.Indexes().CreateOne(ctx, replacement); err != nil {
+				return err
+			}
+			return dropIndex(ctx, clicks, "short_code_1_clicked_at_1")
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("click_events").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys: bson.D{{Key: "short_code", Value: 1}, {Key: "clicked_at", Value: 1}},
+			})
+			return err
+		},
+	})
+}
@@ -0,0 +1,70 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// backfillBatch is how many links are updated per bulk write
+const backfillBatch = 500
+
+// Links created before url_key was stored are only found as duplicates by
+// their exact destination. Giving them a url_key lets duplicate lookups
+// match them like newer links. Newer releases write url_key themselves, so
+// reverting leaves it in place
+func init() {
+	register(Migration{
+		Version: 3,
+		Name:    "backfill_url_key",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			links := db.Collection("short_urls")
+			opts := options.Find().SetProjection(bson.M{"original_url": 1}).SetBatchSize(backfillBatch)
+			cursor, err := links.Find(ctx, bson.M{"url_key": bson.M{"$exists": false}}, opts)
+			if err != nil {
+				return err
+			}
+			defer cursor.Close(ctx)
+
+			var writes []mongo.WriteModel
+			flush := func() error {
+				if len(writes) == 0 {
+					return nil
+				}
+				_, err := links.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+				writes = writes[:0]
+				return err
+			}
+			for cursor.Next(ctx) {
+				var link struct {
+					ID          primitive.ObjectID `bson:"_id"`
+					OriginalURL string             `bson:"original_url"`
+				}
+				if err := cursor.Decode(&link); err != nil {
+					return err
+				}
+				// Matched on url_key still missing, so a link edited
+				// meanwhile keeps the key its edit wrote
+				writes = append(writes, mongo.NewUpdateOneModel().
+					SetFilter(bson.M{"_id": link.ID, "url_key": bson.M{"$exists": false}}).
+					SetUpdate(bson.M{"$set": bson.M{"url_key": validators.URLMatchKey(link.OriginalURL)}}))
+				if len(writes) == backfillBatch {
+					if err := flush(); err != nil {
+						return err
+					}
+				}
+			}
+			if err := cursor.Err(); err != nil {
+				return err
+			}
+			return flush()
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return nil
+		},
+	})
+}
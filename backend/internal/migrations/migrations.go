@@ -0,0 +1,255 @@
+// Package migrations changes stored data and indexes between releases. Each
+// migration lives in its own file, numbered in the order it runs, and
+// registers itself from init. The versions applied are recorded in the
+// schema_migrations collection, and a lease in it keeps replicas that start
+// together from running migrations twice
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	collectionName = "schema_migrations"
+	lockID         = "lock"
+	// lockLease is how long a lock holder may go without renewing it, after
+	// which it is presumed dead. The lease is renewed before each migration
+	lockLease = 15 * time.Minute
+	// lockPoll is how often a replica waiting for the lock tries again
+	lockPoll = 2 * time.Second
+
+	// Server error codes dropIndex tolerates
+	namespaceNotFound = 26
+	indexNotFound     = 27
+)
+
+var (
+	// ErrIrreversible is returned by Down for migrations that can't be undone
+	ErrIrreversible = errors.New("migration can't be reverted")
+	// ErrNothingApplied is returned by Down when no migration was applied
+	ErrNothingApplied = errors.New("no migrations applied")
+)
+
+// Migration is one change to the database. Up must be safe to run again:
+// a replica stopped between applying and recording it runs it anew. Down
+// is nil when the change can't be undone
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+	Down    func(ctx context.Context, db *mongo.Database) error
+}
+
+var registered []Migration
+
+// register adds a migration; each file's init calls it
+func register(m Migration) {
+	for _, other := range registered {
+		if other.Version == m.Version {
+			panic(fmt.Sprintf("migration %d registered twice", m.Version))
+		}
+	}
+	registered = append(registered, m)
+	slices.SortFunc(registered, func(a, b Migration) int { return a.Version - b.Version })
+}
+
+// record is a migration's entry in schema_migrations
+type record struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"applied_at"`
+	AppliedBy string    `bson:"applied_by"`
+}
+
+// Status is a migration and when it was applied, if it was
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Migrator applies and reverts the registered migrations
+type Migrator struct {
+	db         *mongo.Database
+	collection *mongo.Collection
+	// owner names this process in the lock and in records
+	owner string
+}
+
+// NewMigrator creates a migrator; instance names the replica running it
+func NewMigrator(client *mongo.Client, dbName, instance string) *Migrator {
+	db := client.Database(dbName)
+	return &Migrator{
+		db:         db,
+		collection: db.Collection(collectionName),
+		owner:      fmt.Sprintf("%s:%d", instance, os.Getpid()),
+	}
+}
+
+// Up applies every pending migration in order and returns how many ran
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	ran := 0
+	err := m.locked(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for _, migration := range registered {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.renew(ctx); err != nil {
+				return err
+			}
+			log.Printf("Applying migration %03d %s", migration.Version, migration.Name)
+			if err := migration.Up(ctx, m.db); err != nil {
+				return fmt.Errorf("migration %03d %s: %w", migration.Version, migration.Name, err)
+			}
+			_, err := m.collection.InsertOne(ctx, record{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now(),
+				AppliedBy: m.owner,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to record migration %03d: %w", migration.Version, err)
+			}
+			ran++
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// Down reverts the most recently applied migration and returns it
+func (m *Migrator) Down(ctx context.Context) (*Migration, error) {
+	var reverted *Migration
+	err := m.locked(ctx, func() error {
+		var last record
+		err := m.collection.FindOne(ctx, bson.M{"_id": bson.M{"$type": "number"}},
+			options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}})).Decode(&last)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrNothingApplied
+		}
+		if err != nil {
+			return err
+		}
+		index := slices.IndexFunc(registered, func(migration Migration) bool { return migration.Version == last.Version })
+		if index < 0 {
+			return fmt.Errorf("migration %03d %s was applied by a newer release", last.Version, last.Name)
+		}
+		migration := registered[index]
+		if migration.Down == nil {
+			return fmt.Errorf("migration %03d %s: %w", migration.Version, migration.Name, ErrIrreversible)
+		}
+		log.Printf("Reverting migration %03d %s", migration.Version, migration.Name)
+		if err := migration.Down(ctx, m.db); err != nil {
+			return fmt.Errorf("migration %03d %s: %w", migration.Version, migration.Name, err)
+		}
+		if _, err := m.collection.DeleteOne(ctx, bson.M{"_id": migration.Version}); err != nil {
+			return fmt.Errorf("failed to record reverting migration %03d: %w", migration.Version, err)
+		}
+		reverted = &migration
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every registered migration with when it was applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(registered))
+	for _, migration := range registered {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if at, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// applied returns when each applied migration was applied, by version
+func (m *Migrator) applied(ctx context.Context) (map[int]time.Time, error) {
+	cursor, err := m.collection.Find(ctx, bson.M{"_id": bson.M{"$type": "number"}})
+	if err != nil {
+		return nil, err
+	}
+	var records []record
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	applied := make(map[int]time.Time, len(records))
+	for _, r := range records {
+		applied[r.Version] = r.AppliedAt
+	}
+	return applied, nil
+}
+
+// locked runs fn holding the migration lock, waiting for it while another
+// replica holds it
+func (m *Migrator) locked(ctx context.Context, fn func() error) error {
+	for {
+		err := m.renew(ctx)
+		if err == nil {
+			break
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("failed to take the migration lock: %w", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockPoll):
+		}
+	}
+	defer func() {
+		// Released even when ctx has ended, so others needn't wait out the lease
+		releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if _, err := m.collection.DeleteOne(releaseCtx, bson.M{"_id": lockID, "owner": m.owner}); err != nil {
+			log.Printf("Failed to release the migration lock: %v", err)
+		}
+	}()
+	return fn()
+}
+
+// renew takes the lock, or extends it when this process holds it. While
+// another live process holds it the upsert collides with its document and
+// fails with a duplicate key error
+func (m *Migrator) renew(ctx context.Context) error {
+	now := time.Now()
+	filter := bson.M{
+		"_id": lockID,
+		"$or": bson.A{
+			bson.M{"owner": m.owner},
+			bson.M{"expires_at": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"owner": m.owner, "expires_at": now.Add(lockLease)}}
+	_, err := m.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// dropIndex drops an index, succeeding when it is already gone
+func dropIndex(ctx context.Context, collection *mongo.Collection, name string) error {
+	_, err := collection.Indexes().DropOne(ctx, name)
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && (cmdErr.Code == indexNotFound || cmdErr.Code == namespaceNotFound) {
+		return nil
+	}
+	return err
+}
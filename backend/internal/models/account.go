@@ -0,0 +1,105 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKey authenticates API calls on behalf of an account
+// Only a SHA-256 hash of the key is stored; the plaintext is shown once at creation
+// Rotation links keys into a chain: the old key gets a SuccessorID and an
+// ExpiresAt at the end of its grace window, the new key a PredecessorID
+type APIKey struct {
+	ID            primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	AccountID     string              `bson:"account_id" json:"account_id"`
+	Name          string              `bson:"name" json:"name"`
+	Prefix        string              `bson:"prefix" json:"prefix"`
+	KeyHash       string              `bson:"key_hash" json:"-"`
+	Scopes        []string            `bson:"scopes,omitempty" json:"scopes"`
+	Version       int                 `bson:"version" json:"version"`
+	PredecessorID *primitive.ObjectID `bson:"predecessor_id,omitempty" json:"predecessor_id,omitempty"`
+	SuccessorID   *primitive.ObjectID `bson:"successor_id,omitempty" json:"successor_id,omitempty"`
+	CreatedAt     time.Time           `bson:"created_at" json:"created_at"`
+	ExpiresAt     *time.Time          `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	RevokedAt     *time.Time          `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	UsageCount    int64               `bson:"usage_count" json:"usage_count"`
+	LastUsedAt    *time.Time          `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	// Plan names the link creation quota the key gets; LinkQuota overrides it
+	Plan      string     `bson:"plan,omitempty" json:"plan,omitempty"`
+	LinkQuota *LinkQuota `bson:"link_quota,omitempty" json:"link_quota,omitempty"`
+	// QuotaID is shared along a rotation chain so rotating a key doesn't
+	// reset its quota counters; empty means the key's own ID
+	QuotaID string `bson:"quota_id,omitempty" json:"-"`
+	// AllowedOrigins restricts browser requests made with the key to these
+	// origins; empty allows any
+	AllowedOrigins []string `bson:"allowed_origins,omitempty" json:"allowed_origins,omitempty"`
+	// Sandbox keys create short-lived test links, kept apart from the
+	// account's production links and left out of its quotas and analytics
+	Sandbox bool `bson:"sandbox,omitempty" json:"sandbox,omitempty"`
+	// ExternalID is the caller's stable name for a provisioned key, unique
+	// within its account; it moves to the key's successor on rotation
+	ExternalID string `bson:"external_id,omitempty" json:"external_id,omitempty"`
+}
+
+// LinkQuota caps how many links may be created per UTC day and calendar
+// month; 0 means no limit
+type LinkQuota struct {
+	Daily   int64 `bson:"daily" json:"daily"`
+	Monthly int64 `bson:"monthly" json:"monthly"`
+}
+
+// QuotaSubject identifies the key's quota counters
+func (k *APIKey) QuotaSubject() string {
+	if k.QuotaID != "" {
+		return k.QuotaID
+	}
+	return k.ID.Hex()
+}
+
+const (
+	// ScopeShorten allows creating links
+	ScopeShorten = "shorten"
+	// ScopeLinksWrite allows editing the account's existing links
+	ScopeLinksWrite = "links:write"
+	// ScopeStatsRead allows reading link stats and account usage
+	ScopeStatsRead = "stats:read"
+	// ScopeAdmin allows everything, including managing the account's keys
+	ScopeAdmin = "admin"
+)
+
+// ValidScope reports whether scope is one of the known key scopes
+func ValidScope(scope string) bool {
+	switch scope {
+	case ScopeShorten, ScopeLinksWrite, ScopeStatsRead, ScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// HasScope reports whether the key may act with scope
+// Keys issued before scopes existed have none and keep full access
+func (k *APIKey) HasScope(scope string) bool {
+	if len(k.Scopes) == 0 {
+		return true
+	}
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// AccountUsage is a snapshot of an account's counters from the quota subsystem
+type AccountUsage struct {
+	AccountID         string `json:"account_id"`
+	Period            string `json:"period"`
+	LinksCreated      int64  `json:"links_created"`
+	LinksThisMonth    int64  `json:"links_this_month"`
+	ActiveLinks       int64  `json:"active_links"`
+	ClicksThisMonth   int64  `json:"clicks_this_month"`
+	EventStorageBytes int64  `json:"event_storage_bytes"`
+	QuotaLimit        int64  `json:"quota_limit"`
+	QuotaRemaining    int64  `json:"quota_remaining"`
+}
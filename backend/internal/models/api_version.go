@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// APIVersionReport is the traffic one API version served across every replica
+type APIVersionReport struct {
+	Version      string     `json:"version"`
+	Requests     int64      `json:"requests"`
+	ClientErrors int64      `json:"client_errors"`
+	ServerErrors int64      `json:"server_errors"`
+	Accounts     int64      `json:"accounts"`
+	DeprecatedAt *time.Time `json:"deprecated_at,omitempty"`
+	SunsetAt     *time.Time `json:"sunset_at,omitempty"`
+}
+
+// APIVersionAccount is an account still calling an API version
+type APIVersionAccount struct {
+	AccountID string    `json:"account_id"`
+	LastSeen  time.Time `json:"last_seen"`
+}
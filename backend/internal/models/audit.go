@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+	// AuditActionRenew records a new expiration that turned the link back on
+	AuditActionRenew = "renew"
+	// Transfers of the link to another owner: offered, then transferred,
+	// declined or cancelled
+	AuditActionTransferOffered   = "transfer_offered"
+	AuditActionTransfer          = "transfer"
+	AuditActionTransferDeclined  = "transfer_declined"
+	AuditActionTransferCancelled = "transfer_cancelled"
+)
+
+// AuditChange records one field's value before and after a change
+type AuditChange struct {
+	From interface{} `bson:"from" json:"from"`
+	To   interface{} `bson:"to" json:"to"`
+}
+
+// AuditEntry is one change to a short URL, kept as its audit trail
+type AuditEntry struct {
+	ID        primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	Domain    string                 `bson:"domain,omitempty" json:"domain,omitempty"`
+	ShortCode string                 `bson:"short_code" json:"short_code"`
+	AccountID string                 `bson:"account_id,omitempty" json:"account_id,omitempty"`
+	Action    string                 `bson:"action" json:"action"`
+	Changes   map[string]AuditChange `bson:"changes,omitempty" json:"changes,omitempty"`
+	At        time.Time              `bson:"at" json:"at"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Campaign groups short links so their clicks can be reported together
+// Member links carry the campaign's hex ID in their campaign_id field
+type Campaign struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OwnerID     string             `bson:"owner_id" json:"owner_id"`
+	Name        string             `bson:"name" json:"name"`
+	Description string             `bson:"description,omitempty" json:"description,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// CampaignStats is the response of the campaign stats endpoint: click
+// analytics summed over every link in the campaign
+type CampaignStats struct {
+	*Campaign
+	LinkCount     int          `json:"link_count"`
+	ClickCount    int64        `json:"click_count"`
+	BotClickCount int64        `json:"bot_click_count"`
+	From          time.Time    `json:"from"`
+	To            time.Time    `json:"to"`
+	Granularity   string       `json:"granularity"`
+	Traffic       string       `json:"traffic"`
+	Analytics     string       `json:"analytics"`
+	TimeSeries    []TimeBucket `json:"timeseries"`
+	TopReferrers  []CountEntry `json:"top_referrers"`
+	TopCountries  []CountEntry `json:"top_countries"`
+	TopCities     []CityCount  `json:"top_cities"`
+	// TopLinks ranks member links by clicks in the range, keyed by short code
+	// (prefixed with the host for branded domains)
+	TopLinks []CountEntry `json:"top_links"`
+}
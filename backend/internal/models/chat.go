@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Chat platforms whose slash commands can shorten links
+const (
+	ChatSlack   = "slack"
+	ChatDiscord = "discord"
+)
+
+// ChatWorkspace links a Slack workspace or Discord server to the account its
+// slash commands create links for. It starts out pending, holding a connect
+// code, until someone runs the connect command in the workspace
+type ChatWorkspace struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Platform string             `bson:"platform" json:"platform"`
+	// WorkspaceID is the Slack team or Discord guild ID; empty while pending
+	WorkspaceID string `bson:"workspace_id,omitempty" json:"workspace_id,omitempty"`
+	AccountID   string `bson:"account_id" json:"account_id"`
+	// ConnectCode is the SHA-256 of the pending code, good until ConnectBy
+	ConnectCode string     `bson:"connect_code,omitempty" json:"-"`
+	ConnectBy   *time.Time `bson:"connect_by,omitempty" json:"-"`
+	// ConnectedBy is the platform user who ran the connect command
+	ConnectedBy string     `bson:"connected_by,omitempty" json:"connected_by,omitempty"`
+	CreatedAt   time.Time  `bson:"created_at" json:"created_at"`
+	ConnectedAt *time.Time `bson:"connected_at,omitempty" json:"connected_at,omitempty"`
+}
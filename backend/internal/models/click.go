@@ -0,0 +1,175 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ClickEvent is one recorded redirect, stored in the click-events collection
+type ClickEvent struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Domain         string             `bson:"domain,omitempty" json:"domain,omitempty"`
+	ShortCode      string             `bson:"short_code" json:"short_code"`
+	OwnerID        string             `bson:"owner_id,omitempty" json:"owner_id,omitempty"`
+	ClickedAt      time.Time          `bson:"clicked_at" json:"clicked_at"`
+	Referrer       string             `bson:"referrer,omitempty" json:"referrer,omitempty"`
+	ReferrerDomain string             `bson:"referrer_domain,omitempty" json:"referrer_domain,omitempty"`
+	UserAgent      string             `bson:"user_agent,omitempty" json:"user_agent,omitempty"`
+	Device         string             `bson:"device,omitempty" json:"device,omitempty"`
+	Country        string             `bson:"country,omitempty" json:"country,omitempty"`
+	City           string             `bson:"city,omitempty" json:"city,omitempty"`
+	Bot            bool               `bson:"bot,omitempty" json:"bot,omitempty"`
+	Variant        string             `bson:"variant,omitempty" json:"variant,omitempty"`
+}
+
+// How much click data stats are based on
+const (
+	// AnalyticsFull stats are aggregated from recorded click events
+	AnalyticsFull = "full"
+	// AnalyticsAggregate stats are of a link in privacy mode, which records no
+	// click events: only its lifetime click counts are known
+	AnalyticsAggregate = "aggregate"
+	// AnalyticsPartial stats combine links of both kinds
+	AnalyticsPartial = "partial"
+)
+
+// TimeBucket is the click count for one bucket of a time series
+type TimeBucket struct {
+	Start  time.Time `bson:"_id" json:"start"`
+	Clicks int64     `bson:"clicks" json:"clicks"`
+}
+
+// CountEntry is one row of a top-N breakdown (referrers, countries, ...)
+type CountEntry struct {
+	Key    string `bson:"_id" json:"key"`
+	Clicks int64  `bson:"clicks" json:"clicks"`
+}
+
+// ReferrerStats breaks a link's clicks in a range down by referring domain
+// Percentages are of TotalClicks, which includes clicks without a referrer
+type ReferrerStats struct {
+	ShortCode    string          `json:"short_code"`
+	Domain       string          `json:"domain,omitempty"`
+	From         time.Time       `json:"from"`
+	To           time.Time       `json:"to"`
+	Traffic      string          `json:"traffic"`
+	Analytics    string          `json:"analytics"`
+	TotalClicks  int64           `json:"total_clicks"`
+	DirectClicks int64           `json:"direct_clicks"` // Clicks without a Referer header
+	Referrers    []ReferrerShare `json:"referrers"`
+}
+
+// CityCount is the clicks from one city; city names repeat across countries,
+// so each is named with its country
+type CityCount struct {
+	Country string `json:"country"`
+	City    string `json:"city"`
+	Clicks  int64  `json:"clicks"`
+}
+
+// CountryStats breaks a link's clicks in a range down by country, each with
+// its top cities. Percentages are of TotalClicks, which includes clicks from
+// unknown locations
+type CountryStats struct {
+	ShortCode     string         `json:"short_code"`
+	Domain        string         `json:"domain,omitempty"`
+	From          time.Time      `json:"from"`
+	To            time.Time      `json:"to"`
+	Traffic       string         `json:"traffic"`
+	Analytics     string         `json:"analytics"`
+	TotalClicks   int64          `json:"total_clicks"`
+	UnknownClicks int64          `json:"unknown_clicks"` // Clicks without a known country
+	Countries     []CountryShare `json:"countries"`
+}
+
+// CountryShare is one country's clicks and share of the total
+type CountryShare struct {
+	Country    string      `json:"country"`
+	Clicks     int64       `json:"clicks"`
+	Percentage float64     `json:"percentage"`
+	Cities     []CityShare `json:"cities"`
+}
+
+// CityShare is one city's clicks and share of the link's total
+type CityShare struct {
+	City       string  `json:"city"`
+	Clicks     int64   `json:"clicks"`
+	Percentage float64 `json:"percentage"`
+}
+
+// ReferrerShare is one referring domain's clicks and share of the total
+type ReferrerShare struct {
+	Domain     string  `json:"domain"`
+	Clicks     int64   `json:"clicks"`
+	Percentage float64 `json:"percentage"`
+}
+
+// LinkStats is the response of the stats endpoint: the link plus click analytics
+type LinkStats struct {
+	*ShortURL
+	From         time.Time    `json:"from"`
+	To           time.Time    `json:"to"`
+	Granularity  string       `json:"granularity"`
+	Traffic      string       `json:"traffic"`
+	Analytics    string       `json:"analytics"`
+	TimeSeries   []TimeBucket `json:"timeseries"`
+	TopReferrers []CountEntry `json:"top_referrers"`
+	TopCountries []CountEntry `json:"top_countries"`
+	TopCities    []CityCount  `json:"top_cities"`
+	// Variants compares the link's A/B destinations over the range
+	Variants []VariantStats `json:"variants,omitempty"`
+}
+
+// LinkCounter is the public click count of a link shown by badges and
+// embedded widgets
+type LinkCounter struct {
+	ShortCode string
+	Domain    string
+	Title     string
+	Clicks    int64
+}
+
+// VariantStats is the clicks and reported conversions of one A/B variant
+type VariantStats struct {
+	Name           string  `json:"name"`
+	URL            string  `json:"url"`
+	Weight         int     `json:"weight"`
+	Clicks         int64   `json:"clicks"`
+	Conversions    int64   `json:"conversions"`
+	ConversionRate float64 `json:"conversion_rate"`
+}
+
+// Conversion is a goal reached by a visitor sent to one A/B variant, as
+// reported by the link's owner
+type Conversion struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Domain      string             `bson:"domain,omitempty" json:"domain,omitempty"`
+	ShortCode   string             `bson:"short_code" json:"short_code"`
+	Variant     string             `bson:"variant" json:"variant"`
+	ConvertedAt time.Time          `bson:"converted_at" json:"converted_at"`
+}
+
+// ClickAggregate is the number of clicks sharing one day, link and visitor
+// profile; it is the row format of analytics exports
+type ClickAggregate struct {
+	Day            string `bson:"day" json:"day" parquet:"day"`
+	Domain         string `bson:"domain" json:"domain" parquet:"domain"`
+	ShortCode      string `bson:"short_code" json:"short_code" parquet:"short_code"`
+	OwnerID        string `bson:"owner_id" json:"owner_id" parquet:"owner_id"`
+	Country        string `bson:"country" json:"country" parquet:"country"`
+	ReferrerDomain string `bson:"referrer_domain" json:"referrer_domain" parquet:"referrer_domain"`
+	Device         string `bson:"device" json:"device" parquet:"device"`
+	Bot            bool   `bson:"bot" json:"bot" parquet:"bot"`
+	Clicks         int64  `bson:"clicks" json:"clicks" parquet:"clicks"`
+}
+
+// ExportResult describes one analytics export written to object storage
+type ExportResult struct {
+	Key    string    `json:"key"`
+	Format string    `json:"format"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	Rows   int       `json:"rows"`
+	Bytes  int       `json:"bytes"`
+}
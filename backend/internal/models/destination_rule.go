@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Destination rule lists. With any allow rules, only hosts one matches may
+// be shortened; block rules apply either way and win over allow rules
+const (
+	DestinationBlock = "block"
+	DestinationAllow = "allow"
+)
+
+// DestinationRule blocks or allows shortening URLs whose host matches
+// Pattern: a host name, which also covers its subdomains, or an IP address,
+// where '*' stands for any run of characters
+type DestinationRule struct {
+	Pattern string `bson:"pattern" json:"pattern"`
+	List    string `bson:"list" json:"list"`
+	Reason  string `bson:"reason,omitempty" json:"reason,omitempty"`
+	// Configured rules come from the deployment's settings and can't be
+	// changed through the admin API
+	Configured bool      `bson:"-" json:"configured,omitempty"`
+	CreatedAt  time.Time `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DomainVerificationPrefix is prepended to a domain's host to name the TXT
+// record that proves ownership
+const DomainVerificationPrefix = "_shortener-verify."
+
+// Domain is a branded host name an account serves its short links on
+// Links and redirects only use a domain once it is verified
+type Domain struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Host              string             `bson:"host" json:"host"`
+	AccountID         string             `bson:"account_id" json:"account_id"`
+	Verified          bool               `bson:"verified" json:"verified"`
+	VerificationToken string             `bson:"verification_token" json:"verification_token"`
+	VerifiedAt        *time.Time         `bson:"verified_at,omitempty" json:"verified_at,omitempty"`
+	CreatedAt         time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// VerificationRecord is the TXT record name that must hold VerificationToken
+func (d *Domain) VerificationRecord() string {
+	return DomainVerificationPrefix + d.Host
+}
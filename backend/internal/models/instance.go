@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Instance is one running server replica as registered in Redis
+type Instance struct {
+	ID        string    `json:"id"`
+	Hostname  string    `json:"hostname"`
+	Port      string    `json:"port"`
+	Region    string    `json:"region,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	LastSeen  time.Time `json:"last_seen"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import "net/netip"
+
+// IPAccess restricts which visitor addresses a link redirects. Entries are
+// CIDR ranges; a bare address stands for itself. Deny wins over Allow, and
+// a non-empty Allow admits only the addresses it lists
+type IPAccess struct {
+	Allow []string `bson:"allow,omitempty" json:"allow,omitempty"`
+	Deny  []string `bson:"deny,omitempty" json:"deny,omitempty"`
+}
+
+// Allows reports whether a visitor from ip may follow the link. An address
+// that can't be parsed only passes when there is no Allow list
+func (a *IPAccess) Allows(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return len(a.Allow) == 0
+	}
+	addr = addr.Unmap()
+	if containsAddr(a.Deny, addr) {
+		return false
+	}
+	return len(a.Allow) == 0 || containsAddr(a.Allow, addr)
+}
+
+func containsAddr(ranges []string, addr netip.Addr) bool {
+	for _, raw := range ranges {
+		if prefix, err := ParseIPRange(raw); err == nil && prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseIPRange parses a CIDR range or a bare address, returning the masked prefix
+func ParseIPRange(raw string) (netip.Prefix, error) {
+	if addr, err := netip.ParseAddr(raw); err == nil {
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(raw)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return prefix.Masked(), nil
+}
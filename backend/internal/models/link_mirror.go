@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// MirrorReport is what one reconciliation of the Redis link mirror with
+// MongoDB found and repaired
+type MirrorReport struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Links      int64     `json:"links"`      // Live links compared with their copies
+	Copies     int64     `json:"copies"`     // Fields scanned in the mirror
+	Missing    int64     `json:"missing"`    // Links copied in that had no copy
+	Stale      int64     `json:"stale"`      // Copies rewritten that no longer matched their link
+	Orphaned   int64     `json:"orphaned"`   // Copies removed whose link is gone, trashed or shadowed
+	Tombstones int64     `json:"tombstones"` // Expired tombstones of deleted links cleared
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"net/http"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -8,13 +9,136 @@ import (
 
 // ShortURL represents a shortened URL in the database
 type ShortURL struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	OriginalURL string             `bson:"original_url" json:"original_url"`
-	ShortCode   string             `bson:"short_code" json:"short_code"`
-	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
-	ExpiresAt   *time.Time         `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
-	ClickCount  int64              `bson:"click_count" json:"click_count"`
-	IsActive    bool               `bson:"is_active" json:"is_active"`
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OriginalURL    string             `bson:"original_url" json:"original_url"`
+	URLKey         string             `bson:"url_key,omitempty" json:"-"` // validators.URLMatchKey of OriginalURL, for duplicate lookups
+	ShortCode      string             `bson:"short_code" json:"short_code"`
+	Domain         string             `bson:"domain,omitempty" json:"domain,omitempty"`
+	Title          string             `bson:"title,omitempty" json:"title,omitempty"`
+	Description    string             `bson:"description,omitempty" json:"description,omitempty"` // Read from the destination page with fetch_title
+	Notes          string             `bson:"notes,omitempty" json:"notes,omitempty"`             // Free-form notes for the owner's link management
+	Tags           []string           `bson:"tags,omitempty" json:"tags,omitempty"`               // Lowercase labels the owner filters their links by
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt      *time.Time         `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	ExpiresAt      *time.Time         `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	ClickCount     int64              `bson:"click_count" json:"click_count"`
+	BotClickCount  int64              `bson:"bot_click_count,omitempty" json:"bot_click_count"` // ClickCount only counts human visitors
+	LastClickedAt  *time.Time         `bson:"last_clicked_at,omitempty" json:"last_clicked_at,omitempty"`
+	IsActive       bool               `bson:"is_active" json:"is_active"`
+	OwnerID        string             `bson:"owner_id,omitempty" json:"owner_id,omitempty"`
+	DeviceRules    *DeviceRules       `bson:"device_rules,omitempty" json:"device_rules,omitempty"`
+	UTM            *UTMParams         `bson:"utm,omitempty" json:"utm,omitempty"`
+	Indexable      bool               `bson:"indexable,omitempty" json:"indexable,omitempty"`
+	NoIndex        bool               `bson:"noindex,omitempty" json:"noindex,omitempty"` // Redirects ask search engines not to index the link
+	Honeytoken     bool               `bson:"honeytoken,omitempty" json:"honeytoken,omitempty"`
+	MaxClicks      int64              `bson:"max_clicks,omitempty" json:"max_clicks,omitempty"`
+	LimitedClicks  int64              `bson:"limited_clicks,omitempty" json:"limited_clicks,omitempty"` // Redirects used towards MaxClicks
+	SingleUse      bool               `bson:"single_use,omitempty" json:"single_use,omitempty"`         // MaxClicks is 1: the link redirects once, then deactivates
+	EdgeCacheTTL   *int64             `bson:"edge_cache_ttl,omitempty" json:"edge_cache_ttl,omitempty"` // Seconds a CDN may cache the redirect; nil uses the default
+	CacheMaxAge    *int64             `bson:"cache_max_age,omitempty" json:"cache_max_age,omitempty"`   // Seconds browsers may cache the redirect; nil uses the default
+	CampaignID     string             `bson:"campaign_id,omitempty" json:"campaign_id,omitempty"`
+	Variants       []Variant          `bson:"variants,omitempty" json:"variants,omitempty"`           // A/B destinations replacing OriginalURL
+	ActivateAt     *time.Time         `bson:"activate_at,omitempty" json:"activate_at,omitempty"`     // The link redirects from this time on
+	DeactivateAt   *time.Time         `bson:"deactivate_at,omitempty" json:"deactivate_at,omitempty"` // and until this time
+	FallbackURL    string             `bson:"fallback_url,omitempty" json:"fallback_url,omitempty"`   // Where visits go while the link does not redirect
+	IPAccess       *IPAccess          `bson:"ip_access,omitempty" json:"ip_access,omitempty"`
+	RateLimit      *LinkRateLimit     `bson:"rate_limit,omitempty" json:"rate_limit,omitempty"`
+	Cloak          string             `bson:"cloak,omitempty" json:"cloak,omitempty"`                     // One of the Cloak* modes; empty redirects normally
+	ForwardQuery   bool               `bson:"forward_query,omitempty" json:"forward_query,omitempty"`     // Pass the short URL's query string on to the destination
+	RedirectStatus int                `bson:"redirect_status,omitempty" json:"redirect_status,omitempty"` // 0 picks 301 or 307 by whether the redirect is cacheable
+	Privacy        bool               `bson:"privacy,omitempty" json:"privacy,omitempty"`                 // Keep only the aggregate click count, recording no click events
+	SigningKey     string             `bson:"signing_key,omitempty" json:"-"`                             // Set on signed links: parameters added to them must be signed with it; only creating and replacing the link return it
+	DeletedAt      *time.Time         `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`           // Set while the link is in the trash
+	Sandbox        bool               `bson:"sandbox,omitempty" json:"sandbox,omitempty"`                 // Created with a sandbox API key, served under /sandbox/
+	// ManageTokenID names the live management token of an anonymous link;
+	// replacing it revokes the tokens issued before
+	ManageTokenID string `bson:"manage_token_id,omitempty" json:"-"`
+	// ExpiryNotifiedAt is when the owner was warned of the link's expiration,
+	// and NotifiedMilestone the highest click milestone they heard about
+	ExpiryNotifiedAt  *time.Time `bson:"expiry_notified_at,omitempty" json:"-"`
+	NotifiedMilestone int64      `bson:"notified_milestone,omitempty" json:"-"`
+	// Shadow is never exposed in responses, so a shadow-banned owner can't tell
+	Shadow *ShadowAccess `bson:"shadow,omitempty" json:"-"`
+	// Health is the latest check of the destination; HealthCheckedAt is when
+	// a checker last took the link, so replicas don't check it twice
+	Health          *LinkHealth `bson:"health,omitempty" json:"health,omitempty"`
+	HealthCheckedAt *time.Time  `bson:"health_checked_at,omitempty" json:"-"`
+}
+
+// Link health statuses; every status but alive means the destination is dead
+const (
+	HealthAlive       = "alive"
+	HealthNotFound    = "not_found"
+	HealthHTTPError   = "http_error"
+	HealthTimeout     = "timeout"
+	HealthSSLError    = "ssl_error"
+	HealthUnreachable = "unreachable"
+)
+
+// LinkHealth is the outcome of the latest check of a link's destination
+type LinkHealth struct {
+	Status     string    `bson:"status" json:"status"`
+	HTTPStatus int       `bson:"http_status,omitempty" json:"http_status,omitempty"`
+	Error      string    `bson:"error,omitempty" json:"error,omitempty"`
+	CheckedAt  time.Time `bson:"checked_at" json:"checked_at"`
+	// DeadSince is when the destination started failing, unset while alive
+	DeadSince *time.Time `bson:"dead_since,omitempty" json:"dead_since,omitempty"`
+}
+
+// Cloaking modes: instead of a 3xx redirect, visitors get a page that shows
+// the destination in a full-window frame, keeping the short URL in the
+// address bar, or that forwards to it with a meta refresh
+const (
+	CloakFrame = "frame"
+	CloakMeta  = "meta"
+)
+
+// ValidCloak reports whether mode is a cloaking mode, or empty for none
+func ValidCloak(mode string) bool {
+	return mode == "" || mode == CloakFrame || mode == CloakMeta
+}
+
+// ValidRedirectStatus reports whether status is a redirect status a link may
+// use, or 0 for the default
+func ValidRedirectStatus(status int) bool {
+	switch status {
+	case 0, http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// Scheduled reports whether the link's schedule allows redirects at t
+func (u *ShortURL) Scheduled(t time.Time) bool {
+	if u.ActivateAt != nil && t.Before(*u.ActivateAt) {
+		return false
+	}
+	return u.DeactivateAt == nil || t.Before(*u.DeactivateAt)
+}
+
+// DeviceRules holds per-platform destinations that override OriginalURL
+// Empty fields fall back to OriginalURL
+type DeviceRules struct {
+	IOS     string `bson:"ios,omitempty" json:"ios,omitempty" form:"ios_url"`
+	Android string `bson:"android,omitempty" json:"android,omitempty" form:"android_url"`
+	Desktop string `bson:"desktop,omitempty" json:"desktop,omitempty" form:"desktop_url"`
+}
+
+// Variant is one destination of an A/B split; visitors are assigned variants
+// in proportion to their weights and keep the one they got
+type Variant struct {
+	Name   string `bson:"name" json:"name"`
+	URL    string `bson:"url" json:"url"`
+	Weight int    `bson:"weight" json:"weight"`
+}
+
+// UTMParams is a per-link UTM template merged into the destination on redirect
+type UTMParams struct {
+	Source   string `bson:"source,omitempty" json:"source,omitempty" form:"utm_source"`
+	Medium   string `bson:"medium,omitempty" json:"medium,omitempty" form:"utm_medium"`
+	Campaign string `bson:"campaign,omitempty" json:"campaign,omitempty" form:"utm_campaign"`
+	Term     string `bson:"term,omitempty" json:"term,omitempty" form:"utm_term"`
+	Content  string `bson:"content,omitempty" json:"content,omitempty" form:"utm_content"`
 }
 
 // HealthCheck represents a health check record in the database
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// NotificationPreferences are the emails an account has opted into about its
+// links. Accounts without preferences get none
+type NotificationPreferences struct {
+	AccountID string `bson:"account_id" json:"account_id"`
+	Email     string `bson:"email,omitempty" json:"email"`
+	// ExpiryWarningDays is how many days before a link expires its owner is
+	// warned; 0 sends no warnings
+	ExpiryWarningDays int `bson:"expiry_warning_days,omitempty" json:"expiry_warning_days"`
+	// ClickMilestones are the click counts, in ascending order, at which the
+	// owner hears about a link
+	ClickMilestones []int64   `bson:"click_milestones,omitempty" json:"click_milestones"`
+	UpdatedAt       time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// Bounds of notification preferences
+const (
+	MaxExpiryWarningDays = 30
+	MaxClickMilestones   = 10
+)
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Organization is a shared link workspace
+// Its links, keys, domains and quotas belong to AccountID, so everything
+// scoped to an account works for an organization too
+type Organization struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	AccountID string             `bson:"account_id" json:"account_id"`
+	Name      string             `bson:"name" json:"name"`
+	CreatedBy string             `bson:"created_by" json:"created_by"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	// ExternalID is the stable name provisioning tools manage the organization by
+	ExternalID string `bson:"external_id,omitempty" json:"external_id,omitempty"`
+}
+
+// OrganizationAccountID is the account namespace of an organization's workspace
+func OrganizationAccountID(id primitive.ObjectID) string {
+	return "org_" + id.Hex()
+}
+
+// Membership gives an account a role in an organization
+type Membership struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	OrgID     primitive.ObjectID `bson:"org_id" json:"org_id"`
+	AccountID string             `bson:"account_id" json:"account_id"`
+	Role      string             `bson:"role" json:"role"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// OrganizationWithRole is an organization as seen by one of its members
+type OrganizationWithRole struct {
+	Organization `bson:",inline"`
+	Role         string `json:"role"`
+}
+
+const (
+	// RoleAdmin manages members and keys and can do everything editors can
+	RoleAdmin = "admin"
+	// RoleEditor creates and edits the organization's links
+	RoleEditor = "editor"
+	// RoleViewer only reads links and stats
+	RoleViewer = "viewer"
+)
+
+// ValidRole reports whether role is one of the organization roles
+func ValidRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleEditor, RoleViewer:
+		return true
+	}
+	return false
+}
+
+// RoleAllows reports whether a member with role may act with an API key scope
+func RoleAllows(role, scope string) bool {
+	switch role {
+	case RoleAdmin:
+		return true
+	case RoleEditor:
+		return scope == ScopeShorten || scope == ScopeLinksWrite || scope == ScopeStatsRead
+	case RoleViewer:
+		return scope == ScopeStatsRead
+	}
+	return false
+}
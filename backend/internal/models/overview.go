@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+// Overview is the system-wide summary shown on the admin dashboard
+type Overview struct {
+	GeneratedAt time.Time           `json:"generated_at"`
+	Links       LinkTotals          `json:"links"`
+	Redirects   RedirectTotals      `json:"redirects"`
+	TopLinks    []TopLink           `json:"top_links"`
+	Storage     []CollectionStorage `json:"storage"`
+	Cache       CacheStats          `json:"cache"`
+}
+
+// LinkTotals counts links across the hot and archive collections
+type LinkTotals struct {
+	Total    int64 `json:"total"`
+	Archived int64 `json:"archived"`
+	Trashed  int64 `json:"trashed"`
+	// CreatedLast7Days and CreatedLast30Days include today
+	CreatedLast7Days  int64 `json:"created_last_7_days"`
+	CreatedLast30Days int64 `json:"created_last_30_days"`
+	// CreatedPerDay has one entry per UTC day of the last 30, oldest first
+	CreatedPerDay []DailyCount `json:"created_per_day"`
+}
+
+// DailyCount is a count for one UTC day, formatted YYYY-MM-DD
+type DailyCount struct {
+	Day   string `bson:"_id" json:"day"`
+	Count int64  `bson:"count" json:"count"`
+}
+
+// RedirectTotals are the clicks counted on every link
+type RedirectTotals struct {
+	Total int64 `json:"total"`
+	Human int64 `json:"human"`
+	Bot   int64 `json:"bot"`
+}
+
+// TopLink is one of the most clicked links
+type TopLink struct {
+	Domain    string `bson:"domain" json:"domain,omitempty"`
+	ShortCode string `bson:"short_code" json:"short_code"`
+	Clicks    int64  `bson:"click_count" json:"clicks"`
+}
+
+// CollectionStorage is the size of one MongoDB collection
+type CollectionStorage struct {
+	Name         string `json:"name"`
+	Documents    int64  `json:"documents"`
+	SizeBytes    int64  `json:"size_bytes"`
+	StorageBytes int64  `json:"storage_bytes"`
+	IndexBytes   int64  `json:"index_bytes"`
+}
+
+// CacheStats are the link cache's lookups across every replica
+type CacheStats struct {
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+	// Regions breaks the lookups down by the REGION of the replicas
+	// making them; replicas without one are only in the totals
+	Regions map[string]RegionCacheStats `json:"regions,omitempty"`
+}
+
+// RegionCacheStats are the link cache's lookups in one region
+type RegionCacheStats struct {
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Periods a link's redirects can be capped over
+const (
+	RateLimitHour = "hour"
+	RateLimitDay  = "day"
+)
+
+// LinkRateLimit caps how many redirects a link gives per hour or day, for
+// links such as coupons. Windows are fixed and start on the UTC hour or day.
+// Visits over the cap are sent to RetryURL, or get a 429 without it
+type LinkRateLimit struct {
+	Limit    int64  `bson:"limit" json:"limit"`
+	Period   string `bson:"period" json:"period"`
+	RetryURL string `bson:"retry_url,omitempty" json:"retry_url,omitempty"`
+}
+
+// Window is the length of the period, or 0 for an unknown one
+func (l *LinkRateLimit) Window() time.Duration {
+	switch l.Period {
+	case RateLimitHour:
+		return time.Hour
+	case RateLimitDay:
+		return 24 * time.Hour
+	default:
+		return 0
+	}
+}
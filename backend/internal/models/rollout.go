@@ -0,0 +1,18 @@
+package models
+
+// RolloutReport compares the redirects a rollout flag was on for with the
+// ones it was off for, across every replica
+type RolloutReport struct {
+	Flag       string     `json:"flag"`
+	Percentage int        `json:"percentage"`
+	On         RolloutArm `json:"on"`
+	Off        RolloutArm `json:"off"`
+}
+
+// RolloutArm is the redirect traffic on one side of a rollout flag
+type RolloutArm struct {
+	Requests     int64   `json:"requests"`
+	Errors       int64   `json:"errors"`
+	ErrorRate    float64 `json:"error_rate"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+}
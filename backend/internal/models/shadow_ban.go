@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ShadowBan marks an account suspected of abuse. Links it creates or edits
+// keep working for the account itself but are inert for everyone else
+type ShadowBan struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	AccountID string             `bson:"account_id" json:"account_id"`
+	Reason    string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// ShadowAccess limits who a shadow-banned account's link resolves for: the
+// owning account's API keys and the IPs it was created or edited from
+type ShadowAccess struct {
+	CreatorIPs []string `bson:"creator_ips,omitempty"`
+}
+
+// Allows reports whether a visitor from ip, authenticated as accountID,
+// should see the link of ownerID
+func (a *ShadowAccess) Allows(ownerID, accountID, ip string) bool {
+	if accountID != "" && accountID == ownerID {
+		return true
+	}
+	for _, creatorIP := range a.CreatorIPs {
+		if creatorIP == ip {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Link transfer statuses. A pending transfer past its ExpiresAt is expired
+// even before it is marked so
+const (
+	TransferPending   = "pending"
+	TransferAccepted  = "accepted"
+	TransferDeclined  = "declined"
+	TransferCancelled = "cancelled"
+	TransferExpired   = "expired"
+)
+
+// LinkTransfer is an offer to hand a link, stats included, to another
+// account or organization workspace. Ownership only moves once someone
+// acting as the recipient accepts
+type LinkTransfer struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Domain      string             `bson:"domain,omitempty" json:"domain,omitempty"`
+	ShortCode   string             `bson:"short_code" json:"short_code"`
+	FromAccount string             `bson:"from_account" json:"from_account"`
+	ToAccount   string             `bson:"to_account" json:"to_account"`
+	// OfferedBy and RespondedBy are the callers' own accounts, which differ
+	// from the workspaces in organizations
+	OfferedBy   string     `bson:"offered_by" json:"offered_by"`
+	Status      string     `bson:"status" json:"status"`
+	CreatedAt   time.Time  `bson:"created_at" json:"created_at"`
+	ExpiresAt   time.Time  `bson:"expires_at" json:"expires_at"`
+	RespondedBy string     `bson:"responded_by,omitempty" json:"responded_by,omitempty"`
+	RespondedAt *time.Time `bson:"responded_at,omitempty" json:"responded_at,omitempty"`
+}
+
+// Open reports whether the transfer may still be accepted
+func (t *LinkTransfer) Open(now time.Time) bool {
+	return t.Status == TransferPending && now.Before(t.ExpiresAt)
+}
@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// APIKeyRepository handles MongoDB operations for API keys
+type APIKeyRepository struct {
+	collection *mongo.Collection
+}
+
+// NewAPIKeyRepository creates a new API key repository instance
+func NewAPIKeyRepository(ctx context.Context, client *mongo.Client, dbName, collectionName string) (*APIKeyRepository, error) {
+	collection := client.Database(dbName).Collection(collectionName)
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "key_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "account_id", Value: 1}, {Key: "external_id", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"external_id": bson.M{"$exists": true}}),
+		},
+	}
+	err := ensureIndexes(ctx, collectionName, func(ctx context.Context) error {
+		_, err := collection.Indexes().CreateMany(ctx, indexes)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &APIKeyRepository{
+		collection: collection,
+	}, nil
+}
+
+// CreateAPIKey saves a new API key
+func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	if key.CreatedAt.IsZero() {
+		key.CreatedAt = time.Now()
+	}
+	result, err := r.collection.InsertOne(ctx, key)
+	if err != nil {
+		return translateError(err)
+	}
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		key.ID = id
+	}
+	return nil
+}
+
+// GetAPIKeyByHash retrieves an API key by the hash of its plaintext value
+// Returns nil, nil when no key matches
+func (r *APIKeyRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.collection.FindOne(ctx, bson.M{"key_hash": keyHash}).Decode(&key)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetAPIKeyByID retrieves an API key by ID, returning nil, nil when it doesn't exist
+func (r *APIKeyRepository) GetAPIKeyByID(ctx context.Context, id primitive.ObjectID) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&key)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetAPIKeyByExternalID retrieves the account's key provisioned as
+// externalID, returning nil, nil when there is none
+func (r *APIKeyRepository) GetAPIKeyByExternalID(ctx context.Context, accountID, externalID string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.collection.FindOne(ctx, bson.M{"account_id": accountID, "external_id": externalID}).Decode(&key)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// ListAPIKeysByAccount returns one page of an account's keys, newest first
+func (r *APIKeyRepository) ListAPIKeysByAccount(ctx context.Context, accountID string, page PageRequest) (*Page[models.APIKey], error) {
+	return findPage[models.APIKey](ctx, r.collection, bson.M{"account_id": accountID}, page, CreatedListSpec)
+}
+
+// SetSuccessor marks key id as rotated: it stays valid until expiresAt
+func (r *APIKeyRepository) SetSuccessor(ctx context.Context, id, successorID primitive.ObjectID, expiresAt time.Time) error {
+	update := bson.M{"$set": bson.M{"successor_id": successorID, "expires_at": expiresAt}}
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+// MoveExternalID hands the external ID of key from over to key to
+func (r *APIKeyRepository) MoveExternalID(ctx context.Context, from, to primitive.ObjectID, externalID string) error {
+	if _, err := r.collection.UpdateOne(ctx, bson.M{"_id": from}, bson.M{"$unset": bson.M{"external_id": ""}}); err != nil {
+		return err
+	}
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": to}, bson.M{"$set": bson.M{"external_id": externalID}})
+	return translateError(err)
+}
+
+// UpdateSettings replaces the name, scopes, plan, quota override and
+// allowed origins of key.ID with key's; empty values are removed
+// Returns nil, nil when the key doesn't exist
+func (r *APIKeyRepository) UpdateSettings(ctx context.Context, key *models.APIKey) (*models.APIKey, error) {
+	set := bson.M{"name": key.Name, "scopes": key.Scopes}
+	unset := bson.M{}
+	if key.Plan != "" {
+		set["plan"] = key.Plan
+	} else {
+		unset["plan"] = ""
+	}
+	if key.LinkQuota != nil {
+		set["link_quota"] = key.LinkQuota
+	} else {
+		unset["link_quota"] = ""
+	}
+	if len(key.AllowedOrigins) > 0 {
+		set["allowed_origins"] = key.AllowedOrigins
+	} else {
+		unset["allowed_origins"] = ""
+	}
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var updated models.APIKey
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": key.ID}, update, opts).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// SetQuota sets the key's plan and quota override and returns the updated key
+// Empty values are removed; returns nil, nil when the key doesn't exist
+func (r *APIKeyRepository) SetQuota(ctx context.Context, id primitive.ObjectID, plan string, quota *models.LinkQuota) (*models.APIKey, error) {
+	set, unset := bson.M{}, bson.M{}
+	if plan != "" {
+		set["plan"] = plan
+	} else {
+		unset["plan"] = ""
+	}
+	if quota != nil {
+		set["link_quota"] = quota
+	} else {
+		unset["link_quota"] = ""
+	}
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var key models.APIKey
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&key)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// SetAllowedOrigins replaces the origins key id may be used from; empty
+// removes the restriction. Returns nil when the key doesn't exist
+func (r *APIKeyRepository) SetAllowedOrigins(ctx context.Context, id primitive.ObjectID, origins []string) (*models.APIKey, error) {
+	update := bson.M{"$set": bson.M{"allowed_origins": origins}}
+	if len(origins) == 0 {
+		update = bson.M{"$unset": bson.M{"allowed_origins": ""}}
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var key models.APIKey
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&key)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// RevokeAPIKey revokes a key immediately and frees its external ID, so
+// provisioning the ID again issues a new key
+func (r *APIKeyRepository) RevokeAPIKey(ctx context.Context, id primitive.ObjectID) error {
+	update := bson.M{
+		"$set":   bson.M{"revoked_at": time.Now()},
+		"$unset": bson.M{"external_id": ""},
+	}
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id, "revoked_at": bson.M{"$exists": false}}, update)
+	return err
+}
+
+// RecordUsage bumps the key's usage counter and last-used timestamp
+func (r *APIKeyRepository) RecordUsage(ctx context.Context, id primitive.ObjectID) error {
+	update := bson.M{
+		"$inc": bson.M{"usage_count": 1},
+		"$set": bson.M{"last_used_at": time.Now()},
+	}
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
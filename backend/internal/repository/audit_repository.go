@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// AuditRepository handles MongoDB operations for the link audit trail
+type AuditRepository struct {
+	collection *mongo.Collection
+}
+
+// NewAuditRepository creates a new audit repository instance
+func NewAuditRepository(ctx context.Context, client *mongo.Client, dbName, collectionName string) (*AuditRepository, error) {
+	collection := client.Database(dbName).Collection(collectionName)
+
+	indexModel := mongo.IndexModel{
+		Keys: bson.D{{Key: "short_code", Value: 1}, {Key: "_id", Value: -1}},
+	}
+	err := ensureIndexes(ctx, collectionName, func(ctx context.Context) error {
+		_, err := collection.Indexes().CreateOne(ctx, indexModel)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuditRepository{
+		collection: collection,
+	}, nil
+}
+
+// CreateEntry appends an entry to the audit trail
+func (r *AuditRepository) CreateEntry(ctx context.Context, entry *models.AuditEntry) error {
+	if entry.At.IsZero() {
+		entry.At = time.Now()
+	}
+	_, err := r.collection.InsertOne(ctx, entry)
+	return err
+}
+
+// AuditListSpec is how a link's audit trail sorts and filters. Entries are
+// written as they happen, so _id serves the order of at
+var AuditListSpec = &ListSpec{
+	Sorts:       map[string]string{"at": "_id"},
+	DefaultSort: "-at",
+	Filters: map[string]Filter{
+		"action": {Field: "action", Values: []string{models.AuditActionUpdate, models.AuditActionDelete, models.AuditActionRestore}},
+	},
+}
+
+// ListByShortCode returns one page of a link's audit trail, newest first
+// unless page asks otherwise
+func (r *AuditRepository) ListByShortCode(ctx context.Context, domain, shortCode string, page PageRequest) (*Page[models.AuditEntry], error) {
+	return findPage[models.AuditEntry](ctx, r.collection, linkFilter(domain, shortCode), page, AuditListSpec)
+}
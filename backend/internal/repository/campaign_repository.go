@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CampaignRepository handles MongoDB operations for campaigns
+// Membership is stored on the links, see MongoRepository.ListByCampaign
+type CampaignRepository struct {
+	collection *mongo.Collection
+}
+
+// NewCampaignRepository creates a new campaign repository instance
+func NewCampaignRepository(ctx context.Context, client *mongo.Client, dbName, collectionName string) (*CampaignRepository, error) {
+	collection := client.Database(dbName).Collection(collectionName)
+
+	indexModels := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "owner_id", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "owner_id", Value: 1}, {Key: "name", Value: 1}, {Key: "_id", Value: 1}}},
+	}
+	err := ensureIndexes(ctx, collectionName, func(ctx context.Context) error {
+		_, err := collection.Indexes().CreateMany(ctx, indexModels)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &CampaignRepository{
+		collection: collection,
+	}, nil
+}
+
+// Create saves a new campaign
+func (r *CampaignRepository) Create(ctx context.Context, campaign *models.Campaign) error {
+	if campaign.ID.IsZero() {
+		campaign.ID = primitive.NewObjectID()
+	}
+	if campaign.CreatedAt.IsZero() {
+		campaign.CreatedAt = time.Now()
+	}
+	_, err := r.collection.InsertOne(ctx, campaign)
+	return translateError(err)
+}
+
+// Get returns the campaign with id; a missing campaign is ErrNotFound
+func (r *CampaignRepository) Get(ctx context.Context, id primitive.ObjectID) (*models.Campaign, error) {
+	var campaign models.Campaign
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&campaign); err != nil {
+		return nil, translateError(err)
+	}
+	return &campaign, nil
+}
+
+// CampaignListSpec is how campaign lists sort: newest first by default, or
+// by name
+var CampaignListSpec = &ListSpec{
+	Sorts: map[string]string{
+		"created_at": "_id",
+		"name":       "name",
+	},
+	DefaultSort: "-created_at",
+}
+
+// ListByOwner returns one page of ownerID's campaigns, sorted as
+// CampaignListSpec allows
+func (r *CampaignRepository) ListByOwner(ctx context.Context, ownerID string, req PageRequest) (*Page[models.Campaign], error) {
+	page, err := findPage[models.Campaign](ctx, r.collection, bson.M{"owner_id": ownerID}, req, CampaignListSpec)
+	return page, translateError(err)
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/breaker"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// LinkStore holds copies of links for the redirect path, looked up by domain
+// and short code. MongoDB stays the system of record; a LinkStore only has to
+// answer the one query every redirect makes.
+// Writes carry the time their copy was read from MongoDB, and a write never
+// replaces one read later, so a slow backfill can't undo a newer update
+type LinkStore interface {
+	GetShortURLByCode(ctx context.Context, domain, shortCode string) (*models.ShortURL, error)
+	SaveShortURL(ctx context.Context, shortURL *models.ShortURL, readAt time.Time) error
+	DeleteShortURL(ctx context.Context, domain, shortCode string, readAt time.Time) error
+}
+
+// CassandraRepository is a LinkStore on Cassandra or Scylla. Each link is its
+// own partition keyed by domain and short code, so a redirect is a single
+// partition read that scales out with the cluster. Links are stored as BSON,
+// so the table doesn't change when the link model does
+type CassandraRepository struct {
+	session *gocql.Session
+	breaker *breaker.Breaker
+}
+
+// CassandraOptions says how to reach the cluster
+type CassandraOptions struct {
+	Hosts    []string
+	Keyspace string
+	Username string
+	Password string
+	// Consistency is a CQL consistency level such as LOCAL_QUORUM
+	Consistency string
+	Timeout     time.Duration
+}
+
+// NewCassandraRepository connects to the cluster and creates the links table
+// in the keyspace, which must already exist: its replication is up to operators
+func NewCassandraRepository(opts CassandraOptions, cb *breaker.Breaker) (*CassandraRepository, error) {
+	consistency, err := gocql.ParseConsistencyWrapper(opts.Consistency)
+	if err != nil {
+		return nil, err
+	}
+	cluster := gocql.NewCluster(opts.Hosts...)
+	cluster.Keyspace = opts.Keyspace
+	cluster.Consistency = consistency
+	cluster.Timeout = opts.Timeout
+	if opts.Username != "" {
+		cluster.Authenticator = gocql.PasswordAuthenticator{Username: opts.Username, Password: opts.Password}
+	}
+	// Sends each query straight to a replica of its partition
+	cluster.PoolConfig.HostSelectionPolicy = gocql.TokenAwareHostPolicy(gocql.RoundRobinHostPolicy())
+	session, err := cluster.CreateSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Cassandra: %w", err)
+	}
+	err = session.Query(`CREATE TABLE IF NOT EXISTS links (
+		domain text,
+		short_code text,
+		link blob,
+		PRIMARY KEY ((domain, short_code))
+	)`).Exec()
+	if err != nil {
+		session.Close()
+		return nil, fmt.Errorf("failed to create the links table: %w", err)
+	}
+	return &CassandraRepository{session: session, breaker: cb}, nil
+}
+
+// Close ends the session
+func (r *CassandraRepository) Close() {
+	r.session.Close()
+}
+
+// GetShortURLByCode retrieves the stored copy of a link
+func (r *CassandraRepository) GetShortURLByCode(ctx context.Context, domain, shortCode string) (*models.ShortURL, error) {
+	var raw []byte
+	err := r.breaker.Do(func() error {
+		return r.session.Query(`SELECT link FROM links WHERE domain = ? AND short_code = ?`, domain, shortCode).
+			WithContext(ctx).Scan(&raw)
+	})
+	if err != nil {
+		return nil, translateCassandraError(err)
+	}
+	var shortURL models.ShortURL
+	if err := bson.Unmarshal(raw, &shortURL); err != nil {
+		return nil, err
+	}
+	return &shortURL, nil
+}
+
+// SaveShortURL writes a copy of shortURL read at readAt, replacing any read earlier
+func (r *CassandraRepository) SaveShortURL(ctx context.Context, shortURL *models.ShortURL, readAt time.Time) error {
+	raw, err := bson.Marshal(shortURL)
+	if err != nil {
+		return err
+	}
+	return translateCassandraError(r.breaker.Do(func() error {
+		return r.session.Query(`INSERT INTO links (domain, short_code, link) VALUES (?, ?, ?) USING TIMESTAMP ?`,
+			shortURL.Domain, shortURL.ShortCode, raw, readAt.UnixMicro()).WithContext(ctx).Exec()
+	}))
+}
+
+// DeleteShortURL removes the copy of a link found missing at readAt;
+// removing a missing copy is not an error
+func (r *CassandraRepository) DeleteShortURL(ctx context.Context, domain, shortCode string, readAt time.Time) error {
+	return translateCassandraError(r.breaker.Do(func() error {
+		return r.session.Query(`DELETE FROM links USING TIMESTAMP ? WHERE domain = ? AND short_code = ?`,
+			readAt.UnixMicro(), domain, shortCode).WithContext(ctx).Exec()
+	}))
+}
+
+// IsExpectedCassandraError reports errors that mean Cassandra answered
+// normally, so they should not count towards tripping a circuit breaker
+func IsExpectedCassandraError(err error) bool {
+	return errors.Is(err, gocql.ErrNotFound) || errors.Is(err, context.Canceled)
+}
+
+// translateCassandraError maps driver and breaker errors onto the repository errors
+func translateCassandraError(err error) error {
+	var unavailable *gocql.RequestErrUnavailable
+	var readTimeout *gocql.RequestErrReadTimeout
+	var writeTimeout *gocql.RequestErrWriteTimeout
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gocql.ErrNotFound):
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	case errors.Is(err, breaker.ErrOpen), errors.Is(err, gocql.ErrNoConnections), errors.Is(err, gocql.ErrTimeoutNoResponse),
+		errors.Is(err, gocql.ErrConnectionClosed), errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &unavailable), errors.As(err, &readTimeout), errors.As(err, &writeTimeout):
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ChatRepository handles MongoDB operations for chat workspaces
+type ChatRepository struct {
+	collection *mongo.Collection
+}
+
+// NewChatRepository creates a new chat workspace repository instance
+func NewChatRepository(ctx context.Context, client *mongo.Client, dbName, collectionName string) (*ChatRepository, error) {
+	collection := client.Database(dbName).Collection(collectionName)
+
+	indexModels := []mongo.IndexModel{
+		// A workspace is connected to at most one account
+		{
+			Keys: bson.D{{Key: "platform", Value: 1}, {Key: "workspace_id", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"workspace_id": bson.M{"$exists": true}}),
+		},
+		{
+			Keys: bson.D{{Key: "platform", Value: 1}, {Key: "connect_code", Value: 1}},
+			Options: options.Index().
+				SetPartialFilterExpression(bson.M{"connect_code": bson.M{"$exists": true}}),
+		},
+		{Keys: bson.D{{Key: "account_id", Value: 1}, {Key: "platform", Value: 1}}},
+	}
+	err := ensureIndexes(ctx, collectionName, func(ctx context.Context) error {
+		_, err := collection.Indexes().CreateMany(ctx, indexModels)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChatRepository{
+		collection: collection,
+	}, nil
+}
+
+// CreatePending saves a pending workspace, replacing the account's earlier
+// pending one for the same platform
+func (r *ChatRepository) CreatePending(ctx context.Context, workspace *models.ChatWorkspace) error {
+	if workspace.ID.IsZero() {
+		workspace.ID = primitive.NewObjectID()
+	}
+	filter := bson.M{
+		"account_id":   workspace.AccountID,
+		"platform":     workspace.Platform,
+		"workspace_id": bson.M{"$exists": false},
+	}
+	if _, err := r.collection.DeleteMany(ctx, filter); err != nil {
+		return translateError(err)
+	}
+	_, err := r.collection.InsertOne(ctx, workspace)
+	return translateError(err)
+}
+
+// Connect uses the pending code hashed as codeHash, if it is still good at
+// now, to connect workspaceID and returns the connected workspace. An
+// unknown or expired code is ErrNotFound; a workspace already connected to
+// an account is ErrDuplicate
+func (r *ChatRepository) Connect(ctx context.Context, platform, codeHash, workspaceID, userID string, now time.Time) (*models.ChatWorkspace, error) {
+	filter := bson.M{
+		"platform":     platform,
+		"connect_code": codeHash,
+		"connect_by":   bson.M{"$gt": now},
+	}
+	update := bson.M{
+		"$set":   bson.M{"workspace_id": workspaceID, "connected_by": userID, "connected_at": now},
+		"$unset": bson.M{"connect_code": "", "connect_by": ""},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var workspace models.ChatWorkspace
+	if err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&workspace); err != nil {
+		return nil, translateError(err)
+	}
+	return &workspace, nil
+}
+
+// GetConnected returns the connected workspace; a workspace no account
+// connected is ErrNotFound
+func (r *ChatRepository) GetConnected(ctx context.Context, platform, workspaceID string) (*models.ChatWorkspace, error) {
+	var workspace models.ChatWorkspace
+	filter := bson.M{"platform": platform, "workspace_id": workspaceID}
+	if err := r.collection.FindOne(ctx, filter).Decode(&workspace); err != nil {
+		return nil, translateError(err)
+	}
+	return &workspace, nil
+}
+
+// ListConnected returns the workspaces connected to accountID, oldest first
+func (r *ChatRepository) ListConnected(ctx context.Context, accountID string) ([]models.ChatWorkspace, error) {
+	filter := bson.M{"account_id": accountID, "workspace_id": bson.M{"$exists": true}}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, translateError(err)
+	}
+	workspaces := []models.ChatWorkspace{}
+	if err := cursor.All(ctx, &workspaces); err != nil {
+		return nil, translateError(err)
+	}
+	return workspaces, nil
+}
+
+// Delete removes accountID's workspace id; anything else is ErrNotFound
+func (r *ChatRepository) Delete(ctx context.Context, accountID string, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id, "account_id": accountID})
+	if err != nil {
+		return translateError(err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ClickRepository handles MongoDB operations for click events
+type ClickRepository struct {
+	collection *mongo.Collection
+}
+
+// NewClickRepository creates a new click event repository instance
+func NewClickRepository(ctx context.Context, client *mongo.Client, dbName, collectionName string) (*ClickRepository, error) {
+	collection := client.Database(dbName).Collection(collectionName)
+
+	// Every stats query filters by code and time range; _id breaks ties when
+	// paging through the events
+	indexModel := mongo.IndexModel{
+		Keys: bson.D{{Key: "short_code", Value: 1}, {Key: "clicked_at", Value: 1}, {Key: "_id", Value: 1}},
+	}
+	err := ensureIndexes(ctx, collectionName, func(ctx context.Context) error {
+		_, err := collection.Indexes().CreateOne(ctx, indexModel)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ClickRepository{
+		collection: collection,
+	}, nil
+}
+
+// RecordClick saves a click event
+func (r *ClickRepository) RecordClick(ctx context.Context, event *models.ClickEvent) error {
+	_, err := r.collection.InsertOne(ctx, event)
+	return err
+}
+
+// ClickListSpec is how a link's click events sort and filter: by time, and
+// by the visitor details recorded with each click
+var ClickListSpec = &ListSpec{
+	Sorts:       map[string]string{"clicked_at": "clicked_at"},
+	DefaultSort: "-clicked_at",
+	Filters: map[string]Filter{
+		"bot":     {Field: "bot", Match: matchBot},
+		"country": {Field: "country"},
+		"city":    {Field: "city"},
+		"device":  {Field: "device"},
+		"variant": {Field: "variant"},
+	},
+}
+
+// matchBot keeps events recorded before bot detection, which have no bot
+// field, with the human ones
+func matchBot(value string) (interface{}, error) {
+	bot, err := MatchBool(value)
+	if err != nil || bot.(bool) {
+		return bot, err
+	}
+	return bson.M{"$ne": true}, nil
+}
+
+// SetOwner attributes every recorded click of a link to ownerID, for links
+// changing hands
+func (r *ClickRepository) SetOwner(ctx context.Context, domain, shortCode, ownerID string) error {
+	_, err := r.collection.UpdateMany(ctx, linkFilter(domain, shortCode), bson.M{"$set": bson.M{"owner_id": ownerID}})
+	return err
+}
+
+// ListByShortCode returns one page of a link's click events, newest first
+// unless page asks otherwise. Events moved to the archive are not listed
+func (r *ClickRepository) ListByShortCode(ctx context.Context, domain, shortCode string, page PageRequest) (*Page[models.ClickEvent], error) {
+	return findPage[models.ClickEvent](ctx, r.collection, linkFilter(domain, shortCode), page, ClickListSpec)
+}
+
+// TrafficFilter selects which clicks an aggregation counts
+type TrafficFilter string
+
+const (
+	TrafficHuman TrafficFilter = "human"
+	TrafficBot   TrafficFilter = "bot"
+	TrafficAll   TrafficFilter = "all"
+)
+
+// TimeSeries returns click counts for shortCode bucketed by unit (hour, day or week)
+func (r *ClickRepository) TimeSeries(ctx context.Context, domain, shortCode string, from, to time.Time, traffic TrafficFilter, unit string) ([]models.TimeBucket, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: rangeFilter(domain, shortCode, from, to, traffic)}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{"$dateTrunc": bson.M{
+				"date": "$clicked_at",
+				"unit": unit,
+			}},
+			"clicks": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+	buckets := []models.TimeBucket{}
+	if err := r.aggregate(ctx, pipeline, &buckets); err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// Count returns how many clicks shortCode got in the range
+func (r *ClickRepository) Count(ctx context.Context, domain, shortCode string, from, to time.Time, traffic TrafficFilter) (int64, error) {
+	return r.collection.CountDocuments(ctx, rangeFilter(domain, shortCode, from, to, traffic))
+}
+
+// CountMissing returns how many of shortCode's clicks in the range lack field
+func (r *ClickRepository) CountMissing(ctx context.Context, domain, shortCode, field string, from, to time.Time, traffic TrafficFilter) (int64, error) {
+	filter := rangeFilter(domain, shortCode, from, to, traffic)
+	filter[field] = bson.M{"$in": bson.A{nil, ""}}
+	return r.collection.CountDocuments(ctx, filter)
+}
+
+// TopValues returns the limit most frequent values of field for shortCode
+// Events without the field are ignored; a limit of 0 returns every value
+func (r *ClickRepository) TopValues(ctx context.Context, domain, shortCode, field string, from, to time.Time, traffic TrafficFilter, limit int) ([]models.CountEntry, error) {
+	match := rangeFilter(domain, shortCode, from, to, traffic)
+	match[field] = bson.M{"$exists": true, "$ne": ""}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{"_id": "$" + field, "clicks": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "clicks", Value: -1}, {Key: "_id", Value: 1}}}},
+	}
+	if limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: limit}})
+	}
+	entries := []models.CountEntry{}
+	if err := r.aggregate(ctx, pipeline, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// TopCities returns the limit most frequent cities of shortCode's clicks,
+// keyed "country/city" since city names repeat across countries. Events
+// without a city are ignored; a limit of 0 returns every city
+func (r *ClickRepository) TopCities(ctx context.Context, domain, shortCode string, from, to time.Time, traffic TrafficFilter, limit int) ([]models.CountEntry, error) {
+	match := rangeFilter(domain, shortCode, from, to, traffic)
+	match["city"] = bson.M{"$exists": true, "$ne": ""}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id":    bson.M{"$concat": bson.A{bson.M{"$ifNull": bson.A{"$country", ""}}, "/", "$city"}},
+			"clicks": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "clicks", Value: -1}, {Key: "_id", Value: 1}}}},
+	}
+	if limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: limit}})
+	}
+	entries := []models.CountEntry{}
+	if err := r.aggregate(ctx, pipeline, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// DailyAggregates counts clicks between from and to per UTC day, link and
+// visitor profile (country, referrer domain, device, bot)
+func (r *ClickRepository) DailyAggregates(ctx context.Context, from, to time.Time) ([]models.ClickAggregate, error) {
+	orEmpty := func(field string) bson.M {
+		return bson.M{"$ifNull": bson.A{"$" + field, ""}}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"clicked_at": bson.M{"$gte": from, "$lt": to}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"day":             bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$clicked_at"}},
+				"domain":          orEmpty("domain"),
+				"short_code":      "$short_code",
+				"owner_id":        orEmpty("owner_id"),
+				"country":         orEmpty("country"),
+				"referrer_domain": orEmpty("referrer_domain"),
+				"device":          orEmpty("device"),
+				"bot":             bson.M{"$ifNull": bson.A{"$bot", false}},
+			},
+			"clicks": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id.day", Value: 1}, {Key: "_id.domain", Value: 1}, {Key: "_id.short_code", Value: 1}}}},
+		{{Key: "$replaceWith", Value: bson.M{"$mergeObjects": bson.A{"$_id", bson.M{"clicks": "$clicks"}}}}},
+	}
+	rows := []models.ClickAggregate{}
+	if err := r.aggregate(ctx, pipeline, &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// ForEachBefore calls fn for every event older than cutoff, ordered by short
+// code and then time, stopping at the first error
+func (r *ClickRepository) ForEachBefore(ctx context.Context, cutoff time.Time, fn func(*models.ClickEvent) error) error {
+	opts := options.Find().SetSort(bson.D{{Key: "short_code", Value: 1}, {Key: "clicked_at", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"clicked_at": bson.M{"$lt": cutoff}}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var event models.ClickEvent
+		if err := cursor.Decode(&event); err != nil {
+			return err
+		}
+		if err := fn(&event); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// DeleteByIDs removes the given events
+func (r *ClickRepository) DeleteByIDs(ctx context.Context, ids []primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	return err
+}
+
+func (r *ClickRepository) aggregate(ctx context.Context, pipeline mongo.Pipeline, out interface{}) error {
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	return cursor.All(ctx, out)
+}
+
+func rangeFilter(domain, shortCode string, from, to time.Time, traffic TrafficFilter) bson.M {
+	filter := bson.M{
+		"domain":     domainFilter(domain),
+		"short_code": shortCode,
+		"clicked_at": bson.M{"$gte": from, "$lt": to},
+	}
+	// Events recorded before bot detection have no bot field and count as human
+	switch traffic {
+	case TrafficHuman:
+		filter["bot"] = bson.M{"$ne": true}
+	case TrafficBot:
+		filter["bot"] = true
+	}
+	return filter
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ConversionRepository handles MongoDB operations for A/B conversions
+type ConversionRepository struct {
+	collection *mongo.Collection
+}
+
+// NewConversionRepository creates a new conversion repository instance
+func NewConversionRepository(ctx context.Context, client *mongo.Client, dbName, collectionName string) (*ConversionRepository, error) {
+	collection := client.Database(dbName).Collection(collectionName)
+
+	// Stats count a link's conversions over a time range
+	indexModel := mongo.IndexModel{
+		Keys: bson.D{{Key: "short_code", Value: 1}, {Key: "converted_at", Value: 1}},
+	}
+	err := ensureIndexes(ctx, collectionName, func(ctx context.Context) error {
+		_, err := collection.Indexes().CreateOne(ctx, indexModel)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConversionRepository{
+		collection: collection,
+	}, nil
+}
+
+// Record saves a conversion
+func (r *ConversionRepository) Record(ctx context.Context, conversion *models.Conversion) error {
+	_, err := r.collection.InsertOne(ctx, conversion)
+	return translateError(err)
+}
+
+// CountByVariant returns the link's conversions between from and to per variant
+func (r *ConversionRepository) CountByVariant(ctx context.Context, domain, shortCode string, from, to time.Time) ([]models.CountEntry, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"domain":       domainFilter(domain),
+			"short_code":   shortCode,
+			"converted_at": bson.M{"$gte": from, "$lt": to},
+		}}},
+		{{Key: "$group", Value: bson.M{"_id": "$variant", "clicks": bson.M{"$sum": 1}}}},
+	}
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer cursor.Close(ctx)
+	counts := []models.CountEntry{}
+	if err := cursor.All(ctx, &counts); err != nil {
+		return nil, translateError(err)
+	}
+	return counts, nil
+}
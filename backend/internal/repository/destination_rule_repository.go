@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DestinationRuleRepository handles MongoDB operations for the destination
+// rules admins manage
+type DestinationRuleRepository struct {
+	collection *mongo.Collection
+}
+
+// NewDestinationRuleRepository creates a new destination rule repository instance
+func NewDestinationRuleRepository(ctx context.Context, client *mongo.Client, dbName, collectionName string) (*DestinationRuleRepository, error) {
+	collection := client.Database(dbName).Collection(collectionName)
+
+	indexModel := mongo.IndexModel{
+		Keys:    bson.D{{Key: "pattern", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	err := ensureIndexes(ctx, collectionName, func(ctx context.Context) error {
+		_, err := collection.Indexes().CreateOne(ctx, indexModel)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &DestinationRuleRepository{
+		collection: collection,
+	}, nil
+}
+
+// Upsert saves rule.Pattern's rule, moving it to rule.List and replacing the
+// reason of an existing one
+func (r *DestinationRuleRepository) Upsert(ctx context.Context, rule *models.DestinationRule) (*models.DestinationRule, error) {
+	if rule.CreatedAt.IsZero() {
+		rule.CreatedAt = time.Now()
+	}
+	update := bson.M{
+		"$set":         bson.M{"list": rule.List, "reason": rule.Reason},
+		"$setOnInsert": bson.M{"created_at": rule.CreatedAt},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var saved models.DestinationRule
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"pattern": rule.Pattern}, update, opts).Decode(&saved)
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// Delete removes pattern's rule and reports whether there was one
+func (r *DestinationRuleRepository) Delete(ctx context.Context, pattern string) (bool, error) {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"pattern": pattern})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// List returns every rule, in pattern order
+func (r *DestinationRuleRepository) List(ctx context.Context) ([]models.DestinationRule, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "pattern", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	rules := []models.DestinationRule{}
+	if err := cursor.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DomainRepository handles MongoDB operations for branded domains
+type DomainRepository struct {
+	collection *mongo.Collection
+}
+
+// NewDomainRepository creates a new domain repository instance
+func NewDomainRepository(ctx context.Context, client *mongo.Client, dbName, collectionName string) (*DomainRepository, error) {
+	collection := client.Database(dbName).Collection(collectionName)
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "host", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "account_id", Value: 1}, {Key: "_id", Value: -1}}},
+	}
+	err := ensureIndexes(ctx, collectionName, func(ctx context.Context) error {
+		_, err := collection.Indexes().CreateMany(ctx, indexes)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &DomainRepository{
+		collection: collection,
+	}, nil
+}
+
+// CreateDomain stores a new domain and sets its ID
+func (r *DomainRepository) CreateDomain(ctx context.Context, domain *models.Domain) error {
+	if domain.CreatedAt.IsZero() {
+		domain.CreatedAt = time.Now()
+	}
+	result, err := r.collection.InsertOne(ctx, domain)
+	if err != nil {
+		return translateError(err)
+	}
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		domain.ID = id
+	}
+	return nil
+}
+
+// GetDomainByHost returns the domain for host, or nil if none is registered
+func (r *DomainRepository) GetDomainByHost(ctx context.Context, host string) (*models.Domain, error) {
+	var domain models.Domain
+	err := r.collection.FindOne(ctx, bson.M{"host": host}).Decode(&domain)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &domain, nil
+}
+
+// GetDomainByID returns the domain with id, or nil if it doesn't exist
+func (r *DomainRepository) GetDomainByID(ctx context.Context, id primitive.ObjectID) (*models.Domain, error) {
+	var domain models.Domain
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&domain)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &domain, nil
+}
+
+// MarkVerified records that the domain's ownership was proven
+func (r *DomainRepository) MarkVerified(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"verified": true, "verified_at": at}})
+	return err
+}
+
+// ListDomainsByAccount returns one page of an account's domains, newest first
+func (r *DomainRepository) ListDomainsByAccount(ctx context.Context, accountID string, page PageRequest) (*Page[models.Domain], error) {
+	return findPage[models.Domain](ctx, r.collection, bson.M{"account_id": accountID}, page, CreatedListSpec)
+}
+
+// ListVerifiedDomains returns every verified domain, for background workers
+func (r *DomainRepository) ListVerifiedDomains(ctx context.Context) ([]models.Domain, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"verified": true})
+	if err != nil {
+		return nil, err
+	}
+	var domains []models.Domain
+	if err := cursor.All(ctx, &domains); err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
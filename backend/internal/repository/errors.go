@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/breaker"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Repository errors services can match with errors.Is instead of inspecting
+// driver errors. The driver error stays in the chain for logging
+var (
+	// ErrNotFound means the database answered and no document matched
+	ErrNotFound = errors.New("document not found")
+	// ErrDuplicate means a unique index rejected the write
+	ErrDuplicate = errors.New("duplicate key")
+	// ErrUnavailable means the database could not be reached in time, or its
+	// circuit breaker is open; callers should report 503, not "not found"
+	ErrUnavailable = errors.New("database unavailable")
+)
+
+// translateError maps driver and breaker errors onto the repository errors
+// Other errors are returned unchanged
+func translateError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	case mongo.IsDuplicateKeyError(err):
+		return fmt.Errorf("%w: %w", ErrDuplicate, err)
+	case errors.Is(err, breaker.ErrOpen), mongo.IsTimeout(err), mongo.IsNetworkError(err):
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+// indexSetupTimeout bounds each attempt at creating a collection's indexes
+const indexSetupTimeout = 10 * time.Second
+
+// ensureIndexes runs a collection's index setup. When MongoDB is unreachable
+// the setup keeps retrying in the background until ctx ends instead of
+// failing, so the server can start in degraded mode; other errors are returned
+func ensureIndexes(ctx context.Context, collection string, setup func(ctx context.Context) error) error {
+	attemptCtx, cancel := context.WithTimeout(ctx, indexSetupTimeout)
+	err := setup(attemptCtx)
+	cancel()
+	if err == nil || !IsUnavailable(err) || ctx.Err() != nil {
+		return err
+	}
+
+	log.Printf("MongoDB unavailable, creating %s indexes in the background: %v", collection, err)
+	go func() {
+		err := utils.RetryWithBackoff(ctx, time.Second, time.Minute, func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, indexSetupTimeout)
+			defer cancel()
+			return setup(ctx)
+		})
+		if err != nil {
+			log.Printf("Gave up creating %s indexes: %v", collection, err)
+			return
+		}
+		log.Printf("Created %s indexes", collection)
+	}()
+	return nil
+}
+
+// IsUnavailable reports whether err means MongoDB could not be reached
+func IsUnavailable(err error) bool {
+	var selectionErr topology.ServerSelectionError
+	return errors.As(err, &selectionErr) ||
+		mongo.IsNetworkError(err) ||
+		mongo.IsTimeout(err) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ArchiveStale moves up to limit links that haven't been clicked since cutoff
+// (or were created before it and never clicked) into the archive collection,
+// and reports how many were moved. Trashed links are left to the purger
+func (r *MongoRepository) ArchiveStale(ctx context.Context, cutoff time.Time, limit int64) (int64, error) {
+	var archived int64
+	for _, shard := range r.shards {
+		if archived >= limit {
+			break
+		}
+		moved, err := r.archiveStale(ctx, shard, cutoff, limit-archived)
+		archived += moved
+		if err != nil {
+			return archived, err
+		}
+	}
+	return archived, nil
+}
+
+// archiveStale archives up to limit of one shard's stale links
+func (r *MongoRepository) archiveStale(ctx context.Context, shard *linkShard, cutoff time.Time, limit int64) (int64, error) {
+	filter := bson.M{
+		"deleted_at": nil,
+		"$or": bson.A{
+			bson.M{"last_clicked_at": bson.M{"$lt": cutoff}},
+			bson.M{"last_clicked_at": nil, "created_at": bson.M{"$lt": cutoff}},
+		},
+	}
+	var links []models.ShortURL
+	err := shard.breaker.Do(func() error {
+		cursor, err := shard.collection.Find(ctx, filter, options.Find().SetLimit(limit))
+		if err != nil {
+			return err
+		}
+		return cursor.All(ctx, &links)
+	})
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	var archived int64
+	for i := range links {
+		moved, err := shard.archive(ctx, &links[i])
+		if err != nil {
+			return archived, translateError(err)
+		}
+		if moved {
+			archived++
+		}
+	}
+	return archived, nil
+}
+
+// archive copies link into the archive and then removes it from the hot
+// collection. A link clicked, edited or trashed since it was read is left
+// where it is and its copy is dropped, so no click or change is lost
+func (s *linkShard) archive(ctx context.Context, link *models.ShortURL) (bool, error) {
+	var moved bool
+	err := s.breaker.Do(func() error {
+		_, err := s.archived.ReplaceOne(ctx, bson.M{"_id": link.ID}, link, options.Replace().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+		result, err := s.collection.DeleteOne(ctx, bson.M{
+			"_id":             link.ID,
+			"last_clicked_at": link.LastClickedAt,
+			"updated_at":      link.UpdatedAt,
+			"deleted_at":      nil,
+		})
+		if err != nil {
+			return err
+		}
+		if result.DeletedCount == 0 {
+			_, err = s.archived.DeleteOne(ctx, bson.M{"_id": link.ID})
+			return err
+		}
+		moved = true
+		return nil
+	})
+	return moved, err
+}
+
+// GetArchived retrieves an archived link by its short code on domain
+// A link that isn't archived is ErrNotFound
+func (r *MongoRepository) GetArchived(ctx context.Context, domain, shortCode string) (*models.ShortURL, error) {
+	var shortURL models.ShortURL
+	shard := r.shardFor(shortCode)
+	err := shard.breaker.Do(func() error {
+		return shard.archived.FindOne(ctx, linkFilter(domain, shortCode)).Decode(&shortURL)
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &shortURL, nil
+}
+
+// Rehydrate moves an archived link back into the hot collection. Replicas
+// rehydrating the same link at once are fine: the second insert is a
+// duplicate and is ignored
+func (r *MongoRepository) Rehydrate(ctx context.Context, link *models.ShortURL) error {
+	shard := r.shardFor(link.ShortCode)
+	return translateError(shard.breaker.Do(func() error {
+		if _, err := shard.collection.InsertOne(ctx, link); err != nil && !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+		_, err := shard.archived.DeleteOne(ctx, bson.M{"_id": link.ID})
+		return err
+	}))
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// HealthDead filters links whose destination failed its latest check, whatever the reason
+const HealthDead = "dead"
+
+// ClaimHealthCheck takes the active link checked longest ago, if it wasn't
+// checked since before, marking it as checked now so no other replica takes
+// it. It returns nil when no link is due. Each call starts at the next shard,
+// so checks are spread over them
+func (r *MongoRepository) ClaimHealthCheck(ctx context.Context, before time.Time) (*models.ShortURL, error) {
+	filter := bson.M{
+		"is_active":  true,
+		"deleted_at": nil,
+		"$or": bson.A{
+			bson.M{"health_checked_at": nil},
+			bson.M{"health_checked_at": bson.M{"$lt": before}},
+		},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "health_checked_at", Value: 1}}).
+		SetReturnDocument(options.After)
+	first := r.next.Add(1)
+	for i := range r.shards {
+		shard := r.shards[(first+uint64(i))%uint64(len(r.shards))]
+		var link models.ShortURL
+		err := shard.breaker.Do(func() error {
+			return shard.collection.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"health_checked_at": time.Now()}}, opts).Decode(&link)
+		})
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			return nil, translateError(err)
+		}
+		return &link, nil
+	}
+	return nil, nil
+}
+
+// SetHealth records the outcome of a check of the link's destination
+func (r *MongoRepository) SetHealth(ctx context.Context, domain, shortCode string, health *models.LinkHealth) error {
+	shard := r.shardFor(shortCode)
+	return translateError(shard.breaker.Do(func() error {
+		_, err := shard.collection.UpdateOne(ctx, linkFilter(domain, shortCode), bson.M{"$set": bson.M{"health": health}})
+		return err
+	}))
+}
+
+// ListByOwner returns one page of the owner's links, sorted and filtered as
+// LinkListSpec allows
+func (r *MongoRepository) ListByOwner(ctx context.Context, ownerID string, req PageRequest) (*Page[models.ShortURL], error) {
+	return r.listPage(ctx, bson.M{"owner_id": ownerID, "deleted_at": nil}, req)
+}
+
+// LinkHealthFilters are the accepted values of the health filter of link
+// lists: a check status, or HealthDead for any failure
+var LinkHealthFilters = []string{
+	models.HealthAlive, models.HealthNotFound, models.HealthHTTPError, models.HealthTimeout,
+	models.HealthSSLError, models.HealthUnreachable, HealthDead,
+}
+
+func matchHealth(health string) (interface{}, error) {
+	if health == HealthDead {
+		return bson.M{"$exists": true, "$ne": models.HealthAlive}, nil
+	}
+	return health, nil
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ListExpiring returns up to limit of the owner's live links expiring between
+// now and before whose owner hasn't been warned yet
+func (r *MongoRepository) ListExpiring(ctx context.Context, ownerID string, now, before time.Time, limit int64) ([]*models.ShortURL, error) {
+	filter := bson.M{
+		"owner_id":           ownerID,
+		"is_active":          true,
+		"deleted_at":         nil,
+		"expiry_notified_at": nil,
+		"expires_at":         bson.M{"$gt": now, "$lte": before},
+	}
+	return r.listForNotice(ctx, filter, limit)
+}
+
+// ClaimExpiryNotice marks the link's owner as warned about its expiration at
+// expiresAt, and reports whether this call did so. Replicas scanning at the
+// same time claim each link once; a changed expiration is not claimed
+func (r *MongoRepository) ClaimExpiryNotice(ctx context.Context, domain, shortCode string, expiresAt time.Time) (bool, error) {
+	filter := linkFilter(domain, shortCode)
+	filter["expires_at"] = expiresAt
+	filter["expiry_notified_at"] = nil
+	return r.claimNotice(ctx, shortCode, filter, bson.M{"expiry_notified_at": time.Now()})
+}
+
+// ListReachedMilestone returns up to limit of the owner's live links with at
+// least milestone clicks whose owner hasn't heard about that milestone yet
+func (r *MongoRepository) ListReachedMilestone(ctx context.Context, ownerID string, milestone, limit int64) ([]*models.ShortURL, error) {
+	filter := bson.M{
+		"owner_id":           ownerID,
+		"deleted_at":         nil,
+		"click_count":        bson.M{"$gte": milestone},
+		"notified_milestone": bson.M{"$not": bson.M{"$gte": milestone}},
+	}
+	return r.listForNotice(ctx, filter, limit)
+}
+
+// ClaimMilestone records that the link's owner heard about milestone, and
+// reports whether this call did so
+func (r *MongoRepository) ClaimMilestone(ctx context.Context, domain, shortCode string, milestone int64) (bool, error) {
+	filter := linkFilter(domain, shortCode)
+	filter["notified_milestone"] = bson.M{"$not": bson.M{"$gte": milestone}}
+	return r.claimNotice(ctx, shortCode, filter, bson.M{"notified_milestone": milestone})
+}
+
+func (r *MongoRepository) listForNotice(ctx context.Context, filter bson.M, limit int64) ([]*models.ShortURL, error) {
+	return r.findNewest(ctx, filter, limit)
+}
+
+func (r *MongoRepository) claimNotice(ctx context.Context, shortCode string, filter, set bson.M) (bool, error) {
+	var claimed bool
+	shard := r.shardFor(shortCode)
+	err := shard.breaker.Do(func() error {
+		result, err := shard.collection.UpdateOne(ctx, filter, bson.M{"$set": set})
+		if err != nil {
+			return err
+		}
+		claimed = result.ModifiedCount > 0
+		return nil
+	})
+	return claimed, translateError(err)
+}
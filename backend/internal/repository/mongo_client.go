@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// MongoOptions configures the MongoDB client. Zero values leave the
+// driver's defaults, or whatever the URI sets
+type MongoOptions struct {
+	URI string
+	// MaxPoolSize caps open connections per server; MinPoolSize are kept
+	// open even when idle
+	MaxPoolSize uint64
+	MinPoolSize uint64
+	// MaxConnIdleTime closes connections idle for longer
+	MaxConnIdleTime time.Duration
+	// ReadPreference is a mode such as primary or secondaryPreferred
+	ReadPreference string
+	// WriteConcern is "majority" or how many members must acknowledge writes
+	WriteConcern           string
+	ConnectTimeout         time.Duration
+	ServerSelectionTimeout time.Duration
+	// OperationTimeout bounds each operation whose context has no deadline
+	// of its own
+	OperationTimeout time.Duration
+	Monitor          *event.CommandMonitor
+}
+
+// ClientOptions turns the options into the driver's
+func (o MongoOptions) ClientOptions() (*options.ClientOptions, error) {
+	clientOptions := options.Client().ApplyURI(o.URI)
+	if o.MaxPoolSize > 0 {
+		clientOptions.SetMaxPoolSize(o.MaxPoolSize)
+	}
+	if o.MinPoolSize > 0 {
+		clientOptions.SetMinPoolSize(o.MinPoolSize)
+	}
+	if o.MaxConnIdleTime > 0 {
+		clientOptions.SetMaxConnIdleTime(o.MaxConnIdleTime)
+	}
+	if o.ReadPreference != "" {
+		pref, err := ParseReadPreference(o.ReadPreference)
+		if err != nil {
+			return nil, err
+		}
+		clientOptions.SetReadPreference(pref)
+	}
+	if o.WriteConcern != "" {
+		concern, err := ParseWriteConcern(o.WriteConcern)
+		if err != nil {
+			return nil, err
+		}
+		clientOptions.SetWriteConcern(concern)
+	}
+	if o.ConnectTimeout > 0 {
+		clientOptions.SetConnectTimeout(o.ConnectTimeout)
+	}
+	if o.ServerSelectionTimeout > 0 {
+		clientOptions.SetServerSelectionTimeout(o.ServerSelectionTimeout)
+	}
+	if o.OperationTimeout > 0 {
+		clientOptions.SetTimeout(o.OperationTimeout)
+	}
+	if o.Monitor != nil {
+		clientOptions.SetMonitor(o.Monitor)
+	}
+	return clientOptions, clientOptions.Validate()
+}
+
+// ParseReadPreference reads a mode such as primary or nearest
+func ParseReadPreference(mode string) (*readpref.ReadPref, error) {
+	parsed, err := readpref.ModeFromString(mode)
+	if err != nil {
+		return nil, err
+	}
+	return readpref.New(parsed)
+}
+
+// ParseWriteConcern reads "majority" or a number of members
+func ParseWriteConcern(value string) (*writeconcern.WriteConcern, error) {
+	if value == "majority" {
+		return writeconcern.Majority(), nil
+	}
+	members, err := strconv.Atoi(value)
+	if err != nil || members < 0 {
+		return nil, fmt.Errorf("write concern %q is not majority or a number of members", value)
+	}
+	return &writeconcern.WriteConcern{W: members}, nil
+}
+
+// ConnectMongo creates the client. It doesn't dial: operations wait at most
+// ServerSelectionTimeout for a server, so a MongoDB outage fails requests
+// instead of hanging them
+func ConnectMongo(opts MongoOptions) (*mongo.Client, error) {
+	clientOptions, err := opts.ClientOptions()
+	if err != nil {
+		return nil, err
+	}
+	return mongo.Connect(context.Background(), clientOptions)
+}
+
+// ConnectMongoShards creates a client for each shard of the links, keyed by
+// shard name, with opts but each shard's URI
+func ConnectMongoShards(opts MongoOptions, uris map[string]string) (map[string]*mongo.Client, error) {
+	clients := make(map[string]*mongo.Client, len(uris))
+	for name, uri := range uris {
+		opts.URI = uri
+		client, err := ConnectMongo(opts)
+		if err != nil {
+			for _, connected := range clients {
+				connected.Disconnect(context.Background())
+			}
+			return nil, fmt.Errorf("shard %s: %w", name, err)
+		}
+		clients[name] = client
+	}
+	return clients, nil
+}
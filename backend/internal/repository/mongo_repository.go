@@ -1,51 +1,319 @@
 package repository
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/breaker"
 	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 // MongoRepository handles MongoDB operations for short URLs
 // This is the data access layer - it only deals with database operations
+// Every call goes through a circuit breaker so a MongoDB outage fails fast
+// Links can be spread over several MongoDB clusters, placed by consistent
+// hashing of their short codes; lists and scans fan out to every cluster
 type MongoRepository struct {
+	shards []*linkShard
+	ring   *hashRing
+	// next rotates where ClaimHealthCheck looks first
+	next atomic.Uint64
+}
+
+// MongoShard is one MongoDB cluster links are spread over, with the breaker
+// its calls go through. Name places it on the hash ring, so it must stay the
+// same across restarts and replicas
+type MongoShard struct {
+	Name    string
+	Client  *mongo.Client
+	Breaker *breaker.Breaker
+}
+
+// linkShard is one cluster's link collections
+type linkShard struct {
+	name       string
 	collection *mongo.Collection
+	// redirects is collection read with the redirect read preference
+	redirects *mongo.Collection
+	// archived holds links moved out of collection for inactivity
+	archived *mongo.Collection
+	breaker  *breaker.Breaker
 }
 
 // NewMongoRepository creates a new MongoDB repository instance
 // Parameters:
+//   - ctx: Bounds index creation, including retries in the background
 //   - client: MongoDB client connection
 //   - dbName: Database name
 //   - collectionName: Collection name for short URLs
-func NewMongoRepository(client *mongo.Client, dbName, collectionName string) (*MongoRepository, error) {
-	db := client.Database(dbName)
-	collection := db.Collection(collectionName)
+//   - cb: Circuit breaker shared by MongoDB calls
+func NewMongoRepository(ctx context.Context, client *mongo.Client, dbName, collectionName string, cb *breaker.Breaker) (*MongoRepository, error) {
+	return NewShardedMongoRepository(ctx, []MongoShard{{Name: "default", Client: client, Breaker: cb}}, dbName, collectionName)
+}
 
-	// Create index on short_code for faster lookups
-	indexModel := mongo.IndexModel{
-		Keys:    bson.D{{Key: "short_code", Value: 1}},
-		Options: options.Index().SetUnique(true),
+// NewSandboxRepository stores sandbox links in collectionName, which MongoDB
+// empties of expired links by itself; every sandbox link has an expiration
+func NewSandboxRepository(ctx context.Context, client *mongo.Client, dbName, collectionName string, cb *breaker.Breaker) (*MongoRepository, error) {
+	r, err := NewMongoRepository(ctx, client, dbName, collectionName, cb)
+	if err != nil {
+		return nil, err
 	}
-	_, err := collection.Indexes().CreateOne(context.Background(), indexModel)
+	collection := r.shards[0].collection
+	err = ensureIndexes(ctx, collectionName, func(ctx context.Context) error {
+		indexModel := mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		}
+		_, err := collection.Indexes().CreateOne(ctx, indexModel)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
+	return r, nil
+}
 
-	// Create index on original_url for faster lookups
-	indexModel2 := mongo.IndexModel{
-		Keys: bson.D{{Key: "original_url", Value: 1}},
+// NewShardedMongoRepository spreads links over shards, each with the same
+// database, collections and indexes. Adding a shard moves about 1/n of the
+// codes to it; links already stored under those codes must be moved too
+func NewShardedMongoRepository(ctx context.Context, shards []MongoShard, dbName, collectionName string) (*MongoRepository, error) {
+	if len(shards) == 0 {
+		return nil, errors.New("no MongoDB shards")
+	}
+	r := &MongoRepository{}
+	names := make([]string, len(shards))
+	for i, shard := range shards {
+		label := collectionName
+		if len(shards) > 1 {
+			label = collectionName + " (shard " + shard.Name + ")"
+		}
+		linkShard, err := newLinkShard(ctx, shard, dbName, collectionName, label)
+		if err != nil {
+			return nil, err
+		}
+		r.shards = append(r.shards, linkShard)
+		names[i] = shard.Name
 	}
-	collection.Indexes().CreateOne(context.Background(), indexModel2)
+	r.ring = newHashRing(names)
+	return r, nil
+}
+
+// newLinkShard opens the shard's collections and creates their indexes;
+// label names them in logs
+func newLinkShard(ctx context.Context, shard MongoShard, dbName, collectionName, label string) (*linkShard, error) {
+	db := shard.Client.Database(dbName)
+	collection := db.Collection(collectionName)
+
+	err := ensureIndexes(ctx, label, func(ctx context.Context) error {
+		// Short codes are unique per domain
+		indexModel := mongo.IndexModel{
+			Keys:    bson.D{{Key: "domain", Value: 1}, {Key: "short_code", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}
+		if _, err := collection.Indexes().CreateOne(ctx, indexModel); err != nil {
+			return err
+		}
+
+		// Create index on original_url for faster lookups
+		indexModel2 := mongo.IndexModel{
+			Keys: bson.D{{Key: "original_url", Value: 1}},
+		}
+		if _, err := collection.Indexes().CreateOne(ctx, indexModel2); err != nil {
+			return err
+		}
+
+		// Campaign listings page through a campaign's links newest first;
+		// sparse since most links belong to no campaign
+		indexModel3 := mongo.IndexModel{
+			Keys:    bson.D{{Key: "campaign_id", Value: 1}, {Key: "_id", Value: -1}},
+			Options: options.Index().SetSparse(true),
+		}
+		if _, err := collection.Indexes().CreateOne(ctx, indexModel3); err != nil {
+			return err
+		}
 
-	return &MongoRepository{
+		// The trash purger finds links deleted before the retention cutoff
+		indexModel4 := mongo.IndexModel{
+			Keys:    bson.D{{Key: "deleted_at", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		}
+		if _, err := collection.Indexes().CreateOne(ctx, indexModel4); err != nil {
+			return err
+		}
+
+		// Duplicate lookups find an owner's links by normalized destination
+		indexModel5 := mongo.IndexModel{
+			Keys:    bson.D{{Key: "owner_id", Value: 1}, {Key: "url_key", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		}
+		if _, err := collection.Indexes().CreateOne(ctx, indexModel5); err != nil {
+			return err
+		}
+
+		// The archiver finds links whose last click, or creation when they
+		// were never clicked, is older than the cutoff
+		indexModel6 := mongo.IndexModel{
+			Keys: bson.D{{Key: "last_clicked_at", Value: 1}, {Key: "created_at", Value: 1}},
+		}
+		if _, err := collection.Indexes().CreateOne(ctx, indexModel6); err != nil {
+			return err
+		}
+
+		// The health checker takes the links checked longest ago first
+		indexModel7 := mongo.IndexModel{
+			Keys: bson.D{{Key: "health_checked_at", Value: 1}},
+		}
+		if _, err := collection.Indexes().CreateOne(ctx, indexModel7); err != nil {
+			return err
+		}
+
+		// Owners page through their links newest first, optionally by health
+		indexModel8 := mongo.IndexModel{
+			Keys:    bson.D{{Key: "owner_id", Value: 1}, {Key: "_id", Value: -1}},
+			Options: options.Index().SetSparse(true),
+		}
+		if _, err := collection.Indexes().CreateOne(ctx, indexModel8); err != nil {
+			return err
+		}
+
+		// Owner and campaign link lists can also sort by clicks
+		indexModel9 := mongo.IndexModel{
+			Keys: bson.D{{Key: "owner_id", Value: 1}, {Key: "click_count", Value: -1}, {Key: "_id", Value: -1}},
+		}
+		if _, err := collection.Indexes().CreateOne(ctx, indexModel9); err != nil {
+			return err
+		}
+		indexModel10 := mongo.IndexModel{
+			Keys: bson.D{{Key: "campaign_id", Value: 1}, {Key: "click_count", Value: -1}, {Key: "_id", Value: -1}},
+		}
+		_, err := collection.Indexes().CreateOne(ctx, indexModel10)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	archived := db.Collection(collectionName + "_archive")
+	err = ensureIndexes(ctx, label+" archive", func(ctx context.Context) error {
+		indexModel := mongo.IndexModel{
+			Keys:    bson.D{{Key: "domain", Value: 1}, {Key: "short_code", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}
+		_, err := archived.Indexes().CreateOne(ctx, indexModel)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &linkShard{
+		name:       shard.Name,
 		collection: collection,
+		redirects:  collection,
+		archived:   archived,
+		breaker:    shard.Breaker,
 	}, nil
 }
 
+// SetRedirectReadPreference sends GetRedirectLink's reads to the members
+// pref selects, such as the nearest one. Every other read, and every write,
+// keeps the client's preference
+func (r *MongoRepository) SetRedirectReadPreference(pref *readpref.ReadPref) error {
+	for _, shard := range r.shards {
+		redirects, err := shard.collection.Clone(options.Collection().SetReadPreference(pref))
+		if err != nil {
+			return err
+		}
+		shard.redirects = redirects
+	}
+	return nil
+}
+
+// shardFor returns the shard holding the links with shortCode
+func (r *MongoRepository) shardFor(shortCode string) *linkShard {
+	if len(r.shards) == 1 {
+		return r.shards[0]
+	}
+	return r.shards[r.ring.locate(shortCode)]
+}
+
+// eachShard runs fn on every shard at once and joins their errors. fn must
+// guard anything it shares between shards
+func (r *MongoRepository) eachShard(fn func(shard *linkShard) error) error {
+	if len(r.shards) == 1 {
+		return fn(r.shards[0])
+	}
+	errs := make([]error, len(r.shards))
+	var wg sync.WaitGroup
+	for i, shard := range r.shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = fn(shard)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// findNewest returns up to limit links matching filter across shards,
+// newest first
+func (r *MongoRepository) findNewest(ctx context.Context, filter bson.M, limit int64) ([]*models.ShortURL, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(limit)
+	var mu sync.Mutex
+	var links []*models.ShortURL
+	err := r.eachShard(func(shard *linkShard) error {
+		return shard.breaker.Do(func() error {
+			cursor, err := shard.collection.Find(ctx, filter, opts)
+			if err != nil {
+				return err
+			}
+			var found []*models.ShortURL
+			if err := cursor.All(ctx, &found); err != nil {
+				return err
+			}
+			mu.Lock()
+			links = append(links, found...)
+			mu.Unlock()
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	slices.SortFunc(links, func(a, b *models.ShortURL) int {
+		return bytes.Compare(b.ID[:], a.ID[:])
+	})
+	if limit > 0 && int64(len(links)) > limit {
+		links = links[:limit]
+	}
+	return links, nil
+}
+
+// countAcross sums fn's count over every shard
+func (r *MongoRepository) countAcross(fn func(shard *linkShard) (int64, error)) (int64, error) {
+	var total atomic.Int64
+	err := r.eachShard(func(shard *linkShard) error {
+		return shard.breaker.Do(func() error {
+			n, err := fn(shard)
+			total.Add(n)
+			return err
+		})
+	})
+	return total.Load(), translateError(err)
+}
+
 // CreateShortURL saves a new short URL to the database
 // It sets CreatedAt and IsActive fields automatically
 func (r *MongoRepository) CreateShortURL(ctx context.Context, shortURL *models.ShortURL) error {
@@ -60,47 +328,576 @@ func (r *MongoRepository) CreateShortURL(ctx context.Context, shortURL *models.S
 		shortURL.ClickCount = 0
 	}
 
-	_, err := r.collection.InsertOne(ctx, shortURL)
-	return err
+	shard := r.shardFor(shortURL.ShortCode)
+	return translateError(shard.breaker.Do(func() error {
+		_, err := shard.collection.InsertOne(ctx, shortURL)
+		return err
+	}))
 }
 
-// GetShortURLByCode retrieves a short URL by its short code
-func (r *MongoRepository) GetShortURLByCode(ctx context.Context, shortCode string) (*models.ShortURL, error) {
+// GetShortURLByCode retrieves a short URL by its short code on domain
+// An empty domain is the default short domain. A missing or trashed link is ErrNotFound
+func (r *MongoRepository) GetShortURLByCode(ctx context.Context, domain, shortCode string) (*models.ShortURL, error) {
 	var shortURL models.ShortURL
-	err := r.collection.FindOne(ctx, bson.M{"short_code": shortCode}).Decode(&shortURL)
+	filter := linkFilter(domain, shortCode)
+	filter["deleted_at"] = nil
+	shard := r.shardFor(shortCode)
+	err := shard.breaker.Do(func() error {
+		return shard.collection.FindOne(ctx, filter).Decode(&shortURL)
+	})
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return nil, err
+		return nil, translateError(err)
+	}
+	return &shortURL, nil
+}
+
+// GetRedirectLink is GetShortURLByCode for the redirect path, reading with
+// the redirect read preference. A secondary may lag behind the primary, so a
+// link it is missing is looked up on the primary; one it has may be stale
+func (r *MongoRepository) GetRedirectLink(ctx context.Context, domain, shortCode string) (*models.ShortURL, error) {
+	var shortURL models.ShortURL
+	filter := linkFilter(domain, shortCode)
+	filter["deleted_at"] = nil
+	shard := r.shardFor(shortCode)
+	err := shard.breaker.Do(func() error {
+		err := shard.redirects.FindOne(ctx, filter).Decode(&shortURL)
+		// Links created a moment ago may not have reached the secondary yet
+		if errors.Is(err, mongo.ErrNoDocuments) && shard.redirects != shard.collection {
+			err = shard.collection.FindOne(ctx, filter).Decode(&shortURL)
 		}
-		return nil, err
+		return err
+	})
+	if err != nil {
+		return nil, translateError(err)
 	}
 	return &shortURL, nil
 }
 
-// GetShortURLByOriginal retrieves a short URL by its original URL
-func (r *MongoRepository) GetShortURLByOriginal(ctx context.Context, originalURL string) (*models.ShortURL, error) {
+// GetShortURLByOriginal retrieves a short URL by its original URL within one owner's links on domain
+// An empty ownerID matches anonymous links
+func (r *MongoRepository) GetShortURLByOriginal(ctx context.Context, domain, ownerID, originalURL string) (*models.ShortURL, error) {
+	filter := bson.M{"original_url": originalURL, "owner_id": ownerFilter(ownerID), "domain": domainFilter(domain), "deleted_at": nil}
+	links, err := r.findNewest(ctx, filter, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(links) == 0 {
+		return nil, nil // Return nil, nil if not found (not an error)
+	}
+	return links[0], nil
+}
+
+// UpdateClickCount increments the click count for a short URL
+func (r *MongoRepository) UpdateClickCount(ctx context.Context, domain, shortCode string) error {
+	filter := linkFilter(domain, shortCode)
+	update := bson.M{"$inc": bson.M{"click_count": 1}}
+	shard := r.shardFor(shortCode)
+	return translateError(shard.breaker.Do(func() error {
+		_, err := shard.collection.UpdateOne(ctx, filter, update)
+		return err
+	}))
+}
+
+// ConsumeLimitedClick atomically uses one of a click-limited link's allowed
+// redirects and returns the updated link, or nil if none are left. Taking
+// the last one deactivates the link in the same findAndModify. The counter
+// is separate from click_count, which is written in batches
+func (r *MongoRepository) ConsumeLimitedClick(ctx context.Context, domain, shortCode string) (*models.ShortURL, error) {
+	filter := linkFilter(domain, shortCode)
+	filter["is_active"] = true
+	filter["$expr"] = bson.M{"$lt": bson.A{bson.M{"$ifNull": bson.A{"$limited_clicks", 0}}, "$max_clicks"}}
+	// The second stage sees the incremented counter
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"limited_clicks": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$limited_clicks", 0}}, 1}}}}},
+		{{Key: "$set", Value: bson.M{
+			"is_active":  bson.M{"$lt": bson.A{"$limited_clicks", "$max_clicks"}},
+			"updated_at": bson.M{"$cond": bson.A{bson.M{"$lt": bson.A{"$limited_clicks", "$max_clicks"}}, "$updated_at", "$$NOW"}},
+		}}},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
 	var shortURL models.ShortURL
-	err := r.collection.FindOne(ctx, bson.M{"original_url": originalURL}).Decode(&shortURL)
+	shard := r.shardFor(shortCode)
+	err := shard.breaker.Do(func() error {
+		return shard.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&shortURL)
+	})
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, nil // Return nil, nil if not found (not an error)
+			return nil, nil
+		}
+		return nil, translateError(err)
+	}
+	return &shortURL, nil
+}
+
+// Deactivate marks a link inactive and reports whether it was active before
+func (r *MongoRepository) Deactivate(ctx context.Context, domain, shortCode string) (bool, error) {
+	filter := linkFilter(domain, shortCode)
+	filter["is_active"] = true
+	update := bson.M{"$set": bson.M{"is_active": false, "updated_at": time.Now()}}
+	var modified bool
+	shard := r.shardFor(shortCode)
+	err := shard.breaker.Do(func() error {
+		result, err := shard.collection.UpdateOne(ctx, filter, update)
+		if err != nil {
+			return err
+		}
+		modified = result.ModifiedCount > 0
+		return nil
+	})
+	return modified, translateError(err)
+}
+
+// FillPageMetadata sets the title and description read from a link's
+// destination, each only where the link has none yet, so metadata the owner
+// set meanwhile wins. It reports whether anything changed
+func (r *MongoRepository) FillPageMetadata(ctx context.Context, domain, shortCode, title, description string) (bool, error) {
+	fields := map[string]string{"title": title, "description": description}
+	writes := make([]mongo.WriteModel, 0, len(fields))
+	for field, value := range fields {
+		if value == "" {
+			continue
+		}
+		filter := linkFilter(domain, shortCode)
+		filter[field] = bson.M{"$in": bson.A{nil, ""}}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(filter).
+			SetUpdate(bson.M{"$set": bson.M{field: value}}))
+	}
+	if len(writes) == 0 {
+		return false, nil
+	}
+	var modified bool
+	shard := r.shardFor(shortCode)
+	err := shard.breaker.Do(func() error {
+		result, err := shard.collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return err
 		}
+		modified = result.ModifiedCount > 0
+		return nil
+	})
+	return modified, translateError(err)
+}
+
+// IncrementClickCounts adds each link's buffered clicks in a single bulk write
+// per shard and moves its last_clicked_at forward
+func (r *MongoRepository) IncrementClickCounts(ctx context.Context, counts map[LinkKey]ClickCounts) error {
+	now := time.Now()
+	writes := make(map[*linkShard][]mongo.WriteModel)
+	for key, n := range counts {
+		shard := r.shardFor(key.ShortCode)
+		writes[shard] = append(writes[shard], mongo.NewUpdateOneModel().
+			SetFilter(linkFilter(key.Domain, key.ShortCode)).
+			SetUpdate(bson.M{
+				"$inc": bson.M{"click_count": n.Human, "bot_click_count": n.Bot},
+				"$max": bson.M{"last_clicked_at": now},
+			}))
+	}
+	return translateError(r.eachShard(func(shard *linkShard) error {
+		if len(writes[shard]) == 0 {
+			return nil
+		}
+		return shard.breaker.Do(func() error {
+			_, err := shard.collection.BulkWrite(ctx, writes[shard], options.BulkWrite().SetOrdered(false))
+			return err
+		})
+	}))
+}
+
+// UpdateShortURL applies set and unset to the short URL and returns the updated document
+// A missing link is ErrNotFound
+func (r *MongoRepository) UpdateShortURL(ctx context.Context, domain, shortCode string, set, unset bson.M) (*models.ShortURL, error) {
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return r.findOneAndUpdate(ctx, shortCode, linkFilter(domain, shortCode), update)
+}
+
+// PatchShortURL copies the fields named in fields, by their BSON names, from
+// patch to the stored link and returns it. A field patch leaves out, being
+// empty and omitempty, is removed; fields not named are left alone
+func (r *MongoRepository) PatchShortURL(ctx context.Context, domain, shortCode string, patch *models.ShortURL, fields []string) (*models.ShortURL, error) {
+	doc, err := bson.Marshal(patch)
+	if err != nil {
 		return nil, err
 	}
+	set, unset := bson.M{}, bson.M{}
+	for _, field := range fields {
+		if value, err := bson.Raw(doc).LookupErr(field); err == nil {
+			set[field] = value
+		} else {
+			unset[field] = ""
+		}
+	}
+	return r.UpdateShortURL(ctx, domain, shortCode, set, unset)
+}
+
+// Trash moves ownerID's link, or an anonymous one for an empty ownerID, to
+// the trash at at and returns it. A missing, foreign or already trashed link
+// is ErrNotFound
+func (r *MongoRepository) Trash(ctx context.Context, domain, shortCode, ownerID string, at time.Time) (*models.ShortURL, error) {
+	filter := linkFilter(domain, shortCode)
+	filter["owner_id"] = ownerFilter(ownerID)
+	filter["deleted_at"] = nil
+	update := bson.M{"$set": bson.M{"deleted_at": at}}
+	return r.findOneAndUpdate(ctx, shortCode, filter, update)
+}
+
+// SetOwner hands from's link, unless trashed, to the account to and returns
+// it. The link leaves its campaign, which stays with from
+func (r *MongoRepository) SetOwner(ctx context.Context, domain, shortCode, from, to string) (*models.ShortURL, error) {
+	filter := linkFilter(domain, shortCode)
+	filter["owner_id"] = from
+	filter["deleted_at"] = nil
+	update := bson.M{
+		"$set":   bson.M{"owner_id": to},
+		"$unset": bson.M{"campaign_id": ""},
+	}
+	return r.findOneAndUpdate(ctx, shortCode, filter, update)
+}
+
+// SetManageToken replaces the management token ID of an anonymous link,
+// unless trashed, from tokenID to next and returns the link. A link whose
+// token ID changed since is ErrNotFound
+func (r *MongoRepository) SetManageToken(ctx context.Context, domain, shortCode, tokenID, next string) (*models.ShortURL, error) {
+	filter := linkFilter(domain, shortCode)
+	filter["owner_id"] = ownerFilter("")
+	filter["deleted_at"] = nil
+	filter["manage_token_id"] = tokenID
+	if tokenID == "" {
+		// Links made before token IDs were stored have none
+		filter["manage_token_id"] = bson.M{"$in": bson.A{nil, ""}}
+	}
+	update := bson.M{"$set": bson.M{"manage_token_id": next}}
+	return r.findOneAndUpdate(ctx, shortCode, filter, update)
+}
+
+// Restore takes ownerID's link out of the trash if it was deleted after
+// deletedAfter, and returns it. Anything else is ErrNotFound
+func (r *MongoRepository) Restore(ctx context.Context, domain, shortCode, ownerID string, deletedAfter time.Time) (*models.ShortURL, error) {
+	filter := linkFilter(domain, shortCode)
+	filter["owner_id"] = ownerID
+	filter["deleted_at"] = bson.M{"$gt": deletedAfter}
+	update := bson.M{"$unset": bson.M{"deleted_at": ""}}
+	return r.findOneAndUpdate(ctx, shortCode, filter, update)
+}
+
+// PurgeTrashed permanently deletes links trashed before cutoff and reports how many
+func (r *MongoRepository) PurgeTrashed(ctx context.Context, cutoff time.Time) (int64, error) {
+	return r.countAcross(func(shard *linkShard) (int64, error) {
+		result, err := shard.collection.DeleteMany(ctx, bson.M{"deleted_at": bson.M{"$lt": cutoff}})
+		if err != nil {
+			return 0, err
+		}
+		return result.DeletedCount, nil
+	})
+}
+
+// findOneAndUpdate updates the link matching filter on shortCode's shard
+func (r *MongoRepository) findOneAndUpdate(ctx context.Context, shortCode string, filter, update bson.M) (*models.ShortURL, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var shortURL models.ShortURL
+	shard := r.shardFor(shortCode)
+	err := shard.breaker.Do(func() error {
+		return shard.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&shortURL)
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
 	return &shortURL, nil
 }
 
-// UpdateClickCount increments the click count for a short URL
-func (r *MongoRepository) UpdateClickCount(ctx context.Context, shortCode string) error {
-	filter := bson.M{"short_code": shortCode}
-	update := bson.M{"$inc": bson.M{"click_count": 1}}
-	_, err := r.collection.UpdateOne(ctx, filter, update)
-	return err
+// ClearShadow makes every shadowed link of ownerID resolve for everyone again
+func (r *MongoRepository) ClearShadow(ctx context.Context, ownerID string) (int64, error) {
+	return r.countAcross(func(shard *linkShard) (int64, error) {
+		result, err := shard.collection.UpdateMany(ctx,
+			bson.M{"owner_id": ownerID, "shadow": bson.M{"$exists": true}},
+			bson.M{"$unset": bson.M{"shadow": ""}})
+		if err != nil {
+			return 0, err
+		}
+		return result.ModifiedCount, nil
+	})
+}
+
+// ListIndexable returns up to limit active, unexpired links on domain that
+// opted into sitemaps, newest first
+func (r *MongoRepository) ListIndexable(ctx context.Context, domain string, limit int64) ([]models.ShortURL, error) {
+	filter := bson.M{
+		"domain":     domain,
+		"indexable":  true,
+		"is_active":  true,
+		"deleted_at": nil,
+		"$or": bson.A{
+			bson.M{"expires_at": nil},
+			bson.M{"expires_at": bson.M{"$gt": time.Now()}},
+		},
+	}
+	found, err := r.findNewest(ctx, filter, limit)
+	if err != nil {
+		return nil, err
+	}
+	links := make([]models.ShortURL, len(found))
+	for i, link := range found {
+		links[i] = *link
+	}
+	return links, nil
+}
+
+// FindByDestination returns up to limit of ownerID's links, on any domain,
+// whose normalized destination is urlKey, newest first. Links created before
+// url_key was stored are matched on their exact originalURL
+func (r *MongoRepository) FindByDestination(ctx context.Context, ownerID, urlKey, originalURL string, limit int64) ([]*models.ShortURL, error) {
+	filter := bson.M{
+		"owner_id":   ownerID,
+		"deleted_at": nil,
+		"$or": bson.A{
+			bson.M{"url_key": urlKey},
+			bson.M{"original_url": originalURL, "url_key": bson.M{"$exists": false}},
+		},
+	}
+	return r.findNewest(ctx, filter, limit)
+}
+
+// LinkListSpec is how lists of an owner's or a campaign's links sort and
+// filter; both have indexes on their ID followed by each sort field
+var LinkListSpec = &ListSpec{
+	Sorts: map[string]string{
+		"created_at":  "_id",
+		"click_count": "click_count",
+	},
+	DefaultSort: "-created_at",
+	Filters: map[string]Filter{
+		"health":      {Field: "health.status", Values: LinkHealthFilters, Match: matchHealth},
+		"is_active":   {Field: "is_active", Match: MatchBool},
+		"campaign_id": {Field: "campaign_id"},
+		// Links expiring within this many hours, and not expired yet
+		"expires_within": {Field: "expires_at", Match: matchExpiresWithin},
+		"tag":            {Field: "tags", Match: matchTag},
+	},
+}
+
+// matchTag matches links carrying the tag; tags are stored lowercase
+func matchTag(tag string) (interface{}, error) {
+	return strings.ToLower(tag), nil
+}
+
+func matchExpiresWithin(value string) (interface{}, error) {
+	hours, err := strconv.Atoi(value)
+	if err != nil || hours <= 0 {
+		return nil, errors.New("must be a positive number of hours")
+	}
+	now := time.Now()
+	return bson.M{"$gt": now, "$lte": now.Add(time.Duration(hours) * time.Hour)}, nil
+}
+
+// ListByCampaign returns one page of the campaign's links, sorted and
+// filtered as LinkListSpec allows
+func (r *MongoRepository) ListByCampaign(ctx context.Context, campaignID string, req PageRequest) (*Page[models.ShortURL], error) {
+	return r.listPage(ctx, bson.M{"campaign_id": campaignID, "deleted_at": nil}, req)
+}
+
+// listPage returns one page of the links matching filter, merged from every
+// shard's page. Cursors are positions in the sort order, so each shard
+// resumes after the same one
+func (r *MongoRepository) listPage(ctx context.Context, filter bson.M, req PageRequest) (*Page[models.ShortURL], error) {
+	q, query, matching, err := LinkListSpec.pageQuery(req, filter)
+	if err != nil {
+		return nil, err
+	}
+	var mu sync.Mutex
+	var docs []bson.Raw
+	var total int64
+	err = r.eachShard(func(shard *linkShard) error {
+		return shard.breaker.Do(func() error {
+			found, count, err := q.fetch(ctx, shard.collection, query, matching)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			docs = append(docs, found...)
+			total += count
+			mu.Unlock()
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return buildPage[models.ShortURL](q, docs, total)
+}
+
+// AllByCampaign returns up to limit of the campaign's links, newest first
+func (r *MongoRepository) AllByCampaign(ctx context.Context, campaignID string, limit int64) ([]*models.ShortURL, error) {
+	return r.findNewest(ctx, bson.M{"campaign_id": campaignID, "deleted_at": nil}, limit)
+}
+
+// CountByCampaign returns how many links belong to the campaign
+func (r *MongoRepository) CountByCampaign(ctx context.Context, campaignID string) (int64, error) {
+	return r.countAcross(func(shard *linkShard) (int64, error) {
+		return shard.collection.CountDocuments(ctx, bson.M{"campaign_id": campaignID, "deleted_at": nil})
+	})
 }
 
 // GetStats retrieves statistics for a short URL (same as GetShortURLByCode)
 // This method exists for semantic clarity - you might want to add more stats later
-func (r *MongoRepository) GetStats(ctx context.Context, shortCode string) (*models.ShortURL, error) {
-	return r.GetShortURLByCode(ctx, shortCode)
+func (r *MongoRepository) GetStats(ctx context.Context, domain, shortCode string) (*models.ShortURL, error) {
+	return r.GetShortURLByCode(ctx, domain, shortCode)
+}
+
+// LinkKey identifies a link: short codes are unique per domain
+type LinkKey struct {
+	Domain    string
+	ShortCode string
+}
+
+// ForEachCode calls fn with the key of every stored link, trashed and
+// archived ones included, one shard after another, stopping at the first error
+func (r *MongoRepository) ForEachCode(ctx context.Context, fn func(LinkKey) error) error {
+	opts := options.Find().SetProjection(bson.M{"_id": 0, "domain": 1, "short_code": 1})
+	var collections []*mongo.Collection
+	for _, shard := range r.shards {
+		collections = append(collections, shard.collection, shard.archived)
+	}
+	for _, collection := range collections {
+		cursor, err := collection.Find(ctx, bson.M{}, opts)
+		if err != nil {
+			return translateError(err)
+		}
+		err = func() error {
+			defer cursor.Close(ctx)
+			for cursor.Next(ctx) {
+				var link models.ShortURL
+				if err := cursor.Decode(&link); err != nil {
+					return err
+				}
+				if err := fn(LinkKey{Domain: link.Domain, ShortCode: link.ShortCode}); err != nil {
+					return err
+				}
+			}
+			return cursor.Err()
+		}()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForEachShortURL calls fn with every live link in the hot collections,
+// leaving out trashed and shadowed ones, one shard after another, stopping
+// at the first error
+func (r *MongoRepository) ForEachShortURL(ctx context.Context, fn func(*models.ShortURL) error) error {
+	for _, shard := range r.shards {
+		if err := forEachLink(ctx, shard.collection, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindLinks returns the stored links among keys, trashed, shadowed and
+// archived ones included. Keys with no link are left out
+func (r *MongoRepository) FindLinks(ctx context.Context, keys []LinkKey) (map[LinkKey]*models.ShortURL, error) {
+	byShard := make(map[*linkShard][]LinkKey)
+	for _, key := range keys {
+		shard := r.shardFor(key.ShortCode)
+		byShard[shard] = append(byShard[shard], key)
+	}
+	var mu sync.Mutex
+	found := make(map[LinkKey]*models.ShortURL, len(keys))
+	err := r.eachShard(func(shard *linkShard) error {
+		pending := byShard[shard]
+		for _, collection := range []*mongo.Collection{shard.collection, shard.archived} {
+			if len(pending) == 0 {
+				return nil
+			}
+			filters := make(bson.A, len(pending))
+			for i, key := range pending {
+				filters[i] = linkFilter(key.Domain, key.ShortCode)
+			}
+			var links []*models.ShortURL
+			err := shard.breaker.Do(func() error {
+				cursor, err := collection.Find(ctx, bson.M{"$or": filters})
+				if err != nil {
+					return err
+				}
+				return cursor.All(ctx, &links)
+			})
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			for _, link := range links {
+				found[LinkKey{Domain: link.Domain, ShortCode: link.ShortCode}] = link
+			}
+			pending = slices.DeleteFunc(pending, func(key LinkKey) bool {
+				return found[key] != nil
+			})
+			mu.Unlock()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return found, nil
 }
 
+func forEachLink(ctx context.Context, collection *mongo.Collection, fn func(*models.ShortURL) error) error {
+	cursor, err := collection.Find(ctx, bson.M{"deleted_at": nil, "shadow": bson.M{"$exists": false}})
+	if err != nil {
+		return translateError(err)
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var link models.ShortURL
+		if err := cursor.Decode(&link); err != nil {
+			return err
+		}
+		if err := fn(&link); err != nil {
+			return err
+		}
+	}
+	return translateError(cursor.Err())
+}
+
+// ClickCounts are buffered click increments for one link, split by whether
+// the visitor was classified as a bot
+type ClickCounts struct {
+	Human int64
+	Bot   int64
+}
+
+// linkFilter matches the link with shortCode on domain
+func linkFilter(domain, shortCode string) bson.M {
+	return bson.M{"domain": domainFilter(domain), "short_code": shortCode}
+}
+
+// domainFilter matches links on domain; default-domain links have no domain field
+func domainFilter(domain string) interface{} {
+	if domain == "" {
+		return bson.M{"$in": bson.A{nil, ""}}
+	}
+	return domain
+}
+
+// ownerFilter matches links of ownerID; anonymous links have no owner_id field
+func ownerFilter(ownerID string) interface{} {
+	if ownerID == "" {
+		return bson.M{"$in": bson.A{nil, ""}}
+	}
+	return ownerID
+}
+
+// IsExpectedMongoError reports errors that mean MongoDB answered normally, so
+// they should not count towards tripping a circuit breaker
+func IsExpectedMongoError(err error) bool {
+	return errors.Is(err, mongo.ErrNoDocuments) ||
+		mongo.IsDuplicateKeyError(err) ||
+		errors.Is(err, context.Canceled)
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NotificationRepository handles MongoDB operations for accounts' notification preferences
+type NotificationRepository struct {
+	collection *mongo.Collection
+}
+
+// NewNotificationRepository creates a new notification preferences repository instance
+func NewNotificationRepository(ctx context.Context, client *mongo.Client, dbName, collectionName string) (*NotificationRepository, error) {
+	collection := client.Database(dbName).Collection(collectionName)
+
+	indexModel := mongo.IndexModel{
+		Keys:    bson.D{{Key: "account_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	err := ensureIndexes(ctx, collectionName, func(ctx context.Context) error {
+		_, err := collection.Indexes().CreateOne(ctx, indexModel)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &NotificationRepository{
+		collection: collection,
+	}, nil
+}
+
+// Get returns the account's preferences, or nil if it has never set any
+func (r *NotificationRepository) Get(ctx context.Context, accountID string) (*models.NotificationPreferences, error) {
+	var prefs models.NotificationPreferences
+	err := r.collection.FindOne(ctx, bson.M{"account_id": accountID}).Decode(&prefs)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+// Upsert replaces the account's preferences
+func (r *NotificationRepository) Upsert(ctx context.Context, prefs *models.NotificationPreferences) error {
+	prefs.UpdatedAt = time.Now()
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"account_id": prefs.AccountID}, prefs, options.Replace().SetUpsert(true))
+	return err
+}
+
+// ForEachSubscribed calls fn with the preferences of every account that has
+// opted into some email, stopping at the first error
+func (r *NotificationRepository) ForEachSubscribed(ctx context.Context, fn func(*models.NotificationPreferences) error) error {
+	filter := bson.M{
+		"email": bson.M{"$exists": true},
+		"$or": bson.A{
+			bson.M{"expiry_warning_days": bson.M{"$gt": 0}},
+			bson.M{"click_milestones.0": bson.M{"$exists": true}},
+		},
+	}
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var prefs models.NotificationPreferences
+		if err := cursor.Decode(&prefs); err != nil {
+			return err
+		}
+		if err := fn(&prefs); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
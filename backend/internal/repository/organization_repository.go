@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OrganizationRepository handles MongoDB operations for organizations and
+// their memberships
+type OrganizationRepository struct {
+	organizations *mongo.Collection
+	memberships   *mongo.Collection
+}
+
+// NewOrganizationRepository creates a new organization repository instance
+// Memberships live in their own collection so an account's organizations
+// can be found without scanning every organization
+func NewOrganizationRepository(ctx context.Context, client *mongo.Client, dbName, orgCollectionName, membershipCollectionName string) (*OrganizationRepository, error) {
+	db := client.Database(dbName)
+	organizations := db.Collection(orgCollectionName)
+	memberships := db.Collection(membershipCollectionName)
+
+	err := ensureIndexes(ctx, orgCollectionName, func(ctx context.Context) error {
+		_, err := organizations.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.D{{Key: "external_id", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"external_id": bson.M{"$exists": true}}),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = ensureIndexes(ctx, membershipCollectionName, func(ctx context.Context) error {
+		_, err := memberships.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "org_id", Value: 1}, {Key: "account_id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys: bson.D{{Key: "account_id", Value: 1}},
+			},
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &OrganizationRepository{
+		organizations: organizations,
+		memberships:   memberships,
+	}, nil
+}
+
+// CreateOrganization saves org and makes its creator the first admin
+func (r *OrganizationRepository) CreateOrganization(ctx context.Context, org *models.Organization) error {
+	if org.ID.IsZero() {
+		org.ID = primitive.NewObjectID()
+	}
+	if org.CreatedAt.IsZero() {
+		org.CreatedAt = time.Now()
+	}
+	org.AccountID = models.OrganizationAccountID(org.ID)
+	if _, err := r.organizations.InsertOne(ctx, org); err != nil {
+		return translateError(err)
+	}
+	_, err := r.UpsertMembership(ctx, &models.Membership{OrgID: org.ID, AccountID: org.CreatedBy, Role: models.RoleAdmin})
+	return err
+}
+
+// GetOrganization returns the organization with id, or nil if there is none
+func (r *OrganizationRepository) GetOrganization(ctx context.Context, id primitive.ObjectID) (*models.Organization, error) {
+	var org models.Organization
+	err := r.organizations.FindOne(ctx, bson.M{"_id": id}).Decode(&org)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &org, nil
+}
+
+// GetOrganizationByExternalID returns the organization provisioned as
+// externalID, or nil if there is none
+func (r *OrganizationRepository) GetOrganizationByExternalID(ctx context.Context, externalID string) (*models.Organization, error) {
+	var org models.Organization
+	err := r.organizations.FindOne(ctx, bson.M{"external_id": externalID}).Decode(&org)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &org, nil
+}
+
+// RenameOrganization changes the name of organization id
+func (r *OrganizationRepository) RenameOrganization(ctx context.Context, id primitive.ObjectID, name string) error {
+	_, err := r.organizations.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"name": name}})
+	return err
+}
+
+// ListOrganizationsForAccount returns every organization accountID belongs to,
+// with its role, oldest membership first
+func (r *OrganizationRepository) ListOrganizationsForAccount(ctx context.Context, accountID string) ([]models.OrganizationWithRole, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"account_id": accountID}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         r.organizations.Name(),
+			"localField":   "org_id",
+			"foreignField": "_id",
+			"as":           "organization",
+		}}},
+		{{Key: "$unwind", Value: "$organization"}},
+		{{Key: "$replaceWith", Value: bson.M{"$mergeObjects": bson.A{"$organization", bson.M{"role": "$role"}}}}},
+	}
+	cursor, err := r.memberships.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	orgs := []models.OrganizationWithRole{}
+	if err := cursor.All(ctx, &orgs); err != nil {
+		return nil, err
+	}
+	return orgs, nil
+}
+
+// GetMembership returns accountID's membership of orgID, or nil if it is not a member
+func (r *OrganizationRepository) GetMembership(ctx context.Context, orgID primitive.ObjectID, accountID string) (*models.Membership, error) {
+	var membership models.Membership
+	err := r.memberships.FindOne(ctx, bson.M{"org_id": orgID, "account_id": accountID}).Decode(&membership)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// ListMemberships returns the members of orgID, oldest first
+func (r *OrganizationRepository) ListMemberships(ctx context.Context, orgID primitive.ObjectID) ([]models.Membership, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := r.memberships.Find(ctx, bson.M{"org_id": orgID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	members := []models.Membership{}
+	if err := cursor.All(ctx, &members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// UpsertMembership adds membership.AccountID to the organization or changes its role
+func (r *OrganizationRepository) UpsertMembership(ctx context.Context, membership *models.Membership) (*models.Membership, error) {
+	update := bson.M{
+		"$set":         bson.M{"role": membership.Role},
+		"$setOnInsert": bson.M{"created_at": time.Now()},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var saved models.Membership
+	filter := bson.M{"org_id": membership.OrgID, "account_id": membership.AccountID}
+	if err := r.memberships.FindOneAndUpdate(ctx, filter, update, opts).Decode(&saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// DeleteMembership removes accountID from orgID and reports whether it was a member
+func (r *OrganizationRepository) DeleteMembership(ctx context.Context, orgID primitive.ObjectID, accountID string) (bool, error) {
+	result, err := r.memberships.DeleteOne(ctx, bson.M{"org_id": orgID, "account_id": accountID})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// CountAdmins returns how many admins orgID has
+func (r *OrganizationRepository) CountAdmins(ctx context.Context, orgID primitive.ObjectID) (int64, error) {
+	return r.memberships.CountDocuments(ctx, bson.M{"org_id": orgID, "role": models.RoleAdmin})
+}
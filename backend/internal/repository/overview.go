@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// LinkOverview summarizes every link, live, trashed and archived
+type LinkOverview struct {
+	Total     int64
+	Archived  int64
+	Trashed   int64
+	Human     int64
+	Bot       int64
+	CreatedOn map[string]int64
+	TopLinks  []models.TopLink
+}
+
+// linkFacets is one collection's share of a LinkOverview
+type linkFacets struct {
+	Totals []struct {
+		Total   int64 `bson:"total"`
+		Trashed int64 `bson:"trashed"`
+		Human   int64 `bson:"human"`
+		Bot     int64 `bson:"bot"`
+	} `bson:"totals"`
+	Created []models.DailyCount `bson:"created"`
+	Top     []models.TopLink    `bson:"top"`
+}
+
+// Overview counts links and their clicks, the links created per UTC day
+// since since, and the topN most clicked live links, in one aggregation per
+// collection of each shard
+func (r *MongoRepository) Overview(ctx context.Context, since time.Time, topN int64) (*LinkOverview, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$facet", Value: bson.M{
+			"totals": bson.A{
+				bson.M{"$group": bson.M{
+					"_id":     nil,
+					"total":   bson.M{"$sum": 1},
+					"trashed": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$deleted_at", nil}}, 1, 0}}},
+					"human":   bson.M{"$sum": "$click_count"},
+					"bot":     bson.M{"$sum": "$bot_click_count"},
+				}},
+			},
+			"created": bson.A{
+				bson.M{"$match": bson.M{"created_at": bson.M{"$gte": since}}},
+				bson.M{"$group": bson.M{
+					"_id":   bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$created_at"}},
+					"count": bson.M{"$sum": 1},
+				}},
+			},
+			"top": bson.A{
+				bson.M{"$match": bson.M{"deleted_at": nil, "click_count": bson.M{"$gt": 0}}},
+				bson.M{"$sort": bson.D{{Key: "click_count", Value: -1}}},
+				bson.M{"$limit": topN},
+				bson.M{"$project": bson.M{"_id": 0, "domain": 1, "short_code": 1, "click_count": 1}},
+			},
+		}}},
+	}
+
+	overview := &LinkOverview{CreatedOn: make(map[string]int64)}
+	type source struct {
+		shard    *linkShard
+		archived bool
+	}
+	var sources []source
+	for _, shard := range r.shards {
+		sources = append(sources, source{shard, false}, source{shard, true})
+	}
+	for _, src := range sources {
+		collection := src.shard.collection
+		if src.archived {
+			collection = src.shard.archived
+		}
+		var facets []linkFacets
+		err := src.shard.breaker.Do(func() error {
+			cursor, err := collection.Aggregate(ctx, pipeline)
+			if err != nil {
+				return err
+			}
+			defer cursor.Close(ctx)
+			return cursor.All(ctx, &facets)
+		})
+		if err != nil {
+			return nil, translateError(err)
+		}
+		if len(facets) == 0 {
+			continue
+		}
+		if len(facets[0].Totals) > 0 {
+			totals := facets[0].Totals[0]
+			overview.Total += totals.Total
+			overview.Trashed += totals.Trashed
+			overview.Human += totals.Human
+			overview.Bot += totals.Bot
+			if src.archived {
+				overview.Archived += totals.Total
+			}
+		}
+		for _, day := range facets[0].Created {
+			overview.CreatedOn[day.Day] += day.Count
+		}
+		overview.TopLinks = append(overview.TopLinks, facets[0].Top...)
+	}
+
+	sort.SliceStable(overview.TopLinks, func(i, j int) bool {
+		return overview.TopLinks[i].Clicks > overview.TopLinks[j].Clicks
+	})
+	if int64(len(overview.TopLinks)) > topN {
+		overview.TopLinks = overview.TopLinks[:topN]
+	}
+	return overview, nil
+}
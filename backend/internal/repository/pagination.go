@@ -0,0 +1,356 @@
+package repository
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/base64"
+	"errors"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+
+	// totalEstimateCap bounds the count scan so totals stay cheap on big collections
+	totalEstimateCap = 10000
+)
+
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// PageRequest describes which page of a list the caller wants and how the
+// list is sorted and filtered. Cursor is opaque to clients; it is the
+// NextCursor of the previous page and only valid with the same Sort
+type PageRequest struct {
+	Limit  int
+	Cursor string
+	// Sort is a sort name of the list's ListSpec, prefixed with - for
+	// descending order; empty uses the list's default
+	Sort string
+	// Filters maps filter names of the list's ListSpec to their values
+	Filters map[string]string
+}
+
+// PageInfo is the pagination metadata returned by every list endpoint
+// TotalEstimate is exact below totalEstimateCap and capped above it
+type PageInfo struct {
+	NextCursor    string `json:"next_cursor,omitempty"`
+	HasMore       bool   `json:"has_more"`
+	TotalEstimate int64  `json:"total_estimate"`
+}
+
+// Page is one page of results plus its pagination metadata
+type Page[T any] struct {
+	Items []T `json:"items"`
+	PageInfo
+}
+
+// ListSpec describes how one list can be sorted and filtered. A sort field
+// belongs in Sorts only when an index leads with the list's own filter and
+// continues with the field and _id, so every page is an index scan however
+// deep it is
+type ListSpec struct {
+	// Sorts maps the sort names clients use to document fields. The fields
+	// must be set on every document; ties are broken by _id
+	Sorts map[string]string
+	// DefaultSort is used when the request names none
+	DefaultSort string
+	// Filters maps the filter names clients use to how they match
+	Filters map[string]Filter
+}
+
+// Filter is one filter a list accepts
+type Filter struct {
+	Field string
+	// Values, when set, are the only values accepted
+	Values []string
+	// Match turns a value into the condition on Field; nil matches the value
+	// itself
+	Match func(value string) (interface{}, error)
+}
+
+// CreatedListSpec is for lists only sorted by creation, newest first by
+// default. ObjectIDs increase with creation time, so _id serves the order
+var CreatedListSpec = &ListSpec{
+	Sorts:       map[string]string{"created_at": "_id"},
+	DefaultSort: "-created_at",
+}
+
+// QueryError reports a sort or filter value a list doesn't accept
+type QueryError struct {
+	Param   string
+	Message string
+}
+
+func (e *QueryError) Error() string {
+	return e.Message
+}
+
+// MatchBool is the Match of filters on boolean fields
+func MatchBool(value string) (interface{}, error) {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, errors.New("must be true or false")
+	}
+	return b, nil
+}
+
+// Check reports whether req is valid for the list: a known sort, accepted
+// filter values, and a cursor from a page with the same sort
+func (s *ListSpec) Check(req PageRequest) error {
+	_, err := s.query(req)
+	return err
+}
+
+// listQuery is a PageRequest checked against its ListSpec
+type listQuery struct {
+	limit  int
+	sort   string
+	field  string
+	desc   bool
+	filter bson.M
+	after  *pageCursor
+}
+
+func (s *ListSpec) query(req PageRequest) (*listQuery, error) {
+	q := &listQuery{limit: req.normalizedLimit(), sort: req.Sort, filter: bson.M{}}
+	if q.sort == "" {
+		q.sort = s.DefaultSort
+	}
+	field, ok := s.Sorts[strings.TrimPrefix(q.sort, "-")]
+	if !ok {
+		names := slices.Sorted(maps.Keys(s.Sorts))
+		return nil, &QueryError{Param: "sort", Message: "sort must be one of " + strings.Join(names, ", ") + ", prefixed with - for descending order"}
+	}
+	q.field, q.desc = field, strings.HasPrefix(q.sort, "-")
+
+	for _, name := range slices.Sorted(maps.Keys(req.Filters)) {
+		value := req.Filters[name]
+		filter, ok := s.Filters[name]
+		if !ok {
+			return nil, &QueryError{Param: name, Message: name + " is not a filter of this list"}
+		}
+		if len(filter.Values) > 0 && !slices.Contains(filter.Values, value) {
+			return nil, &QueryError{Param: name, Message: name + " must be one of " + strings.Join(filter.Values, ", ")}
+		}
+		var cond interface{} = value
+		if filter.Match != nil {
+			var err error
+			if cond, err = filter.Match(value); err != nil {
+				return nil, &QueryError{Param: name, Message: name + " " + err.Error()}
+			}
+		}
+		q.filter[filter.Field] = cond
+	}
+
+	if req.Cursor != "" {
+		after, err := decodeCursor(req.Cursor)
+		if err != nil || after.Sort != q.sort {
+			return nil, ErrInvalidCursor
+		}
+		q.after = after
+	}
+	return q, nil
+}
+
+func (q *listQuery) direction() int {
+	if q.desc {
+		return -1
+	}
+	return 1
+}
+
+func (q *listQuery) sortDoc() bson.D {
+	if q.field == "_id" {
+		return bson.D{{Key: "_id", Value: q.direction()}}
+	}
+	return bson.D{{Key: q.field, Value: q.direction()}, {Key: "_id", Value: q.direction()}}
+}
+
+// keyset matches the documents after the cursor in sort order
+func (q *listQuery) keyset() bson.M {
+	op := "$gt"
+	if q.desc {
+		op = "$lt"
+	}
+	if q.field == "_id" {
+		return bson.M{"_id": bson.M{op: q.after.ID}}
+	}
+	return bson.M{"$or": bson.A{
+		bson.M{q.field: bson.M{op: q.after.Value}},
+		bson.M{q.field: q.after.Value, "_id": bson.M{op: q.after.ID}},
+	}}
+}
+
+// pageCursor is the position after the last document of a page: its sort
+// value and _id, plus the sort it is only valid with
+type pageCursor struct {
+	Sort  string        `bson:"s"`
+	Value bson.RawValue `bson:"v"`
+	ID    bson.RawValue `bson:"i"`
+}
+
+// cursorAfter encodes the position after doc
+func (q *listQuery) cursorAfter(doc bson.Raw) (string, error) {
+	value, err := doc.LookupErr(strings.Split(q.field, ".")...)
+	if err != nil {
+		return "", err
+	}
+	raw, err := bson.Marshal(pageCursor{Sort: q.sort, Value: value, ID: doc.Lookup("_id")})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeCursor(cursor string) (*pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var after pageCursor
+	if err := bson.Unmarshal(raw, &after); err != nil || after.Value.Type == 0 || after.ID.Type == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &after, nil
+}
+
+func (p PageRequest) normalizedLimit() int {
+	if p.Limit <= 0 {
+		return DefaultPageLimit
+	}
+	if p.Limit > MaxPageLimit {
+		return MaxPageLimit
+	}
+	return p.Limit
+}
+
+// findPage runs a keyset-paginated query over the documents matching filter,
+// sorted and further filtered as req asks within spec. It never uses skip,
+// so deep pages cost the same as the first one
+func findPage[T any](ctx context.Context, collection *mongo.Collection, filter bson.M, req PageRequest, spec *ListSpec) (*Page[T], error) {
+	q, query, matching, err := spec.pageQuery(req, filter)
+	if err != nil {
+		return nil, err
+	}
+	docs, total, err := q.fetch(ctx, collection, query, matching)
+	if err != nil {
+		return nil, err
+	}
+	return buildPage[T](q, docs, total)
+}
+
+// pageQuery checks req against spec and returns the query for the page, and
+// the filter its total is counted with
+func (s *ListSpec) pageQuery(req PageRequest, filter bson.M) (q *listQuery, query, matching bson.M, err error) {
+	if q, err = s.query(req); err != nil {
+		return nil, nil, nil, err
+	}
+	// The caller's filter is applied last so request filters can only narrow it
+	matching = q.filter
+	for k, v := range filter {
+		matching[k] = v
+	}
+	query = matching
+	if q.after != nil {
+		query = bson.M{"$and": bson.A{matching, q.keyset()}}
+	}
+	return q, query, matching, nil
+}
+
+// fetch reads one more document than the page holds from collection, in
+// sort order, and counts the matching documents up to totalEstimateCap
+func (q *listQuery) fetch(ctx context.Context, collection *mongo.Collection, query, matching bson.M) ([]bson.Raw, int64, error) {
+	opts := options.Find().
+		SetSort(q.sortDoc()).
+		SetLimit(int64(q.limit + 1))
+	cursor, err := collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	docs := make([]bson.Raw, 0, q.limit+1)
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, 0, err
+	}
+	total, err := collection.CountDocuments(ctx, matching, options.Count().SetLimit(totalEstimateCap))
+	if err != nil {
+		return nil, 0, err
+	}
+	return docs, total, nil
+}
+
+// buildPage turns fetched documents into the page. Documents fetched from
+// several collections are merged into sort order first
+func buildPage[T any](q *listQuery, docs []bson.Raw, total int64) (*Page[T], error) {
+	slices.SortStableFunc(docs, q.compare)
+	page := &Page[T]{}
+	page.TotalEstimate = min(total, totalEstimateCap)
+	if len(docs) > q.limit {
+		docs = docs[:q.limit]
+		page.HasMore = true
+		var err error
+		if page.NextCursor, err = q.cursorAfter(docs[len(docs)-1]); err != nil {
+			return nil, err
+		}
+	}
+	page.Items = make([]T, len(docs))
+	for i, doc := range docs {
+		if err := bson.Unmarshal(doc, &page.Items[i]); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// compare orders two documents as the query sorts them
+func (q *listQuery) compare(a, b bson.Raw) int {
+	c := compareValues(a.Lookup(strings.Split(q.field, ".")...), b.Lookup(strings.Split(q.field, ".")...))
+	if c == 0 && q.field != "_id" {
+		c = compareValues(a.Lookup("_id"), b.Lookup("_id"))
+	}
+	if q.desc {
+		return -c
+	}
+	return c
+}
+
+// compareValues orders the BSON values list sort fields hold: ObjectIDs,
+// numbers, dates and strings
+func compareValues(a, b bson.RawValue) int {
+	if aID, ok := a.ObjectIDOK(); ok {
+		if bID, ok := b.ObjectIDOK(); ok {
+			return bytes.Compare(aID[:], bID[:])
+		}
+	}
+	if aNum, ok := a.DoubleOK(); ok {
+		if bNum, ok := b.DoubleOK(); ok {
+			return cmp.Compare(aNum, bNum)
+		}
+	}
+	if aInt, ok := a.AsInt64OK(); ok {
+		if bInt, ok := b.AsInt64OK(); ok {
+			return cmp.Compare(aInt, bInt)
+		}
+	}
+	if aTime, ok := a.DateTimeOK(); ok {
+		if bTime, ok := b.DateTimeOK(); ok {
+			return cmp.Compare(aTime, bTime)
+		}
+	}
+	if aStr, ok := a.StringValueOK(); ok {
+		if bStr, ok := b.StringValueOK(); ok {
+			return strings.Compare(aStr, bStr)
+		}
+	}
+	return bytes.Compare(a.Value, b.Value)
+}
@@ -0,0 +1,226 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/breaker"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// RedisLinkStore is a LinkStore on a single Redis hash with one field per
+// link, so redirects keep working from Redis while MongoDB is down. Each
+// field holds the time its copy was read from MongoDB, a colon and the link
+// as BSON. A deleted link leaves a tombstone holding only the time, so a
+// copy read before the delete can't bring it back
+type RedisLinkStore struct {
+	redisClient redis.UniversalClient
+	key         string
+	breaker     *breaker.Breaker
+}
+
+// MirroredLink is one field of the store
+type MirroredLink struct {
+	Key    LinkKey
+	ReadAt time.Time
+	// Link is nil for a tombstone
+	Link *models.ShortURL
+	// value is the field as read, so Forget leaves a newer one alone
+	value string
+}
+
+func NewRedisLinkStore(redisClient redis.UniversalClient, key string, cb *breaker.Breaker) *RedisLinkStore {
+	return &RedisLinkStore{
+		redisClient: redisClient,
+		key:         key,
+		breaker:     cb,
+	}
+}
+
+// storeScript sets field ARGV[1] to ARGV[3] unless its copy was read after
+// ARGV[2], in microseconds, and returns 1 if it did
+var storeScript = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], ARGV[1])
+if current then
+	local readAt = tonumber(string.match(current, '^(%d+):'))
+	if readAt and readAt > tonumber(ARGV[2]) then
+		return 0
+	end
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[3])
+return 1
+`)
+
+// forgetScript removes field ARGV[1] if it still holds ARGV[2]
+var forgetScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], ARGV[1]) == ARGV[2] then
+	return redis.call('HDEL', KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+// GetShortURLByCode retrieves the stored copy of a link
+func (s *RedisLinkStore) GetShortURLByCode(ctx context.Context, domain, shortCode string) (*models.ShortURL, error) {
+	var value string
+	err := s.breaker.Do(func() error {
+		var err error
+		value, err = s.redisClient.HGet(ctx, s.key, mirrorField(domain, shortCode)).Result()
+		return err
+	})
+	if err != nil {
+		return nil, translateRedisError(err)
+	}
+	copied, err := decodeMirrored(mirrorField(domain, shortCode), value)
+	if err != nil {
+		return nil, err
+	}
+	if copied.Link == nil {
+		return nil, fmt.Errorf("%w: link was deleted", ErrNotFound)
+	}
+	return copied.Link, nil
+}
+
+// SaveShortURL writes a copy of shortURL read at readAt, replacing any read earlier
+func (s *RedisLinkStore) SaveShortURL(ctx context.Context, shortURL *models.ShortURL, readAt time.Time) error {
+	raw, err := bson.Marshal(shortURL)
+	if err != nil {
+		return err
+	}
+	return s.store(ctx, mirrorField(shortURL.Domain, shortURL.ShortCode), readAt, raw)
+}
+
+// DeleteShortURL replaces the copy of a link found missing at readAt with a
+// tombstone; removing a missing copy is not an error
+func (s *RedisLinkStore) DeleteShortURL(ctx context.Context, domain, shortCode string, readAt time.Time) error {
+	return s.store(ctx, mirrorField(domain, shortCode), readAt, nil)
+}
+
+func (s *RedisLinkStore) store(ctx context.Context, field string, readAt time.Time, raw []byte) error {
+	micros := strconv.FormatInt(readAt.UnixMicro(), 10)
+	return translateRedisError(s.breaker.Do(func() error {
+		return storeScript.Run(ctx, s.redisClient, []string{s.key}, field, micros, micros+":"+string(raw)).Err()
+	}))
+}
+
+// GetMany returns the fields of keys in order, nil where a key has none
+func (s *RedisLinkStore) GetMany(ctx context.Context, keys []LinkKey) ([]*MirroredLink, error) {
+	fields := make([]string, len(keys))
+	for i, key := range keys {
+		fields[i] = mirrorField(key.Domain, key.ShortCode)
+	}
+	var values []interface{}
+	err := s.breaker.Do(func() error {
+		var err error
+		values, err = s.redisClient.HMGet(ctx, s.key, fields...).Result()
+		return err
+	})
+	if err != nil {
+		return nil, translateRedisError(err)
+	}
+	copies := make([]*MirroredLink, len(keys))
+	for i, value := range values {
+		value, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if copies[i], err = decodeMirrored(fields[i], value); err != nil {
+			return nil, err
+		}
+	}
+	return copies, nil
+}
+
+// Scan calls fn with every field in batches of about count, stopping at the
+// first error. Fields changed during the scan may be seen twice or not at all
+func (s *RedisLinkStore) Scan(ctx context.Context, count int64, fn func([]*MirroredLink) error) error {
+	var cursor uint64
+	for {
+		var page []string
+		err := s.breaker.Do(func() error {
+			var err error
+			page, cursor, err = s.redisClient.HScan(ctx, s.key, cursor, "", count).Result()
+			return err
+		})
+		if err != nil {
+			return translateRedisError(err)
+		}
+		copies := make([]*MirroredLink, 0, len(page)/2)
+		for i := 0; i+1 < len(page); i += 2 {
+			copied, err := decodeMirrored(page[i], page[i+1])
+			if err != nil {
+				return err
+			}
+			copies = append(copies, copied)
+		}
+		if len(copies) > 0 {
+			if err := fn(copies); err != nil {
+				return err
+			}
+		}
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// Forget removes a field read by GetMany or Scan, unless it changed since
+func (s *RedisLinkStore) Forget(ctx context.Context, copied *MirroredLink) error {
+	return translateRedisError(s.breaker.Do(func() error {
+		field := mirrorField(copied.Key.Domain, copied.Key.ShortCode)
+		return forgetScript.Run(ctx, s.redisClient, []string{s.key}, field, copied.value).Err()
+	}))
+}
+
+// mirrorField names default-domain links by their code and prefixes branded
+// ones with the host, which can't contain a slash
+func mirrorField(domain, shortCode string) string {
+	if domain == "" {
+		return shortCode
+	}
+	return domain + "/" + shortCode
+}
+
+func decodeMirrored(field, value string) (*MirroredLink, error) {
+	copied := &MirroredLink{value: value}
+	if domain, shortCode, ok := strings.Cut(field, "/"); ok {
+		copied.Key = LinkKey{Domain: domain, ShortCode: shortCode}
+	} else {
+		copied.Key = LinkKey{ShortCode: field}
+	}
+	micros, raw, _ := strings.Cut(value, ":")
+	readAt, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("malformed mirrored link %q: %w", field, err)
+	}
+	copied.ReadAt = time.UnixMicro(readAt)
+	if raw == "" {
+		return copied, nil
+	}
+	copied.Link = &models.ShortURL{}
+	if err := bson.Unmarshal([]byte(raw), copied.Link); err != nil {
+		return nil, fmt.Errorf("malformed mirrored link %q: %w", field, err)
+	}
+	return copied, nil
+}
+
+// translateRedisError maps client and breaker errors onto the repository errors
+func translateRedisError(err error) error {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, redis.Nil):
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	case errors.Is(err, breaker.ErrOpen), errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr):
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ShadowBanRepository handles MongoDB operations for shadow-banned accounts
+type ShadowBanRepository struct {
+	collection *mongo.Collection
+}
+
+// NewShadowBanRepository creates a new shadow ban repository instance
+func NewShadowBanRepository(ctx context.Context, client *mongo.Client, dbName, collectionName string) (*ShadowBanRepository, error) {
+	collection := client.Database(dbName).Collection(collectionName)
+
+	indexModel := mongo.IndexModel{
+		Keys:    bson.D{{Key: "account_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	err := ensureIndexes(ctx, collectionName, func(ctx context.Context) error {
+		_, err := collection.Indexes().CreateOne(ctx, indexModel)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ShadowBanRepository{
+		collection: collection,
+	}, nil
+}
+
+// Upsert bans ban.AccountID, replacing the reason of an existing ban
+func (r *ShadowBanRepository) Upsert(ctx context.Context, ban *models.ShadowBan) (*models.ShadowBan, error) {
+	if ban.CreatedAt.IsZero() {
+		ban.CreatedAt = time.Now()
+	}
+	update := bson.M{
+		"$set":         bson.M{"reason": ban.Reason},
+		"$setOnInsert": bson.M{"created_at": ban.CreatedAt},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var saved models.ShadowBan
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"account_id": ban.AccountID}, update, opts).Decode(&saved)
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// Get returns the account's ban, or nil if it isn't banned
+func (r *ShadowBanRepository) Get(ctx context.Context, accountID string) (*models.ShadowBan, error) {
+	var ban models.ShadowBan
+	err := r.collection.FindOne(ctx, bson.M{"account_id": accountID}).Decode(&ban)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &ban, nil
+}
+
+// Delete lifts the account's ban and reports whether there was one
+func (r *ShadowBanRepository) Delete(ctx context.Context, accountID string) (bool, error) {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"account_id": accountID})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// List returns every ban, newest first
+func (r *ShadowBanRepository) List(ctx context.Context) ([]models.ShadowBan, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}})
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	bans := []models.ShadowBan{}
+	if err := cursor.All(ctx, &bans); err != nil {
+		return nil, err
+	}
+	return bans, nil
+}
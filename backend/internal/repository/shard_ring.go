@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"strconv"
+)
+
+// shardVirtualNodes is how many points each shard has on the ring; more
+// points spread codes more evenly between shards
+const shardVirtualNodes = 128
+
+// hashRing places short codes on shards by consistent hashing. Each shard
+// owns the arcs ending at its points, so adding or removing one of n shards
+// only moves the codes on its arcs, about 1/n of them
+type hashRing struct {
+	points []ringPoint
+}
+
+type ringPoint struct {
+	hash  uint64
+	shard int
+}
+
+// newHashRing places the shards by name, so the ring doesn't depend on the
+// order they are listed in
+func newHashRing(names []string) *hashRing {
+	ring := &hashRing{points: make([]ringPoint, 0, len(names)*shardVirtualNodes)}
+	for i, name := range names {
+		for v := 0; v < shardVirtualNodes; v++ {
+			ring.points = append(ring.points, ringPoint{hash: ringHash(name + "#" + strconv.Itoa(v)), shard: i})
+		}
+	}
+	sort.Slice(ring.points, func(i, j int) bool {
+		return ring.points[i].hash < ring.points[j].hash
+	})
+	return ring
+}
+
+// locate returns the index of the shard owning key
+func (r *hashRing) locate(key string) int {
+	hash := ringHash(key)
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= hash
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].shard
+}
+
+func ringHash(key string) uint64 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
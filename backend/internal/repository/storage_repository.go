@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"sort"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// StorageRepository reads the size of the database's collections
+type StorageRepository struct {
+	db *mongo.Database
+}
+
+// NewStorageRepository creates a new storage repository instance
+func NewStorageRepository(client *mongo.Client, dbName string) *StorageRepository {
+	return &StorageRepository{db: client.Database(dbName)}
+}
+
+// Collections returns the size of every collection, sorted by name. Sharded
+// collections report one entry summed over their shards
+func (r *StorageRepository) Collections(ctx context.Context) ([]models.CollectionStorage, error) {
+	names, err := r.db.ListCollectionNames(ctx, bson.M{"type": "collection", "name": bson.M{"$not": bson.M{"$regex": "^system\\."}}})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	pipeline := mongo.Pipeline{{{Key: "$collStats", Value: bson.M{"storageStats": bson.M{}}}}}
+	collections := make([]models.CollectionStorage, 0, len(names))
+	for _, name := range names {
+		cursor, err := r.db.Collection(name).Aggregate(ctx, pipeline)
+		if err != nil {
+			return nil, err
+		}
+		var shards []struct {
+			StorageStats struct {
+				Count          int64 `bson:"count"`
+				Size           int64 `bson:"size"`
+				StorageSize    int64 `bson:"storageSize"`
+				TotalIndexSize int64 `bson:"totalIndexSize"`
+			} `bson:"storageStats"`
+		}
+		if err := cursor.All(ctx, &shards); err != nil {
+			return nil, err
+		}
+		storage := models.CollectionStorage{Name: name}
+		for _, shard := range shards {
+			storage.Documents += shard.StorageStats.Count
+			storage.SizeBytes += shard.StorageStats.Size
+			storage.StorageBytes += shard.StorageStats.StorageSize
+			storage.IndexBytes += shard.StorageStats.TotalIndexSize
+		}
+		collections = append(collections, storage)
+	}
+	return collections, nil
+}
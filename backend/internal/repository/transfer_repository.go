@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TransferRepository handles MongoDB operations for link transfers
+type TransferRepository struct {
+	collection *mongo.Collection
+}
+
+// NewTransferRepository creates a new transfer repository instance
+func NewTransferRepository(ctx context.Context, client *mongo.Client, dbName, collectionName string) (*TransferRepository, error) {
+	collection := client.Database(dbName).Collection(collectionName)
+
+	indexModels := []mongo.IndexModel{
+		// A link has at most one pending transfer
+		{
+			Keys: bson.D{{Key: "domain", Value: 1}, {Key: "short_code", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"status": models.TransferPending}),
+		},
+		{Keys: bson.D{{Key: "to_account", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "from_account", Value: 1}, {Key: "_id", Value: -1}}},
+	}
+	err := ensureIndexes(ctx, collectionName, func(ctx context.Context) error {
+		_, err := collection.Indexes().CreateMany(ctx, indexModels)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TransferRepository{
+		collection: collection,
+	}, nil
+}
+
+// Create saves a new pending transfer. ErrDuplicate means the link already
+// has one
+func (r *TransferRepository) Create(ctx context.Context, transfer *models.LinkTransfer) error {
+	if transfer.ID.IsZero() {
+		transfer.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, transfer)
+	return translateError(err)
+}
+
+// Get returns the transfer with id; a missing transfer is ErrNotFound
+func (r *TransferRepository) Get(ctx context.Context, id primitive.ObjectID) (*models.LinkTransfer, error) {
+	var transfer models.LinkTransfer
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&transfer); err != nil {
+		return nil, translateError(err)
+	}
+	return &transfer, nil
+}
+
+// Resolve moves transfer id from status from to status to and returns it
+// Closing a pending transfer only succeeds before it expires, so a transfer
+// is accepted, declined or cancelled at most once; anything else is ErrNotFound
+func (r *TransferRepository) Resolve(ctx context.Context, id primitive.ObjectID, from, to, by string, at time.Time) (*models.LinkTransfer, error) {
+	filter := bson.M{"_id": id, "status": from}
+	if from == models.TransferPending {
+		filter["expires_at"] = bson.M{"$gt": at}
+	}
+	update := bson.M{"$set": bson.M{"status": to, "responded_by": by, "responded_at": at}}
+	if to == models.TransferPending {
+		update = bson.M{
+			"$set":   bson.M{"status": to},
+			"$unset": bson.M{"responded_by": "", "responded_at": ""},
+		}
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var transfer models.LinkTransfer
+	if err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&transfer); err != nil {
+		return nil, translateError(err)
+	}
+	return &transfer, nil
+}
+
+// ExpirePending marks the link's pending transfer expired if its time is
+// up, making way for a new one
+func (r *TransferRepository) ExpirePending(ctx context.Context, domain, shortCode string, now time.Time) error {
+	filter := linkFilter(domain, shortCode)
+	filter["status"] = models.TransferPending
+	filter["expires_at"] = bson.M{"$lte": now}
+	_, err := r.collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"status": models.TransferExpired}})
+	return translateError(err)
+}
+
+// ListByRecipient returns one page of the transfers offered to accountID, newest first
+func (r *TransferRepository) ListByRecipient(ctx context.Context, accountID string, req PageRequest) (*Page[models.LinkTransfer], error) {
+	page, err := findPage[models.LinkTransfer](ctx, r.collection, bson.M{"to_account": accountID}, req, CreatedListSpec)
+	return page, translateError(err)
+}
+
+// ListBySender returns one page of the transfers accountID offered, newest first
+func (r *TransferRepository) ListBySender(ctx context.Context, accountID string, req PageRequest) (*Page[models.LinkTransfer], error) {
+	page, err := findPage[models.LinkTransfer](ctx, r.collection, bson.M{"from_account": accountID}, req, CreatedListSpec)
+	return page, translateError(err)
+}
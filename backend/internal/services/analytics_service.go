@@ -0,0 +1,779 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrInvalidStatsQuery = newError(http.StatusBadRequest, utils.ErrCodeInvalidQuery, "invalid stats query", "")
+	// ErrStatsQueryTooCostly is returned with guidance on how to make the query cheaper
+	ErrStatsQueryTooCostly = newError(http.StatusUnprocessableEntity, utils.ErrCodeQueryTooCostly, "stats query too expensive", "")
+)
+
+const (
+	GranularityHour = "hour"
+	GranularityDay  = "day"
+	GranularityWeek = "week"
+
+	defaultStatsRange = 30 * 24 * time.Hour
+	topBreakdownLimit = 10
+	// MaxReferrerLimit bounds how many referrers one breakdown may list
+	MaxReferrerLimit = 100
+	// MaxCountryLimit bounds how many countries one breakdown may list
+	MaxCountryLimit = 100
+	// citiesPerCountry is how many cities a country breakdown lists for each
+	citiesPerCountry = 5
+)
+
+// StatsQuery selects the range, bucket size and traffic for click analytics
+// Zero values default to daily buckets of human clicks over the last 30 days
+type StatsQuery struct {
+	From        time.Time
+	To          time.Time
+	Granularity string
+	Traffic     repository.TrafficFilter
+}
+
+// StatsLimits bound how much work one stats request may do so heavy dashboard
+// queries can't starve the database the redirect path depends on
+type StatsLimits struct {
+	MaxBuckets    int
+	MaxRange      time.Duration
+	MaxBatchCodes int
+	Timeout       time.Duration
+}
+
+// AnalyticsService records click events and aggregates them for the stats endpoint
+// Ranges reaching past the hot window also read events from the archive when one is configured
+type AnalyticsService struct {
+	clickRepo    *repository.ClickRepository
+	conversions  *repository.ConversionRepository
+	quotaService *QuotaService
+	archive      *ClickArchiver
+	feed         *ClickFeed
+	limits       StatsLimits
+	// privacyMode puts every link in privacy mode
+	privacyMode bool
+}
+
+func NewAnalyticsService(clickRepo *repository.ClickRepository, conversions *repository.ConversionRepository, quotaService *QuotaService, archive *ClickArchiver, feed *ClickFeed, limits StatsLimits, privacyMode bool) *AnalyticsService {
+	return &AnalyticsService{
+		clickRepo:    clickRepo,
+		conversions:  conversions,
+		quotaService: quotaService,
+		archive:      archive,
+		feed:         feed,
+		limits:       limits,
+		privacyMode:  privacyMode,
+	}
+}
+
+// Tracks reports whether visits to shortURL are recorded as click events.
+// Links in privacy mode only count their clicks
+func (s *AnalyticsService) Tracks(shortURL *models.ShortURL) bool {
+	return !s.privacyMode && !shortURL.Privacy
+}
+
+// Clicks returns one page of the click events recorded for shortURL; links
+// that aren't tracked have none
+func (s *AnalyticsService) Clicks(ctx context.Context, shortURL *models.ShortURL, page repository.PageRequest) (*repository.Page[models.ClickEvent], error) {
+	if !s.Tracks(shortURL) {
+		return &repository.Page[models.ClickEvent]{Items: []models.ClickEvent{}}, nil
+	}
+	return s.clickRepo.ListByShortCode(ctx, shortURL.Domain, shortURL.ShortCode, page)
+}
+
+// WatchClicks returns the click events on shortURL as they are recorded,
+// until ctx ends. Links that aren't tracked record none, so theirs stays quiet
+func (s *AnalyticsService) WatchClicks(ctx context.Context, shortURL *models.ShortURL) (<-chan models.ClickEvent, error) {
+	return s.feed.Watch(ctx, shortURL.Domain, shortURL.ShortCode)
+}
+
+// RecordClick stores a click event for shortURL and charges its size to the
+// owner, and passes it on to dashboards watching the link
+func (s *AnalyticsService) RecordClick(ctx context.Context, shortURL *models.ShortURL, visit Visit) error {
+	if !s.Tracks(shortURL) {
+		return nil
+	}
+	event := &models.ClickEvent{
+		Domain:         shortURL.Domain,
+		ShortCode:      shortURL.ShortCode,
+		OwnerID:        shortURL.OwnerID,
+		ClickedAt:      time.Now().UTC(),
+		Referrer:       visit.Referrer,
+		ReferrerDomain: referrerDomain(visit.Referrer),
+		UserAgent:      visit.UserAgent,
+		Device:         string(utils.ParseDevice(visit.UserAgent)),
+		Country:        strings.ToUpper(visit.Country),
+		City:           visit.City,
+		Bot:            visit.Bot,
+		Variant:        visit.Variant,
+	}
+	if err := s.clickRepo.RecordClick(ctx, event); err != nil {
+		return fmt.Errorf("failed to record click event: %w", err)
+	}
+	if err := s.feed.Publish(ctx, event); err != nil {
+		fmt.Printf("Failed to publish click event: %v\n", err)
+	}
+	if event.OwnerID != "" {
+		if raw, err := bson.Marshal(event); err == nil {
+			if err := s.quotaService.RecordEventStorage(ctx, event.OwnerID, int64(len(raw))); err != nil {
+				return fmt.Errorf("failed to record event storage: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// RecordConversion stores a conversion for one of shortURL's A/B variants
+func (s *AnalyticsService) RecordConversion(ctx context.Context, shortURL *models.ShortURL, variant string) error {
+	err := s.conversions.Record(ctx, &models.Conversion{
+		Domain:      shortURL.Domain,
+		ShortCode:   shortURL.ShortCode,
+		Variant:     variant,
+		ConvertedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrUnavailable) {
+			return ErrServiceUnavailable
+		}
+		return fmt.Errorf("failed to record conversion: %w", err)
+	}
+	return nil
+}
+
+// TransferClicks attributes shortURL's recorded clicks to its new owner, so
+// exports list them under the account that now has the link. Archived
+// clicks keep the owner they were archived with
+func (s *AnalyticsService) TransferClicks(ctx context.Context, shortURL *models.ShortURL, ownerID string) error {
+	if err := s.clickRepo.SetOwner(ctx, shortURL.Domain, shortURL.ShortCode, ownerID); err != nil {
+		return fmt.Errorf("failed to reassign click events: %w", err)
+	}
+	return nil
+}
+
+// Stats aggregates click events for shortURL over the requested range
+func (s *AnalyticsService) Stats(ctx context.Context, shortURL *models.ShortURL, query StatsQuery) (*models.LinkStats, error) {
+	query, err := normalizeStatsQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkCost(query); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.limits.Timeout)
+	defer cancel()
+
+	stats, err := s.aggregate(ctx, shortURL, query, false)
+	if err != nil {
+		return nil, s.timeoutError(err)
+	}
+	return stats, nil
+}
+
+// Referrers breaks shortURL's clicks over the range down by referring domain
+// and returns the limit largest; the granularity of query is ignored
+func (s *AnalyticsService) Referrers(ctx context.Context, shortURL *models.ShortURL, query StatsQuery, limit int) (*models.ReferrerStats, error) {
+	query.Granularity = ""
+	query, err := normalizeStatsQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if limit < 1 || limit > MaxReferrerLimit {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidStatsQuery, MaxReferrerLimit)
+	}
+	if err := s.checkCost(query); err != nil {
+		return nil, err
+	}
+	if !s.Tracks(shortURL) {
+		return &models.ReferrerStats{
+			ShortCode: shortURL.ShortCode,
+			Domain:    shortURL.Domain,
+			From:      query.From,
+			To:        query.To,
+			Traffic:   string(query.Traffic),
+			Analytics: models.AnalyticsAggregate,
+			Referrers: []models.ReferrerShare{},
+		}, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.limits.Timeout)
+	defer cancel()
+
+	code := shortURL.ShortCode
+	topLimit := limit
+	var cold []models.ClickEvent
+	withArchive := s.archive != nil && query.From.Before(s.archive.HotStart())
+	if withArchive {
+		cold, err = s.archive.ColdEvents(ctx, shortURL.Domain, code, query.From, query.To)
+		if err != nil {
+			return nil, s.timeoutError(err)
+		}
+		cold = filterTraffic(cold, query.Traffic)
+		// Hot rankings must be complete to merge with cold counts
+		topLimit = 0
+	}
+	total, err := s.clickRepo.Count(ctx, shortURL.Domain, code, query.From, query.To, query.Traffic)
+	if err != nil {
+		return nil, s.timeoutError(fmt.Errorf("failed to count clicks: %w", err))
+	}
+	direct, err := s.clickRepo.CountMissing(ctx, shortURL.Domain, code, "referrer_domain", query.From, query.To, query.Traffic)
+	if err != nil {
+		return nil, s.timeoutError(fmt.Errorf("failed to count direct clicks: %w", err))
+	}
+	referrers, err := s.clickRepo.TopValues(ctx, shortURL.Domain, code, "referrer_domain", query.From, query.To, query.Traffic, topLimit)
+	if err != nil {
+		return nil, s.timeoutError(fmt.Errorf("failed to aggregate referrers: %w", err))
+	}
+	if withArchive {
+		total += int64(len(cold))
+		for i := range cold {
+			if cold[i].ReferrerDomain == "" {
+				direct++
+			}
+		}
+		referrers = mergeTopValues(referrers, cold, func(e *models.ClickEvent) string { return e.ReferrerDomain }, limit)
+	}
+
+	stats := &models.ReferrerStats{
+		ShortCode:    code,
+		Domain:       shortURL.Domain,
+		From:         query.From,
+		To:           query.To,
+		Traffic:      string(query.Traffic),
+		Analytics:    models.AnalyticsFull,
+		TotalClicks:  total,
+		DirectClicks: direct,
+		Referrers:    make([]models.ReferrerShare, len(referrers)),
+	}
+	for i, entry := range referrers {
+		share := models.ReferrerShare{Domain: entry.Key, Clicks: entry.Clicks}
+		if total > 0 {
+			// Rounded to two decimals, as shown in dashboards
+			share.Percentage = math.Round(float64(entry.Clicks)*10000/float64(total)) / 100
+		}
+		stats.Referrers[i] = share
+	}
+	return stats, nil
+}
+
+// Countries breaks shortURL's clicks over the range down by country, listing
+// the limit largest with their top cities; the granularity of query is ignored
+func (s *AnalyticsService) Countries(ctx context.Context, shortURL *models.ShortURL, query StatsQuery, limit int) (*models.CountryStats, error) {
+	query.Granularity = ""
+	query, err := normalizeStatsQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if limit < 1 || limit > MaxCountryLimit {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidStatsQuery, MaxCountryLimit)
+	}
+	if err := s.checkCost(query); err != nil {
+		return nil, err
+	}
+	if !s.Tracks(shortURL) {
+		return &models.CountryStats{
+			ShortCode: shortURL.ShortCode,
+			Domain:    shortURL.Domain,
+			From:      query.From,
+			To:        query.To,
+			Traffic:   string(query.Traffic),
+			Analytics: models.AnalyticsAggregate,
+			Countries: []models.CountryShare{},
+		}, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.limits.Timeout)
+	defer cancel()
+
+	code := shortURL.ShortCode
+	topLimit := limit
+	var cold []models.ClickEvent
+	withArchive := s.archive != nil && query.From.Before(s.archive.HotStart())
+	if withArchive {
+		cold, err = s.archive.ColdEvents(ctx, shortURL.Domain, code, query.From, query.To)
+		if err != nil {
+			return nil, s.timeoutError(err)
+		}
+		cold = filterTraffic(cold, query.Traffic)
+		// Hot rankings must be complete to merge with cold counts
+		topLimit = 0
+	}
+	total, err := s.clickRepo.Count(ctx, shortURL.Domain, code, query.From, query.To, query.Traffic)
+	if err != nil {
+		return nil, s.timeoutError(fmt.Errorf("failed to count clicks: %w", err))
+	}
+	unknown, err := s.clickRepo.CountMissing(ctx, shortURL.Domain, code, "country", query.From, query.To, query.Traffic)
+	if err != nil {
+		return nil, s.timeoutError(fmt.Errorf("failed to count clicks of unknown location: %w", err))
+	}
+	countries, err := s.clickRepo.TopValues(ctx, shortURL.Domain, code, "country", query.From, query.To, query.Traffic, topLimit)
+	if err != nil {
+		return nil, s.timeoutError(fmt.Errorf("failed to aggregate countries: %w", err))
+	}
+	// Every city, since the top cities of each listed country are needed
+	cities, err := s.clickRepo.TopCities(ctx, shortURL.Domain, code, query.From, query.To, query.Traffic, 0)
+	if err != nil {
+		return nil, s.timeoutError(fmt.Errorf("failed to aggregate cities: %w", err))
+	}
+	if withArchive {
+		total += int64(len(cold))
+		for i := range cold {
+			if cold[i].Country == "" {
+				unknown++
+			}
+		}
+		countries = mergeTopValues(countries, cold, func(e *models.ClickEvent) string { return e.Country }, limit)
+		cities = mergeTopValues(cities, cold, eventCity, 0)
+	}
+
+	share := func(clicks int64) float64 {
+		if total == 0 {
+			return 0
+		}
+		// Rounded to two decimals, as shown in dashboards
+		return math.Round(float64(clicks)*10000/float64(total)) / 100
+	}
+	byCountry := make(map[string][]models.CityShare, len(countries))
+	for _, city := range cityCounts(cities) {
+		if listed := byCountry[city.Country]; len(listed) < citiesPerCountry {
+			byCountry[city.Country] = append(listed, models.CityShare{City: city.City, Clicks: city.Clicks, Percentage: share(city.Clicks)})
+		}
+	}
+	stats := &models.CountryStats{
+		ShortCode:     code,
+		Domain:        shortURL.Domain,
+		From:          query.From,
+		To:            query.To,
+		Traffic:       string(query.Traffic),
+		Analytics:     models.AnalyticsFull,
+		TotalClicks:   total,
+		UnknownClicks: unknown,
+		Countries:     make([]models.CountryShare, len(countries)),
+	}
+	for i, entry := range countries {
+		stats.Countries[i] = models.CountryShare{
+			Country:    entry.Key,
+			Clicks:     entry.Clicks,
+			Percentage: share(entry.Clicks),
+			Cities:     byCountry[entry.Key],
+		}
+		if stats.Countries[i].Cities == nil {
+			stats.Countries[i].Cities = []models.CityShare{}
+		}
+	}
+	return stats, nil
+}
+
+// StatsBatch aggregates several links with one shared deadline
+func (s *AnalyticsService) StatsBatch(ctx context.Context, shortURLs []*models.ShortURL, query StatsQuery) ([]*models.LinkStats, error) {
+	if err := s.CheckBatchSize(len(shortURLs)); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.limits.Timeout)
+	defer cancel()
+	results := make([]*models.LinkStats, 0, len(shortURLs))
+	for _, shortURL := range shortURLs {
+		stats, err := s.Stats(ctx, shortURL, query)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, stats)
+	}
+	return results, nil
+}
+
+// CombinedStats sums the analytics of several links over one range, with one
+// shared deadline; ClickCount and BotClickCount are the links' lifetime totals
+// The caller fills in the campaign the links belong to
+func (s *AnalyticsService) CombinedStats(ctx context.Context, shortURLs []*models.ShortURL, query StatsQuery) (*models.CampaignStats, error) {
+	query, err := normalizeStatsQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkCost(query); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.limits.Timeout)
+	defer cancel()
+
+	combined := &models.CampaignStats{
+		LinkCount:   len(shortURLs),
+		From:        query.From,
+		To:          query.To,
+		Granularity: query.Granularity,
+		Traffic:     string(query.Traffic),
+	}
+	var series []models.TimeBucket
+	var referrers, countries, cities, links []models.CountEntry
+	tracked := 0
+	for _, shortURL := range shortURLs {
+		if s.Tracks(shortURL) {
+			tracked++
+		}
+		stats, err := s.aggregate(ctx, shortURL, query, true)
+		if err != nil {
+			return nil, s.timeoutError(err)
+		}
+		var clicks int64
+		for _, bucket := range stats.TimeSeries {
+			clicks += bucket.Clicks
+		}
+		if clicks > 0 {
+			links = append(links, models.CountEntry{Key: linkLabel(shortURL), Clicks: clicks})
+		}
+		series = append(series, stats.TimeSeries...)
+		referrers = append(referrers, stats.TopReferrers...)
+		countries = append(countries, stats.TopCountries...)
+		cities = append(cities, cityEntries(stats.TopCities)...)
+		combined.ClickCount += shortURL.ClickCount
+		combined.BotClickCount += shortURL.BotClickCount
+	}
+	combined.TimeSeries = sumTimeSeries(series)
+	combined.TopReferrers = sumTopValues(referrers, topBreakdownLimit)
+	combined.TopCountries = sumTopValues(countries, topBreakdownLimit)
+	combined.TopCities = cityCounts(sumTopValues(cities, topBreakdownLimit))
+	combined.TopLinks = sumTopValues(links, topBreakdownLimit)
+	switch tracked {
+	case len(shortURLs):
+		combined.Analytics = models.AnalyticsFull
+	case 0:
+		combined.Analytics = models.AnalyticsAggregate
+	default:
+		combined.Analytics = models.AnalyticsPartial
+	}
+	return combined, nil
+}
+
+// timeoutError explains a query that ran out of time; other errors are returned unchanged
+func (s *AnalyticsService) timeoutError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err) {
+		return fmt.Errorf("%w: the query did not finish within %s; narrow the range or use a coarser granularity", ErrStatsQueryTooCostly, s.limits.Timeout)
+	}
+	return err
+}
+
+// CheckBatchSize rejects batches with more codes than allowed
+func (s *AnalyticsService) CheckBatchSize(n int) error {
+	if n > s.limits.MaxBatchCodes {
+		return fmt.Errorf("%w: at most %d codes per batch; split the request", ErrStatsQueryTooCostly, s.limits.MaxBatchCodes)
+	}
+	return nil
+}
+
+// checkCost rejects ranges that are too long overall or for the granularity
+func (s *AnalyticsService) checkCost(query StatsQuery) error {
+	span := query.To.Sub(query.From)
+	if span > s.limits.MaxRange {
+		return fmt.Errorf("%w: the range may span at most %s; narrow from/to", ErrStatsQueryTooCostly, s.limits.MaxRange)
+	}
+	bucket := time.Hour
+	switch query.Granularity {
+	case GranularityDay:
+		bucket = 24 * time.Hour
+	case GranularityWeek:
+		bucket = 7 * 24 * time.Hour
+	}
+	if buckets := int(span / bucket); buckets > s.limits.MaxBuckets {
+		suggestion := "narrow the range"
+		if query.Granularity != GranularityWeek {
+			suggestion = "use a coarser granularity or narrow the range"
+		}
+		return fmt.Errorf("%w: %s granularity over this range gives %d buckets, the limit is %d; %s", ErrStatsQueryTooCostly, query.Granularity, buckets, s.limits.MaxBuckets, suggestion)
+	}
+	return nil
+}
+
+// aggregate computes one link's stats; complete returns every referrer and
+// country instead of the top ones, so the results can be summed with others
+func (s *AnalyticsService) aggregate(ctx context.Context, shortURL *models.ShortURL, query StatsQuery, complete bool) (*models.LinkStats, error) {
+	if !s.Tracks(shortURL) {
+		// The link's own click counts are all there is
+		return &models.LinkStats{
+			ShortURL:     shortURL,
+			From:         query.From,
+			To:           query.To,
+			Granularity:  query.Granularity,
+			Traffic:      string(query.Traffic),
+			Analytics:    models.AnalyticsAggregate,
+			TimeSeries:   []models.TimeBucket{},
+			TopReferrers: []models.CountEntry{},
+			TopCountries: []models.CountEntry{},
+			TopCities:    []models.CityCount{},
+		}, nil
+	}
+	var err error
+	code := shortURL.ShortCode
+	var cold []models.ClickEvent
+	keep := topBreakdownLimit
+	if complete {
+		keep = 0
+	}
+	topLimit := keep
+	withArchive := s.archive != nil && query.From.Before(s.archive.HotStart())
+	if withArchive {
+		cold, err = s.archive.ColdEvents(ctx, shortURL.Domain, code, query.From, query.To)
+		if err != nil {
+			return nil, err
+		}
+		cold = filterTraffic(cold, query.Traffic)
+		// Hot rankings must be complete to merge with cold counts
+		topLimit = 0
+	}
+
+	series, err := s.clickRepo.TimeSeries(ctx, shortURL.Domain, code, query.From, query.To, query.Traffic, query.Granularity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate time series: %w", err)
+	}
+	referrers, err := s.clickRepo.TopValues(ctx, shortURL.Domain, code, "referrer_domain", query.From, query.To, query.Traffic, topLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate referrers: %w", err)
+	}
+	countries, err := s.clickRepo.TopValues(ctx, shortURL.Domain, code, "country", query.From, query.To, query.Traffic, topLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate countries: %w", err)
+	}
+	cities, err := s.clickRepo.TopCities(ctx, shortURL.Domain, code, query.From, query.To, query.Traffic, topLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate cities: %w", err)
+	}
+	var variants []models.VariantStats
+	if len(shortURL.Variants) > 0 && !complete {
+		variants, err = s.variantStats(ctx, shortURL, query, cold)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if withArchive {
+		series = mergeTimeSeries(series, cold, query.Granularity)
+		referrers = mergeTopValues(referrers, cold, func(e *models.ClickEvent) string { return e.ReferrerDomain }, keep)
+		countries = mergeTopValues(countries, cold, func(e *models.ClickEvent) string { return e.Country }, keep)
+		cities = mergeTopValues(cities, cold, eventCity, keep)
+	}
+
+	return &models.LinkStats{
+		ShortURL:     shortURL,
+		From:         query.From,
+		To:           query.To,
+		Granularity:  query.Granularity,
+		Traffic:      string(query.Traffic),
+		Analytics:    models.AnalyticsFull,
+		TimeSeries:   series,
+		TopReferrers: referrers,
+		TopCountries: countries,
+		TopCities:    cityCounts(cities),
+		Variants:     variants,
+	}, nil
+}
+
+// variantStats compares clicks and conversions of the link's current A/B
+// variants; cold holds the link's archived events in the range
+func (s *AnalyticsService) variantStats(ctx context.Context, shortURL *models.ShortURL, query StatsQuery, cold []models.ClickEvent) ([]models.VariantStats, error) {
+	clicks, err := s.clickRepo.TopValues(ctx, shortURL.Domain, shortURL.ShortCode, "variant", query.From, query.To, query.Traffic, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate variants: %w", err)
+	}
+	clicks = mergeTopValues(clicks, cold, func(e *models.ClickEvent) string { return e.Variant }, 0)
+	conversions, err := s.conversions.CountByVariant(ctx, shortURL.Domain, shortURL.ShortCode, query.From, query.To)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count conversions: %w", err)
+	}
+	counts := func(entries []models.CountEntry, name string) int64 {
+		for _, entry := range entries {
+			if entry.Key == name {
+				return entry.Clicks
+			}
+		}
+		return 0
+	}
+	stats := make([]models.VariantStats, len(shortURL.Variants))
+	for i, variant := range shortURL.Variants {
+		stats[i] = models.VariantStats{
+			Name:        variant.Name,
+			URL:         variant.URL,
+			Weight:      variant.Weight,
+			Clicks:      counts(clicks, variant.Name),
+			Conversions: counts(conversions, variant.Name),
+		}
+		if stats[i].Clicks > 0 {
+			stats[i].ConversionRate = float64(stats[i].Conversions) / float64(stats[i].Clicks)
+		}
+	}
+	return stats, nil
+}
+
+// filterTraffic keeps the archived events the traffic filter selects
+func filterTraffic(events []models.ClickEvent, traffic repository.TrafficFilter) []models.ClickEvent {
+	if traffic == repository.TrafficAll {
+		return events
+	}
+	kept := events[:0]
+	for _, event := range events {
+		if event.Bot == (traffic == repository.TrafficBot) {
+			kept = append(kept, event)
+		}
+	}
+	return kept
+}
+
+// mergeTimeSeries adds archived events into hot buckets, bucketing them the
+// way $dateTrunc does (UTC, weeks starting on Sunday)
+func mergeTimeSeries(series []models.TimeBucket, cold []models.ClickEvent, granularity string) []models.TimeBucket {
+	merged := append([]models.TimeBucket{}, series...)
+	for i := range cold {
+		merged = append(merged, models.TimeBucket{Start: bucketStart(cold[i].ClickedAt, granularity), Clicks: 1})
+	}
+	return sumTimeSeries(merged)
+}
+
+// sumTimeSeries adds up buckets with the same start, oldest first
+func sumTimeSeries(series []models.TimeBucket) []models.TimeBucket {
+	counts := make(map[time.Time]int64, len(series))
+	for _, bucket := range series {
+		counts[bucket.Start.UTC()] += bucket.Clicks
+	}
+	summed := make([]models.TimeBucket, 0, len(counts))
+	for start, clicks := range counts {
+		summed = append(summed, models.TimeBucket{Start: start, Clicks: clicks})
+	}
+	sort.Slice(summed, func(i, j int) bool { return summed[i].Start.Before(summed[j].Start) })
+	return summed
+}
+
+// mergeTopValues adds archived events into complete hot counts and keeps the
+// limit top entries, or all of them for a limit of 0
+func mergeTopValues(hot []models.CountEntry, cold []models.ClickEvent, field func(*models.ClickEvent) string, limit int) []models.CountEntry {
+	merged := append([]models.CountEntry{}, hot...)
+	for i := range cold {
+		if key := field(&cold[i]); key != "" {
+			merged = append(merged, models.CountEntry{Key: key, Clicks: 1})
+		}
+	}
+	return sumTopValues(merged, limit)
+}
+
+// eventCity keys an event's city like ClickRepository.TopCities
+func eventCity(e *models.ClickEvent) string {
+	if e.City == "" {
+		return ""
+	}
+	return e.Country + "/" + e.City
+}
+
+// cityCounts converts city rankings keyed by eventCity
+func cityCounts(entries []models.CountEntry) []models.CityCount {
+	counts := make([]models.CityCount, len(entries))
+	for i, entry := range entries {
+		country, city, _ := strings.Cut(entry.Key, "/")
+		counts[i] = models.CityCount{Country: country, City: city, Clicks: entry.Clicks}
+	}
+	return counts
+}
+
+// cityEntries converts city rankings back to entries keyed by eventCity
+func cityEntries(counts []models.CityCount) []models.CountEntry {
+	entries := make([]models.CountEntry, len(counts))
+	for i, count := range counts {
+		entries[i] = models.CountEntry{Key: count.Country + "/" + count.City, Clicks: count.Clicks}
+	}
+	return entries
+}
+
+// sumTopValues adds up complete counts with the same key and keeps the limit
+// top entries, or all of them for a limit of 0
+func sumTopValues(entries []models.CountEntry, limit int) []models.CountEntry {
+	counts := make(map[string]int64, len(entries))
+	for _, entry := range entries {
+		counts[entry.Key] += entry.Clicks
+	}
+	summed := make([]models.CountEntry, 0, len(counts))
+	for key, clicks := range counts {
+		summed = append(summed, models.CountEntry{Key: key, Clicks: clicks})
+	}
+	sort.Slice(summed, func(i, j int) bool {
+		if summed[i].Clicks != summed[j].Clicks {
+			return summed[i].Clicks > summed[j].Clicks
+		}
+		return summed[i].Key < summed[j].Key
+	})
+	if limit > 0 && len(summed) > limit {
+		summed = summed[:limit]
+	}
+	return summed
+}
+
+// linkLabel names a link in rankings: its code, prefixed with a branded host
+func linkLabel(shortURL *models.ShortURL) string {
+	if shortURL.Domain == "" {
+		return shortURL.ShortCode
+	}
+	return shortURL.Domain + "/" + shortURL.ShortCode
+}
+
+func bucketStart(t time.Time, granularity string) time.Time {
+	t = t.UTC()
+	switch granularity {
+	case GranularityHour:
+		return t.Truncate(time.Hour)
+	case GranularityWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -int(day.Weekday()))
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+func normalizeStatsQuery(query StatsQuery) (StatsQuery, error) {
+	if query.Granularity == "" {
+		query.Granularity = GranularityDay
+	}
+	switch query.Granularity {
+	case GranularityHour, GranularityDay, GranularityWeek:
+	default:
+		return query, fmt.Errorf("%w: granularity must be hour, day or week", ErrInvalidStatsQuery)
+	}
+	if query.Traffic == "" {
+		query.Traffic = repository.TrafficHuman
+	}
+	switch query.Traffic {
+	case repository.TrafficHuman, repository.TrafficBot, repository.TrafficAll:
+	default:
+		return query, fmt.Errorf("%w: traffic must be human, bot or all", ErrInvalidStatsQuery)
+	}
+	if query.To.IsZero() {
+		// End of the current minute, so repeated requests describe the same
+		// range and unchanged stats keep their ETag
+		query.To = time.Now().UTC().Truncate(time.Minute).Add(time.Minute)
+	}
+	if query.From.IsZero() {
+		query.From = query.To.Add(-defaultStatsRange)
+	}
+	if !query.From.Before(query.To) {
+		return query, fmt.Errorf("%w: from must be before to", ErrInvalidStatsQuery)
+	}
+	return query, nil
+}
+
+// referrerDomain reduces a Referer header to its host for grouping
+func referrerDomain(referrer string) string {
+	if referrer == "" {
+		return ""
+	}
+	parsed, err := url.Parse(referrer)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrInvalidAPIKey        = errors.New("invalid API key")
+	ErrAPIKeyRevoked        = newError(http.StatusConflict, utils.ErrCodeAPIKeyRevoked, "API key revoked", "API key is revoked")
+	ErrAPIKeyExpired        = errors.New("API key expired")
+	ErrAPIKeyNotFound       = newError(http.StatusNotFound, utils.ErrCodeAPIKeyNotFound, "API key not found", "API key not found")
+	ErrAPIKeyAlreadyRotated = newError(http.StatusConflict, utils.ErrCodeAPIKeyRotated, "API key already rotated", "API key has already been rotated")
+	ErrInvalidScope         = newFieldError("scopes", validators.CodeInvalid, "invalid API key scope", "scopes must be "+models.ScopeShorten+", "+models.ScopeLinksWrite+", "+models.ScopeStatsRead+" or "+models.ScopeAdmin)
+	ErrAPIKeySandboxChanged = newFieldError("sandbox", validators.CodeInvalid, "API key sandbox mode can't be changed", "sandbox can't be changed once the key is issued")
+)
+
+const apiKeyPrefix = "usk_"
+
+type APIKeyService struct {
+	repo         *repository.APIKeyRepository
+	defaultGrace time.Duration
+}
+
+func NewAPIKeyService(repo *repository.APIKeyRepository, defaultGrace time.Duration) *APIKeyService {
+	return &APIKeyService{
+		repo:         repo,
+		defaultGrace: defaultGrace,
+	}
+}
+
+// CreateKey issues a new API key for an account limited to scopes
+// No scopes means a full-access admin key; a sandbox key only creates test links
+// The plaintext key is returned once and never stored
+func (s *APIKeyService) CreateKey(ctx context.Context, accountID, name string, scopes []string, sandbox bool) (string, *models.APIKey, error) {
+	if len(scopes) == 0 {
+		scopes = []string{models.ScopeAdmin}
+	}
+	for _, scope := range scopes {
+		if !models.ValidScope(scope) {
+			return "", nil, ErrInvalidScope
+		}
+	}
+	plaintext, key, err := newAPIKey(accountID, name)
+	if err != nil {
+		return "", nil, err
+	}
+	key.Scopes = scopes
+	key.Sandbox = sandbox
+	if err := s.repo.CreateAPIKey(ctx, key); err != nil {
+		return "", nil, fmt.Errorf("failed to create API key: %w", err)
+	}
+	return plaintext, key, nil
+}
+
+// RotateKey issues a successor for key id while the old key keeps working for
+// the grace window (the service default when grace is zero)
+func (s *APIKeyService) RotateKey(ctx context.Context, accountID string, id primitive.ObjectID, grace time.Duration) (string, *models.APIKey, error) {
+	old, err := s.getOwnedKey(ctx, accountID, id)
+	if err != nil {
+		return "", nil, err
+	}
+	if old.RevokedAt != nil {
+		return "", nil, ErrAPIKeyRevoked
+	}
+	if old.SuccessorID != nil {
+		return "", nil, ErrAPIKeyAlreadyRotated
+	}
+	if grace <= 0 {
+		grace = s.defaultGrace
+	}
+
+	plaintext, key, err := newAPIKey(accountID, old.Name)
+	if err != nil {
+		return "", nil, err
+	}
+	key.Version = old.Version + 1
+	key.Scopes = old.Scopes
+	key.Plan = old.Plan
+	key.LinkQuota = old.LinkQuota
+	key.AllowedOrigins = old.AllowedOrigins
+	key.Sandbox = old.Sandbox
+	key.QuotaID = old.QuotaSubject()
+	key.PredecessorID = &old.ID
+	if err := s.repo.CreateAPIKey(ctx, key); err != nil {
+		return "", nil, fmt.Errorf("failed to create API key: %w", err)
+	}
+	if err := s.repo.SetSuccessor(ctx, old.ID, key.ID, time.Now().Add(grace)); err != nil {
+		return "", nil, fmt.Errorf("failed to mark API key as rotated: %w", err)
+	}
+	if old.ExternalID != "" {
+		// Provisioning tools keep managing the key under the same ID
+		if err := s.repo.MoveExternalID(ctx, old.ID, key.ID, old.ExternalID); err != nil {
+			return "", nil, fmt.Errorf("failed to move API key external ID: %w", err)
+		}
+		key.ExternalID = old.ExternalID
+	}
+	return plaintext, key, nil
+}
+
+// APIKeySpec is the desired state of a provisioned key
+type APIKeySpec struct {
+	Name           string
+	Scopes         []string
+	Plan           string
+	LinkQuota      *models.LinkQuota
+	AllowedOrigins []string
+	Sandbox        bool
+}
+
+// PutKey makes the account's key provisioned as externalID match spec,
+// issuing it if there is none yet. The plaintext key is only returned, and
+// created only true, when the key was issued
+func (s *APIKeyService) PutKey(ctx context.Context, accountID, externalID string, spec APIKeySpec) (string, *models.APIKey, bool, error) {
+	scopes := spec.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{models.ScopeAdmin}
+	}
+	for _, scope := range scopes {
+		if !models.ValidScope(scope) {
+			return "", nil, false, ErrInvalidScope
+		}
+	}
+	existing, err := s.repo.GetAPIKeyByExternalID(ctx, accountID, externalID)
+	if err != nil {
+		return "", nil, false, fmt.Errorf("failed to look up API key: %w", err)
+	}
+	if existing == nil {
+		plaintext, key, err := newAPIKey(accountID, spec.Name)
+		if err != nil {
+			return "", nil, false, err
+		}
+		key.ExternalID = externalID
+		key.Scopes = scopes
+		key.Plan = spec.Plan
+		key.LinkQuota = spec.LinkQuota
+		key.AllowedOrigins = spec.AllowedOrigins
+		key.Sandbox = spec.Sandbox
+		err = s.repo.CreateAPIKey(ctx, key)
+		if err == nil {
+			return plaintext, key, true, nil
+		}
+		if !errors.Is(err, repository.ErrDuplicate) {
+			return "", nil, false, fmt.Errorf("failed to create API key: %w", err)
+		}
+		// A concurrent request issued it first; update that one instead
+		if existing, err = s.repo.GetAPIKeyByExternalID(ctx, accountID, externalID); err != nil {
+			return "", nil, false, fmt.Errorf("failed to look up API key: %w", err)
+		}
+		if existing == nil {
+			return "", nil, false, ErrAPIKeyNotFound
+		}
+	}
+	if existing.Sandbox != spec.Sandbox {
+		return "", nil, false, ErrAPIKeySandboxChanged
+	}
+	existing.Name = spec.Name
+	existing.Scopes = scopes
+	existing.Plan = spec.Plan
+	existing.LinkQuota = spec.LinkQuota
+	existing.AllowedOrigins = spec.AllowedOrigins
+	key, err := s.repo.UpdateSettings(ctx, existing)
+	if err != nil {
+		return "", nil, false, fmt.Errorf("failed to update API key: %w", err)
+	}
+	if key == nil {
+		return "", nil, false, ErrAPIKeyNotFound
+	}
+	return "", key, false, nil
+}
+
+// GetProvisionedKey returns the account's key provisioned as externalID
+func (s *APIKeyService) GetProvisionedKey(ctx context.Context, accountID, externalID string) (*models.APIKey, error) {
+	key, err := s.repo.GetAPIKeyByExternalID(ctx, accountID, externalID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up API key: %w", err)
+	}
+	if key == nil {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+// RevokeProvisionedKey revokes the account's key provisioned as externalID
+// and reports whether there was one
+func (s *APIKeyService) RevokeProvisionedKey(ctx context.Context, accountID, externalID string) (bool, error) {
+	key, err := s.repo.GetAPIKeyByExternalID(ctx, accountID, externalID)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up API key: %w", err)
+	}
+	if key == nil {
+		return false, nil
+	}
+	if err := s.repo.RevokeAPIKey(ctx, key.ID); err != nil {
+		return false, fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	return true, nil
+}
+
+// SetQuota assigns key id a quota plan and optional per-key override
+// An empty plan is the default plan; a nil quota removes the override
+func (s *APIKeyService) SetQuota(ctx context.Context, id primitive.ObjectID, plan string, quota *models.LinkQuota) (*models.APIKey, error) {
+	key, err := s.repo.SetQuota(ctx, id, plan, quota)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update API key quota: %w", err)
+	}
+	if key == nil {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+// SetAllowedOrigins restricts the browser origins key id may be used
+// from; empty origins lift the restriction
+func (s *APIKeyService) SetAllowedOrigins(ctx context.Context, id primitive.ObjectID, origins []string) (*models.APIKey, error) {
+	key, err := s.repo.SetAllowedOrigins(ctx, id, origins)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update API key origins: %w", err)
+	}
+	if key == nil {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+// RevokeKey invalidates one of the account's keys immediately
+func (s *APIKeyService) RevokeKey(ctx context.Context, accountID string, id primitive.ObjectID) error {
+	if _, err := s.getOwnedKey(ctx, accountID, id); err != nil {
+		return err
+	}
+	return s.repo.RevokeAPIKey(ctx, id)
+}
+
+// ListKeys returns the account's keys with their per-key usage
+func (s *APIKeyService) ListKeys(ctx context.Context, accountID string, page repository.PageRequest) (*repository.Page[models.APIKey], error) {
+	return s.repo.ListAPIKeysByAccount(ctx, accountID, page)
+}
+
+func (s *APIKeyService) getOwnedKey(ctx context.Context, accountID string, id primitive.ObjectID) (*models.APIKey, error) {
+	key, err := s.repo.GetAPIKeyByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up API key: %w", err)
+	}
+	if key == nil || key.AccountID != accountID {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+// Authenticate resolves a plaintext API key to its stored record
+func (s *APIKeyService) Authenticate(ctx context.Context, plaintext string) (*models.APIKey, error) {
+	key, err := s.repo.GetAPIKeyByHash(ctx, hashAPIKey(plaintext))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up API key: %w", err)
+	}
+	if key == nil {
+		return nil, ErrInvalidAPIKey
+	}
+	if key.RevokedAt != nil {
+		return nil, ErrAPIKeyRevoked
+	}
+	if key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt) {
+		return nil, ErrAPIKeyExpired
+	}
+	if err := s.repo.RecordUsage(ctx, key.ID); err != nil {
+		log.Printf("Failed to record API key usage: %v", err)
+	}
+	return key, nil
+}
+
+func newAPIKey(accountID, name string) (string, *models.APIKey, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	plaintext := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return plaintext, &models.APIKey{
+		AccountID: accountID,
+		Name:      name,
+		Prefix:    plaintext[:len(apiKeyPrefix)+6],
+		KeyHash:   hashAPIKey(plaintext),
+		Version:   1,
+	}, nil
+}
+
+func hashAPIKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
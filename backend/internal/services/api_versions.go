@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// API versions served under /api/<version>
+const (
+	APIVersion1 = "v1"
+	APIVersion2 = "v2"
+)
+
+// APIVersions lists the served API versions, oldest first
+var APIVersions = []string{APIVersion1, APIVersion2}
+
+// Deprecation announces that an API version will be retired
+type Deprecation struct {
+	DeprecatedAt time.Time
+	SunsetAt     time.Time
+	// MigrationURL documents how to move to the successor version
+	MigrationURL string
+}
+
+// APIVersionMetrics counts requests per API version and remembers which
+// accounts still call each one, so clients of a deprecated version can be
+// found before it is retired. Counts are buffered in memory and added to
+// Redis every interval
+type APIVersionMetrics struct {
+	redisClient  redis.UniversalClient
+	deprecations map[string]Deprecation
+	interval     time.Duration
+
+	mu       sync.Mutex
+	pending  map[string]apiVersionCounts
+	lastSeen map[string]map[string]time.Time
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+type apiVersionCounts struct {
+	requests     int64
+	clientErrors int64
+	serverErrors int64
+}
+
+func NewAPIVersionMetrics(redisClient redis.UniversalClient, deprecations map[string]Deprecation, interval time.Duration) *APIVersionMetrics {
+	return &APIVersionMetrics{
+		redisClient:  redisClient,
+		deprecations: deprecations,
+		interval:     interval,
+		pending:      make(map[string]apiVersionCounts),
+		lastSeen:     make(map[string]map[string]time.Time),
+		stop:         make(chan struct{}),
+		stopped:      make(chan struct{}),
+	}
+}
+
+// Deprecation returns the announced retirement of version, if any
+func (m *APIVersionMetrics) Deprecation(version string) (Deprecation, bool) {
+	deprecation, ok := m.deprecations[version]
+	return deprecation, ok
+}
+
+// Observe counts one request to version; accountID is empty for anonymous calls
+func (m *APIVersionMetrics) Observe(version, accountID string, status int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts := m.pending[version]
+	counts.requests++
+	switch {
+	case status >= 500:
+		counts.serverErrors++
+	case status >= 400:
+		counts.clientErrors++
+	}
+	m.pending[version] = counts
+	if accountID == "" {
+		return
+	}
+	if m.lastSeen[version] == nil {
+		m.lastSeen[version] = make(map[string]time.Time)
+	}
+	m.lastSeen[version][accountID] = time.Now()
+}
+
+// Start runs the flush loop until Stop is called
+func (m *APIVersionMetrics) Start() {
+	go func() {
+		defer close(m.stopped)
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-m.stop:
+				return
+			}
+			if err := m.Flush(context.Background()); err != nil {
+				log.Printf("Failed to flush API version metrics: %v", err)
+			}
+		}
+	}()
+}
+
+// Stop ends the flush loop and writes whatever is still buffered
+func (m *APIVersionMetrics) Stop(ctx context.Context) error {
+	close(m.stop)
+	<-m.stopped
+	return m.Flush(ctx)
+}
+
+// Flush adds the buffered counts to Redis; on failure they are put back so
+// the next flush retries them
+func (m *APIVersionMetrics) Flush(ctx context.Context) error {
+	m.mu.Lock()
+	batch, seen := m.pending, m.lastSeen
+	m.pending = make(map[string]apiVersionCounts)
+	m.lastSeen = make(map[string]map[string]time.Time)
+	m.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+	pipe := m.redisClient.TxPipeline()
+	for version, counts := range batch {
+		pipe.HIncrBy(ctx, apiVersionMetricsKey(version), "requests", counts.requests)
+		pipe.HIncrBy(ctx, apiVersionMetricsKey(version), "client_errors", counts.clientErrors)
+		pipe.HIncrBy(ctx, apiVersionMetricsKey(version), "server_errors", counts.serverErrors)
+	}
+	for version, accounts := range seen {
+		values := make(map[string]interface{}, len(accounts))
+		for accountID, at := range accounts {
+			values[accountID] = at.Unix()
+		}
+		pipe.HSet(ctx, apiVersionAccountsKey(version), values)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		m.mu.Lock()
+		for version, counts := range batch {
+			merged := m.pending[version]
+			merged.requests += counts.requests
+			merged.clientErrors += counts.clientErrors
+			merged.serverErrors += counts.serverErrors
+			m.pending[version] = merged
+		}
+		for version, accounts := range seen {
+			if m.lastSeen[version] == nil {
+				m.lastSeen[version] = accounts
+				continue
+			}
+			for accountID, at := range accounts {
+				if at.After(m.lastSeen[version][accountID]) {
+					m.lastSeen[version][accountID] = at
+				}
+			}
+		}
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Reports summarizes the traffic of every API version, oldest first
+func (m *APIVersionMetrics) Reports(ctx context.Context) ([]models.APIVersionReport, error) {
+	pipe := m.redisClient.Pipeline()
+	counts := make([]*redis.MapStringStringCmd, len(APIVersions))
+	accounts := make([]*redis.IntCmd, len(APIVersions))
+	for i, version := range APIVersions {
+		counts[i] = pipe.HGetAll(ctx, apiVersionMetricsKey(version))
+		accounts[i] = pipe.HLen(ctx, apiVersionAccountsKey(version))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to read API version metrics: %w", err)
+	}
+	reports := make([]models.APIVersionReport, len(APIVersions))
+	for i, version := range APIVersions {
+		fields := counts[i].Val()
+		report := models.APIVersionReport{Version: version, Accounts: accounts[i].Val()}
+		fmt.Sscan(fields["requests"], &report.Requests)
+		fmt.Sscan(fields["client_errors"], &report.ClientErrors)
+		fmt.Sscan(fields["server_errors"], &report.ServerErrors)
+		if deprecation, ok := m.deprecations[version]; ok {
+			if !deprecation.DeprecatedAt.IsZero() {
+				report.DeprecatedAt = &deprecation.DeprecatedAt
+			}
+			if !deprecation.SunsetAt.IsZero() {
+				report.SunsetAt = &deprecation.SunsetAt
+			}
+		}
+		reports[i] = report
+	}
+	return reports, nil
+}
+
+// Accounts lists the accounts that called version, most recent first
+func (m *APIVersionMetrics) Accounts(ctx context.Context, version string) ([]models.APIVersionAccount, error) {
+	fields, err := m.redisClient.HGetAll(ctx, apiVersionAccountsKey(version)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API version accounts: %w", err)
+	}
+	accounts := make([]models.APIVersionAccount, 0, len(fields))
+	for accountID, raw := range fields {
+		seconds, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			continue
+		}
+		accounts = append(accounts, models.APIVersionAccount{AccountID: accountID, LastSeen: time.Unix(seconds, 0).UTC()})
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].LastSeen.After(accounts[j].LastSeen)
+	})
+	return accounts, nil
+}
+
+func apiVersionMetricsKey(version string) string {
+	return "api_version:" + version
+}
+
+func apiVersionAccountsKey(version string) string {
+	return "api_version:" + version + ":accounts"
+}
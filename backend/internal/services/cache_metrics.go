@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const cacheMetricsKey = "cache:metrics"
+
+// CacheMetrics counts link cache hits and misses. Counts are buffered in
+// memory and added to Redis every interval, so the ratio covers every replica.
+// Replicas tagged with a region also count their lookups under it, so each
+// region's hit ratio shows how often its redirects skip MongoDB
+type CacheMetrics struct {
+	redisClient redis.UniversalClient
+	region      string
+	interval    time.Duration
+
+	mu     sync.Mutex
+	hits   int64
+	misses int64
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+func NewCacheMetrics(redisClient redis.UniversalClient, region string, interval time.Duration) *CacheMetrics {
+	return &CacheMetrics{
+		redisClient: redisClient,
+		region:      region,
+		interval:    interval,
+		stop:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+}
+
+// Observe counts one lookup; nil metrics count nothing
+func (m *CacheMetrics) Observe(hit bool) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if hit {
+		m.hits++
+	} else {
+		m.misses++
+	}
+}
+
+// Start runs the flush loop until Stop is called
+func (m *CacheMetrics) Start() {
+	go func() {
+		defer close(m.stopped)
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-m.stop:
+				return
+			}
+			if err := m.Flush(context.Background()); err != nil {
+				log.Printf("Failed to flush cache metrics: %v", err)
+			}
+		}
+	}()
+}
+
+// Stop ends the flush loop and writes whatever is still buffered
+func (m *CacheMetrics) Stop(ctx context.Context) error {
+	close(m.stop)
+	<-m.stopped
+	return m.Flush(ctx)
+}
+
+// Flush adds the buffered counts to Redis; on failure they are put back so
+// the next flush retries them
+func (m *CacheMetrics) Flush(ctx context.Context) error {
+	m.mu.Lock()
+	hits, misses := m.hits, m.misses
+	m.hits, m.misses = 0, 0
+	m.mu.Unlock()
+
+	if hits == 0 && misses == 0 {
+		return nil
+	}
+	pipe := m.redisClient.TxPipeline()
+	pipe.HIncrBy(ctx, cacheMetricsKey, "hits", hits)
+	pipe.HIncrBy(ctx, cacheMetricsKey, "misses", misses)
+	if m.region != "" {
+		pipe.HIncrBy(ctx, cacheMetricsKey, "hits:"+m.region, hits)
+		pipe.HIncrBy(ctx, cacheMetricsKey, "misses:"+m.region, misses)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		m.mu.Lock()
+		m.hits += hits
+		m.misses += misses
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Stats returns the lookups counted so far across every replica
+func (m *CacheMetrics) Stats(ctx context.Context) (models.CacheStats, error) {
+	fields, err := m.redisClient.HGetAll(ctx, cacheMetricsKey).Result()
+	if err != nil {
+		return models.CacheStats{}, fmt.Errorf("failed to read cache metrics: %w", err)
+	}
+	var stats models.CacheStats
+	fmt.Sscan(fields["hits"], &stats.Hits)
+	fmt.Sscan(fields["misses"], &stats.Misses)
+	stats.HitRatio = hitRatio(stats.Hits, stats.Misses)
+	for field, value := range fields {
+		kind, region, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+		if stats.Regions == nil {
+			stats.Regions = make(map[string]models.RegionCacheStats)
+		}
+		regional := stats.Regions[region]
+		if kind == "hits" {
+			fmt.Sscan(value, &regional.Hits)
+		} else {
+			fmt.Sscan(value, &regional.Misses)
+		}
+		regional.HitRatio = hitRatio(regional.Hits, regional.Misses)
+		stats.Regions[region] = regional
+	}
+	return stats, nil
+}
+
+func hitRatio(hits, misses int64) float64 {
+	if lookups := hits + misses; lookups > 0 {
+		return float64(hits) / float64(lookups)
+	}
+	return 0
+}
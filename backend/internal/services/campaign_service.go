@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrCampaignNotFound    = newError(http.StatusNotFound, utils.ErrCodeCampaignNotFound, "campaign not found", "Campaign not found")
+	ErrInvalidCampaignName = newFieldError("name", validators.CodeRequired, "campaign name is required", "name is required")
+	// ErrCampaignFull means adding the links would exceed the campaign size limit
+	ErrCampaignFull = newError(http.StatusConflict, utils.ErrCodeCampaignFull, "campaign is full", "Campaign is full")
+)
+
+// CampaignService groups an account's links into campaigns and reports
+// their clicks together. Campaign size is capped so stats stay cheap
+type CampaignService struct {
+	repo       *repository.CampaignRepository
+	links      *repository.MongoRepository
+	urlService *URLService
+	analytics  *AnalyticsService
+	maxLinks   int
+}
+
+func NewCampaignService(repo *repository.CampaignRepository, links *repository.MongoRepository, urlService *URLService, analytics *AnalyticsService, maxLinks int) *CampaignService {
+	return &CampaignService{
+		repo:       repo,
+		links:      links,
+		urlService: urlService,
+		analytics:  analytics,
+		maxLinks:   maxLinks,
+	}
+}
+
+// BundleLink is one link to create with a campaign
+type BundleLink struct {
+	URL     string
+	Options ShortenOptions
+}
+
+// BundleResult is the outcome of one BundleLink: the link or why it failed
+type BundleResult struct {
+	Link *models.ShortURL
+	Err  error
+}
+
+// MaxLinks is how many links a campaign may hold
+func (s *CampaignService) MaxLinks() int {
+	return s.maxLinks
+}
+
+// Create makes an empty campaign owned by ownerID
+func (s *CampaignService) Create(ctx context.Context, ownerID, name, description string) (*models.Campaign, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrInvalidCampaignName
+	}
+	campaign := &models.Campaign{OwnerID: ownerID, Name: name, Description: strings.TrimSpace(description)}
+	if err := s.repo.Create(ctx, campaign); err != nil {
+		return nil, campaignError(err)
+	}
+	return campaign, nil
+}
+
+// CreateBundle makes a campaign and shortens every link into it
+// Links are created independently: one failing doesn't undo the others,
+// and the results are in the order of links
+func (s *CampaignService) CreateBundle(ctx context.Context, ownerID, name, description string, links []BundleLink) (*models.Campaign, []BundleResult, error) {
+	if len(links) > s.maxLinks {
+		return nil, nil, ErrCampaignFull
+	}
+	campaign, err := s.Create(ctx, ownerID, name, description)
+	if err != nil {
+		return nil, nil, err
+	}
+	results := make([]BundleResult, len(links))
+	for i, link := range links {
+		opts := link.Options
+		opts.OwnerID = ownerID
+		opts.CampaignID = campaign.ID.Hex()
+		results[i].Link, results[i].Err = s.urlService.ShortenURL(ctx, link.URL, opts)
+	}
+	return campaign, results, nil
+}
+
+// Get returns ownerID's campaign with id
+// Other accounts' campaigns are ErrCampaignNotFound so IDs can't be probed
+func (s *CampaignService) Get(ctx context.Context, ownerID, id string) (*models.Campaign, error) {
+	campaignID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrCampaignNotFound
+	}
+	campaign, err := s.repo.Get(ctx, campaignID)
+	if err != nil {
+		return nil, campaignError(err)
+	}
+	if campaign.OwnerID != ownerID {
+		return nil, ErrCampaignNotFound
+	}
+	return campaign, nil
+}
+
+// CheckCapacity makes sure ownerID's campaign exists and has room for n more links
+func (s *CampaignService) CheckCapacity(ctx context.Context, ownerID, id string, n int) error {
+	campaign, err := s.Get(ctx, ownerID, id)
+	if err != nil {
+		return err
+	}
+	count, err := s.links.CountByCampaign(ctx, campaign.ID.Hex())
+	if err != nil {
+		return campaignError(err)
+	}
+	if int(count)+n > s.maxLinks {
+		return ErrCampaignFull
+	}
+	return nil
+}
+
+// List returns one page of ownerID's campaigns, newest first
+func (s *CampaignService) List(ctx context.Context, ownerID string, page repository.PageRequest) (*repository.Page[models.Campaign], error) {
+	campaigns, err := s.repo.ListByOwner(ctx, ownerID, page)
+	if err != nil {
+		return nil, campaignError(err)
+	}
+	return campaigns, nil
+}
+
+// Links returns one page of the campaign's links, newest first
+func (s *CampaignService) Links(ctx context.Context, ownerID, id string, page repository.PageRequest) (*repository.Page[models.ShortURL], error) {
+	campaign, err := s.Get(ctx, ownerID, id)
+	if err != nil {
+		return nil, err
+	}
+	links, err := s.links.ListByCampaign(ctx, campaign.ID.Hex(), page)
+	if err != nil {
+		return nil, campaignError(err)
+	}
+	return links, nil
+}
+
+// Stats sums the click analytics of every link in the campaign
+func (s *CampaignService) Stats(ctx context.Context, ownerID, id string, query StatsQuery) (*models.CampaignStats, error) {
+	campaign, err := s.Get(ctx, ownerID, id)
+	if err != nil {
+		return nil, err
+	}
+	links, err := s.links.AllByCampaign(ctx, campaign.ID.Hex(), int64(s.maxLinks))
+	if err != nil {
+		return nil, campaignError(err)
+	}
+	stats, err := s.analytics.CombinedStats(ctx, links, query)
+	if err != nil {
+		return nil, err
+	}
+	stats.Campaign = campaign
+	return stats, nil
+}
+
+// campaignError maps repository errors for campaigns and their links
+func campaignError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrCampaignNotFound
+	case errors.Is(err, repository.ErrUnavailable):
+		return ErrServiceUnavailable
+	default:
+		return fmt.Errorf("failed to load campaign: %w", err)
+	}
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrChatDisabled means the platform's signing secret or key isn't configured
+	ErrChatDisabled = newError(http.StatusServiceUnavailable, utils.ErrCodeChatDisabled, "chat integration is not configured", "Chat integration is not enabled for this platform")
+	// ErrChatSignature means a request's signature is missing, wrong or too old
+	ErrChatSignature = newError(http.StatusUnauthorized, utils.ErrCodeInvalidSignature, "invalid request signature", "Invalid request signature")
+	ErrChatPlatform  = newFieldError("platform", validators.CodeInvalid, "unknown chat platform", "platform must be slack or discord")
+	// ErrChatCode means the connect code is unknown, used or expired
+	ErrChatCode = errors.New("invalid connect code")
+	// ErrChatWorkspaceTaken means the workspace is already connected to an account
+	ErrChatWorkspaceTaken        = errors.New("workspace is already connected")
+	ErrChatWorkspaceNotConnected = errors.New("workspace is not connected")
+	ErrChatWorkspaceNotFound     = newError(http.StatusNotFound, utils.ErrCodeWorkspaceNotFound, "chat workspace not found", "Chat workspace not found")
+)
+
+// chatSignatureMaxAge bounds how old a signed request may be, so a captured
+// one can't be replayed later
+const chatSignatureMaxAge = 5 * time.Minute
+
+// connectCodeAlphabet leaves out letters and digits easily mistaken for
+// each other, since codes are typed into a chat box
+const connectCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// ChatService connects Slack workspaces and Discord servers to accounts and
+// verifies the slash command requests the platforms send. An account asks
+// for a connect code, and running the connect command with it in a
+// workspace proves both sides: the caller holds the account's key and can
+// use the workspace
+type ChatService struct {
+	repo        *repository.ChatRepository
+	slackSecret []byte
+	discordKey  ed25519.PublicKey
+	codeTTL     time.Duration
+}
+
+// NewChatService takes Slack's signing secret and Discord's hex encoded
+// application public key; either platform is disabled while its is empty
+func NewChatService(repo *repository.ChatRepository, slackSigningSecret, discordPublicKey string, codeTTL time.Duration) *ChatService {
+	s := &ChatService{
+		repo:        repo,
+		slackSecret: []byte(slackSigningSecret),
+		codeTTL:     codeTTL,
+	}
+	if key, err := hex.DecodeString(discordPublicKey); err == nil && len(key) == ed25519.PublicKeySize {
+		s.discordKey = key
+	}
+	return s
+}
+
+// Enabled reports whether requests from platform can be verified
+func (s *ChatService) Enabled(platform string) bool {
+	switch platform {
+	case models.ChatSlack:
+		return len(s.slackSecret) > 0
+	case models.ChatDiscord:
+		return s.discordKey != nil
+	default:
+		return false
+	}
+}
+
+// VerifySlack checks a request's X-Slack-Signature, an HMAC-SHA256 of the
+// timestamp and body under the signing secret
+func (s *ChatService) VerifySlack(timestamp, signature string, body []byte) error {
+	if !s.Enabled(models.ChatSlack) {
+		return ErrChatDisabled
+	}
+	if err := checkSignedAt(timestamp); err != nil {
+		return err
+	}
+	mac := hmac.New(sha256.New, s.slackSecret)
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrChatSignature
+	}
+	return nil
+}
+
+// VerifyDiscord checks a request's X-Signature-Ed25519, the application
+// key's signature of the timestamp and body
+func (s *ChatService) VerifyDiscord(timestamp, signature string, body []byte) error {
+	if !s.Enabled(models.ChatDiscord) {
+		return ErrChatDisabled
+	}
+	if err := checkSignedAt(timestamp); err != nil {
+		return err
+	}
+	sig, err := hex.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return ErrChatSignature
+	}
+	if !ed25519.Verify(s.discordKey, append([]byte(timestamp), body...), sig) {
+		return ErrChatSignature
+	}
+	return nil
+}
+
+// checkSignedAt rejects requests whose Unix timestamp is too far from now
+func checkSignedAt(timestamp string) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrChatSignature
+	}
+	age := time.Since(time.Unix(seconds, 0))
+	if age > chatSignatureMaxAge || age < -chatSignatureMaxAge {
+		return ErrChatSignature
+	}
+	return nil
+}
+
+// NewConnectCode issues a code connecting the workspace it is used in to
+// accountID, replacing the account's unused code for platform
+func (s *ChatService) NewConnectCode(ctx context.Context, accountID, platform string) (string, time.Time, error) {
+	if platform != models.ChatSlack && platform != models.ChatDiscord {
+		return "", time.Time{}, ErrChatPlatform
+	}
+	if !s.Enabled(platform) {
+		return "", time.Time{}, ErrChatDisabled
+	}
+	code, err := newConnectCode()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	now := time.Now()
+	connectBy := now.Add(s.codeTTL)
+	workspace := &models.ChatWorkspace{
+		Platform:    platform,
+		AccountID:   accountID,
+		ConnectCode: hashConnectCode(code),
+		ConnectBy:   &connectBy,
+		CreatedAt:   now,
+	}
+	if err := s.repo.CreatePending(ctx, workspace); err != nil {
+		return "", time.Time{}, chatError(err)
+	}
+	return code, connectBy, nil
+}
+
+// Connect uses code to connect workspaceID on platform; userID ran the command
+func (s *ChatService) Connect(ctx context.Context, platform, workspaceID, userID, code string) (*models.ChatWorkspace, error) {
+	workspace, err := s.repo.Connect(ctx, platform, hashConnectCode(code), workspaceID, userID, time.Now())
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return nil, ErrChatCode
+	case errors.Is(err, repository.ErrDuplicate):
+		return nil, ErrChatWorkspaceTaken
+	case err != nil:
+		return nil, chatError(err)
+	}
+	return workspace, nil
+}
+
+// AccountFor returns the account workspaceID's commands create links for
+func (s *ChatService) AccountFor(ctx context.Context, platform, workspaceID string) (string, error) {
+	workspace, err := s.repo.GetConnected(ctx, platform, workspaceID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return "", ErrChatWorkspaceNotConnected
+		}
+		return "", chatError(err)
+	}
+	return workspace.AccountID, nil
+}
+
+// Workspaces lists the workspaces connected to accountID
+func (s *ChatService) Workspaces(ctx context.Context, accountID string) ([]models.ChatWorkspace, error) {
+	workspaces, err := s.repo.ListConnected(ctx, accountID)
+	if err != nil {
+		return nil, chatError(err)
+	}
+	return workspaces, nil
+}
+
+// Disconnect removes one of accountID's workspaces; its commands stop working
+func (s *ChatService) Disconnect(ctx context.Context, accountID string, id primitive.ObjectID) error {
+	if err := s.repo.Delete(ctx, accountID, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrChatWorkspaceNotFound
+		}
+		return chatError(err)
+	}
+	return nil
+}
+
+// newConnectCode returns 8 random characters from connectCodeAlphabet
+func newConnectCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate connect code: %w", err)
+	}
+	for i := range b {
+		b[i] = connectCodeAlphabet[int(b[i])%len(connectCodeAlphabet)]
+	}
+	return string(b), nil
+}
+
+// hashConnectCode is what's stored of a code; typed codes are matched
+// regardless of case
+func hashConnectCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToUpper(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
+
+func chatError(err error) error {
+	if errors.Is(err, repository.ErrUnavailable) {
+		return ErrServiceUnavailable
+	}
+	return fmt.Errorf("failed to update chat workspace: %w", err)
+}
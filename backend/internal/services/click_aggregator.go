@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+)
+
+// ClickAggregator buffers click count increments in memory and writes them to
+// MongoDB as one batched $inc per link, every interval or once maxPending
+// clicks have accumulated, instead of one write per redirect. With shared
+// counters configured, batches go to Redis instead and reach MongoDB through
+// their periodic sync
+type ClickAggregator struct {
+	repo       *repository.MongoRepository
+	counters   *ClickCounters
+	interval   time.Duration
+	maxPending int
+
+	mu      sync.Mutex
+	pending map[repository.LinkKey]repository.ClickCounts
+	total   int
+
+	flushNow chan struct{}
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+func NewClickAggregator(repo *repository.MongoRepository, counters *ClickCounters, interval time.Duration, maxPending int) *ClickAggregator {
+	return &ClickAggregator{
+		repo:       repo,
+		counters:   counters,
+		interval:   interval,
+		maxPending: maxPending,
+		pending:    make(map[repository.LinkKey]repository.ClickCounts),
+		flushNow:   make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+}
+
+// Add counts one human or bot click for shortCode on domain
+func (a *ClickAggregator) Add(domain, shortCode string, bot bool) {
+	a.mu.Lock()
+	key := repository.LinkKey{Domain: domain, ShortCode: shortCode}
+	counts := a.pending[key]
+	if bot {
+		counts.Bot++
+	} else {
+		counts.Human++
+	}
+	a.pending[key] = counts
+	a.total++
+	full := a.total >= a.maxPending
+	a.mu.Unlock()
+
+	if full {
+		select {
+		case a.flushNow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Start runs the flush loop until Stop is called
+func (a *ClickAggregator) Start() {
+	go func() {
+		defer close(a.stopped)
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-a.flushNow:
+			case <-a.stop:
+				return
+			}
+			if err := a.Flush(context.Background()); err != nil {
+				log.Printf("Failed to flush click counts: %v", err)
+			}
+		}
+	}()
+}
+
+// Stop ends the flush loop and writes whatever is still buffered
+func (a *ClickAggregator) Stop(ctx context.Context) error {
+	close(a.stop)
+	<-a.stopped
+	return a.Flush(ctx)
+}
+
+// Flush writes the buffered increments; on failure they are put back so the
+// next flush retries them
+func (a *ClickAggregator) Flush(ctx context.Context) error {
+	a.mu.Lock()
+	batch := a.pending
+	a.pending = make(map[repository.LinkKey]repository.ClickCounts)
+	a.total = 0
+	a.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+	if err := a.write(ctx, batch); err != nil {
+		a.mu.Lock()
+		for key, n := range batch {
+			counts := a.pending[key]
+			counts.Human += n.Human
+			counts.Bot += n.Bot
+			a.pending[key] = counts
+			a.total += int(n.Human + n.Bot)
+		}
+		a.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// write hands batch to the shared counters, or straight to MongoDB when
+// there are none or Redis is unavailable
+func (a *ClickAggregator) write(ctx context.Context, batch map[repository.LinkKey]repository.ClickCounts) error {
+	if a.counters != nil {
+		err := a.counters.Add(ctx, batch)
+		if err == nil {
+			return nil
+		}
+		log.Printf("Failed to add clicks to shared counters, writing them to MongoDB: %v", err)
+	}
+	return a.repo.IncrementClickCounts(ctx, batch)
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// maxArchiveFileEvents caps how many events are buffered into one file
+	maxArchiveFileEvents = 10000
+	archiveDayLayout     = "2006-01-02"
+)
+
+// ClickArchiver moves click events older than the hot window out of MongoDB
+// into gzipped NDJSON files in object storage, one or more files per link and
+// day under clicks/[<domain>/]<code>/<day>-<nanos>.ndjson.gz, and reads them
+// back for long-range stats queries
+type ClickArchiver struct {
+	clickRepo *repository.ClickRepository
+	store     *storage.ObjectStore
+	hotWindow time.Duration
+	interval  time.Duration
+	stop      chan struct{}
+}
+
+func NewClickArchiver(clickRepo *repository.ClickRepository, store *storage.ObjectStore, hotWindow, interval time.Duration) *ClickArchiver {
+	return &ClickArchiver{
+		clickRepo: clickRepo,
+		store:     store,
+		hotWindow: hotWindow,
+		interval:  interval,
+		stop:      make(chan struct{}),
+	}
+}
+
+// HotStart is the oldest instant still guaranteed to be in MongoDB
+// Events are archived in whole UTC days
+func (a *ClickArchiver) HotStart() time.Time {
+	return time.Now().UTC().Add(-a.hotWindow).Truncate(24 * time.Hour)
+}
+
+// Start archives every interval until Stop is called
+func (a *ClickArchiver) Start() {
+	go func() {
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+		for {
+			if err := a.Archive(context.Background()); err != nil {
+				log.Printf("Failed to archive click events: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-a.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the archiving worker
+func (a *ClickArchiver) Stop() {
+	close(a.stop)
+}
+
+// Archive uploads every event older than HotStart and then deletes it from MongoDB
+// Events are only deleted after their file is stored, so a failed run loses nothing
+func (a *ClickArchiver) Archive(ctx context.Context) error {
+	var (
+		batch    []models.ClickEvent
+		batchKey string
+	)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := a.writeFile(ctx, batch); err != nil {
+			return err
+		}
+		ids := make([]primitive.ObjectID, len(batch))
+		for i, event := range batch {
+			ids[i] = event.ID
+		}
+		if err := a.clickRepo.DeleteByIDs(ctx, ids); err != nil {
+			return fmt.Errorf("failed to delete archived events: %w", err)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	err := a.clickRepo.ForEachBefore(ctx, a.HotStart(), func(event *models.ClickEvent) error {
+		key := archivePrefix(event.Domain, event.ShortCode) + event.ClickedAt.UTC().Format(archiveDayLayout)
+		if key != batchKey || len(batch) >= maxArchiveFileEvents {
+			if err := flush(); err != nil {
+				return err
+			}
+			batchKey = key
+		}
+		batch = append(batch, *event)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+// ColdEvents returns the archived events of shortCode on domain in [from, to)
+func (a *ClickArchiver) ColdEvents(ctx context.Context, domain, shortCode string, from, to time.Time) ([]models.ClickEvent, error) {
+	prefix := archivePrefix(domain, shortCode)
+	keys, err := a.store.List(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archived events: %w", err)
+	}
+	firstDay := from.UTC().Truncate(24 * time.Hour)
+	var events []models.ClickEvent
+	for _, key := range keys {
+		name := strings.TrimPrefix(key, prefix)
+		if len(name) < len(archiveDayLayout) {
+			continue
+		}
+		day, err := time.Parse(archiveDayLayout, name[:len(archiveDayLayout)])
+		if err != nil || day.Before(firstDay) || !day.Before(to) {
+			continue
+		}
+		fileEvents, err := a.readFile(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range fileEvents {
+			if !event.ClickedAt.Before(from) && event.ClickedAt.Before(to) {
+				events = append(events, event)
+			}
+		}
+	}
+	return events, nil
+}
+
+func (a *ClickArchiver) writeFile(ctx context.Context, events []models.ClickEvent) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(gz)
+	for i := range events {
+		if err := encoder.Encode(&events[i]); err != nil {
+			return fmt.Errorf("failed to encode click event: %w", err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress click events: %w", err)
+	}
+	first := events[0]
+	key := fmt.Sprintf("%s%s-%d.ndjson.gz", archivePrefix(first.Domain, first.ShortCode), first.ClickedAt.UTC().Format(archiveDayLayout), time.Now().UnixNano())
+	if err := a.store.Put(ctx, key, buf.Bytes(), "application/gzip"); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	return nil
+}
+
+func (a *ClickArchiver) readFile(ctx context.Context, key string) ([]models.ClickEvent, error) {
+	raw, err := a.store.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %w", key, err)
+	}
+	defer gz.Close()
+	var events []models.ClickEvent
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event models.ClickEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", key, err)
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
+
+// archivePrefix keeps default-domain links at clicks/<code>/; host names always
+// contain a dot and codes never do, so the two layouts can't collide
+func archivePrefix(domain, shortCode string) string {
+	if domain == "" {
+		return "clicks/" + shortCode + "/"
+	}
+	return "clicks/" + domain + "/" + shortCode + "/"
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/breaker"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis keys of the shared click counters. The hash being synced is
+// renamed from clickCountsKey, so it is hash tagged into the same cluster slot
+const (
+	clickCountsKey     = "click_counts"
+	clickCountsSyncKey = "{click_counts}:syncing"
+	clickCountsLockKey = "click_counts:sync_lock"
+)
+
+// ClickCounters moves click counting off the link documents. Replicas add
+// their buffered clicks to one Redis hash, which absorbs any write rate, and
+// every interval a single replica folds the totals into MongoDB with one $inc
+// per link. A viral link's document is then written once per interval no
+// matter how many replicas serve it or how often they flush
+type ClickCounters struct {
+	redisClient redis.UniversalClient
+	repo        *repository.MongoRepository
+	breaker     *breaker.Breaker
+	interval    time.Duration
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+func NewClickCounters(redisClient redis.UniversalClient, repo *repository.MongoRepository, redisBreaker *breaker.Breaker, interval time.Duration) *ClickCounters {
+	return &ClickCounters{
+		redisClient: redisClient,
+		repo:        repo,
+		breaker:     redisBreaker,
+		interval:    interval,
+		stop:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+}
+
+// Add adds a batch of click increments to the shared counters
+func (c *ClickCounters) Add(ctx context.Context, batch map[repository.LinkKey]repository.ClickCounts) error {
+	return c.breaker.Do(func() error {
+		pipe := c.redisClient.Pipeline()
+		for key, counts := range batch {
+			if counts.Human > 0 {
+				pipe.HIncrBy(ctx, clickCountsKey, clickCounterField("human", key), counts.Human)
+			}
+			if counts.Bot > 0 {
+				pipe.HIncrBy(ctx, clickCountsKey, clickCounterField("bot", key), counts.Bot)
+			}
+		}
+		_, err := pipe.Exec(ctx)
+		return err
+	})
+}
+
+// Start runs the sync loop until Stop is called
+func (c *ClickCounters) Start() {
+	go func() {
+		defer close(c.stopped)
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-c.stop:
+				return
+			}
+			if err := c.Sync(context.Background()); err != nil {
+				log.Printf("Failed to sync click counts: %v", err)
+			}
+		}
+	}()
+}
+
+// Stop ends the sync loop. Counts left in Redis are synced by the next
+// replica to run the loop
+func (c *ClickCounters) Stop() {
+	close(c.stop)
+	<-c.stopped
+}
+
+// Sync writes the counted clicks to MongoDB if no other replica has done so
+// this interval. The counters are renamed away first, so clicks arriving
+// meanwhile start a fresh hash; a sync that failed halfway is finished by
+// the next one. Syncing is at least once: a replica dying between the write
+// and the cleanup makes the next sync count those clicks again
+func (c *ClickCounters) Sync(ctx context.Context) error {
+	acquired, err := c.redisClient.SetNX(ctx, clickCountsLockKey, 1, c.interval).Result()
+	if err != nil || !acquired {
+		return err
+	}
+	interrupted, err := c.redisClient.Exists(ctx, clickCountsSyncKey).Result()
+	if err != nil {
+		return err
+	}
+	if interrupted == 0 {
+		pending, err := c.redisClient.Exists(ctx, clickCountsKey).Result()
+		if err != nil || pending == 0 {
+			return err
+		}
+		if err := c.redisClient.Rename(ctx, clickCountsKey, clickCountsSyncKey).Err(); err != nil {
+			return err
+		}
+	}
+
+	fields, err := c.redisClient.HGetAll(ctx, clickCountsSyncKey).Result()
+	if err != nil {
+		return err
+	}
+	batch := make(map[repository.LinkKey]repository.ClickCounts, len(fields))
+	for field, raw := range fields {
+		kind, key, ok := parseClickCounterField(field)
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if !ok || err != nil {
+			continue
+		}
+		counts := batch[key]
+		if kind == "bot" {
+			counts.Bot += n
+		} else {
+			counts.Human += n
+		}
+		batch[key] = counts
+	}
+	if len(batch) > 0 {
+		if err := c.repo.IncrementClickCounts(ctx, batch); err != nil {
+			return fmt.Errorf("failed to write click counts: %w", err)
+		}
+	}
+	return c.redisClient.Del(ctx, clickCountsSyncKey).Err()
+}
+
+// clickCounterField names a link's human or bot counter. Neither host names
+// nor short codes contain '/'
+func clickCounterField(kind string, key repository.LinkKey) string {
+	return kind + ":" + key.Domain + "/" + key.ShortCode
+}
+
+func parseClickCounterField(field string) (string, repository.LinkKey, bool) {
+	kind, link, ok := strings.Cut(field, ":")
+	if !ok {
+		return "", repository.LinkKey{}, false
+	}
+	domain, shortCode, ok := strings.Cut(link, "/")
+	if !ok {
+		return "", repository.LinkKey{}, false
+	}
+	return kind, repository.LinkKey{Domain: domain, ShortCode: shortCode}, true
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// clickFeedWatchedKey is a sorted set of the watched links' channels,
+	// scored by when their watch lapses
+	clickFeedWatchedKey = "clickfeed:watched"
+	// clickFeedWatchTTL is how long a watch lasts without being renewed;
+	// watchers renew theirs three times as often
+	clickFeedWatchTTL = 30 * time.Second
+	// clickFeedRefresh is how often replicas reread the watched links, so
+	// how long after a dashboard starts watching its first clicks may take
+	clickFeedRefresh = 5 * time.Second
+	// clickFeedBuffer is how many clicks a slow watcher may fall behind by
+	// before more are dropped
+	clickFeedBuffer = 64
+)
+
+// ClickFeed carries clicks on a link to its owner's open dashboards through
+// Redis pub/sub, whichever replica served the redirect. Dashboards mark the
+// links they watch in Redis and replicas only publish clicks on marked links,
+// so redirects of unwatched links cost nothing extra
+type ClickFeed struct {
+	redisClient redis.UniversalClient
+
+	mu      sync.RWMutex
+	watched map[string]struct{}
+
+	// closing ends every watch, so a shutdown isn't held up by open streams
+	closing   chan struct{}
+	closeOnce sync.Once
+	stop      chan struct{}
+	stopped   chan struct{}
+}
+
+func NewClickFeed(redisClient redis.UniversalClient) *ClickFeed {
+	return &ClickFeed{
+		redisClient: redisClient,
+		watched:     make(map[string]struct{}),
+		closing:     make(chan struct{}),
+		stop:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+}
+
+// clickFeedChannel is the pub/sub channel of a link's clicks
+func clickFeedChannel(domain, shortCode string) string {
+	return "clickfeed:" + linkCacheKey(domain, shortCode)
+}
+
+// Start rereads the watched links now and then every clickFeedRefresh
+func (f *ClickFeed) Start() {
+	go func() {
+		defer close(f.stopped)
+		ticker := time.NewTicker(clickFeedRefresh)
+		defer ticker.Stop()
+		for {
+			if err := f.refresh(context.Background()); err != nil {
+				log.Printf("Failed to read watched links: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-f.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close ends every open watch
+func (f *ClickFeed) Close() {
+	f.closeOnce.Do(func() { close(f.closing) })
+}
+
+// Stop ends the refresh loop and every open watch
+func (f *ClickFeed) Stop() {
+	f.Close()
+	close(f.stop)
+	<-f.stopped
+}
+
+func (f *ClickFeed) refresh(ctx context.Context) error {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	if err := f.redisClient.ZRemRangeByScore(ctx, clickFeedWatchedKey, "-inf", now).Err(); err != nil {
+		return err
+	}
+	channels, err := f.redisClient.ZRange(ctx, clickFeedWatchedKey, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	watched := make(map[string]struct{}, len(channels))
+	for _, channel := range channels {
+		watched[channel] = struct{}{}
+	}
+	f.mu.Lock()
+	f.watched = watched
+	f.mu.Unlock()
+	return nil
+}
+
+// Publish sends a click to the dashboards watching its link, if any; nil
+// feeds publish nothing
+func (f *ClickFeed) Publish(ctx context.Context, click *models.ClickEvent) error {
+	if f == nil {
+		return nil
+	}
+	channel := clickFeedChannel(click.Domain, click.ShortCode)
+	f.mu.RLock()
+	_, watched := f.watched[channel]
+	f.mu.RUnlock()
+	if !watched {
+		return nil
+	}
+	raw, err := json.Marshal(click)
+	if err != nil {
+		return err
+	}
+	if err := f.redisClient.Publish(ctx, channel, raw).Err(); err != nil {
+		return fmt.Errorf("failed to publish click: %w", err)
+	}
+	return nil
+}
+
+// Watch returns the clicks on a link as they happen, until ctx ends or the
+// feed closes, when the channel is closed. Clicks a slow reader can't keep
+// up with are dropped
+func (f *ClickFeed) Watch(ctx context.Context, domain, shortCode string) (<-chan models.ClickEvent, error) {
+	channel := clickFeedChannel(domain, shortCode)
+	pubsub := f.redisClient.Subscribe(ctx, channel)
+	// Wait for the confirmation, so a Redis outage fails the request
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to clicks: %w", err)
+	}
+	if err := f.mark(ctx, channel); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	clicks := make(chan models.ClickEvent, clickFeedBuffer)
+	go func() {
+		defer close(clicks)
+		defer pubsub.Close()
+		renew := time.NewTicker(clickFeedWatchTTL / 3)
+		defer renew.Stop()
+		messages := pubsub.Channel()
+		for {
+			select {
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				var click models.ClickEvent
+				if err := json.Unmarshal([]byte(msg.Payload), &click); err != nil {
+					log.Printf("Failed to decode click: %v", err)
+					continue
+				}
+				select {
+				case clicks <- click:
+				default:
+				}
+			case <-renew.C:
+				if err := f.mark(ctx, channel); err != nil {
+					log.Printf("Failed to renew click watch: %v", err)
+				}
+			case <-ctx.Done():
+				return
+			case <-f.closing:
+				return
+			}
+		}
+	}()
+	return clicks, nil
+}
+
+// mark tells replicas to publish the clicks on channel's link for another
+// clickFeedWatchTTL
+func (f *ClickFeed) mark(ctx context.Context, channel string) error {
+	lapses := float64(time.Now().Add(clickFeedWatchTTL).Unix())
+	// GT keeps the latest lapse when several dashboards watch the link
+	err := f.redisClient.ZAddGT(ctx, clickFeedWatchedKey, redis.Z{Score: lapses, Member: channel}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to mark watched link: %w", err)
+	}
+	return nil
+}
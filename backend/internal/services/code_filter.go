@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/breaker"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis keys of the short code Bloom filter. The keys the scripts and
+// transactions use together are hash tagged into the filter's cluster slot
+const (
+	codeFilterKey           = "codes:bloom"
+	codeFilterNextKey       = "{codes:bloom}:next"
+	codeFilterReadyKey      = "{codes:bloom}:ready"
+	codeFilterRebuildingKey = "{codes:bloom}:rebuilding"
+	codeFilterLockKey       = "codes:bloom:rebuild_lock"
+)
+
+const (
+	// Redis bitmaps hold at most 2^32 bits
+	maxCodeFilterBits = 1 << 32
+	// codeFilterRetryInterval is how often codes that couldn't be added are retried
+	codeFilterRetryInterval = 10 * time.Second
+	codeFilterBatchSize     = 1000
+)
+
+// CodeFilter is a Bloom filter of every stored short code, kept in a Redis
+// bitmap shared by all replicas. The redirect path asks it before going to
+// MongoDB: a code it has never seen is certainly not a link, so scanners
+// guessing codes are answered without a database lookup. Codes of deleted
+// links can't be taken out of a Bloom filter; they stay until the periodic
+// rebuild, costing at most a lookup each.
+// The filter fails open: until a rebuild has filled it, while Redis is
+// unreachable or when replicas disagree on its size, every code may exist
+type CodeFilter struct {
+	redisClient     redis.UniversalClient
+	repo            *repository.MongoRepository
+	breaker         *breaker.Breaker
+	bits            uint64
+	hashes          int
+	rebuildInterval time.Duration
+
+	mu      sync.Mutex
+	pending []repository.LinkKey
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// NewCodeFilter sizes the filter for capacity codes at falsePositiveRate
+func NewCodeFilter(redisClient redis.UniversalClient, repo *repository.MongoRepository, redisBreaker *breaker.Breaker, capacity int64, falsePositiveRate float64, rebuildInterval time.Duration) *CodeFilter {
+	bits := math.Ceil(-float64(capacity) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	bits = math.Min(math.Max(bits, 64), maxCodeFilterBits)
+	hashes := int(math.Round(bits / float64(capacity) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+	return &CodeFilter{
+		redisClient:     redisClient,
+		repo:            repo,
+		breaker:         redisBreaker,
+		bits:            uint64(bits),
+		hashes:          hashes,
+		rebuildInterval: rebuildInterval,
+		stop:            make(chan struct{}),
+		stopped:         make(chan struct{}),
+	}
+}
+
+// containsScript returns 0 if any of the code's bits is unset, and 1 if all
+// are set or the filter wasn't built with these parameters
+var containsScript = redis.NewScript(`
+if redis.call('GET', KEYS[2]) ~= ARGV[1] then
+	return 1
+end
+for i = 2, #ARGV do
+	if redis.call('GETBIT', KEYS[1], ARGV[i]) == 0 then
+		return 0
+	end
+end
+return 1
+`)
+
+// addScript sets a code's bits, also in the filter being rebuilt if any,
+// so codes created during a rebuild aren't missing from its result
+var addScript = redis.NewScript(`
+local rebuilding = redis.call('EXISTS', KEYS[3]) == 1
+for i = 1, #ARGV do
+	redis.call('SETBIT', KEYS[1], ARGV[i], 1)
+	if rebuilding then
+		redis.call('SETBIT', KEYS[2], ARGV[i], 1)
+	end
+end
+return 1
+`)
+
+// MayExist reports whether domain/shortCode may be a stored link. False is
+// certain; errors come with true, so callers fall back to the database.
+// A nil filter is disabled and always returns true
+func (f *CodeFilter) MayExist(ctx context.Context, domain, shortCode string) (bool, error) {
+	if f == nil {
+		return true, nil
+	}
+	args := []interface{}{f.params()}
+	for _, offset := range f.offsets(domain, shortCode) {
+		args = append(args, offset)
+	}
+	var found int64
+	err := f.breaker.Do(func() error {
+		var err error
+		found, err = containsScript.Run(ctx, f.redisClient, []string{codeFilterKey, codeFilterReadyKey}, args...).Int64()
+		return err
+	})
+	if err != nil {
+		return true, err
+	}
+	return found == 1, nil
+}
+
+// Add records a newly stored link. Codes that can't be written are kept
+// and retried, since a missing code would make its link unreachable
+func (f *CodeFilter) Add(ctx context.Context, domain, shortCode string) {
+	if f == nil {
+		return
+	}
+	key := repository.LinkKey{Domain: domain, ShortCode: shortCode}
+	if err := f.add(ctx, key); err != nil {
+		log.Printf("Failed to add %s to code filter, will retry: %v", shortCode, err)
+		f.mu.Lock()
+		f.pending = append(f.pending, key)
+		f.mu.Unlock()
+	}
+}
+
+func (f *CodeFilter) add(ctx context.Context, key repository.LinkKey) error {
+	var args []interface{}
+	for _, offset := range f.offsets(key.Domain, key.ShortCode) {
+		args = append(args, offset)
+	}
+	return f.breaker.Do(func() error {
+		return addScript.Run(ctx, f.redisClient, []string{codeFilterKey, codeFilterNextKey, codeFilterRebuildingKey}, args...).Err()
+	})
+}
+
+// retry adds the codes whose earlier Add failed, keeping those that fail again
+func (f *CodeFilter) retry(ctx context.Context) {
+	f.mu.Lock()
+	pending := f.pending
+	f.pending = nil
+	f.mu.Unlock()
+
+	var failed []repository.LinkKey
+	for i, key := range pending {
+		if err := f.add(ctx, key); err != nil {
+			failed = pending[i:]
+			break
+		}
+	}
+	if len(failed) > 0 {
+		f.mu.Lock()
+		f.pending = append(failed, f.pending...)
+		f.mu.Unlock()
+	}
+}
+
+// Start rebuilds the filter now if it isn't ready, then every rebuild
+// interval, until Stop is called
+func (f *CodeFilter) Start() {
+	go func() {
+		defer close(f.stopped)
+		ctx := context.Background()
+		if ready, err := f.redisClient.Get(ctx, codeFilterReadyKey).Result(); err != nil || ready != f.params() {
+			if err := f.Rebuild(ctx); err != nil {
+				log.Printf("Failed to rebuild code filter: %v", err)
+			}
+		}
+		rebuild := time.NewTicker(f.rebuildInterval)
+		defer rebuild.Stop()
+		retry := time.NewTicker(codeFilterRetryInterval)
+		defer retry.Stop()
+		for {
+			select {
+			case <-retry.C:
+				f.retry(ctx)
+			case <-rebuild.C:
+				if err := f.Rebuild(ctx); err != nil {
+					log.Printf("Failed to rebuild code filter: %v", err)
+				}
+			case <-f.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the rebuild loop
+func (f *CodeFilter) Stop() {
+	close(f.stop)
+	<-f.stopped
+}
+
+// Rebuild fills a fresh filter from every link in MongoDB, trashed and
+// archived ones included, and swaps it in, dropping codes of purged links.
+// Only one replica rebuilds per interval
+func (f *CodeFilter) Rebuild(ctx context.Context) error {
+	acquired, err := f.redisClient.SetNX(ctx, codeFilterLockKey, 1, f.rebuildInterval).Result()
+	if err != nil || !acquired {
+		return err
+	}
+	pipe := f.redisClient.TxPipeline()
+	pipe.Set(ctx, codeFilterRebuildingKey, 1, f.rebuildInterval)
+	pipe.Del(ctx, codeFilterNextKey)
+	// Allocates the whole bitmap up front, so an empty filter exists too
+	pipe.SetBit(ctx, codeFilterNextKey, int64(f.bits-1), 0)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	batch := f.redisClient.Pipeline()
+	queued := 0
+	err = f.repo.ForEachCode(ctx, func(key repository.LinkKey) error {
+		for _, offset := range f.offsets(key.Domain, key.ShortCode) {
+			batch.SetBit(ctx, codeFilterNextKey, int64(offset), 1)
+		}
+		if queued++; queued < codeFilterBatchSize {
+			return nil
+		}
+		queued = 0
+		_, err := batch.Exec(ctx)
+		return err
+	})
+	if err == nil && queued > 0 {
+		_, err = batch.Exec(ctx)
+	}
+	if err != nil {
+		f.redisClient.Del(ctx, codeFilterRebuildingKey, codeFilterNextKey)
+		return err
+	}
+
+	pipe = f.redisClient.TxPipeline()
+	pipe.Rename(ctx, codeFilterNextKey, codeFilterKey)
+	pipe.Set(ctx, codeFilterReadyKey, f.params(), 0)
+	pipe.Del(ctx, codeFilterRebuildingKey)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// params identifies the filter's size, so replicas configured differently
+// don't read each other's bits
+func (f *CodeFilter) params() string {
+	return fmt.Sprintf("%d:%d", f.bits, f.hashes)
+}
+
+// offsets are the bits of domain/shortCode, by double hashing
+func (f *CodeFilter) offsets(domain, shortCode string) []uint64 {
+	h := fnv.New128a()
+	h.Write([]byte(domain + "/" + shortCode))
+	sum := h.Sum(nil)
+	h1 := binary.BigEndian.Uint64(sum[:8])
+	h2 := binary.BigEndian.Uint64(sum[8:])
+	offsets := make([]uint64, f.hashes)
+	for i := range offsets {
+		offsets[i] = (h1 + uint64(i)*h2) % f.bits
+	}
+	return offsets
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+)
+
+var (
+	// ErrDestinationBlocked and ErrDestinationNotAllowlisted wrap the rule a
+	// destination host failed
+	ErrDestinationBlocked        = newFieldError("url", validators.CodeDomainBlocked, "destination domain is blocked", "")
+	ErrDestinationNotAllowlisted = newFieldError("url", validators.CodeDomainNotAllowed, "destination domain is not on the allowlist", "")
+	ErrInvalidHostPattern        = newFieldError("pattern", validators.CodeInvalidHost, "invalid host pattern", "pattern must be a host name or IP address, optionally with '*' wildcards")
+	ErrInvalidRuleList           = newFieldError("list", validators.CodeInvalid, "invalid rule list", "list must be "+models.DestinationBlock+" or "+models.DestinationAllow)
+	ErrDestinationRuleConfigured = newError(http.StatusConflict, utils.ErrCodeDestinationRuleConfigured, "destination rule is configured", "Rule is set in the server configuration and can't be changed here")
+	ErrDestinationRuleNotFound   = newError(http.StatusNotFound, utils.ErrCodeDestinationRuleNotFound, "destination rule not found", "Destination rule not found")
+)
+
+// InvalidateDestinationRules tells replicas to reload the destination rules
+const InvalidateDestinationRules = "destination_rules.changed"
+
+// DestinationRules decides which destination hosts may be shortened, from
+// the rules in the configuration plus those admins manage at runtime. The
+// managed rules are kept in memory and reloaded when any replica changes them
+type DestinationRules struct {
+	repo       *repository.DestinationRuleRepository
+	bus        *InvalidationBus
+	configured []models.DestinationRule
+
+	mu sync.RWMutex
+	// managed is nil until loaded, and again once a replica changed them
+	managed []models.DestinationRule
+	// generation counts changes, so a load that raced one isn't kept
+	generation uint64
+}
+
+// NewDestinationRules builds the rules from the configured block and allow
+// patterns, which config.Validate has checked, and the ones stored in repo
+func NewDestinationRules(repo *repository.DestinationRuleRepository, bus *InvalidationBus, blocked, allowed []string) *DestinationRules {
+	r := &DestinationRules{repo: repo, bus: bus}
+	for _, list := range []struct {
+		name     string
+		patterns []string
+	}{{models.DestinationBlock, blocked}, {models.DestinationAllow, allowed}} {
+		for _, raw := range list.patterns {
+			pattern, _ := validators.NormalizeHostPattern(raw)
+			r.configured = append(r.configured, models.DestinationRule{Pattern: pattern, List: list.name, Configured: true})
+		}
+	}
+	bus.Subscribe(InvalidateDestinationRules, func(string) { r.forget() })
+	bus.OnReset(r.forget)
+	return r
+}
+
+// Check returns why host may not be shortened, or nil if it may
+func (r *DestinationRules) Check(ctx context.Context, host string) error {
+	managed, err := r.load(ctx)
+	if err != nil {
+		return err
+	}
+	rules := append(slices.Clip(r.configured), managed...)
+	allowlisted, matched := false, false
+	for _, rule := range rules {
+		if rule.List != models.DestinationAllow {
+			continue
+		}
+		allowlisted = true
+		if validators.MatchHostPattern(rule.Pattern, host) {
+			matched = true
+			break
+		}
+	}
+	for _, rule := range rules {
+		if rule.List == models.DestinationBlock && validators.MatchHostPattern(rule.Pattern, host) {
+			if rule.Reason != "" {
+				return fmt.Errorf("%w: %s matches %s (%s)", ErrDestinationBlocked, host, rule.Pattern, rule.Reason)
+			}
+			return fmt.Errorf("%w: %s matches %s", ErrDestinationBlocked, host, rule.Pattern)
+		}
+	}
+	if allowlisted && !matched {
+		return fmt.Errorf("%w: %s", ErrDestinationNotAllowlisted, host)
+	}
+	return nil
+}
+
+// List returns the configured rules followed by the managed ones
+func (r *DestinationRules) List(ctx context.Context) ([]models.DestinationRule, error) {
+	managed, err := r.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(slices.Clip(r.configured), managed...), nil
+}
+
+// Put saves a managed rule for pattern, moving an existing one to list
+func (r *DestinationRules) Put(ctx context.Context, pattern, list, reason string) (*models.DestinationRule, error) {
+	pattern, ok := validators.NormalizeHostPattern(pattern)
+	if !ok {
+		return nil, ErrInvalidHostPattern
+	}
+	if list != models.DestinationBlock && list != models.DestinationAllow {
+		return nil, ErrInvalidRuleList
+	}
+	if r.isConfigured(pattern) {
+		return nil, ErrDestinationRuleConfigured
+	}
+	rule, err := r.repo.Upsert(ctx, &models.DestinationRule{Pattern: pattern, List: list, Reason: strings.TrimSpace(reason)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save destination rule: %w", err)
+	}
+	r.changed(ctx)
+	return rule, nil
+}
+
+// Delete removes pattern's managed rule
+func (r *DestinationRules) Delete(ctx context.Context, pattern string) error {
+	pattern, _ = validators.NormalizeHostPattern(pattern)
+	if r.isConfigured(pattern) {
+		return ErrDestinationRuleConfigured
+	}
+	found, err := r.repo.Delete(ctx, pattern)
+	if err != nil {
+		return fmt.Errorf("failed to delete destination rule: %w", err)
+	}
+	if !found {
+		return ErrDestinationRuleNotFound
+	}
+	r.changed(ctx)
+	return nil
+}
+
+func (r *DestinationRules) isConfigured(pattern string) bool {
+	return slices.ContainsFunc(r.configured, func(rule models.DestinationRule) bool {
+		return rule.Pattern == pattern
+	})
+}
+
+// load returns the managed rules, reading them from MongoDB unless cached
+func (r *DestinationRules) load(ctx context.Context) ([]models.DestinationRule, error) {
+	r.mu.RLock()
+	managed, generation := r.managed, r.generation
+	r.mu.RUnlock()
+	if managed != nil {
+		return managed, nil
+	}
+	managed, err := r.repo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load destination rules: %w", err)
+	}
+	r.mu.Lock()
+	if r.generation == generation {
+		r.managed = managed
+	}
+	r.mu.Unlock()
+	return managed, nil
+}
+
+// changed makes this replica and the others reload the managed rules
+func (r *DestinationRules) changed(ctx context.Context) {
+	r.forget()
+	if err := r.bus.Publish(ctx, InvalidateDestinationRules, ""); err != nil {
+		log.Printf("Failed to publish destination rule invalidation: %v", err)
+	}
+}
+
+func (r *DestinationRules) forget() {
+	r.mu.Lock()
+	r.managed = nil
+	r.generation++
+	r.mu.Unlock()
+}
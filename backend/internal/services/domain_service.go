@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrInvalidDomain            = newFieldError("host", validators.CodeInvalidHost, "invalid domain", "Invalid host name")
+	ErrDomainTaken              = newError(http.StatusConflict, utils.ErrCodeDomainTaken, "domain already registered", "Domain is already registered")
+	ErrDomainNotFound           = newError(http.StatusNotFound, utils.ErrCodeDomainNotFound, "domain not found", "Domain not found")
+	ErrDomainNotVerified        = newError(http.StatusBadRequest, utils.ErrCodeDomainNotVerified, "domain not verified", "Domain is not verified")
+	ErrDomainVerificationFailed = newError(http.StatusUnprocessableEntity, utils.ErrCodeVerificationNotFound, "domain verification TXT record not found", "TXT record "+models.DomainVerificationPrefix+"<host> with the verification token was not found")
+	// ErrUnknownDomain means a link can't go on the domain: it is malformed or
+	// not the account's
+	ErrUnknownDomain = newError(http.StatusBadRequest, utils.ErrCodeUnknownDomain, "unknown domain", "Unknown domain")
+)
+
+const (
+	// domainCacheTTL bounds how long a Host lookup is reused by the redirect path
+	domainCacheTTL = time.Minute
+	// maxCachedDomains stops arbitrary Host headers from growing the cache forever
+	maxCachedDomains = 10000
+)
+
+type cachedDomain struct {
+	domain    *models.Domain
+	expiresAt time.Time
+}
+
+// DomainService manages the branded domains accounts serve links on and
+// verifies ownership through a DNS TXT record
+type DomainService struct {
+	repo     *repository.DomainRepository
+	resolver *net.Resolver
+
+	mu    sync.Mutex
+	cache map[string]cachedDomain
+	bus   *InvalidationBus
+}
+
+func NewDomainService(repo *repository.DomainRepository, bus *InvalidationBus) *DomainService {
+	s := &DomainService{
+		repo:     repo,
+		resolver: net.DefaultResolver,
+		cache:    make(map[string]cachedDomain),
+		bus:      bus,
+	}
+	bus.Subscribe(InvalidateDomainChanged, s.forget)
+	bus.OnReset(s.forgetAll)
+	return s
+}
+
+// AddDomain registers host for accountID, unverified
+// The owner proves control by publishing the returned token as a TXT record
+func (s *DomainService) AddDomain(ctx context.Context, accountID, host string) (*models.Domain, error) {
+	host, ok := validators.NormalizeHost(host)
+	if !ok {
+		return nil, ErrInvalidDomain
+	}
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("failed to generate verification token: %w", err)
+	}
+	domain := &models.Domain{
+		Host:              host,
+		AccountID:         accountID,
+		VerificationToken: hex.EncodeToString(token),
+	}
+	if err := s.repo.CreateDomain(ctx, domain); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, ErrDomainTaken
+		}
+		return nil, fmt.Errorf("failed to create domain: %w", err)
+	}
+	return domain, nil
+}
+
+// ProvisionDomain makes sure host is registered to accountID, adding it if
+// nobody has it yet; created reports whether it did. ErrDomainTaken means
+// another account has it
+func (s *DomainService) ProvisionDomain(ctx context.Context, accountID, host string) (*models.Domain, bool, error) {
+	host, ok := validators.NormalizeHost(host)
+	if !ok {
+		return nil, false, ErrInvalidDomain
+	}
+	domain, err := s.repo.GetDomainByHost(ctx, host)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to look up domain: %w", err)
+	}
+	if domain == nil {
+		domain, err = s.AddDomain(ctx, accountID, host)
+		if err == nil {
+			return domain, true, nil
+		}
+		if !errors.Is(err, ErrDomainTaken) {
+			return nil, false, err
+		}
+		// Somebody registered it meanwhile; it may have been this account
+		if domain, err = s.repo.GetDomainByHost(ctx, host); err != nil {
+			return nil, false, fmt.Errorf("failed to look up domain: %w", err)
+		}
+	}
+	if domain == nil || domain.AccountID != accountID {
+		return nil, false, ErrDomainTaken
+	}
+	return domain, false, nil
+}
+
+// ProvisionedDomain returns host if it is registered to accountID
+func (s *DomainService) ProvisionedDomain(ctx context.Context, accountID, host string) (*models.Domain, error) {
+	host, ok := validators.NormalizeHost(host)
+	if !ok {
+		return nil, ErrDomainNotFound
+	}
+	domain, err := s.repo.GetDomainByHost(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up domain: %w", err)
+	}
+	if domain == nil || domain.AccountID != accountID {
+		return nil, ErrDomainNotFound
+	}
+	return domain, nil
+}
+
+// VerifyDomain checks the domain's TXT record and marks it verified
+func (s *DomainService) VerifyDomain(ctx context.Context, accountID string, id primitive.ObjectID) (*models.Domain, error) {
+	domain, err := s.repo.GetDomainByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up domain: %w", err)
+	}
+	if domain == nil || domain.AccountID != accountID {
+		return nil, ErrDomainNotFound
+	}
+	if domain.Verified {
+		return domain, nil
+	}
+	records, err := s.resolver.LookupTXT(ctx, domain.VerificationRecord())
+	if err != nil {
+		return nil, ErrDomainVerificationFailed
+	}
+	for _, record := range records {
+		if strings.TrimSpace(record) == domain.VerificationToken {
+			now := time.Now()
+			if err := s.repo.MarkVerified(ctx, domain.ID, now); err != nil {
+				return nil, fmt.Errorf("failed to mark domain verified: %w", err)
+			}
+			domain.Verified = true
+			domain.VerifiedAt = &now
+			s.changed(ctx, domain.Host)
+			return domain, nil
+		}
+	}
+	return nil, ErrDomainVerificationFailed
+}
+
+// ListDomains returns the account's domains
+func (s *DomainService) ListDomains(ctx context.Context, accountID string, page repository.PageRequest) (*repository.Page[models.Domain], error) {
+	return s.repo.ListDomainsByAccount(ctx, accountID, page)
+}
+
+// AllDomains returns every verified domain, for background workers
+func (s *DomainService) AllDomains(ctx context.Context) ([]models.Domain, error) {
+	return s.repo.ListVerifiedDomains(ctx)
+}
+
+// Resolve returns the verified domain for a request's Host header, or nil
+// when the host is the default short domain or unknown
+// Lookups are cached briefly since every redirect resolves its host
+func (s *DomainService) Resolve(ctx context.Context, host string) (*models.Domain, error) {
+	host, ok := validators.NormalizeHost(host)
+	if !ok {
+		return nil, nil
+	}
+	s.mu.Lock()
+	entry, hit := s.cache[host]
+	s.mu.Unlock()
+	if hit && time.Now().Before(entry.expiresAt) {
+		return entry.domain, nil
+	}
+
+	domain, err := s.repo.GetDomainByHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if domain != nil && !domain.Verified {
+		domain = nil
+	}
+	s.mu.Lock()
+	if len(s.cache) >= maxCachedDomains {
+		s.cache = make(map[string]cachedDomain)
+	}
+	s.cache[host] = cachedDomain{domain: domain, expiresAt: time.Now().Add(domainCacheTTL)}
+	s.mu.Unlock()
+	return domain, nil
+}
+
+// CheckUsable ensures accountID may create links on host
+// An empty host is the default short domain, usable by everyone
+func (s *DomainService) CheckUsable(ctx context.Context, accountID, host string) (string, error) {
+	if host == "" {
+		return "", nil
+	}
+	host, ok := validators.NormalizeHost(host)
+	if !ok {
+		return "", ErrUnknownDomain
+	}
+	domain, err := s.repo.GetDomainByHost(ctx, host)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up domain: %w", err)
+	}
+	if domain == nil || accountID == "" || domain.AccountID != accountID {
+		return "", ErrUnknownDomain
+	}
+	if !domain.Verified {
+		return "", ErrDomainNotVerified
+	}
+	return host, nil
+}
+
+// changed drops host from this replica's lookup cache and the others'
+func (s *DomainService) changed(ctx context.Context, host string) {
+	s.forget(host)
+	if err := s.bus.Publish(ctx, InvalidateDomainChanged, host); err != nil {
+		log.Printf("Failed to publish domain invalidation: %v", err)
+	}
+}
+
+func (s *DomainService) forget(host string) {
+	s.mu.Lock()
+	delete(s.cache, host)
+	s.mu.Unlock()
+}
+
+func (s *DomainService) forgetAll() {
+	s.mu.Lock()
+	s.cache = make(map[string]cachedDomain)
+	s.mu.Unlock()
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+)
+
+// CDN providers the edge cache can purge
+const (
+	CDNFastly     = "fastly"
+	CDNCloudflare = "cloudflare"
+)
+
+// EdgeCache decides which redirects CDNs and browsers may cache and purges
+// them from the CDN when their link changes. Fastly purges by Surrogate-Key;
+// Cloudflare by URL. Browser caches can't be purged
+type EdgeCache struct {
+	defaultTTL    time.Duration
+	defaultMaxAge time.Duration
+	provider      string
+	apiToken      string
+	// serviceID is the Fastly service ID or the Cloudflare zone ID
+	serviceID  string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewEdgeCache creates the cache policy; a zero defaultTTL or defaultMaxAge
+// leaves links uncached by CDNs or browsers unless they set their own, and an
+// empty provider disables purging
+func NewEdgeCache(defaultTTL, defaultMaxAge time.Duration, provider, apiToken, serviceID, baseURL string) *EdgeCache {
+	return &EdgeCache{
+		defaultTTL:    defaultTTL,
+		defaultMaxAge: defaultMaxAge,
+		provider:      provider,
+		apiToken:      apiToken,
+		serviceID:     serviceID,
+		baseURL:       strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// TTL is how long a CDN may cache the link's redirect, or 0 if it must not
+// Links whose redirect depends on the visitor or must see every click are
+// never cached, nor are sandbox links, whose edits aren't purged. The TTL
+// never outlives the link's expiry or schedule
+func (e *EdgeCache) TTL(shortURL *models.ShortURL) time.Duration {
+	return cacheLifetime(shortURL, e.defaultTTL, shortURL.EdgeCacheTTL)
+}
+
+// MaxAge is how long browsers may cache the link's redirect, or 0 if they must not
+// It follows the same rules as TTL, but a browser keeps the redirect even
+// after the link is edited, so it should stay short
+func (e *EdgeCache) MaxAge(shortURL *models.ShortURL) time.Duration {
+	return cacheLifetime(shortURL, e.defaultMaxAge, shortURL.CacheMaxAge)
+}
+
+// cacheLifetime is the link's own lifetime in seconds, or defaultTTL when it
+// has none, limited by the link's expiry and its cacheability
+func cacheLifetime(shortURL *models.ShortURL, defaultTTL time.Duration, seconds *int64) time.Duration {
+	ttl := defaultTTL
+	if seconds != nil {
+		ttl = time.Duration(*seconds) * time.Second
+	}
+	if ttl <= 0 || shortURL.Honeytoken || shortURL.MaxClicks > 0 || shortURL.DeviceRules != nil || len(shortURL.Variants) > 0 || shortURL.Shadow != nil || shortURL.IPAccess != nil || shortURL.RateLimit != nil || shortURL.Sandbox || shortURL.SigningKey != "" {
+		return 0
+	}
+	if !shortURL.Scheduled(time.Now()) {
+		return 0
+	}
+	for _, end := range []*time.Time{shortURL.ExpiresAt, shortURL.DeactivateAt} {
+		if end == nil {
+			continue
+		}
+		if remaining := time.Until(*end); remaining < ttl {
+			ttl = remaining
+		}
+	}
+	if ttl < time.Second {
+		return 0
+	}
+	return ttl
+}
+
+// SurrogateKey tags a link's cached redirect so it can be purged on its own
+func SurrogateKey(domain, shortCode string) string {
+	if domain == "" {
+		return "link-" + shortCode
+	}
+	return "link-" + domain + "-" + shortCode
+}
+
+// Purge drops the link's redirect from the CDN in the background
+func (e *EdgeCache) Purge(domain, shortCode string) {
+	if e.provider == "" {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := e.purge(ctx, domain, shortCode); err != nil {
+			log.Printf("Failed to purge CDN cache for %s: %v", shortCode, err)
+		}
+	}()
+}
+
+func (e *EdgeCache) purge(ctx context.Context, domain, shortCode string) error {
+	var req *http.Request
+	var err error
+	switch e.provider {
+	case CDNFastly:
+		endpoint := fmt.Sprintf("https://api.fastly.com/service/%s/purge/%s", url.PathEscape(e.serviceID), url.PathEscape(SurrogateKey(domain, shortCode)))
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, endpoint, nil)
+		if err == nil {
+			req.Header.Set("Fastly-Key", e.apiToken)
+		}
+	case CDNCloudflare:
+		link := e.baseURL + "/" + shortCode
+		if domain != "" {
+			link = "https://" + domain + "/" + shortCode
+		}
+		body, _ := json.Marshal(map[string][]string{"files": {link}})
+		endpoint := fmt.Sprintf("https://api.cloudflare.com/client/v4/zones/%s/purge_cache", url.PathEscape(e.serviceID))
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+		if err == nil {
+			req.Header.Set("Authorization", "Bearer "+e.apiToken)
+			req.Header.Set("Content-Type", "application/json")
+		}
+	default:
+		return fmt.Errorf("unknown CDN provider %q", e.provider)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to build purge request: %w", err)
+	}
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call %s purge API: %w", e.provider, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s purge API returned %s", e.provider, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+)
+
+var (
+	ErrEmbedDisabled         = newError(http.StatusServiceUnavailable, utils.ErrCodeEmbedDisabled, "embed widget is not configured", "Embed widget is not enabled")
+	ErrInvalidSiteToken      = newError(http.StatusUnauthorized, utils.ErrCodeInvalidSiteToken, "invalid site token", "Invalid site token")
+	ErrSiteTokenExpired      = newError(http.StatusUnauthorized, utils.ErrCodeSiteTokenExpired, "site token expired", "Site token has expired")
+	ErrInvalidEmbedDomain    = newFieldError("domain", validators.CodeInvalidHost, "invalid embed domain", "domain must be a bare host name")
+	ErrDestinationNotAllowed = errors.New("destination is outside the embedding site's domain")
+)
+
+// SiteToken is the signed claim an embedding site presents to the widget endpoint
+type SiteToken struct {
+	AccountID string `json:"acct"`
+	Domain    string `json:"site"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+}
+
+// EmbedService issues and verifies HMAC-signed site tokens for the public
+// "shorten this page" widget
+type EmbedService struct {
+	signer tokenSigner
+}
+
+func NewEmbedService(secret string) *EmbedService {
+	return &EmbedService{
+		signer: newTokenSigner(secret),
+	}
+}
+
+// IssueToken signs a token letting accountID's widget shorten pages on domain
+// A zero ttl issues a token that never expires
+func (s *EmbedService) IssueToken(accountID, domain string, ttl time.Duration) (string, *SiteToken, error) {
+	if !s.signer.enabled() {
+		return "", nil, ErrEmbedDisabled
+	}
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if domain == "" || strings.ContainsAny(domain, "/:@") {
+		return "", nil, ErrInvalidEmbedDomain
+	}
+	claims := &SiteToken{AccountID: accountID, Domain: domain}
+	if ttl > 0 {
+		claims.ExpiresAt = time.Now().Add(ttl).Unix()
+	}
+	token, err := s.signer.sign(claims)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode site token: %w", err)
+	}
+	return token, claims, nil
+}
+
+// VerifyToken checks the signature and expiry of a site token
+func (s *EmbedService) VerifyToken(token string) (*SiteToken, error) {
+	if !s.signer.enabled() {
+		return nil, ErrEmbedDisabled
+	}
+	var claims SiteToken
+	if !s.signer.verify(token, &claims) || claims.AccountID == "" || claims.Domain == "" {
+		return nil, ErrInvalidSiteToken
+	}
+	if claims.ExpiresAt != 0 && time.Now().Unix() > claims.ExpiresAt {
+		return nil, ErrSiteTokenExpired
+	}
+	return &claims, nil
+}
+
+// CheckDestination ensures rawURL lives on the token's domain or a subdomain of it
+func (s *EmbedService) CheckDestination(claims *SiteToken, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ErrInvalidURL
+	}
+	if !hostWithinDomain(parsed.Hostname(), claims.Domain) {
+		return ErrDestinationNotAllowed
+	}
+	return nil
+}
+
+// AllowedOrigin reports whether a browser Origin header belongs to the token's site
+func (s *EmbedService) AllowedOrigin(claims *SiteToken, origin string) bool {
+	parsed, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return hostWithinDomain(parsed.Hostname(), claims.Domain)
+}
+
+func hostWithinDomain(host, domain string) bool {
+	host = strings.ToLower(host)
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+)
+
+// Error is a service error that knows how the API reports it. The exported
+// Err* values are *Error, so callers keep matching them with errors.Is while
+// middleware.ServiceErrors finds one anywhere in a wrapped chain with
+// errors.As and answers with its status and code
+type Error struct {
+	// Status and Code are the HTTP status and error code clients get
+	Status int
+	Code   string
+	// Field and FieldCode are set on errors about one request field, which
+	// are reported as a validation failure of that field
+	Field     string
+	FieldCode string
+	// Detail is the message clients get; empty sends the whole chain's text,
+	// which carries the reason wrapped errors add
+	Detail string
+
+	message string
+}
+
+func (e *Error) Error() string {
+	return e.message
+}
+
+// newError is an error reported with status and code
+func newError(status int, code, message, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail, message: message}
+}
+
+// newFieldError is an error about the request's field, reported as a
+// validation failure with fieldCode
+func newFieldError(field, fieldCode, message, detail string) *Error {
+	return &Error{
+		Status:    http.StatusBadRequest,
+		Code:      utils.ErrCodeValidationFailed,
+		Field:     field,
+		FieldCode: fieldCode,
+		Detail:    detail,
+		message:   message,
+	}
+}
+
+// Describe returns how err is reported to clients: the *Error in its chain
+// with Detail filled in, or a 500 saying fallback for any other error
+func Describe(err error, fallback string) *Error {
+	var serviceErr *Error
+	if !errors.As(err, &serviceErr) {
+		return &Error{Status: http.StatusInternalServerError, Code: utils.ErrCodeInternal, Detail: fallback, message: err.Error()}
+	}
+	described := *serviceErr
+	if described.Detail == "" {
+		described.Detail = err.Error()
+	}
+	return &described
+}
+
+// Internal reports whether err is not a service error, so it is a failure
+// clients only see as a 500 and is worth logging
+func Internal(err error) bool {
+	var serviceErr *Error
+	return !errors.As(err, &serviceErr)
+}
+
+// failure drops the service errors that are normal outcomes for visitors and
+// callers, so only internal errors and unavailability mark a span as failed
+func failure(err error) error {
+	if Internal(err) || Describe(err, "").Status >= http.StatusInternalServerError {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/events"
+)
+
+// streamBatch caps how many events go to the publisher at once
+const streamBatch = 100
+
+// EventStream hands click and link lifecycle events to a publisher in the
+// background, so the redirect path never waits on Kafka or NATS. Events are
+// buffered up to bufferSize and dropped, with a warning, when the publisher
+// falls that far behind
+type EventStream struct {
+	registry  *events.Registry
+	publisher events.Publisher
+	// enabled is false for the no-op publisher, which skips building events
+	enabled bool
+	queue   chan events.Message
+	dropped atomic.Int64
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+func NewEventStream(registry *events.Registry, publisher events.Publisher, bufferSize int) *EventStream {
+	_, nop := publisher.(events.NopPublisher)
+	return &EventStream{
+		registry:  registry,
+		publisher: publisher,
+		enabled:   !nop,
+		queue:     make(chan events.Message, bufferSize),
+		stop:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+}
+
+// Emit queues an event for payload; key orders it among events of the same link
+func (s *EventStream) Emit(t events.Type, key string, payload interface{}) {
+	if !s.enabled {
+		return
+	}
+	env, err := s.registry.New(t, payload)
+	if err != nil {
+		log.Printf("Failed to build %s event: %v", t, err)
+		return
+	}
+	select {
+	case <-s.stop:
+	case s.queue <- events.Message{Key: key, Envelope: env}:
+	default:
+		if s.dropped.Add(1) == 1 {
+			log.Printf("Event stream buffer full, dropping events")
+		}
+	}
+}
+
+// Start runs the publish loop until Stop is called
+func (s *EventStream) Start() {
+	go func() {
+		defer close(s.stopped)
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case message := <-s.queue:
+				s.publish(s.batch(message))
+			case <-ticker.C:
+				if n := s.dropped.Swap(0); n > 0 {
+					log.Printf("Dropped %d events in the last minute because the event stream fell behind", n)
+				}
+			case <-s.stop:
+				for len(s.queue) > 0 {
+					s.publish(s.batch(<-s.queue))
+				}
+				return
+			}
+		}
+	}()
+}
+
+// Stop publishes the events still buffered and closes the publisher
+func (s *EventStream) Stop() error {
+	close(s.stop)
+	<-s.stopped
+	return s.publisher.Close()
+}
+
+// batch collects first and whatever else is already queued, up to streamBatch
+func (s *EventStream) batch(first events.Message) []events.Message {
+	messages := []events.Message{first}
+	for len(messages) < streamBatch {
+		select {
+		case message := <-s.queue:
+			messages = append(messages, message)
+		default:
+			return messages
+		}
+	}
+	return messages
+}
+
+func (s *EventStream) publish(messages []events.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.publisher.Publish(ctx, messages); err != nil {
+		log.Printf("Failed to publish %d events: %v", len(messages), err)
+	}
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/storage"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+)
+
+// Export formats
+const (
+	ExportCSV     = "csv"
+	ExportGzipCSV = "csv.gz"
+	ExportParquet = "parquet"
+)
+
+// exportMarkerKey holds the end of the last interval the scheduler exported
+const exportMarkerKey = "exports/clicks/last-window"
+
+var (
+	ErrExportsDisabled = newError(http.StatusServiceUnavailable, utils.ErrCodeExportStorageMissing, "exports need object storage to be configured", "Exports need object storage to be configured")
+	ErrInvalidExport   = newError(http.StatusBadRequest, utils.ErrCodeBadRequest, "invalid export request", "")
+)
+
+// ExportService writes daily click aggregates to object storage for BI tools
+// Files land under exports/clicks/<from day>/clicks-<from>-<to>.<format>;
+// the scheduler exports each completed interval when it ends
+// Only events still in MongoDB are exported, so intervals can't be longer
+// than the archive's hot window
+type ExportService struct {
+	clickRepo *repository.ClickRepository
+	store     *storage.ObjectStore
+	format    string
+	interval  time.Duration
+	hotWindow time.Duration
+	stop      chan struct{}
+}
+
+func NewExportService(clickRepo *repository.ClickRepository, store *storage.ObjectStore, format string, interval, hotWindow time.Duration) *ExportService {
+	return &ExportService{
+		clickRepo: clickRepo,
+		store:     store,
+		format:    format,
+		interval:  interval,
+		hotWindow: hotWindow,
+		stop:      make(chan struct{}),
+	}
+}
+
+// Start exports each interval once it completes, until Stop is called. It
+// carries on from the last interval exported, so intervals missed while no
+// server ran are exported on start
+// Nothing is scheduled without object storage or with a zero interval
+func (s *ExportService) Start() {
+	if s.store == nil || s.interval <= 0 {
+		return
+	}
+	go func() {
+		for {
+			s.catchUp(context.Background())
+			next := time.Now().UTC().Truncate(s.interval).Add(s.interval)
+			select {
+			case <-time.After(time.Until(next)):
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// catchUp exports the completed intervals after the last one exported,
+// oldest first, recording each once uploaded. Without a record it exports
+// the last interval only. Intervals whose events have left the hot window
+// are skipped
+func (s *ExportService) catchUp(ctx context.Context) {
+	last, end := s.LastWindow()
+	from, err := s.lastExported(ctx)
+	if err != nil {
+		log.Printf("Failed to read the last exported click window: %v", err)
+		return
+	}
+	if from.IsZero() {
+		from = last
+	}
+	if oldest := end.Add(-s.hotWindow); s.hotWindow > 0 && from.Before(oldest) {
+		skipped := from
+		for from.Before(oldest) {
+			from = from.Add(s.interval)
+		}
+		log.Printf("Skipping click exports from %s to %s: the events are past CLICK_HOT_WINDOW", skipped.Format(time.RFC3339), from.Format(time.RFC3339))
+	}
+	for to := from.Add(s.interval); !to.After(end); from, to = to, to.Add(s.interval) {
+		if _, err := s.Export(ctx, from, to, ""); err != nil {
+			log.Printf("Failed to export click analytics: %v", err)
+			return
+		}
+		if err := s.store.Put(ctx, exportMarkerKey, []byte(to.Format(time.RFC3339)), "text/plain"); err != nil {
+			log.Printf("Failed to record the last exported click window: %v", err)
+			return
+		}
+	}
+}
+
+// lastExported is the end of the last interval the scheduler exported, or
+// zero if it has exported none
+func (s *ExportService) lastExported(ctx context.Context) (time.Time, error) {
+	raw, err := s.store.Get(ctx, exportMarkerKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, strings.TrimSpace(string(raw)))
+}
+
+// Stop ends the scheduler
+func (s *ExportService) Stop() {
+	close(s.stop)
+}
+
+// LastWindow is the most recent completed interval (or UTC day without a schedule)
+func (s *ExportService) LastWindow() (time.Time, time.Time) {
+	interval := s.interval
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+	to := time.Now().UTC().Truncate(interval)
+	return to.Add(-interval), to
+}
+
+// Export aggregates clicks in [from, to) and uploads them in format, or the
+// configured format when empty
+func (s *ExportService) Export(ctx context.Context, from, to time.Time, format string) (*models.ExportResult, error) {
+	if s.store == nil {
+		return nil, ErrExportsDisabled
+	}
+	if format == "" {
+		format = s.format
+	}
+	if format != ExportCSV && format != ExportGzipCSV && format != ExportParquet {
+		return nil, fmt.Errorf("%w: format must be csv, csv.gz or parquet", ErrInvalidExport)
+	}
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidExport)
+	}
+
+	rows, err := s.clickRepo.DailyAggregates(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate clicks: %w", err)
+	}
+	var body []byte
+	var contentType string
+	switch format {
+	case ExportParquet:
+		body, err = encodeParquet(rows)
+		contentType = "application/vnd.apache.parquet"
+	case ExportGzipCSV:
+		body, err = encodeCSV(rows, true)
+		contentType = "application/gzip"
+	default:
+		body, err = encodeCSV(rows, false)
+		contentType = "text/csv"
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	from, to = from.UTC(), to.UTC()
+	key := fmt.Sprintf("exports/clicks/%s/clicks-%s-%s.%s", from.Format(archiveDayLayout), from.Format("20060102T150405Z"), to.Format("20060102T150405Z"), format)
+	if err := s.store.Put(ctx, key, body, contentType); err != nil {
+		return nil, fmt.Errorf("failed to upload export: %w", err)
+	}
+	return &models.ExportResult{Key: key, Format: format, From: from, To: to, Rows: len(rows), Bytes: len(body)}, nil
+}
+
+// encodeCSV writes rows with a header line, gzipped when compress is set
+func encodeCSV(rows []models.ClickAggregate, compress bool) ([]byte, error) {
+	var buf bytes.Buffer
+	var gz *gzip.Writer
+	w := csv.NewWriter(&buf)
+	if compress {
+		gz = gzip.NewWriter(&buf)
+		w = csv.NewWriter(gz)
+	}
+	w.Write([]string{"day", "domain", "short_code", "owner_id", "country", "referrer_domain", "device", "bot", "clicks"})
+	for _, row := range rows {
+		w.Write([]string{
+			row.Day, row.Domain, row.ShortCode, row.OwnerID, row.Country, row.ReferrerDomain, row.Device,
+			strconv.FormatBool(row.Bot), strconv.FormatInt(row.Clicks, 10),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to encode CSV export: %w", err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress CSV export: %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeParquet writes rows as a Snappy-compressed Parquet file, with the
+// columns of the CSV export
+func encodeParquet(rows []models.ClickAggregate) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := parquet.Write(&buf, rows, parquet.Compression(&parquet.Snappy)); err != nil {
+		return nil, fmt.Errorf("failed to encode Parquet export: %w", err)
+	}
+	return buf.Bytes(), nil
+}
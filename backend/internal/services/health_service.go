@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Dependency states reported by readiness checks
+const (
+	DependencyUp   = "up"
+	DependencyDown = "down"
+)
+
+// DependencyStatus is the state of one backing service
+type DependencyStatus struct {
+	Status   string `json:"status"`
+	Required bool   `json:"required"`
+	Error    string `json:"error,omitempty"`
+}
+
+// dependency is a backing service that can be pinged
+type dependency struct {
+	name     string
+	required bool
+	ping     func(ctx context.Context) error
+}
+
+// HealthService reports whether the server's dependencies are reachable
+// MongoDB is required to serve traffic; without Redis the server runs degraded
+// (no queue-issued codes, no shared cache or rate limits) but stays ready.
+// A down link shard only fails the links it holds, so it doesn't make the
+// server unready either
+type HealthService struct {
+	dependencies []dependency
+	timeout      time.Duration
+}
+
+func NewHealthService(mongoClient *mongo.Client, redisClient redis.UniversalClient, shards map[string]*mongo.Client) *HealthService {
+	s := &HealthService{
+		dependencies: []dependency{
+			{name: "mongodb", required: true, ping: func(ctx context.Context) error {
+				return mongoClient.Ping(ctx, nil)
+			}},
+			{name: "redis", ping: func(ctx context.Context) error {
+				return redisClient.Ping(ctx).Err()
+			}},
+		},
+		timeout: 2 * time.Second,
+	}
+	for _, name := range slices.Sorted(maps.Keys(shards)) {
+		client := shards[name]
+		s.dependencies = append(s.dependencies, dependency{name: "mongodb_shard_" + name, ping: func(ctx context.Context) error {
+			return client.Ping(ctx, nil)
+		}})
+	}
+	return s
+}
+
+// Check pings every dependency and reports whether all required ones are up
+func (s *HealthService) Check(ctx context.Context) (bool, map[string]DependencyStatus) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	type result struct {
+		name string
+		err  error
+	}
+	results := make(chan result, len(s.dependencies))
+	for _, dep := range s.dependencies {
+		go func(dep dependency) {
+			results <- result{name: dep.name, err: dep.ping(ctx)}
+		}(dep)
+	}
+
+	required := make(map[string]bool, len(s.dependencies))
+	for _, dep := range s.dependencies {
+		required[dep.name] = dep.required
+	}
+	ready := true
+	statuses := make(map[string]DependencyStatus, len(s.dependencies))
+	for range s.dependencies {
+		r := <-results
+		status := DependencyStatus{Status: DependencyUp, Required: required[r.name]}
+		if r.err != nil {
+			status.Status = DependencyDown
+			status.Error = r.err.Error()
+			if status.Required {
+				ready = false
+			}
+		}
+		statuses[r.name] = status
+	}
+	return ready, statuses
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/events"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+)
+
+// redactedHeaders never leave the server in alerts
+var redactedHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+	"X-Api-Key":     true,
+	"X-Admin-Token": true,
+}
+
+// HoneytokenService raises an alert whenever a honeytoken link is opened
+// Alerts go to the webhook endpoints and, when SMTP is configured, by email
+type HoneytokenService struct {
+	webhooks   *WebhookService
+	mailer     *Mailer
+	recipients []string
+	ips        *IPAnonymizer
+}
+
+func NewHoneytokenService(webhooks *WebhookService, mailer *Mailer, recipients []string, ips *IPAnonymizer) *HoneytokenService {
+	return &HoneytokenService{
+		webhooks:   webhooks,
+		mailer:     mailer,
+		recipients: recipients,
+		ips:        ips,
+	}
+}
+
+// Trigger alerts on an access to link; delivery happens in the background so
+// the visitor sees a normal redirect
+func (s *HoneytokenService) Trigger(link *models.ShortURL, visit Visit) {
+	visit.IP = s.ips.Anonymize(visit.IP)
+	headers := alertHeaders(visit.Headers)
+	if s.ips.Enabled() {
+		for _, name := range ipForwardingHeaders {
+			delete(headers, name)
+		}
+	}
+	alert := events.HoneytokenTriggered{
+		Priority:    "high",
+		ShortCode:   link.ShortCode,
+		Domain:      link.Domain,
+		Label:       link.Title,
+		OwnerID:     link.OwnerID,
+		TriggeredAt: time.Now().UTC(),
+		IP:          visit.IP,
+		Country:     strings.ToUpper(visit.Country),
+		UserAgent:   visit.UserAgent,
+		Referrer:    visit.Referrer,
+		URL:         visit.URL,
+		Headers:     headers,
+	}
+	log.Printf("Honeytoken %s triggered from %s (%s)", link.ShortCode, visit.IP, visit.UserAgent)
+
+	s.webhooks.Emit(events.TypeHoneytokenTriggered, alert)
+	if s.mailer.Enabled() && len(s.recipients) > 0 {
+		go func() {
+			subject := fmt.Sprintf("[ALERT] Honeytoken %s opened from %s", link.ShortCode, visit.IP)
+			if err := s.mailer.Send(s.recipients, subject, alertBody(alert)); err != nil {
+				log.Printf("Failed to email honeytoken alert: %v", err)
+			}
+		}()
+	}
+}
+
+// alertHeaders flattens request headers, redacting credentials
+func alertHeaders(header http.Header) map[string]string {
+	flat := make(map[string]string, len(header))
+	for name, values := range header {
+		if redactedHeaders[http.CanonicalHeaderKey(name)] {
+			flat[name] = "[redacted]"
+			continue
+		}
+		flat[name] = strings.Join(values, ", ")
+	}
+	return flat
+}
+
+func alertBody(alert events.HoneytokenTriggered) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "A honeytoken link was opened.\n\n")
+	fmt.Fprintf(&b, "Short code:  %s\n", alert.ShortCode)
+	if alert.Domain != "" {
+		fmt.Fprintf(&b, "Domain:      %s\n", alert.Domain)
+	}
+	if alert.Label != "" {
+		fmt.Fprintf(&b, "Label:       %s\n", alert.Label)
+	}
+	fmt.Fprintf(&b, "Time:        %s\n", alert.TriggeredAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "IP:          %s\n", alert.IP)
+	if alert.Country != "" {
+		fmt.Fprintf(&b, "Country:     %s\n", alert.Country)
+	}
+	fmt.Fprintf(&b, "User-Agent:  %s\n", alert.UserAgent)
+	fmt.Fprintf(&b, "Referrer:    %s\n", alert.Referrer)
+	fmt.Fprintf(&b, "URL:         %s\n\nHeaders:\n", alert.URL)
+	names := make([]string, 0, len(alert.Headers))
+	for name := range alert.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "  %s: %s\n", name, alert.Headers[name])
+	}
+	return b.String()
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const instanceRegistryKey = "instances"
+
+// InstanceRegistry announces this replica in Redis with periodic heartbeats
+// so operators can see which replicas are alive. A replica that misses three
+// heartbeats drops out of the registry
+type InstanceRegistry struct {
+	redisClient redis.UniversalClient
+	instance    models.Instance
+	interval    time.Duration
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+// NewInstanceRegistry creates the registry entry for this replica
+// An empty id falls back to the hostname, which is stable across restarts
+// on most orchestrators, and then to a random ID
+func NewInstanceRegistry(redisClient redis.UniversalClient, id, port, region string, interval time.Duration) *InstanceRegistry {
+	hostname, _ := os.Hostname()
+	if id == "" {
+		id = hostname
+	}
+	if id == "" {
+		id = randomInstanceID()
+	}
+	return &InstanceRegistry{
+		redisClient: redisClient,
+		instance: models.Instance{
+			ID:        id,
+			Hostname:  hostname,
+			Port:      port,
+			Region:    region,
+			StartedAt: time.Now().UTC(),
+		},
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// ID is this replica's instance ID
+func (r *InstanceRegistry) ID() string {
+	return r.instance.ID
+}
+
+// Start sends a heartbeat now and then every interval
+func (r *InstanceRegistry) Start() {
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			if err := r.heartbeat(context.Background()); err != nil {
+				log.Printf("Failed to send instance heartbeat: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the heartbeats and removes this replica from the registry so
+// it disappears right away instead of after its heartbeat expires
+func (r *InstanceRegistry) Stop() {
+	close(r.stop)
+	<-r.done
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pipe := r.redisClient.TxPipeline()
+	pipe.ZRem(ctx, instanceRegistryKey, r.instance.ID)
+	pipe.Del(ctx, instanceKey(r.instance.ID))
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to deregister instance: %v", err)
+	}
+}
+
+// Instances lists the replicas that sent a heartbeat recently, longest running first
+func (r *InstanceRegistry) Instances(ctx context.Context) ([]models.Instance, error) {
+	cutoff := time.Now().Add(-r.expiry())
+	if err := r.redisClient.ZRemRangeByScore(ctx, instanceRegistryKey, "-inf", "("+strconv.FormatInt(cutoff.UnixMilli(), 10)).Err(); err != nil {
+		return nil, fmt.Errorf("failed to prune instances: %w", err)
+	}
+	ids, err := r.redisClient.ZRange(ctx, instanceRegistryKey, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instances: %w", err)
+	}
+	instances := []models.Instance{}
+	if len(ids) == 0 {
+		return instances, nil
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = instanceKey(id)
+	}
+	values, err := r.redisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read instances: %w", err)
+	}
+	for _, value := range values {
+		raw, ok := value.(string)
+		if !ok {
+			continue // expired between the two reads
+		}
+		var instance models.Instance
+		if err := json.Unmarshal([]byte(raw), &instance); err != nil {
+			continue
+		}
+		instances = append(instances, instance)
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].StartedAt.Before(instances[j].StartedAt)
+	})
+	return instances, nil
+}
+
+// heartbeat refreshes this replica's entry and its last-seen time
+func (r *InstanceRegistry) heartbeat(ctx context.Context) error {
+	instance := r.instance
+	instance.LastSeen = time.Now().UTC()
+	raw, err := json.Marshal(instance)
+	if err != nil {
+		return err
+	}
+	pipe := r.redisClient.TxPipeline()
+	pipe.Set(ctx, instanceKey(instance.ID), raw, r.expiry())
+	pipe.ZAdd(ctx, instanceRegistryKey, redis.Z{Score: float64(instance.LastSeen.UnixMilli()), Member: instance.ID})
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (r *InstanceRegistry) expiry() time.Duration {
+	return 3 * r.interval
+}
+
+// instanceKey is hash tagged into the registry's cluster slot, so an
+// instance's entries are written in one transaction and read in one MGET
+func instanceKey(id string) string {
+	return "{" + instanceRegistryKey + "}:instance:" + id
+}
+
+func randomInstanceID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// invalidationChannel is the Redis pub/sub channel replicas share
+const invalidationChannel = "invalidations"
+
+// Invalidation kinds; the key is a link cache key or a domain host
+const (
+	InvalidateLinkUpdated     = "link.updated"
+	InvalidateLinkDeactivated = "link.deactivated"
+	InvalidateLinkDeleted     = "link.deleted"
+	InvalidateDomainChanged   = "domain.changed"
+)
+
+// Invalidation tells replicas that something they may have cached changed
+type Invalidation struct {
+	Kind string `json:"kind"`
+	Key  string `json:"key"`
+	// Origin is the publishing replica, which has already evicted its own copy
+	Origin string `json:"origin"`
+}
+
+// InvalidationBus spreads cache invalidations to every replica through Redis
+// pub/sub. In-process caches subscribe to the kinds they hold, and are reset
+// when the subscription reconnects since invalidations sent meanwhile are lost
+type InvalidationBus struct {
+	redisClient redis.UniversalClient
+	origin      string
+
+	mu       sync.RWMutex
+	handlers map[string][]func(key string)
+	resets   []func()
+
+	pubsub *redis.PubSub
+	done   chan struct{}
+}
+
+func NewInvalidationBus(redisClient redis.UniversalClient, instanceID string) *InvalidationBus {
+	return &InvalidationBus{
+		redisClient: redisClient,
+		origin:      instanceID,
+		handlers:    make(map[string][]func(key string)),
+		done:        make(chan struct{}),
+	}
+}
+
+// Subscribe calls fn with the key of every invalidation of kind published by
+// another replica
+func (b *InvalidationBus) Subscribe(kind string, fn func(key string)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[kind] = append(b.handlers[kind], fn)
+}
+
+// OnReset calls fn whenever invalidations may have been missed, so the
+// subscriber should drop everything it caches
+func (b *InvalidationBus) OnReset(fn func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.resets = append(b.resets, fn)
+}
+
+// Publish tells the other replicas that key changed
+func (b *InvalidationBus) Publish(ctx context.Context, kind, key string) error {
+	raw, err := json.Marshal(Invalidation{Kind: kind, Key: key, Origin: b.origin})
+	if err != nil {
+		return err
+	}
+	if err := b.redisClient.Publish(ctx, invalidationChannel, raw).Err(); err != nil {
+		return fmt.Errorf("failed to publish %s invalidation: %w", kind, err)
+	}
+	return nil
+}
+
+// Start listens for invalidations from other replicas
+func (b *InvalidationBus) Start() {
+	b.pubsub = b.redisClient.Subscribe(context.Background(), invalidationChannel)
+	go func() {
+		defer close(b.done)
+		for {
+			msg, err := b.pubsub.Receive(context.Background())
+			if err != nil {
+				if err == redis.ErrClosed {
+					return
+				}
+				// Receive reconnects on the next call; don't spin while Redis is down
+				log.Printf("Failed to receive invalidations: %v", err)
+				time.Sleep(time.Second)
+				continue
+			}
+			switch m := msg.(type) {
+			case *redis.Subscription:
+				b.reset()
+			case *redis.Message:
+				b.dispatch(m.Payload)
+			}
+		}
+	}()
+}
+
+// Stop ends the listener
+func (b *InvalidationBus) Stop() {
+	if b.pubsub == nil {
+		return
+	}
+	b.pubsub.Close()
+	<-b.done
+}
+
+func (b *InvalidationBus) dispatch(payload string) {
+	var inv Invalidation
+	if err := json.Unmarshal([]byte(payload), &inv); err != nil {
+		log.Printf("Failed to decode invalidation: %v", err)
+		return
+	}
+	if inv.Origin == b.origin {
+		return
+	}
+	b.mu.RLock()
+	handlers := b.handlers[inv.Kind]
+	b.mu.RUnlock()
+	for _, fn := range handlers {
+		fn(inv.Key)
+	}
+}
+
+func (b *InvalidationBus) reset() {
+	b.mu.RLock()
+	resets := b.resets
+	b.mu.RUnlock()
+	for _, fn := range resets {
+		fn()
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/netip"
+)
+
+// IP anonymization modes
+const (
+	// IPAnonymizeOff keeps addresses as they are
+	IPAnonymizeOff = ""
+	// IPAnonymizeTruncate zeroes the host part: the last octet of IPv4
+	// addresses and all but the first 48 bits of IPv6 ones
+	IPAnonymizeTruncate = "truncate"
+	// IPAnonymizeHash replaces addresses with a keyed hash, so the same
+	// visitor can still be recognized without the address being recoverable
+	IPAnonymizeHash = "hash"
+)
+
+var ErrInvalidIPAnonymization = errors.New("IP anonymization must be truncate or hash, and hash needs a secret")
+
+// ipForwardingHeaders carry visitor addresses and are dropped from alerts
+// when addresses are anonymized
+var ipForwardingHeaders = []string{"X-Forwarded-For", "X-Real-Ip", "Forwarded", "Cf-Connecting-Ip", "True-Client-Ip"}
+
+// IPAnonymizer rewrites visitor addresses before they are stored or sent
+// anywhere, for deployments that must not keep personal data. Addresses are
+// still used as they are for access rules and rate limits while a request
+// is being served
+type IPAnonymizer struct {
+	mode   string
+	secret []byte
+}
+
+// NewIPAnonymizer returns an anonymizer for mode, one of the IPAnonymize* modes
+func NewIPAnonymizer(mode, secret string) (*IPAnonymizer, error) {
+	switch mode {
+	case IPAnonymizeOff, IPAnonymizeTruncate:
+	case IPAnonymizeHash:
+		if secret == "" {
+			return nil, ErrInvalidIPAnonymization
+		}
+	default:
+		return nil, ErrInvalidIPAnonymization
+	}
+	return &IPAnonymizer{mode: mode, secret: []byte(secret)}, nil
+}
+
+// Enabled reports whether addresses are rewritten; a nil anonymizer is off
+func (a *IPAnonymizer) Enabled() bool {
+	return a != nil && a.mode != IPAnonymizeOff
+}
+
+// Anonymize rewrites ip according to the mode. Values that aren't addresses
+// are hashed or dropped, never kept
+func (a *IPAnonymizer) Anonymize(ip string) string {
+	if !a.Enabled() || ip == "" {
+		return ip
+	}
+	if a.mode == IPAnonymizeHash {
+		mac := hmac.New(sha256.New, a.secret)
+		mac.Write([]byte(ip))
+		return hex.EncodeToString(mac.Sum(nil)[:16])
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+	bits := 48
+	if addr.Is4() {
+		bits = 24
+	}
+	prefix, _ := addr.Prefix(bits)
+	return prefix.Addr().String()
+}
@@ -8,11 +8,18 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/breaker"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/tracing"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
@@ -20,14 +27,75 @@ var (
 	ErrRedisUnavailable      = errors.New("redis unavailable")
 )
 
+// keyLeasesKey is a hash of the blocks of codes replicas hold, by lease
+const keyLeasesKey = "keygen:lease_blocks"
+
+// keyBlock is a block of codes leased off the queue, handed out without
+// asking Redis again
+type keyBlock struct {
+	lease string
+	codes []string
+	// next is the index of the next code to hand out
+	next int
+}
+
+// leaseRecord is how a lease is kept in keyLeasesKey: its block, how many of
+// the codes its replica had handed out when it last renewed the lease, and
+// when the lease lapses unless renewed again
+type leaseRecord struct {
+	Codes  []string `json:"codes"`
+	Used   int      `json:"used"`
+	Lapses int64    `json:"lapses"`
+}
+
+// reclaimScript removes lease ARGV[1] if it still holds ARGV[2], so only one
+// replica requeues the codes of a lapsed lease
+var reclaimScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], ARGV[1]) == ARGV[2] then
+	return redis.call('HDEL', KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+// renewScript sets each lease of ARGV, given as lease and record pairs, to
+// its record if the lease is still in KEYS[1], and returns the leases that
+// are not: another replica found them lapsed and requeued their codes
+var renewScript = redis.NewScript(`
+local lost = {}
+for i = 1, #ARGV, 2 do
+	if redis.call('HEXISTS', KEYS[1], ARGV[i]) == 1 then
+		redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+	else
+		table.insert(lost, ARGV[i])
+	end
+end
+return lost
+`)
+
 type KeyService struct {
-	redisClient *redis.Client
+	redisClient redis.UniversalClient
 	httpClient  *http.Client
 	serviceURL  string
 	queueName   string
+	reserved    *validators.ReservedWords
+	breaker     *breaker.Breaker
+	instanceID  string
+	// blockSize is how many codes a lease takes; 0 pops one code per link
+	blockSize int64
+	leaseTTL  time.Duration
+
+	mu        sync.Mutex
+	blocks    []keyBlock
+	available int64
+	// finished are leases whose codes have all been handed out
+	finished []string
+
+	low     chan struct{}
+	stop    chan struct{}
+	stopped chan struct{}
 }
 
-func NewKeyService(redisClient *redis.Client, serviceURL, queueName string) *KeyService {
+func NewKeyService(redisClient redis.UniversalClient, serviceURL, queueName string, reserved *validators.ReservedWords, redisBreaker *breaker.Breaker, instanceID string, blockSize int64, leaseTTL time.Duration) *KeyService {
 	return &KeyService{
 		redisClient: redisClient,
 		httpClient: &http.Client{
@@ -35,24 +103,328 @@ func NewKeyService(redisClient *redis.Client, serviceURL, queueName string) *Key
 		},
 		serviceURL: serviceURL,
 		queueName:  queueName,
+		reserved:   reserved,
+		breaker:    redisBreaker,
+		instanceID: instanceID,
+		blockSize:  blockSize,
+		leaseTTL:   leaseTTL,
+		low:        make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		stopped:    make(chan struct{}),
 	}
 }
+
+// Start leases the next block in the background whenever the held codes run
+// low, and renews the held leases every third of their TTL
+func (s *KeyService) Start() {
+	go func() {
+		defer close(s.stopped)
+		if s.blockSize == 0 || s.redisClient == nil {
+			return
+		}
+		ticker := time.NewTicker(s.leaseTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.low:
+				s.refill(context.Background())
+				s.renew(context.Background())
+			case <-ticker.C:
+				s.renew(context.Background())
+			case <-s.stop:
+				s.release(context.Background())
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the background leasing and puts the codes not handed out back
+// on the queue
+func (s *KeyService) Stop() {
+	close(s.stop)
+	<-s.stopped
+}
+
 func (s *KeyService) GetShortCode(ctx context.Context) (string, error) {
-	// Try to get from Redis queue first
-	shortCode, err := s.getFromRedisQueue(ctx)
-	if err == nil && shortCode != "" {
+	ctx, span := tracing.Start(ctx, "KeyService.GetShortCode")
+	defer span.End()
+	// Try the leased block or the Redis queue first, skipping anything that
+	// would collide with a route
+	source := "queue"
+	var shortCode string
+	var err error
+	if s.blockSize > 0 {
+		source = "block"
+		shortCode, err = s.takeFromBlock(ctx)
+	} else {
+		shortCode, err = s.getFromRedisQueue(ctx)
+	}
+	if err == nil && shortCode != "" && s.reserved.Allowed(shortCode) {
+		span.SetAttributes(attribute.String("key.source", source))
 		return shortCode, nil
 	}
-	
-	// Generate locally instead of calling external service
+
+	// The queue is empty or unreachable, so ask the keygen service directly
+	if s.serviceURL != "" {
+		shortCode, err = s.getFromKeyGenService(ctx)
+		if err == nil && shortCode != "" && s.reserved.Allowed(shortCode) {
+			span.SetAttributes(attribute.String("key.source", "service"))
+			return shortCode, nil
+		}
+		if err != nil {
+			fmt.Printf("Failed to get short code from key generation service: %v\n", err)
+		}
+	}
+
+	// Generate locally as a last resort; a collision is caught on insert
+	span.SetAttributes(attribute.String("key.source", "local"))
 	shortCode = s.generateShortCode()
 	return shortCode, nil
 }
+
+// takeFromBlock hands out the next leased code, leasing a block first when
+// none is held. Once the held codes run low the next block is leased in the
+// background, so shortening rarely waits on Redis. Callers that find no code
+// held may each lease a block; the spare ones are handed out later
+func (s *KeyService) takeFromBlock(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	if s.available == 0 {
+		s.mu.Unlock()
+		block, err := s.lease(ctx)
+		if err != nil {
+			return "", err
+		}
+		s.mu.Lock()
+		s.hold(block)
+		if s.available == 0 {
+			s.mu.Unlock()
+			return "", nil
+		}
+	}
+	defer s.mu.Unlock()
+	block := &s.blocks[0]
+	code := block.codes[block.next]
+	block.next++
+	s.available--
+	if block.next == len(block.codes) {
+		s.finished = append(s.finished, block.lease)
+		s.blocks = s.blocks[1:]
+	}
+	if s.available <= s.blockSize/4 {
+		select {
+		case s.low <- struct{}{}:
+		default:
+		}
+	}
+	return code, nil
+}
+
+// hold adds a leased block to the codes to hand out; the caller holds s.mu
+func (s *KeyService) hold(block *keyBlock) {
+	if block == nil {
+		return
+	}
+	if len(block.codes) == 0 {
+		s.finished = append(s.finished, block.lease)
+		return
+	}
+	s.blocks = append(s.blocks, *block)
+	s.available += int64(len(block.codes))
+}
+
+// lease pops a block of codes off the queue and records the lease, which
+// lapses after leaseTTL unless renewed. An empty queue leases nothing
+func (s *KeyService) lease(ctx context.Context) (*keyBlock, error) {
+	if s.redisClient == nil {
+		return nil, ErrRedisUnavailable
+	}
+	var codes []string
+	err := s.breaker.Do(func() error {
+		var err error
+		codes, err = s.redisClient.LPopCount(ctx, s.queueName, int(s.blockSize)).Result()
+		return err
+	})
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to lease short codes: %w", err)
+	}
+	block := &keyBlock{
+		lease: fmt.Sprintf("%s:%d", s.instanceID, time.Now().UnixNano()),
+		codes: make([]string, 0, len(codes)),
+	}
+	for _, code := range codes {
+		if s.reserved.Allowed(code) {
+			block.codes = append(block.codes, code)
+		}
+	}
+	// A block is only held with a record of its lease, which renewals
+	// extend; without one its codes go back on the queue
+	record, err := json.Marshal(leaseRecord{Codes: block.codes, Lapses: time.Now().Add(s.leaseTTL).Unix()})
+	if err == nil {
+		err = s.redisClient.HSet(ctx, keyLeasesKey, block.lease, record).Err()
+	}
+	if err != nil {
+		if len(block.codes) > 0 {
+			if err := s.redisClient.LPush(ctx, s.queueName, stringsToAny(block.codes)...).Err(); err != nil {
+				log.Printf("Failed to requeue unleased short codes: %v", err)
+			}
+		}
+		return nil, fmt.Errorf("failed to record short code lease: %w", err)
+	}
+	return block, nil
+}
+
+// refill leases the next block if the held codes are still running low
+func (s *KeyService) refill(ctx context.Context) {
+	s.mu.Lock()
+	low := s.available <= s.blockSize/4
+	s.mu.Unlock()
+	if !low {
+		return
+	}
+	block, err := s.lease(ctx)
+	if err != nil {
+		log.Printf("Failed to lease short codes: %v", err)
+		return
+	}
+	s.mu.Lock()
+	s.hold(block)
+	s.mu.Unlock()
+}
+
+// renew extends the leases of the blocks still held, noting how many of
+// their codes were handed out, ends those used up and reclaims lapsed leases.
+// A lease that was reclaimed meanwhile is not recreated: its codes are back
+// on the queue, so its block is dropped instead
+func (s *KeyService) renew(ctx context.Context) {
+	s.mu.Lock()
+	lapses := time.Now().Add(s.leaseTTL).Unix()
+	held := make([]interface{}, 0, 2*len(s.blocks))
+	for _, block := range s.blocks {
+		record, err := json.Marshal(leaseRecord{Codes: block.codes, Used: block.next, Lapses: lapses})
+		if err != nil {
+			continue
+		}
+		held = append(held, block.lease, record)
+	}
+	finished := s.finished
+	s.finished = nil
+	s.mu.Unlock()
+
+	if len(held) > 0 {
+		lost, err := renewScript.Run(ctx, s.redisClient, []string{keyLeasesKey}, held...).StringSlice()
+		if err != nil {
+			log.Printf("Failed to renew short code leases: %v", err)
+			return
+		}
+		s.drop(lost)
+	}
+	if len(finished) > 0 {
+		if err := s.redisClient.HDel(ctx, keyLeasesKey, finished...).Err(); err != nil {
+			log.Printf("Failed to end short code leases: %v", err)
+		}
+	}
+	s.reclaim(ctx)
+}
+
+// drop stops handing out the codes of blocks whose leases were reclaimed
+func (s *KeyService) drop(leases []string) {
+	if len(leases) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blocks = slices.DeleteFunc(s.blocks, func(block keyBlock) bool {
+		if !slices.Contains(leases, block.lease) {
+			return false
+		}
+		log.Printf("Short code lease %s lapsed and was reclaimed; dropping its %d codes", block.lease, len(block.codes)-block.next)
+		s.available -= int64(len(block.codes) - block.next)
+		return true
+	})
+}
+
+// reclaim puts the codes of lapsed leases, whose replicas went away without
+// returning them, back on the queue. Codes handed out since a lease was last
+// renewed go back too; reissuing one fails on insert and a new code is taken
+func (s *KeyService) reclaim(ctx context.Context) {
+	leases, err := s.redisClient.HGetAll(ctx, keyLeasesKey).Result()
+	if err != nil {
+		log.Printf("Failed to read short code leases: %v", err)
+		return
+	}
+	now := time.Now().Unix()
+	for lease, value := range leases {
+		var record leaseRecord
+		if err := json.Unmarshal([]byte(value), &record); err != nil || record.Lapses > now {
+			continue
+		}
+		claimed, err := reclaimScript.Run(ctx, s.redisClient, []string{keyLeasesKey}, lease, value).Int()
+		if err != nil {
+			log.Printf("Failed to reclaim short code lease: %v", err)
+			return
+		}
+		if claimed == 0 || record.Used >= len(record.Codes) {
+			continue
+		}
+		if err := s.redisClient.LPush(ctx, s.queueName, stringsToAny(record.Codes[record.Used:])...).Err(); err != nil {
+			log.Printf("Failed to requeue the short codes of lease %s: %v", lease, err)
+		}
+	}
+}
+
+// release puts the codes not handed out back at the head of the queue and
+// ends every lease this replica holds
+func (s *KeyService) release(ctx context.Context) {
+	s.mu.Lock()
+	var codes []interface{}
+	leases := s.finished
+	for _, block := range s.blocks {
+		leases = append(leases, block.lease)
+		for _, code := range block.codes[block.next:] {
+			codes = append(codes, code)
+		}
+	}
+	s.blocks, s.available, s.finished = nil, 0, nil
+	s.mu.Unlock()
+	if len(leases) == 0 {
+		return
+	}
+
+	_, err := s.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		if len(codes) > 0 {
+			pipe.LPush(ctx, s.queueName, codes...)
+		}
+		pipe.HDel(ctx, keyLeasesKey, leases...)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to return leased short codes: %v", err)
+	}
+}
+
+// stringsToAny converts values for variadic Redis commands
+func stringsToAny(values []string) []interface{} {
+	converted := make([]interface{}, len(values))
+	for i, value := range values {
+		converted[i] = value
+	}
+	return converted
+}
+
 func (s *KeyService) getFromRedisQueue(ctx context.Context) (string, error) {
 	if s.redisClient == nil {
 		return "", ErrRedisUnavailable
 	}
-	result, err := s.redisClient.LPop(ctx, s.queueName).Result()
+	var result string
+	err := s.breaker.Do(func() error {
+		var err error
+		result, err = s.redisClient.LPop(ctx, s.queueName).Result()
+		return err
+	})
 	if err != nil {
 		if err == redis.Nil {
 			return "", nil
@@ -66,6 +438,7 @@ func (s *KeyService) getFromKeyGenService(ctx context.Context) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
+	tracing.Inject(ctx, req.Header)
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to get short code from key generation service: %w", err)
@@ -91,13 +464,22 @@ func (s *KeyService) getFromKeyGenService(ctx context.Context) (string, error) {
 	return response.ShortCode, nil
 }
 
-// generateShortCode generates a random short code locally
-// Uses base64 URL-safe encoding for shorter codes (6-8 characters)
+// generateShortCode generates a random short code locally that is not reserved
 func (s *KeyService) generateShortCode() string {
+	for {
+		if code := randomShortCode(); s.reserved.Allowed(code) {
+			return code
+		}
+	}
+}
+
+// randomShortCode generates a random short code
+// Uses base64 URL-safe encoding for shorter codes (6-8 characters)
+func randomShortCode() string {
 	// Generate 6 random bytes
 	b := make([]byte, 6)
 	rand.Read(b)
-	
+
 	// Encode to base64 URL-safe string and take first 8 characters
 	encoded := base64.URLEncoding.EncodeToString(b)
 	// Remove padding and take 8 chars for short code
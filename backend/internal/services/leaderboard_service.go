@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/breaker"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// Leaderboard periods; days and ISO weeks are counted in UTC
+const (
+	LeaderboardDay  = "day"
+	LeaderboardWeek = "week"
+)
+
+// maxLeaderboardSize caps how many entries one request may return
+const maxLeaderboardSize = 100
+
+var ErrInvalidLeaderboardPeriod = newError(http.StatusBadRequest, utils.ErrCodeBadRequest, "period must be day or week", "")
+
+// LeaderboardEntry is one link's click count in a period
+type LeaderboardEntry struct {
+	Domain    string
+	ShortCode string
+	Clicks    int64
+}
+
+// LeaderboardService keeps the most clicked links of the current day and week
+// in Redis sorted sets, globally and per account, updated on every redirect
+type LeaderboardService struct {
+	redisClient redis.UniversalClient
+	breaker     *breaker.Breaker
+}
+
+func NewLeaderboardService(redisClient redis.UniversalClient, redisBreaker *breaker.Breaker) *LeaderboardService {
+	return &LeaderboardService{
+		redisClient: redisClient,
+		breaker:     redisBreaker,
+	}
+}
+
+// RecordClick adds a click on link to the global and owner leaderboards
+func (s *LeaderboardService) RecordClick(ctx context.Context, link *models.ShortURL) error {
+	now := time.Now().UTC()
+	member := leaderboardMember(link.Domain, link.ShortCode)
+	return s.breaker.Do(func() error {
+		pipe := s.redisClient.Pipeline()
+		for _, period := range []string{LeaderboardDay, LeaderboardWeek} {
+			bucket, ttl := leaderboardBucket(period, now)
+			keys := []string{leaderboardKey(bucket, "")}
+			if link.OwnerID != "" {
+				keys = append(keys, leaderboardKey(bucket, link.OwnerID))
+			}
+			for _, key := range keys {
+				pipe.ZIncrBy(ctx, key, 1, member)
+				pipe.Expire(ctx, key, ttl)
+			}
+		}
+		_, err := pipe.Exec(ctx)
+		return err
+	})
+}
+
+// Top returns the limit most clicked links of the current period, for one
+// account or, with an empty accountID, across all links
+func (s *LeaderboardService) Top(ctx context.Context, accountID, period string, limit int) ([]LeaderboardEntry, error) {
+	if period != LeaderboardDay && period != LeaderboardWeek {
+		return nil, ErrInvalidLeaderboardPeriod
+	}
+	if limit < 1 || limit > maxLeaderboardSize {
+		limit = maxLeaderboardSize
+	}
+	bucket, _ := leaderboardBucket(period, time.Now().UTC())
+	var scores []redis.Z
+	err := s.breaker.Do(func() error {
+		var err error
+		scores, err = s.redisClient.ZRevRangeWithScores(ctx, leaderboardKey(bucket, accountID), 0, int64(limit-1)).Result()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read leaderboard: %w", err)
+	}
+	entries := make([]LeaderboardEntry, 0, len(scores))
+	for _, z := range scores {
+		member, _ := z.Member.(string)
+		domain, shortCode := parseLeaderboardMember(member)
+		entries = append(entries, LeaderboardEntry{Domain: domain, ShortCode: shortCode, Clicks: int64(z.Score)})
+	}
+	return entries, nil
+}
+
+// leaderboardBucket names the period containing now and how long its set is
+// kept, a little longer than the period itself
+func leaderboardBucket(period string, now time.Time) (string, time.Duration) {
+	if period == LeaderboardWeek {
+		year, week := now.ISOWeek()
+		return fmt.Sprintf("week:%d-W%02d", year, week), 8 * 24 * time.Hour
+	}
+	return "day:" + now.Format("2006-01-02"), 2 * 24 * time.Hour
+}
+
+func leaderboardKey(bucket, accountID string) string {
+	if accountID == "" {
+		return "leaderboard:" + bucket
+	}
+	return "leaderboard:" + bucket + ":account:" + accountID
+}
+
+// leaderboardMember encodes a link like the link cache does: the bare code on
+// the default domain, "<domain>/<code>" on branded ones
+func leaderboardMember(domain, shortCode string) string {
+	if domain == "" {
+		return shortCode
+	}
+	return domain + "/" + shortCode
+}
+
+func parseLeaderboardMember(member string) (string, string) {
+	if domain, shortCode, ok := strings.Cut(member, "/"); ok {
+		return domain, shortCode
+	}
+	return "", member
+}
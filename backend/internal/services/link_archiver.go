@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+)
+
+// LinkArchiver moves links that haven't been clicked for a while into the
+// archive collection, keeping the hot collection and its indexes small.
+// Archived links still resolve through a slower lookup on a cache miss
+type LinkArchiver struct {
+	repo      *repository.MongoRepository
+	after     time.Duration
+	interval  time.Duration
+	batchSize int64
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+func NewLinkArchiver(repo *repository.MongoRepository, after, interval time.Duration, batchSize int64) *LinkArchiver {
+	return &LinkArchiver{
+		repo:      repo,
+		after:     after,
+		interval:  interval,
+		batchSize: batchSize,
+		stop:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+}
+
+// Start archives right away and then every interval until Stop is called
+func (a *LinkArchiver) Start() {
+	go func() {
+		defer close(a.stopped)
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+		for {
+			if err := a.Archive(context.Background()); err != nil {
+				log.Printf("Failed to archive stale links: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-a.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the archive loop, letting the current batch finish
+func (a *LinkArchiver) Stop() {
+	close(a.stop)
+	<-a.stopped
+}
+
+// Archive moves stale links in batches until a batch comes back short
+func (a *LinkArchiver) Archive(ctx context.Context) error {
+	cutoff := time.Now().Add(-a.after)
+	var total int64
+	for {
+		moved, err := a.repo.ArchiveStale(ctx, cutoff, a.batchSize)
+		total += moved
+		if err != nil {
+			return err
+		}
+		if moved < a.batchSize {
+			break
+		}
+		select {
+		case <-a.stop:
+			return nil
+		default:
+		}
+	}
+	if total > 0 {
+		log.Printf("Archived %d stale links", total)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/breaker"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/tracing"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// LinkCache caches short URL documents for the redirect path: hot links in
+// an in-process LRU with a short TTL, backed by Redis
+// Every write path that changes a link must call Invalidate, which also
+// evicts the link from every replica's LRU through the invalidation bus
+type LinkCache struct {
+	redisClient redis.UniversalClient
+	ttl         time.Duration
+	breaker     *breaker.Breaker
+	local       *lruCache[models.ShortURL]
+	bus         *InvalidationBus
+	rollouts    *Rollouts
+	metrics     *CacheMetrics
+}
+
+// NewLinkCache creates the cache; a localSize of 0 disables the in-process
+// layer, and the local_cache rollout limits it to a share of links. Hits and
+// misses are counted in metrics
+func NewLinkCache(redisClient redis.UniversalClient, ttl time.Duration, localSize int, localTTL time.Duration, redisBreaker *breaker.Breaker, bus *InvalidationBus, rollouts *Rollouts, metrics *CacheMetrics) *LinkCache {
+	c := &LinkCache{
+		redisClient: redisClient,
+		ttl:         ttl,
+		breaker:     redisBreaker,
+		bus:         bus,
+		rollouts:    rollouts,
+		metrics:     metrics,
+	}
+	if localSize > 0 && localTTL > 0 {
+		c.local = newLRUCache[models.ShortURL](localSize, localTTL)
+		for _, kind := range []string{InvalidateLinkUpdated, InvalidateLinkDeactivated, InvalidateLinkDeleted} {
+			bus.Subscribe(kind, c.local.Remove)
+		}
+		bus.OnReset(c.local.Purge)
+	}
+	return c
+}
+
+// Get returns the cached link, or nil on a cache miss
+func (c *LinkCache) Get(ctx context.Context, domain, shortCode string) (_ *models.ShortURL, err error) {
+	ctx, span := tracing.Start(ctx, "LinkCache.Get", tracing.Link(domain, shortCode)...)
+	defer func() { tracing.End(span, err) }()
+	key := linkCacheKey(domain, shortCode)
+	useLocal := c.useLocal(domain, shortCode)
+	if useLocal {
+		if shortURL, ok := c.local.Get(key); ok {
+			span.SetAttributes(attribute.String("cache.result", "local"))
+			c.metrics.Observe(true)
+			return &shortURL, nil
+		}
+	}
+	var raw []byte
+	err = c.breaker.Do(func() error {
+		var err error
+		raw, err = c.redisClient.Get(ctx, key).Bytes()
+		return err
+	})
+	if err != nil {
+		if err == redis.Nil {
+			span.SetAttributes(attribute.String("cache.result", "miss"))
+			c.metrics.Observe(false)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read link cache: %w", err)
+	}
+	var shortURL models.ShortURL
+	if err := bson.Unmarshal(raw, &shortURL); err != nil {
+		return nil, fmt.Errorf("failed to decode cached link: %w", err)
+	}
+	if useLocal {
+		c.local.Add(key, shortURL)
+	}
+	span.SetAttributes(attribute.String("cache.result", "redis"))
+	c.metrics.Observe(true)
+	return &shortURL, nil
+}
+
+// Set caches the link for the configured TTL, as BSON so fields responses
+// leave out, like signing keys, are kept
+// Shadowed links aren't cached: lifting a ban doesn't invalidate them
+func (c *LinkCache) Set(ctx context.Context, shortURL *models.ShortURL) error {
+	if shortURL.Shadow != nil {
+		return nil
+	}
+	raw, err := bson.Marshal(shortURL)
+	if err != nil {
+		return fmt.Errorf("failed to encode link for cache: %w", err)
+	}
+	key := linkCacheKey(shortURL.Domain, shortURL.ShortCode)
+	if c.useLocal(shortURL.Domain, shortURL.ShortCode) {
+		c.local.Add(key, *shortURL)
+	}
+	return c.breaker.Do(func() error {
+		return c.redisClient.Set(ctx, key, raw, c.ttl).Err()
+	})
+}
+
+// Invalidate drops the cached link so the next redirect reads the database,
+// and tells the other replicas why through the invalidation bus. If that
+// fails they serve their in-process copy until its TTL runs out
+func (c *LinkCache) Invalidate(ctx context.Context, kind, domain, shortCode string) error {
+	key := linkCacheKey(domain, shortCode)
+	if c.local != nil {
+		c.local.Remove(key)
+	}
+	err := c.breaker.Do(func() error {
+		return c.redisClient.Del(ctx, key).Err()
+	})
+	if err != nil {
+		return err
+	}
+	return c.bus.Publish(ctx, kind, key)
+}
+
+// useLocal reports whether the link goes through the in-process layer
+func (c *LinkCache) useLocal(domain, shortCode string) bool {
+	return c.local != nil && c.rollouts.Enabled(RolloutLocalCache, domain, shortCode)
+}
+
+// linkCacheKey keeps default-domain keys as link:<code> and prefixes branded ones with the host
+func linkCacheKey(domain, shortCode string) string {
+	if domain == "" {
+		return "link:" + shortCode
+	}
+	return "link:" + domain + "/" + shortCode
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+)
+
+// healthCheckUserAgent identifies the checker to destination sites
+const healthCheckUserAgent = "url-shortener-link-checker/1.0"
+
+// LinkHealthChecker requests the destinations of active links in the
+// background and records whether they are still alive. Each round claims a
+// batch of the links checked longest ago, so replicas share the work and
+// every link is checked about once per recheck period. Links dead for
+// deactivateAfter are turned off, when set
+type LinkHealthChecker struct {
+	repo            *repository.MongoRepository
+	urls            *URLService
+	client          *http.Client
+	interval        time.Duration
+	recheck         time.Duration
+	batchSize       int
+	concurrency     int
+	deactivateAfter time.Duration
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+func NewLinkHealthChecker(repo *repository.MongoRepository, urls *URLService, timeout, interval, recheck time.Duration, batchSize, concurrency int, deactivateAfter time.Duration) *LinkHealthChecker {
+	return &LinkHealthChecker{
+		repo:            repo,
+		urls:            urls,
+		client:          publicHTTPClient(timeout),
+		interval:        interval,
+		recheck:         recheck,
+		batchSize:       batchSize,
+		concurrency:     concurrency,
+		deactivateAfter: deactivateAfter,
+		stop:            make(chan struct{}),
+		stopped:         make(chan struct{}),
+	}
+}
+
+// Start checks a batch right away and then every interval until Stop is called
+func (c *LinkHealthChecker) Start() {
+	go func() {
+		defer close(c.stopped)
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			if err := c.CheckBatch(context.Background()); err != nil {
+				log.Printf("Failed to check link health: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the check loop, letting the current batch finish
+func (c *LinkHealthChecker) Stop() {
+	close(c.stop)
+	<-c.stopped
+}
+
+// CheckBatch checks up to batchSize due links, concurrency at a time
+func (c *LinkHealthChecker) CheckBatch(ctx context.Context) error {
+	links := make(chan *models.ShortURL)
+	var wg sync.WaitGroup
+	for i := 0; i < c.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for link := range links {
+				c.checkLink(ctx, link)
+			}
+		}()
+	}
+	defer func() {
+		close(links)
+		wg.Wait()
+	}()
+
+	before := time.Now().Add(-c.recheck)
+	for i := 0; i < c.batchSize; i++ {
+		link, err := c.repo.ClaimHealthCheck(ctx, before)
+		if err != nil {
+			return err
+		}
+		if link == nil {
+			return nil
+		}
+		select {
+		case links <- link:
+		case <-c.stop:
+			return nil
+		}
+	}
+	return nil
+}
+
+// checkLink checks one link, records the outcome and deactivates the link
+// if it has been dead for too long
+func (c *LinkHealthChecker) checkLink(ctx context.Context, link *models.ShortURL) {
+	health := c.Check(ctx, link.OriginalURL)
+	if health.Status != models.HealthAlive {
+		health.DeadSince = &health.CheckedAt
+		if link.Health != nil && link.Health.DeadSince != nil {
+			health.DeadSince = link.Health.DeadSince
+		}
+	}
+	if err := c.repo.SetHealth(ctx, link.Domain, link.ShortCode, health); err != nil {
+		log.Printf("Failed to record health of %s: %v", link.ShortCode, err)
+		return
+	}
+	if c.deactivateAfter > 0 && health.DeadSince != nil && health.CheckedAt.Sub(*health.DeadSince) >= c.deactivateAfter {
+		c.urls.deactivate(ctx, link)
+		log.Printf("Deactivated link %s: destination dead since %s", link.ShortCode, health.DeadSince.Format(time.RFC3339))
+	}
+}
+
+// Check requests destination with HEAD, falling back to GET for servers
+// that don't allow HEAD. Only missing pages and server errors count as
+// dead: pages behind a login or refusing bots still exist
+func (c *LinkHealthChecker) Check(ctx context.Context, destination string) *models.LinkHealth {
+	health := &models.LinkHealth{CheckedAt: time.Now()}
+	resp, err := c.request(ctx, http.MethodHead, destination)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp, err = c.request(ctx, http.MethodGet, destination)
+	}
+	if err != nil {
+		health.Status = healthFailure(err)
+		health.Error = err.Error()
+		return health
+	}
+	health.HTTPStatus = resp.StatusCode
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		health.Status = models.HealthNotFound
+	case resp.StatusCode >= 500:
+		health.Status = models.HealthHTTPError
+	default:
+		health.Status = models.HealthAlive
+	}
+	return health
+}
+
+func (c *LinkHealthChecker) request(ctx context.Context, method, destination string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, destination, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", healthCheckUserAgent)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	// Only the status matters; the body of a GET is dropped unread
+	resp.Body.Close()
+	return resp, nil
+}
+
+// healthFailure classifies a failed request
+func healthFailure(err error) string {
+	var netErr net.Error
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidCert x509.CertificateInvalidError
+	switch {
+	case errors.As(err, &certErr), errors.As(err, &recordErr), errors.As(err, &unknownAuthority),
+		errors.As(err, &hostnameErr), errors.As(err, &invalidCert):
+		return models.HealthSSLError
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return models.HealthTimeout
+	default:
+		return models.HealthUnreachable
+	}
+}
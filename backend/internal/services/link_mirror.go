@@ -0,0 +1,240 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var ErrLinkMirrorDisabled = newError(http.StatusServiceUnavailable, utils.ErrCodeLinkMirrorDisabled, "the Redis link mirror is not enabled", "The Redis link mirror is not enabled")
+
+const (
+	linkMirrorLockKey   = "link_mirror:reconcile_lock"
+	linkMirrorBatchSize = 500
+	// linkMirrorTombstoneTTL is how long the tombstone of a deleted link is
+	// kept, which must outlast any copy of it still being written
+	linkMirrorTombstoneTTL = time.Hour
+)
+
+// LinkMirror keeps the Redis redirect store a full copy of the links in
+// MongoDB, so redirects keep working through a MongoDB outage. Link changes
+// made through URLService already update their copies; reconciling catches
+// the rest: links that predate the mirror, copies whose write was lost while
+// Redis was unreachable, and changes made straight in MongoDB, such as lifted
+// shadow bans and purged trash. Only one replica reconciles per interval
+type LinkMirror struct {
+	store       *repository.RedisLinkStore
+	repo        *repository.MongoRepository
+	redisClient redis.UniversalClient
+	interval    time.Duration
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// NewLinkMirror reconciles store with repo; a nil store means redirects
+// don't read the Redis mirror
+func NewLinkMirror(store *repository.RedisLinkStore, repo *repository.MongoRepository, redisClient redis.UniversalClient, interval time.Duration) *LinkMirror {
+	return &LinkMirror{
+		store:       store,
+		repo:        repo,
+		redisClient: redisClient,
+		interval:    interval,
+		stop:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+}
+
+// Start reconciles right away and then every interval until Stop is called
+// Nothing is scheduled without a store
+func (m *LinkMirror) Start() {
+	if m.store == nil {
+		close(m.stopped)
+		return
+	}
+	go func() {
+		defer close(m.stopped)
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			m.reconcileOnce(context.Background())
+			select {
+			case <-ticker.C:
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the reconcile loop
+func (m *LinkMirror) Stop() {
+	close(m.stop)
+	<-m.stopped
+}
+
+// reconcileOnce reconciles if no other replica has this interval
+func (m *LinkMirror) reconcileOnce(ctx context.Context) {
+	acquired, err := m.redisClient.SetNX(ctx, linkMirrorLockKey, 1, m.interval).Result()
+	if err != nil || !acquired {
+		if err != nil {
+			log.Printf("Failed to lock link mirror reconciliation: %v", err)
+		}
+		return
+	}
+	report, err := m.reconcile(ctx)
+	if err != nil {
+		log.Printf("Failed to reconcile link mirror: %v", err)
+		return
+	}
+	if report.Missing > 0 || report.Stale > 0 || report.Orphaned > 0 {
+		log.Printf("Repaired link mirror drift: %d missing, %d stale and %d orphaned copies", report.Missing, report.Stale, report.Orphaned)
+	}
+}
+
+// Reconcile runs a pass now, whether or not another replica is reconciling
+func (m *LinkMirror) Reconcile(ctx context.Context) (*models.MirrorReport, error) {
+	if m.store == nil {
+		return nil, ErrLinkMirrorDisabled
+	}
+	report, err := m.reconcile(ctx)
+	if errors.Is(err, repository.ErrUnavailable) {
+		return nil, ErrServiceUnavailable
+	}
+	return report, err
+}
+
+// reconcile compares every live link with its copy, copying in missing and
+// changed ones, then scans the mirror and removes copies whose link is gone.
+// Copies written since the pass started are newer than what it read, and
+// are left alone
+func (m *LinkMirror) reconcile(ctx context.Context) (*models.MirrorReport, error) {
+	report := &models.MirrorReport{StartedAt: time.Now()}
+	batch := make([]*models.ShortURL, 0, linkMirrorBatchSize)
+	err := m.repo.ForEachShortURL(ctx, func(link *models.ShortURL) error {
+		if batch = append(batch, link); len(batch) < linkMirrorBatchSize {
+			return nil
+		}
+		err := m.copyLinks(ctx, batch, report)
+		batch = batch[:0]
+		return err
+	})
+	if err == nil && len(batch) > 0 {
+		err = m.copyLinks(ctx, batch, report)
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = m.store.Scan(ctx, linkMirrorBatchSize, func(copies []*repository.MirroredLink) error {
+		return m.checkCopies(ctx, copies, report)
+	})
+	if err != nil {
+		return nil, err
+	}
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+// copyLinks writes the links whose copy is missing or differs, as read when
+// the pass started
+func (m *LinkMirror) copyLinks(ctx context.Context, links []*models.ShortURL, report *models.MirrorReport) error {
+	keys := make([]repository.LinkKey, len(links))
+	for i, link := range links {
+		keys[i] = repository.LinkKey{Domain: link.Domain, ShortCode: link.ShortCode}
+	}
+	copies, err := m.store.GetMany(ctx, keys)
+	if err != nil {
+		return err
+	}
+	for i, link := range links {
+		report.Links++
+		copied := copies[i]
+		switch {
+		case copied != nil && copied.ReadAt.After(report.StartedAt):
+			continue
+		case copied == nil || copied.Link == nil:
+			report.Missing++
+		case !sameMirrored(copied.Link, link):
+			report.Stale++
+		default:
+			continue
+		}
+		if err := m.store.SaveShortURL(ctx, link, report.StartedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkCopies looks up the links of copies read before the pass started,
+// replacing those of links that are gone, trashed or shadowed with
+// tombstones and rewriting changed ones. Old tombstones are cleared
+func (m *LinkMirror) checkCopies(ctx context.Context, copies []*repository.MirroredLink, report *models.MirrorReport) error {
+	var keys []repository.LinkKey
+	for _, copied := range copies {
+		report.Copies++
+		switch {
+		case copied.Link == nil && time.Since(copied.ReadAt) > linkMirrorTombstoneTTL:
+			if err := m.store.Forget(ctx, copied); err != nil {
+				return err
+			}
+			report.Tombstones++
+		case copied.Link != nil && copied.ReadAt.Before(report.StartedAt):
+			keys = append(keys, copied.Key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	readAt := time.Now()
+	links, err := m.repo.FindLinks(ctx, keys)
+	if err != nil {
+		return err
+	}
+	for _, copied := range copies {
+		if copied.Link == nil || !copied.ReadAt.Before(report.StartedAt) {
+			continue
+		}
+		link := links[copied.Key]
+		switch {
+		case link == nil || link.DeletedAt != nil || link.Shadow != nil:
+			report.Orphaned++
+			err = m.store.DeleteShortURL(ctx, copied.Key.Domain, copied.Key.ShortCode, readAt)
+		case !sameMirrored(copied.Link, link):
+			report.Stale++
+			err = m.store.SaveShortURL(ctx, link, readAt)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sameMirrored reports whether a copy still matches its link, ignoring the
+// counters and check results that change without the copy being updated
+func sameMirrored(copied, link *models.ShortURL) bool {
+	a, err := bson.Marshal(mirroredFields(copied))
+	if err != nil {
+		return false
+	}
+	b, err := bson.Marshal(mirroredFields(link))
+	return err == nil && bytes.Equal(a, b)
+}
+
+func mirroredFields(link *models.ShortURL) models.ShortURL {
+	fields := *link
+	fields.ClickCount, fields.BotClickCount, fields.LimitedClicks = 0, 0, 0
+	fields.LastClickedAt, fields.ExpiryNotifiedAt = nil, nil
+	fields.Health, fields.HealthCheckedAt = nil, nil
+	return fields
+}
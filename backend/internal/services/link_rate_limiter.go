@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/breaker"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// LinkRateLimiter enforces links' rate_limit caps with a Redis counter per
+// link and window. Counters expire with their window, so each hour or day
+// starts from zero
+type LinkRateLimiter struct {
+	redisClient redis.UniversalClient
+	breaker     *breaker.Breaker
+}
+
+func NewLinkRateLimiter(redisClient redis.UniversalClient, redisBreaker *breaker.Breaker) *LinkRateLimiter {
+	return &LinkRateLimiter{
+		redisClient: redisClient,
+		breaker:     redisBreaker,
+	}
+}
+
+// Allow reports whether link may redirect once more in its current window,
+// and when the window resets. Counted visits use up the cap; others only
+// check it
+func (l *LinkRateLimiter) Allow(ctx context.Context, link *models.ShortURL, count bool) (bool, time.Time, error) {
+	window := link.RateLimit.Window()
+	windowStart := time.Now().UTC().Truncate(window)
+	reset := windowStart.Add(window)
+	key := fmt.Sprintf("linkrate:%s:%s:%d", link.Domain, link.ShortCode, windowStart.Unix())
+	var used int64
+	err := l.breaker.Do(func() error {
+		if !count {
+			n, err := l.redisClient.Get(ctx, key).Int64()
+			if err == redis.Nil {
+				return nil
+			}
+			used = n + 1
+			return err
+		}
+		pipe := l.redisClient.TxPipeline()
+		incr := pipe.Incr(ctx, key)
+		pipe.ExpireAt(ctx, key, reset.Add(time.Second))
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+		used = incr.Val()
+		return nil
+	})
+	if err != nil {
+		return false, reset, fmt.Errorf("failed to count link redirect: %w", err)
+	}
+	return used <= link.RateLimit.Limit, reset, nil
+}
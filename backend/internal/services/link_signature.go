@@ -0,0 +1,76 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+)
+
+// Query parameters that sign the parameters added to a signed link. exp is
+// optional: a Unix time after which the signature no longer holds
+const (
+	SignatureParam       = "sig"
+	SignatureExpiryParam = "exp"
+)
+
+// ErrInvalidLinkSignature means a visit to a signed link added parameters
+// without a valid, unexpired signature
+var ErrInvalidLinkSignature = newError(http.StatusForbidden, utils.ErrCodeLinkSignature, "invalid link signature", "The link's parameters are not validly signed, or the signature has expired")
+
+// newSigningKey generates the key the owner of a signed link signs with
+func newSigningKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// SignQuery is the signature of query for a link with signingKey: the hex
+// HMAC-SHA256 of the query string without sig, its parameters sorted by name
+func SignQuery(signingKey string, query url.Values) string {
+	signed := url.Values{}
+	for key, values := range query {
+		if key != SignatureParam {
+			signed[key] = values
+		}
+	}
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(signed.Encode()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifySignedQuery checks the parameters a visit adds to a signed link and
+// returns them without sig and exp, ready to merge into the destination
+// A visit adding no parameters needs no signature
+func verifySignedQuery(shortURL *models.ShortURL, query url.Values, now time.Time) (url.Values, error) {
+	if len(query) == 0 {
+		return query, nil
+	}
+	sig := query.Get(SignatureParam)
+	if sig == "" || !hmac.Equal([]byte(sig), []byte(SignQuery(shortURL.SigningKey, query))) {
+		return nil, ErrInvalidLinkSignature
+	}
+	if raw := query.Get(SignatureExpiryParam); raw != "" {
+		expiry, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || now.Unix() > expiry {
+			return nil, ErrInvalidLinkSignature
+		}
+	}
+	verified := url.Values{}
+	for key, values := range query {
+		if key != SignatureParam && key != SignatureExpiryParam {
+			verified[key] = values
+		}
+	}
+	return verified, nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+var ErrMailerDisabled = errors.New("email is not configured")
+
+// Mailer sends plain-text email through an SMTP relay
+type Mailer struct {
+	addr     string
+	from     string
+	username string
+	password string
+}
+
+// NewMailer returns a mailer for the relay at addr (host:port)
+// An empty addr disables sending
+func NewMailer(addr, username, password, from string) *Mailer {
+	return &Mailer{
+		addr:     addr,
+		from:     from,
+		username: username,
+		password: password,
+	}
+}
+
+// Enabled reports whether an SMTP relay is configured
+func (m *Mailer) Enabled() bool {
+	return m.addr != ""
+}
+
+// Send delivers one message to every recipient
+func (m *Mailer) Send(to []string, subject, body string) error {
+	if !m.Enabled() {
+		return ErrMailerDisabled
+	}
+	if len(to) == 0 {
+		return nil
+	}
+	var auth smtp.Auth
+	if m.username != "" {
+		host, _, err := net.SplitHostPort(m.addr)
+		if err != nil {
+			return fmt.Errorf("invalid SMTP address: %w", err)
+		}
+		auth = smtp.PlainAuth("", m.username, m.password, host)
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", m.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	if err := smtp.SendMail(m.addr, auth, m.from, to, []byte(msg.String())); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
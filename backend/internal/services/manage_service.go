@@ -0,0 +1,83 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+)
+
+var (
+	ErrManageDisabled     = newError(http.StatusServiceUnavailable, utils.ErrCodeManageDisabled, "management tokens are not configured", "Management tokens are not enabled")
+	ErrInvalidManageToken = newError(http.StatusUnauthorized, utils.ErrCodeInvalidManageToken, "invalid management token", "Invalid management token")
+)
+
+// ManageToken is the signed claim to an anonymous link, handed to whoever
+// created it. LinkID ties it to that link, so a code reused after the link
+// is gone can't be managed with it, and TokenID to the token ID stored on
+// the link, so rotating that revokes it
+type ManageToken struct {
+	Domain    string `json:"d,omitempty"`
+	ShortCode string `json:"c"`
+	LinkID    string `json:"id"`
+	TokenID   string `json:"t,omitempty"`
+}
+
+// ManageService issues and verifies HMAC-signed management tokens, which
+// let anonymous users see the stats of the links they made and delete them
+type ManageService struct {
+	signer tokenSigner
+}
+
+func NewManageService(secret string) *ManageService {
+	return &ManageService{
+		signer: newTokenSigner(secret),
+	}
+}
+
+// Enabled reports whether tokens are issued, which needs a secret
+func (s *ManageService) Enabled() bool {
+	return s.signer.enabled()
+}
+
+// IssueToken signs a token managing shortURL, under its stored token ID
+func (s *ManageService) IssueToken(shortURL *models.ShortURL) (string, error) {
+	if !s.Enabled() {
+		return "", ErrManageDisabled
+	}
+	token, err := s.signer.sign(ManageToken{
+		Domain:    shortURL.Domain,
+		ShortCode: shortURL.ShortCode,
+		LinkID:    shortURL.ID.Hex(),
+		TokenID:   shortURL.ManageTokenID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode management token: %w", err)
+	}
+	return token, nil
+}
+
+// VerifyToken checks the signature of a management token. Whether it was
+// revoked is up to the link's stored token ID, which GetManagedURL checks
+func (s *ManageService) VerifyToken(token string) (*ManageToken, error) {
+	if !s.Enabled() {
+		return nil, ErrManageDisabled
+	}
+	var claims ManageToken
+	if !s.signer.verify(token, &claims) || claims.ShortCode == "" || claims.LinkID == "" {
+		return nil, ErrInvalidManageToken
+	}
+	return &claims, nil
+}
+
+// newManageTokenID generates the token ID stored on an anonymous link
+func newManageTokenID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate management token ID: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
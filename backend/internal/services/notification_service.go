@@ -0,0 +1,282 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+)
+
+const (
+	// noticeBatchSize caps the links noticed per account and kind in one scan;
+	// the rest are picked up by the next scan
+	noticeBatchSize = 100
+	// noticeSendTimeout bounds the retries of one email
+	noticeSendTimeout = 30 * time.Second
+)
+
+var errNotificationsStopped = errors.New("notifications stopped")
+
+// noticeTemplates render the emails; each kind has a subject and a body
+var noticeTemplates = template.Must(template.New("notices").Parse(`
+{{- define "expiry_subject"}}Your short link {{.ShortURL}} expires in {{.DaysLeft}} day{{if ne .DaysLeft 1}}s{{end}}{{end}}
+{{- define "expiry_body"}}Hello,
+
+Your short link {{.ShortURL}}{{with .Title}} ("{{.}}"){{end}} expires on {{.ExpiresAt.UTC.Format "Monday, January 2, 2006 at 15:04 MST"}}.
+It points to {{.OriginalURL}}
+
+After that, visitors get an error instead of your destination. To keep it working, update the link with a new expiration.
+
+You receive this email because you asked to be warned {{.WarningDays}} days before your links expire.
+{{end}}
+{{- define "milestone_subject"}}Your short link {{.ShortURL}} reached {{.Milestone}} clicks{{end}}
+{{- define "milestone_body"}}Hello,
+
+Your short link {{.ShortURL}}{{with .Title}} ("{{.}}"){{end}} has been clicked {{.Clicks}} times, passing {{.Milestone}}.
+It points to {{.OriginalURL}}
+
+You receive this email because you asked to hear when your links reach {{.Milestone}} clicks.
+{{end}}`))
+
+// linkNotice is the data the notice templates render
+type linkNotice struct {
+	ShortURL    string
+	OriginalURL string
+	Title       string
+	ExpiresAt   time.Time
+	DaysLeft    int
+	WarningDays int
+	Milestone   int64
+	Clicks      int64
+}
+
+// email is one rendered message waiting for a worker
+type email struct {
+	to      string
+	subject string
+	body    string
+}
+
+// NotificationService emails link owners who opted in: a warning some days
+// before a link expires, and a note when a link's clicks pass a milestone.
+// A periodic scan finds the links due and claims each notice in MongoDB, so
+// every notice is sent once however many replicas scan; workers send the
+// queued emails through the mailer
+type NotificationService struct {
+	prefs    *repository.NotificationRepository
+	links    *repository.MongoRepository
+	mailer   *Mailer
+	baseURL  string
+	interval time.Duration
+	workers  int
+
+	queue   chan email
+	sending sync.WaitGroup
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+func NewNotificationService(prefs *repository.NotificationRepository, links *repository.MongoRepository, mailer *Mailer, baseURL string, interval time.Duration, workers, queueSize int) *NotificationService {
+	return &NotificationService{
+		prefs:    prefs,
+		links:    links,
+		mailer:   mailer,
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		interval: interval,
+		workers:  workers,
+		queue:    make(chan email, queueSize),
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+// GetPreferences returns the account's preferences; accounts that never set
+// any get empty ones
+func (s *NotificationService) GetPreferences(ctx context.Context, accountID string) (*models.NotificationPreferences, error) {
+	prefs, err := s.prefs.Get(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if prefs == nil {
+		prefs = &models.NotificationPreferences{AccountID: accountID}
+	}
+	if prefs.ClickMilestones == nil {
+		prefs.ClickMilestones = []int64{}
+	}
+	return prefs, nil
+}
+
+// SetPreferences replaces the account's preferences. Milestones are sorted
+// and deduplicated
+func (s *NotificationService) SetPreferences(ctx context.Context, prefs *models.NotificationPreferences) (*models.NotificationPreferences, error) {
+	milestones := slices.Clone(prefs.ClickMilestones)
+	slices.Sort(milestones)
+	prefs.ClickMilestones = slices.Compact(milestones)
+	if err := s.prefs.Upsert(ctx, prefs); err != nil {
+		return nil, err
+	}
+	if prefs.ClickMilestones == nil {
+		prefs.ClickMilestones = []int64{}
+	}
+	return prefs, nil
+}
+
+// Start scans right away and then every interval, and starts the workers
+// sending the emails, until Stop is called
+func (s *NotificationService) Start() {
+	for i := 0; i < s.workers; i++ {
+		s.sending.Add(1)
+		go func() {
+			defer s.sending.Done()
+			for msg := range s.queue {
+				s.send(msg)
+			}
+		}()
+	}
+	go func() {
+		defer close(s.stopped)
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			if err := s.Scan(context.Background()); err != nil && !errors.Is(err, errNotificationsStopped) {
+				log.Printf("Failed to scan for notifications: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the scan loop and waits for the queued emails to be sent
+func (s *NotificationService) Stop() {
+	close(s.stop)
+	<-s.stopped
+	close(s.queue)
+	s.sending.Wait()
+}
+
+// Scan queues the notices due for every subscribed account. Accounts whose
+// links can't be read are skipped until the next scan
+func (s *NotificationService) Scan(ctx context.Context) error {
+	now := time.Now()
+	return s.prefs.ForEachSubscribed(ctx, func(prefs *models.NotificationPreferences) error {
+		err := s.noticeExpiring(ctx, prefs, now)
+		if err == nil {
+			err = s.noticeMilestones(ctx, prefs)
+		}
+		if errors.Is(err, errNotificationsStopped) {
+			return err
+		}
+		if err != nil {
+			log.Printf("Failed to notify account %s: %v", prefs.AccountID, err)
+		}
+		return nil
+	})
+}
+
+// noticeExpiring warns about the account's links expiring within its warning days
+func (s *NotificationService) noticeExpiring(ctx context.Context, prefs *models.NotificationPreferences, now time.Time) error {
+	if prefs.ExpiryWarningDays <= 0 {
+		return nil
+	}
+	window := time.Duration(prefs.ExpiryWarningDays) * 24 * time.Hour
+	links, err := s.links.ListExpiring(ctx, prefs.AccountID, now, now.Add(window), noticeBatchSize)
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		claimed, err := s.links.ClaimExpiryNotice(ctx, link.Domain, link.ShortCode, *link.ExpiresAt)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+		notice := s.notice(link)
+		notice.ExpiresAt = *link.ExpiresAt
+		notice.DaysLeft = int(link.ExpiresAt.Sub(now).Hours()/24) + 1
+		notice.WarningDays = prefs.ExpiryWarningDays
+		if err := s.enqueue(prefs.Email, "expiry", notice); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// noticeMilestones tells the account about links that passed its milestones.
+// A link that passed several since the last scan gets one email, about the highest
+func (s *NotificationService) noticeMilestones(ctx context.Context, prefs *models.NotificationPreferences) error {
+	for i := len(prefs.ClickMilestones) - 1; i >= 0; i-- {
+		milestone := prefs.ClickMilestones[i]
+		links, err := s.links.ListReachedMilestone(ctx, prefs.AccountID, milestone, noticeBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, link := range links {
+			claimed, err := s.links.ClaimMilestone(ctx, link.Domain, link.ShortCode, milestone)
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				continue
+			}
+			notice := s.notice(link)
+			notice.Milestone = milestone
+			notice.Clicks = link.ClickCount
+			if err := s.enqueue(prefs.Email, "milestone", notice); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// notice fills in the fields every notice about link shares
+func (s *NotificationService) notice(link *models.ShortURL) linkNotice {
+	shortURL := s.baseURL + "/" + link.ShortCode
+	if link.Domain != "" {
+		shortURL = "https://" + link.Domain + "/" + link.ShortCode
+	}
+	return linkNotice{ShortURL: shortURL, OriginalURL: link.OriginalURL, Title: link.Title}
+}
+
+// enqueue renders a notice of kind and waits for room in the queue
+func (s *NotificationService) enqueue(to, kind string, notice linkNotice) error {
+	var subject, body strings.Builder
+	if err := noticeTemplates.ExecuteTemplate(&subject, kind+"_subject", notice); err != nil {
+		return fmt.Errorf("failed to render %s subject: %w", kind, err)
+	}
+	if err := noticeTemplates.ExecuteTemplate(&body, kind+"_body", notice); err != nil {
+		return fmt.Errorf("failed to render %s body: %w", kind, err)
+	}
+	select {
+	case s.queue <- email{to: to, subject: subject.String(), body: body.String()}:
+		return nil
+	case <-s.stop:
+		return errNotificationsStopped
+	}
+}
+
+// send delivers one email, retrying briefly when the relay fails
+func (s *NotificationService) send(msg email) {
+	ctx, cancel := context.WithTimeout(context.Background(), noticeSendTimeout)
+	defer cancel()
+	err := utils.RetryWithBackoff(ctx, time.Second, 10*time.Second, func(ctx context.Context) error {
+		return s.mailer.Send([]string{msg.to}, msg.subject, msg.body)
+	})
+	if err != nil {
+		log.Printf("Failed to send notification to %s: %v", msg.to, err)
+	}
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrOrganizationNotFound = newError(http.StatusNotFound, utils.ErrCodeOrganizationNotFound, "organization not found", "Organization not found")
+	ErrNotOrganizationAdmin = newError(http.StatusForbidden, utils.ErrCodeOrgAdminRequired, "organization admin role required", "Organization admin role required")
+	ErrInvalidRole          = newFieldError("role", validators.CodeInvalid, "invalid organization role", "role must be one of admin, editor, viewer")
+	ErrMemberNotFound       = newError(http.StatusNotFound, utils.ErrCodeMemberNotFound, "member not found", "Member not found")
+	ErrLastAdmin            = newError(http.StatusConflict, utils.ErrCodeLastOrgAdmin, "organization must keep at least one admin", "Organization must keep at least one admin")
+	ErrInvalidOrgName       = newFieldError("name", validators.CodeRequired, "organization name is required", "name is required")
+	ErrOrgAdminsRequired    = newFieldError("admins", validators.CodeRequired, "organization needs at least one admin", "admins must name at least one account")
+)
+
+// OrganizationService manages organizations, their members' roles and
+// the API keys issued to an organization's workspace
+type OrganizationService struct {
+	repo          *repository.OrganizationRepository
+	apiKeyService *APIKeyService
+}
+
+func NewOrganizationService(repo *repository.OrganizationRepository, apiKeyService *APIKeyService) *OrganizationService {
+	return &OrganizationService{
+		repo:          repo,
+		apiKeyService: apiKeyService,
+	}
+}
+
+// Create makes a new organization with accountID as its first admin
+func (s *OrganizationService) Create(ctx context.Context, accountID, name string) (*models.Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrInvalidOrgName
+	}
+	org := &models.Organization{Name: name, CreatedBy: accountID}
+	if err := s.repo.CreateOrganization(ctx, org); err != nil {
+		return nil, fmt.Errorf("failed to create organization: %w", err)
+	}
+	return org, nil
+}
+
+// Provision makes the organization provisioned as externalID have name and
+// at least the given admins, creating it if there is none yet; created
+// reports whether it did. Members added by other means are left alone
+func (s *OrganizationService) Provision(ctx context.Context, externalID, name string, admins []string) (*models.Organization, bool, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, false, ErrInvalidOrgName
+	}
+	if len(admins) == 0 {
+		return nil, false, ErrOrgAdminsRequired
+	}
+	org, err := s.repo.GetOrganizationByExternalID(ctx, externalID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to look up organization: %w", err)
+	}
+	created := false
+	if org == nil {
+		org = &models.Organization{Name: name, CreatedBy: admins[0], ExternalID: externalID}
+		err := s.repo.CreateOrganization(ctx, org)
+		switch {
+		case err == nil:
+			created = true
+		case errors.Is(err, repository.ErrDuplicate):
+			// A concurrent request created it first; bring that one in line
+			if org, err = s.repo.GetOrganizationByExternalID(ctx, externalID); err != nil {
+				return nil, false, fmt.Errorf("failed to look up organization: %w", err)
+			}
+			if org == nil {
+				return nil, false, ErrOrganizationNotFound
+			}
+		default:
+			return nil, false, fmt.Errorf("failed to create organization: %w", err)
+		}
+	}
+	if org.Name != name {
+		if err := s.repo.RenameOrganization(ctx, org.ID, name); err != nil {
+			return nil, false, fmt.Errorf("failed to rename organization: %w", err)
+		}
+		org.Name = name
+	}
+	for _, admin := range admins {
+		if _, err := s.repo.UpsertMembership(ctx, &models.Membership{OrgID: org.ID, AccountID: admin, Role: models.RoleAdmin}); err != nil {
+			return nil, false, fmt.Errorf("failed to save membership: %w", err)
+		}
+	}
+	return org, created, nil
+}
+
+// GetProvisioned returns the organization provisioned as externalID
+func (s *OrganizationService) GetProvisioned(ctx context.Context, externalID string) (*models.Organization, error) {
+	org, err := s.repo.GetOrganizationByExternalID(ctx, externalID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up organization: %w", err)
+	}
+	if org == nil {
+		return nil, ErrOrganizationNotFound
+	}
+	return org, nil
+}
+
+// List returns the organizations accountID belongs to
+func (s *OrganizationService) List(ctx context.Context, accountID string) ([]models.OrganizationWithRole, error) {
+	return s.repo.ListOrganizationsForAccount(ctx, accountID)
+}
+
+// Resolve returns the organization with id and accountID's role in it
+// Non-members get ErrOrganizationNotFound so they can't probe for IDs
+func (s *OrganizationService) Resolve(ctx context.Context, id, accountID string) (*models.Organization, string, error) {
+	orgID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, "", ErrOrganizationNotFound
+	}
+	membership, err := s.repo.GetMembership(ctx, orgID, accountID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to look up membership: %w", err)
+	}
+	if membership == nil {
+		return nil, "", ErrOrganizationNotFound
+	}
+	org, err := s.repo.GetOrganization(ctx, orgID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to look up organization: %w", err)
+	}
+	if org == nil {
+		return nil, "", ErrOrganizationNotFound
+	}
+	return org, membership.Role, nil
+}
+
+// WorkspaceAccount returns the account of organization id's workspace, for
+// handing it things; unlike Resolve it doesn't need a membership
+func (s *OrganizationService) WorkspaceAccount(ctx context.Context, id string) (string, error) {
+	orgID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return "", ErrOrganizationNotFound
+	}
+	org, err := s.repo.GetOrganization(ctx, orgID)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up organization: %w", err)
+	}
+	if org == nil {
+		return "", ErrOrganizationNotFound
+	}
+	return org.AccountID, nil
+}
+
+// Members lists the organization's members; any member may see them
+func (s *OrganizationService) Members(ctx context.Context, id, accountID string) ([]models.Membership, error) {
+	org, _, err := s.Resolve(ctx, id, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ListMemberships(ctx, org.ID)
+}
+
+// SetMember adds member to the organization or changes its role
+func (s *OrganizationService) SetMember(ctx context.Context, id, accountID, member, role string) (*models.Membership, error) {
+	if !models.ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	org, err := s.requireAdmin(ctx, id, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if role != models.RoleAdmin {
+		if err := s.keepAnAdmin(ctx, org.ID, member); err != nil {
+			return nil, err
+		}
+	}
+	membership, err := s.repo.UpsertMembership(ctx, &models.Membership{OrgID: org.ID, AccountID: member, Role: role})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save membership: %w", err)
+	}
+	return membership, nil
+}
+
+// RemoveMember takes member out of the organization
+func (s *OrganizationService) RemoveMember(ctx context.Context, id, accountID, member string) error {
+	org, err := s.requireAdmin(ctx, id, accountID)
+	if err != nil {
+		return err
+	}
+	if err := s.keepAnAdmin(ctx, org.ID, member); err != nil {
+		return err
+	}
+	removed, err := s.repo.DeleteMembership(ctx, org.ID, member)
+	if err != nil {
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+	if !removed {
+		return ErrMemberNotFound
+	}
+	return nil
+}
+
+// IssueKey creates an API key acting as the organization's workspace
+// The key is not tied to any member and survives membership changes
+func (s *OrganizationService) IssueKey(ctx context.Context, id, accountID, name string, scopes []string) (string, *models.APIKey, error) {
+	org, err := s.requireAdmin(ctx, id, accountID)
+	if err != nil {
+		return "", nil, err
+	}
+	return s.apiKeyService.CreateKey(ctx, org.AccountID, name, scopes, false)
+}
+
+// requireAdmin resolves the organization and checks accountID administers it
+func (s *OrganizationService) requireAdmin(ctx context.Context, id, accountID string) (*models.Organization, error) {
+	org, role, err := s.Resolve(ctx, id, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if role != models.RoleAdmin {
+		return nil, ErrNotOrganizationAdmin
+	}
+	return org, nil
+}
+
+// keepAnAdmin fails if member is the organization's only admin, since
+// demoting or removing it would leave nobody able to manage the organization
+func (s *OrganizationService) keepAnAdmin(ctx context.Context, orgID primitive.ObjectID, member string) error {
+	current, err := s.repo.GetMembership(ctx, orgID, member)
+	if err != nil {
+		return fmt.Errorf("failed to look up membership: %w", err)
+	}
+	if current == nil || current.Role != models.RoleAdmin {
+		return nil
+	}
+	admins, err := s.repo.CountAdmins(ctx, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to count admins: %w", err)
+	}
+	if admins <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	overviewCacheKey = "admin:overview"
+	// overviewCacheTTL is how long replicas share one computed overview
+	overviewCacheTTL = time.Minute
+	// overviewDays is how many days of link creation the overview reports
+	overviewDays = 30
+	// overviewTopLinks is how many of the most clicked links are listed
+	overviewTopLinks = 10
+)
+
+// OverviewService builds the admin dashboard summary. The aggregations scan
+// every link, so the result is cached in Redis and shared by the replicas
+type OverviewService struct {
+	links       *repository.MongoRepository
+	storage     *repository.StorageRepository
+	cache       *CacheMetrics
+	redisClient redis.UniversalClient
+}
+
+func NewOverviewService(links *repository.MongoRepository, storage *repository.StorageRepository, cache *CacheMetrics, redisClient redis.UniversalClient) *OverviewService {
+	return &OverviewService{
+		links:       links,
+		storage:     storage,
+		cache:       cache,
+		redisClient: redisClient,
+	}
+}
+
+// Overview returns the cached summary, computing it when it is older than a
+// minute. It is still served when Redis is unavailable, without caching or
+// cache metrics
+func (s *OverviewService) Overview(ctx context.Context) (*models.Overview, error) {
+	if raw, err := s.redisClient.Get(ctx, overviewCacheKey).Bytes(); err == nil {
+		var overview models.Overview
+		if err := json.Unmarshal(raw, &overview); err == nil {
+			return &overview, nil
+		}
+	} else if err != redis.Nil {
+		fmt.Printf("Failed to read cached overview: %v\n", err)
+	}
+
+	overview, err := s.compute(ctx)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(overview)
+	if err == nil {
+		err = s.redisClient.Set(ctx, overviewCacheKey, raw, overviewCacheTTL).Err()
+	}
+	if err != nil {
+		fmt.Printf("Failed to cache overview: %v\n", err)
+	}
+	return overview, nil
+}
+
+func (s *OverviewService) compute(ctx context.Context) (*models.Overview, error) {
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	since := today.AddDate(0, 0, 1-overviewDays)
+
+	links, err := s.links.Overview(ctx, since, overviewTopLinks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate links: %w", err)
+	}
+	storage, err := s.storage.Collections(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage stats: %w", err)
+	}
+	cache, err := s.cache.Stats(ctx)
+	if err != nil {
+		fmt.Printf("Failed to read cache metrics: %v\n", err)
+	}
+
+	overview := &models.Overview{
+		GeneratedAt: now,
+		Links: models.LinkTotals{
+			Total:         links.Total,
+			Archived:      links.Archived,
+			Trashed:       links.Trashed,
+			CreatedPerDay: make([]models.DailyCount, overviewDays),
+		},
+		Redirects: models.RedirectTotals{
+			Total: links.Human + links.Bot,
+			Human: links.Human,
+			Bot:   links.Bot,
+		},
+		TopLinks: links.TopLinks,
+		Storage:  storage,
+		Cache:    cache,
+	}
+	for i := range overview.Links.CreatedPerDay {
+		day := since.AddDate(0, 0, i).Format("2006-01-02")
+		count := links.CreatedOn[day]
+		overview.Links.CreatedPerDay[i] = models.DailyCount{Day: day, Count: count}
+		overview.Links.CreatedLast30Days += count
+		if i >= overviewDays-7 {
+			overview.Links.CreatedLast7Days += count
+		}
+	}
+	if overview.TopLinks == nil {
+		overview.TopLinks = []models.TopLink{}
+	}
+	return overview, nil
+}
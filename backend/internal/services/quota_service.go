@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrDailyQuotaExceeded means the API key created its daily allowance of links
+	ErrDailyQuotaExceeded = newError(http.StatusTooManyRequests, utils.ErrCodeDailyQuotaExceeded, "daily link quota exceeded", "Daily link quota exceeded")
+	// ErrMonthlyQuotaExceeded means the API key's plan allows no more links this month
+	ErrMonthlyQuotaExceeded = newError(http.StatusPaymentRequired, utils.ErrCodeMonthlyQuotaExceeded, "monthly link quota exceeded", "Monthly link quota exceeded; upgrade the plan to create more links")
+	ErrUnknownPlan          = errors.New("unknown quota plan")
+)
+
+const (
+	usageFieldLinksCreated = "links_created"
+	usageFieldActiveLinks  = "active_links"
+	usageFieldEventBytes   = "event_bytes"
+	usageFieldLinksPrefix  = "links:"
+	usageFieldClicksPrefix = "clicks:"
+)
+
+// QuotaService maintains per-account usage counters in Redis
+// Each account has one hash; monthly counters use a "<counter>:YYYY-MM" field
+// It also enforces the link creation quotas of API keys, which come from the
+// key's plan unless the key overrides them
+type QuotaService struct {
+	redisClient        redis.UniversalClient
+	linksPerMonthLimit int64
+	// defaultQuota applies to keys without a plan
+	defaultQuota models.LinkQuota
+	plans        map[string]models.LinkQuota
+}
+
+func NewQuotaService(redisClient redis.UniversalClient, linksPerMonthLimit, linksPerDayLimit int64, plans map[string]models.LinkQuota) *QuotaService {
+	return &QuotaService{
+		redisClient:        redisClient,
+		linksPerMonthLimit: linksPerMonthLimit,
+		defaultQuota:       models.LinkQuota{Daily: linksPerDayLimit, Monthly: linksPerMonthLimit},
+		plans:              plans,
+	}
+}
+
+// CreationQuota is the allowance a link creation is charged against. State
+// is filled in by the charge, whether or not it was allowed
+type CreationQuota struct {
+	Key   *models.APIKey
+	State *QuotaState
+	// charged are the counters a successful charge incremented
+	charged []string
+}
+
+// QuotaState is what is left of the tightest limit a charge counted against
+type QuotaState struct {
+	Limit     int64
+	Remaining int64
+	// Reset is when the limit's period ends and its counter starts over
+	Reset time.Time
+}
+
+// chargeScript counts one creation against the day and month counters,
+// unless either is at its limit (0 is unlimited). It returns 0, 1 (daily
+// limit reached) or 2 (monthly limit reached) and both counters
+var chargeScript = redis.NewScript(`
+local daily = tonumber(redis.call('GET', KEYS[1]) or '0')
+local monthly = tonumber(redis.call('GET', KEYS[2]) or '0')
+if tonumber(ARGV[1]) > 0 and daily >= tonumber(ARGV[1]) then
+	return {1, daily, monthly}
+end
+if tonumber(ARGV[2]) > 0 and monthly >= tonumber(ARGV[2]) then
+	return {2, daily, monthly}
+end
+daily = redis.call('INCR', KEYS[1])
+redis.call('EXPIREAT', KEYS[1], ARGV[3])
+monthly = redis.call('INCR', KEYS[2])
+redis.call('EXPIREAT', KEYS[2], ARGV[4])
+return {0, daily, monthly}
+`)
+
+// ValidPlan reports whether plan is a configured quota plan; empty is the default plan
+func (s *QuotaService) ValidPlan(plan string) bool {
+	_, ok := s.plans[plan]
+	return plan == "" || ok
+}
+
+// LinkQuota returns the creation limits that apply to key
+func (s *QuotaService) LinkQuota(key *models.APIKey) models.LinkQuota {
+	if key.LinkQuota != nil {
+		return *key.LinkQuota
+	}
+	if quota, ok := s.plans[key.Plan]; ok {
+		return quota
+	}
+	return s.defaultQuota
+}
+
+// ChargeLinkCreation counts one link against quota's key, failing with
+// ErrDailyQuotaExceeded or ErrMonthlyQuotaExceeded when a limit is reached.
+// Counters are per UTC day and month, so they reset on their own
+func (s *QuotaService) ChargeLinkCreation(ctx context.Context, quota *CreationQuota) error {
+	limits := s.LinkQuota(quota.Key)
+	if limits.Daily <= 0 && limits.Monthly <= 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	dayEnd := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	monthEnd := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	subject := quota.Key.QuotaSubject()
+	keys := []string{creationKey(subject, now.Format("2006-01-02")), creationKey(subject, now.Format("2006-01"))}
+	// Counters outlive their period by a day so a late refund can't recreate them
+	result, err := chargeScript.Run(ctx, s.redisClient, keys, limits.Daily, limits.Monthly,
+		dayEnd.Add(24*time.Hour).Unix(), monthEnd.Add(24*time.Hour).Unix()).Int64Slice()
+	if err != nil {
+		return fmt.Errorf("failed to charge link quota: %w", err)
+	}
+	outcome, daily, monthly := result[0], result[1], result[2]
+
+	// Report whichever limit has fewer links left
+	var states []QuotaState
+	if limits.Daily > 0 {
+		states = append(states, QuotaState{Limit: limits.Daily, Remaining: max(limits.Daily-daily, 0), Reset: dayEnd})
+	}
+	if limits.Monthly > 0 {
+		states = append(states, QuotaState{Limit: limits.Monthly, Remaining: max(limits.Monthly-monthly, 0), Reset: monthEnd})
+	}
+	state := states[0]
+	for _, other := range states[1:] {
+		if other.Remaining < state.Remaining {
+			state = other
+		}
+	}
+	switch outcome {
+	case 1:
+		quota.State = &QuotaState{Limit: limits.Daily, Remaining: 0, Reset: dayEnd}
+		return ErrDailyQuotaExceeded
+	case 2:
+		quota.State = &QuotaState{Limit: limits.Monthly, Remaining: 0, Reset: monthEnd}
+		return ErrMonthlyQuotaExceeded
+	}
+	quota.State = &state
+	quota.charged = keys
+	return nil
+}
+
+// RefundLinkCreation gives back a charge for a link that wasn't created after all
+func (s *QuotaService) RefundLinkCreation(ctx context.Context, quota *CreationQuota) error {
+	if len(quota.charged) == 0 {
+		return nil
+	}
+	pipe := s.redisClient.Pipeline()
+	for _, key := range quota.charged {
+		pipe.Decr(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	quota.charged = nil
+	quota.State.Remaining = min(quota.State.Remaining+1, quota.State.Limit)
+	return nil
+}
+
+// RecordLinkCreated counts a new link against the account
+func (s *QuotaService) RecordLinkCreated(ctx context.Context, accountID string) error {
+	pipe := s.redisClient.TxPipeline()
+	key := usageKey(accountID)
+	pipe.HIncrBy(ctx, key, usageFieldLinksCreated, 1)
+	pipe.HIncrBy(ctx, key, usageFieldActiveLinks, 1)
+	pipe.HIncrBy(ctx, key, usageFieldLinksPrefix+currentPeriod(), 1)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// RecordLinkDeactivated removes a link from the account's active count
+func (s *QuotaService) RecordLinkDeactivated(ctx context.Context, accountID string) error {
+	return s.redisClient.HIncrBy(ctx, usageKey(accountID), usageFieldActiveLinks, -1).Err()
+}
+
+// RecordLinkRestored adds a link taken out of the trash back to the active count
+func (s *QuotaService) RecordLinkRestored(ctx context.Context, accountID string) error {
+	return s.redisClient.HIncrBy(ctx, usageKey(accountID), usageFieldActiveLinks, 1).Err()
+}
+
+// RecordClick counts a redirect against the account for the current month
+func (s *QuotaService) RecordClick(ctx context.Context, accountID string) error {
+	return s.redisClient.HIncrBy(ctx, usageKey(accountID), usageFieldClicksPrefix+currentPeriod(), 1).Err()
+}
+
+// RecordEventStorage adds the size of a stored analytics event to the account
+func (s *QuotaService) RecordEventStorage(ctx context.Context, accountID string, bytes int64) error {
+	return s.redisClient.HIncrBy(ctx, usageKey(accountID), usageFieldEventBytes, bytes).Err()
+}
+
+// Usage returns the account's current counters and remaining monthly link quota
+func (s *QuotaService) Usage(ctx context.Context, accountID string) (*models.AccountUsage, error) {
+	fields, err := s.redisClient.HGetAll(ctx, usageKey(accountID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read usage counters: %w", err)
+	}
+
+	period := currentPeriod()
+	counter := func(field string) int64 {
+		n, _ := strconv.ParseInt(fields[field], 10, 64)
+		return n
+	}
+	usage := &models.AccountUsage{
+		AccountID:         accountID,
+		Period:            period,
+		LinksCreated:      counter(usageFieldLinksCreated),
+		LinksThisMonth:    counter(usageFieldLinksPrefix + period),
+		ActiveLinks:       counter(usageFieldActiveLinks),
+		ClicksThisMonth:   counter(usageFieldClicksPrefix + period),
+		EventStorageBytes: counter(usageFieldEventBytes),
+		QuotaLimit:        s.linksPerMonthLimit,
+	}
+	usage.QuotaRemaining = usage.QuotaLimit - usage.LinksThisMonth
+	if usage.QuotaRemaining < 0 {
+		usage.QuotaRemaining = 0
+	}
+	return usage, nil
+}
+
+// creationKey names the key's link creation counter for period, a UTC day or
+// month. It is hash tagged by subject, so the day and month counters the
+// charge script updates together share a cluster slot
+func creationKey(subject, period string) string {
+	return "quota:links:{" + subject + "}:" + period
+}
+
+func usageKey(accountID string) string {
+	return "usage:" + accountID
+}
+
+func currentPeriod() string {
+	return time.Now().UTC().Format("2006-01")
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/events"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// RateLimitResult is the state of a subject's current rate limit window
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int64
+	Remaining int64
+	Reset     time.Time
+}
+
+// RateLimiter is a fixed-window request limiter backed by Redis counters
+// API keys that cross warnThreshold of their window trigger a one-off warning webhook
+type RateLimiter struct {
+	name          string
+	redisClient   redis.UniversalClient
+	webhooks      *WebhookService
+	limit         int64
+	window        time.Duration
+	warnThreshold float64
+}
+
+// NewRateLimiter creates a limiter whose counters are namespaced by name so
+// several limiters can share one Redis
+func NewRateLimiter(name string, redisClient redis.UniversalClient, webhooks *WebhookService, limit int64, window time.Duration, warnThreshold float64) *RateLimiter {
+	return &RateLimiter{
+		name:          name,
+		redisClient:   redisClient,
+		webhooks:      webhooks,
+		limit:         limit,
+		window:        window,
+		warnThreshold: warnThreshold,
+	}
+}
+
+// Allow counts one request for subject (an API key or client IP)
+// key is the authenticated API key, or nil for anonymous requests
+func (l *RateLimiter) Allow(ctx context.Context, subject string, key *models.APIKey) (*RateLimitResult, error) {
+	now := time.Now()
+	windowStart := now.Truncate(l.window)
+	reset := windowStart.Add(l.window)
+	counterKey := fmt.Sprintf("ratelimit:%s:%s:%d", l.name, subject, windowStart.Unix())
+
+	pipe := l.redisClient.TxPipeline()
+	incr := pipe.Incr(ctx, counterKey)
+	pipe.ExpireAt(ctx, counterKey, reset.Add(time.Second))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to count request: %w", err)
+	}
+
+	used := incr.Val()
+	result := &RateLimitResult{
+		Allowed:   used <= l.limit,
+		Limit:     l.limit,
+		Remaining: l.limit - used,
+		Reset:     reset,
+	}
+	if result.Remaining < 0 {
+		result.Remaining = 0
+	}
+
+	if key != nil && float64(used) >= float64(l.limit)*l.warnThreshold {
+		l.warnOnce(ctx, counterKey, key, used, reset)
+	}
+	return result, nil
+}
+
+// warnOnce emits a rate_limit.warning event the first time a window crosses the threshold
+func (l *RateLimiter) warnOnce(ctx context.Context, counterKey string, key *models.APIKey, used int64, reset time.Time) {
+	first, err := l.redisClient.SetNX(ctx, counterKey+":warned", 1, time.Until(reset)+time.Second).Result()
+	if err != nil || !first {
+		return
+	}
+	l.webhooks.Emit(events.TypeRateLimitWarning, events.RateLimitWarning{
+		AccountID: key.AccountID,
+		KeyPrefix: key.Prefix,
+		Limit:     l.limit,
+		Used:      used,
+		ResetAt:   reset.UTC(),
+	})
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// Rollout flags gate changes to the redirect path
+const (
+	// RolloutLocalCache serves links from the in-process LRU
+	RolloutLocalCache = "local_cache"
+)
+
+// rolloutBuckets is the granularity of a rollout percentage
+const rolloutBuckets = 100
+
+// Rollouts turns flags on for a configured percentage of short codes. Each
+// code hashes to a fixed bucket per flag, so a link always takes the same
+// path on every replica and raising the percentage only adds links
+// Redirect outcomes are counted per flag and arm, buffered in memory and
+// added to Redis every interval so the arms can be compared
+type Rollouts struct {
+	redisClient redis.UniversalClient
+	percentages map[string]int
+	interval    time.Duration
+
+	mu      sync.Mutex
+	pending map[rolloutArmKey]rolloutCounts
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+type rolloutArmKey struct {
+	flag string
+	on   bool
+}
+
+type rolloutCounts struct {
+	requests  int64
+	errors    int64
+	latencyUs int64
+}
+
+func NewRollouts(redisClient redis.UniversalClient, percentages map[string]int, interval time.Duration) *Rollouts {
+	return &Rollouts{
+		redisClient: redisClient,
+		percentages: percentages,
+		interval:    interval,
+		pending:     make(map[rolloutArmKey]rolloutCounts),
+		stop:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+}
+
+// Enabled reports whether flag is on for the link; flags that aren't
+// configured are off
+func (r *Rollouts) Enabled(flag, domain, shortCode string) bool {
+	percent, ok := r.percentages[flag]
+	if !ok || percent <= 0 {
+		return false
+	}
+	if percent >= rolloutBuckets {
+		return true
+	}
+	return rolloutBucket(flag, domain, shortCode) < percent
+}
+
+// rolloutBucket hashes the link into [0, rolloutBuckets); the flag is part of
+// the hash so different flags pick different links
+func rolloutBucket(flag, domain, shortCode string) int {
+	h := fnv.New32a()
+	h.Write([]byte(flag + "|" + domain + "/" + shortCode))
+	return int(h.Sum32() % rolloutBuckets)
+}
+
+// Observe counts one redirect of the link against the arm of every flag it
+// fell into
+func (r *Rollouts) Observe(domain, shortCode string, latency time.Duration, failed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for flag := range r.percentages {
+		key := rolloutArmKey{flag: flag, on: r.Enabled(flag, domain, shortCode)}
+		counts := r.pending[key]
+		counts.requests++
+		if failed {
+			counts.errors++
+		}
+		counts.latencyUs += latency.Microseconds()
+		r.pending[key] = counts
+	}
+}
+
+// Start runs the flush loop until Stop is called
+func (r *Rollouts) Start() {
+	go func() {
+		defer close(r.stopped)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-r.stop:
+				return
+			}
+			if err := r.Flush(context.Background()); err != nil {
+				log.Printf("Failed to flush rollout metrics: %v", err)
+			}
+		}
+	}()
+}
+
+// Stop ends the flush loop and writes whatever is still buffered
+func (r *Rollouts) Stop(ctx context.Context) error {
+	close(r.stop)
+	<-r.stopped
+	return r.Flush(ctx)
+}
+
+// Flush adds the buffered counts to Redis; on failure they are put back so
+// the next flush retries them
+func (r *Rollouts) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	batch := r.pending
+	r.pending = make(map[rolloutArmKey]rolloutCounts)
+	r.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+	// One transaction per flag, since a Redis cluster only runs
+	// transactions over keys in one slot
+	byFlag := make(map[string][]rolloutArmKey)
+	for key := range batch {
+		byFlag[key.flag] = append(byFlag[key.flag], key)
+	}
+	var failed error
+	for flag, keys := range byFlag {
+		pipe := r.redisClient.TxPipeline()
+		for _, key := range keys {
+			counts := batch[key]
+			arm := rolloutArm(key.on)
+			pipe.HIncrBy(ctx, rolloutMetricsKey(flag), arm+":requests", counts.requests)
+			pipe.HIncrBy(ctx, rolloutMetricsKey(flag), arm+":errors", counts.errors)
+			pipe.HIncrBy(ctx, rolloutMetricsKey(flag), arm+":latency_us", counts.latencyUs)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			r.mu.Lock()
+			for _, key := range keys {
+				counts := batch[key]
+				merged := r.pending[key]
+				merged.requests += counts.requests
+				merged.errors += counts.errors
+				merged.latencyUs += counts.latencyUs
+				r.pending[key] = merged
+			}
+			r.mu.Unlock()
+			failed = err
+		}
+	}
+	return failed
+}
+
+// Reports compares the arms of every configured flag, sorted by flag
+func (r *Rollouts) Reports(ctx context.Context) ([]models.RolloutReport, error) {
+	flags := make([]string, 0, len(r.percentages))
+	for flag := range r.percentages {
+		flags = append(flags, flag)
+	}
+	sort.Strings(flags)
+
+	pipe := r.redisClient.Pipeline()
+	results := make([]*redis.MapStringStringCmd, len(flags))
+	for i, flag := range flags {
+		results[i] = pipe.HGetAll(ctx, rolloutMetricsKey(flag))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to read rollout metrics: %w", err)
+	}
+	reports := make([]models.RolloutReport, len(flags))
+	for i, flag := range flags {
+		fields := results[i].Val()
+		reports[i] = models.RolloutReport{
+			Flag:       flag,
+			Percentage: r.percentages[flag],
+			On:         rolloutArmReport(fields, rolloutArm(true)),
+			Off:        rolloutArmReport(fields, rolloutArm(false)),
+		}
+	}
+	return reports, nil
+}
+
+func rolloutArmReport(fields map[string]string, arm string) models.RolloutArm {
+	var requests, errors, latencyUs int64
+	fmt.Sscan(fields[arm+":requests"], &requests)
+	fmt.Sscan(fields[arm+":errors"], &errors)
+	fmt.Sscan(fields[arm+":latency_us"], &latencyUs)
+	report := models.RolloutArm{Requests: requests, Errors: errors}
+	if requests > 0 {
+		report.ErrorRate = float64(errors) / float64(requests)
+		report.AvgLatencyMs = float64(latencyUs) / float64(requests) / 1000
+	}
+	return report
+}
+
+func rolloutArm(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+func rolloutMetricsKey(flag string) string {
+	return "rollout:" + flag
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+)
+
+var ErrShadowBanNotFound = newError(http.StatusNotFound, utils.ErrCodeNotShadowBanned, "account is not shadow-banned", "Account is not shadow-banned")
+
+// ShadowBanService manages shadow bans. Links a banned account creates or
+// edits resolve only for that account (its API keys or the IPs it worked
+// from) and look like unknown codes to everyone else
+type ShadowBanService struct {
+	repo     *repository.ShadowBanRepository
+	linkRepo *repository.MongoRepository
+	// ips anonymizes the creator IPs kept on links, and visitor IPs alike
+	// before they are compared
+	ips *IPAnonymizer
+}
+
+func NewShadowBanService(repo *repository.ShadowBanRepository, linkRepo *repository.MongoRepository, ips *IPAnonymizer) *ShadowBanService {
+	return &ShadowBanService{
+		repo:     repo,
+		linkRepo: linkRepo,
+		ips:      ips,
+	}
+}
+
+// Ban shadow-bans accountID; banning it again updates the reason
+func (s *ShadowBanService) Ban(ctx context.Context, accountID, reason string) (*models.ShadowBan, error) {
+	accountID = strings.TrimSpace(accountID)
+	if accountID == "" {
+		return nil, errors.New("account ID is required")
+	}
+	ban, err := s.repo.Upsert(ctx, &models.ShadowBan{AccountID: accountID, Reason: reason})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save shadow ban: %w", err)
+	}
+	return ban, nil
+}
+
+// Unban lifts the ban and makes the account's shadowed links public
+func (s *ShadowBanService) Unban(ctx context.Context, accountID string) error {
+	found, err := s.repo.Delete(ctx, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to delete shadow ban: %w", err)
+	}
+	if !found {
+		return ErrShadowBanNotFound
+	}
+	if _, err := s.linkRepo.ClearShadow(ctx, accountID); err != nil {
+		return fmt.Errorf("failed to release shadowed links: %w", err)
+	}
+	return nil
+}
+
+// List returns every shadow ban, newest first
+func (s *ShadowBanService) List(ctx context.Context) ([]models.ShadowBan, error) {
+	return s.repo.List(ctx)
+}
+
+// AccessFor returns the restriction to put on a link accountID creates or
+// edits from clientIP, or nil when the account isn't banned
+// Lookup failures are logged and treated as not banned
+func (s *ShadowBanService) AccessFor(ctx context.Context, accountID, clientIP string) *models.ShadowAccess {
+	if accountID == "" {
+		return nil
+	}
+	ban, err := s.repo.Get(ctx, accountID)
+	if err != nil {
+		fmt.Printf("Failed to check shadow ban: %v\n", err)
+		return nil
+	}
+	if ban == nil {
+		return nil
+	}
+	access := &models.ShadowAccess{}
+	if clientIP != "" {
+		access.CreatorIPs = []string{s.ips.Anonymize(clientIP)}
+	}
+	return access
+}
+
+// Hides reports whether shortURL is shadowed from visit, so it must look
+// like an unknown code
+func (s *ShadowBanService) Hides(shortURL *models.ShortURL, visit Visit) bool {
+	return shortURL.Shadow != nil && !shortURL.Shadow.Allows(shortURL.OwnerID, visit.AccountID, s.ips.Anonymize(visit.IP))
+}
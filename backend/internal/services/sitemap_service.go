@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+// maxSitemapURLs is the sitemaps.org limit for a single sitemap file
+const maxSitemapURLs = 50000
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// SitemapService builds XML sitemaps of the indexable links on each branded
+// domain. A worker regenerates them periodically into Redis so every instance
+// serves the same copy
+type SitemapService struct {
+	domains     *DomainService
+	linkRepo    *repository.MongoRepository
+	redisClient redis.UniversalClient
+	interval    time.Duration
+	stop        chan struct{}
+}
+
+func NewSitemapService(domains *DomainService, linkRepo *repository.MongoRepository, redisClient redis.UniversalClient, interval time.Duration) *SitemapService {
+	return &SitemapService{
+		domains:     domains,
+		linkRepo:    linkRepo,
+		redisClient: redisClient,
+		interval:    interval,
+		stop:        make(chan struct{}),
+	}
+}
+
+// Start regenerates every domain's sitemap each interval until Stop is called
+func (s *SitemapService) Start() {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			if err := s.RegenerateAll(context.Background()); err != nil {
+				log.Printf("Failed to regenerate sitemaps: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the regeneration worker
+func (s *SitemapService) Stop() {
+	close(s.stop)
+}
+
+// RegenerateAll rebuilds the sitemap of every registered domain
+func (s *SitemapService) RegenerateAll(ctx context.Context) error {
+	domains, err := s.domains.AllDomains(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list domains: %w", err)
+	}
+	for i := range domains {
+		if _, err := s.regenerate(ctx, &domains[i]); err != nil {
+			log.Printf("Failed to regenerate sitemap for %s: %v", domains[i].Host, err)
+		}
+	}
+	return nil
+}
+
+// Get returns the sitemap for a request's Host header, or nil when the host
+// is not a registered domain. A sitemap not generated yet is built on demand
+func (s *SitemapService) Get(ctx context.Context, host string) ([]byte, error) {
+	domain, err := s.domains.Resolve(ctx, host)
+	if err != nil || domain == nil {
+		return nil, err
+	}
+	cached, err := s.redisClient.Get(ctx, sitemapKey(domain.Host)).Bytes()
+	if err == nil {
+		return cached, nil
+	}
+	if err != redis.Nil {
+		log.Printf("Failed to read cached sitemap: %v", err)
+	}
+	return s.regenerate(ctx, domain)
+}
+
+func (s *SitemapService) regenerate(ctx context.Context, domain *models.Domain) ([]byte, error) {
+	links, err := s.linkRepo.ListIndexable(ctx, domain.Host, maxSitemapURLs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexable links: %w", err)
+	}
+	set := sitemapURLSet{XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	for _, link := range links {
+		lastMod := link.CreatedAt
+		if link.UpdatedAt != nil {
+			lastMod = *link.UpdatedAt
+		}
+		set.URLs = append(set.URLs, sitemapURL{
+			Loc:     fmt.Sprintf("https://%s/%s", domain.Host, link.ShortCode),
+			LastMod: lastMod.UTC().Format("2006-01-02"),
+		})
+	}
+	body, err := xml.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode sitemap: %w", err)
+	}
+	body = append([]byte(xml.Header), body...)
+	// Keep the copy past the next regeneration so a slow run never leaves a gap
+	if err := s.redisClient.Set(ctx, sitemapKey(domain.Host), body, 2*s.interval).Err(); err != nil {
+		log.Printf("Failed to cache sitemap: %v", err)
+	}
+	return body, nil
+}
+
+func sitemapKey(host string) string {
+	return "sitemap:" + host
+}
@@ -0,0 +1,439 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+	"golang.org/x/net/html"
+)
+
+// maxPageBytes bounds how much of a destination page is read looking for its
+// metadata, and maxRobotsBytes how much of a site's robots.txt
+const (
+	maxPageBytes   = 1 << 20
+	maxRobotsBytes = 512 << 10
+)
+
+// titleFetchAgent identifies the fetcher to destination sites; robots.txt
+// groups naming titleFetchToken apply to it
+const (
+	titleFetchToken = "url-shortener-title-fetcher"
+	titleFetchAgent = titleFetchToken + "/1.0"
+)
+
+var (
+	// ErrPrivateAddress means a fetch was refused because the host resolved to
+	// an address that isn't publicly routable
+	ErrPrivateAddress = errors.New("destination resolves to a private address")
+	// ErrRobotsDisallowed means the site's robots.txt doesn't let the fetcher in
+	ErrRobotsDisallowed = errors.New("destination disallows fetching in robots.txt")
+)
+
+// PageMetadata is what a destination page says about itself
+type PageMetadata struct {
+	Title       string
+	Description string
+}
+
+// TitleFetcher reads the title and description of destination pages for
+// links created with fetch_title. Fetches run in the background on a fixed
+// pool of workers fed by a bounded queue, so a slow site never holds up link
+// creation; when the queue is full the fetch is skipped. Connections are only
+// made to public addresses, so a link can't be used to probe the internal
+// network, and pages a site's robots.txt disallows are left alone
+type TitleFetcher struct {
+	client *http.Client
+	// robotsClient reads robots.txt; it doesn't check robots.txt on redirects
+	robotsClient *http.Client
+	robots       *lruCache[robotsRules]
+	workers      int
+
+	queue   chan titleJob
+	dropped atomic.Int64
+	running sync.WaitGroup
+	stop    chan struct{}
+}
+
+type titleJob struct {
+	pageURL string
+	done    func(context.Context, PageMetadata)
+}
+
+func NewTitleFetcher(timeout time.Duration, workers, queueSize int) *TitleFetcher {
+	f := &TitleFetcher{
+		client:       publicHTTPClient(timeout),
+		robotsClient: publicHTTPClient(timeout),
+		robots:       newLRUCache[robotsRules](1000, time.Hour),
+		workers:      workers,
+		queue:        make(chan titleJob, queueSize),
+		stop:         make(chan struct{}),
+	}
+	// Every hop of a redirect chain must be open to the fetcher too
+	limit := f.client.CheckRedirect
+	f.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if err := limit(req, via); err != nil {
+			return err
+		}
+		return f.checkRobots(req.Context(), req.URL)
+	}
+	return f
+}
+
+// publicHTTPClient makes requests to destinations, refusing to connect to
+// addresses that aren't publicly routable and following up to 5 redirects
+func publicHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		// Checked after DNS resolution, so a public name pointing at an
+		// internal address is refused too
+		Control: func(_, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublicAddr(addrPort.Addr()) {
+				return ErrPrivateAddress
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			return nil
+		},
+	}
+}
+
+// Start runs the workers until Stop is called
+func (f *TitleFetcher) Start() {
+	for i := 0; i < f.workers; i++ {
+		f.running.Add(1)
+		go func() {
+			defer f.running.Done()
+			for {
+				select {
+				case job := <-f.queue:
+					f.run(job)
+				case <-f.stop:
+					return
+				}
+			}
+		}()
+	}
+	f.running.Add(1)
+	go func() {
+		defer f.running.Done()
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if n := f.dropped.Swap(0); n > 0 {
+					log.Printf("Skipped %d title fetches in the last minute because the queue was full", n)
+				}
+			case <-f.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the workers once their current fetches finish. Queued fetches
+// are dropped; links keep whatever metadata they have
+func (f *TitleFetcher) Stop() {
+	close(f.stop)
+	f.running.Wait()
+}
+
+// Enqueue fetches pageURL's metadata in the background and hands it to done,
+// unless the page can't be read or has neither a title nor a description
+func (f *TitleFetcher) Enqueue(pageURL string, done func(context.Context, PageMetadata)) {
+	select {
+	case <-f.stop:
+	case f.queue <- titleJob{pageURL: pageURL, done: done}:
+	default:
+		if f.dropped.Add(1) == 1 {
+			log.Printf("Title fetch queue full, skipping fetches")
+		}
+	}
+}
+
+func (f *TitleFetcher) run(job titleJob) {
+	ctx := context.Background()
+	meta, err := f.Fetch(ctx, job.pageURL)
+	if err != nil {
+		log.Printf("Failed to fetch title of %s: %v", job.pageURL, err)
+		return
+	}
+	if meta.Title != "" || meta.Description != "" {
+		job.done(ctx, meta)
+	}
+}
+
+// Fetch returns the title and description of the HTML page at pageURL,
+// whitespace collapsed and cut to the lengths links accept
+func (f *TitleFetcher) Fetch(ctx context.Context, pageURL string) (PageMetadata, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return PageMetadata{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	if err := f.checkRobots(ctx, req.URL); err != nil {
+		return PageMetadata{}, err
+	}
+	req.Header.Set("Accept", "text/html")
+	req.Header.Set("User-Agent", titleFetchAgent)
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return PageMetadata{}, fmt.Errorf("failed to fetch page: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return PageMetadata{}, fmt.Errorf("failed to fetch page: %s", resp.Status)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/html" {
+		return PageMetadata{}, fmt.Errorf("page is %q, not HTML", mediaType)
+	}
+	return pageMetadata(io.LimitReader(resp.Body, maxPageBytes)), nil
+}
+
+// checkRobots returns ErrRobotsDisallowed unless the robots.txt of target's
+// site allows the fetcher to read target. Rules are cached per site for an hour
+func (f *TitleFetcher) checkRobots(ctx context.Context, target *url.URL) error {
+	site := target.Scheme + "://" + target.Host
+	rules, ok := f.robots.Get(site)
+	if !ok {
+		rules = f.fetchRobots(ctx, site)
+		f.robots.Add(site, rules)
+	}
+	path := target.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if target.RawQuery != "" {
+		path += "?" + target.RawQuery
+	}
+	if !rules.allows(path) {
+		return ErrRobotsDisallowed
+	}
+	return nil
+}
+
+// fetchRobots reads site's rules for the fetcher. As RFC 9309 asks, a
+// missing robots.txt allows everything and an unreachable one nothing
+func (f *TitleFetcher) fetchRobots(ctx context.Context, site string) robotsRules {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, site+"/robots.txt", nil)
+	if err != nil {
+		return robotsRules{disallowAll: true}
+	}
+	req.Header.Set("User-Agent", titleFetchAgent)
+	resp, err := f.robotsClient.Do(req)
+	if err != nil {
+		return robotsRules{disallowAll: true}
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsBytes))
+		if err != nil {
+			return robotsRules{disallowAll: true}
+		}
+		return parseRobots(string(body), titleFetchToken)
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return robotsRules{}
+	default:
+		return robotsRules{disallowAll: true}
+	}
+}
+
+// robotsRules are the Allow and Disallow lines of the robots.txt group that
+// applies to the fetcher
+type robotsRules struct {
+	allow       []robotsRule
+	disallow    []robotsRule
+	disallowAll bool
+}
+
+// robotsRule is one path pattern. '*' matches any run of characters and a
+// trailing '$' anchors the end of the path; longer patterns are more specific
+type robotsRule struct {
+	length  int
+	pattern *regexp.Regexp
+}
+
+func newRobotsRule(path string) robotsRule {
+	anchored := strings.HasSuffix(path, "$")
+	expr := regexp.QuoteMeta(strings.TrimSuffix(path, "$"))
+	expr = "^" + strings.ReplaceAll(expr, `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return robotsRule{length: len(path), pattern: regexp.MustCompile(expr)}
+}
+
+// allows applies the most specific matching rule to path; on a tie Allow wins
+func (r robotsRules) allows(path string) bool {
+	if r.disallowAll {
+		return false
+	}
+	longest := func(rules []robotsRule) int {
+		best := -1
+		for _, rule := range rules {
+			if rule.length > best && rule.pattern.MatchString(path) {
+				best = rule.length
+			}
+		}
+		return best
+	}
+	disallowed := longest(r.disallow)
+	return disallowed < 0 || longest(r.allow) >= disallowed
+}
+
+// parseRobots returns the rules of the groups naming token, or of the '*'
+// groups when none does
+func parseRobots(body, token string) robotsRules {
+	var named, wildcard robotsRules
+	var foundNamed bool
+	var agents []string
+	inRules := false
+	for _, line := range strings.Split(body, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			// A user-agent line after rules starts a new group
+			if inRules {
+				agents = nil
+				inRules = false
+			}
+			agents = append(agents, strings.ToLower(value))
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				continue // an empty Disallow allows everything
+			}
+			for _, agent := range agents {
+				target := &wildcard
+				if agent == token {
+					target = &named
+					foundNamed = true
+				} else if agent != "*" {
+					continue
+				}
+				if key == "allow" {
+					target.allow = append(target.allow, newRobotsRule(value))
+				} else {
+					target.disallow = append(target.disallow, newRobotsRule(value))
+				}
+			}
+		default:
+			// Other lines, such as Sitemap, don't end the group
+		}
+	}
+	if foundNamed {
+		return named
+	}
+	return wildcard
+}
+
+// pageMetadata returns the text of the first <title> element and the
+// content of the description meta tag, falling back to their Open Graph
+// equivalents. Only the page's <head> is read
+func pageMetadata(r io.Reader) PageMetadata {
+	var meta PageMetadata
+	var ogTitle, ogDescription string
+	tokenizer := html.NewTokenizer(r)
+head:
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+		name, hasAttr := tokenizer.TagName()
+		switch string(name) {
+		case "title":
+			if meta.Title == "" && tokenizer.Next() == html.TextToken {
+				meta.Title = cleanText(string(tokenizer.Text()), validators.MaxTitleLength)
+			}
+		case "meta":
+			attrs := map[string]string{}
+			for hasAttr {
+				var key, value []byte
+				key, value, hasAttr = tokenizer.TagAttr()
+				attrs[string(key)] = string(value)
+			}
+			content := attrs["content"]
+			switch {
+			case strings.EqualFold(attrs["name"], "description") && meta.Description == "":
+				meta.Description = cleanText(content, validators.MaxDescriptionLength)
+			case attrs["property"] == "og:title" && ogTitle == "":
+				ogTitle = cleanText(content, validators.MaxTitleLength)
+			case attrs["property"] == "og:description" && ogDescription == "":
+				ogDescription = cleanText(content, validators.MaxDescriptionLength)
+			}
+		case "body", "svg":
+			// Metadata only counts in <head>; an <svg> has its own title
+			break head
+		}
+	}
+	if meta.Title == "" {
+		meta.Title = ogTitle
+	}
+	if meta.Description == "" {
+		meta.Description = ogDescription
+	}
+	return meta
+}
+
+// cleanText collapses whitespace in text and cuts it to max characters
+func cleanText(text string, max int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	return string([]rune(text)[:max])
+}
+
+// isPublicAddr reports whether addr is a publicly routable unicast address
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// sharedAddressSpace is carrier-grade NAT space (RFC 6598), which
+// IsPrivate doesn't cover
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
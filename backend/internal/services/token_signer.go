@@ -0,0 +1,55 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// tokenSigner issues the stateless tokens of management and embed widget
+// claims: the claims as base64url JSON, a dot, and their base64url
+// HMAC-SHA256 signature
+type tokenSigner struct {
+	secret []byte
+}
+
+func newTokenSigner(secret string) tokenSigner {
+	return tokenSigner{secret: []byte(secret)}
+}
+
+// enabled reports whether tokens can be signed, which needs a secret
+func (s tokenSigner) enabled() bool {
+	return len(s.secret) > 0
+}
+
+// sign encodes claims as JSON and signs them
+func (s tokenSigner) sign(claims any) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.mac(encoded), nil
+}
+
+// verify decodes the claims of a validly signed token into claims and
+// reports whether it could
+func (s tokenSigner) verify(token string, claims any) bool {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.mac(encoded))) {
+		return false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(payload, claims) == nil
+}
+
+func (s tokenSigner) mac(encoded string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
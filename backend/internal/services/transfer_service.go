@@ -0,0 +1,266 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrTransferNotFound = newError(http.StatusNotFound, utils.ErrCodeTransferNotFound, "transfer not found", "Transfer not found")
+	// ErrTransferRecipient means the recipient is missing or is the link's owner
+	ErrTransferRecipient = newFieldError("to_account", validators.CodeInvalid, "invalid transfer recipient", "the recipient must be another account or organization")
+	// ErrTransferPending means the link already has an open transfer
+	ErrTransferPending = newError(http.StatusConflict, utils.ErrCodeTransferPending, "link already has a pending transfer", "Link already has a pending transfer")
+	// ErrTransferClosed means the transfer was already accepted, declined,
+	// cancelled or has expired
+	ErrTransferClosed = newError(http.StatusConflict, utils.ErrCodeTransferClosed, "transfer is no longer pending", "Transfer is no longer pending")
+	// ErrTransferDomain means the recipient can't use the link's domain
+	ErrTransferDomain = newError(http.StatusConflict, utils.ErrCodeTransferDomain, "recipient can't use the link's domain", "The recipient has no verified domain for this link")
+)
+
+// Transfer directions, as seen by the account listing them
+const (
+	TransfersIncoming = "incoming"
+	TransfersOutgoing = "outgoing"
+)
+
+// TransferOffer is a request to hand a link to another account or to an
+// organization's workspace
+type TransferOffer struct {
+	Domain    string
+	ShortCode string
+	// From owns the link and OfferedBy is the caller acting for it
+	From      string
+	OfferedBy string
+	// ToAccount or ToOrganization names the recipient
+	ToAccount      string
+	ToOrganization string
+}
+
+// TransferService hands links between accounts and organizations. The
+// owner offers a link, and it only changes hands once the recipient
+// accepts; until then the owner may cancel and the recipient decline.
+// Every step is written to the link's audit trail
+type TransferService struct {
+	repo          *repository.TransferRepository
+	auditRepo     *repository.AuditRepository
+	urlService    *URLService
+	domainService *DomainService
+	orgService    *OrganizationService
+	// offerTTL is how long the recipient has to accept
+	offerTTL time.Duration
+}
+
+func NewTransferService(repo *repository.TransferRepository, auditRepo *repository.AuditRepository, urlService *URLService, domainService *DomainService, orgService *OrganizationService, offerTTL time.Duration) *TransferService {
+	return &TransferService{
+		repo:          repo,
+		auditRepo:     auditRepo,
+		urlService:    urlService,
+		domainService: domainService,
+		orgService:    orgService,
+		offerTTL:      offerTTL,
+	}
+}
+
+// Offer opens a transfer of one of offer.From's links
+func (s *TransferService) Offer(ctx context.Context, offer TransferOffer) (*models.LinkTransfer, error) {
+	to := offer.ToAccount
+	if offer.ToOrganization != "" {
+		account, err := s.orgService.WorkspaceAccount(ctx, offer.ToOrganization)
+		if err != nil {
+			return nil, err
+		}
+		to = account
+	}
+	if to == "" || to == offer.From {
+		return nil, ErrTransferRecipient
+	}
+	link, err := s.urlService.GetOwnedLink(ctx, offer.From, offer.Domain, offer.ShortCode)
+	if err != nil {
+		return nil, err
+	}
+	if link.DeletedAt != nil {
+		return nil, ErrURLNotFound
+	}
+	if err := s.checkDomain(ctx, to, link.Domain); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := s.repo.ExpirePending(ctx, link.Domain, link.ShortCode, now); err != nil {
+		return nil, transferError(err)
+	}
+	transfer := &models.LinkTransfer{
+		Domain:      link.Domain,
+		ShortCode:   link.ShortCode,
+		FromAccount: offer.From,
+		ToAccount:   to,
+		OfferedBy:   offer.OfferedBy,
+		Status:      models.TransferPending,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.offerTTL),
+	}
+	if err := s.repo.Create(ctx, transfer); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, ErrTransferPending
+		}
+		return nil, transferError(err)
+	}
+	s.recordAudit(ctx, offer.From, transfer, models.AuditActionTransferOffered, now)
+	return transfer, nil
+}
+
+// Accept moves the link to the recipient. accountID must be the recipient:
+// the account itself or, for an organization, a member acting in its workspace
+func (s *TransferService) Accept(ctx context.Context, accountID, caller string, id primitive.ObjectID) (*models.LinkTransfer, error) {
+	transfer, err := s.open(ctx, id, func(t *models.LinkTransfer) bool { return t.ToAccount == accountID })
+	if err != nil {
+		return nil, err
+	}
+	// The recipient may have lost the domain since the offer
+	if err := s.checkDomain(ctx, transfer.ToAccount, transfer.Domain); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	accepted, err := s.repo.Resolve(ctx, id, models.TransferPending, models.TransferAccepted, caller, now)
+	if err != nil {
+		return nil, transferError(err)
+	}
+	_, err = s.urlService.TransferOwnership(ctx, transfer.Domain, transfer.ShortCode, transfer.FromAccount, transfer.ToAccount)
+	if err != nil {
+		// The owner deleted the link meanwhile: the offer is void. Otherwise
+		// it stays open to be accepted again
+		status := models.TransferPending
+		if errors.Is(err, ErrURLNotFound) {
+			status = models.TransferCancelled
+		}
+		if _, reopenErr := s.repo.Resolve(ctx, id, models.TransferAccepted, status, caller, now); reopenErr != nil {
+			fmt.Printf("Failed to reopen transfer %s: %v\n", id.Hex(), reopenErr)
+		}
+		return nil, err
+	}
+	s.recordAudit(ctx, accountID, accepted, models.AuditActionTransfer, now)
+	return accepted, nil
+}
+
+// Decline turns the transfer down; accountID must be the recipient
+func (s *TransferService) Decline(ctx context.Context, accountID, caller string, id primitive.ObjectID) (*models.LinkTransfer, error) {
+	return s.close(ctx, id, caller, models.TransferDeclined, models.AuditActionTransferDeclined, accountID,
+		func(t *models.LinkTransfer) bool { return t.ToAccount == accountID })
+}
+
+// Cancel withdraws the offer; accountID must be the link's owner
+func (s *TransferService) Cancel(ctx context.Context, accountID, caller string, id primitive.ObjectID) (*models.LinkTransfer, error) {
+	return s.close(ctx, id, caller, models.TransferCancelled, models.AuditActionTransferCancelled, accountID,
+		func(t *models.LinkTransfer) bool { return t.FromAccount == accountID })
+}
+
+// List returns one page of the transfers offered to accountID, or with
+// TransfersOutgoing those it offered, newest first
+func (s *TransferService) List(ctx context.Context, accountID, direction string, page repository.PageRequest) (*repository.Page[models.LinkTransfer], error) {
+	var result *repository.Page[models.LinkTransfer]
+	var err error
+	if direction == TransfersOutgoing {
+		result, err = s.repo.ListBySender(ctx, accountID, page)
+	} else {
+		result, err = s.repo.ListByRecipient(ctx, accountID, page)
+	}
+	if err != nil {
+		return nil, transferError(err)
+	}
+	now := time.Now()
+	for i := range result.Items {
+		markExpired(&result.Items[i], now)
+	}
+	return result, nil
+}
+
+func (s *TransferService) close(ctx context.Context, id primitive.ObjectID, caller, status, action, accountID string, party func(*models.LinkTransfer) bool) (*models.LinkTransfer, error) {
+	if _, err := s.open(ctx, id, party); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	closed, err := s.repo.Resolve(ctx, id, models.TransferPending, status, caller, now)
+	if err != nil {
+		return nil, transferError(err)
+	}
+	s.recordAudit(ctx, accountID, closed, action, now)
+	return closed, nil
+}
+
+// open loads a pending transfer that party allows the caller to act on.
+// Transfers the caller isn't part of are reported as not found
+func (s *TransferService) open(ctx context.Context, id primitive.ObjectID, party func(*models.LinkTransfer) bool) (*models.LinkTransfer, error) {
+	transfer, err := s.repo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrTransferNotFound
+		}
+		return nil, transferError(err)
+	}
+	if !party(transfer) {
+		return nil, ErrTransferNotFound
+	}
+	if !transfer.Open(time.Now()) {
+		return nil, ErrTransferClosed
+	}
+	return transfer, nil
+}
+
+// checkDomain ensures to may have a link on host. Links on the default
+// domain can go anywhere; a branded domain must be verified by the recipient
+func (s *TransferService) checkDomain(ctx context.Context, to, host string) error {
+	if _, err := s.domainService.CheckUsable(ctx, to, host); err != nil {
+		if errors.Is(err, ErrUnknownDomain) || errors.Is(err, ErrDomainNotVerified) {
+			return ErrTransferDomain
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *TransferService) recordAudit(ctx context.Context, accountID string, transfer *models.LinkTransfer, action string, at time.Time) {
+	entry := &models.AuditEntry{
+		Domain:    transfer.Domain,
+		ShortCode: transfer.ShortCode,
+		AccountID: accountID,
+		Action:    action,
+		Changes: map[string]models.AuditChange{
+			"owner_id": {From: transfer.FromAccount, To: transfer.ToAccount},
+		},
+		At: at,
+	}
+	if err := s.auditRepo.CreateEntry(ctx, entry); err != nil {
+		fmt.Printf("Failed to write audit entry: %v\n", err)
+	}
+}
+
+// markExpired reports a pending transfer past its time as expired, whether
+// or not it was marked so yet
+func markExpired(transfer *models.LinkTransfer, now time.Time) {
+	if transfer.Status == models.TransferPending && !transfer.Open(now) {
+		transfer.Status = models.TransferExpired
+	}
+}
+
+// transferError maps repository errors: a transfer that couldn't be closed
+// was closed by someone else first
+func transferError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrTransferClosed
+	case errors.Is(err, repository.ErrUnavailable):
+		return ErrServiceUnavailable
+	default:
+		return fmt.Errorf("failed to update transfer: %w", err)
+	}
+}
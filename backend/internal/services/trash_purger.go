@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+)
+
+// TrashPurger permanently removes links that have been in the trash longer
+// than the retention period, freeing their short codes. Replicas may purge at
+// the same time; deleting is idempotent, so they don't coordinate
+type TrashPurger struct {
+	repo      *repository.MongoRepository
+	retention time.Duration
+	interval  time.Duration
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+func NewTrashPurger(repo *repository.MongoRepository, retention, interval time.Duration) *TrashPurger {
+	return &TrashPurger{
+		repo:      repo,
+		retention: retention,
+		interval:  interval,
+		stop:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+}
+
+// Start purges right away and then every interval until Stop is called
+func (p *TrashPurger) Start() {
+	go func() {
+		defer close(p.stopped)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			if err := p.Purge(context.Background()); err != nil {
+				log.Printf("Failed to purge trashed links: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the purge loop
+func (p *TrashPurger) Stop() {
+	close(p.stop)
+	<-p.stopped
+}
+
+// Purge deletes every link trashed more than the retention period ago
+func (p *TrashPurger) Purge(ctx context.Context) error {
+	deleted, err := p.repo.PurgeTrashed(ctx, time.Now().Add(-p.retention))
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		log.Printf("Purged %d links from the trash", deleted)
+	}
+	return nil
+}
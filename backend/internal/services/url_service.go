@@ -4,90 +4,1992 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
+	"reflect"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/events"
 	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
 	"github.com/ranjanshahajishitole/url-shortener/backend/internal/repository"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/tracing"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/utils"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 var (
-	ErrInvalidURL  = errors.New("invalid URL")
-	ErrURLNotFound = errors.New("URL not found")
-	ErrURLExpired  = errors.New("URL expired")
-	ErrURLInactive = errors.New("URL is inactive")
+	ErrInvalidURL   = newFieldError("url", validators.CodeInvalidURL, "invalid URL", "Invalid URL")
+	ErrURLNotFound  = newError(http.StatusNotFound, utils.ErrCodeURLNotFound, "URL not found", "Short URL not found")
+	ErrURLExpired   = newError(http.StatusGone, utils.ErrCodeURLExpired, "URL expired", "URL has expired")
+	ErrURLInactive  = newError(http.StatusGone, utils.ErrCodeURLInactive, "URL is inactive", "URL is inactive")
+	ErrInvalidAlias = newFieldError("alias", validators.CodeInvalidAlias, "invalid alias", "Alias must be 3-32 letters, digits, '-' or '_' and not a reserved word")
+	ErrAliasTaken   = newError(http.StatusConflict, utils.ErrCodeAliasTaken, "alias already taken", "Alias is already taken")
+	// ErrClickLimitReached means the link used up its max_clicks
+	ErrClickLimitReached = newError(http.StatusGone, utils.ErrCodeClickLimitReached, "click limit reached", "URL has reached its click limit")
+	ErrInvalidMaxClicks  = newFieldError("max_clicks", validators.CodeOutOfRange, "max_clicks must be positive", "")
+	// ErrURLNotAllowed wraps the reason a URL fails the account's URL policy
+	ErrURLNotAllowed = newFieldError("url", validators.CodeURLNotAllowed, "URL not allowed", "")
+	// ErrInvalidSchedule wraps why a link's activation window was rejected
+	ErrInvalidSchedule = newFieldError("deactivate_at", validators.CodeInvalid, "invalid schedule", "")
+	// ErrInvalidFallbackURL wraps why a link's fallback_url was rejected
+	ErrInvalidFallbackURL = newFieldError("fallback_url", validators.CodeURLNotAllowed, "invalid fallback URL", "")
+	// ErrInvalidIPAccess wraps why a link's ip_access lists were rejected
+	ErrInvalidIPAccess = newFieldError("ip_access", validators.CodeInvalidCIDR, "invalid IP access lists", "")
+	// ErrInvalidRateLimit wraps why a link's rate_limit was rejected
+	ErrInvalidRateLimit = newFieldError("rate_limit", validators.CodeInvalid, "invalid rate limit", "")
+	// ErrLinkRateLimited means the link has used up its rate_limit for the
+	// current window and has no retry URL
+	ErrLinkRateLimited = newError(http.StatusTooManyRequests, utils.ErrCodeLinkRateLimited, "link rate limit reached", "This link has been used as often as allowed for now; try again later")
+	// ErrIPBlocked means the link's ip_access lists don't admit the visitor
+	ErrIPBlocked = newError(http.StatusForbidden, utils.ErrCodeIPBlocked, "IP address not allowed", "Access to this link is not allowed from your network")
+	// ErrServiceUnavailable means the database is unreachable or its circuit breaker is open
+	ErrServiceUnavailable = newError(http.StatusServiceUnavailable, utils.ErrCodeServiceUnavailable, "service temporarily unavailable", "Service temporarily unavailable")
 )
 
+// maxCodeAttempts bounds retries when a generated code collides with an existing one
+const maxCodeAttempts = 3
+
+// maxIPRanges bounds each of a link's ip_access lists, which are checked on every redirect
+const maxIPRanges = 100
+
+// ShortenOptions carries the optional settings for a new short URL
+type ShortenOptions struct {
+	OwnerID   string
+	Domain    string
+	Alias     string
+	ExpiresIn *time.Duration
+	Title     string
+	// FetchTitle fills an empty Title, and the description, from the
+	// destination page in the background after the link is created
+	FetchTitle  bool
+	Notes       string
+	DeviceRules *models.DeviceRules
+	UTM         *models.UTMParams
+	Indexable   bool
+	NoIndex     bool // Redirects send X-Robots-Tag: noindex
+	Honeytoken  bool
+	MaxClicks   int64
+	// EdgeCacheTTL overrides the default CDN cache time in seconds; 0 disables it
+	EdgeCacheTTL *int64
+	// CacheMaxAge overrides the default browser cache time in seconds; 0 disables it
+	CacheMaxAge *int64
+	// CampaignID adds the link to a campaign the caller owns
+	CampaignID string
+	// Variants split traffic between destinations instead of OriginalURL
+	Variants []models.Variant
+	// ActivateAt and DeactivateAt bound when the link redirects
+	ActivateAt   *time.Time
+	DeactivateAt *time.Time
+	// FallbackURL is where visitors go while the link doesn't redirect:
+	// outside its schedule, expired, deactivated or out of clicks
+	FallbackURL string
+	// IPAccess limits which visitor addresses the link redirects
+	IPAccess *models.IPAccess
+	// RateLimit caps the link's redirects per hour or day
+	RateLimit *models.LinkRateLimit
+	// Cloak serves visitors an HTML page instead of a redirect
+	Cloak string
+	// ForwardQuery passes the query string of each visit on to the destination
+	ForwardQuery bool
+	// SignedQuery forwards only parameters signed with the link's signing key
+	SignedQuery bool
+	// RedirectStatus overrides the redirect's status code; 0 keeps the default
+	RedirectStatus int
+	// Privacy keeps only the aggregate click count, recording no click events
+	Privacy bool
+	// SingleUse links redirect once and then turn themselves off
+	SingleUse bool
+	ClientIP  string
+	// Quota, when set, is charged for the link; reusing an existing link is free
+	Quota *CreationQuota
+	// Created, when set, is set to whether a new link was made rather than an
+	// existing one reused
+	Created *bool
+}
+
+// customized reports whether the link differs from a plain shortening of the
+// URL, in which case an existing short code for the same URL can't be reused
+func (o ShortenOptions) customized() bool {
+	return o.Alias != "" || o.Notes != "" || o.DeviceRules != nil || o.UTM != nil || o.Indexable || o.NoIndex || o.Honeytoken || o.MaxClicks > 0 || o.EdgeCacheTTL != nil || o.CacheMaxAge != nil || o.CampaignID != "" || len(o.Variants) > 0 ||
+		o.ActivateAt != nil || o.DeactivateAt != nil || o.FallbackURL != "" || o.IPAccess != nil || o.RateLimit != nil || o.Cloak != "" || o.ForwardQuery || o.SignedQuery || o.RedirectStatus != 0 || o.Privacy || o.SingleUse
+}
+
+// Visit carries the request details the redirect path uses to pick a destination
+type Visit struct {
+	UserAgent string
+	IP        string
+	Referrer  string
+	Country   string
+	City      string
+	// Bot marks crawlers and clients that failed the JS challenge
+	Bot bool
+	// Uncounted visits are redirected without recording a click, or using
+	// up one of a click-limited link's redirects
+	Uncounted bool
+	// AccountID is set when the visitor sent a valid API key
+	AccountID string
+	// Variant is the A/B variant the visitor was given before, from its cookie
+	Variant string
+	// URL and Headers describe the request for honeytoken alerts
+	URL     string
+	Headers http.Header
+	// Query is the visit's query string, forwarded by links that ask for it
+	Query url.Values
+}
+
+// UpdateOptions is the new state of a link's editable fields (PUT semantics)
+// A nil ExpiresIn removes the expiration
+type UpdateOptions struct {
+	OriginalURL string
+	ExpiresIn   *time.Duration
+	Title       string
+	Notes       string
+	Indexable   bool
+	NoIndex     bool
+	// EdgeCacheTTL overrides the default CDN cache time in seconds; nil restores the default
+	EdgeCacheTTL *int64
+	// CacheMaxAge overrides the default browser cache time in seconds; nil restores the default
+	CacheMaxAge *int64
+	// Variants replaces the A/B split; nil removes it
+	Variants []models.Variant
+	// ActivateAt, DeactivateAt and FallbackURL replace the schedule; nil
+	// removes either end
+	ActivateAt   *time.Time
+	DeactivateAt *time.Time
+	FallbackURL  string
+	// IPAccess replaces the visitor address lists; nil removes them. With
+	// KeepIPAccess they are left as they are
+	IPAccess     *models.IPAccess
+	KeepIPAccess bool
+	// RateLimit replaces the redirect cap; nil removes it. With KeepRateLimit
+	// it is left as it is
+	RateLimit     *models.LinkRateLimit
+	KeepRateLimit bool
+	// Cloak replaces the cloaking mode; empty redirects normally
+	Cloak        string
+	ForwardQuery bool
+	// SignedQuery true keeps the link's signing key or generates one, false
+	// removes it and nil leaves it as it is
+	SignedQuery    *bool
+	RedirectStatus int
+	// Privacy turns privacy mode on or off; nil leaves it as it is
+	Privacy  *bool
+	ClientIP string
+}
+
+// Fields a LinkPatch can change
+const (
+	PatchOriginalURL = "url"
+	PatchTitle       = "title"
+	PatchNotes       = "notes"
+	PatchTags        = "tags"
+	PatchExpiresIn   = "expires_in"
+	PatchIsActive    = "is_active"
+)
+
+// LinkPatch is a partial update of a link: only the fields named in Fields,
+// one of the Patch* names each, change. Zero values clear a field, and a nil
+// ExpiresIn removes the expiration
+type LinkPatch struct {
+	Fields      []string
+	OriginalURL string
+	// ClientIP is the editor's address, which shadow bans apply to when the
+	// destination changes
+	ClientIP  string
+	Title     string
+	Notes     string
+	Tags      []string
+	ExpiresIn *time.Duration
+	IsActive  bool
+}
+
+// Redirect is where a visit goes and how long CDNs and browsers may cache the response
+type Redirect struct {
+	URL string
+	// Variant is the A/B variant the visitor was sent to, if the link splits traffic
+	Variant      string
+	EdgeCacheTTL time.Duration
+	MaxAge       time.Duration
+	SurrogateKey string
+	// Cloak is the link's cloaking mode, and Title what a cloaked page is titled
+	Cloak string
+	Title string
+	// Status is the link's redirect status, or 0 for the default
+	Status int
+	// NoIndex asks search engines not to index the short URL
+	NoIndex bool
+	// RetryAt is when a link refusing visits with ErrLinkRateLimited
+	// redirects again
+	RetryAt time.Time
+}
+
+// Statuses a resolved link can have
+const (
+	ResolveActive     = "active"
+	ResolveExpired    = "expired"
+	ResolveInactive   = "inactive"
+	ResolveClickLimit = "click_limit_reached"
+	// ResolveLimited links redirect a limited number of times, so resolving
+	// them would show the destination without using up a click
+	ResolveLimited  = "click_limited"
+	ResolveBlocked  = "blocked"
+	ResolveNotFound = "not_found"
+)
+
+// Resolution is what a redirect of a code would do, without following it
+type Resolution struct {
+	Code   string
+	Status string
+	// URL is the destination of an active link, or the fallback URL a link
+	// that doesn't redirect sends visitors to
+	URL string
+}
+
 type URLService struct {
-	repo       *repository.MongoRepository
-	keyService *KeyService
+	repo             *repository.MongoRepository
+	auditRepo        *repository.AuditRepository
+	keyService       *KeyService
+	quotaService     *QuotaService
+	analyticsService *AnalyticsService
+	linkCache        *LinkCache
+	clicks           *ClickAggregator
+	domainService    *DomainService
+	leaderboards     *LeaderboardService
+	shadowBans       *ShadowBanService
+	honeytokens      *HoneytokenService
+	edgeCache        *EdgeCache
+	rateLimits       *LinkRateLimiter
+	titles           *TitleFetcher
+	stream           *EventStream
+	// codes answers lookups of codes that were never created; nil when disabled
+	codes *CodeFilter
+	// redirects serves the redirect path ahead of MongoDB; nil when redirects
+	// read MongoDB directly
+	redirects repository.LinkStore
+	// trashRetention is how long deleted links can be restored
+	trashRetention time.Duration
+	// rehydrateArchived moves archived links back to the hot collection when
+	// they are visited
+	rehydrateArchived bool
+	urlPolicies       *validators.URLPolicies
+	destinations      *DestinationRules
+	normalizer        validators.URLNormalizer
+	reserved          *validators.ReservedWords
+	// selfHosts serve this shortener; links may not point back at them
+	selfHosts map[string]struct{}
+	// sandbox marks the service Sandbox returns
+	sandbox bool
 }
 
-func NewURLService(repo *repository.MongoRepository, keyService *KeyService) *URLService {
+func NewURLService(repo *repository.MongoRepository, auditRepo *repository.AuditRepository, keyService *KeyService, quotaService *QuotaService, analyticsService *AnalyticsService, linkCache *LinkCache, clicks *ClickAggregator, domainService *DomainService, leaderboards *LeaderboardService, shadowBans *ShadowBanService, honeytokens *HoneytokenService, edgeCache *EdgeCache, rateLimits *LinkRateLimiter, titles *TitleFetcher, stream *EventStream, codes *CodeFilter, redirects repository.LinkStore, trashRetention time.Duration, rehydrateArchived bool, urlPolicies *validators.URLPolicies, destinations *DestinationRules, normalizer validators.URLNormalizer, reserved *validators.ReservedWords, selfHosts []string) *URLService {
+	hosts := make(map[string]struct{}, len(selfHosts))
+	for _, host := range selfHosts {
+		hosts[strings.ToLower(host)] = struct{}{}
+	}
 	return &URLService{
-		repo:       repo,
-		keyService: keyService,
+		repo:              repo,
+		auditRepo:         auditRepo,
+		keyService:        keyService,
+		quotaService:      quotaService,
+		analyticsService:  analyticsService,
+		linkCache:         linkCache,
+		clicks:            clicks,
+		domainService:     domainService,
+		leaderboards:      leaderboards,
+		shadowBans:        shadowBans,
+		honeytokens:       honeytokens,
+		edgeCache:         edgeCache,
+		rateLimits:        rateLimits,
+		titles:            titles,
+		stream:            stream,
+		codes:             codes,
+		redirects:         redirects,
+		trashRetention:    trashRetention,
+		rehydrateArchived: rehydrateArchived,
+		urlPolicies:       urlPolicies,
+		destinations:      destinations,
+		normalizer:        normalizer,
+		reserved:          reserved,
+		selfHosts:         hosts,
 	}
 }
 
-func (s *URLService) ShortenURL(ctx context.Context, originalURL string, expiresIn *time.Duration) (*models.ShortURL, error) {
-	if !isValidURL(originalURL) {
-		return nil, ErrInvalidURL
+// SandboxTTL is the longest a sandbox link lives
+const SandboxTTL = 24 * time.Hour
+
+// Sandbox returns a service for the links created with sandbox API keys,
+// stored in repo instead of the production collection. Sandbox links expire
+// within SandboxTTL and are left out of the caches, the redirect store,
+// quotas, analytics, the audit log and the event stream
+func (s *URLService) Sandbox(repo *repository.MongoRepository) *URLService {
+	sandbox := *s
+	sandbox.repo = repo
+	sandbox.codes = nil
+	sandbox.redirects = nil
+	sandbox.stream = &EventStream{}
+	sandbox.sandbox = true
+	return &sandbox
+}
+
+// sandboxExpiry caps a sandbox link's expiration at SandboxTTL after now
+func sandboxExpiry(now time.Time, expiresAt *time.Time) *time.Time {
+	limit := now.Add(SandboxTTL)
+	if expiresAt != nil && expiresAt.Before(limit) {
+		return expiresAt
+	}
+	return &limit
+}
+
+func (s *URLService) ShortenURL(ctx context.Context, originalURL string, opts ShortenOptions) (shortURL *models.ShortURL, err error) {
+	ctx, span := tracing.Start(ctx, "URLService.ShortenURL")
+	defer func() { tracing.End(span, failure(err)) }()
+	if s.sandbox {
+		opts.Quota = nil
 	}
-	existing, _ := s.repo.GetShortURLByOriginal(ctx, originalURL)
-	if existing != nil {
-		return existing, nil
+	if originalURL, err = s.normalize(originalURL); err != nil {
+		return nil, err
 	}
-	shortCode, err := s.keyService.GetShortCode(ctx)
+	if err := s.checkURL(ctx, opts.OwnerID, originalURL); err != nil {
+		return nil, err
+	}
+	if rules := opts.DeviceRules; rules != nil {
+		for _, target := range []string{rules.IOS, rules.Android, rules.Desktop} {
+			if target == "" {
+				continue
+			}
+			if err := s.checkURL(ctx, opts.OwnerID, target); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := s.checkVariants(ctx, opts.OwnerID, opts.Variants, opts.DeviceRules); err != nil {
+		return nil, err
+	}
+	if err := s.checkSchedule(ctx, opts.OwnerID, opts.ActivateAt, opts.DeactivateAt, opts.FallbackURL); err != nil {
+		return nil, err
+	}
+	if err := s.checkRateLimit(ctx, opts.OwnerID, opts.RateLimit); err != nil {
+		return nil, err
+	}
+	ipAccess, err := normalizeIPAccess(opts.IPAccess)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate short code: %w", err)
+		return nil, err
 	}
-	shortURL := &models.ShortURL{
-		OriginalURL: originalURL,
-		ShortCode:   shortCode,
-		CreatedAt:   time.Now(),
-		IsActive:    true,
-		ClickCount:  0,
+	if opts.Alias != "" && !s.reserved.Allowed(opts.Alias) {
+		return nil, ErrInvalidAlias
 	}
-	if expiresIn != nil {
-		expiresAt := time.Now().Add(*expiresIn)
+	if opts.MaxClicks < 0 {
+		return nil, ErrInvalidMaxClicks
+	}
+	if opts.SingleUse {
+		// A one-click limit, so crawlers and previews don't use the link up
+		opts.MaxClicks = 1
+	}
+	var signingKey string
+	if opts.SignedQuery {
+		if signingKey, err = newSigningKey(); err != nil {
+			return nil, err
+		}
+	}
+	var manageTokenID string
+	if opts.OwnerID == "" {
+		if manageTokenID, err = newManageTokenID(); err != nil {
+			return nil, err
+		}
+	}
+	domain, err := s.domainService.CheckUsable(ctx, opts.OwnerID, opts.Domain)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.customized() {
+		existing, _ := s.repo.GetShortURLByOriginal(ctx, domain, opts.OwnerID, originalURL)
+		if existing != nil {
+			return existing, nil
+		}
+	}
+	if opts.Quota != nil {
+		if err := s.quotaService.ChargeLinkCreation(ctx, opts.Quota); err != nil {
+			if errors.Is(err, ErrDailyQuotaExceeded) || errors.Is(err, ErrMonthlyQuotaExceeded) {
+				return nil, err
+			}
+			// Quotas are not enforced while Redis is unreachable
+			fmt.Printf("Failed to charge link quota: %v\n", err)
+		}
+	}
+	shortURL = &models.ShortURL{
+		OriginalURL:    originalURL,
+		URLKey:         validators.URLMatchKey(originalURL),
+		Domain:         domain,
+		Title:          opts.Title,
+		Notes:          opts.Notes,
+		CreatedAt:      time.Now(),
+		IsActive:       true,
+		ClickCount:     0,
+		OwnerID:        opts.OwnerID,
+		DeviceRules:    opts.DeviceRules,
+		UTM:            opts.UTM,
+		Indexable:      opts.Indexable,
+		NoIndex:        opts.NoIndex,
+		Honeytoken:     opts.Honeytoken,
+		MaxClicks:      opts.MaxClicks,
+		EdgeCacheTTL:   opts.EdgeCacheTTL,
+		CacheMaxAge:    opts.CacheMaxAge,
+		CampaignID:     opts.CampaignID,
+		Variants:       opts.Variants,
+		ActivateAt:     opts.ActivateAt,
+		DeactivateAt:   opts.DeactivateAt,
+		FallbackURL:    opts.FallbackURL,
+		IPAccess:       ipAccess,
+		RateLimit:      opts.RateLimit,
+		Cloak:          opts.Cloak,
+		ForwardQuery:   opts.ForwardQuery,
+		SigningKey:     signingKey,
+		RedirectStatus: opts.RedirectStatus,
+		Privacy:        opts.Privacy,
+		SingleUse:      opts.SingleUse,
+		Shadow:         s.shadowBans.AccessFor(ctx, opts.OwnerID, opts.ClientIP),
+		ManageTokenID:  manageTokenID,
+	}
+	if opts.ExpiresIn != nil {
+		expiresAt := time.Now().Add(*opts.ExpiresIn)
 		shortURL.ExpiresAt = &expiresAt
 	}
-	if err := s.repo.CreateShortURL(ctx, shortURL); err != nil {
-		return nil, fmt.Errorf("failed to create short URL: %w", err)
+	if s.sandbox {
+		shortURL.Sandbox = true
+		shortURL.ExpiresAt = sandboxExpiry(shortURL.CreatedAt, shortURL.ExpiresAt)
+	}
+	if err := s.insertWithCode(ctx, shortURL, opts.Alias); err != nil {
+		if opts.Quota != nil {
+			if err := s.quotaService.RefundLinkCreation(ctx, opts.Quota); err != nil {
+				fmt.Printf("Failed to refund link quota: %v\n", err)
+			}
+		}
+		if errors.Is(err, repository.ErrUnavailable) {
+			return nil, ErrServiceUnavailable
+		}
+		return nil, err
+	}
+	if opts.Created != nil {
+		*opts.Created = true
+	}
+	s.codes.Add(ctx, shortURL.Domain, shortURL.ShortCode)
+	s.storeRedirect(ctx, shortURL, time.Now())
+	if opts.FetchTitle {
+		domain, shortCode := shortURL.Domain, shortURL.ShortCode
+		s.titles.Enqueue(shortURL.OriginalURL, func(ctx context.Context, meta PageMetadata) {
+			s.fillPageMetadata(ctx, domain, shortCode, meta)
+		})
 	}
+	if shortURL.OwnerID != "" && !s.sandbox {
+		if err := s.quotaService.RecordLinkCreated(ctx, shortURL.OwnerID); err != nil {
+			fmt.Printf("Failed to record link usage: %v\n", err)
+		}
+	}
+	s.stream.Emit(events.TypeLinkCreated, linkLabel(shortURL), events.LinkCreated{
+		ShortCode:   shortURL.ShortCode,
+		Domain:      shortURL.Domain,
+		OriginalURL: shortURL.OriginalURL,
+		CreatedAt:   shortURL.CreatedAt,
+		ExpiresAt:   shortURL.ExpiresAt,
+	})
 	return shortURL, nil
 }
-func (s *URLService) GetOriginalURL(ctx context.Context, shortCode string) (string, error) {
-	shortURL, err := s.repo.GetShortURLByCode(ctx, shortCode)
+
+// fillPageMetadata stores the metadata read from a link's destination where
+// the link still has none
+func (s *URLService) fillPageMetadata(ctx context.Context, domain, shortCode string, meta PageMetadata) {
+	changed, err := s.repo.FillPageMetadata(ctx, domain, shortCode, meta.Title, meta.Description)
 	if err != nil {
-		return "", ErrURLNotFound
+		fmt.Printf("Failed to store page metadata: %v\n", err)
+		return
+	}
+	if changed {
+		s.invalidate(ctx, InvalidateLinkUpdated, domain, shortCode)
+	}
+}
+
+// GetOriginalURL resolves shortCode on domain for a redirect and records the click
+func (s *URLService) GetOriginalURL(ctx context.Context, domain, shortCode string, visit Visit) (redirect *Redirect, err error) {
+	ctx, span := tracing.Start(ctx, "URLService.GetOriginalURL", tracing.Link(domain, shortCode)...)
+	defer func() { tracing.End(span, failure(err)) }()
+	shortURL, err := s.lookup(ctx, domain, shortCode)
+	if err != nil {
+		// Cached links keep redirecting while MongoDB is down; anything else fails fast
+		return nil, linkError(err)
+	}
+	// Any access to a honeytoken alerts, whatever happens to the redirect
+	if shortURL.Honeytoken {
+		s.honeytokens.Trigger(shortURL, visit)
+	}
+	// Shadowed links look like unknown codes to everyone but their owner
+	if s.shadowBans.Hides(shortURL, visit) {
+		return nil, ErrURLNotFound
+	}
+	if shortURL.IPAccess != nil && !shortURL.IPAccess.Allows(visit.IP) {
+		return nil, ErrIPBlocked
 	}
+	// Tampered parameters are turned away before the visit uses up anything
+	if shortURL.SigningKey != "" {
+		if visit.Query, err = verifySignedQuery(shortURL, visit.Query, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	// A link that doesn't redirect sends visitors to its fallback, uncached
+	// and uncounted, when it has one. Outside its schedule it otherwise looks
+	// like an unknown code
 	if !shortURL.IsActive {
-		return "", ErrURLInactive
+		return fallback(shortURL, ErrURLInactive)
 	}
 	if shortURL.ExpiresAt != nil && time.Now().After(*shortURL.ExpiresAt) {
-		return "", ErrURLExpired
+		return fallback(shortURL, ErrURLExpired)
+	}
+	if !shortURL.Scheduled(time.Now()) {
+		return fallback(shortURL, ErrURLNotFound)
+	}
+	// Uncounted visits, like crawlers', are held to a link's limits without
+	// using them up
+	if shortURL.RateLimit != nil {
+		allowed, reset, err := s.rateLimits.Allow(ctx, shortURL, !visit.Uncounted)
+		if err != nil {
+			// Caps are not enforced while Redis is unreachable
+			fmt.Printf("Failed to check link rate limit: %v\n", err)
+		} else if !allowed {
+			return rateLimited(shortURL, reset)
+		}
+	}
+	switch {
+	case shortURL.MaxClicks > 0 && visit.Uncounted:
+		if shortURL.LimitedClicks >= shortURL.MaxClicks {
+			return fallback(shortURL, ErrClickLimitReached)
+		}
+	case shortURL.MaxClicks > 0:
+		if err := s.consumeLimitedClick(ctx, shortURL); err != nil {
+			if errors.Is(err, ErrClickLimitReached) {
+				return fallback(shortURL, err)
+			}
+			return nil, err
+		}
+	}
+	destination, variant := resolveDestination(shortURL, visit)
+	visit.Variant = variant
+	if !visit.Uncounted && !s.sandbox {
+		s.recordClick(ctx, domain, shortURL, visit)
+	}
+	return &Redirect{
+		URL:          appendUTM(forwardQuery(destination, shortURL, visit.Query), shortURL.UTM),
+		Variant:      variant,
+		Cloak:        shortURL.Cloak,
+		Title:        shortURL.Title,
+		Status:       shortURL.RedirectStatus,
+		NoIndex:      shortURL.NoIndex,
+		EdgeCacheTTL: s.edgeCache.TTL(shortURL),
+		MaxAge:       s.edgeCache.MaxAge(shortURL),
+		SurrogateKey: SurrogateKey(domain, shortCode),
+	}, nil
+}
+
+// rateLimited sends a visit over the link's rate_limit to its retry URL. With
+// none it fails with ErrLinkRateLimited, and the redirect only carries RetryAt
+func rateLimited(shortURL *models.ShortURL, reset time.Time) (*Redirect, error) {
+	if shortURL.RateLimit.RetryURL == "" {
+		return &Redirect{RetryAt: reset}, ErrLinkRateLimited
+	}
+	return &Redirect{URL: shortURL.RateLimit.RetryURL, NoIndex: shortURL.NoIndex}, nil
+}
+
+// fallback redirects to the link's fallback URL, or fails with err without one
+func fallback(shortURL *models.ShortURL, err error) (*Redirect, error) {
+	if shortURL.FallbackURL == "" {
+		return nil, err
+	}
+	return &Redirect{URL: shortURL.FallbackURL, NoIndex: shortURL.NoIndex}, nil
+}
+
+// recordClick counts a redirect towards the link's clicks, the owner's usage,
+// analytics and leaderboards, and streams it
+func (s *URLService) recordClick(ctx context.Context, domain string, shortURL *models.ShortURL, visit Visit) {
+	// Counted in memory and written to MongoDB in batches
+	s.clicks.Add(domain, shortURL.ShortCode, visit.Bot)
+	if shortURL.OwnerID != "" {
+		if err := s.quotaService.RecordClick(ctx, shortURL.OwnerID); err != nil {
+			fmt.Printf("Failed to record click usage: %v\n", err)
+		}
+	}
+	if err := s.analyticsService.RecordClick(ctx, shortURL, visit); err != nil {
+		fmt.Printf("Failed to record click event: %v\n", err)
+	}
+	if !visit.Bot {
+		if err := s.leaderboards.RecordClick(ctx, shortURL); err != nil {
+			fmt.Printf("Failed to update leaderboards: %v\n", err)
+		}
+	}
+	// Like click events, visit details of links in privacy mode aren't streamed
+	if s.analyticsService.Tracks(shortURL) {
+		s.stream.Emit(events.TypeLinkClicked, linkLabel(shortURL), events.LinkClicked{
+			ShortCode: shortURL.ShortCode,
+			Domain:    domain,
+			ClickedAt: time.Now().UTC(),
+			Referrer:  visit.Referrer,
+			UserAgent: visit.UserAgent,
+			Country:   strings.ToUpper(visit.Country),
+			City:      visit.City,
+			Bot:       visit.Bot,
+			Variant:   visit.Variant,
+		})
+	}
+}
+
+// consumeLimitedClick uses one of the link's allowed redirects, deactivating
+// the link when the last one is taken. The check, increment and deactivation
+// are a single conditional update, so concurrent redirects can't overshoot
+// the limit and a single-use link redirects exactly once
+func (s *URLService) consumeLimitedClick(ctx context.Context, shortURL *models.ShortURL) error {
+	updated, err := s.repo.ConsumeLimitedClick(ctx, shortURL.Domain, shortURL.ShortCode)
+	if err != nil {
+		if errors.Is(err, repository.ErrUnavailable) {
+			return ErrServiceUnavailable
+		}
+		return fmt.Errorf("failed to check click limit: %w", err)
 	}
-	if err := s.repo.UpdateClickCount(ctx, shortCode); err != nil {
-		// Log error but don't fail the request
-		fmt.Printf("Failed to update click count: %v\n", err)
+	if updated == nil {
+		// Exhausted, or deactivated by an earlier redirect; make sure it stays off
+		s.deactivate(ctx, shortURL)
+		return ErrClickLimitReached
 	}
-	return shortURL.OriginalURL, nil
+	if !updated.IsActive {
+		// This redirect took the last one and turned the link off
+		s.deactivated(ctx, shortURL, true)
+	}
+	return nil
 }
 
-func (s *URLService) GetStats(ctx context.Context, shortCode string) (*models.ShortURL, error) {
-	shortURL, err := s.repo.GetStats(ctx, shortCode)
+// deactivate turns a link off, dropping it from the cache and the owner's
+// active link count
+func (s *URLService) deactivate(ctx context.Context, shortURL *models.ShortURL) {
+	changed, err := s.repo.Deactivate(ctx, shortURL.Domain, shortURL.ShortCode)
 	if err != nil {
+		fmt.Printf("Failed to deactivate link: %v\n", err)
+		return
+	}
+	s.deactivated(ctx, shortURL, changed)
+}
+
+// deactivated drops a link that was turned off from the cache and, when
+// changed says it was active until now, from the owner's active link count
+func (s *URLService) deactivated(ctx context.Context, shortURL *models.ShortURL, changed bool) {
+	s.invalidate(ctx, InvalidateLinkDeactivated, shortURL.Domain, shortURL.ShortCode)
+	if changed && shortURL.OwnerID != "" && !s.sandbox {
+		if err := s.quotaService.RecordLinkDeactivated(ctx, shortURL.OwnerID); err != nil {
+			fmt.Printf("Failed to record link deactivation: %v\n", err)
+		}
+	}
+}
+
+// invalidate drops a changed link from every replica's cache and the CDN
+// kind says what happened to it, one of the InvalidateLink* kinds
+func (s *URLService) invalidate(ctx context.Context, kind, domain, shortCode string) {
+	// Sandbox links are never cached; their codes may also name production links
+	if s.sandbox {
+		return
+	}
+	if err := s.linkCache.Invalidate(ctx, kind, domain, shortCode); err != nil {
+		fmt.Printf("Failed to invalidate link cache: %v\n", err)
+	}
+	s.edgeCache.Purge(domain, shortCode)
+	s.syncRedirect(ctx, domain, shortCode)
+}
+
+// syncRedirect copies a changed link's current state to the redirect store
+func (s *URLService) syncRedirect(ctx context.Context, domain, shortCode string) {
+	if s.redirects == nil {
+		return
+	}
+	readAt := time.Now()
+	shortURL, err := s.repo.GetShortURLByCode(ctx, domain, shortCode)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		if err := s.redirects.DeleteShortURL(ctx, domain, shortCode, readAt); err != nil {
+			fmt.Printf("Failed to remove link from redirect store: %v\n", err)
+		}
+	case err != nil:
+		fmt.Printf("Failed to reload link for redirect store: %v\n", err)
+	default:
+		s.storeRedirect(ctx, shortURL, readAt)
+	}
+}
+
+// storeRedirect writes a copy of shortURL, read at readAt, to the redirect
+// store. Shadowed links are left out: lifting a shadow ban doesn't go through
+// the service, so their next visit reads MongoDB and stores them then
+func (s *URLService) storeRedirect(ctx context.Context, shortURL *models.ShortURL, readAt time.Time) {
+	if s.redirects == nil {
+		return
+	}
+	var err error
+	if shortURL.Shadow != nil || shortURL.DeletedAt != nil {
+		err = s.redirects.DeleteShortURL(ctx, shortURL.Domain, shortURL.ShortCode, readAt)
+	} else {
+		err = s.redirects.SaveShortURL(ctx, shortURL, readAt)
+	}
+	if err != nil {
+		fmt.Printf("Failed to write link to redirect store: %v\n", err)
+	}
+}
+
+// insertWithCode stores shortURL under alias, or under a generated code when
+// alias is empty, retrying generation if the code is already taken
+func (s *URLService) insertWithCode(ctx context.Context, shortURL *models.ShortURL, alias string) error {
+	if alias != "" {
+		shortURL.ShortCode = alias
+		// Archived links keep their codes; the unique index only covers the hot collection
+		if _, err := s.repo.GetArchived(ctx, shortURL.Domain, alias); err == nil {
+			return ErrAliasTaken
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return fmt.Errorf("failed to check archived links: %w", err)
+		}
+		if err := s.repo.CreateShortURL(ctx, shortURL); err != nil {
+			if errors.Is(err, repository.ErrDuplicate) {
+				return ErrAliasTaken
+			}
+			return fmt.Errorf("failed to create short URL: %w", err)
+		}
+		return nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		shortCode, err := s.keyService.GetShortCode(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to generate short code: %w", err)
+		}
+		shortURL.ShortCode = shortCode
+		err = s.repo.CreateShortURL(ctx, shortURL)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, repository.ErrDuplicate) || attempt == maxCodeAttempts {
+			return fmt.Errorf("failed to create short URL: %w", err)
+		}
+	}
+}
+
+// lookup reads a link for the redirect path, going through the link cache
+// and then the redirect store before MongoDB
+func (s *URLService) lookup(ctx context.Context, domain, shortCode string) (*models.ShortURL, error) {
+	if s.sandbox {
+		return s.findLink(ctx, domain, shortCode, false)
+	}
+	cached, err := s.linkCache.Get(ctx, domain, shortCode)
+	if err != nil {
+		fmt.Printf("Failed to read link cache: %v\n", err)
+	}
+	if cached != nil {
+		// A copy cached before the link was trashed, if its invalidation was lost
+		if cached.DeletedAt != nil {
+			return nil, repository.ErrNotFound
+		}
+		return cached, nil
+	}
+	exists, err := s.codes.MayExist(ctx, domain, shortCode)
+	if err != nil {
+		fmt.Printf("Failed to check code filter: %v\n", err)
+	}
+	if !exists {
+		return nil, repository.ErrNotFound
+	}
+	shortURL, err := s.readRedirect(ctx, domain, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.linkCache.Set(ctx, shortURL); err != nil {
+		fmt.Printf("Failed to populate link cache: %v\n", err)
+	}
+	return shortURL, nil
+}
+
+// readRedirect reads a link from the redirect store, falling back to
+// MongoDB and copying what it finds there into the store. Without a redirect
+// store, MongoDB is read with the redirect read preference; a copy that may
+// lag behind the primary is never written to the store
+func (s *URLService) readRedirect(ctx context.Context, domain, shortCode string) (*models.ShortURL, error) {
+	if s.redirects == nil {
+		shortURL, err := s.repo.GetRedirectLink(ctx, domain, shortCode)
+		if errors.Is(err, repository.ErrNotFound) {
+			return s.findArchived(ctx, domain, shortCode, s.rehydrateArchived)
+		}
+		return shortURL, err
+	}
+	shortURL, err := s.redirects.GetShortURLByCode(ctx, domain, shortCode)
+	if err == nil {
+		return shortURL, nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		fmt.Printf("Failed to read redirect store: %v\n", err)
+	}
+	readAt := time.Now()
+	shortURL, err = s.findLink(ctx, domain, shortCode, s.rehydrateArchived)
+	if err != nil {
+		return nil, err
+	}
+	s.storeRedirect(ctx, shortURL, readAt)
+	return shortURL, nil
+}
+
+// UpdateURL replaces the destination, expiration, title and notes of an owned link
+// The short code is unchanged; previous values are kept in the audit trail
+func (s *URLService) UpdateURL(ctx context.Context, accountID, domain, shortCode string, opts UpdateOptions) (*models.ShortURL, error) {
+	originalURL, err := s.normalize(opts.OriginalURL)
+	if err != nil {
+		return nil, err
+	}
+	opts.OriginalURL = originalURL
+	if err := s.checkURL(ctx, accountID, opts.OriginalURL); err != nil {
+		return nil, err
+	}
+	current, err := s.getOwnedURL(ctx, accountID, domain, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkVariants(ctx, accountID, opts.Variants, current.DeviceRules); err != nil {
+		return nil, err
+	}
+	if err := s.checkSchedule(ctx, accountID, opts.ActivateAt, opts.DeactivateAt, opts.FallbackURL); err != nil {
+		return nil, err
+	}
+	// Privacy and security settings only change when the update names them
+	ipAccess, rateLimit := current.IPAccess, current.RateLimit
+	privacy, signedQuery := current.Privacy, current.SigningKey != ""
+	if !opts.KeepRateLimit {
+		if err := s.checkRateLimit(ctx, accountID, opts.RateLimit); err != nil {
+			return nil, err
+		}
+		rateLimit = opts.RateLimit
+	}
+	if !opts.KeepIPAccess {
+		if ipAccess, err = normalizeIPAccess(opts.IPAccess); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Privacy != nil {
+		privacy = *opts.Privacy
+	}
+	if opts.SignedQuery != nil {
+		signedQuery = *opts.SignedQuery
+	}
+
+	now := time.Now()
+	set := bson.M{
+		"original_url": opts.OriginalURL,
+		"url_key":      validators.URLMatchKey(opts.OriginalURL),
+		"title":        opts.Title,
+		"indexable":    opts.Indexable,
+		"updated_at":   now,
+	}
+	unset := bson.M{}
+	if opts.Notes != "" {
+		set["notes"] = opts.Notes
+	} else {
+		unset["notes"] = ""
+	}
+	if opts.EdgeCacheTTL != nil {
+		set["edge_cache_ttl"] = *opts.EdgeCacheTTL
+	} else {
+		unset["edge_cache_ttl"] = ""
+	}
+	if opts.CacheMaxAge != nil {
+		set["cache_max_age"] = *opts.CacheMaxAge
+	} else {
+		unset["cache_max_age"] = ""
+	}
+	if len(opts.Variants) > 0 {
+		set["variants"] = opts.Variants
+	} else {
+		unset["variants"] = ""
+	}
+	if opts.ActivateAt != nil {
+		set["activate_at"] = *opts.ActivateAt
+	} else {
+		unset["activate_at"] = ""
+	}
+	if opts.DeactivateAt != nil {
+		set["deactivate_at"] = *opts.DeactivateAt
+	} else {
+		unset["deactivate_at"] = ""
+	}
+	if opts.FallbackURL != "" {
+		set["fallback_url"] = opts.FallbackURL
+	} else {
+		unset["fallback_url"] = ""
+	}
+	if ipAccess != nil {
+		set["ip_access"] = ipAccess
+	} else {
+		unset["ip_access"] = ""
+	}
+	if rateLimit != nil {
+		set["rate_limit"] = rateLimit
+	} else {
+		unset["rate_limit"] = ""
+	}
+	if opts.Cloak != "" {
+		set["cloak"] = opts.Cloak
+	} else {
+		unset["cloak"] = ""
+	}
+	if opts.ForwardQuery {
+		set["forward_query"] = true
+	} else {
+		unset["forward_query"] = ""
+	}
+	signingKey := current.SigningKey
+	if !signedQuery {
+		signingKey = ""
+		unset["signing_key"] = ""
+	} else if signingKey == "" {
+		if signingKey, err = newSigningKey(); err != nil {
+			return nil, err
+		}
+		set["signing_key"] = signingKey
+	}
+	if opts.RedirectStatus != 0 {
+		set["redirect_status"] = opts.RedirectStatus
+	} else {
+		unset["redirect_status"] = ""
+	}
+	if privacy {
+		set["privacy"] = true
+	} else {
+		unset["privacy"] = ""
+	}
+	if opts.NoIndex {
+		set["noindex"] = true
+	} else {
+		unset["noindex"] = ""
+	}
+	var expiresAt *time.Time
+	if opts.ExpiresIn != nil {
+		t := now.Add(*opts.ExpiresIn)
+		expiresAt = &t
+	}
+	if s.sandbox {
+		// Edits can't keep a sandbox link past its creation's SandboxTTL
+		expiresAt = sandboxExpiry(current.CreatedAt, expiresAt)
+	}
+	if expiresAt != nil {
+		set["expires_at"] = *expiresAt
+	} else {
+		unset["expires_at"] = ""
+	}
+	// A new expiration gets its own warning
+	unset["expiry_notified_at"] = ""
+	// A new destination is checked afresh, soon
+	if current.OriginalURL != originalURL {
+		unset["health"] = ""
+		unset["health_checked_at"] = ""
+	}
+	if access := s.shadowBans.AccessFor(ctx, accountID, opts.ClientIP); access != nil {
+		set["shadow"] = mergeShadowAccess(current.Shadow, access)
+	}
+
+	changes := map[string]models.AuditChange{}
+	if current.OriginalURL != opts.OriginalURL {
+		changes["original_url"] = models.AuditChange{From: current.OriginalURL, To: opts.OriginalURL}
+	}
+	if current.Title != opts.Title {
+		changes["title"] = models.AuditChange{From: current.Title, To: opts.Title}
+	}
+	if current.Notes != opts.Notes {
+		changes["notes"] = models.AuditChange{From: current.Notes, To: opts.Notes}
+	}
+	if current.Indexable != opts.Indexable {
+		changes["indexable"] = models.AuditChange{From: current.Indexable, To: opts.Indexable}
+	}
+	if !sameInt(current.EdgeCacheTTL, opts.EdgeCacheTTL) {
+		changes["edge_cache_ttl"] = models.AuditChange{From: current.EdgeCacheTTL, To: opts.EdgeCacheTTL}
+	}
+	if !sameInt(current.CacheMaxAge, opts.CacheMaxAge) {
+		changes["cache_max_age"] = models.AuditChange{From: current.CacheMaxAge, To: opts.CacheMaxAge}
+	}
+	if !slices.Equal(current.Variants, opts.Variants) {
+		changes["variants"] = models.AuditChange{From: current.Variants, To: opts.Variants}
+	}
+	if !sameTime(current.ActivateAt, opts.ActivateAt) {
+		changes["activate_at"] = models.AuditChange{From: current.ActivateAt, To: opts.ActivateAt}
+	}
+	if !sameTime(current.DeactivateAt, opts.DeactivateAt) {
+		changes["deactivate_at"] = models.AuditChange{From: current.DeactivateAt, To: opts.DeactivateAt}
+	}
+	if current.FallbackURL != opts.FallbackURL {
+		changes["fallback_url"] = models.AuditChange{From: current.FallbackURL, To: opts.FallbackURL}
+	}
+	if !reflect.DeepEqual(current.IPAccess, ipAccess) {
+		changes["ip_access"] = models.AuditChange{From: current.IPAccess, To: ipAccess}
+	}
+	if !reflect.DeepEqual(current.RateLimit, rateLimit) {
+		changes["rate_limit"] = models.AuditChange{From: current.RateLimit, To: rateLimit}
+	}
+	if current.Cloak != opts.Cloak {
+		changes["cloak"] = models.AuditChange{From: current.Cloak, To: opts.Cloak}
+	}
+	if current.ForwardQuery != opts.ForwardQuery {
+		changes["forward_query"] = models.AuditChange{From: current.ForwardQuery, To: opts.ForwardQuery}
+	}
+	// The key itself stays out of the audit trail
+	if (current.SigningKey != "") != (signingKey != "") {
+		changes["signed_query"] = models.AuditChange{From: current.SigningKey != "", To: signingKey != ""}
+	}
+	if current.RedirectStatus != opts.RedirectStatus {
+		changes["redirect_status"] = models.AuditChange{From: current.RedirectStatus, To: opts.RedirectStatus}
+	}
+	if current.Privacy != privacy {
+		changes["privacy"] = models.AuditChange{From: current.Privacy, To: privacy}
+	}
+	if current.NoIndex != opts.NoIndex {
+		changes["noindex"] = models.AuditChange{From: current.NoIndex, To: opts.NoIndex}
+	}
+	if !sameTime(current.ExpiresAt, expiresAt) {
+		changes["expires_at"] = models.AuditChange{From: current.ExpiresAt, To: expiresAt}
+	}
+
+	updated, err := s.repo.UpdateShortURL(ctx, domain, shortCode, set, unset)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) || errors.Is(err, repository.ErrUnavailable) {
+			return nil, linkError(err)
+		}
+		return nil, fmt.Errorf("failed to update short URL: %w", err)
+	}
+	s.invalidate(ctx, InvalidateLinkUpdated, domain, shortCode)
+	s.recordUpdate(ctx, accountID, domain, shortCode, changes, now)
+	s.emitUpdated(updated, now)
+	return updated, nil
+}
+
+// RenewURL gives an owned link a new expiration, expiresIn from now, or
+// none when expiresIn is nil, so an expired link redirects again. Nothing
+// else changes: a link its owner turned off stays off, and one out of its
+// max_clicks is ErrClickLimitReached. The renewed link is put back in the
+// cache right away rather than on its next visit
+func (s *URLService) RenewURL(ctx context.Context, accountID, domain, shortCode string, expiresIn *time.Duration) (*models.ShortURL, error) {
+	current, err := s.getOwnedURL(ctx, accountID, domain, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	if current.MaxClicks > 0 && current.LimitedClicks >= current.MaxClicks {
+		return nil, ErrClickLimitReached
+	}
+	now := time.Now()
+	var expiresAt *time.Time
+	if expiresIn != nil {
+		t := now.Add(*expiresIn)
+		expiresAt = &t
+	}
+	if s.sandbox {
+		expiresAt = sandboxExpiry(current.CreatedAt, expiresAt)
+	}
+	set := bson.M{"updated_at": now}
+	// The new expiration gets its own warning
+	unset := bson.M{"expiry_notified_at": ""}
+	if expiresAt != nil {
+		set["expires_at"] = *expiresAt
+	} else {
+		unset["expires_at"] = ""
+	}
+	changes := map[string]models.AuditChange{}
+	if !sameTime(current.ExpiresAt, expiresAt) {
+		changes["expires_at"] = models.AuditChange{From: current.ExpiresAt, To: expiresAt}
+	}
+
+	renewed, err := s.repo.UpdateShortURL(ctx, domain, shortCode, set, unset)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) || errors.Is(err, repository.ErrUnavailable) {
+			return nil, linkError(err)
+		}
+		return nil, fmt.Errorf("failed to renew short URL: %w", err)
+	}
+	s.invalidate(ctx, InvalidateLinkUpdated, domain, shortCode)
+	if !s.sandbox {
+		if err := s.linkCache.Set(ctx, renewed); err != nil {
+			fmt.Printf("Failed to populate link cache: %v\n", err)
+		}
+	}
+	s.recordAudit(ctx, accountID, domain, shortCode, models.AuditActionRenew, changes, now)
+	s.emitUpdated(renewed, now)
+	return renewed, nil
+}
+
+// PatchURL changes the fields of an owned link that patch names, leaving
+// the rest as they are
+func (s *URLService) PatchURL(ctx context.Context, accountID, domain, shortCode string, patch LinkPatch) (*models.ShortURL, error) {
+	current, err := s.getOwnedURL(ctx, accountID, domain, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	values := &models.ShortURL{UpdatedAt: &now}
+	fields := []string{"updated_at"}
+	changes := map[string]models.AuditChange{}
+	for _, field := range patch.Fields {
+		switch field {
+		case PatchOriginalURL:
+			originalURL, err := s.normalize(patch.OriginalURL)
+			if err != nil {
+				return nil, err
+			}
+			if err := s.checkURL(ctx, accountID, originalURL); err != nil {
+				return nil, err
+			}
+			values.OriginalURL = originalURL
+			values.URLKey = validators.URLMatchKey(originalURL)
+			fields = append(fields, "original_url", "url_key")
+			if current.OriginalURL != originalURL {
+				changes["original_url"] = models.AuditChange{From: current.OriginalURL, To: originalURL}
+				// A new destination is checked afresh, soon
+				fields = append(fields, "health", "health_checked_at")
+			}
+			if access := s.shadowBans.AccessFor(ctx, accountID, patch.ClientIP); access != nil {
+				values.Shadow = mergeShadowAccess(current.Shadow, access)
+				fields = append(fields, "shadow")
+			}
+		case PatchTitle:
+			values.Title = patch.Title
+			fields = append(fields, "title")
+			if current.Title != patch.Title {
+				changes["title"] = models.AuditChange{From: current.Title, To: patch.Title}
+			}
+		case PatchNotes:
+			values.Notes = patch.Notes
+			fields = append(fields, "notes")
+			if current.Notes != patch.Notes {
+				changes["notes"] = models.AuditChange{From: current.Notes, To: patch.Notes}
+			}
+		case PatchTags:
+			values.Tags = normalizeTags(patch.Tags)
+			fields = append(fields, "tags")
+			if !slices.Equal(current.Tags, values.Tags) {
+				changes["tags"] = models.AuditChange{From: current.Tags, To: values.Tags}
+			}
+		case PatchExpiresIn:
+			if patch.ExpiresIn != nil {
+				t := now.Add(*patch.ExpiresIn)
+				values.ExpiresAt = &t
+			}
+			if s.sandbox {
+				values.ExpiresAt = sandboxExpiry(current.CreatedAt, values.ExpiresAt)
+			}
+			// A new expiration gets its own warning
+			fields = append(fields, "expires_at", "expiry_notified_at")
+			if !sameTime(current.ExpiresAt, values.ExpiresAt) {
+				changes["expires_at"] = models.AuditChange{From: current.ExpiresAt, To: values.ExpiresAt}
+			}
+		case PatchIsActive:
+			values.IsActive = patch.IsActive
+			fields = append(fields, "is_active")
+			if current.IsActive != patch.IsActive {
+				changes["is_active"] = models.AuditChange{From: current.IsActive, To: patch.IsActive}
+			}
+		default:
+			return nil, fmt.Errorf("field %q can't be patched", field)
+		}
+	}
+
+	patched, err := s.repo.PatchShortURL(ctx, domain, shortCode, values, fields)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) || errors.Is(err, repository.ErrUnavailable) {
+			return nil, linkError(err)
+		}
+		return nil, fmt.Errorf("failed to patch short URL: %w", err)
+	}
+	s.invalidate(ctx, InvalidateLinkUpdated, domain, shortCode)
+	if current.IsActive != patched.IsActive && !s.sandbox {
+		var err error
+		if patched.IsActive {
+			err = s.quotaService.RecordLinkRestored(ctx, accountID)
+		} else {
+			err = s.quotaService.RecordLinkDeactivated(ctx, accountID)
+		}
+		if err != nil {
+			fmt.Printf("Failed to record link activation change: %v\n", err)
+		}
+	}
+	s.recordUpdate(ctx, accountID, domain, shortCode, changes, now)
+	s.emitUpdated(patched, now)
+	return patched, nil
+}
+
+// normalizeTags lowercases and trims tags, dropping empty and repeated ones
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// UpsertBySlug makes the account's link under slug point at originalURL,
+// creating it on first use. Calling it again with the same values is a no-op,
+// so CMS plugins can call it on every publish. An empty title keeps the current one
+func (s *URLService) UpsertBySlug(ctx context.Context, accountID, domain, slug, originalURL, title, clientIP string) (*models.ShortURL, bool, error) {
+	originalURL, err := s.normalize(originalURL)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := s.checkURL(ctx, accountID, originalURL); err != nil {
+		return nil, false, err
+	}
+	if !s.reserved.Allowed(slug) {
+		return nil, false, ErrInvalidAlias
+	}
+	domain, err = s.domainService.CheckUsable(ctx, accountID, domain)
+	if err != nil {
+		return nil, false, err
+	}
+	current, err := s.findLink(ctx, domain, slug, true)
+	if errors.Is(err, repository.ErrNotFound) {
+		created, err := s.ShortenURL(ctx, originalURL, ShortenOptions{OwnerID: accountID, Domain: domain, Alias: slug, Title: title, ClientIP: clientIP})
+		return created, err == nil, err
+	}
+	if err != nil {
+		return nil, false, linkError(err)
+	}
+	if current.OwnerID != accountID {
+		return nil, false, ErrAliasTaken
+	}
+	if title == "" {
+		title = current.Title
+	}
+	if current.OriginalURL == originalURL && current.Title == title {
+		return current, false, nil
+	}
+
+	now := time.Now()
+	changes := map[string]models.AuditChange{}
+	if current.OriginalURL != originalURL {
+		changes["original_url"] = models.AuditChange{From: current.OriginalURL, To: originalURL}
+	}
+	if current.Title != title {
+		changes["title"] = models.AuditChange{From: current.Title, To: title}
+	}
+	set := bson.M{"original_url": originalURL, "url_key": validators.URLMatchKey(originalURL), "title": title, "updated_at": now}
+	if access := s.shadowBans.AccessFor(ctx, accountID, clientIP); access != nil {
+		set["shadow"] = mergeShadowAccess(current.Shadow, access)
+	}
+	updated, err := s.repo.UpdateShortURL(ctx, domain, slug, set, nil)
+	if err != nil {
+		return nil, false, linkError(err)
+	}
+	s.invalidate(ctx, InvalidateLinkUpdated, domain, slug)
+	s.recordUpdate(ctx, accountID, domain, slug, changes, now)
+	s.emitUpdated(updated, now)
+	return updated, false, nil
+}
+
+// emitUpdated streams a link.updated event for shortURL
+func (s *URLService) emitUpdated(shortURL *models.ShortURL, at time.Time) {
+	s.stream.Emit(events.TypeLinkUpdated, linkLabel(shortURL), events.LinkUpdated{
+		ShortCode:   shortURL.ShortCode,
+		Domain:      shortURL.Domain,
+		OriginalURL: shortURL.OriginalURL,
+		UpdatedAt:   at,
+	})
+}
+
+// PeekLink looks a link up for a page shown before its redirect (such as the
+// bot challenge) without counting a click. destination is where this visit
+// would be sent, or empty if it would not be redirected; honeytoken lets the
+// redirect path skip steps that would delay the alert
+func (s *URLService) PeekLink(ctx context.Context, domain, shortCode string, visit Visit) (destination string, honeytoken bool) {
+	shortURL, err := s.lookup(ctx, domain, shortCode)
+	if err != nil {
+		return "", false
+	}
+	if s.shadowBans.Hides(shortURL, visit) {
+		return "", false
+	}
+	if shortURL.IPAccess != nil && !shortURL.IPAccess.Allows(visit.IP) {
+		return "", shortURL.Honeytoken
+	}
+	if !shortURL.IsActive || (shortURL.ExpiresAt != nil && time.Now().After(*shortURL.ExpiresAt)) || !shortURL.Scheduled(time.Now()) {
+		return shortURL.FallbackURL, shortURL.Honeytoken
+	}
+	// Click-limited destinations are only revealed by the redirect that uses up a click
+	if shortURL.MaxClicks > 0 {
+		return "", shortURL.Honeytoken
+	}
+	destination, _ = resolveDestination(shortURL, visit)
+	return destination, shortURL.Honeytoken
+}
+
+// ResolveLinks reports where each code redirects, through the redirect
+// cache, without counting clicks. Links outside their schedule look like
+// unknown codes unless they have a fallback, and click-limited links that
+// still redirect don't show their destination. Honeytokens still alert
+func (s *URLService) ResolveLinks(ctx context.Context, domain string, shortCodes []string, visit Visit) ([]Resolution, error) {
+	now := time.Now()
+	resolutions := make([]Resolution, 0, len(shortCodes))
+	for _, code := range shortCodes {
+		resolution := Resolution{Code: code, Status: ResolveNotFound}
+		shortURL, err := s.lookup(ctx, domain, code)
+		if err != nil {
+			if !errors.Is(err, repository.ErrNotFound) {
+				return nil, linkError(err)
+			}
+			resolutions = append(resolutions, resolution)
+			continue
+		}
+		if shortURL.Honeytoken {
+			s.honeytokens.Trigger(shortURL, visit)
+		}
+		switch {
+		case s.shadowBans.Hides(shortURL, visit):
+		case shortURL.IPAccess != nil && !shortURL.IPAccess.Allows(visit.IP):
+			resolution.Status = ResolveBlocked
+		case !shortURL.IsActive && shortURL.MaxClicks > 0 && shortURL.LimitedClicks >= shortURL.MaxClicks:
+			resolution.Status, resolution.URL = ResolveClickLimit, shortURL.FallbackURL
+		case !shortURL.IsActive:
+			resolution.Status, resolution.URL = ResolveInactive, shortURL.FallbackURL
+		case shortURL.ExpiresAt != nil && now.After(*shortURL.ExpiresAt):
+			resolution.Status, resolution.URL = ResolveExpired, shortURL.FallbackURL
+		case !shortURL.Scheduled(now):
+			if shortURL.FallbackURL != "" {
+				resolution.Status, resolution.URL = ResolveInactive, shortURL.FallbackURL
+			}
+		case shortURL.MaxClicks > 0:
+			resolution.Status = ResolveLimited
+		default:
+			destination, _ := resolveDestination(shortURL, visit)
+			resolution.Status, resolution.URL = ResolveActive, appendUTM(destination, shortURL.UTM)
+		}
+		resolutions = append(resolutions, resolution)
+	}
+	return resolutions, nil
+}
+
+// GetLink returns a link by code through the redirect cache
+func (s *URLService) GetLink(ctx context.Context, domain, shortCode string) (*models.ShortURL, error) {
+	shortURL, err := s.lookup(ctx, domain, shortCode)
+	if err != nil {
+		return nil, linkError(err)
+	}
+	return shortURL, nil
+}
+
+// recordUpdate writes an audit entry for changes, if there are any
+func (s *URLService) recordUpdate(ctx context.Context, accountID, domain, shortCode string, changes map[string]models.AuditChange, at time.Time) {
+	if len(changes) == 0 {
+		return
+	}
+	s.recordAudit(ctx, accountID, domain, shortCode, models.AuditActionUpdate, changes, at)
+}
+
+func (s *URLService) recordAudit(ctx context.Context, accountID, domain, shortCode, action string, changes map[string]models.AuditChange, at time.Time) {
+	if s.sandbox {
+		return
+	}
+	entry := &models.AuditEntry{
+		Domain:    domain,
+		ShortCode: shortCode,
+		AccountID: accountID,
+		Action:    action,
+		Changes:   changes,
+		At:        at,
+	}
+	if err := s.auditRepo.CreateEntry(ctx, entry); err != nil {
+		fmt.Printf("Failed to write audit entry: %v\n", err)
+	}
+}
+
+// DeleteURL moves an owned link to the trash. It stops redirecting at once
+// and can be restored until the trash purger removes it for good
+func (s *URLService) DeleteURL(ctx context.Context, accountID, domain, shortCode string) (*models.ShortURL, error) {
+	if accountID == "" {
+		return nil, ErrURLNotFound
+	}
+	now := time.Now()
+	trashed, err := s.repo.Trash(ctx, domain, shortCode, accountID, now)
+	if errors.Is(err, repository.ErrNotFound) {
+		// An archived link has to be back in the hot collection to be trashed
+		if _, findErr := s.findLink(ctx, domain, shortCode, true); findErr == nil {
+			trashed, err = s.repo.Trash(ctx, domain, shortCode, accountID, now)
+		}
+	}
+	if err != nil {
+		return nil, linkError(err)
+	}
+	s.invalidate(ctx, InvalidateLinkDeleted, domain, shortCode)
+	if trashed.IsActive && !s.sandbox {
+		if err := s.quotaService.RecordLinkDeactivated(ctx, accountID); err != nil {
+			fmt.Printf("Failed to record link deletion: %v\n", err)
+		}
+	}
+	s.recordAudit(ctx, accountID, domain, shortCode, models.AuditActionDelete, nil, now)
+	s.stream.Emit(events.TypeLinkDeleted, linkLabel(trashed), events.LinkDeleted{
+		ShortCode: trashed.ShortCode,
+		Domain:    trashed.Domain,
+		DeletedAt: now.UTC(),
+	})
+	return trashed, nil
+}
+
+// GetManagedURL returns the anonymous link claims were issued for. A link
+// that is gone, or was claimed by an account since, is not found, and a
+// token revoked by rotating the link's token ID is invalid
+func (s *URLService) GetManagedURL(ctx context.Context, claims *ManageToken) (*models.ShortURL, error) {
+	shortURL, err := s.findLink(ctx, claims.Domain, claims.ShortCode, true)
+	if err != nil {
+		return nil, linkError(err)
+	}
+	if shortURL.OwnerID != "" || shortURL.DeletedAt != nil || shortURL.ID.Hex() != claims.LinkID {
+		return nil, ErrURLNotFound
+	}
+	if shortURL.ManageTokenID != claims.TokenID {
+		return nil, ErrInvalidManageToken
+	}
+	return shortURL, nil
+}
+
+// RotateManagedToken gives the anonymous link claims were issued for a new
+// token ID, revoking every token issued before, and returns the link to
+// issue the new token for
+func (s *URLService) RotateManagedToken(ctx context.Context, claims *ManageToken) (*models.ShortURL, error) {
+	if _, err := s.GetManagedURL(ctx, claims); err != nil {
+		return nil, err
+	}
+	tokenID, err := newManageTokenID()
+	if err != nil {
+		return nil, err
+	}
+	shortURL, err := s.repo.SetManageToken(ctx, claims.Domain, claims.ShortCode, claims.TokenID, tokenID)
+	if errors.Is(err, repository.ErrNotFound) {
+		// Rotated, claimed or deleted since it was read
+		return nil, ErrInvalidManageToken
+	}
+	if err != nil {
+		return nil, linkError(err)
+	}
+	return shortURL, nil
+}
+
+// GetManagedStats returns the click analytics of the anonymous link claims
+// were issued for
+func (s *URLService) GetManagedStats(ctx context.Context, claims *ManageToken, query StatsQuery) (*models.LinkStats, error) {
+	shortURL, err := s.GetManagedURL(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	return s.analyticsService.Stats(ctx, shortURL, query)
+}
+
+// DeleteManagedURL moves the anonymous link claims were issued for to the
+// trash. Nobody can restore it, so it is purged with the trash
+func (s *URLService) DeleteManagedURL(ctx context.Context, claims *ManageToken) (*models.ShortURL, error) {
+	if _, err := s.GetManagedURL(ctx, claims); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	trashed, err := s.repo.Trash(ctx, claims.Domain, claims.ShortCode, "", now)
+	if err != nil {
+		return nil, linkError(err)
+	}
+	s.invalidate(ctx, InvalidateLinkDeleted, claims.Domain, claims.ShortCode)
+	s.stream.Emit(events.TypeLinkDeleted, linkLabel(trashed), events.LinkDeleted{
+		ShortCode: trashed.ShortCode,
+		Domain:    trashed.Domain,
+		DeletedAt: now.UTC(),
+	})
+	return trashed, nil
+}
+
+// RestoreURL takes an owned link out of the trash. Links deleted longer
+// than the retention period ago are gone and reported as not found
+func (s *URLService) RestoreURL(ctx context.Context, accountID, domain, shortCode string) (*models.ShortURL, error) {
+	if accountID == "" {
+		return nil, ErrURLNotFound
+	}
+	now := time.Now()
+	restored, err := s.repo.Restore(ctx, domain, shortCode, accountID, now.Add(-s.trashRetention))
+	if err != nil {
+		return nil, linkError(err)
+	}
+	if restored.IsActive && !s.sandbox {
+		if err := s.quotaService.RecordLinkRestored(ctx, accountID); err != nil {
+			fmt.Printf("Failed to record link restore: %v\n", err)
+		}
+	}
+	s.syncRedirect(ctx, domain, shortCode)
+	s.recordAudit(ctx, accountID, domain, shortCode, models.AuditActionRestore, nil, now)
+	return restored, nil
+}
+
+// TransferOwnership hands a link of from, with its clicks, to the account
+// to. Trashed links can't be transferred and are reported as not found, and
+// a link sending visitors anywhere to's URL policy or destination rules
+// refuse is ErrURLNotAllowed
+func (s *URLService) TransferOwnership(ctx context.Context, domain, shortCode, from, to string) (*models.ShortURL, error) {
+	// Brings an archived link back, so the update below finds it
+	current, err := s.getOwnedURL(ctx, from, domain, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	for _, target := range linkTargets(current) {
+		if err := s.checkURL(ctx, to, target); err != nil {
+			if errors.Is(err, ErrURLNotAllowed) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: %v", ErrURLNotAllowed, err)
+		}
+	}
+	transferred, err := s.repo.SetOwner(ctx, domain, shortCode, from, to)
+	if err != nil {
+		return nil, linkError(err)
+	}
+	s.invalidate(ctx, InvalidateLinkUpdated, domain, shortCode)
+	if err := s.analyticsService.TransferClicks(ctx, transferred, to); err != nil {
+		fmt.Printf("Failed to transfer click events: %v\n", err)
+	}
+	if transferred.IsActive {
+		if err := s.quotaService.RecordLinkDeactivated(ctx, from); err != nil {
+			fmt.Printf("Failed to record link transfer: %v\n", err)
+		}
+		if err := s.quotaService.RecordLinkRestored(ctx, to); err != nil {
+			fmt.Printf("Failed to record link transfer: %v\n", err)
+		}
+	}
+	return transferred, nil
+}
+
+// linkTargets lists every URL a link can send visitors to
+func linkTargets(shortURL *models.ShortURL) []string {
+	targets := []string{shortURL.OriginalURL}
+	if rules := shortURL.DeviceRules; rules != nil {
+		targets = append(targets, rules.IOS, rules.Android, rules.Desktop)
+	}
+	for _, variant := range shortURL.Variants {
+		targets = append(targets, variant.URL)
+	}
+	targets = append(targets, shortURL.FallbackURL)
+	if shortURL.RateLimit != nil {
+		targets = append(targets, shortURL.RateLimit.RetryURL)
+	}
+	return slices.DeleteFunc(targets, func(target string) bool { return target == "" })
+}
+
+// GetHistory returns the audit trail of an owned link
+func (s *URLService) GetHistory(ctx context.Context, accountID, domain, shortCode string, page repository.PageRequest) (*repository.Page[models.AuditEntry], error) {
+	if _, err := s.getOwnedURL(ctx, accountID, domain, shortCode); err != nil {
+		return nil, err
+	}
+	return s.auditRepo.ListByShortCode(ctx, domain, shortCode, page)
+}
+
+// ListClicks returns one page of the recorded click events of an owned link
+func (s *URLService) ListClicks(ctx context.Context, accountID, domain, shortCode string, page repository.PageRequest) (*repository.Page[models.ClickEvent], error) {
+	shortURL, err := s.getOwnedURL(ctx, accountID, domain, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	return s.analyticsService.Clicks(ctx, shortURL, page)
+}
+
+// maxDestinationMatches caps how many links LookupDestination returns
+const maxDestinationMatches = 50
+
+// ListLinks returns one page of the account's links, sorted and filtered as
+// repository.LinkListSpec allows
+func (s *URLService) ListLinks(ctx context.Context, accountID string, page repository.PageRequest) (*repository.Page[models.ShortURL], error) {
+	links, err := s.repo.ListByOwner(ctx, accountID, page)
+	if err != nil {
+		return nil, linkError(err)
+	}
+	return links, nil
+}
+
+// LookupDestination returns the account's links, on any domain, that point at
+// originalURL once both are normalized (case, default ports, trailing slash,
+// query order), so clients can check for a link before creating one
+func (s *URLService) LookupDestination(ctx context.Context, accountID, originalURL string) ([]*models.ShortURL, error) {
+	if !validators.IsValidURL(originalURL) {
+		return nil, ErrInvalidURL
+	}
+	links, err := s.repo.FindByDestination(ctx, accountID, validators.URLMatchKey(originalURL), originalURL, maxDestinationMatches)
+	if err != nil {
+		if errors.Is(err, repository.ErrUnavailable) {
+			return nil, ErrServiceUnavailable
+		}
+		return nil, fmt.Errorf("failed to look up links: %w", err)
+	}
+	return links, nil
+}
+
+// WatchClicks returns the clicks on a link owned by accountID as they happen,
+// until ctx ends
+func (s *URLService) WatchClicks(ctx context.Context, accountID, domain, shortCode string) (<-chan models.ClickEvent, error) {
+	shortURL, err := s.getOwnedURL(ctx, accountID, domain, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	clicks, err := s.analyticsService.WatchClicks(ctx, shortURL)
+	if err != nil {
+		fmt.Printf("Failed to watch clicks: %v\n", err)
+		return nil, ErrServiceUnavailable
+	}
+	return clicks, nil
+}
+
+// GetOwnedLink returns a link owned by accountID
+// Links owned by someone else are reported as not found
+func (s *URLService) GetOwnedLink(ctx context.Context, accountID, domain, shortCode string) (*models.ShortURL, error) {
+	return s.getOwnedURL(ctx, accountID, domain, shortCode)
+}
+
+// findLink loads a link from the hot collection, falling back to the archive
+// for links moved there for inactivity. With rehydrate, an archived link is
+// moved back so later lookups and updates find it in the hot collection
+func (s *URLService) findLink(ctx context.Context, domain, shortCode string, rehydrate bool) (*models.ShortURL, error) {
+	shortURL, err := s.repo.GetShortURLByCode(ctx, domain, shortCode)
+	if !errors.Is(err, repository.ErrNotFound) {
+		return shortURL, err
+	}
+	return s.findArchived(ctx, domain, shortCode, rehydrate)
+}
+
+// findArchived is findLink's fallback for links missing from the hot collection
+func (s *URLService) findArchived(ctx context.Context, domain, shortCode string, rehydrate bool) (*models.ShortURL, error) {
+	archived, err := s.repo.GetArchived(ctx, domain, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	if rehydrate {
+		if err := s.repo.Rehydrate(ctx, archived); err != nil {
+			fmt.Printf("Failed to rehydrate archived link: %v\n", err)
+		}
+	}
+	return archived, nil
+}
+
+// getOwnedURL loads a link owned by accountID
+// Links owned by someone else are reported as not found
+func (s *URLService) getOwnedURL(ctx context.Context, accountID, domain, shortCode string) (*models.ShortURL, error) {
+	shortURL, err := s.findLink(ctx, domain, shortCode, true)
+	if err != nil {
+		return nil, linkError(err)
+	}
+	if accountID == "" || shortURL.OwnerID != accountID {
 		return nil, ErrURLNotFound
 	}
 	return shortURL, nil
 }
 
-func isValidURL(rawURL string) bool {
+// linkError maps a repository error from loading a link to the service error
+// handlers report: missing links are ErrURLNotFound and an unreachable
+// database is ErrServiceUnavailable. Anything else is wrapped as a failure
+func linkError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrURLNotFound
+	case errors.Is(err, repository.ErrUnavailable):
+		return ErrServiceUnavailable
+	default:
+		return fmt.Errorf("failed to load short URL: %w", err)
+	}
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// sameInt reports whether two optional integers are equal (both nil counts as equal)
+func sameInt(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// resolveDestination picks the target for this visit: the visitor's A/B
+// variant, named in the second result, when the link splits traffic, else
+// device rules when the link has them, falling back to OriginalURL
+func resolveDestination(shortURL *models.ShortURL, visit Visit) (string, string) {
+	if len(shortURL.Variants) > 0 {
+		variant := pickVariant(shortURL, visit)
+		return variant.URL, variant.Name
+	}
+	rules := shortURL.DeviceRules
+	if rules == nil {
+		return shortURL.OriginalURL, ""
+	}
+	var target string
+	switch utils.ParseDevice(visit.UserAgent) {
+	case utils.DeviceIOS:
+		target = rules.IOS
+	case utils.DeviceAndroid:
+		target = rules.Android
+	case utils.DeviceDesktop:
+		target = rules.Desktop
+	}
+	if target == "" {
+		return shortURL.OriginalURL, ""
+	}
+	return target, ""
+}
+
+// GetStats returns the click analytics of a link accountID owns
+func (s *URLService) GetStats(ctx context.Context, accountID, domain, shortCode string, query StatsQuery) (*models.LinkStats, error) {
+	shortURL, err := s.getOwnedURL(ctx, accountID, domain, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	return s.analyticsService.Stats(ctx, shortURL, query)
+}
+
+// GetCounter returns the public click counter of a link, for badges and
+// embedded widgets. Honeytokens have none: they exist to be left alone
+func (s *URLService) GetCounter(ctx context.Context, domain, shortCode string) (*models.LinkCounter, error) {
+	shortURL, err := s.findLink(ctx, domain, shortCode, false)
+	if err != nil {
+		return nil, linkError(err)
+	}
+	if shortURL.Honeytoken {
+		return nil, ErrURLNotFound
+	}
+	return &models.LinkCounter{
+		ShortCode: shortURL.ShortCode,
+		Domain:    shortURL.Domain,
+		Title:     shortURL.Title,
+		Clicks:    shortURL.ClickCount,
+	}, nil
+}
+
+// GetReferrerStats breaks the clicks of a link accountID owns down by
+// referring domain
+func (s *URLService) GetReferrerStats(ctx context.Context, accountID, domain, shortCode string, query StatsQuery, limit int) (*models.ReferrerStats, error) {
+	shortURL, err := s.getOwnedURL(ctx, accountID, domain, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	return s.analyticsService.Referrers(ctx, shortURL, query, limit)
+}
+
+// GetCountryStats breaks the clicks of a link accountID owns down by
+// country and city
+func (s *URLService) GetCountryStats(ctx context.Context, accountID, domain, shortCode string, query StatsQuery, limit int) (*models.CountryStats, error) {
+	shortURL, err := s.getOwnedURL(ctx, accountID, domain, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	return s.analyticsService.Countries(ctx, shortURL, query, limit)
+}
+
+// GetStatsBatch returns stats for several codes plus the codes that aren't
+// links accountID owns
+func (s *URLService) GetStatsBatch(ctx context.Context, accountID, domain string, shortCodes []string, query StatsQuery) ([]*models.LinkStats, []string, error) {
+	if err := s.analyticsService.CheckBatchSize(len(shortCodes)); err != nil {
+		return nil, nil, err
+	}
+	var (
+		found    []*models.ShortURL
+		notFound = []string{}
+	)
+	for _, code := range shortCodes {
+		shortURL, err := s.getOwnedURL(ctx, accountID, domain, code)
+		if err != nil {
+			if !errors.Is(err, ErrURLNotFound) {
+				return nil, nil, err
+			}
+			notFound = append(notFound, code)
+			continue
+		}
+		found = append(found, shortURL)
+	}
+	stats, err := s.analyticsService.StatsBatch(ctx, found, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	return stats, notFound, nil
+}
+
+// RecordConversion counts a conversion for one of the A/B variants of an owned link
+func (s *URLService) RecordConversion(ctx context.Context, accountID, domain, shortCode, variant string) error {
+	shortURL, err := s.getOwnedURL(ctx, accountID, domain, shortCode)
+	if err != nil {
+		return err
+	}
+	if !hasVariant(shortURL, variant) {
+		return ErrUnknownVariant
+	}
+	return s.analyticsService.RecordConversion(ctx, shortURL, variant)
+}
+
+// forwardQuery adds the visit's query parameters to the destination of a link
+// that forwards them, or of a signed link once they are verified. Parameters
+// already on the destination keep its values
+func forwardQuery(destination string, shortURL *models.ShortURL, query url.Values) string {
+	if (!shortURL.ForwardQuery && shortURL.SigningKey == "") || len(query) == 0 {
+		return destination
+	}
+	parsed, err := url.Parse(destination)
+	if err != nil {
+		return destination
+	}
+	merged := parsed.Query()
+	for key, values := range query {
+		if !merged.Has(key) {
+			merged[key] = values
+		}
+	}
+	parsed.RawQuery = merged.Encode()
+	return parsed.String()
+}
+
+// appendUTM merges the link's UTM template into the destination query string
+// Parameters already present on the destination are left untouched
+func appendUTM(destination string, utm *models.UTMParams) string {
+	if utm == nil {
+		return destination
+	}
+	parsed, err := url.Parse(destination)
+	if err != nil {
+		return destination
+	}
+	query := parsed.Query()
+	for key, value := range map[string]string{
+		"utm_source":   utm.Source,
+		"utm_medium":   utm.Medium,
+		"utm_campaign": utm.Campaign,
+		"utm_term":     utm.Term,
+		"utm_content":  utm.Content,
+	} {
+		if value != "" && !query.Has(key) {
+			query.Set(key, value)
+		}
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// normalize canonicalizes a destination before it is checked, stored or
+// compared, so http://Example.com:80 and http://example.com/ are one link
+func (s *URLService) normalize(rawURL string) (string, error) {
+	normalized, err := s.normalizer.Normalize(rawURL)
+	if err != nil {
+		return "", ErrInvalidURL
+	}
+	return normalized, nil
+}
+
+// checkURL validates a destination against the URL policy of accountID and
+// rejects destinations on the shortener's own hosts. Since no link can point
+// at another short link of ours, redirect loops through our codes can't form
+func (s *URLService) checkURL(ctx context.Context, accountID, rawURL string) error {
 	parsedURL, err := url.Parse(rawURL)
+	if err != nil || parsedURL.Scheme == "" || parsedURL.Host == "" {
+		return ErrInvalidURL
+	}
+	if err := s.urlPolicies.For(accountID).Check(parsedURL); err != nil {
+		return fmt.Errorf("%w: %v", ErrURLNotAllowed, err)
+	}
+	if s.isSelfHost(ctx, parsedURL.Hostname()) {
+		return fmt.Errorf("%w: links can't point back at this shortener", ErrURLNotAllowed)
+	}
+	return s.destinations.Check(ctx, parsedURL.Hostname())
+}
+
+// checkSchedule validates an activation window, where deactivate_at must come
+// after activate_at, and the fallback, which must pass checkURL
+func (s *URLService) checkSchedule(ctx context.Context, accountID string, activateAt, deactivateAt *time.Time, fallbackURL string) error {
+	if activateAt != nil && deactivateAt != nil && !deactivateAt.After(*activateAt) {
+		return fmt.Errorf("%w: deactivate_at must be after activate_at", ErrInvalidSchedule)
+	}
+	if fallbackURL == "" {
+		return nil
+	}
+	if err := s.checkURL(ctx, accountID, fallbackURL); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidFallbackURL, err)
+	}
+	return nil
+}
+
+// checkRateLimit validates a link's redirect cap; its retry URL must pass the
+// same checks as destinations
+func (s *URLService) checkRateLimit(ctx context.Context, accountID string, limit *models.LinkRateLimit) error {
+	if limit == nil {
+		return nil
+	}
+	if limit.Limit < 1 {
+		return fmt.Errorf("%w: limit must be at least 1", ErrInvalidRateLimit)
+	}
+	if limit.Window() == 0 {
+		return fmt.Errorf("%w: period must be %s or %s", ErrInvalidRateLimit, models.RateLimitHour, models.RateLimitDay)
+	}
+	if limit.RetryURL == "" {
+		return nil
+	}
+	if err := s.checkURL(ctx, accountID, limit.RetryURL); err != nil {
+		return fmt.Errorf("%w: retry_url: %v", ErrInvalidRateLimit, err)
+	}
+	return nil
+}
+
+// normalizeIPAccess validates a link's visitor address lists and stores each
+// entry as a masked CIDR range; empty lists mean no restriction
+func normalizeIPAccess(access *models.IPAccess) (*models.IPAccess, error) {
+	if access == nil || (len(access.Allow) == 0 && len(access.Deny) == 0) {
+		return nil, nil
+	}
+	allow, err := normalizeIPRanges("allow", access.Allow)
 	if err != nil {
-		return false
+		return nil, err
+	}
+	deny, err := normalizeIPRanges("deny", access.Deny)
+	if err != nil {
+		return nil, err
+	}
+	return &models.IPAccess{Allow: allow, Deny: deny}, nil
+}
+
+func normalizeIPRanges(list string, ranges []string) ([]string, error) {
+	if len(ranges) > maxIPRanges {
+		return nil, fmt.Errorf("%w: %s may list at most %d ranges", ErrInvalidIPAccess, list, maxIPRanges)
+	}
+	var normalized []string
+	for _, raw := range ranges {
+		prefix, err := models.ParseIPRange(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q in %s is not an IP address or CIDR range", ErrInvalidIPAccess, raw, list)
+		}
+		normalized = append(normalized, prefix.String())
+	}
+	return normalized, nil
+}
+
+// isSelfHost reports whether host is the default short domain, one of its
+// aliases, or a verified branded domain
+func (s *URLService) isSelfHost(ctx context.Context, host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if _, ok := s.selfHosts[host]; ok {
+		return true
+	}
+	domain, err := s.domainService.Resolve(ctx, host)
+	return err == nil && domain != nil
+}
+
+// mergeShadowAccess adds the editor's IPs to a link's existing restriction
+func mergeShadowAccess(current, next *models.ShadowAccess) *models.ShadowAccess {
+	if current == nil {
+		return next
+	}
+	merged := &models.ShadowAccess{CreatorIPs: append([]string{}, current.CreatorIPs...)}
+	for _, ip := range next.CreatorIPs {
+		if !slices.Contains(merged.CreatorIPs, ip) {
+			merged.CreatorIPs = append(merged.CreatorIPs, ip)
+		}
 	}
-	return parsedURL.Scheme != "" && parsedURL.Host != ""
+	return merged
 }
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/models"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
+)
+
+// VariantCookie remembers which A/B variant a visitor was sent to; it is
+// scoped to the link's path so each link keeps its own assignment
+const VariantCookie = "sc_variant"
+
+const (
+	// MaxVariants bounds how many destinations one split may have
+	MaxVariants      = 10
+	maxVariantWeight = 1000
+)
+
+var (
+	// ErrInvalidVariants wraps why a link's A/B variants were rejected
+	ErrInvalidVariants = newFieldError("variants", validators.CodeInvalid, "invalid variants", "")
+	// ErrUnknownVariant means a conversion named a variant the link doesn't have
+	ErrUnknownVariant = newFieldError("variant", validators.CodeInvalid, "unknown variant", "The link has no variant by that name")
+)
+
+var variantNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// checkVariants validates an A/B split: 2 to MaxVariants uniquely named
+// variants with positive weights, each destination passing checkURL
+func (s *URLService) checkVariants(ctx context.Context, accountID string, variants []models.Variant, deviceRules *models.DeviceRules) error {
+	if len(variants) == 0 {
+		return nil
+	}
+	if len(variants) < 2 || len(variants) > MaxVariants {
+		return fmt.Errorf("%w: a split needs 2 to %d variants", ErrInvalidVariants, MaxVariants)
+	}
+	if deviceRules != nil {
+		return fmt.Errorf("%w: variants can't be combined with device_rules", ErrInvalidVariants)
+	}
+	seen := make(map[string]bool, len(variants))
+	for _, variant := range variants {
+		if !variantNamePattern.MatchString(variant.Name) {
+			return fmt.Errorf("%w: variant names must be 1-32 letters, digits, '-' or '_'", ErrInvalidVariants)
+		}
+		if seen[variant.Name] {
+			return fmt.Errorf("%w: variant %q is listed twice", ErrInvalidVariants, variant.Name)
+		}
+		seen[variant.Name] = true
+		if variant.Weight < 1 || variant.Weight > maxVariantWeight {
+			return fmt.Errorf("%w: weights must be between 1 and %d", ErrInvalidVariants, maxVariantWeight)
+		}
+		if err := s.checkURL(ctx, accountID, variant.URL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pickVariant returns the visitor's variant: the one it was given before if
+// the link still has it, otherwise a weighted choice from a hash of the
+// visitor, so someone without the cookie still lands on the same variant
+func pickVariant(shortURL *models.ShortURL, visit Visit) models.Variant {
+	total := 0
+	for _, variant := range shortURL.Variants {
+		if variant.Name == visit.Variant {
+			return variant
+		}
+		total += variant.Weight
+	}
+	h := fnv.New32a()
+	h.Write([]byte(shortURL.Domain + "/" + shortURL.ShortCode + "|" + visit.IP + "|" + visit.UserAgent))
+	point := int(h.Sum32() % uint32(total))
+	for _, variant := range shortURL.Variants {
+		if point < variant.Weight {
+			return variant
+		}
+		point -= variant.Weight
+	}
+	return shortURL.Variants[len(shortURL.Variants)-1]
+}
+
+// hasVariant reports whether the link splits traffic to a variant named name
+func hasVariant(shortURL *models.ShortURL, name string) bool {
+	for _, variant := range shortURL.Variants {
+		if variant.Name == name {
+			return true
+		}
+	}
+	return false
+}
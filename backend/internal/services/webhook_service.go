@@ -0,0 +1,102 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/events"
+)
+
+// WebhookService delivers event envelopes to the configured webhook endpoints
+// Payloads are converted to SchemaVersion when consumers pin an older version
+type WebhookService struct {
+	httpClient    *http.Client
+	registry      *events.Registry
+	endpoints     []string
+	secret        string
+	schemaVersion int
+}
+
+func NewWebhookService(registry *events.Registry, endpoints []string, secret string, schemaVersion int) *WebhookService {
+	return &WebhookService{
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+		registry:      registry,
+		endpoints:     endpoints,
+		secret:        secret,
+		schemaVersion: schemaVersion,
+	}
+}
+
+// Emit builds an event for payload and delivers it in the background
+func (s *WebhookService) Emit(t events.Type, payload interface{}) {
+	if len(s.endpoints) == 0 {
+		return
+	}
+	env, err := s.registry.New(t, payload)
+	if err != nil {
+		log.Printf("Failed to build %s event: %v", t, err)
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := s.Deliver(ctx, env); err != nil {
+			log.Printf("Failed to deliver %s webhook: %v", t, err)
+		}
+	}()
+}
+
+// Deliver posts env to every endpoint, returning the last delivery error
+func (s *WebhookService) Deliver(ctx context.Context, env *events.Envelope) error {
+	converted, err := s.registry.Convert(env, s.schemaVersion)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(converted)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook body: %w", err)
+	}
+
+	var lastErr error
+	for _, endpoint := range s.endpoints {
+		if err := s.post(ctx, endpoint, converted, body); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (s *WebhookService) post(ctx context.Context, endpoint string, env *events.Envelope, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Type", string(env.Type))
+	req.Header.Set("X-Event-Schema-Version", fmt.Sprint(env.SchemaVersion))
+	if s.secret != "" {
+		mac := hmac.New(sha256.New, []byte(s.secret))
+		mac.Write(body)
+		req.Header.Set("X-Webhook-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post webhook to %s: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook endpoint %s returned %s", endpoint, resp.Status)
+	}
+	return nil
+}
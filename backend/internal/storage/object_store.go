@@ -0,0 +1,80 @@
+// Package storage wraps S3-compatible object storage (AWS S3, MinIO, GCS interop)
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// ErrNotFound means no object is stored under a key
+var ErrNotFound = errors.New("object not found")
+
+// ObjectStore reads and writes objects in a single bucket
+type ObjectStore struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewObjectStore connects to an S3-compatible endpoint, creating bucket if missing
+func NewObjectStore(ctx context.Context, endpoint, accessKey, secretKey, bucket string, useSSL bool) (*ObjectStore, error) {
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: useSSL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create object storage client: %w", err)
+	}
+	exists, err := client.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check bucket: %w", err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{}); err != nil {
+			return nil, fmt.Errorf("failed to create bucket: %w", err)
+		}
+	}
+	return &ObjectStore{
+		client: client,
+		bucket: bucket,
+	}, nil
+}
+
+// Put uploads body under key
+func (s *ObjectStore) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, bytes.NewReader(body), int64(len(body)), minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	return err
+}
+
+// Get downloads the object stored under key. A missing object is ErrNotFound
+func (s *ObjectStore) Get(ctx context.Context, key string) ([]byte, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+	body, err := io.ReadAll(object)
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return body, err
+}
+
+// List returns the keys of every object whose key starts with prefix
+func (s *ObjectStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	for info := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if info.Err != nil {
+			return nil, info.Err
+		}
+		keys = append(keys, info.Key)
+	}
+	return keys, nil
+}
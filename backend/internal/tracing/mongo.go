@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// MongoMonitor returns a command monitor that records every MongoDB command
+// as a client span under the span of the operation's context. Command bodies
+// are not recorded since they hold link destinations and visitor data
+func MongoMonitor() *event.CommandMonitor {
+	var spans sync.Map // request ID -> trace.Span
+	finish := func(requestID int64, err string) {
+		value, ok := spans.LoadAndDelete(requestID)
+		if !ok {
+			return
+		}
+		span := value.(trace.Span)
+		if err != "" {
+			span.SetStatus(codes.Error, err)
+		}
+		span.End()
+	}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			_, span := otel.Tracer(instrumentationName).Start(ctx, "mongodb."+evt.CommandName,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					semconv.DBSystemNameMongoDB,
+					semconv.DBNamespace(evt.DatabaseName),
+					semconv.DBOperationName(evt.CommandName),
+				),
+			)
+			spans.Store(evt.RequestID, span)
+		},
+		Succeeded: func(_ context.Context, evt *event.CommandSucceededEvent) {
+			finish(evt.RequestID, "")
+		},
+		Failed: func(_ context.Context, evt *event.CommandFailedEvent) {
+			finish(evt.RequestID, evt.Failure)
+		},
+	}
+}
@@ -0,0 +1,84 @@
+// Package tracing wires OpenTelemetry into the server. Spans are exported over
+// OTLP/HTTP, which Jaeger and the OpenTelemetry Collector both accept; with
+// no endpoint configured the global provider stays a no-op and spans cost
+// next to nothing
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer spans started here come from
+const instrumentationName = "github.com/ranjanshahajishitole/url-shortener/backend"
+
+// Setup installs W3C trace context propagation and, when endpoint is set,
+// a tracer provider exporting to it. The returned function flushes buffered
+// spans on shutdown; region, when set, tags every span
+func Setup(ctx context.Context, endpoint, serviceName, instanceID, region string, sampleRatio float64) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	// The exporter reads OTEL_EXPORTER_OTLP_ENDPOINT and the other standard
+	// OTEL_EXPORTER_OTLP_* variables itself
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+	attrs := []attribute.KeyValue{
+		semconv.ServiceName(serviceName),
+		semconv.ServiceInstanceID(instanceID),
+	}
+	if region != "" {
+		attrs = append(attrs, semconv.CloudRegion(region))
+	}
+	res := resource.NewWithAttributes(semconv.SchemaURL, attrs...)
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// Follow the caller's decision so a trace is never half recorded
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start begins a span named name as a child of the span in ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err on span, if any, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject adds the trace context of ctx to outgoing request headers so the
+// next hop joins the trace
+func Inject(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// Link returns the attributes identifying a short link on spans
+func Link(domain, shortCode string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("link.domain", domain),
+		attribute.String("link.code", shortCode),
+	}
+}
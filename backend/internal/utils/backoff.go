@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"context"
+	"time"
+)
+
+// RetryWithBackoff calls fn until it succeeds or ctx ends, doubling the wait
+// between attempts from initial up to max. It returns fn's last error
+func RetryWithBackoff(ctx context.Context, initial, max time.Duration, fn func(ctx context.Context) error) error {
+	delay := initial
+	for {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > max {
+			delay = max
+		}
+	}
+}
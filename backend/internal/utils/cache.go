@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cacheVary lists the request headers that change an API response's body:
+// who is asking, which workspace, and the negotiated format
+const cacheVary = "Authorization, X-API-Key, X-Organization, X-Response-Casing, X-Response-Envelope"
+
+// RespondWithETag writes payload like RespondWithJSON, tagged with an ETag
+// and cacheable privately for maxAge (0 means revalidate every time)
+// A request whose If-None-Match matches gets an empty 304 instead
+func RespondWithETag(c *gin.Context, payload interface{}, maxAge time.Duration) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		RespondWithError(c, http.StatusInternalServerError, "failed to render response")
+		return
+	}
+	format := GetResponseFormat(c)
+	sum := sha256.Sum256(append(raw, fmt.Sprintf("|%s|%t", format.Casing, format.Envelope)...))
+	etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+
+	c.Header("ETag", etag)
+	c.Header("Vary", cacheVary)
+	if maxAge > 0 {
+		c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds())))
+	} else {
+		c.Header("Cache-Control", "private, no-cache")
+	}
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	RespondWithJSON(c, http.StatusOK, payload)
+}
+
+// etagMatches applies If-None-Match's weak comparison to etag
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
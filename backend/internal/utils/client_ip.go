@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+var ErrInvalidTrustedProxy = errors.New("trusted proxies must be IP addresses or CIDR ranges")
+
+// ClientIPResolver finds the address of the client behind the load
+// balancers. Forwarding headers are only believed when the connection comes
+// from a trusted proxy, so clients can't pick their own address; a nil
+// resolver trusts no proxy
+type ClientIPResolver struct {
+	trusted []netip.Prefix
+	headers []string
+}
+
+// NewClientIPResolver trusts the proxies in trusted, given as addresses or
+// CIDR ranges, to report the client address in headers, read in order
+func NewClientIPResolver(trusted, headers []string) (*ClientIPResolver, error) {
+	r := &ClientIPResolver{headers: headers}
+	for _, entry := range trusted {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(entry)
+			if addrErr != nil {
+				return nil, ErrInvalidTrustedProxy
+			}
+			prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+		r.trusted = append(r.trusted, prefix.Masked())
+	}
+	return r, nil
+}
+
+// ClientIP returns the client address of req. Behind trusted proxies it is
+// the last address in the forwarding headers that isn't a trusted proxy
+// itself; otherwise it is the peer address
+func (r *ClientIPResolver) ClientIP(req *http.Request) string {
+	remote := remoteIP(req)
+	if !r.trusts(remote) {
+		return remote
+	}
+	for _, header := range r.headers {
+		values := req.Header.Values(header)
+		if len(values) == 0 {
+			continue
+		}
+		// Each proxy appends the address it got the request from, so the
+		// chain is read from the right until it leaves the trusted proxies
+		hops := strings.Split(strings.Join(values, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			addr, err := netip.ParseAddr(hop)
+			if err != nil {
+				break
+			}
+			if i == 0 || !r.trusts(addr.Unmap().String()) {
+				return addr.Unmap().String()
+			}
+		}
+	}
+	return remote
+}
+
+// FromTrustedProxy reports whether req came through a trusted proxy, so
+// headers it sets, such as the visitor's country, can be believed
+func (r *ClientIPResolver) FromTrustedProxy(req *http.Request) bool {
+	return r.trusts(remoteIP(req))
+}
+
+func (r *ClientIPResolver) trusts(ip string) bool {
+	if r == nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range r.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP is the address of the peer the connection came from
+func remoteIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(req.RemoteAddr))
+	if err != nil {
+		return strings.TrimSpace(req.RemoteAddr)
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr.Unmap().String()
+	}
+	return host
+}
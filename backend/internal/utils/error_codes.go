@@ -0,0 +1,115 @@
+package utils
+
+import "net/http"
+
+// Error codes are the stable, machine-readable part of every error response.
+// Clients branch on them; messages are for people and may change
+const (
+	// Generic codes, used when nothing more specific applies
+	ErrCodeBadRequest         = "BAD_REQUEST"
+	ErrCodeUnauthorized       = "UNAUTHORIZED"
+	ErrCodePaymentRequired    = "PAYMENT_REQUIRED"
+	ErrCodeForbidden          = "FORBIDDEN"
+	ErrCodeNotFound           = "NOT_FOUND"
+	ErrCodeConflict           = "CONFLICT"
+	ErrCodeGone               = "GONE"
+	ErrCodeUnprocessable      = "UNPROCESSABLE"
+	ErrCodePayloadTooLarge    = "PAYLOAD_TOO_LARGE"
+	ErrCodeURITooLong         = "URI_TOO_LONG"
+	ErrCodeRateLimited        = "RATE_LIMITED"
+	ErrCodeInternal           = "INTERNAL_ERROR"
+	ErrCodeServiceUnavailable = "SERVICE_UNAVAILABLE"
+
+	// Requests
+	ErrCodeValidationFailed = "VALIDATION_FAILED"
+	ErrCodeInvalidCursor    = "INVALID_CURSOR"
+	ErrCodeInvalidQuery     = "INVALID_QUERY"
+	ErrCodeQueryTooCostly   = "QUERY_TOO_COSTLY"
+
+	// Authentication and authorization
+	ErrCodeAPIKeyRequired     = "API_KEY_REQUIRED"
+	ErrCodeInvalidAPIKey      = "INVALID_API_KEY"
+	ErrCodeInsufficientScope  = "INSUFFICIENT_SCOPE"
+	ErrCodeAdminTokenRequired = "ADMIN_TOKEN_REQUIRED"
+	ErrCodeInvalidSiteToken   = "INVALID_SITE_TOKEN"
+	ErrCodeSiteTokenExpired   = "SITE_TOKEN_EXPIRED"
+	ErrCodeInvalidManageToken = "INVALID_MANAGE_TOKEN"
+	ErrCodeOriginNotAllowed   = "ORIGIN_NOT_ALLOWED"
+	ErrCodeInvalidSignature   = "INVALID_SIGNATURE"
+	ErrCodeSandboxKey         = "SANDBOX_KEY_NOT_ALLOWED"
+
+	// Links
+	ErrCodeURLNotFound               = "URL_NOT_FOUND"
+	ErrCodeURLExpired                = "URL_EXPIRED"
+	ErrCodeURLInactive               = "URL_INACTIVE"
+	ErrCodeClickLimitReached         = "CLICK_LIMIT_REACHED"
+	ErrCodeIPBlocked                 = "IP_BLOCKED"
+	ErrCodeLinkRateLimited           = "LINK_RATE_LIMITED"
+	ErrCodeLinkSignature             = "INVALID_LINK_SIGNATURE"
+	ErrCodeAliasTaken                = "ALIAS_TAKEN"
+	ErrCodeDailyQuotaExceeded        = "DAILY_QUOTA_EXCEEDED"
+	ErrCodeMonthlyQuotaExceeded      = "MONTHLY_QUOTA_EXCEEDED"
+	ErrCodeURLNotAllowed             = "URL_NOT_ALLOWED"
+	ErrCodeEmbedDisabled             = "EMBED_DISABLED"
+	ErrCodeManageDisabled            = "MANAGE_TOKENS_DISABLED"
+	ErrCodeExportStorageMissing      = "EXPORT_STORAGE_NOT_CONFIGURED"
+	ErrCodeLeaderboardUnavailable    = "LEADERBOARD_UNAVAILABLE"
+	ErrCodeTransferNotFound          = "TRANSFER_NOT_FOUND"
+	ErrCodeTransferPending           = "TRANSFER_PENDING"
+	ErrCodeTransferClosed            = "TRANSFER_CLOSED"
+	ErrCodeTransferDomain            = "TRANSFER_DOMAIN_NOT_USABLE"
+	ErrCodeChatDisabled              = "CHAT_INTEGRATION_DISABLED"
+	ErrCodeDestinationRuleConfigured = "DESTINATION_RULE_CONFIGURED"
+	ErrCodeDestinationRuleNotFound   = "DESTINATION_RULE_NOT_FOUND"
+	ErrCodeLinkMirrorDisabled        = "LINK_MIRROR_DISABLED"
+
+	// Domains
+	ErrCodeUnknownDomain        = "UNKNOWN_DOMAIN"
+	ErrCodeDomainNotVerified    = "DOMAIN_NOT_VERIFIED"
+	ErrCodeDomainTaken          = "DOMAIN_TAKEN"
+	ErrCodeDomainNotFound       = "DOMAIN_NOT_FOUND"
+	ErrCodeVerificationNotFound = "VERIFICATION_RECORD_NOT_FOUND"
+	ErrCodeSitemapNotFound      = "SITEMAP_NOT_FOUND"
+
+	// API keys, organizations, campaigns and accounts
+	ErrCodeAPIKeyNotFound       = "API_KEY_NOT_FOUND"
+	ErrCodeAPIKeyRevoked        = "API_KEY_REVOKED"
+	ErrCodeAPIKeyRotated        = "API_KEY_ALREADY_ROTATED"
+	ErrCodeOrganizationNotFound = "ORGANIZATION_NOT_FOUND"
+	ErrCodeMemberNotFound       = "MEMBER_NOT_FOUND"
+	ErrCodeOrgAdminRequired     = "ORGANIZATION_ADMIN_REQUIRED"
+	ErrCodeLastOrgAdmin         = "LAST_ORGANIZATION_ADMIN"
+	ErrCodeCampaignNotFound     = "CAMPAIGN_NOT_FOUND"
+	ErrCodeCampaignFull         = "CAMPAIGN_FULL"
+	ErrCodeWorkspaceNotFound    = "CHAT_WORKSPACE_NOT_FOUND"
+	ErrCodeNotShadowBanned      = "NOT_SHADOW_BANNED"
+	ErrCodeUnknownAPIVersion    = "UNKNOWN_API_VERSION"
+	ErrCodeRouteNotFound        = "ROUTE_NOT_FOUND"
+)
+
+// statusErrorCodes are the generic codes of error statuses
+var statusErrorCodes = map[int]string{
+	http.StatusBadRequest:            ErrCodeBadRequest,
+	http.StatusUnauthorized:          ErrCodeUnauthorized,
+	http.StatusPaymentRequired:       ErrCodePaymentRequired,
+	http.StatusForbidden:             ErrCodeForbidden,
+	http.StatusNotFound:              ErrCodeNotFound,
+	http.StatusConflict:              ErrCodeConflict,
+	http.StatusGone:                  ErrCodeGone,
+	http.StatusRequestEntityTooLarge: ErrCodePayloadTooLarge,
+	http.StatusRequestURITooLong:     ErrCodeURITooLong,
+	http.StatusUnprocessableEntity:   ErrCodeUnprocessable,
+	http.StatusTooManyRequests:       ErrCodeRateLimited,
+	http.StatusServiceUnavailable:    ErrCodeServiceUnavailable,
+}
+
+// StatusErrorCode is the generic code of an error status
+func StatusErrorCode(status int) string {
+	if code, ok := statusErrorCodes[status]; ok {
+		return code
+	}
+	if status < http.StatusInternalServerError {
+		return ErrCodeBadRequest
+	}
+	return ErrCodeInternal
+}
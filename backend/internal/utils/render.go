@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	CasingSnake = "snake"
+	CasingCamel = "camel"
+
+	responseFormatKey = "response_format"
+)
+
+// ResponseFormat describes how JSON responses are rendered for a request
+type ResponseFormat struct {
+	Casing   string
+	Envelope bool
+	// Version is the API version serving the request; envelopes carry it in
+	// meta.api_version
+	Version string
+}
+
+// Envelope is the {data, error, meta} wrapper used when a client opts in
+type Envelope struct {
+	Data  interface{}            `json:"data"`
+	Error interface{}            `json:"error"`
+	Meta  map[string]interface{} `json:"meta"`
+}
+
+// SetResponseFormat stores the negotiated format on the request context
+func SetResponseFormat(c *gin.Context, format ResponseFormat) {
+	c.Set(responseFormatKey, format)
+}
+
+// GetResponseFormat returns the negotiated format, defaulting to plain snake_case
+func GetResponseFormat(c *gin.Context) ResponseFormat {
+	if v, ok := c.Get(responseFormatKey); ok {
+		if format, ok := v.(ResponseFormat); ok {
+			return format
+		}
+	}
+	return ResponseFormat{Casing: CasingSnake}
+}
+
+func render(c *gin.Context, code int, format ResponseFormat, payload interface{}) {
+	if format.Casing != CasingCamel {
+		c.JSON(code, payload)
+		return
+	}
+
+	// Round-trip through JSON so struct tags stay the single source of field names
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "failed to render response", Code: ErrCodeInternal})
+		return
+	}
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "failed to render response", Code: ErrCodeInternal})
+		return
+	}
+	c.JSON(code, camelizeKeys(generic))
+}
+
+func camelizeKeys(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(value))
+		for k, child := range value {
+			out[snakeToCamel(k)] = camelizeKeys(child)
+		}
+		return out
+	case []interface{}:
+		for i, child := range value {
+			value[i] = camelizeKeys(child)
+		}
+		return value
+	default:
+		return v
+	}
+}
+
+func snakeToCamel(s string) string {
+	if !strings.Contains(s, "_") {
+		return s
+	}
+	parts := strings.Split(s, "_")
+	var b strings.Builder
+	b.WriteString(parts[0])
+	for _, p := range parts[1:] {
+		if p == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(p[:1]))
+		b.WriteString(p[1:])
+	}
+	return b.String()
+}
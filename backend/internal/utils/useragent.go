@@ -0,0 +1,26 @@
+package utils
+
+import "strings"
+
+// Device is the coarse platform class derived from a User-Agent
+type Device string
+
+const (
+	DeviceIOS     Device = "ios"
+	DeviceAndroid Device = "android"
+	DeviceDesktop Device = "desktop"
+)
+
+// ParseDevice classifies a User-Agent string as iOS, Android or desktop
+// Anything that is not recognisably iOS or Android is treated as desktop
+func ParseDevice(userAgent string) Device {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ipod"):
+		return DeviceIOS
+	case strings.Contains(ua, "android"):
+		return DeviceAndroid
+	default:
+		return DeviceDesktop
+	}
+}
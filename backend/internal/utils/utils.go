@@ -1,17 +1,75 @@
 package utils
 
 import (
+	"net/http"
+
 	"github.com/gin-gonic/gin"
+	"github.com/ranjanshahajishitole/url-shortener/backend/internal/validators"
 )
 
+// ErrorResponse is the body of every error. Code is one of the ErrCode*
+// constants; Errors lists the offending fields when the request failed validation
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error  string                  `json:"error"`
+	Code   string                  `json:"code"`
+	Errors []validators.FieldError `json:"errors,omitempty"`
 }
 
+// RespondWithError writes an error in the format negotiated for this request,
+// with the generic code of its status
 func RespondWithError(c *gin.Context, code int, message string) {
-	c.JSON(code, ErrorResponse{Error: message})
+	RespondWithErrorCode(c, code, StatusErrorCode(code), message)
+}
+
+// RespondWithErrorCode is RespondWithError with a specific error code
+func RespondWithErrorCode(c *gin.Context, status int, code, message string) {
+	respondWithErrorResponse(c, status, ErrorResponse{Error: message, Code: code})
 }
 
+// RespondWithValidationError writes a 400 listing every field that failed validation
+func RespondWithValidationError(c *gin.Context, errs validators.Errors) {
+	respondWithErrorResponse(c, http.StatusBadRequest, ErrorResponse{
+		Error:  "Validation failed",
+		Code:   ErrCodeValidationFailed,
+		Errors: errs,
+	})
+}
+
+func respondWithErrorResponse(c *gin.Context, code int, response ErrorResponse) {
+	format := GetResponseFormat(c)
+	if format.Envelope {
+		render(c, code, format, Envelope{
+			Error: response,
+			Meta:  envelopeMeta(format, nil),
+		})
+		return
+	}
+	render(c, code, format, response)
+}
+
+// RespondWithJSON writes payload in the format negotiated for this request
 func RespondWithJSON(c *gin.Context, code int, payload interface{}) {
-	c.JSON(code, payload)
+	RespondWithMeta(c, code, payload, nil)
+}
+
+// RespondWithMeta is RespondWithJSON with extra metadata for the envelope format
+// Meta is dropped when the client did not ask for an envelope
+func RespondWithMeta(c *gin.Context, code int, payload interface{}, meta map[string]interface{}) {
+	format := GetResponseFormat(c)
+	if format.Envelope {
+		render(c, code, format, Envelope{Data: payload, Meta: envelopeMeta(format, meta)})
+		return
+	}
+	render(c, code, format, payload)
+}
+
+// envelopeMeta is meta plus the API version, if the request has one
+func envelopeMeta(format ResponseFormat, meta map[string]interface{}) map[string]interface{} {
+	if meta == nil {
+		meta = map[string]interface{}{}
+	}
+	if format.Version != "" {
+		meta["api_version"] = format.Version
+	}
+	return meta
 }
@@ -0,0 +1,227 @@
+package validators
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Field error codes returned to clients; they are part of the API contract
+const (
+	CodeRequired         = "required"
+	CodeInvalid          = "invalid"
+	CodeInvalidType      = "invalid_type"
+	CodeMalformedBody    = "malformed_body"
+	CodeInvalidURL       = "invalid_url"
+	CodeURLTooLong       = "url_too_long"
+	CodeSchemeNotAllowed = "scheme_not_allowed"
+	CodeURLNotAllowed    = "url_not_allowed"
+	CodeDomainBlocked    = "domain_blocked"
+	CodeDomainNotAllowed = "domain_not_allowed"
+	CodeInvalidCharset   = "invalid_charset"
+	CodeInvalidAlias     = "invalid_alias"
+	CodeOutOfRange       = "out_of_range"
+	CodeInvalidDuration  = "invalid_duration"
+	CodeInvalidHost      = "invalid_host"
+	CodeInvalidCIDR      = "invalid_cidr"
+	CodeTooLong          = "too_long"
+)
+
+// MaxURLLength is the longest destination URL accepted, in bytes
+const MaxURLLength = 2048
+
+// MaxTitleLength, MaxDescriptionLength and MaxNotesLength bound a link's
+// free-text metadata, in characters
+const (
+	MaxTitleLength       = 200
+	MaxDescriptionLength = 500
+	MaxNotesLength       = 2000
+)
+
+// MaxTags is how many tags a link may carry, and MaxTagLength the longest
+// tag in characters
+const (
+	MaxTags      = 20
+	MaxTagLength = 50
+)
+
+// AllowedSchemes are the destination URL schemes links may point to
+var AllowedSchemes = []string{"http", "https"}
+
+// FieldError describes one problem with one request field
+// Field is the JSON name (dotted for nested fields), or empty for the whole body
+type FieldError struct {
+	Code    string `json:"code"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors is a list of field errors; an empty list means the request is valid
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Add appends a field error
+func (e *Errors) Add(code, field, message string) {
+	*e = append(*e, FieldError{Code: code, Field: field, Message: message})
+}
+
+// Err returns e as an error, or nil when there are no field errors
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Merge appends the errors in other for fields e does not already report
+func (e Errors) Merge(other Errors) Errors {
+	for _, fe := range other {
+		if !slices.ContainsFunc(e, func(existing FieldError) bool { return existing.Field == fe.Field }) {
+			e = append(e, fe)
+		}
+	}
+	return e
+}
+
+// NewError is a single field error
+func NewError(code, field, message string) Errors {
+	return Errors{{Code: code, Field: field, Message: message}}
+}
+
+// CheckURL adds an error when raw is not an absolute URL with an allowed
+// scheme within MaxURLLength. Empty values are left to the required check
+func (e *Errors) CheckURL(field, raw string) {
+	if raw == "" {
+		return
+	}
+	if len(raw) > MaxURLLength {
+		e.Add(CodeURLTooLong, field, fmt.Sprintf("%s must be at most %d characters", field, MaxURLLength))
+		return
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		e.Add(CodeInvalidURL, field, field+" must be an absolute URL")
+		return
+	}
+	if !slices.Contains(AllowedSchemes, strings.ToLower(u.Scheme)) {
+		e.Add(CodeSchemeNotAllowed, field, fmt.Sprintf("%s scheme must be one of %s", field, strings.Join(AllowedSchemes, ", ")))
+	}
+}
+
+// CheckShortCode adds an error when code is set but is not 3-32 letters,
+// digits, '-' or '_'
+func (e *Errors) CheckShortCode(field, code string) {
+	if code != "" && !IsValidShortCode(code) {
+		e.Add(CodeInvalidCharset, field, field+" must be 3-32 letters, digits, '-' or '_'")
+	}
+}
+
+// CheckLength adds an error when value has more than max characters
+func (e *Errors) CheckLength(field, value string, max int) {
+	if utf8.RuneCountInString(value) > max {
+		e.Add(CodeTooLong, field, fmt.Sprintf("%s must be at most %d characters", field, max))
+	}
+}
+
+// CheckOrigins adds an error for each entry of origins that isn't an
+// origin pattern
+func (e *Errors) CheckOrigins(field string, origins []string) {
+	for i, origin := range origins {
+		if !ValidOriginPattern(origin) {
+			e.Add(CodeInvalid, fmt.Sprintf("%s[%d]", field, i), field+" entries must be an origin like https://example.com, chrome-extension://* or https://*.example.com")
+		}
+	}
+}
+
+// CheckDuration adds an error when value is set but is not a positive duration
+func (e *Errors) CheckDuration(field, value string) {
+	if value == "" {
+		return
+	}
+	if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+		e.Add(CodeInvalidDuration, field, field+" must be a positive duration such as 72h")
+	}
+}
+
+// FromBindError turns a gin binding error (decoding or struct-tag validation)
+// into field errors
+func FromBindError(err error) Errors {
+	var fieldErrs Errors
+	if errors.As(err, &fieldErrs) {
+		return fieldErrs
+	}
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		out := make(Errors, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			out = append(out, fromValidationError(fe))
+		}
+		return out
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return NewError(CodeInvalidType, typeErr.Field, fmt.Sprintf("%s must be a %s", typeErr.Field, typeErr.Type.Kind()))
+	}
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return NewError(CodeMalformedBody, "", "request body is not valid JSON")
+	}
+	if errors.Is(err, io.EOF) {
+		return NewError(CodeRequired, "", "request body is required")
+	}
+	return NewError(CodeInvalid, "", err.Error())
+}
+
+// fromValidationError maps a struct-tag failure to a field error
+func fromValidationError(fe validator.FieldError) FieldError {
+	field := fe.Namespace()
+	// Drop the request type name: "ShortenURLRequest.utm.source" -> "utm.source"
+	if i := strings.Index(field, "."); i >= 0 {
+		field = field[i+1:]
+	}
+	switch fe.Tag() {
+	case "required":
+		return FieldError{Code: CodeRequired, Field: field, Message: field + " is required"}
+	case "url":
+		return FieldError{Code: CodeInvalidURL, Field: field, Message: field + " must be an absolute URL"}
+	case "min", "gte":
+		return FieldError{Code: CodeOutOfRange, Field: field, Message: fmt.Sprintf("%s must be at least %s", field, fe.Param())}
+	case "max", "lte":
+		return FieldError{Code: CodeOutOfRange, Field: field, Message: fmt.Sprintf("%s must be at most %s", field, fe.Param())}
+	case "oneof":
+		return FieldError{Code: CodeInvalid, Field: field, Message: fmt.Sprintf("%s must be one of %s", field, strings.ReplaceAll(fe.Param(), " ", ", "))}
+	default:
+		return FieldError{Code: CodeInvalid, Field: field, Message: field + " is invalid"}
+	}
+}
+
+// JSONFieldName is a validator tag-name function reporting fields by their
+// JSON name, falling back to the form name and then the Go name
+func JSONFieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "-" {
+			continue
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
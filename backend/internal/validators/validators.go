@@ -1,8 +1,16 @@
 package validators
 
 import (
+	"errors"
+	"fmt"
+	"net"
 	"net/url"
+	"path"
 	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/idna"
 )
 
 func IsValidURL(str string) bool {
@@ -16,3 +24,296 @@ var urlRegex = regexp.MustCompile(`^(http|https)://[a-zA-Z0-9\-\.]+\.[a-zA-Z]{2,
 func IsValidURLRegex(str string) bool {
 	return urlRegex.MatchString(str)
 }
+
+// URLPolicy is a set of extra restrictions on destination URLs
+// The zero value only requires a scheme and a host
+type URLPolicy struct {
+	HTTPSOnly         bool
+	StandardPortsOnly bool
+	NoIPHosts         bool
+	NoUserinfo        bool
+}
+
+// StrictURLPolicy is the profile for deployments that must forbid plain-http
+// and IP-based destinations
+var StrictURLPolicy = URLPolicy{
+	HTTPSOnly:         true,
+	StandardPortsOnly: true,
+	NoIPHosts:         true,
+	NoUserinfo:        true,
+}
+
+// Check returns why u violates the policy, or nil if it is allowed
+func (p URLPolicy) Check(u *url.URL) error {
+	if p.HTTPSOnly && u.Scheme != "https" {
+		return errors.New("only https URLs are allowed")
+	}
+	if p.StandardPortsOnly {
+		if port := u.Port(); port != "" && port != defaultPorts[u.Scheme] {
+			return errors.New("only the scheme's standard port is allowed")
+		}
+	}
+	if p.NoIPHosts && isIPHost(u.Hostname()) {
+		return errors.New("IP address hosts are not allowed")
+	}
+	if p.NoUserinfo && u.User != nil {
+		return errors.New("credentials in URLs are not allowed")
+	}
+	return nil
+}
+
+var defaultPorts = map[string]string{"http": "80", "https": "443"}
+
+// isIPHost reports whether host is an IP literal, including the integer and
+// hex forms browsers also resolve as IPv4 (http://2130706433/)
+func isIPHost(host string) bool {
+	if net.ParseIP(host) != nil {
+		return true
+	}
+	if _, err := strconv.ParseUint(host, 0, 32); err == nil {
+		return true
+	}
+	return false
+}
+
+// URLNormalizer canonicalizes destination URLs before they are stored and
+// compared, so equivalent spellings of a URL dedupe to the same link
+type URLNormalizer struct {
+	// SortQuery orders query parameters by name. Off by default since some
+	// destinations treat parameter order as significant
+	SortQuery bool
+}
+
+// Normalize returns rawURL with a lowercase scheme, a lowercase host in
+// punycode, no default port or fragment, and "/" for an empty path
+func (n URLNormalizer) Normalize(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", errors.New("URL must have a scheme and a host")
+	}
+	if err := canonicalize(u, n.SortQuery); err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// canonicalize rewrites u in place into its canonical form
+func canonicalize(u *url.URL, sortQuery bool) error {
+	u.Scheme = strings.ToLower(u.Scheme)
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	if !isASCII(host) {
+		ascii, err := idna.Lookup.ToASCII(host)
+		if err != nil {
+			return fmt.Errorf("invalid international host name: %w", err)
+		}
+		host = ascii
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+	u.Fragment, u.RawFragment = "", ""
+	if u.Path == "" {
+		u.Path, u.RawPath = "/", ""
+	}
+	if sortQuery && u.RawQuery != "" {
+		u.RawQuery = u.Query().Encode()
+	}
+	u.ForceQuery = false
+	return nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// URLMatchKey reduces rawURL to the form used to find links to the same
+// destination: its canonical form with query parameters sorted and no
+// trailing slash. Unparseable input is returned trimmed
+func URLMatchKey(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || canonicalize(u, true) != nil {
+		return rawURL
+	}
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = strings.TrimRight(u.RawPath, "/")
+	return u.String()
+}
+
+// URLPolicies picks the URL policy for each account: the deployment default,
+// or the strict profile for accounts that opted into it
+type URLPolicies struct {
+	defaultPolicy  URLPolicy
+	strictAccounts map[string]struct{}
+}
+
+// NewURLPolicies builds the policy set from the deployment default and the
+// accounts that always get StrictURLPolicy
+func NewURLPolicies(defaultPolicy URLPolicy, strictAccounts []string) *URLPolicies {
+	p := &URLPolicies{defaultPolicy: defaultPolicy, strictAccounts: make(map[string]struct{}, len(strictAccounts))}
+	for _, account := range strictAccounts {
+		p.strictAccounts[account] = struct{}{}
+	}
+	return p
+}
+
+// For returns the policy applied to links of accountID ("" for anonymous links)
+func (p *URLPolicies) For(accountID string) URLPolicy {
+	if _, ok := p.strictAccounts[accountID]; ok && accountID != "" {
+		return StrictURLPolicy
+	}
+	return p.defaultPolicy
+}
+
+// shortCodePattern is the format every short code (generated or custom alias) must match
+var shortCodePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,32}$`)
+
+// IsValidShortCode reports whether code has the allowed charset and length
+func IsValidShortCode(code string) bool {
+	return shortCodePattern.MatchString(code)
+}
+
+// externalIDPattern is the format of the IDs provisioning tools give the
+// organizations and keys they manage
+var externalIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:-]{0,127}$`)
+
+// IsValidExternalID reports whether id is a usable external ID
+func IsValidExternalID(id string) bool {
+	return externalIDPattern.MatchString(id)
+}
+
+// hostPattern matches a lowercase DNS host name with at least two labels
+var hostPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+// NormalizeHost lowercases host, drops any port and trailing dot, and reports
+// whether the result is a valid host name
+func NormalizeHost(host string) (string, bool) {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(host, ".")
+	return host, len(host) <= 253 && hostPattern.MatchString(host)
+}
+
+// hostPatternChars matches a destination host pattern: host name or IPv4
+// characters and '*' wildcards
+var hostPatternChars = regexp.MustCompile(`^[a-z0-9*]([a-z0-9.*-]*[a-z0-9*])?$`)
+
+// NormalizeHostPattern lowercases a destination host pattern and drops any
+// trailing dot, and reports whether the result is a valid pattern
+func NormalizeHostPattern(pattern string) (string, bool) {
+	pattern = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(pattern)), ".")
+	return pattern, len(pattern) <= 253 && hostPatternChars.MatchString(pattern)
+}
+
+// MatchHostPattern reports whether host matches a pattern normalized with
+// NormalizeHostPattern. A host name also matches through any domain it is a
+// subdomain of, so "example.com" covers www.example.com while
+// "*.example.com" covers only the subdomains. IP addresses match whole
+func MatchHostPattern(pattern, host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if ok, _ := path.Match(pattern, host); ok {
+		return true
+	}
+	if isIPHost(host) {
+		return false
+	}
+	for {
+		dot := strings.IndexByte(host, '.')
+		if dot < 0 {
+			return false
+		}
+		host = host[dot+1:]
+		if ok, _ := path.Match(pattern, host); ok {
+			return true
+		}
+	}
+}
+
+// ReservedWords is the set of short codes that would collide with routes
+type ReservedWords struct {
+	words map[string]struct{}
+}
+
+// NewReservedWords builds a case-insensitive reserved-word set
+func NewReservedWords(words []string) *ReservedWords {
+	r := &ReservedWords{words: make(map[string]struct{}, len(words))}
+	for _, w := range words {
+		r.words[strings.ToLower(strings.TrimSpace(w))] = struct{}{}
+	}
+	return r
+}
+
+// Contains reports whether code is reserved
+func (r *ReservedWords) Contains(code string) bool {
+	_, ok := r.words[strings.ToLower(code)]
+	return ok
+}
+
+// Allowed reports whether code is well-formed and not reserved
+func (r *ReservedWords) Allowed(code string) bool {
+	return IsValidShortCode(code) && !r.Contains(code)
+}
+
+// ValidOriginPattern reports whether pattern is "*", an origin such as
+// https://example.com, any origin of a scheme such as chrome-extension://*,
+// or the subdomains of a host such as https://*.example.com
+func ValidOriginPattern(pattern string) bool {
+	if pattern == "*" {
+		return true
+	}
+	scheme, host, ok := strings.Cut(pattern, "://")
+	if !ok || scheme == "" || strings.Contains(scheme, "*") {
+		return false
+	}
+	if host == "*" {
+		return true
+	}
+	host = strings.TrimPrefix(host, "*.")
+	u, err := url.Parse(scheme + "://" + host)
+	return err == nil && u.Host == host && !strings.Contains(host, "*") && u.Path == "" && u.RawQuery == ""
+}
+
+// MatchOrigin reports whether a request's Origin header matches any of
+// patterns, ignoring case. Wildcards only stand for a whole host or whole
+// leading labels of one, so https://*.example.com doesn't match
+// https://example.com.evil.net. An empty origin matches nothing
+func MatchOrigin(patterns []string, origin string) bool {
+	if origin == "" {
+		return false
+	}
+	scheme, host, _ := strings.Cut(strings.ToLower(origin), "://")
+	for _, pattern := range patterns {
+		if pattern == "*" {
+			return true
+		}
+		patternScheme, patternHost, _ := strings.Cut(strings.ToLower(pattern), "://")
+		if patternScheme != scheme {
+			continue
+		}
+		if parent, ok := strings.CutPrefix(patternHost, "*."); ok {
+			if strings.HasSuffix(host, "."+parent) {
+				return true
+			}
+		} else if patternHost == "*" || patternHost == host {
+			return true
+		}
+	}
+	return false
+}